@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"database/sql"
+	"firego-wallet-service/internal/apikey"
+	"firego-wallet-service/internal/audit"
+	"firego-wallet-service/internal/cache"
+	"firego-wallet-service/internal/config"
+	"firego-wallet-service/internal/deposit"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/handler"
+	"firego-wallet-service/internal/idempotency"
+	"firego-wallet-service/internal/leader"
+	"firego-wallet-service/internal/ledger"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/metrics"
+	"firego-wallet-service/internal/openapi"
+	"firego-wallet-service/internal/payout"
+	"firego-wallet-service/internal/ratelimit"
+	"firego-wallet-service/internal/reconcile"
+	"firego-wallet-service/internal/repository"
+	"firego-wallet-service/internal/schedule"
+	"firego-wallet-service/internal/sweep"
+	"firego-wallet-service/internal/tracing"
+	"firego-wallet-service/internal/transfer"
+	"gorm.io/gorm"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// app is the service wired from its configuration: the routes and the background workers, without the listener
+type app struct {
+	handler       http.Handler
+	walletHandler *handler.WalletHandler
+	transfers     *transfer.Service
+	// workers run until their context is cancelled
+	workers []func(ctx context.Context)
+	// electors are released once the workers have stopped
+	electors []*leader.Elector
+}
+
+// newApp wires the repositories, handlers and workers. The webhook public key is only used when webhooks are enabled.
+func newApp(cfg *config.Config, db *gorm.DB, sqlDB *sql.DB, fireblocksClient *fireblocks.Client, webhookPublicKey *rsa.PublicKey, startedAt time.Time) *app {
+	a := &app{}
+	balances := newBalanceCache(cfg, fireblocksClient)
+	limiter := newRateLimiter(cfg, sqlDB)
+	idempotencyKeys := newIdempotencyKeys(cfg, sqlDB)
+
+	walletRepo := repository.NewWalletRepository(db)
+	depositRepo := repository.NewDepositRepository(db)
+	transferRepo := repository.NewTransferRepository(db)
+	ledgerRepo := repository.NewLedgerRepository(db)
+	reconciliationRepo := repository.NewReconciliationRepository(db)
+	walletLedger := ledger.New(ledgerRepo)
+	auditLog := audit.NewLog(repository.NewAuditRepository(db))
+	payoutElector := leader.NewElector(sqlDB, "payouts")
+	a.electors = append(a.electors, payoutElector)
+	payouts := payout.New(repository.NewPayoutRepository(db), transferRepo, fireblocksClient, balances, payoutElector, cfg.Payouts.RetryInterval)
+	a.workers = append(a.workers, payouts.Run)
+	// the transfers are checked against the balance less the payouts not submitted yet
+	a.transfers = transfer.NewService(walletRepo, transferRepo, fireblocksClient, balances, payouts)
+	a.walletHandler = handler.NewWalletHandler(walletRepo, fireblocksClient, balances, a.transfers)
+	walletBatchHandler := handler.NewWalletBatchHandler(walletRepo, fireblocksClient, handler.BatchLimits{
+		MaxSize:     cfg.Wallets.BatchMaxSize,
+		Concurrency: cfg.Wallets.BatchConcurrency,
+	})
+	payoutHandler := handler.NewPayoutHandler(walletRepo, payouts, balances, cfg.Payouts.MaxLines)
+	scheduleRepo := repository.NewTransferScheduleRepository(db)
+	scheduleElector := leader.NewElector(sqlDB, "schedules")
+	a.electors = append(a.electors, scheduleElector)
+	a.workers = append(a.workers, schedule.NewScheduler(scheduleRepo, a.transfers, transferRepo, fireblocksClient, scheduleElector, cfg.Schedules.Interval).Run)
+	scheduleHandler := handler.NewScheduleHandler(walletRepo, scheduleRepo)
+	sweepRepo := repository.NewSweepRepository(db)
+	if len(cfg.Sweeps.Rules) > 0 {
+		sweepElector := leader.NewElector(sqlDB, "sweeps")
+		a.electors = append(a.electors, sweepElector)
+		sweeper := sweep.NewSweeper(sweepRepo, walletRepo, transferRepo, fireblocksClient, payouts, balances, sweepElector, sweep.Config{
+			Rules:    cfg.Sweeps.Rules,
+			Interval: cfg.Sweeps.Interval,
+		})
+		a.workers = append(a.workers, sweeper.Run)
+	}
+	sweepHandler := handler.NewSweepHandler(sweepRepo)
+	depositHandler := handler.NewDepositHandler(walletRepo, depositRepo)
+	ledgerHandler := handler.NewLedgerHandler(walletRepo, walletLedger)
+	reconciliationHandler := handler.NewReconciliationHandler(reconciliationRepo)
+	auditHandler := handler.NewAuditHandler(auditLog)
+	healthHandler := handler.NewHealthHandler(sqlDB, fireblocksClient, readBuildInfo(), startedAt)
+
+	depositProcessor := deposit.NewProcessor(walletRepo, depositRepo, walletLedger, deposit.ConfirmationThresholds{
+		Default:  cfg.Deposits.DefaultConfirmations,
+		PerAsset: cfg.Deposits.Confirmations,
+	})
+	if cfg.Features.DepositPolling {
+		depositElector := leader.NewElector(sqlDB, "deposit_polling")
+		a.electors = append(a.electors, depositElector)
+		depositPoller := deposit.NewPoller(fireblocksClient, depositRepo, depositProcessor, repository.NewPollerCursorRepository(db), depositElector, cfg.Deposits.PollInterval, cfg.Deposits.PollLookback)
+		a.workers = append(a.workers, depositPoller.Run)
+	}
+
+	transferProcessor := transfer.NewProcessor(transferRepo, walletRepo, walletLedger)
+	if cfg.Features.TransferPolling {
+		transferElector := leader.NewElector(sqlDB, "transfer_polling")
+		a.electors = append(a.electors, transferElector)
+		transferPoller := transfer.NewPoller(fireblocksClient, transferRepo, transferProcessor, transferElector, cfg.Transfers.PollInterval)
+		a.workers = append(a.workers, transferPoller.Run)
+	}
+
+	if cfg.Features.Reconciliation {
+		reconciler := reconcile.NewReconciler(walletRepo, transferRepo, ledgerRepo, reconciliationRepo, fireblocksClient, reconcile.Config{
+			TransferWindow: cfg.Reconciliation.TransferWindow,
+			IgnoredVaults:  cfg.Reconciliation.IgnoredVaults,
+		})
+		reconciliationElector := leader.NewElector(sqlDB, "reconciliation")
+		a.electors = append(a.electors, reconciliationElector)
+		reconciliationScheduler := reconcile.NewScheduler(reconciler, reconciliationElector, cfg.Reconciliation.Interval)
+		a.workers = append(a.workers, reconciliationScheduler.Run)
+	}
+
+	// validate checks the requests against the OpenAPI specification, inside the rate limiter and the audit log so that
+	// rejected requests are still counted and recorded. Requests replayed from their Idempotency-Key skip it, they
+	// were validated the first time.
+	validate := func(handler http.HandlerFunc) http.HandlerFunc { return handler }
+	if cfg.Features.RequestValidation {
+		validate = openapi.NewValidator(openapi.MustLoad()).Wrap
+	}
+
+	// authenticate requires an API key on the wallet endpoints, and authenticateAdmin a key issued for them on the admin
+	// endpoints
+	authenticate := func(handler http.HandlerFunc) http.HandlerFunc { return handler }
+	authenticateAdmin := authenticate
+	if cfg.Features.APIKeys {
+		apiKeys := apikey.New(repository.NewAPIKeyRepository(db))
+		authenticate = apiKeys.Wrap
+		authenticateAdmin = apiKeys.WrapAdmin
+	} else {
+		slog.Warn("API keys are not required")
+	}
+
+	// limit counts the requests per IP before they are authenticated, so that requests with made-up keys are limited
+	// too, and then per verified API key
+	limit := func(class string, handler http.HandlerFunc) http.HandlerFunc {
+		return limiter.Wrap(class, authenticate(limiter.WrapKey(class, handler)))
+	}
+	// limitAdmin limits the admin endpoints like the wallet reads, they are open to anyone reaching the service
+	// without API keys
+	limitAdmin := func(handler http.HandlerFunc) http.HandlerFunc {
+		return limiter.Wrap(ratelimit.Read, authenticateAdmin(limiter.WrapKey(ratelimit.Read, handler)))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", healthHandler.Liveness)
+	mux.HandleFunc("GET /readyz", healthHandler.Readiness)
+	mux.HandleFunc("GET /status", healthHandler.Status)
+	if cfg.Features.Metrics {
+		mux.Handle("GET /metrics", metrics.Handler())
+	}
+	mux.HandleFunc("POST /wallets", limit(ratelimit.Write, auditLog.Wrap("wallet.create", idempotencyKeys.Wrap(validate(a.walletHandler.CreateWallet)))))
+	mux.HandleFunc("POST /wallets:batch", limit(ratelimit.Write, auditLog.Wrap("wallet.batch_create", idempotencyKeys.Wrap(validate(walletBatchHandler.CreateWallets)))))
+	mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/balance", limit(ratelimit.Read, validate(a.walletHandler.GetWalletBalance)))
+	mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/address", limit(ratelimit.Read, validate(a.walletHandler.GetDepositAddress)))
+	mux.HandleFunc("POST /wallets/{walletId}/assets/{assetId}/addresses", limit(ratelimit.Write, auditLog.Wrap("deposit_address.create", idempotencyKeys.Wrap(validate(a.walletHandler.CreateDepositAddress)))))
+	mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/addresses", limit(ratelimit.Read, validate(a.walletHandler.ListDepositAddresses)))
+	mux.HandleFunc("POST /wallets/{walletId}/transactions", limit(ratelimit.Transfer, auditLog.Wrap("transfer.initiate", idempotencyKeys.Wrap(validate(a.walletHandler.InitiateTransfer)))))
+	mux.HandleFunc("POST /wallets/{walletId}/payouts", limit(ratelimit.Transfer, auditLog.Wrap("payout.create", idempotencyKeys.Wrap(validate(payoutHandler.CreatePayout)))))
+	mux.HandleFunc("GET /wallets/{walletId}/payouts/{payoutId}", limit(ratelimit.Read, validate(payoutHandler.GetPayout)))
+	mux.HandleFunc("POST /wallets/{walletId}/schedules", limit(ratelimit.Write, auditLog.Wrap("schedule.create", idempotencyKeys.Wrap(validate(scheduleHandler.CreateSchedule)))))
+	mux.HandleFunc("GET /wallets/{walletId}/schedules", limit(ratelimit.Read, validate(scheduleHandler.ListSchedules)))
+	mux.HandleFunc("POST /wallets/{walletId}/schedules/{scheduleId}/pause", limit(ratelimit.Write, auditLog.Wrap("schedule.pause", idempotencyKeys.Wrap(validate(scheduleHandler.PauseSchedule)))))
+	mux.HandleFunc("POST /wallets/{walletId}/schedules/{scheduleId}/resume", limit(ratelimit.Write, auditLog.Wrap("schedule.resume", idempotencyKeys.Wrap(validate(scheduleHandler.ResumeSchedule)))))
+	mux.HandleFunc("DELETE /wallets/{walletId}/schedules/{scheduleId}", limit(ratelimit.Write, auditLog.Wrap("schedule.delete", validate(scheduleHandler.DeleteSchedule))))
+	mux.HandleFunc("GET /wallets/{walletId}/schedules/{scheduleId}/runs", limit(ratelimit.Read, validate(scheduleHandler.ListScheduleRuns)))
+	mux.HandleFunc("GET /wallets/{walletId}/deposits", limit(ratelimit.Read, validate(depositHandler.ListDeposits)))
+	mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/ledger/balance", limit(ratelimit.Read, validate(ledgerHandler.GetLedgerBalance)))
+	mux.HandleFunc("POST /wallets/{walletId}/assets/{assetId}/ledger/allocations", limit(ratelimit.Write, auditLog.Wrap("ledger.allocate", idempotencyKeys.Wrap(validate(ledgerHandler.CreateAllocation)))))
+	mux.HandleFunc("GET /wallets/{walletId}/ledger/entries", limit(ratelimit.Read, validate(ledgerHandler.ListLedgerEntries)))
+	mux.HandleFunc("GET /admin/reconciliation", limitAdmin(validate(reconciliationHandler.ListReports)))
+	mux.HandleFunc("GET /admin/sweeps", limitAdmin(validate(sweepHandler.ListSweeps)))
+	mux.HandleFunc("GET /admin/audit", limitAdmin(validate(auditHandler.ListAuditEntries)))
+	mux.HandleFunc("GET /admin/audit/verify", limitAdmin(validate(auditHandler.VerifyAuditLog)))
+	mux.HandleFunc("GET /openapi.json", openapi.Handler)
+	mux.HandleFunc("GET /docs", openapi.DocsHandler)
+
+	if cfg.Features.Webhooks {
+		webhookHandler := handler.NewWebhookHandler(webhookPublicKey, balances, depositProcessor, transferProcessor)
+		mux.HandleFunc("POST /webhooks/fireblocks", validate(webhookHandler.HandleFireblocksWebhook))
+	} else {
+		slog.Warn("Fireblocks webhooks are disabled")
+	}
+
+	a.handler = http.MaxBytesHandler(logging.Middleware(tracing.Middleware(metrics.Instrument(mux))), cfg.Limits.MaxRequestBodyBytes)
+	return a
+}
+
+// newBalanceCache puts the configured cache in front of the Fireblocks balance reads
+func newBalanceCache(cfg *config.Config, client cache.BalanceClient) *cache.Balances {
+	switch cfg.Cache.Backend {
+	case cache.BackendMemory:
+		return cache.NewBalances(client, cache.NewMemoryStore(), cfg.Cache.BalanceTTL)
+	case cache.BackendRedis:
+		store := cache.NewRedisStore(cache.RedisConfig{
+			Addr:     cfg.Cache.Redis.Addr,
+			Password: cfg.Cache.Redis.Password.Value(),
+			DB:       cfg.Cache.Redis.DB,
+		})
+		// the cache failing only costs Fireblocks calls, so it does not prevent starting
+		if err := store.Ping(context.Background()); err != nil {
+			slog.Warn("Redis not reachable, balances are read from Fireblocks until it is", "addr", cfg.Cache.Redis.Addr, "error", err)
+		}
+		return cache.NewBalances(client, store, cfg.Cache.BalanceTTL)
+	default:
+		return cache.NewBalances(client, nil, 0)
+	}
+}
+
+// newIdempotencyKeys keeps the responses to the POST requests with an Idempotency-Key in the configured store
+func newIdempotencyKeys(cfg *config.Config, sqlDB *sql.DB) *idempotency.Keys {
+	var store idempotency.Store
+	switch cfg.Idempotency.Store {
+	case idempotency.StorePostgres:
+		store = idempotency.NewPostgresStore(sqlDB)
+	default:
+		store = idempotency.NewMemoryStore()
+	}
+	return idempotency.New(store, cfg.Idempotency.KeyTTL)
+}
+
+// newRateLimiter limits the public endpoints, with the counters kept in the configured store
+func newRateLimiter(cfg *config.Config, sqlDB *sql.DB) *ratelimit.Limiter {
+	var store ratelimit.Store
+	switch cfg.RateLimiting.Store {
+	case ratelimit.StorePostgres:
+		store = ratelimit.NewPostgresStore(sqlDB)
+	case ratelimit.StoreRedis:
+		store = ratelimit.NewRedisStore(cache.NewRedisStore(cache.RedisConfig{
+			Addr:     cfg.Cache.Redis.Addr,
+			Password: cfg.Cache.Redis.Password.Value(),
+			DB:       cfg.Cache.Redis.DB,
+		}))
+	default:
+		store = ratelimit.NewMemoryStore()
+	}
+
+	return ratelimit.NewLimiter(store, ratelimit.Config{
+		IPLimits:          requestLimits(cfg.RateLimiting.PerIP),
+		KeyLimits:         requestLimits(cfg.RateLimiting.PerKey),
+		TrustForwardedFor: cfg.RateLimiting.TrustForwardedFor,
+	})
+}
+
+// requestLimits are the configured limits by request class
+func requestLimits(limits config.RequestLimits) map[string]ratelimit.Limit {
+	return map[string]ratelimit.Limit{
+		ratelimit.Read:     {Requests: limits.Read.Requests, Window: limits.Read.Window},
+		ratelimit.Write:    {Requests: limits.Write.Requests, Window: limits.Write.Window},
+		ratelimit.Transfer: {Requests: limits.Transfer.Requests, Window: limits.Transfer.Window},
+	}
+}
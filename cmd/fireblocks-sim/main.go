@@ -0,0 +1,117 @@
+// Command fireblocks-sim serves an in-memory Fireblocks API for local development, see package fireblockstest
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"firego-wallet-service/internal/fireblocks/fireblockstest"
+	"firego-wallet-service/internal/logging"
+	"flag"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	apiKey := flag.String("api-key", envOr("FIREBLOCKS_API_KEY", "sim-api-key"), "API key the requests must carry")
+	secretKeyPath := flag.String("secret-key-path", envOr("FIREBLOCKS_SECRET_KEY_PATH", "fireblocks_secret.key"), "private key the service signs its requests with, generated if missing")
+	webhookURL := flag.String("webhook-url", "", "URL the transaction webhooks are sent to, e.g. http://localhost:8080/webhooks/fireblocks")
+	webhookKeyPath := flag.String("webhook-key-path", "fireblocks_sim_webhook.key", "private key signing the webhooks, generated if missing")
+	webhookPublicKeyPath := flag.String("webhook-public-key-path", "fireblocks_sim_webhook.pub", "where the webhook public key is written, for FIREBLOCKS_WEBHOOK_PUBLIC_KEY_PATH")
+	stepInterval := flag.Duration("step-interval", 2*time.Second, "how often transactions move to their next status, 0 to only move them with POST /sim/advance")
+	confirmations := flag.Int("confirmations", 3, "confirmations after which transactions complete")
+	flag.Parse()
+
+	slog.SetDefault(logging.New(os.Stdout, slog.LevelInfo))
+
+	signingKey, err := loadOrGenerateKey(*secretKeyPath)
+	if err != nil {
+		fatal("failed to load the API private key", "path", *secretKeyPath, "error", err)
+	}
+	webhookKey, err := loadOrGenerateKey(*webhookKeyPath)
+	if err != nil {
+		fatal("failed to load the webhook private key", "path", *webhookKeyPath, "error", err)
+	}
+	if err = writePublicKey(*webhookPublicKeyPath, &webhookKey.PublicKey); err != nil {
+		fatal("failed to write the webhook public key", "path", *webhookPublicKeyPath, "error", err)
+	}
+
+	sim := fireblockstest.NewServer(fireblockstest.Config{
+		APIKey:        *apiKey,
+		PublicKey:     &signingKey.PublicKey,
+		WebhookURL:    *webhookURL,
+		WebhookKey:    webhookKey,
+		Confirmations: *confirmations,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *stepInterval > 0 {
+		go sim.Run(ctx, *stepInterval)
+	}
+
+	server := &http.Server{Addr: *addr, Handler: sim, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("Fireblocks simulator listening", "addr", *addr, "webhook_url", *webhookURL, "webhook_public_key_path", *webhookPublicKeyPath)
+	if err = server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("server failed", "error", err)
+	}
+}
+
+// loadOrGenerateKey reads a PEM encoded RSA private key, generating and writing one if the file does not exist
+func loadOrGenerateKey(path string) (*rsa.PrivateKey, error) {
+	keyBytes, err := os.ReadFile(path)
+	if err == nil {
+		return jwt.ParseRSAPrivateKeyFromPEM(keyBytes)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	keyBytes = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err = os.WriteFile(path, keyBytes, 0o600); err != nil {
+		return nil, err
+	}
+	slog.Info("Generated RSA private key", "path", path)
+	return key, nil
+}
+
+func writePublicKey(path string, key *rsa.PublicKey) error {
+	keyBytes, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyBytes}), 0o644)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"firego-wallet-service/internal/apikey"
+	"firego-wallet-service/internal/bootstrap"
+	"firego-wallet-service/internal/config"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/model"
+	"firego-wallet-service/internal/reconcile"
+	"firego-wallet-service/internal/repository"
+	"firego-wallet-service/pkg/client"
+	"firego-wallet-service/pkg/walletapi"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+type cli struct {
+	stdin  *bufio.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	apiURL     string
+	apiKey     string
+	actor      string
+	configPath string
+	output     string
+	yes        bool
+
+	// connect opens the maintenance backends, once, for the commands that need them
+	connect     func() (*maintenance, error)
+	maintenance *maintenance
+}
+
+type walletRepository interface {
+	GetByID(ctx context.Context, id string) (*model.Wallet, error)
+	List(ctx context.Context) ([]model.Wallet, error)
+}
+
+type transferRepository interface {
+	GetByFireblocksTxID(txID string) (*model.Transfer, error)
+}
+
+type fireblocksAPI interface {
+	GetVaultAccount(ctx context.Context, vaultAccountID string) (*fireblocks.VaultAccount, int, error)
+	GetTransaction(ctx context.Context, txID string) (*fireblocks.TransactionResponse, int, error)
+	CancelTransaction(ctx context.Context, txID string) (*fireblocks.CancelTransactionResponse, int, error)
+}
+
+type reconciliationRunner interface {
+	Run(ctx context.Context) (*model.ReconciliationReport, error)
+}
+
+// maintenance is what the commands without an endpoint work with, directly
+type maintenance struct {
+	wallets    walletRepository
+	transfers  transferRepository
+	apiKeys    *apikey.Keys
+	fireblocks fireblocksAPI
+	reconciler reconciliationRunner
+	db         *sql.DB
+}
+
+// client is the SDK client of the service API, retrying and sending Idempotency-Keys
+func (c *cli) client() *client.Client {
+	opts := []client.Option{client.WithActor(c.actor)}
+	if c.apiKey != "" {
+		opts = append(opts, client.WithAPIKey(c.apiKey))
+	}
+	return client.New(c.apiURL, opts...)
+}
+
+// adminClient is the client of the admin endpoints, which the SDK leaves out
+func (c *cli) adminClient() *walletapi.Client {
+	opts := []walletapi.Option{walletapi.WithHeader("X-Actor", c.actor)}
+	if c.apiKey != "" {
+		opts = append(opts, walletapi.WithHeader(apikey.Header, c.apiKey))
+	}
+	return walletapi.NewClient(c.apiURL, opts...)
+}
+
+func (c *cli) backend() (*maintenance, error) {
+	if c.maintenance == nil {
+		m, err := c.connect()
+		if err != nil {
+			return nil, err
+		}
+		c.maintenance = m
+	}
+	return c.maintenance, nil
+}
+
+func (c *cli) close() {
+	if c.maintenance != nil && c.maintenance.db != nil {
+		c.maintenance.db.Close()
+	}
+}
+
+// connectMaintenance loads the configuration of the service and connects to its database and Fireblocks workspace
+func (c *cli) connectMaintenance() (*maintenance, error) {
+	cfg, err := config.Load(c.configPath, os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	// the configuration was validated, the level parses; the logs go to stderr, out of the way of the output
+	logLevel, _ := logging.ParseLevel(cfg.Log.Level)
+	slog.SetDefault(logging.New(c.stderr, max(logLevel, slog.LevelWarn)))
+
+	privateKey, err := bootstrap.LoadPrivateKey(cfg.Fireblocks.SecretKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	db, sqlDB, err := bootstrap.OpenDatabase(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	fireblocksClient := bootstrap.NewFireblocksClient(cfg, privateKey)
+	walletRepo := repository.NewWalletRepository(db)
+	transferRepo := repository.NewTransferRepository(db)
+	return &maintenance{
+		wallets:    walletRepo,
+		transfers:  transferRepo,
+		apiKeys:    apikey.New(repository.NewAPIKeyRepository(db)),
+		fireblocks: fireblocksClient,
+		reconciler: reconcile.NewReconciler(walletRepo, transferRepo, repository.NewLedgerRepository(db), repository.NewReconciliationRepository(db), fireblocksClient, reconcile.Config{
+			TransferWindow: cfg.Reconciliation.TransferWindow,
+			IgnoredVaults:  cfg.Reconciliation.IgnoredVaults,
+		}),
+		db: sqlDB,
+	}, nil
+}
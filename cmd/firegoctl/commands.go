@@ -0,0 +1,544 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/apikey"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/model"
+	"firego-wallet-service/pkg/client"
+	"firego-wallet-service/pkg/walletapi"
+	"flag"
+	"fmt"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commands are the commands by name, the arguments after the name are passed to them
+var commands = map[string]func(ctx context.Context, c *cli, args []string) error{
+	"wallet create":   walletCreate,
+	"wallet list":     walletList,
+	"wallet show":     walletShow,
+	"balance":         balance,
+	"deposit-address": depositAddress,
+	"transfer":        transfer,
+	"tx status":       txStatus,
+	"tx cancel":       txCancel,
+	"apikey issue":    apiKeyIssue,
+	"apikey list":     apiKeyList,
+	"apikey revoke":   apiKeyRevoke,
+	"reconcile run":   reconcileRun,
+	"reconcile list":  reconcileList,
+}
+
+type walletOutput struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	VaultAccountID string    `json:"vaultAccountId"`
+	CreatedAt      time.Time `json:"createdAt,omitzero"`
+	Assets         []string  `json:"assets,omitempty"`
+}
+
+type assetBalanceOutput struct {
+	AssetID   string `json:"assetId"`
+	Total     string `json:"total"`
+	Available string `json:"available"`
+	Pending   string `json:"pending"`
+	Frozen    string `json:"frozen"`
+}
+
+type balanceOutput struct {
+	WalletID string               `json:"walletId"`
+	Assets   []assetBalanceOutput `json:"assets"`
+}
+
+type transactionOutput struct {
+	TransactionID      string `json:"transactionId"`
+	WalletID           string `json:"walletId,omitempty"`
+	AssetID            string `json:"assetId"`
+	Amount             string `json:"amount"`
+	DestinationAddress string `json:"destinationAddress"`
+	Note               string `json:"note,omitempty"`
+	// StoredStatus is the status the service last recorded, empty for transactions it did not submit
+	StoredStatus  string `json:"storedStatus,omitempty"`
+	Status        string `json:"status"`
+	SubStatus     string `json:"subStatus,omitempty"`
+	TxHash        string `json:"txHash,omitempty"`
+	Confirmations int    `json:"confirmations"`
+}
+
+type apiKeyOutput struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Admin     bool       `json:"admin"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	// Key is only set when the key is issued
+	Key string `json:"key,omitempty"`
+}
+
+func walletCreate(ctx context.Context, c *cli, args []string) error {
+	positional, err := c.parseArgs(flag.NewFlagSet("wallet create", flag.ContinueOnError), args, 1, "wallet create NAME")
+	if err != nil {
+		return err
+	}
+
+	wallet, err := c.client().CreateWallet(ctx, client.CreateWalletRequest{Name: positional[0]})
+	if err != nil {
+		return err
+	}
+	out := walletOutput{ID: wallet.ID, Name: wallet.Name, VaultAccountID: wallet.VaultAccountID}
+	return c.printWallets(out, []walletOutput{out}, false)
+}
+
+func walletList(ctx context.Context, c *cli, args []string) error {
+	if _, err := c.parseArgs(flag.NewFlagSet("wallet list", flag.ContinueOnError), args, 0, "wallet list"); err != nil {
+		return err
+	}
+	m, err := c.backend()
+	if err != nil {
+		return err
+	}
+
+	wallets, err := m.wallets.List(ctx)
+	if err != nil {
+		return err
+	}
+	out := make([]walletOutput, 0, len(wallets))
+	for _, wallet := range wallets {
+		out = append(out, toWalletOutput(wallet))
+	}
+	return c.printWallets(out, out, false)
+}
+
+func walletShow(ctx context.Context, c *cli, args []string) error {
+	positional, err := c.parseArgs(flag.NewFlagSet("wallet show", flag.ContinueOnError), args, 1, "wallet show WALLET_ID")
+	if err != nil {
+		return err
+	}
+	m, err := c.backend()
+	if err != nil {
+		return err
+	}
+
+	wallet, account, err := m.vaultAccount(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	out := toWalletOutput(*wallet)
+	out.Assets = []string{}
+	for _, asset := range account.Assets {
+		out.Assets = append(out.Assets, asset.ID)
+	}
+	return c.printWallets(out, []walletOutput{out}, true)
+}
+
+func balance(ctx context.Context, c *cli, args []string) error {
+	positional, err := c.parseArgs(flag.NewFlagSet("balance", flag.ContinueOnError), args, 1, "balance WALLET_ID")
+	if err != nil {
+		return err
+	}
+	m, err := c.backend()
+	if err != nil {
+		return err
+	}
+
+	wallet, account, err := m.vaultAccount(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	out := balanceOutput{WalletID: wallet.ID, Assets: []assetBalanceOutput{}}
+	var rows [][]string
+	for _, asset := range account.Assets {
+		out.Assets = append(out.Assets, assetBalanceOutput{
+			AssetID:   asset.ID,
+			Total:     asset.Total,
+			Available: asset.Available,
+			Pending:   asset.Pending,
+			Frozen:    asset.Frozen,
+		})
+		rows = append(rows, []string{asset.ID, asset.Total, asset.Available, asset.Pending, asset.Frozen})
+	}
+	return c.print(out, []string{"ASSET", "TOTAL", "AVAILABLE", "PENDING", "FROZEN"}, rows)
+}
+
+func depositAddress(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("deposit-address", flag.ContinueOnError)
+	create := flags.Bool("new", false, "create a new address rather than showing the current one")
+	description := flags.String("description", "", "description of the new address")
+	customerRefID := flags.String("customer-ref-id", "", "customer reference of the new address")
+	positional, err := c.parseArgs(flags, args, 2, "deposit-address [-new [-description TEXT] [-customer-ref-id ID]] WALLET_ID ASSET_ID")
+	if err != nil {
+		return err
+	}
+	walletID, assetID := positional[0], positional[1]
+
+	header := []string{"ASSET", "ADDRESS", "FORMAT", "TYPE"}
+	if *create {
+		address, err := c.client().CreateDepositAddress(ctx, walletID, assetID, client.CreateDepositAddressRequest{
+			Description:   *description,
+			CustomerRefID: *customerRefID,
+		})
+		if err != nil {
+			return err
+		}
+		return c.print(address, header, [][]string{{address.AssetID, address.Address, address.AddressFormat, address.Type}})
+	}
+
+	address, err := c.client().GetDepositAddress(ctx, walletID, assetID)
+	if err != nil {
+		return err
+	}
+	return c.print(address, header, [][]string{{address.AssetID, address.Address, address.AddressFormat, address.Type}})
+}
+
+func transfer(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("transfer", flag.ContinueOnError)
+	assetID := flags.String("asset", "", "asset to send, e.g. BTC_TEST")
+	amount := flags.String("amount", "", "amount to send, in units of the asset")
+	destination := flags.String("to", "", "destination address")
+	note := flags.String("note", "", "note recorded with the transaction")
+	positional, err := c.parseArgs(flags, args, 1, "transfer -asset ASSET_ID -amount AMOUNT -to ADDRESS [-note NOTE] WALLET_ID")
+	if err != nil {
+		return err
+	}
+	if *assetID == "" || *amount == "" || *destination == "" {
+		flags.Usage()
+		return errUsage
+	}
+	walletID := positional[0]
+
+	ok, err := c.confirm(fmt.Sprintf("Send %s %s from wallet %s to %s?", *amount, *assetID, walletID, *destination))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("transfer not confirmed")
+	}
+
+	resp, err := c.client().InitiateTransfer(ctx, walletID, client.InitiateTransferRequest{
+		AssetID:            *assetID,
+		Amount:             *amount,
+		DestinationAddress: *destination,
+		Note:               *note,
+	})
+	if err != nil {
+		return err
+	}
+	return c.printTransaction(transactionOutput{
+		TransactionID:      resp.TransactionID,
+		WalletID:           walletID,
+		AssetID:            resp.AssetID,
+		Amount:             resp.Amount,
+		DestinationAddress: resp.DestinationAddress,
+		Note:               resp.Note,
+		Status:             resp.Status,
+	})
+}
+
+func txStatus(ctx context.Context, c *cli, args []string) error {
+	positional, err := c.parseArgs(flag.NewFlagSet("tx status", flag.ContinueOnError), args, 1, "tx status TX_ID")
+	if err != nil {
+		return err
+	}
+	m, err := c.backend()
+	if err != nil {
+		return err
+	}
+
+	out, err := m.transaction(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	return c.printTransaction(*out)
+}
+
+func txCancel(ctx context.Context, c *cli, args []string) error {
+	positional, err := c.parseArgs(flag.NewFlagSet("tx cancel", flag.ContinueOnError), args, 1, "tx cancel TX_ID")
+	if err != nil {
+		return err
+	}
+	m, err := c.backend()
+	if err != nil {
+		return err
+	}
+
+	txID := positional[0]
+	tx, err := m.transaction(ctx, txID)
+	if err != nil {
+		return err
+	}
+	if fireblocks.IsTerminalTransactionStatus(tx.Status) {
+		return fmt.Errorf("transaction %s is already %s", txID, tx.Status)
+	}
+	ok, err := c.confirm(fmt.Sprintf("Cancel the transfer of %s %s to %s, which is %s?", tx.Amount, tx.AssetID, tx.DestinationAddress, tx.Status))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("cancellation not confirmed")
+	}
+
+	// the transfer poller and the webhooks record the CANCELLED status; nothing is to be released in the ledger, which
+	// only books transfers once COMPLETED
+	if _, _, err = m.fireblocks.CancelTransaction(ctx, txID); err != nil {
+		return fmt.Errorf("failed to cancel transaction %s: %w", txID, err)
+	}
+	if tx, err = m.transaction(ctx, txID); err != nil {
+		return err
+	}
+	return c.printTransaction(*tx)
+}
+
+func apiKeyIssue(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("apikey issue", flag.ContinueOnError)
+	admin := flags.Bool("admin", false, "allow the key on the admin endpoints")
+	positional, err := c.parseArgs(flags, args, 1, "apikey issue [-admin] NAME")
+	if err != nil {
+		return err
+	}
+	m, err := c.backend()
+	if err != nil {
+		return err
+	}
+
+	key, apiKey, err := m.apiKeys.Issue(ctx, positional[0], *admin)
+	if err != nil {
+		return err
+	}
+	out := toAPIKeyOutput(*apiKey)
+	out.Key = key
+	if c.output == outputTable {
+		fmt.Fprintln(c.stderr, "The key is shown once, store it now.")
+	}
+	return c.print(out, []string{"ID", "NAME", "KEY"}, [][]string{{out.ID, out.Name, key}})
+}
+
+func apiKeyList(ctx context.Context, c *cli, args []string) error {
+	if _, err := c.parseArgs(flag.NewFlagSet("apikey list", flag.ContinueOnError), args, 0, "apikey list"); err != nil {
+		return err
+	}
+	m, err := c.backend()
+	if err != nil {
+		return err
+	}
+
+	keys, err := m.apiKeys.List(ctx)
+	if err != nil {
+		return err
+	}
+	out := make([]apiKeyOutput, 0, len(keys))
+	var rows [][]string
+	for _, key := range keys {
+		out = append(out, toAPIKeyOutput(key))
+		revokedAt := ""
+		if key.RevokedAt != nil {
+			revokedAt = formatTime(*key.RevokedAt)
+		}
+		rows = append(rows, []string{key.ID, key.Name, key.Prefix + "...", strconv.FormatBool(key.Admin), formatTime(key.CreatedAt), revokedAt})
+	}
+	return c.print(out, []string{"ID", "NAME", "PREFIX", "ADMIN", "CREATED", "REVOKED"}, rows)
+}
+
+func apiKeyRevoke(ctx context.Context, c *cli, args []string) error {
+	positional, err := c.parseArgs(flag.NewFlagSet("apikey revoke", flag.ContinueOnError), args, 1, "apikey revoke KEY_ID")
+	if err != nil {
+		return err
+	}
+	m, err := c.backend()
+	if err != nil {
+		return err
+	}
+
+	if err = m.apiKeys.Revoke(ctx, positional[0]); errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("API key %s not found or already revoked", positional[0])
+	} else if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "API key %s revoked, instances that recently accepted it keep doing so for up to %s.\n", positional[0], apikey.CacheTTL)
+	return nil
+}
+
+func reconcileRun(ctx context.Context, c *cli, args []string) error {
+	if _, err := c.parseArgs(flag.NewFlagSet("reconcile run", flag.ContinueOnError), args, 0, "reconcile run"); err != nil {
+		return err
+	}
+	m, err := c.backend()
+	if err != nil {
+		return err
+	}
+
+	report, err := m.reconciler.Run(ctx)
+	if report == nil {
+		return err
+	}
+	// the report of a failed run is stored and printed too, it tells how far it got
+	if printErr := c.printReports([]walletapi.ReconciliationReportResponse{toReportOutput(*report)}, true); printErr != nil {
+		return printErr
+	}
+	return err
+}
+
+func reconcileList(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("reconcile list", flag.ContinueOnError)
+	limit := flags.Int("limit", 10, "number of reports, latest first")
+	if _, err := c.parseArgs(flags, args, 0, "reconcile list [-limit N]"); err != nil {
+		return err
+	}
+
+	resp, err := c.adminClient().ListReconciliationReports(ctx, &walletapi.ListReconciliationReportsParams{Limit: *limit})
+	if err != nil {
+		return err
+	}
+	return c.printReports(resp.Reports, false)
+}
+
+// vaultAccount returns the wallet and its vault account, with the balances of its assets
+func (m *maintenance) vaultAccount(ctx context.Context, walletID string) (*model.Wallet, *fireblocks.VaultAccount, error) {
+	wallet, err := m.wallets.GetByID(ctx, walletID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("wallet %s not found", walletID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	account, _, err := m.fireblocks.GetVaultAccount(ctx, wallet.VaultAccountID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get vault account %s: %w", wallet.VaultAccountID, err)
+	}
+	return wallet, account, nil
+}
+
+// transaction returns the transaction as Fireblocks has it, along with the transfer stored for it if any
+func (m *maintenance) transaction(ctx context.Context, txID string) (*transactionOutput, error) {
+	tx, _, err := m.fireblocks.GetTransaction(ctx, txID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction %s: %w", txID, err)
+	}
+	out := &transactionOutput{
+		TransactionID:      tx.ID,
+		AssetID:            tx.AssetID,
+		Amount:             tx.AmountInfo.Amount,
+		DestinationAddress: tx.DestinationAddress,
+		Note:               tx.Note,
+		Status:             tx.Status,
+		SubStatus:          tx.SubStatus,
+		TxHash:             tx.TxHash,
+		Confirmations:      tx.NumOfConfirmations,
+	}
+
+	stored, err := m.transfers.GetByFireblocksTxID(txID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if stored != nil {
+		out.WalletID = stored.WalletID
+		out.StoredStatus = stored.Status
+	}
+	return out, nil
+}
+
+// printWallets prints v as JSON, or the wallets as a table
+func (c *cli) printWallets(v any, wallets []walletOutput, withAssets bool) error {
+	header := []string{"ID", "NAME", "VAULT", "CREATED"}
+	if withAssets {
+		header = append(header, "ASSETS")
+	}
+	var rows [][]string
+	for _, wallet := range wallets {
+		row := []string{wallet.ID, wallet.Name, wallet.VaultAccountID, formatTime(wallet.CreatedAt)}
+		if withAssets {
+			row = append(row, strings.Join(wallet.Assets, ","))
+		}
+		rows = append(rows, row)
+	}
+	return c.print(v, header, rows)
+}
+
+func (c *cli) printTransaction(tx transactionOutput) error {
+	status := tx.Status
+	if tx.SubStatus != "" {
+		status += " (" + tx.SubStatus + ")"
+	}
+	return c.print(tx, []string{"TRANSACTION", "WALLET", "ASSET", "AMOUNT", "DESTINATION", "STATUS", "STORED STATUS", "CONFIRMATIONS"}, [][]string{{
+		tx.TransactionID, tx.WalletID, tx.AssetID, tx.Amount, tx.DestinationAddress, status, tx.StoredStatus, strconv.Itoa(tx.Confirmations),
+	}})
+}
+
+// printReports lists the reports, and their issues when withIssues is set
+func (c *cli) printReports(reports []walletapi.ReconciliationReportResponse, withIssues bool) error {
+	if c.output == outputJSON {
+		if withIssues && len(reports) == 1 {
+			return c.print(reports[0], nil, nil)
+		}
+		return c.print(reports, nil, nil)
+	}
+
+	var rows [][]string
+	for _, report := range reports {
+		rows = append(rows, []string{report.ID, report.Status, strconv.Itoa(report.IssueCount), formatTime(report.StartedAt), formatTime(report.FinishedAt), report.Error})
+	}
+	if err := c.print(nil, []string{"REPORT", "STATUS", "ISSUES", "STARTED", "FINISHED", "ERROR"}, rows); err != nil {
+		return err
+	}
+	if !withIssues || len(reports) != 1 || len(reports[0].Issues) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(c.stdout)
+	rows = nil
+	for _, issue := range reports[0].Issues {
+		rows = append(rows, []string{issue.Kind, issue.WalletID, issue.AssetID, issue.Reference, issue.Expected, issue.Actual, issue.Details})
+	}
+	return c.print(nil, []string{"ISSUE", "WALLET", "ASSET", "REFERENCE", "EXPECTED", "ACTUAL", "DETAILS"}, rows)
+}
+
+func toWalletOutput(wallet model.Wallet) walletOutput {
+	return walletOutput{
+		ID:             wallet.ID,
+		Name:           wallet.Name,
+		VaultAccountID: wallet.VaultAccountID,
+		CreatedAt:      wallet.CreatedAt,
+	}
+}
+
+func toAPIKeyOutput(key model.APIKey) apiKeyOutput {
+	return apiKeyOutput{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Admin:     key.Admin,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
+
+// toReportOutput converts the report to the response of GET /admin/reconciliation, so that run and list print the
+// same JSON
+func toReportOutput(report model.ReconciliationReport) walletapi.ReconciliationReportResponse {
+	out := walletapi.ReconciliationReportResponse{
+		ID:         report.ID,
+		Status:     string(report.Status),
+		Error:      report.Error,
+		IssueCount: report.IssueCount,
+		StartedAt:  report.StartedAt,
+		FinishedAt: report.FinishedAt,
+		Issues:     []walletapi.ReconciliationIssueResponse{},
+	}
+	for _, issue := range report.Issues {
+		out.Issues = append(out.Issues, walletapi.ReconciliationIssueResponse{
+			Kind:           string(issue.Kind),
+			WalletID:       issue.WalletID,
+			VaultAccountID: issue.VaultAccountID,
+			AssetID:        issue.AssetID,
+			Reference:      issue.Reference,
+			Expected:       issue.Expected,
+			Actual:         issue.Actual,
+			Details:        issue.Details,
+		})
+	}
+	return out
+}
@@ -0,0 +1,160 @@
+// Command firegoctl is the operator CLI of the wallet service. The wallet, deposit address and transfer commands go
+// through the service API, like any client, so that they are validated, rate limited and audited. The maintenance
+// commands, which have no endpoint, connect to the database and Fireblocks with the configuration of the service.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+const usage = `Usage: firegoctl [flags] COMMAND
+
+Commands going through the service API:
+  wallet create NAME                          create a wallet and its vault account
+  deposit-address [-new] WALLET_ID ASSET_ID   show, or create, the deposit address of a wallet asset
+  transfer -asset ASSET_ID -amount AMOUNT -to ADDRESS [-note NOTE] WALLET_ID
+                                              send funds out of a wallet, after confirmation
+  reconcile list [-limit N]                   list the latest reconciliation reports
+
+Maintenance commands, connecting to the database and Fireblocks:
+  wallet list                                 list the wallets
+  wallet show WALLET_ID                       show a wallet and its vault account assets
+  balance WALLET_ID                           show the Fireblocks balances of every asset of a wallet
+  tx status TX_ID                             show a transfer, as stored and as Fireblocks has it
+  tx cancel TX_ID                             cancel a transfer not broadcast yet, after confirmation
+  apikey issue [-admin] NAME                  issue an API key, shown once
+  apikey list                                 list the API keys
+  apikey revoke KEY_ID                        revoke an API key
+  reconcile run                               run a reconciliation now and store its report
+
+Flags:
+`
+
+// errUsage is returned for invalid command lines, after the usage was printed
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	c := &cli{
+		stdin:  bufio.NewReader(os.Stdin),
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+	c.connect = c.connectMaintenance
+	err := c.run(ctx, os.Args[1:])
+	stop()
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "firegoctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the global flags and runs the command
+func (c *cli) run(ctx context.Context, args []string) error {
+	stderr := c.stderr
+	flags := flag.NewFlagSet("firegoctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&c.apiURL, "url", envOr("FIREGO_URL", "http://localhost:8080"), "base URL of the service API")
+	flags.StringVar(&c.apiKey, "api-key", os.Getenv("FIREGO_API_KEY"), "API key sent as X-API-Key")
+	flags.StringVar(&c.actor, "actor", envOr("FIREGO_ACTOR", os.Getenv("USER")), "who the API calls are recorded against in the audit log")
+	flags.StringVar(&c.configPath, "config", os.Getenv("CONFIG_FILE"), "YAML configuration of the service, overridden by the environment, for the maintenance commands")
+	flags.StringVar(&c.output, "o", "table", "output format, table or json")
+	flags.BoolVar(&c.yes, "yes", false, "do not ask for confirmation")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if c.output != outputTable && c.output != outputJSON {
+		fmt.Fprintln(stderr, "-o must be table or json")
+		return errUsage
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		return errUsage
+	}
+	command, ok := commands[commandName(args)]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", strings.Join(args[:min(2, len(args))], " "))
+		flags.Usage()
+		return errUsage
+	}
+	defer c.close()
+	return command(ctx, c, args[len(strings.Fields(commandName(args))):])
+}
+
+// commandName is the name the command is registered with, one or two words
+func commandName(args []string) string {
+	if len(args) > 1 {
+		if _, ok := commands[args[0]+" "+args[1]]; ok {
+			return args[0] + " " + args[1]
+		}
+	}
+	return args[0]
+}
+
+// confirm asks the question on stderr and tells whether it was answered yes. -yes answers it.
+func (c *cli) confirm(question string) (bool, error) {
+	if c.yes {
+		return true, nil
+	}
+	fmt.Fprintf(c.stderr, "%s [y/N] ", question)
+	answer, err := c.stdin.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// parseArgs parses the flags of a command, which may come before, between or after its arguments
+func (c *cli) parseArgs(flags *flag.FlagSet, args []string, want int, usage string) ([]string, error) {
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: firegoctl %s\n", usage)
+		flags.PrintDefaults()
+	}
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) != want {
+		flags.Usage()
+		return nil, errUsage
+	}
+	return positional, nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
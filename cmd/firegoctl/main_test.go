@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"firego-wallet-service/internal/apikey"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/fireblocks/fireblockstest"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testAPIKey = "sim-api-key"
+
+type MockWalletRepository struct {
+	wallets []model.Wallet
+}
+
+func (m *MockWalletRepository) GetByID(_ context.Context, id string) (*model.Wallet, error) {
+	for _, wallet := range m.wallets {
+		if wallet.ID == id {
+			return &wallet, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockWalletRepository) List(_ context.Context) ([]model.Wallet, error) {
+	return m.wallets, nil
+}
+
+type MockTransferRepository struct {
+	transfers []model.Transfer
+}
+
+func (m *MockTransferRepository) GetByFireblocksTxID(txID string) (*model.Transfer, error) {
+	for _, transfer := range m.transfers {
+		if transfer.FireblocksTxID == txID {
+			return &transfer, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type MockAPIKeyRepository struct {
+	keys []*model.APIKey
+}
+
+func (m *MockAPIKeyRepository) Create(_ context.Context, key *model.APIKey) error {
+	key.ID = "key-1"
+	key.CreatedAt = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.keys = append(m.keys, key)
+	return nil
+}
+
+func (m *MockAPIKeyRepository) GetByHash(_ context.Context, _ string) (*model.APIKey, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockAPIKeyRepository) List(_ context.Context) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	for _, key := range m.keys {
+		keys = append(keys, *key)
+	}
+	return keys, nil
+}
+
+func (m *MockAPIKeyRepository) Revoke(_ context.Context, id string, revokedAt time.Time) error {
+	for _, key := range m.keys {
+		if key.ID == id && key.RevokedAt == nil {
+			key.RevokedAt = &revokedAt
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+type MockReconciler struct{}
+
+func (MockReconciler) Run(_ context.Context) (*model.ReconciliationReport, error) {
+	return &model.ReconciliationReport{
+		ID:         "report-1",
+		Status:     model.ReconciliationStatusCompleted,
+		IssueCount: 1,
+		StartedAt:  time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		FinishedAt: time.Date(2024, 1, 1, 12, 0, 5, 0, time.UTC),
+		Issues: []model.ReconciliationIssue{
+			{Kind: model.ReconciliationIssueOrphanedVault, VaultAccountID: "7"},
+		},
+	}, nil
+}
+
+// runCLI runs a command line against the API at url, and the maintenance backends m
+func runCLI(t *testing.T, url string, m *maintenance, stdin string, args ...string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	c := &cli{
+		stdin:   bufio.NewReader(strings.NewReader(stdin)),
+		stdout:  &stdout,
+		stderr:  &stderr,
+		connect: func() (*maintenance, error) { return m, nil },
+	}
+	err := c.run(context.Background(), append([]string{"-url", url, "-actor", "operator"}, args...))
+	return stdout.String(), stderr.String(), err
+}
+
+// newTestAPI serves the endpoints the API commands use, recording the requests
+func newTestAPI(t *testing.T) (string, *atomic.Int32) {
+	var transfers atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /wallets", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "operator", r.Header.Get("X-Actor"))
+		assert.NotEmpty(t, r.Header.Get("Idempotency-Key"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "wallet-1", "name": "treasury", "vaultAccountID": "0"}`))
+	})
+	mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/address", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("walletId") != "wallet-1" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code": "wallet_not_found", "message": "Wallet not found"}`))
+			return
+		}
+		w.Write([]byte(`{"assetId": "BTC_TEST", "address": "tb1qaddress", "addressFormat": "SEGWIT", "type": "Permanent"}`))
+	})
+	mux.HandleFunc("POST /wallets/{walletId}/transactions", func(w http.ResponseWriter, r *http.Request) {
+		transfers.Add(1)
+		var req map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, map[string]string{"assetId": "BTC_TEST", "amount": "0.5", "destinationAddress": "tb1qdestination", "note": "payout"}, req)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"transactionId": "tx-1", "status": "SUBMITTED", "assetId": "BTC_TEST", "amount": "0.5", "destinationAddress": "tb1qdestination", "note": "payout"}`))
+	})
+	mux.HandleFunc("GET /admin/reconciliation", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "2", r.URL.Query().Get("limit"))
+		w.Write([]byte(`{"reports": [{"id": "report-1", "status": "COMPLETED", "issueCount": 0, "startedAt": "2024-01-01T12:00:00Z", "finishedAt": "2024-01-01T12:00:05Z", "issues": []}]}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL, &transfers
+}
+
+func TestAPICommands(t *testing.T) {
+	url, transfers := newTestAPI(t)
+
+	tests := []struct {
+		name    string
+		args    []string
+		stdin   string
+		wantOut string
+		wantErr string
+	}{
+		{
+			name:    "wallet_create",
+			args:    []string{"wallet", "create", "treasury"},
+			wantOut: "ID        NAME      VAULT  CREATED\nwallet-1  treasury  0      \n",
+		},
+		{
+			name:    "wallet_create_json",
+			args:    []string{"-o", "json", "wallet", "create", "treasury"},
+			wantOut: "{\n  \"id\": \"wallet-1\",\n  \"name\": \"treasury\",\n  \"vaultAccountId\": \"0\"\n}\n",
+		},
+		{
+			name:    "deposit_address",
+			args:    []string{"deposit-address", "wallet-1", "BTC_TEST"},
+			wantOut: "ASSET     ADDRESS      FORMAT  TYPE\nBTC_TEST  tb1qaddress  SEGWIT  Permanent\n",
+		},
+		{
+			name:    "deposit_address_unknown_wallet",
+			args:    []string{"deposit-address", "wallet-2", "BTC_TEST"},
+			wantErr: "wallet service answered 404 wallet_not_found: Wallet not found",
+		},
+		{
+			name:    "transfer_declined",
+			args:    []string{"transfer", "-asset", "BTC_TEST", "-amount", "0.5", "-to", "tb1qdestination", "-note", "payout", "wallet-1"},
+			stdin:   "n\n",
+			wantErr: "transfer not confirmed",
+		},
+		{
+			name:    "transfer_confirmed",
+			args:    []string{"-o", "json", "transfer", "wallet-1", "-asset", "BTC_TEST", "-amount", "0.5", "-to", "tb1qdestination", "-note", "payout"},
+			stdin:   "y\n",
+			wantOut: `"transactionId": "tx-1"`,
+		},
+		{
+			name:    "reconcile_list",
+			args:    []string{"reconcile", "list", "-limit", "2"},
+			wantOut: "REPORT    STATUS     ISSUES  STARTED               FINISHED              ERROR\nreport-1  COMPLETED  0       2024-01-01T12:00:00Z  2024-01-01T12:00:05Z  \n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, _, err := runCLI(t, url, nil, tt.stdin, tt.args...)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Contains(t, stdout, tt.wantOut)
+		})
+	}
+
+	// only the confirmed transfer was sent
+	assert.Equal(t, int32(1), transfers.Load())
+}
+
+func TestMaintenanceCommands(t *testing.T) {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	sim := fireblockstest.NewServer(fireblockstest.Config{APIKey: testAPIKey, PublicKey: &key.PublicKey})
+	server := httptest.NewServer(sim)
+	t.Cleanup(server.Close)
+	fireblocksClient := fireblocks.NewClient(server.URL, testAPIKey, key)
+
+	vault, _, err := fireblocksClient.CreateVaultAccount(ctx, fireblocks.CreateVaultAccountRequest{Name: "treasury"})
+	assert.NoError(t, err)
+	_, err = sim.Deposit(ctx, vault.ID, "ETH_TEST5", "2")
+	assert.NoError(t, err)
+	sim.Advance(ctx)
+	tx, _, err := fireblocksClient.CreateTransaction(ctx, fireblocks.NewVaultTransferRequest("ETH_TEST5", vault.ID, "0xdestination", "0.5", ""))
+	assert.NoError(t, err)
+
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := &maintenance{
+		wallets: &MockWalletRepository{wallets: []model.Wallet{
+			{ID: "wallet-1", Name: "treasury", VaultAccountID: vault.ID, CreatedAt: createdAt},
+		}},
+		transfers: &MockTransferRepository{transfers: []model.Transfer{
+			{WalletID: "wallet-1", FireblocksTxID: tx.ID, Status: fireblocks.TransactionStatusSubmitted},
+		}},
+		apiKeys:    apikey.New(&MockAPIKeyRepository{}),
+		fireblocks: fireblocksClient,
+		reconciler: MockReconciler{},
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		stdin   string
+		wantOut string
+		wantErr string
+	}{
+		{
+			name:    "wallet_list",
+			args:    []string{"-o", "json", "wallet", "list"},
+			wantOut: "[\n  {\n    \"id\": \"wallet-1\",\n    \"name\": \"treasury\",\n    \"vaultAccountId\": \"0\",\n    \"createdAt\": \"2024-01-01T12:00:00Z\"\n  }\n]\n",
+		},
+		{
+			name:    "wallet_show",
+			args:    []string{"wallet", "show", "wallet-1"},
+			wantOut: "ID        NAME      VAULT  CREATED               ASSETS\nwallet-1  treasury  0      2024-01-01T12:00:00Z  ETH_TEST5\n",
+		},
+		{
+			name:    "wallet_show_unknown",
+			args:    []string{"wallet", "show", "wallet-2"},
+			wantErr: "wallet wallet-2 not found",
+		},
+		{
+			name:    "balance",
+			args:    []string{"balance", "wallet-1"},
+			wantOut: "ASSET      TOTAL  AVAILABLE  PENDING  FROZEN\nETH_TEST5  2      1.5        0        0\n",
+		},
+		{
+			name:    "tx_status",
+			args:    []string{"-o", "json", "tx", "status", tx.ID},
+			wantOut: `"storedStatus": "SUBMITTED",` + "\n  \"status\": \"SUBMITTED\"",
+		},
+		{
+			name:    "tx_cancel_declined",
+			args:    []string{"tx", "cancel", tx.ID},
+			stdin:   "\n",
+			wantErr: "cancellation not confirmed",
+		},
+		{
+			name:    "tx_cancel",
+			args:    []string{"tx", "cancel", tx.ID},
+			stdin:   "yes\n",
+			wantOut: "CANCELLED (CANCELLED_BY_USER)  SUBMITTED",
+		},
+		{
+			name:    "tx_cancel_ended",
+			args:    []string{"-yes", "tx", "cancel", tx.ID},
+			wantErr: "is already CANCELLED",
+		},
+		{
+			name:    "reconcile_run",
+			args:    []string{"reconcile", "run"},
+			wantOut: "ISSUE           WALLET  ASSET  REFERENCE  EXPECTED  ACTUAL  DETAILS\nORPHANED_VAULT",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, _, err := runCLI(t, "", m, tt.stdin, tt.args...)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Contains(t, stdout, tt.wantOut)
+		})
+	}
+
+	t.Run("api_keys", func(t *testing.T) {
+		stdout, stderr, err := runCLI(t, "", m, "", "-o", "json", "apikey", "issue", "payments-team")
+		assert.NoError(t, err)
+		assert.Empty(t, stderr)
+		var issued apiKeyOutput
+		assert.NoError(t, json.Unmarshal([]byte(stdout), &issued))
+		assert.True(t, strings.HasPrefix(issued.Key, issued.Prefix))
+		assert.False(t, issued.Admin)
+
+		_, _, err = runCLI(t, "", m, "", "apikey", "revoke", issued.ID)
+		assert.NoError(t, err)
+		_, _, err = runCLI(t, "", m, "", "apikey", "revoke", issued.ID)
+		assert.ErrorContains(t, err, "not found or already revoked")
+
+		stdout, _, err = runCLI(t, "", m, "", "apikey", "list")
+		assert.NoError(t, err)
+		assert.Contains(t, stdout, "key-1  payments-team  "+issued.Prefix+"...  false  2024-01-01T12:00:00Z")
+		assert.NotContains(t, stdout, issued.Key)
+
+		stdout, _, err = runCLI(t, "", m, "", "-o", "json", "apikey", "issue", "-admin", "operations")
+		assert.NoError(t, err)
+		var admin apiKeyOutput
+		assert.NoError(t, json.Unmarshal([]byte(stdout), &admin))
+		assert.True(t, admin.Admin)
+	})
+}
+
+func TestUsage(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantStderr string
+	}{
+		{name: "no_command", args: nil, wantStderr: "Usage: firegoctl [flags] COMMAND"},
+		{name: "unknown_command", args: []string{"wallet", "delete"}, wantStderr: `unknown command "wallet delete"`},
+		{name: "invalid_output", args: []string{"-o", "yaml", "wallet", "list"}, wantStderr: "-o must be table or json"},
+		{name: "missing_argument", args: []string{"wallet", "show"}, wantStderr: "Usage: firegoctl wallet show WALLET_ID"},
+		{name: "extra_argument", args: []string{"balance", "wallet-1", "BTC_TEST"}, wantStderr: "Usage: firegoctl balance WALLET_ID"},
+		{name: "missing_flag", args: []string{"transfer", "-asset", "BTC_TEST", "wallet-1"}, wantStderr: "Usage: firegoctl transfer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, stderr, err := runCLI(t, "", nil, "", tt.args...)
+			assert.ErrorIs(t, err, errUsage)
+			assert.Contains(t, stderr, tt.wantStderr)
+		})
+	}
+}
@@ -0,0 +1,414 @@
+//go:build integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"firego-wallet-service/internal/apikey"
+	"firego-wallet-service/internal/bootstrap"
+	"firego-wallet-service/internal/config"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/fireblocks/fireblockstest"
+	"firego-wallet-service/internal/handler"
+	"firego-wallet-service/internal/migrate"
+	"firego-wallet-service/internal/model"
+	"firego-wallet-service/internal/repository"
+	"firego-wallet-service/internal/sweep"
+	"firego-wallet-service/internal/testdb"
+	"firego-wallet-service/pkg/client"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+const integrationAPIKey = "integration-api-key"
+
+var postgres *testdb.Server
+
+func TestMain(m *testing.M) {
+	var err error
+	postgres, err = testdb.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start Postgres: %v\n", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	postgres.Stop()
+	os.Exit(code)
+}
+
+// testService is the service wired by newApp against a fresh database and the Fireblocks simulator, which sends its
+// webhooks to the service
+type testService struct {
+	url        string
+	db         *gorm.DB
+	sim        *fireblockstest.Server
+	fireblocks *fireblocks.Client
+	// apiKey is an admin key, sent with every request
+	apiKey string
+}
+
+// newTestService boots the service against a database of its own and the simulator, with the configuration changed by
+// configure, if any
+func newTestService(t *testing.T, configure ...func(cfg *config.Config)) *testService {
+	apiKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	webhookKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	// the listener exists before the server starts, so the simulator can be given the webhook URL
+	server := httptest.NewUnstartedServer(nil)
+	sim := fireblockstest.NewServer(fireblockstest.Config{
+		APIKey:        integrationAPIKey,
+		PublicKey:     &apiKey.PublicKey,
+		WebhookURL:    "http://" + server.Listener.Addr().String() + "/webhooks/fireblocks",
+		WebhookKey:    webhookKey,
+		Confirmations: 2,
+	})
+	simServer := httptest.NewServer(sim)
+	t.Cleanup(simServer.Close)
+
+	cfg := config.Default()
+	cfg.Database.URL = config.Secret(postgres.NewDatabase(t))
+	cfg.Fireblocks.BaseURL = simServer.URL
+	cfg.Fireblocks.APIKey = integrationAPIKey
+	cfg.Deposits.DefaultConfirmations = 2
+	// webhooks drive the flows, the pollers and the reconciliation would race with the assertions
+	cfg.Features.Webhooks = true
+	cfg.Features.DepositPolling = false
+	cfg.Features.TransferPolling = false
+	cfg.Features.Reconciliation = false
+	// the flows go through the API keys, an admin key on the admin endpoints
+	cfg.Features.APIKeys = true
+	cfg.Schedules.Interval = 50 * time.Millisecond
+	for _, configure := range configure {
+		configure(cfg)
+	}
+
+	db, sqlDB, err := bootstrap.OpenDatabase(cfg)
+	assert.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	migrator, err := migrate.New(sqlDB)
+	assert.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	assert.NoError(t, err)
+	key, _, err := apikey.New(repository.NewAPIKeyRepository(db)).Issue(context.Background(), "integration-test", true)
+	assert.NoError(t, err)
+
+	fireblocksClient := bootstrap.NewFireblocksClient(cfg, apiKey)
+	app := newApp(cfg, db, sqlDB, fireblocksClient, &webhookKey.PublicKey, time.Now())
+	server.Config.Handler = app.handler
+	server.Start()
+	t.Cleanup(server.Close)
+
+	// the workers left enabled, e.g. the payouts, run until the test ends
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	for _, run := range app.workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workersCtx)
+		}()
+	}
+	t.Cleanup(func() {
+		stopWorkers()
+		workers.Wait()
+	})
+
+	return &testService{url: server.URL, db: db, sim: sim, fireblocks: fireblocksClient, apiKey: key}
+}
+
+// do sends a JSON request to the service and decodes the response into out, if not nil
+func (s *testService) do(t *testing.T, method, path string, body any, out any) int {
+	t.Helper()
+	var reqBody bytes.Buffer
+	if body != nil {
+		assert.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+	}
+	req, err := http.NewRequest(method, s.url+path, &reqBody)
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(apikey.Header, s.apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+// createFundedWallet creates a wallet, activates the asset in its vault account and credits a deposit of amount
+func (s *testService) createFundedWallet(t *testing.T, assetID, amount string) handler.CreateWalletResponse {
+	t.Helper()
+	ctx := context.Background()
+
+	var wallet handler.CreateWalletResponse
+	assert.Equal(t, http.StatusCreated, s.do(t, http.MethodPost, "/wallets", handler.CreateWalletRequest{Name: "integration"}, &wallet))
+	_, _, err := s.fireblocks.CreateVaultAccountAsset(ctx, wallet.VaultAccountID, assetID)
+	assert.NoError(t, err)
+
+	_, err = s.sim.Deposit(ctx, wallet.VaultAccountID, assetID, amount)
+	assert.NoError(t, err)
+	s.sim.Advance(ctx)
+	s.sim.Advance(ctx)
+	return wallet
+}
+
+// transfer returns the stored transfer of the transaction
+func (s *testService) transfer(t *testing.T, txID string) *model.Transfer {
+	t.Helper()
+	transfer, err := repository.NewTransferRepository(s.db).GetByFireblocksTxID(txID)
+	assert.NoError(t, err)
+	return transfer
+}
+
+func TestDepositAndTransfer(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+
+	var wallet handler.CreateWalletResponse
+	assert.Equal(t, http.StatusCreated, service.do(t, http.MethodPost, "/wallets", handler.CreateWalletRequest{Name: "integration"}, &wallet))
+	assert.NotEmpty(t, wallet.VaultAccountID)
+
+	// the asset wallet has to be activated in Fireblocks before it can be used
+	balancePath := "/wallets/" + wallet.ID + "/assets/BTC_TEST/balance"
+	assert.Equal(t, http.StatusBadRequest, service.do(t, http.MethodGet, balancePath, nil, nil))
+	_, _, err := service.fireblocks.CreateVaultAccountAsset(ctx, wallet.VaultAccountID, "BTC_TEST")
+	assert.NoError(t, err)
+
+	var address handler.GetDepositAddressResponse
+	assert.Equal(t, http.StatusOK, service.do(t, http.MethodGet, "/wallets/"+wallet.ID+"/assets/BTC_TEST/address", nil, &address))
+	assert.NotEmpty(t, address.Address)
+
+	// the deposit is credited once it has the required confirmations
+	_, err = service.sim.Deposit(ctx, wallet.VaultAccountID, "BTC_TEST", "2")
+	assert.NoError(t, err)
+	service.sim.Advance(ctx)
+	var deposits handler.ListDepositsResponse
+	assert.Equal(t, http.StatusOK, service.do(t, http.MethodGet, "/wallets/"+wallet.ID+"/deposits", nil, &deposits))
+	assert.Len(t, deposits.Deposits, 1)
+	assert.Equal(t, string(model.DepositStatusPending), deposits.Deposits[0].Status)
+
+	service.sim.Advance(ctx)
+	assert.Equal(t, http.StatusOK, service.do(t, http.MethodGet, "/wallets/"+wallet.ID+"/deposits", nil, &deposits))
+	assert.Equal(t, string(model.DepositStatusCredited), deposits.Deposits[0].Status)
+	assert.Equal(t, address.Address, deposits.Deposits[0].DestinationAddress)
+
+	var balance handler.GetWalletBalanceResponse
+	assert.Equal(t, http.StatusOK, service.do(t, http.MethodGet, balancePath, nil, &balance))
+	assert.Equal(t, "2", balance.Available)
+	var ledgerBalance handler.LedgerBalanceResponse
+	assert.Equal(t, http.StatusOK, service.do(t, http.MethodGet, "/wallets/"+wallet.ID+"/assets/BTC_TEST/ledger/balance", nil, &ledgerBalance))
+	assert.Equal(t, "2", ledgerBalance.Total)
+
+	// the transfer walks through its statuses as the webhooks come in, and is booked once completed
+	var transfer handler.InitiateTransferResponse
+	assert.Equal(t, http.StatusCreated, service.do(t, http.MethodPost, "/wallets/"+wallet.ID+"/transactions", handler.InitiateTransferRequest{
+		AssetID:            "BTC_TEST",
+		Amount:             "0.5",
+		DestinationAddress: "tb1qintegration",
+	}, &transfer))
+	assert.Equal(t, fireblocks.TransactionStatusSubmitted, transfer.Status)
+
+	for _, want := range []string{
+		fireblocks.TransactionStatusPendingSignature,
+		fireblocks.TransactionStatusBroadcasting,
+		fireblocks.TransactionStatusConfirming,
+		fireblocks.TransactionStatusCompleted,
+	} {
+		service.sim.Advance(ctx)
+		assert.Equal(t, want, service.transfer(t, transfer.TransactionID).Status)
+	}
+
+	assert.Equal(t, http.StatusOK, service.do(t, http.MethodGet, balancePath, nil, &balance))
+	assert.Equal(t, "1.5", balance.Available)
+	assert.Equal(t, http.StatusOK, service.do(t, http.MethodGet, "/wallets/"+wallet.ID+"/assets/BTC_TEST/ledger/balance", nil, &ledgerBalance))
+	assert.Equal(t, "1.5", ledgerBalance.Total)
+
+	assert.Equal(t, http.StatusOK, service.do(t, http.MethodGet, "/admin/audit/verify", nil, nil))
+}
+
+func TestTransferFailures(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+	wallet := service.createFundedWallet(t, "ETH_TEST5", "1")
+	transfersPath := "/wallets/" + wallet.ID + "/transactions"
+
+	t.Run("insufficient_balance", func(t *testing.T) {
+		status := service.do(t, http.MethodPost, transfersPath, handler.InitiateTransferRequest{
+			AssetID:            "ETH_TEST5",
+			Amount:             "5",
+			DestinationAddress: "0xintegration",
+		}, nil)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("blocked_by_policy", func(t *testing.T) {
+		var transfer handler.InitiateTransferResponse
+		assert.Equal(t, http.StatusCreated, service.do(t, http.MethodPost, transfersPath, handler.InitiateTransferRequest{
+			AssetID:            "ETH_TEST5",
+			Amount:             "0.4",
+			DestinationAddress: "0xintegration",
+		}, &transfer))
+
+		assert.NoError(t, service.sim.Fail(ctx, transfer.TransactionID, fireblocks.TransactionStatusBlocked, "BLOCKED_BY_POLICY"))
+		stored := service.transfer(t, transfer.TransactionID)
+		assert.Equal(t, fireblocks.TransactionStatusBlocked, stored.Status)
+		assert.Equal(t, "BLOCKED_BY_POLICY", stored.SubStatus)
+
+		// nothing was booked, and the held funds are available again
+		var ledgerBalance handler.LedgerBalanceResponse
+		assert.Equal(t, http.StatusOK, service.do(t, http.MethodGet, "/wallets/"+wallet.ID+"/assets/ETH_TEST5/ledger/balance", nil, &ledgerBalance))
+		assert.Equal(t, "1", ledgerBalance.Total)
+		var balance handler.GetWalletBalanceResponse
+		assert.Equal(t, http.StatusOK, service.do(t, http.MethodGet, "/wallets/"+wallet.ID+"/assets/ETH_TEST5/balance", nil, &balance))
+		assert.Equal(t, "1", balance.Available)
+	})
+}
+
+func TestUnknownWallet(t *testing.T) {
+	service := newTestService(t)
+
+	status := service.do(t, http.MethodGet, "/wallets/00000000-0000-0000-0000-000000000000/assets/BTC_TEST/balance", nil, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, http.StatusOK, service.do(t, http.MethodGet, "/readyz", nil, nil))
+}
+
+func TestIdempotentTransfer(t *testing.T) {
+	service := newTestService(t)
+	wallet := service.createFundedWallet(t, "BTC_TEST", "1")
+	c := client.New(service.url, client.WithAPIKey(service.apiKey))
+	ctx := client.WithIdempotencyKey(context.Background(), "integration-payout-1")
+	req := client.InitiateTransferRequest{AssetID: "BTC_TEST", Amount: "0.25", DestinationAddress: "tb1qintegration"}
+
+	first, err := c.InitiateTransfer(ctx, wallet.ID, req)
+	assert.NoError(t, err)
+	again, err := c.InitiateTransfer(ctx, wallet.ID, req)
+	assert.NoError(t, err)
+	assert.Equal(t, first.TransactionID, again.TransactionID)
+
+	var transfers int64
+	assert.NoError(t, service.db.Model(&model.Transfer{}).Where("wallet_id = ?", wallet.ID).Count(&transfers).Error)
+	assert.Equal(t, int64(1), transfers)
+
+	req.Amount = "0.5"
+	_, err = c.InitiateTransfer(ctx, wallet.ID, req)
+	assert.ErrorIs(t, err, client.ErrIdempotencyKeyReused)
+}
+
+func TestPayout(t *testing.T) {
+	service := newTestService(t)
+	wallet := service.createFundedWallet(t, "ETH_TEST5", "3")
+	c := client.New(service.url, client.WithAPIKey(service.apiKey))
+	ctx := context.Background()
+
+	created, err := c.CreatePayout(ctx, wallet.ID, client.CreatePayoutRequest{AssetID: "ETH_TEST5", Lines: []client.CreatePayoutLine{
+		{DestinationAddress: "0xalice", Amount: "1", Note: "June salary"},
+		{DestinationAddress: "0xbob", Amount: "1.5", Note: "June salary"},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "2.5", created.Total)
+
+	var payout *client.PayoutResponse
+	assert.Eventually(t, func() bool {
+		payout, err = c.GetPayout(ctx, wallet.ID, created.ID)
+		return err == nil && payout.Status == string(model.PayoutStatusCompleted)
+	}, 5*time.Second, 20*time.Millisecond)
+	for _, line := range payout.Lines {
+		assert.Equal(t, string(model.PayoutLineStatusSubmitted), line.Status)
+		assert.Equal(t, line.Amount, service.transfer(t, line.TransactionID).Amount)
+	}
+
+	// the submitted lines are held by Fireblocks, so the 0.5 left cannot pay out more
+	_, err = c.CreatePayout(ctx, wallet.ID, client.CreatePayoutRequest{AssetID: "ETH_TEST5", Lines: []client.CreatePayoutLine{
+		{DestinationAddress: "0xcarol", Amount: "1"},
+	}})
+	assert.ErrorIs(t, err, client.ErrInsufficientBalance)
+}
+
+func TestSchedule(t *testing.T) {
+	service := newTestService(t)
+	wallet := service.createFundedWallet(t, "ETH_TEST5", "3")
+	c := client.New(service.url, client.WithAPIKey(service.apiKey))
+	ctx := context.Background()
+
+	runAt := time.Now().Add(time.Second).UTC().Truncate(time.Second)
+	oneOff, err := c.CreateSchedule(ctx, wallet.ID, client.CreateScheduleRequest{AssetID: "ETH_TEST5", Amount: "1", DestinationAddress: "0xcold", RunAt: &runAt})
+	assert.NoError(t, err)
+	recurring, err := c.CreateSchedule(ctx, wallet.ID, client.CreateScheduleRequest{AssetID: "ETH_TEST5", Amount: "1", DestinationAddress: "0xcold", Cron: "* * * * *"})
+	assert.NoError(t, err)
+	_, err = c.PauseSchedule(ctx, wallet.ID, recurring.ID)
+	assert.NoError(t, err)
+
+	var runs *client.ListScheduleRunsResponse
+	assert.Eventually(t, func() bool {
+		runs, err = c.ListScheduleRuns(ctx, wallet.ID, oneOff.ID, 0)
+		return err == nil && len(runs.Runs) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, string(model.TransferScheduleRunStatusSubmitted), runs.Runs[0].Status)
+	assert.Equal(t, runAt, runs.Runs[0].ScheduledAt.UTC())
+	assert.Equal(t, "0xcold", service.transfer(t, runs.Runs[0].TransactionID).DestinationAddress)
+
+	schedules, err := c.ListSchedules(ctx, wallet.ID)
+	assert.NoError(t, err)
+	assert.Len(t, schedules.Schedules, 2)
+	assert.Equal(t, string(model.TransferScheduleStatusCompleted), schedules.Schedules[0].Status)
+	assert.Nil(t, schedules.Schedules[0].NextRunAt)
+	// the paused schedule did not run
+	runs, err = c.ListScheduleRuns(ctx, wallet.ID, recurring.ID, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, runs.Runs)
+
+	assert.NoError(t, c.DeleteSchedule(ctx, wallet.ID, oneOff.ID))
+	_, err = c.ListScheduleRuns(ctx, wallet.ID, oneOff.ID, 0)
+	assert.ErrorIs(t, err, client.ErrScheduleNotFound)
+}
+
+func TestSweep(t *testing.T) {
+	const treasuryID = "5b0f3c9e-8a52-4c1d-9a0e-2f7d6b1c4e83"
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.Sweeps.Interval = 50 * time.Millisecond
+		cfg.Sweeps.Rules = map[string]sweep.Rule{"ETH_TEST5": {Threshold: "1", MinLeftover: "0.1", TreasuryWalletID: treasuryID}}
+	})
+	ctx := context.Background()
+
+	// the treasury wallet is inserted with the ID of the rule, which is read when the service starts
+	vault, _, err := service.fireblocks.CreateVaultAccount(ctx, fireblocks.CreateVaultAccountRequest{Name: "treasury"})
+	assert.NoError(t, err)
+	assert.NoError(t, service.db.Create(&model.Wallet{ID: treasuryID, Name: "treasury", VaultAccountID: vault.ID}).Error)
+	wallet := service.createFundedWallet(t, "ETH_TEST5", "3")
+
+	var sweeps handler.ListSweepsResponse
+	assert.Eventually(t, func() bool {
+		status := service.do(t, http.MethodGet, "/admin/sweeps?walletId="+wallet.ID, nil, &sweeps)
+		return status == http.StatusOK && len(sweeps.Sweeps) == 1 && sweeps.Sweeps[0].Status == string(model.SweepStatusSubmitted)
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "2.9", sweeps.Sweeps[0].Amount)
+	assert.Equal(t, sweep.TreasuryAddress(vault.ID), service.transfer(t, sweeps.Sweeps[0].TransactionID).DestinationAddress)
+
+	// the completed sweep is booked into the treasury wallet, without a deposit
+	for range 5 {
+		service.sim.Advance(ctx)
+	}
+	var balance handler.LedgerBalanceResponse
+	assert.Eventually(t, func() bool {
+		status := service.do(t, http.MethodGet, "/wallets/"+treasuryID+"/assets/ETH_TEST5/ledger/balance", nil, &balance)
+		return status == http.StatusOK && balance.Total == "2.9"
+	}, 5*time.Second, 20*time.Millisecond)
+	var deposits handler.ListDepositsResponse
+	assert.Equal(t, http.StatusOK, service.do(t, http.MethodGet, "/wallets/"+treasuryID+"/deposits", nil, &deposits))
+	assert.Empty(t, deposits.Deposits)
+}
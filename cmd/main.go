@@ -1,88 +1,219 @@
 package main
 
 import (
-	"firego-wallet-service/internal/database"
-	"firego-wallet-service/internal/fireblocks"
+	"context"
+	"crypto/rsa"
+	"firego-wallet-service/internal/bootstrap"
+	"firego-wallet-service/internal/config"
 	"firego-wallet-service/internal/handler"
-	"firego-wallet-service/internal/repository"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/migrate"
+	"firego-wallet-service/internal/tracing"
+	"flag"
+	"fmt"
 	"github.com/golang-jwt/jwt/v5"
-	"log"
+	"gopkg.in/yaml.v3"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"syscall"
+	"time"
 )
 
+// transferDrainMargin is added to the Fireblocks timeout when waiting for transfers at shutdown, so that a transfer
+// being submitted is either stored or known to have failed
+const transferDrainMargin = 10 * time.Second
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
 func main() {
-	port := getEnv("PORT", "8080")
+	startedAt := time.Now()
 
-	fireblocksBaseURL, ok := os.LookupEnv("FIREBLOCKS_BASE_URL")
-	if !ok || fireblocksBaseURL == "" {
-		log.Fatal("FIREBLOCKS_BASE_URL not set")
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path of the YAML configuration file, overridden by the environment")
+	printConfig := flag.Bool("print-config", false, "print the configuration, with secrets masked, and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [migrate up | down [STEPS] | status]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath, os.LookupEnv)
+	if *printConfig && cfg != nil {
+		out, marshalErr := yaml.Marshal(cfg)
+		if marshalErr != nil {
+			fatal("failed to print configuration", "error", marshalErr)
+		}
+		os.Stdout.Write(out)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		return
 	}
 
-	fireblocksAPIKey, ok := os.LookupEnv("FIREBLOCKS_API_KEY")
-	if !ok || fireblocksAPIKey == "" {
-		log.Fatal("FIREBLOCKS_API_KEY not set")
+	// the configuration was validated, the levels parse
+	logLevel, _ := logging.ParseLevel(cfg.Log.Level)
+	slog.SetDefault(logging.New(os.Stdout, logLevel))
+
+	if flag.Arg(0) == "migrate" {
+		if err = runMigrate(cfg, flag.Args()[1:]); err != nil {
+			fatal("migration failed", "error", err)
+		}
+		return
 	}
 
-	fireblocksSecretKeyPath, ok := os.LookupEnv("FIREBLOCKS_SECRET_KEY_PATH")
-	if !ok || fireblocksSecretKeyPath == "" {
-		log.Fatal("FIREBLOCKS_SECRET_KEY_PATH not set")
+	fireblocksPrivateKey, err := bootstrap.LoadPrivateKey(cfg.Fireblocks.SecretKeyPath)
+	if err != nil {
+		fatal("failed to load the Fireblocks private key", "error", err)
 	}
-	fireblocksPrivateKeyBytes, err := os.ReadFile(fireblocksSecretKeyPath)
+
+	var fireblocksWebhookPublicKey *rsa.PublicKey
+	if cfg.Features.Webhooks {
+		webhookPublicKeyBytes, err := os.ReadFile(cfg.Fireblocks.WebhookPublicKeyPath)
+		if err != nil {
+			fatal("error reading webhook public key", "path", cfg.Fireblocks.WebhookPublicKeyPath, "error", err)
+		}
+		fireblocksWebhookPublicKey, err = jwt.ParseRSAPublicKeyFromPEM(webhookPublicKeyBytes)
+		if err != nil {
+			fatal("error parsing webhook RSA public key", "error", err)
+		}
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter: cfg.Tracing.Exporter,
+		FilePath: cfg.Tracing.File,
+	})
+	if err != nil {
+		fatal("failed to set up tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	db, sqlDB, err := bootstrap.OpenDatabase(cfg)
 	if err != nil {
-		log.Fatalf("error reading private key from %s: %v", fireblocksSecretKeyPath, err)
+		fatal("failed to connect to database", "error", err)
 	}
-	fireblocksPrivateKey, err := jwt.ParseRSAPrivateKeyFromPEM(fireblocksPrivateKeyBytes)
+
+	migrator, err := migrate.New(sqlDB)
 	if err != nil {
-		log.Fatalf("error parsing RSA private key: %v", err)
+		fatal("failed to load migrations", "error", err)
+	}
+	if err = migrator.Check(context.Background()); err != nil {
+		fatal("refusing to start", "error", err)
 	}
 
-	dbHost := getEnv("DB_HOST", "localhost")
-	dbPort := getEnv("DB_PORT", "5432")
-	dbName := getEnv("DB_NAME", "firego_wallet")
-	dbSSLMode := getEnv("DB_SSL_MODE", "disable")
+	fireblocksClient := bootstrap.NewFireblocksClient(cfg, fireblocksPrivateKey)
+	app := newApp(cfg, db, sqlDB, fireblocksClient, fireblocksWebhookPublicKey, startedAt)
 
-	dbUser, ok := os.LookupEnv("DB_USER")
-	if !ok || dbUser == "" {
-		log.Fatal("DB_USER environment variable is required")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// background workers stop once ctx is cancelled, the shutdown waits for them
+	var workers sync.WaitGroup
+	for _, run := range app.workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(ctx)
+		}()
 	}
 
-	dbPassword, ok := os.LookupEnv("DB_PASSWORD")
-	if !ok || dbPassword == "" {
-		log.Fatal("DB_PASSWORD environment variable is required")
+	server := &http.Server{
+		Addr:           ":" + cfg.Server.Port,
+		Handler:        app.handler,
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
+		IdleTimeout:    cfg.Server.IdleTimeout,
+		MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
+		ErrorLog:       slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
 	}
 
-	db, err := database.Connect(dbHost, dbPort, dbName, dbUser, dbPassword, dbSSLMode)
-	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Listening", "port", cfg.Server.Port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serverErr:
+		fatal("server stopped", "error", err)
+	case <-ctx.Done():
 	}
+	stop()
 
-	mux := http.NewServeMux()
+	slog.Info("Shutting down", "timeout", cfg.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
 
-	fireblocksClient := fireblocks.NewClient(fireblocksBaseURL, fireblocksAPIKey, fireblocksPrivateKey)
-	walletRepo := repository.NewWalletRepository(db)
-	walletHandler := handler.NewWalletHandler(walletRepo, fireblocksClient)
+	if err = server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to drain in-flight requests", "error", err)
+		server.Close()
+	}
 
-	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
-		resp, _ := fireblocksClient.GetAccountsPaged()
+	// The workers were stopped with ctx, those submitting a transaction, a payout line, a sweep or a scheduled
+	// transfer, finish storing it first. Then the transfers already submitted to Fireblocks are stored even if their
+	// request was cut off above; no transfer starts once they are waited for.
+	transfersCtx, cancelTransfers := context.WithTimeout(context.Background(), cfg.Fireblocks.Timeout+transferDrainMargin)
+	defer cancelTransfers()
+	if err = waitFor(transfersCtx, &workers); err != nil {
+		slog.Error("Failed to stop background workers", "error", err)
+	}
+	if err = app.transfers.WaitForTransfers(transfersCtx); err != nil {
+		slog.Error("Transfers still being submitted at shutdown, the transfer poller will not see them", "error", err)
+	}
+	for _, elector := range app.electors {
+		elector.Release()
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(200)
-		w.Write(resp)
-	})
+	if err = sqlDB.Close(); err != nil {
+		slog.Error("Failed to close database connections", "error", err)
+	}
+	slog.Info("Shut down")
+}
 
-	mux.HandleFunc("POST /wallets", walletHandler.CreateWallet)
-	mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/balance", walletHandler.GetWalletBalance)
-	mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/address", walletHandler.GetDepositAddress)
-	mux.HandleFunc("POST /wallets/{walletId}/transactions", walletHandler.InitiateTransfer)
+// waitFor waits for the group, or until the context is done
+func waitFor(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	log.Printf("Listening on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, mux))
+// fatal logs the error and exits, the deferred functions of main are not run
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+// readBuildInfo completes the version with the VCS information Go embeds in the binary
+func readBuildInfo() handler.BuildInfo {
+	info := handler.BuildInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = setting.Value
+			case "vcs.time":
+				info.BuildTime = setting.Value
+			}
+		}
 	}
-	return fallback
+	return info
 }
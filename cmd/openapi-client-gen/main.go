@@ -0,0 +1,31 @@
+// Command openapi-client-gen generates the typed client of pkg/walletapi from the OpenAPI specification, see
+// openapi.GenerateClient. It is run by go generate in pkg/walletapi.
+package main
+
+import (
+	"firego-wallet-service/internal/openapi"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	packageName := flag.String("package", "walletapi", "package of the generated file")
+	output := flag.String("o", "client.gen.go", "file to write")
+	flag.Parse()
+
+	doc, err := openapi.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	source, err := openapi.GenerateClient(doc, *packageName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate the client: %v\n", err)
+		os.Exit(1)
+	}
+	if err = os.WriteFile(*output, source, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
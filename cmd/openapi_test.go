@@ -0,0 +1,53 @@
+package main
+
+import (
+	"firego-wallet-service/internal/openapi"
+	"github.com/stretchr/testify/assert"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestOpenAPICoversRoutes keeps the specification in line with the routes registered in newApp
+func TestOpenAPICoversRoutes(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "app.go", nil, 0)
+	assert.NoError(t, err)
+
+	var routes []string
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		selector, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (selector.Sel.Name != "HandleFunc" && selector.Sel.Name != "Handle") {
+			return true
+		}
+		if ident, ok := selector.X.(*ast.Ident); !ok || ident.Name != "mux" {
+			return true
+		}
+		if literal, ok := call.Args[0].(*ast.BasicLit); ok && literal.Kind == token.STRING {
+			pattern, err := strconv.Unquote(literal.Value)
+			assert.NoError(t, err)
+			routes = append(routes, pattern)
+		}
+		return true
+	})
+	sort.Strings(routes)
+
+	doc := openapi.MustLoad()
+	var documented []string
+	for _, path := range doc.PathList() {
+		for method := range doc.Paths[path] {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(documented)
+
+	assert.NotEmpty(t, routes)
+	assert.Equal(t, routes, documented)
+}
@@ -0,0 +1,75 @@
+// Package apierror writes the error responses of the API. They are the plain text message, unless the client accepts
+// application/json, in which case they are an Error with a stable code that clients can act on.
+package apierror
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Error codes, the message may change but the codes do not
+const (
+	// InvalidRequest is a missing or invalid field, path or query parameter
+	InvalidRequest = "invalid_request"
+	// InvalidBody is a request body that is not valid JSON
+	InvalidBody      = "invalid_body"
+	InvalidAmount    = "invalid_amount"
+	BodyTooLarge     = "body_too_large"
+	WalletNotFound   = "wallet_not_found"
+	PayoutNotFound   = "payout_not_found"
+	ScheduleNotFound = "schedule_not_found"
+	// ScheduleCompleted is a one-off schedule that has already run, which can no longer be paused or resumed
+	ScheduleCompleted   = "schedule_completed"
+	NoDepositAddress    = "no_deposit_address"
+	InsufficientBalance = "insufficient_balance"
+	// FireblocksRejected is a request Fireblocks refused, an unknown asset or a transaction blocked by policy
+	FireblocksRejected = "fireblocks_rejected"
+	InvalidSignature   = "invalid_signature"
+	// Unauthorized is a missing, unknown or revoked X-API-Key, when API keys are required
+	Unauthorized = "unauthorized"
+	// Forbidden is a valid X-API-Key not issued for the admin endpoints
+	Forbidden   = "forbidden"
+	RateLimited = "rate_limited"
+	// IdempotencyKeyReused is an Idempotency-Key sent again with a different request
+	IdempotencyKeyReused = "idempotency_key_reused"
+	// RequestInProgress is an Idempotency-Key whose first request has not completed yet
+	RequestInProgress = "request_in_progress"
+	// ServiceUnavailable is Fireblocks failing or not answering, the request can be retried
+	ServiceUnavailable = "service_unavailable"
+	Internal           = "internal_error"
+)
+
+// Error is the JSON error response
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Write answers the request with the error, as JSON if the client accepts it and as text otherwise
+func Write(w http.ResponseWriter, r *http.Request, statusCode int, code, message string) {
+	if !AcceptsJSON(r) {
+		http.Error(w, message, statusCode)
+		return
+	}
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(Error{Code: code, Message: message})
+}
+
+// AcceptsJSON reports whether the Accept header of the request names application/json. Wildcards do not count, so
+// that browsers and curl keep the text errors.
+func AcceptsJSON(r *http.Request) bool {
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err == nil && mediaType == "application/json" && params["q"] != "0" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package apierror
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{name: "no_accept", contentType: "text/plain; charset=utf-8", body: "Wallet not found\n"},
+		{name: "wildcard", accept: "*/*", contentType: "text/plain; charset=utf-8", body: "Wallet not found\n"},
+		{name: "json", accept: "application/json", contentType: "application/json", body: `{"code":"wallet_not_found","message":"Wallet not found"}` + "\n"},
+		{name: "json_among_others", accept: "text/html, application/json;q=0.9", contentType: "application/json", body: `{"code":"wallet_not_found","message":"Wallet not found"}` + "\n"},
+		{name: "json_refused", accept: "application/json;q=0", contentType: "text/plain; charset=utf-8", body: "Wallet not found\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/wallets/1/deposits", nil)
+			req.Header.Set("Accept", tt.accept)
+			recorder := httptest.NewRecorder()
+
+			Write(recorder, req, http.StatusNotFound, WalletNotFound, "Wallet not found")
+
+			assert.Equal(t, http.StatusNotFound, recorder.Code)
+			assert.Equal(t, tt.contentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tt.body, recorder.Body.String())
+		})
+	}
+}
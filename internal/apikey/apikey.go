@@ -0,0 +1,180 @@
+// Package apikey issues the keys the clients authenticate with, and checks them on the requests. Only the SHA-256 of
+// a key is stored: a key is shown once, when it is issued.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/model"
+	"gorm.io/gorm"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Header carries the API key of a request
+const Header = "X-API-Key"
+
+// keyPrefix marks the keys of the service, so that leaked ones are recognized by secret scanners
+const keyPrefix = "fgw_"
+
+// shownPrefixLength is how much of a key is kept in clear, for operators to tell the keys apart
+const shownPrefixLength = len(keyPrefix) + 8
+
+// CacheTTL is how long a valid key is trusted without looking it up again, a revoked key stops working within it
+const CacheTTL = 30 * time.Second
+
+type Repository interface {
+	Create(ctx context.Context, key *model.APIKey) error
+	// GetByHash returns gorm.ErrRecordNotFound for unknown keys
+	GetByHash(ctx context.Context, hash string) (*model.APIKey, error)
+	List(ctx context.Context) ([]model.APIKey, error)
+	// Revoke returns gorm.ErrRecordNotFound when the key does not exist or was already revoked
+	Revoke(ctx context.Context, id string, revokedAt time.Time) error
+}
+
+type contextKey struct{}
+
+type Keys struct {
+	repo Repository
+	now  func() time.Time
+
+	mu sync.Mutex
+	// valid holds the keys found valid, by hash
+	valid map[string]cachedKey
+}
+
+type cachedKey struct {
+	key       *model.APIKey
+	expiresAt time.Time
+}
+
+func New(repo Repository) *Keys {
+	return &Keys{
+		repo:  repo,
+		now:   time.Now,
+		valid: map[string]cachedKey{},
+	}
+}
+
+// Issue creates a key named after who it is for, allowed on the admin endpoints when admin is set. The returned
+// string is the key itself, it cannot be read again.
+func (k *Keys) Issue(ctx context.Context, name string, admin bool) (string, *model.APIKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	key := keyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := &model.APIKey{
+		Name:   name,
+		Prefix: key[:shownPrefixLength],
+		Hash:   Hash(key),
+		Admin:  admin,
+	}
+	if err := k.repo.Create(ctx, apiKey); err != nil {
+		return "", nil, err
+	}
+	return key, apiKey, nil
+}
+
+// Revoke stops the key from authenticating requests, after at most CacheTTL on the instances that recently saw it
+func (k *Keys) Revoke(ctx context.Context, id string) error {
+	return k.repo.Revoke(ctx, id, k.now())
+}
+
+func (k *Keys) List(ctx context.Context) ([]model.APIKey, error) {
+	return k.repo.List(ctx)
+}
+
+// Wrap only lets the requests with a valid X-API-Key reach handler, the others are answered 401. The key is put in
+// the context of the request, see FromContext.
+func (k *Keys) Wrap(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" {
+			apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Missing "+Header+" header")
+			return
+		}
+
+		apiKey, err := k.authenticate(r.Context(), key)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to look up API key", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+			return
+		}
+		if apiKey == nil {
+			apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Invalid API key")
+			return
+		}
+		handler(w, r.WithContext(NewContext(r.Context(), apiKey)))
+	}
+}
+
+// WrapAdmin is Wrap for the admin endpoints: requests with a valid key that was not issued for them are answered 403
+func (k *Keys) WrapAdmin(handler http.HandlerFunc) http.HandlerFunc {
+	return k.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		if apiKey, _ := FromContext(r.Context()); !apiKey.Admin {
+			apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "API key not allowed on admin endpoints")
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// NewContext returns a copy of ctx carrying the key a request was authenticated with
+func NewContext(ctx context.Context, apiKey *model.APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, apiKey)
+}
+
+// FromContext returns the key the request was authenticated with, ok is false outside a request let through by Wrap
+func FromContext(ctx context.Context) (_ *model.APIKey, ok bool) {
+	apiKey, ok := ctx.Value(contextKey{}).(*model.APIKey)
+	return apiKey, ok
+}
+
+// authenticate returns the key if it exists and is not revoked, nil otherwise. Only valid keys are cached, so that
+// requests with made-up keys cannot fill the cache.
+func (k *Keys) authenticate(ctx context.Context, key string) (*model.APIKey, error) {
+	hash := Hash(key)
+	now := k.now()
+
+	k.mu.Lock()
+	cached, ok := k.valid[hash]
+	if ok && !now.Before(cached.expiresAt) {
+		delete(k.valid, hash)
+		ok = false
+	}
+	k.mu.Unlock()
+	if ok {
+		return cached.key, nil
+	}
+
+	apiKey, err := k.repo.GetByHash(ctx, hash)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.RevokedAt != nil {
+		return nil, nil
+	}
+
+	k.mu.Lock()
+	k.valid[hash] = cachedKey{key: apiKey, expiresAt: now.Add(CacheTTL)}
+	k.mu.Unlock()
+	return apiKey, nil
+}
+
+// Hash is what is stored of a key. Keys are random, a plain SHA-256 is enough to keep them out of the database.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
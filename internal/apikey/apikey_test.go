@@ -0,0 +1,184 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockRepository struct {
+	keys    []*model.APIKey
+	lookups int
+	err     error
+}
+
+func (m *MockRepository) Create(_ context.Context, key *model.APIKey) error {
+	key.ID = "key-" + strconv.Itoa(len(m.keys)+1)
+	m.keys = append(m.keys, key)
+	return nil
+}
+
+func (m *MockRepository) GetByHash(_ context.Context, hash string) (*model.APIKey, error) {
+	m.lookups++
+	if m.err != nil {
+		return nil, m.err
+	}
+	for _, key := range m.keys {
+		if key.Hash == hash {
+			copied := *key
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockRepository) List(_ context.Context) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	for _, key := range m.keys {
+		keys = append(keys, *key)
+	}
+	return keys, nil
+}
+
+func (m *MockRepository) Revoke(_ context.Context, id string, revokedAt time.Time) error {
+	for _, key := range m.keys {
+		if key.ID == id && key.RevokedAt == nil {
+			key.RevokedAt = &revokedAt
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func TestIssue(t *testing.T) {
+	repo := &MockRepository{}
+	keys := New(repo)
+
+	key, apiKey, err := keys.Issue(context.Background(), "payments-team", false)
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "fgw_"))
+	assert.Equal(t, "payments-team", apiKey.Name)
+	assert.Equal(t, key[:12], apiKey.Prefix)
+	assert.Equal(t, Hash(key), apiKey.Hash)
+	assert.NotContains(t, apiKey.Hash, key)
+
+	other, _, err := keys.Issue(context.Background(), "payments-team", false)
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestWrap(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := &MockRepository{}
+	keys := New(repo)
+	keys.now = func() time.Time { return now }
+	key, apiKey, err := keys.Issue(context.Background(), "payments-team", false)
+	assert.NoError(t, err)
+	handler := keys.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		authenticated, ok := FromContext(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, apiKey.ID, authenticated.ID)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	serve := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/wallets/1/deposits", nil)
+		req.Header.Set("Accept", "application/json")
+		if apiKey != "" {
+			req.Header.Set(Header, apiKey)
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+		return recorder
+	}
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "valid", apiKey: key, wantStatus: http.StatusNoContent},
+		{name: "missing", wantStatus: http.StatusUnauthorized, wantBody: `{"code": "unauthorized", "message": "Missing X-API-Key header"}`},
+		{name: "unknown", apiKey: "fgw_unknown", wantStatus: http.StatusUnauthorized, wantBody: `{"code": "unauthorized", "message": "Invalid API key"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(tt.apiKey)
+			assert.Equal(t, tt.wantStatus, recorder.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, recorder.Body.String())
+			}
+		})
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		lookups := repo.lookups
+		assert.Equal(t, http.StatusNoContent, serve(key).Code)
+		assert.Equal(t, lookups, repo.lookups)
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		assert.NoError(t, keys.Revoke(context.Background(), apiKey.ID))
+		assert.ErrorIs(t, keys.Revoke(context.Background(), apiKey.ID), gorm.ErrRecordNotFound)
+
+		// the key is trusted until its cache entry expires
+		assert.Equal(t, http.StatusNoContent, serve(key).Code)
+		now = now.Add(CacheTTL)
+		assert.Equal(t, http.StatusUnauthorized, serve(key).Code)
+	})
+
+	t.Run("repository_failure", func(t *testing.T) {
+		repo.err = errors.New("connection refused")
+		assert.Equal(t, http.StatusInternalServerError, serve("fgw_other").Code)
+	})
+}
+
+func TestWrapAdmin(t *testing.T) {
+	keys := New(&MockRepository{})
+	key, _, err := keys.Issue(context.Background(), "payments-team", false)
+	assert.NoError(t, err)
+	adminKey, _, err := keys.Issue(context.Background(), "operations", true)
+	assert.NoError(t, err)
+	handler := keys.WrapAdmin(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "admin", apiKey: adminKey, wantStatus: http.StatusNoContent},
+		{name: "not_admin", apiKey: key, wantStatus: http.StatusForbidden, wantBody: `{"code": "forbidden", "message": "API key not allowed on admin endpoints"}`},
+		{name: "missing", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/audit", nil)
+			req.Header.Set("Accept", "application/json")
+			if tt.apiKey != "" {
+				req.Header.Set(Header, tt.apiKey)
+			}
+			recorder := httptest.NewRecorder()
+			handler(recorder, req)
+
+			assert.Equal(t, tt.wantStatus, recorder.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"firego-wallet-service/internal/model"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GenesisHash is the PrevHash of the first entry in the chain
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// verifyBatchSize is the number of entries loaded at once when verifying the chain
+const verifyBatchSize = 1000
+
+type Filter struct {
+	Actor     string
+	Action    string
+	Target    string
+	Outcome   model.AuditOutcome
+	RequestID string
+	Since     *time.Time
+	Until     *time.Time
+	// BeforeSequence pages backwards through the log, zero starts at the latest entry
+	BeforeSequence int64
+	Limit          int
+}
+
+type Repository interface {
+	// Append chains the entry to the latest one with Seal and stores it, serialized with concurrent appends
+	Append(entry *model.AuditEntry) error
+	// List returns the entries matching the filter, latest first
+	List(filter Filter) ([]model.AuditEntry, error)
+	// ListFromSequence returns up to limit entries with a sequence of at least from, in chain order
+	ListFromSequence(from int64, limit int) ([]model.AuditEntry, error)
+}
+
+// Verification is the result of walking the whole chain. BrokenAt is the sequence of the first entry that
+// does not link to its predecessor, zero if the chain is intact.
+type Verification struct {
+	Valid    bool
+	Checked  int
+	BrokenAt int64
+	Reason   string
+}
+
+type Log struct {
+	repo Repository
+}
+
+func NewLog(repo Repository) *Log {
+	return &Log{
+		repo: repo,
+	}
+}
+
+// Record appends the entry to the chain. CreatedAt is set here, at the precision Postgres stores,
+// so that the hash can be recomputed from the stored row.
+func (l *Log) Record(entry *model.AuditEntry) error {
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	if err := l.repo.Append(entry); err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	return nil
+}
+
+func (l *Log) List(filter Filter) ([]model.AuditEntry, error) {
+	return l.repo.List(filter)
+}
+
+// Verify recomputes every hash in the chain and reports the first entry that was altered, inserted or
+// follows a removed one
+func (l *Log) Verify() (*Verification, error) {
+	result := &Verification{Valid: true}
+	prevHash := GenesisHash
+	next := int64(1)
+
+	for {
+		entries, err := l.repo.ListFromSequence(next, verifyBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list audit entries: %w", err)
+		}
+
+		for _, entry := range entries {
+			var reason string
+			switch {
+			case entry.Sequence != next:
+				reason = fmt.Sprintf("expected sequence %d", next)
+			case entry.PrevHash != prevHash:
+				reason = "previous hash does not match"
+			case entry.Hash != Hash(entry):
+				reason = "hash does not match the entry"
+			}
+			if reason != "" {
+				result.Valid = false
+				result.BrokenAt = entry.Sequence
+				result.Reason = reason
+				return result, nil
+			}
+
+			result.Checked++
+			prevHash = entry.Hash
+			next++
+		}
+
+		if len(entries) < verifyBatchSize {
+			return result, nil
+		}
+	}
+}
+
+// Seal links the entry to the previous one in the chain, nil for the first entry, and computes its hash
+func Seal(entry *model.AuditEntry, prev *model.AuditEntry) {
+	entry.Sequence = 1
+	entry.PrevHash = GenesisHash
+	if prev != nil {
+		entry.Sequence = prev.Sequence + 1
+		entry.PrevHash = prev.Hash
+	}
+	entry.Hash = Hash(*entry)
+}
+
+// Hash is the hex SHA-256 of the entry's content and its PrevHash. The fields are JSON encoded as an array,
+// which keeps the encoding unambiguous whatever the field values contain.
+func Hash(entry model.AuditEntry) string {
+	content, _ := json.Marshal([]interface{}{
+		entry.Sequence,
+		entry.PrevHash,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		entry.Actor,
+		entry.Action,
+		entry.Target,
+		entry.RequestID,
+		entry.SourceIP,
+		entry.Payload,
+		entry.Outcome,
+		entry.StatusCode,
+		entry.FireblocksIDs,
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
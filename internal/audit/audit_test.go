@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"errors"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type MockRepository struct {
+	Entries     []model.AuditEntry
+	AppendError error
+}
+
+func (m *MockRepository) Append(entry *model.AuditEntry) error {
+	if m.AppendError != nil {
+		return m.AppendError
+	}
+	var prev *model.AuditEntry
+	if len(m.Entries) > 0 {
+		prev = &m.Entries[len(m.Entries)-1]
+	}
+	Seal(entry, prev)
+	m.Entries = append(m.Entries, *entry)
+	return nil
+}
+
+func (m *MockRepository) List(filter Filter) ([]model.AuditEntry, error) {
+	return m.Entries, nil
+}
+
+func (m *MockRepository) ListFromSequence(from int64, limit int) ([]model.AuditEntry, error) {
+	var entries []model.AuditEntry
+	for _, entry := range m.Entries {
+		if entry.Sequence >= from && len(entries) < limit {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func recordEntries(t *testing.T, log *Log, actions ...string) {
+	for _, action := range actions {
+		err := log.Record(&model.AuditEntry{
+			Actor:      "alice",
+			Action:     action,
+			RequestID:  "req-" + action,
+			Outcome:    model.AuditOutcomeSuccess,
+			StatusCode: 201,
+		})
+		assert.NoError(t, err)
+	}
+}
+
+func TestRecordChainsEntries(t *testing.T) {
+	repo := &MockRepository{}
+	log := NewLog(repo)
+
+	recordEntries(t, log, "wallet.create", "transfer.initiate")
+
+	assert.Len(t, repo.Entries, 2)
+	assert.Equal(t, int64(1), repo.Entries[0].Sequence)
+	assert.Equal(t, GenesisHash, repo.Entries[0].PrevHash)
+	assert.Equal(t, int64(2), repo.Entries[1].Sequence)
+	assert.Equal(t, repo.Entries[0].Hash, repo.Entries[1].PrevHash)
+	assert.Len(t, repo.Entries[1].Hash, 64)
+	assert.False(t, repo.Entries[0].CreatedAt.IsZero())
+}
+
+func TestRecordError(t *testing.T) {
+	log := NewLog(&MockRepository{AppendError: errors.New("database error")})
+
+	err := log.Record(&model.AuditEntry{Action: "wallet.create"})
+
+	assert.ErrorContains(t, err, "database error")
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name         string
+		tamper       func(entries []model.AuditEntry) []model.AuditEntry
+		wantValid    bool
+		wantBrokenAt int64
+	}{
+		{
+			name:      "intact",
+			tamper:    func(entries []model.AuditEntry) []model.AuditEntry { return entries },
+			wantValid: true,
+		},
+		{
+			name: "altered_entry",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry {
+				entries[1].Actor = "mallory"
+				return entries
+			},
+			wantBrokenAt: 2,
+		},
+		{
+			name: "removed_entry",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry {
+				return append(entries[:1], entries[2:]...)
+			},
+			wantBrokenAt: 3,
+		},
+		{
+			name: "rehashed_entry",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry {
+				entries[0].Outcome = model.AuditOutcomeFailure
+				entries[0].Hash = Hash(entries[0])
+				return entries
+			},
+			wantBrokenAt: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &MockRepository{}
+			log := NewLog(repo)
+			recordEntries(t, log, "wallet.create", "transfer.initiate", "ledger.allocate")
+			repo.Entries = tt.tamper(repo.Entries)
+
+			verification, err := log.Verify()
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantValid, verification.Valid)
+			assert.Equal(t, tt.wantBrokenAt, verification.BrokenAt)
+			if tt.wantValid {
+				assert.Equal(t, 3, verification.Checked)
+			} else {
+				assert.NotEmpty(t, verification.Reason)
+			}
+		})
+	}
+}
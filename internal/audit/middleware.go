@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/apikey"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/model"
+	"github.com/google/uuid"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ActorHeader names who performs the action on behalf of the API key, e.g. the operator behind a back office. It
+	// is self-declared, so it is only recorded next to the name of the key, or alone when API keys are not required.
+	ActorHeader = "X-Actor"
+
+	anonymousActor = "anonymous"
+	redactedValue  = "[REDACTED]"
+
+	// maxPayloadSize bounds the request bodies of audited endpoints, which are all small JSON documents
+	maxPayloadSize = 1 << 20
+)
+
+// redactedFields are request fields never stored in the audit log, matched case-insensitively at any depth
+var redactedFields = map[string]bool{
+	"destinationaddress": true,
+	"address":            true,
+	"customerrefid":      true,
+	"note":               true,
+}
+
+type contextKey struct{}
+
+// record collects what only the handler knows about the action
+type record struct {
+	target        string
+	fireblocksIDs []string
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Wrap records an audit entry for every request served by next, once it has responded. The target defaults
+// to the wallet in the path; handlers refine it with SetTarget and attach Fireblocks IDs with AddFireblocksID.
+func (l *Log) Wrap(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// the request ID is assigned by logging.Middleware, which wraps every route
+		requestID := logging.RequestID(r.Context())
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+
+		entry := &model.AuditEntry{
+			Actor:     actor(r),
+			Action:    action,
+			RequestID: requestID,
+			SourceIP:  sourceIP(r),
+		}
+		rec := &record{target: r.PathValue("walletId")}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+		if err != nil {
+			apierror.Write(recorder, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
+		} else {
+			entry.Payload = redactPayload(body)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next(recorder, r.WithContext(context.WithValue(r.Context(), contextKey{}, rec)))
+		}
+
+		entry.Target = rec.target
+		entry.FireblocksIDs = strings.Join(rec.fireblocksIDs, ",")
+		entry.StatusCode = recorder.status
+		entry.Outcome = model.AuditOutcomeSuccess
+		if recorder.status >= http.StatusBadRequest {
+			entry.Outcome = model.AuditOutcomeFailure
+		}
+
+		if err = l.Record(entry); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to record audit entry", "action", action, "error", err)
+		}
+	}
+}
+
+// SetTarget sets the resource the audited action applies to. It is a no-op outside an audited request.
+func SetTarget(ctx context.Context, target string) {
+	if rec, ok := ctx.Value(contextKey{}).(*record); ok {
+		rec.target = target
+	}
+}
+
+// AddFireblocksID attaches the ID of a Fireblocks object the audited action created or used.
+// It is a no-op outside an audited request.
+func AddFireblocksID(ctx context.Context, id string) {
+	if rec, ok := ctx.Value(contextKey{}).(*record); ok && id != "" {
+		rec.fireblocksIDs = append(rec.fireblocksIDs, id)
+	}
+}
+
+// actor is the name of the API key the request was authenticated with, followed by the X-Actor it declares if any
+func actor(r *http.Request) string {
+	declared := r.Header.Get(ActorHeader)
+	if apiKey, ok := apikey.FromContext(r.Context()); ok {
+		if declared == "" {
+			return apiKey.Name
+		}
+		return apiKey.Name + "/" + declared
+	}
+	if declared == "" {
+		return anonymousActor
+	}
+	return declared
+}
+
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// redactPayload returns the JSON body with sensitive fields masked. Bodies that are not valid JSON are not
+// stored, as they cannot be redacted reliably.
+func redactPayload(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var payload interface{}
+	if err := decoder.Decode(&payload); err != nil {
+		return redactedValue
+	}
+
+	redacted, err := json.Marshal(redact(payload))
+	if err != nil {
+		return redactedValue
+	}
+	return string(redacted)
+}
+
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if redactedFields[strings.ToLower(key)] {
+				v[key] = redactedValue
+			} else {
+				v[key] = redact(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redact(item)
+		}
+	}
+	return value
+}
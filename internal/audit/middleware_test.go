@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/apikey"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		headers map[string]string
+		handler http.HandlerFunc
+		assert  func(t *testing.T, entry model.AuditEntry, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "success",
+			body:    `{"assetId":"BTC_TEST","amount":"0.5","destinationAddress":"tb1qdestination","note":"rent"}`,
+			headers: map[string]string{ActorHeader: "alice", logging.RequestIDHeader: "req-1"},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				var req map[string]string
+				err := json.NewDecoder(r.Body).Decode(&req)
+				assert.NoError(t, err)
+				assert.Equal(t, "tb1qdestination", req["destinationAddress"])
+
+				AddFireblocksID(r.Context(), "86")
+				AddFireblocksID(r.Context(), "tx-1")
+				w.WriteHeader(http.StatusCreated)
+			},
+			assert: func(t *testing.T, entry model.AuditEntry, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, "alice", entry.Actor)
+				assert.Equal(t, "transfer.initiate", entry.Action)
+				assert.Equal(t, "wallet-1", entry.Target)
+				assert.Equal(t, "req-1", entry.RequestID)
+				assert.Equal(t, "192.0.2.1", entry.SourceIP)
+				assert.JSONEq(t, `{"assetId":"BTC_TEST","amount":"0.5","destinationAddress":"[REDACTED]","note":"[REDACTED]"}`, entry.Payload)
+				assert.Equal(t, model.AuditOutcomeSuccess, entry.Outcome)
+				assert.Equal(t, http.StatusCreated, entry.StatusCode)
+				assert.Equal(t, "86,tx-1", entry.FireblocksIDs)
+				assert.Equal(t, "req-1", recorder.Header().Get(logging.RequestIDHeader))
+			},
+		},
+		{
+			name: "failure_with_generated_request_id",
+			body: `not json`,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				SetTarget(r.Context(), "other")
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+			},
+			assert: func(t *testing.T, entry model.AuditEntry, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, anonymousActor, entry.Actor)
+				assert.Equal(t, "other", entry.Target)
+				assert.NotEmpty(t, entry.RequestID)
+				assert.Equal(t, entry.RequestID, recorder.Header().Get(logging.RequestIDHeader))
+				assert.Equal(t, redactedValue, entry.Payload)
+				assert.Equal(t, model.AuditOutcomeFailure, entry.Outcome)
+				assert.Equal(t, http.StatusBadRequest, entry.StatusCode)
+				assert.Empty(t, entry.FireblocksIDs)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &MockRepository{}
+			log := NewLog(repo)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /wallets/{walletId}/transactions", log.Wrap("transfer.initiate", tt.handler))
+
+			req := httptest.NewRequest(http.MethodPost, "/wallets/wallet-1/transactions", strings.NewReader(tt.body))
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			recorder := httptest.NewRecorder()
+
+			logging.Middleware(mux).ServeHTTP(recorder, req)
+
+			assert.Len(t, repo.Entries, 1)
+			tt.assert(t, repo.Entries[0], recorder)
+		})
+	}
+}
+
+func TestWrapRespondsWhenRecordingFails(t *testing.T) {
+	log := NewLog(&MockRepository{AppendError: errors.New("database error")})
+	handler := log.Wrap("wallet.create", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, "/wallets", io.NopCloser(strings.NewReader(`{}`))))
+
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+}
+
+func TestActor(t *testing.T) {
+	tests := []struct {
+		name      string
+		apiKey    *model.APIKey
+		declared  string
+		wantActor string
+	}{
+		{name: "api_key", apiKey: &model.APIKey{Name: "back-office"}, wantActor: "back-office"},
+		{name: "api_key_and_declared", apiKey: &model.APIKey{Name: "back-office"}, declared: "alice", wantActor: "back-office/alice"},
+		{name: "declared", declared: "alice", wantActor: "alice"},
+		{name: "anonymous", wantActor: anonymousActor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/wallets", nil)
+			if tt.apiKey != nil {
+				req = req.WithContext(apikey.NewContext(req.Context(), tt.apiKey))
+			}
+			if tt.declared != "" {
+				req.Header.Set(ActorHeader, tt.declared)
+			}
+			assert.Equal(t, tt.wantActor, actor(req))
+		})
+	}
+}
+
+func TestRedactPayload(t *testing.T) {
+	assert.Equal(t, "", redactPayload(nil))
+	assert.JSONEq(t,
+		`{"items":[{"address":"[REDACTED]","amount":0.1}],"CustomerRefId":"[REDACTED]","name":"Alice"}`,
+		redactPayload([]byte(`{"items":[{"address":"tb1q","amount":0.1}],"CustomerRefId":"c-7","name":"Alice"}`)),
+	)
+}
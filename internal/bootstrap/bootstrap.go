@@ -0,0 +1,77 @@
+// Package bootstrap builds the clients shared by the commands from the configuration: the service itself, its
+// migrations and the operator CLI all connect to the same database and Fireblocks workspace the same way.
+package bootstrap
+
+import (
+	"context"
+	"crypto/rsa"
+	"database/sql"
+	"firego-wallet-service/internal/config"
+	"firego-wallet-service/internal/database"
+	"firego-wallet-service/internal/fireblocks"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"os"
+)
+
+// OpenDatabase connects to the database, waiting for it to be reachable
+func OpenDatabase(cfg *config.Config) (*gorm.DB, *sql.DB, error) {
+	// the configuration was validated, the level parses
+	logLevel, _ := database.ParseLogLevel(cfg.Log.DatabaseLevel)
+	db, err := database.Connect(context.Background(), database.Config{
+		URL:              cfg.Database.URL.Value(),
+		Host:             cfg.Database.Host,
+		Port:             cfg.Database.Port,
+		Name:             cfg.Database.Name,
+		User:             cfg.Database.User,
+		Password:         cfg.Database.Password.Value(),
+		SSLMode:          cfg.Database.SSLMode,
+		SSLRootCert:      cfg.Database.SSLRootCert,
+		StatementTimeout: cfg.Database.StatementTimeout,
+		ConnectTimeout:   cfg.Database.ConnectTimeout,
+		MaxOpenConns:     cfg.Database.MaxOpenConns,
+		MaxIdleConns:     cfg.Database.MaxIdleConns,
+		ConnMaxLifetime:  cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime:  cfg.Database.ConnMaxIdleTime,
+		LogLevel:         logLevel,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get database handle: %w", err)
+	}
+	return db, sqlDB, nil
+}
+
+// LoadPrivateKey reads the PEM encoded RSA key the requests to Fireblocks are signed with
+func LoadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	keyBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading private key %s: %w", path, err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing RSA private key: %w", err)
+	}
+	return key, nil
+}
+
+// NewFireblocksClient creates the Fireblocks client with the configured timeout, retries and rate limits
+func NewFireblocksClient(cfg *config.Config, privateKey *rsa.PrivateKey) *fireblocks.Client {
+	return fireblocks.NewClient(cfg.Fireblocks.BaseURL, cfg.Fireblocks.APIKey.Value(), privateKey,
+		fireblocks.WithTimeout(cfg.Fireblocks.Timeout),
+		fireblocks.WithRetryPolicy(fireblocks.RetryPolicy{
+			MaxAttempts:    cfg.Fireblocks.Retry.MaxAttempts,
+			InitialBackoff: cfg.Fireblocks.Retry.InitialBackoff,
+			MaxBackoff:     cfg.Fireblocks.Retry.MaxBackoff,
+		}),
+		fireblocks.WithRateLimits(fireblocks.RateLimits{
+			Read:    fireblocks.RateLimit{Rate: cfg.Fireblocks.RateLimits.Read.Rate, Burst: cfg.Fireblocks.RateLimits.Read.Burst},
+			Write:   fireblocks.RateLimit{Rate: cfg.Fireblocks.RateLimits.Write.Rate, Burst: cfg.Fireblocks.RateLimits.Write.Burst},
+			MaxWait: cfg.Fireblocks.RateLimits.MaxWait,
+		}),
+	)
+}
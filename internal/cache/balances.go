@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/metrics"
+	"golang.org/x/sync/singleflight"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const balanceKeyPrefix = "firego:balance:"
+
+const (
+	resultHit    = "hit"
+	resultMiss   = "miss"
+	resultBypass = "bypass"
+)
+
+type BalanceClient interface {
+	GetVaultAccountAssetBalance(ctx context.Context, vaultAccountID, assetID string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error)
+}
+
+type bypassKey struct{}
+
+// Bypass returns a context whose balance reads go to Fireblocks, the fresh balance is then cached
+func Bypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+func bypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassKey{}).(bool)
+	return bypass
+}
+
+// Balances is a read-through cache of the Fireblocks vault asset balances. Concurrent misses for the same vault
+// and asset share a single Fireblocks call. Failed calls are not cached. A balance being read from Fireblocks while
+// it is invalidated may still be cached as it was read, for at most the TTL.
+type Balances struct {
+	client BalanceClient
+	store  Store
+	ttl    time.Duration
+	group  singleflight.Group
+}
+
+// NewBalances caches the balances read through client in store for ttl. A zero ttl disables caching, concurrent
+// reads are still coalesced.
+func NewBalances(client BalanceClient, store Store, ttl time.Duration) *Balances {
+	return &Balances{
+		client: client,
+		store:  store,
+		ttl:    ttl,
+	}
+}
+
+type balanceResult struct {
+	balance    *fireblocks.GetVaultAccountAssetBalanceResponse
+	statusCode int
+}
+
+// GetVaultAccountAssetBalance returns the cached balance, or reads it from Fireblocks and caches it. The store
+// failing is logged and treated as a miss.
+func (b *Balances) GetVaultAccountAssetBalance(ctx context.Context, vaultAccountID, assetID string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error) {
+	key := balanceKey(vaultAccountID, assetID)
+
+	if bypassed(ctx) {
+		metrics.ObserveBalanceCache(resultBypass)
+		return b.fetch(ctx, key, vaultAccountID, assetID)
+	}
+
+	if b.ttl > 0 {
+		if balance, ok := b.lookup(ctx, key); ok {
+			metrics.ObserveBalanceCache(resultHit)
+			return balance, http.StatusOK, nil
+		}
+	}
+	metrics.ObserveBalanceCache(resultMiss)
+
+	// the call is shared, so it must not be cancelled along with the caller who happened to start it
+	result, err, _ := b.group.Do(key, func() (any, error) {
+		balance, statusCode, err := b.fetch(context.WithoutCancel(ctx), key, vaultAccountID, assetID)
+		return balanceResult{balance: balance, statusCode: statusCode}, err
+	})
+	shared := result.(balanceResult)
+	return shared.balance, shared.statusCode, err
+}
+
+// Invalidate drops the cached balance, e.g. once a transaction moved funds of the vault
+func (b *Balances) Invalidate(ctx context.Context, vaultAccountID, assetID string) {
+	b.invalidate(ctx, balanceKey(vaultAccountID, assetID))
+}
+
+// ProcessTransaction drops the cached balances a transaction changes: the asset, and the fee currency, of the
+// source and destination vault accounts. It is registered as a webhook processor and never fails.
+func (b *Balances) ProcessTransaction(ctx context.Context, tx fireblocks.TransactionResponse) error {
+	var keys []string
+	if isVault(tx.Source) {
+		keys = append(keys, balanceKey(tx.Source.ID, tx.AssetID))
+		if tx.FeeCurrency != "" && tx.FeeCurrency != tx.AssetID {
+			keys = append(keys, balanceKey(tx.Source.ID, tx.FeeCurrency))
+		}
+	}
+	if isVault(tx.Destination) {
+		keys = append(keys, balanceKey(tx.Destination.ID, tx.AssetID))
+	}
+	b.invalidate(ctx, keys...)
+	return nil
+}
+
+func (b *Balances) lookup(ctx context.Context, key string) (*fireblocks.GetVaultAccountAssetBalanceResponse, bool) {
+	value, ok, err := b.store.Get(ctx, key)
+	if err != nil {
+		slog.Warn("Failed to read cached balance", "key", key, "error", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	var balance fireblocks.GetVaultAccountAssetBalanceResponse
+	if err = json.Unmarshal(value, &balance); err != nil {
+		slog.Warn("Ignoring invalid cached balance", "key", key, "error", err)
+		return nil, false
+	}
+	return &balance, true
+}
+
+func (b *Balances) fetch(ctx context.Context, key, vaultAccountID, assetID string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error) {
+	balance, statusCode, err := b.client.GetVaultAccountAssetBalance(ctx, vaultAccountID, assetID)
+	if err != nil || b.ttl <= 0 {
+		return balance, statusCode, err
+	}
+
+	value, err := json.Marshal(balance)
+	if err == nil {
+		err = b.store.Set(ctx, key, value, b.ttl)
+	}
+	if err != nil {
+		slog.Warn("Failed to cache balance", "key", key, "error", err)
+	}
+	return balance, statusCode, nil
+}
+
+func (b *Balances) invalidate(ctx context.Context, keys ...string) {
+	if b.ttl <= 0 || len(keys) == 0 {
+		return
+	}
+	if err := b.store.Delete(ctx, keys...); err != nil {
+		slog.Warn("Failed to invalidate cached balances", "keys", keys, "error", err)
+	}
+}
+
+func isVault(peer fireblocks.TransferPeerPath) bool {
+	return peer.Type == "VAULT_ACCOUNT" && peer.ID != ""
+}
+
+func balanceKey(vaultAccountID, assetID string) string {
+	return balanceKeyPrefix + vaultAccountID + ":" + assetID
+}
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/fireblocks"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type MockBalanceClient struct {
+	Response   *fireblocks.GetVaultAccountAssetBalanceResponse
+	StatusCode int
+	Error      error
+	// Release, if set, blocks the calls until closed
+	Release chan struct{}
+
+	Calls atomic.Int32
+}
+
+func (m *MockBalanceClient) GetVaultAccountAssetBalance(ctx context.Context, _, _ string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error) {
+	m.Calls.Add(1)
+	if m.Release != nil {
+		<-m.Release
+	}
+	if ctx.Err() != nil {
+		return nil, 0, ctx.Err()
+	}
+	return m.Response, m.StatusCode, m.Error
+}
+
+func TestBalancesReadThrough(t *testing.T) {
+	ctx := context.Background()
+	client := &MockBalanceClient{
+		Response:   &fireblocks.GetVaultAccountAssetBalanceResponse{ID: "BTC_TEST", Available: "0.5"},
+		StatusCode: http.StatusOK,
+	}
+	balances := NewBalances(client, NewMemoryStore(), time.Minute)
+
+	for range 2 {
+		balance, statusCode, err := balances.GetVaultAccountAssetBalance(ctx, "1", "BTC_TEST")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, "0.5", balance.Available)
+	}
+	assert.Equal(t, int32(1), client.Calls.Load())
+
+	_, _, err := balances.GetVaultAccountAssetBalance(Bypass(ctx), "1", "BTC_TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), client.Calls.Load())
+
+	_, _, err = balances.GetVaultAccountAssetBalance(ctx, "1", "ETH_TEST5")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), client.Calls.Load())
+}
+
+func TestBalancesErrorsNotCached(t *testing.T) {
+	ctx := context.Background()
+	client := &MockBalanceClient{StatusCode: http.StatusNotFound, Error: errors.New("not found")}
+	balances := NewBalances(client, NewMemoryStore(), time.Minute)
+
+	for range 2 {
+		_, statusCode, err := balances.GetVaultAccountAssetBalance(ctx, "1", "BTC_TEST")
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, statusCode)
+	}
+	assert.Equal(t, int32(2), client.Calls.Load())
+}
+
+func TestBalancesCoalesceMisses(t *testing.T) {
+	client := &MockBalanceClient{
+		Response:   &fireblocks.GetVaultAccountAssetBalanceResponse{ID: "BTC_TEST", Available: "0.5"},
+		StatusCode: http.StatusOK,
+		Release:    make(chan struct{}),
+	}
+	balances := NewBalances(client, NewMemoryStore(), 0)
+
+	get := func(ctx context.Context, wg *sync.WaitGroup) {
+		defer wg.Done()
+		balance, _, err := balances.GetVaultAccountAssetBalance(ctx, "1", "BTC_TEST")
+		assert.NoError(t, err)
+		assert.Equal(t, "0.5", balance.Available)
+	}
+
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	wg.Add(1)
+	go get(ctx, &wg)
+	assert.Eventually(t, func() bool { return client.Calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	for range 4 {
+		wg.Add(1)
+		go get(context.Background(), &wg)
+	}
+	time.Sleep(50 * time.Millisecond)
+	// the caller who started the call giving up does not fail the others
+	cancel()
+	close(client.Release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), client.Calls.Load())
+}
+
+func TestBalancesProcessTransaction(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	balances := NewBalances(&MockBalanceClient{}, store, time.Minute)
+	for _, key := range []string{
+		balanceKey("1", "USDC_TEST"),
+		balanceKey("1", "ETH_TEST5"),
+		balanceKey("2", "USDC_TEST"),
+		balanceKey("2", "ETH_TEST5"),
+		balanceKey("3", "USDC_TEST"),
+	} {
+		assert.NoError(t, store.Set(ctx, key, []byte("{}"), time.Minute))
+	}
+
+	err := balances.ProcessTransaction(ctx, fireblocks.TransactionResponse{
+		AssetID:     "USDC_TEST",
+		FeeCurrency: "ETH_TEST5",
+		Source:      fireblocks.TransferPeerPath{Type: "VAULT_ACCOUNT", ID: "1"},
+		Destination: fireblocks.TransferPeerPath{Type: "VAULT_ACCOUNT", ID: "2"},
+	})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{balanceKey("2", "ETH_TEST5"), balanceKey("3", "USDC_TEST")}, keys(store))
+}
+
+func keys(store *MemoryStore) []string {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var keys []string
+	for key := range store.entries {
+		keys = append(keys, key)
+	}
+	return keys
+}
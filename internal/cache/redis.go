@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// defaultRedisTimeout bounds a Redis command when the context has no earlier deadline
+const defaultRedisTimeout = time.Second
+
+// incrementScript adds one to a counter and sets its expiry in the same step, so that a counter is never left without
+// one, e.g. by a failure between the two commands. Counters found without an expiry get one.
+var incrementScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 or redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count`)
+
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+}
+
+// RedisStore is a Store shared by the instances of the service, kept in Redis or a compatible server such as Valkey
+// or KeyDB
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(config RedisConfig) *RedisStore {
+	return &RedisStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:         config.Addr,
+			Password:     config.Password,
+			DB:           config.DB,
+			DialTimeout:  defaultRedisTimeout,
+			ReadTimeout:  defaultRedisTimeout,
+			WriteTimeout: defaultRedisTimeout,
+			// the deadline of the context applies when it is earlier
+			ContextTimeoutEnabled: true,
+			// compatible servers do not all know CLIENT SETINFO
+			DisableIdentity: true,
+		}),
+	}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
+
+// Increment adds one to the counter of key and returns its new value. The counter expires ttl after it was created.
+func (s *RedisStore) Increment(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return incrementScript.Run(ctx, s.client, []string{key}, max(ttl.Milliseconds(), 1)).Int64()
+}
+
+// Ping checks that the server answers
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// Close closes the connections
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	store := NewRedisStore(RedisConfig{Addr: server.Addr(), Password: "secret"})
+	defer store.Close()
+
+	assert.NoError(t, store.Ping(ctx))
+
+	_, ok, err := store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, store.Set(ctx, "a", []byte(`{"available":"1"}`), 10*time.Second))
+	value, ok, err := store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte(`{"available":"1"}`), value)
+	assert.Equal(t, 10*time.Second, server.TTL("a"))
+
+	assert.NoError(t, store.Delete(ctx, "a", "b"))
+	_, ok, _ = store.Get(ctx, "a")
+	assert.False(t, ok)
+}
+
+func TestRedisStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+
+	store := NewRedisStore(RedisConfig{Addr: server.Addr(), Password: "wrong"})
+	_, _, err := store.Get(ctx, "a")
+	assert.ErrorContains(t, err, "WRONGPASS")
+
+	addr := server.Addr()
+	server.Close()
+	store = NewRedisStore(RedisConfig{Addr: addr})
+	assert.Error(t, store.Set(ctx, "a", []byte("1"), time.Second))
+}
+
+func TestRedisStoreIncrement(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	store := NewRedisStore(RedisConfig{Addr: server.Addr()})
+	defer store.Close()
+
+	for want := int64(1); want <= 3; want++ {
+		count, err := store.Increment(ctx, "counter", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, want, count)
+	}
+	assert.Equal(t, time.Minute, server.TTL("counter"))
+
+	// the expiry is set when the counter is created, not extended by the next increments
+	server.FastForward(30 * time.Second)
+	_, err := store.Increment(ctx, "counter", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, server.TTL("counter"))
+
+	// a counter left without an expiry gets one
+	assert.NoError(t, server.Set("stuck", "5"))
+	count, err := store.Increment(ctx, "stuck", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), count)
+	assert.Equal(t, time.Minute, server.TTL("stuck"))
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	assert.NoError(t, store.Set(ctx, "a", []byte("1"), time.Second))
+	assert.NoError(t, store.Set(ctx, "b", []byte("2"), time.Minute))
+
+	value, ok, err := store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	now = now.Add(time.Second)
+	_, ok, _ = store.Get(ctx, "a")
+	assert.False(t, ok)
+
+	assert.NoError(t, store.Delete(ctx, "b", "missing"))
+	_, ok, _ = store.Get(ctx, "b")
+	assert.False(t, ok)
+}
+
+func TestMemoryStoreSweepsExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	assert.NoError(t, store.Set(ctx, "a", []byte("1"), time.Second))
+	now = now.Add(sweepInterval)
+	assert.NoError(t, store.Set(ctx, "b", []byte("2"), time.Second))
+
+	assert.Len(t, store.entries, 1)
+	assert.Contains(t, store.entries, "b")
+}
@@ -0,0 +1,548 @@
+package config
+
+import (
+	"errors"
+	"firego-wallet-service/internal/cache"
+	"firego-wallet-service/internal/database"
+	"firego-wallet-service/internal/idempotency"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/ratelimit"
+	"firego-wallet-service/internal/sweep"
+	"firego-wallet-service/internal/tracing"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const redactedValue = "[REDACTED]"
+
+// Secret is a configuration value that is masked whenever the configuration is printed or logged
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+// Value returns the secret itself
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// Config is the whole service configuration. It is loaded from the defaults, then the optional YAML file,
+// then the environment, see Load.
+type Config struct {
+	Server         ServerConfig         `yaml:"server"`
+	Log            LogConfig            `yaml:"log"`
+	Database       DatabaseConfig       `yaml:"database"`
+	Fireblocks     FireblocksConfig     `yaml:"fireblocks"`
+	Wallets        WalletsConfig        `yaml:"wallets"`
+	Deposits       DepositsConfig       `yaml:"deposits"`
+	Transfers      TransfersConfig      `yaml:"transfers"`
+	Payouts        PayoutsConfig        `yaml:"payouts"`
+	Schedules      SchedulesConfig      `yaml:"schedules"`
+	Sweeps         SweepsConfig         `yaml:"sweeps"`
+	Reconciliation ReconciliationConfig `yaml:"reconciliation"`
+	Tracing        TracingConfig        `yaml:"tracing"`
+	Cache          CacheConfig          `yaml:"cache"`
+	Limits         LimitsConfig         `yaml:"limits"`
+	RateLimiting   RateLimitingConfig   `yaml:"rateLimiting"`
+	Idempotency    IdempotencyConfig    `yaml:"idempotency"`
+	Features       FeaturesConfig       `yaml:"features"`
+}
+
+type ServerConfig struct {
+	Port            string        `yaml:"port"`
+	ReadTimeout     time.Duration `yaml:"readTimeout"`
+	WriteTimeout    time.Duration `yaml:"writeTimeout"`
+	IdleTimeout     time.Duration `yaml:"idleTimeout"`
+	MaxHeaderBytes  int           `yaml:"maxHeaderBytes"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+}
+
+type LogConfig struct {
+	// Level is one of debug, info, warn or error
+	Level string `yaml:"level"`
+	// DatabaseLevel is one of silent, error, warn or info
+	DatabaseLevel string `yaml:"databaseLevel"`
+}
+
+type DatabaseConfig struct {
+	// URL is a full connection string, a postgres:// URL or key=value pairs, which replaces the discrete
+	// connection settings. It is a secret as it usually holds the password.
+	URL              Secret        `yaml:"url"`
+	Host             string        `yaml:"host"`
+	Port             string        `yaml:"port"`
+	Name             string        `yaml:"name"`
+	User             string        `yaml:"user"`
+	Password         Secret        `yaml:"password"`
+	SSLMode          string        `yaml:"sslMode"`
+	SSLRootCert      string        `yaml:"sslRootCert"`
+	StatementTimeout time.Duration `yaml:"statementTimeout"`
+	ConnectTimeout   time.Duration `yaml:"connectTimeout"`
+	MaxOpenConns     int           `yaml:"maxOpenConns"`
+	MaxIdleConns     int           `yaml:"maxIdleConns"`
+	ConnMaxLifetime  time.Duration `yaml:"connMaxLifetime"`
+	ConnMaxIdleTime  time.Duration `yaml:"connMaxIdleTime"`
+}
+
+type FireblocksConfig struct {
+	BaseURL              string        `yaml:"baseURL"`
+	APIKey               Secret        `yaml:"apiKey"`
+	SecretKeyPath        string        `yaml:"secretKeyPath"`
+	WebhookPublicKeyPath string        `yaml:"webhookPublicKeyPath"`
+	Timeout              time.Duration `yaml:"timeout"`
+	Retry                RetryConfig   `yaml:"retry"`
+	RateLimits           RateLimits    `yaml:"rateLimits"`
+}
+
+// RetryConfig is the retry policy of idempotent Fireblocks requests
+type RetryConfig struct {
+	// MaxAttempts includes the first attempt, 1 disables retries
+	MaxAttempts    int           `yaml:"maxAttempts"`
+	InitialBackoff time.Duration `yaml:"initialBackoff"`
+	MaxBackoff     time.Duration `yaml:"maxBackoff"`
+}
+
+// RateLimits is the client-side limit of the Fireblocks requests, GET requests are reads and the other ones writes
+type RateLimits struct {
+	Read  RateLimit `yaml:"read"`
+	Write RateLimit `yaml:"write"`
+	// MaxWait is how long a request may queue before failing
+	MaxWait time.Duration `yaml:"maxWait"`
+}
+
+// RateLimit is a token bucket, a rate of 0 disables it
+type RateLimit struct {
+	// Rate is in requests per second
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// WalletsConfig bounds the batches of POST /wallets:batch
+type WalletsConfig struct {
+	BatchMaxSize int `yaml:"batchMaxSize"`
+	// BatchConcurrency is how many wallets of a batch are created at the same time
+	BatchConcurrency int `yaml:"batchConcurrency"`
+}
+
+type DepositsConfig struct {
+	PollInterval         time.Duration  `yaml:"pollInterval"`
+	PollLookback         time.Duration  `yaml:"pollLookback"`
+	DefaultConfirmations int            `yaml:"defaultConfirmations"`
+	Confirmations        map[string]int `yaml:"confirmations"`
+}
+
+type TransfersConfig struct {
+	PollInterval time.Duration `yaml:"pollInterval"`
+}
+
+type PayoutsConfig struct {
+	// MaxLines is the most lines a payout may have
+	MaxLines int `yaml:"maxLines"`
+	// RetryInterval is how often the payouts with lines left to submit are resumed
+	RetryInterval time.Duration `yaml:"retryInterval"`
+}
+
+type SchedulesConfig struct {
+	// Interval is how often the due scheduled transfers are run
+	Interval time.Duration `yaml:"interval"`
+}
+
+type SweepsConfig struct {
+	// Interval is how often the wallet balances are checked against the rules
+	Interval time.Duration `yaml:"interval"`
+	// Rules are the sweep rules by asset ID, the wallets are not swept when there is none
+	Rules map[string]sweep.Rule `yaml:"rules"`
+}
+
+type ReconciliationConfig struct {
+	Interval       time.Duration `yaml:"interval"`
+	TransferWindow time.Duration `yaml:"transferWindow"`
+	IgnoredVaults  []string      `yaml:"ignoredVaults"`
+}
+
+type TracingConfig struct {
+	// Exporter is one of none, stdout, file or otlp
+	Exporter string `yaml:"exporter"`
+	File     string `yaml:"file"`
+}
+
+type CacheConfig struct {
+	// Backend is one of none, memory or redis
+	Backend    string        `yaml:"backend"`
+	BalanceTTL time.Duration `yaml:"balanceTTL"`
+	Redis      RedisConfig   `yaml:"redis"`
+}
+
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Password Secret `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+type LimitsConfig struct {
+	MaxRequestBodyBytes int64 `yaml:"maxRequestBodyBytes"`
+}
+
+// RateLimitingConfig limits the requests to the public endpoints per client IP, and per API key once verified
+type RateLimitingConfig struct {
+	// Store is one of memory, postgres or redis, which uses the cache.redis settings
+	Store string `yaml:"store"`
+	// TrustForwardedFor takes the client IP from X-Forwarded-For, only behind a reverse proxy setting it
+	TrustForwardedFor bool `yaml:"trustForwardedFor"`
+	// PerIP is shared by all the requests from an IP, including those of the keys used behind the same NAT or proxy
+	PerIP RequestLimits `yaml:"perIP"`
+	// PerKey is counted per verified API key across the IPs it is used from, with features.apiKeys
+	PerKey RequestLimits `yaml:"perKey"`
+}
+
+// RequestLimits are the limits of each request class
+type RequestLimits struct {
+	Read     RequestLimit `yaml:"read"`
+	Write    RequestLimit `yaml:"write"`
+	Transfer RequestLimit `yaml:"transfer"`
+}
+
+// RequestLimit allows Requests per Window, 0 requests disables it
+type RequestLimit struct {
+	Requests int           `yaml:"requests"`
+	Window   time.Duration `yaml:"window"`
+}
+
+// IdempotencyConfig keeps the responses to the requests sent with an Idempotency-Key, to replay them on retries
+type IdempotencyConfig struct {
+	// Store is one of memory or postgres
+	Store string `yaml:"store"`
+	// KeyTTL is how long a response is replayed for
+	KeyTTL time.Duration `yaml:"keyTTL"`
+}
+
+// FeaturesConfig turns optional parts of the service on or off
+type FeaturesConfig struct {
+	Webhooks        bool `yaml:"webhooks"`
+	DepositPolling  bool `yaml:"depositPolling"`
+	TransferPolling bool `yaml:"transferPolling"`
+	Reconciliation  bool `yaml:"reconciliation"`
+	Metrics         bool `yaml:"metrics"`
+	// RequestValidation checks the requests against the OpenAPI specification before they reach the handlers
+	RequestValidation bool `yaml:"requestValidation"`
+	// APIKeys requires a valid X-API-Key on the wallet and admin endpoints, see package apikey
+	APIKeys bool `yaml:"apiKeys"`
+}
+
+// Default returns the configuration used for anything neither the file nor the environment sets
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            "8080",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    75 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			MaxHeaderBytes:  64 << 10,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Log: LogConfig{
+			Level:         "info",
+			DatabaseLevel: "warn",
+		},
+		Database: DatabaseConfig{
+			Host:             "localhost",
+			Port:             "5432",
+			Name:             "firego_wallet",
+			SSLMode:          "disable",
+			StatementTimeout: 30 * time.Second,
+			ConnectTimeout:   30 * time.Second,
+			MaxOpenConns:     20,
+			MaxIdleConns:     10,
+			ConnMaxLifetime:  30 * time.Minute,
+			ConnMaxIdleTime:  5 * time.Minute,
+		},
+		Fireblocks: FireblocksConfig{
+			Timeout: 30 * time.Second,
+			Retry: RetryConfig{
+				MaxAttempts:    3,
+				InitialBackoff: 200 * time.Millisecond,
+				MaxBackoff:     2 * time.Second,
+			},
+			RateLimits: RateLimits{
+				Read:    RateLimit{Rate: 20, Burst: 40},
+				Write:   RateLimit{Rate: 5, Burst: 10},
+				MaxWait: 5 * time.Second,
+			},
+		},
+		Wallets: WalletsConfig{
+			BatchMaxSize:     50,
+			BatchConcurrency: 4,
+		},
+		Deposits: DepositsConfig{
+			PollInterval:         time.Minute,
+			PollLookback:         24 * time.Hour,
+			DefaultConfirmations: 1,
+			Confirmations:        map[string]int{},
+		},
+		Transfers: TransfersConfig{
+			PollInterval: time.Minute,
+		},
+		Payouts: PayoutsConfig{
+			MaxLines:      1000,
+			RetryInterval: time.Minute,
+		},
+		Schedules: SchedulesConfig{
+			Interval: 30 * time.Second,
+		},
+		Sweeps: SweepsConfig{
+			Interval: 15 * time.Minute,
+			Rules:    map[string]sweep.Rule{},
+		},
+		Reconciliation: ReconciliationConfig{
+			Interval:       time.Hour,
+			TransferWindow: 72 * time.Hour,
+		},
+		Tracing: TracingConfig{
+			Exporter: tracing.ExporterNone,
+		},
+		Cache: CacheConfig{
+			Backend:    cache.BackendMemory,
+			BalanceTTL: 10 * time.Second,
+		},
+		Limits: LimitsConfig{
+			MaxRequestBodyBytes: 1 << 20,
+		},
+		RateLimiting: RateLimitingConfig{
+			Store: ratelimit.StoreMemory,
+			PerIP: RequestLimits{
+				Read:     RequestLimit{Requests: 1200, Window: time.Minute},
+				Write:    RequestLimit{Requests: 120, Window: time.Minute},
+				Transfer: RequestLimit{Requests: 60, Window: time.Minute},
+			},
+			PerKey: RequestLimits{
+				Read:     RequestLimit{Requests: 600, Window: time.Minute},
+				Write:    RequestLimit{Requests: 60, Window: time.Minute},
+				Transfer: RequestLimit{Requests: 30, Window: time.Minute},
+			},
+		},
+		Idempotency: IdempotencyConfig{
+			Store:  idempotency.StorePostgres,
+			KeyTTL: 24 * time.Hour,
+		},
+		Features: FeaturesConfig{
+			Webhooks:          true,
+			DepositPolling:    true,
+			TransferPolling:   true,
+			Reconciliation:    true,
+			Metrics:           true,
+			RequestValidation: true,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the YAML file at path if not empty, and the environment,
+// in that order, and validates it. All problems are reported at once in the returned error. The configuration
+// is returned along with validation errors, so that it can still be printed.
+func Load(path string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	config := Default()
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open config file: %w", err)
+		}
+		defer file.Close()
+
+		decoder := yaml.NewDecoder(file)
+		decoder.KnownFields(true)
+		if err = decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	envErr := config.applyEnv(lookupEnv)
+	return config, errors.Join(envErr, config.Validate())
+}
+
+// Validate reports every invalid setting
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.check(validPort(c.Server.Port), "server.port must be a port number")
+	v.positive(c.Server.ReadTimeout, "server.readTimeout")
+	v.positive(c.Server.WriteTimeout, "server.writeTimeout")
+	v.positive(c.Server.IdleTimeout, "server.idleTimeout")
+	v.check(c.Server.MaxHeaderBytes > 0, "server.maxHeaderBytes must be positive")
+	v.positive(c.Server.ShutdownTimeout, "server.shutdownTimeout")
+
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		v.add("log.level: %v", err)
+	}
+	if _, err := database.ParseLogLevel(c.Log.DatabaseLevel); err != nil {
+		v.add("log.databaseLevel: %v", err)
+	}
+
+	if c.Database.URL == "" {
+		v.required(c.Database.Host, "database.host")
+		v.check(validPort(c.Database.Port), "database.port must be a port number")
+		v.required(c.Database.Name, "database.name")
+		v.required(c.Database.User, "database.user")
+		v.required(c.Database.Password.Value(), "database.password")
+		v.check(validSSLModes[c.Database.SSLMode], "database.sslMode must be one of disable, allow, prefer, require, verify-ca or verify-full")
+	}
+	v.check(c.Database.StatementTimeout >= 0, "database.statementTimeout must not be negative")
+	v.positive(c.Database.ConnectTimeout, "database.connectTimeout")
+	v.check(c.Database.MaxOpenConns > 0, "database.maxOpenConns must be positive")
+	v.check(c.Database.MaxIdleConns >= 0 && c.Database.MaxIdleConns <= c.Database.MaxOpenConns, "database.maxIdleConns must be between 0 and database.maxOpenConns")
+	v.check(c.Database.ConnMaxLifetime >= 0, "database.connMaxLifetime must not be negative")
+	v.check(c.Database.ConnMaxIdleTime >= 0, "database.connMaxIdleTime must not be negative")
+
+	if baseURL, err := url.Parse(c.Fireblocks.BaseURL); err != nil || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.Host == "" {
+		v.add("fireblocks.baseURL must be an http or https URL")
+	}
+	v.required(c.Fireblocks.APIKey.Value(), "fireblocks.apiKey")
+	v.required(c.Fireblocks.SecretKeyPath, "fireblocks.secretKeyPath")
+	if c.Features.Webhooks {
+		v.check(c.Fireblocks.WebhookPublicKeyPath != "", "fireblocks.webhookPublicKeyPath is required when features.webhooks is enabled")
+	}
+	v.positive(c.Fireblocks.Timeout, "fireblocks.timeout")
+	v.check(c.Fireblocks.Retry.MaxAttempts >= 1, "fireblocks.retry.maxAttempts must be at least 1")
+	v.positive(c.Fireblocks.Retry.InitialBackoff, "fireblocks.retry.initialBackoff")
+	v.check(c.Fireblocks.Retry.MaxBackoff >= c.Fireblocks.Retry.InitialBackoff, "fireblocks.retry.maxBackoff must not be less than fireblocks.retry.initialBackoff")
+	for name, limit := range map[string]RateLimit{"read": c.Fireblocks.RateLimits.Read, "write": c.Fireblocks.RateLimits.Write} {
+		v.check(limit.Rate >= 0, fmt.Sprintf("fireblocks.rateLimits.%s.rate must not be negative", name))
+		if limit.Rate > 0 {
+			v.check(limit.Burst >= 1, fmt.Sprintf("fireblocks.rateLimits.%s.burst must be at least 1", name))
+		}
+	}
+	v.check(c.Fireblocks.RateLimits.MaxWait >= 0, "fireblocks.rateLimits.maxWait must not be negative")
+
+	v.check(c.Wallets.BatchMaxSize >= 1, "wallets.batchMaxSize must be at least 1")
+	v.check(c.Wallets.BatchConcurrency >= 1, "wallets.batchConcurrency must be at least 1")
+	// every worker queues for the Fireblocks rate limiter, the last one for concurrency / rate seconds
+	if write := c.Fireblocks.RateLimits.Write; write.Rate > 0 && c.Fireblocks.RateLimits.MaxWait > 0 {
+		v.check(float64(c.Wallets.BatchConcurrency) <= write.Rate*c.Fireblocks.RateLimits.MaxWait.Seconds(),
+			"wallets.batchConcurrency must not exceed fireblocks.rateLimits.write.rate times fireblocks.rateLimits.maxWait in seconds")
+	}
+
+	if c.Features.DepositPolling {
+		v.positive(c.Deposits.PollInterval, "deposits.pollInterval")
+		v.positive(c.Deposits.PollLookback, "deposits.pollLookback")
+	}
+	v.check(c.Deposits.DefaultConfirmations >= 0, "deposits.defaultConfirmations must not be negative")
+	for assetID, count := range c.Deposits.Confirmations {
+		v.check(count >= 0, fmt.Sprintf("deposits.confirmations.%s must not be negative", assetID))
+	}
+
+	if c.Features.TransferPolling {
+		v.positive(c.Transfers.PollInterval, "transfers.pollInterval")
+	}
+
+	v.check(c.Payouts.MaxLines >= 1, "payouts.maxLines must be at least 1")
+	v.positive(c.Payouts.RetryInterval, "payouts.retryInterval")
+
+	v.positive(c.Schedules.Interval, "schedules.interval")
+
+	if len(c.Sweeps.Rules) > 0 {
+		v.positive(c.Sweeps.Interval, "sweeps.interval")
+	}
+	for assetID, rule := range c.Sweeps.Rules {
+		if err := rule.Validate(); err != nil {
+			v.add("sweeps.rules.%s: %v", assetID, err)
+		}
+	}
+
+	if c.Features.Reconciliation {
+		v.positive(c.Reconciliation.Interval, "reconciliation.interval")
+		v.positive(c.Reconciliation.TransferWindow, "reconciliation.transferWindow")
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	case tracing.ExporterFile:
+		v.required(c.Tracing.File, "tracing.file")
+	default:
+		v.add("tracing.exporter must be one of none, stdout, file or otlp")
+	}
+
+	switch c.Cache.Backend {
+	case cache.BackendNone, cache.BackendMemory:
+	case cache.BackendRedis:
+		v.required(c.Cache.Redis.Addr, "cache.redis.addr")
+		v.check(c.Cache.Redis.DB >= 0, "cache.redis.db must not be negative")
+	default:
+		v.add("cache.backend must be one of none, memory or redis")
+	}
+	if c.Cache.Backend != cache.BackendNone {
+		v.positive(c.Cache.BalanceTTL, "cache.balanceTTL")
+	}
+
+	v.check(c.Limits.MaxRequestBodyBytes > 0, "limits.maxRequestBodyBytes must be positive")
+
+	switch c.RateLimiting.Store {
+	case ratelimit.StoreMemory, ratelimit.StorePostgres:
+	case ratelimit.StoreRedis:
+		v.check(c.Cache.Redis.Addr != "", "cache.redis.addr is required when rateLimiting.store is redis")
+	default:
+		v.add("rateLimiting.store must be one of memory, postgres or redis")
+	}
+	for client, limits := range map[string]RequestLimits{"perIP": c.RateLimiting.PerIP, "perKey": c.RateLimiting.PerKey} {
+		for name, limit := range map[string]RequestLimit{"read": limits.Read, "write": limits.Write, "transfer": limits.Transfer} {
+			v.check(limit.Requests >= 0, fmt.Sprintf("rateLimiting.%s.%s.requests must not be negative", client, name))
+			if limit.Requests > 0 {
+				v.check(limit.Window >= time.Second, fmt.Sprintf("rateLimiting.%s.%s.window must be at least 1s", client, name))
+			}
+		}
+	}
+
+	switch c.Idempotency.Store {
+	case idempotency.StoreMemory, idempotency.StorePostgres:
+	default:
+		v.add("idempotency.store must be one of memory or postgres")
+	}
+	v.positive(c.Idempotency.KeyTTL, "idempotency.keyTTL")
+
+	return errors.Join(v.errs...)
+}
+
+var validSSLModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+func (v *validator) check(ok bool, msg string) {
+	if !ok {
+		v.errs = append(v.errs, errors.New(msg))
+	}
+}
+
+func (v *validator) required(value, name string) {
+	v.check(value != "", name+" is required")
+}
+
+func (v *validator) positive(d time.Duration, name string) {
+	v.check(d > 0, name+" must be positive")
+}
@@ -0,0 +1,277 @@
+package config
+
+import (
+	"firego-wallet-service/internal/sweep"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func lookupEnv(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func requiredEnv() map[string]string {
+	return map[string]string{
+		"FIREBLOCKS_BASE_URL":                "https://api.fireblocks.io",
+		"FIREBLOCKS_API_KEY":                 "api-key",
+		"FIREBLOCKS_SECRET_KEY_PATH":         "fireblocks_secret.key",
+		"FIREBLOCKS_WEBHOOK_PUBLIC_KEY_PATH": "fireblocks_webhook.pub",
+		"DB_USER":                            "postgres",
+		"DB_PASSWORD":                        "password",
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadEnv(t *testing.T) {
+	env := requiredEnv()
+	env["PORT"] = "9090"
+	env["DB_MAX_OPEN_CONNS"] = "50"
+	env["DEPOSIT_CONFIRMATIONS"] = "BTC_TEST=2, ETH_TEST5=12"
+	env["RECONCILIATION_IGNORED_VAULTS"] = "0, 7,"
+	env["FEATURE_RECONCILIATION"] = "false"
+	env["FEATURE_REQUEST_VALIDATION"] = "false"
+	env["FEATURE_API_KEYS"] = "true"
+	env["WALLET_BATCH_CONCURRENCY"] = "8"
+	env["PAYOUT_RETRY_INTERVAL"] = "30s"
+	env["SCHEDULE_INTERVAL"] = "1m"
+	env["SWEEP_RULES"] = "ETH_TEST5=0.5:0.01:treasury-wallet"
+	env["RECONCILIATION_INTERVAL"] = ""
+
+	config, err := Load("", lookupEnv(env))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "9090", config.Server.Port)
+	assert.Equal(t, 50, config.Database.MaxOpenConns)
+	assert.Equal(t, "password", config.Database.Password.Value())
+	assert.Equal(t, map[string]int{"BTC_TEST": 2, "ETH_TEST5": 12}, config.Deposits.Confirmations)
+	assert.Equal(t, []string{"0", "7"}, config.Reconciliation.IgnoredVaults)
+	assert.False(t, config.Features.Reconciliation)
+	assert.False(t, config.Features.RequestValidation)
+	assert.True(t, config.Features.APIKeys)
+	assert.True(t, config.Features.Metrics)
+	assert.Equal(t, WalletsConfig{BatchMaxSize: 50, BatchConcurrency: 8}, config.Wallets)
+	assert.Equal(t, PayoutsConfig{MaxLines: 1000, RetryInterval: 30 * time.Second}, config.Payouts)
+	assert.Equal(t, time.Minute, config.Schedules.Interval)
+	assert.Equal(t, SweepsConfig{
+		Interval: 15 * time.Minute,
+		Rules:    map[string]sweep.Rule{"ETH_TEST5": {Threshold: "0.5", MinLeftover: "0.01", TreasuryWalletID: "treasury-wallet"}},
+	}, config.Sweeps)
+	assert.Equal(t, time.Hour, config.Reconciliation.Interval)
+	assert.Equal(t, 30*time.Second, config.Fireblocks.Timeout)
+}
+
+func TestLoadFile(t *testing.T) {
+	path := writeFile(t, `
+server:
+  port: "9090"
+  shutdownTimeout: 1m
+database:
+  user: wallet
+  password: from-file
+fireblocks:
+  retry:
+    maxAttempts: 5
+  rateLimits:
+    read:
+      rate: 2.5
+rateLimiting:
+  perKey:
+    transfer:
+      requests: 5
+deposits:
+  confirmations:
+    BTC_TEST: 3
+sweeps:
+  rules:
+    BTC_TEST:
+      threshold: "0.1"
+      treasuryWalletId: treasury-wallet
+`)
+	env := requiredEnv()
+	delete(env, "DB_USER")
+	env["DB_PASSWORD"] = "from-env"
+
+	config, err := Load(path, lookupEnv(env))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "9090", config.Server.Port)
+	assert.Equal(t, time.Minute, config.Server.ShutdownTimeout)
+	assert.Equal(t, "wallet", config.Database.User)
+	assert.Equal(t, "from-env", config.Database.Password.Value())
+	assert.Equal(t, 5, config.Fireblocks.Retry.MaxAttempts)
+	assert.Equal(t, 200*time.Millisecond, config.Fireblocks.Retry.InitialBackoff)
+	assert.Equal(t, RateLimit{Rate: 2.5, Burst: 40}, config.Fireblocks.RateLimits.Read)
+	assert.Equal(t, RequestLimit{Requests: 5, Window: time.Minute}, config.RateLimiting.PerKey.Transfer)
+	assert.Equal(t, RequestLimit{Requests: 60, Window: time.Minute}, config.RateLimiting.PerIP.Transfer)
+	assert.Equal(t, map[string]int{"BTC_TEST": 3}, config.Deposits.Confirmations)
+	assert.Equal(t, map[string]sweep.Rule{"BTC_TEST": {Threshold: "0.1", TreasuryWalletID: "treasury-wallet"}}, config.Sweeps.Rules)
+}
+
+func TestLoadFileUnknownField(t *testing.T) {
+	path := writeFile(t, "server:\n  prot: \"9090\"\n")
+
+	config, err := Load(path, lookupEnv(requiredEnv()))
+
+	assert.Nil(t, config)
+	assert.ErrorContains(t, err, "field prot not found")
+}
+
+func TestLoadReportsAllErrors(t *testing.T) {
+	env := requiredEnv()
+	delete(env, "DB_PASSWORD")
+	delete(env, "FIREBLOCKS_WEBHOOK_PUBLIC_KEY_PATH")
+	env["FIREBLOCKS_BASE_URL"] = "api.fireblocks.io"
+	env["HTTP_READ_TIMEOUT"] = "soon"
+	env["DB_MAX_IDLE_CONNS"] = "100"
+	env["LOG_LEVEL"] = "verbose"
+	env["TRACING_EXPORTER"] = "file"
+	env["FIREBLOCKS_RATE_LIMIT_WRITE_RATE"] = "fast"
+	env["FIREBLOCKS_RATE_LIMIT_READ_BURST"] = "0"
+	env["RATE_LIMIT_STORE"] = "disk"
+	env["RATE_LIMIT_KEY_WRITE_WINDOW"] = "0"
+	env["IDEMPOTENCY_STORE"] = "redis"
+	env["IDEMPOTENCY_KEY_TTL"] = "0s"
+	env["WALLET_BATCH_MAX_SIZE"] = "0"
+	env["WALLET_BATCH_CONCURRENCY"] = "30"
+	env["PAYOUT_MAX_LINES"] = "0"
+	env["PAYOUT_RETRY_INTERVAL"] = "0s"
+	env["SCHEDULE_INTERVAL"] = "-1s"
+	env["SWEEP_INTERVAL"] = "0s"
+	env["SWEEP_RULES"] = "ETH_TEST5=0.01:0.5:treasury-wallet"
+
+	config, err := Load("", lookupEnv(env))
+
+	assert.NotNil(t, config)
+	assert.Error(t, err)
+	for _, msg := range []string{
+		`HTTP_READ_TIMEOUT: invalid duration "soon"`,
+		"log.level: invalid log level",
+		"database.password is required",
+		"database.maxIdleConns must be between 0 and database.maxOpenConns",
+		"fireblocks.baseURL must be an http or https URL",
+		"fireblocks.webhookPublicKeyPath is required when features.webhooks is enabled",
+		"tracing.file is required",
+		`FIREBLOCKS_RATE_LIMIT_WRITE_RATE: invalid number "fast"`,
+		"fireblocks.rateLimits.read.burst must be at least 1",
+		"rateLimiting.store must be one of memory, postgres or redis",
+		"rateLimiting.perKey.write.window must be at least 1s",
+		"idempotency.store must be one of memory or postgres",
+		"idempotency.keyTTL must be positive",
+		"wallets.batchMaxSize must be at least 1",
+		"wallets.batchConcurrency must not exceed fireblocks.rateLimits.write.rate",
+		"payouts.maxLines must be at least 1",
+		"payouts.retryInterval must be positive",
+		"schedules.interval must be positive",
+		"sweeps.interval must be positive",
+		"sweeps.rules.ETH_TEST5: threshold must be greater than minLeftover",
+	} {
+		assert.ErrorContains(t, err, msg)
+	}
+}
+
+func TestSecretsMasked(t *testing.T) {
+	config, err := Load("", lookupEnv(requiredEnv()))
+	assert.NoError(t, err)
+
+	out, err := yaml.Marshal(config)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "apiKey: '[REDACTED]'")
+	assert.Contains(t, string(out), "password: '[REDACTED]'")
+	assert.NotContains(t, string(out), "api-key")
+	assert.NotContains(t, string(out), "password: password")
+	assert.Equal(t, "[REDACTED]", config.Fireblocks.APIKey.String())
+	assert.Equal(t, "", Secret("").String())
+}
+
+func TestLoadDatabaseURL(t *testing.T) {
+	env := requiredEnv()
+	delete(env, "DB_USER")
+	delete(env, "DB_PASSWORD")
+	env["DATABASE_URL"] = "postgres://wallet:secret@db:5432/firego?sslmode=verify-full"
+	env["DB_SSL_MODE"] = "sometimes"
+	env["DB_STATEMENT_TIMEOUT"] = "0"
+
+	config, err := Load("", lookupEnv(env))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://wallet:secret@db:5432/firego?sslmode=verify-full", config.Database.URL.Value())
+	assert.Equal(t, time.Duration(0), config.Database.StatementTimeout)
+	assert.Equal(t, 30*time.Second, config.Database.ConnectTimeout)
+
+	out, err := yaml.Marshal(config)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), "secret@db")
+}
+
+func TestLoadDatabaseTimeouts(t *testing.T) {
+	env := requiredEnv()
+	env["DB_STATEMENT_TIMEOUT"] = "-1s"
+	env["DB_CONNECT_TIMEOUT"] = "0"
+
+	_, err := Load("", lookupEnv(env))
+
+	assert.ErrorContains(t, err, "database.statementTimeout must not be negative")
+	assert.ErrorContains(t, err, "database.connectTimeout")
+}
+
+func TestLoadCache(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		expectedErr string
+	}{
+		{
+			name: "redis",
+			env:  map[string]string{"CACHE_BACKEND": "redis", "REDIS_ADDR": "redis:6379", "REDIS_PASSWORD": "secret", "REDIS_DB": "2"},
+		},
+		{
+			name: "none_ignores_ttl",
+			env:  map[string]string{"CACHE_BACKEND": "none", "CACHE_BALANCE_TTL": "0s"},
+		},
+		{
+			name:        "redis_without_addr",
+			env:         map[string]string{"CACHE_BACKEND": "redis"},
+			expectedErr: "cache.redis.addr is required",
+		},
+		{
+			name:        "unknown_backend",
+			env:         map[string]string{"CACHE_BACKEND": "memcached"},
+			expectedErr: "cache.backend must be one of none, memory or redis",
+		},
+		{
+			name:        "no_ttl",
+			env:         map[string]string{"CACHE_BALANCE_TTL": "0s"},
+			expectedErr: "cache.balanceTTL must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := requiredEnv()
+			for key, value := range tt.env {
+				env[key] = value
+			}
+
+			config, err := Load("", lookupEnv(env))
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.env["CACHE_BACKEND"], config.Cache.Backend)
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"errors"
+	"firego-wallet-service/internal/deposit"
+	"firego-wallet-service/internal/sweep"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv overrides the configuration with the environment variables that are set and not empty
+func (c *Config) applyEnv(lookupEnv func(string) (string, bool)) error {
+	e := &envLoader{lookupEnv: lookupEnv}
+
+	e.string("PORT", &c.Server.Port)
+	e.duration("HTTP_READ_TIMEOUT", &c.Server.ReadTimeout)
+	e.duration("HTTP_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	e.duration("HTTP_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	e.int("HTTP_MAX_HEADER_BYTES", &c.Server.MaxHeaderBytes)
+	e.duration("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+
+	e.string("LOG_LEVEL", &c.Log.Level)
+	e.string("DB_LOG_LEVEL", &c.Log.DatabaseLevel)
+
+	e.secret("DATABASE_URL", &c.Database.URL)
+	e.string("DB_HOST", &c.Database.Host)
+	e.string("DB_PORT", &c.Database.Port)
+	e.string("DB_NAME", &c.Database.Name)
+	e.string("DB_USER", &c.Database.User)
+	e.secret("DB_PASSWORD", &c.Database.Password)
+	e.string("DB_SSL_MODE", &c.Database.SSLMode)
+	e.string("DB_SSL_ROOT_CERT", &c.Database.SSLRootCert)
+	e.duration("DB_STATEMENT_TIMEOUT", &c.Database.StatementTimeout)
+	e.duration("DB_CONNECT_TIMEOUT", &c.Database.ConnectTimeout)
+	e.int("DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns)
+	e.int("DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns)
+	e.duration("DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime)
+	e.duration("DB_CONN_MAX_IDLE_TIME", &c.Database.ConnMaxIdleTime)
+
+	e.string("FIREBLOCKS_BASE_URL", &c.Fireblocks.BaseURL)
+	e.secret("FIREBLOCKS_API_KEY", &c.Fireblocks.APIKey)
+	e.string("FIREBLOCKS_SECRET_KEY_PATH", &c.Fireblocks.SecretKeyPath)
+	e.string("FIREBLOCKS_WEBHOOK_PUBLIC_KEY_PATH", &c.Fireblocks.WebhookPublicKeyPath)
+	e.duration("FIREBLOCKS_TIMEOUT", &c.Fireblocks.Timeout)
+	e.int("FIREBLOCKS_RETRY_MAX_ATTEMPTS", &c.Fireblocks.Retry.MaxAttempts)
+	e.duration("FIREBLOCKS_RETRY_INITIAL_BACKOFF", &c.Fireblocks.Retry.InitialBackoff)
+	e.duration("FIREBLOCKS_RETRY_MAX_BACKOFF", &c.Fireblocks.Retry.MaxBackoff)
+	e.float("FIREBLOCKS_RATE_LIMIT_READ_RATE", &c.Fireblocks.RateLimits.Read.Rate)
+	e.int("FIREBLOCKS_RATE_LIMIT_READ_BURST", &c.Fireblocks.RateLimits.Read.Burst)
+	e.float("FIREBLOCKS_RATE_LIMIT_WRITE_RATE", &c.Fireblocks.RateLimits.Write.Rate)
+	e.int("FIREBLOCKS_RATE_LIMIT_WRITE_BURST", &c.Fireblocks.RateLimits.Write.Burst)
+	e.duration("FIREBLOCKS_RATE_LIMIT_MAX_WAIT", &c.Fireblocks.RateLimits.MaxWait)
+
+	e.int("WALLET_BATCH_MAX_SIZE", &c.Wallets.BatchMaxSize)
+	e.int("WALLET_BATCH_CONCURRENCY", &c.Wallets.BatchConcurrency)
+
+	e.duration("DEPOSIT_POLL_INTERVAL", &c.Deposits.PollInterval)
+	e.duration("DEPOSIT_POLL_LOOKBACK", &c.Deposits.PollLookback)
+	e.int("DEPOSIT_DEFAULT_CONFIRMATIONS", &c.Deposits.DefaultConfirmations)
+	if value, ok := e.lookup("DEPOSIT_CONFIRMATIONS"); ok {
+		thresholds, err := deposit.ParseConfirmationThresholds(value, c.Deposits.DefaultConfirmations)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("DEPOSIT_CONFIRMATIONS: %w", err))
+		} else {
+			c.Deposits.Confirmations = thresholds.PerAsset
+		}
+	}
+
+	e.duration("TRANSFER_POLL_INTERVAL", &c.Transfers.PollInterval)
+
+	e.int("PAYOUT_MAX_LINES", &c.Payouts.MaxLines)
+	e.duration("PAYOUT_RETRY_INTERVAL", &c.Payouts.RetryInterval)
+
+	e.duration("SCHEDULE_INTERVAL", &c.Schedules.Interval)
+
+	e.duration("SWEEP_INTERVAL", &c.Sweeps.Interval)
+	if value, ok := e.lookup("SWEEP_RULES"); ok {
+		rules, err := sweep.ParseRules(value)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("SWEEP_RULES: %w", err))
+		} else {
+			c.Sweeps.Rules = rules
+		}
+	}
+
+	e.duration("RECONCILIATION_INTERVAL", &c.Reconciliation.Interval)
+	e.duration("RECONCILIATION_TRANSFER_WINDOW", &c.Reconciliation.TransferWindow)
+	e.list("RECONCILIATION_IGNORED_VAULTS", &c.Reconciliation.IgnoredVaults)
+
+	e.string("TRACING_EXPORTER", &c.Tracing.Exporter)
+	e.string("TRACING_FILE", &c.Tracing.File)
+
+	e.string("CACHE_BACKEND", &c.Cache.Backend)
+	e.duration("CACHE_BALANCE_TTL", &c.Cache.BalanceTTL)
+	e.string("REDIS_ADDR", &c.Cache.Redis.Addr)
+	e.secret("REDIS_PASSWORD", &c.Cache.Redis.Password)
+	e.int("REDIS_DB", &c.Cache.Redis.DB)
+
+	e.int64("MAX_REQUEST_BODY_BYTES", &c.Limits.MaxRequestBodyBytes)
+
+	e.string("RATE_LIMIT_STORE", &c.RateLimiting.Store)
+	e.bool("RATE_LIMIT_TRUST_FORWARDED_FOR", &c.RateLimiting.TrustForwardedFor)
+	e.int("RATE_LIMIT_IP_READ_REQUESTS", &c.RateLimiting.PerIP.Read.Requests)
+	e.duration("RATE_LIMIT_IP_READ_WINDOW", &c.RateLimiting.PerIP.Read.Window)
+	e.int("RATE_LIMIT_IP_WRITE_REQUESTS", &c.RateLimiting.PerIP.Write.Requests)
+	e.duration("RATE_LIMIT_IP_WRITE_WINDOW", &c.RateLimiting.PerIP.Write.Window)
+	e.int("RATE_LIMIT_IP_TRANSFER_REQUESTS", &c.RateLimiting.PerIP.Transfer.Requests)
+	e.duration("RATE_LIMIT_IP_TRANSFER_WINDOW", &c.RateLimiting.PerIP.Transfer.Window)
+	e.int("RATE_LIMIT_KEY_READ_REQUESTS", &c.RateLimiting.PerKey.Read.Requests)
+	e.duration("RATE_LIMIT_KEY_READ_WINDOW", &c.RateLimiting.PerKey.Read.Window)
+	e.int("RATE_LIMIT_KEY_WRITE_REQUESTS", &c.RateLimiting.PerKey.Write.Requests)
+	e.duration("RATE_LIMIT_KEY_WRITE_WINDOW", &c.RateLimiting.PerKey.Write.Window)
+	e.int("RATE_LIMIT_KEY_TRANSFER_REQUESTS", &c.RateLimiting.PerKey.Transfer.Requests)
+	e.duration("RATE_LIMIT_KEY_TRANSFER_WINDOW", &c.RateLimiting.PerKey.Transfer.Window)
+
+	e.string("IDEMPOTENCY_STORE", &c.Idempotency.Store)
+	e.duration("IDEMPOTENCY_KEY_TTL", &c.Idempotency.KeyTTL)
+
+	e.bool("FEATURE_WEBHOOKS", &c.Features.Webhooks)
+	e.bool("FEATURE_DEPOSIT_POLLING", &c.Features.DepositPolling)
+	e.bool("FEATURE_TRANSFER_POLLING", &c.Features.TransferPolling)
+	e.bool("FEATURE_RECONCILIATION", &c.Features.Reconciliation)
+	e.bool("FEATURE_METRICS", &c.Features.Metrics)
+	e.bool("FEATURE_REQUEST_VALIDATION", &c.Features.RequestValidation)
+	e.bool("FEATURE_API_KEYS", &c.Features.APIKeys)
+
+	return errors.Join(e.errs...)
+}
+
+// envLoader parses environment variables into configuration fields, collecting the parsing errors
+type envLoader struct {
+	lookupEnv func(string) (string, bool)
+	errs      []error
+}
+
+func (e *envLoader) lookup(key string) (string, bool) {
+	value, ok := e.lookupEnv(key)
+	value = strings.TrimSpace(value)
+	return value, ok && value != ""
+}
+
+func (e *envLoader) string(key string, dst *string) {
+	if value, ok := e.lookup(key); ok {
+		*dst = value
+	}
+}
+
+func (e *envLoader) secret(key string, dst *Secret) {
+	if value, ok := e.lookup(key); ok {
+		*dst = Secret(value)
+	}
+}
+
+func (e *envLoader) int(key string, dst *int) {
+	if value, ok := e.lookup(key); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: invalid integer %q", key, value))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envLoader) int64(key string, dst *int64) {
+	if value, ok := e.lookup(key); ok {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: invalid integer %q", key, value))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envLoader) float(key string, dst *float64) {
+	if value, ok := e.lookup(key); ok {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: invalid number %q", key, value))
+			return
+		}
+		*dst = f
+	}
+}
+
+func (e *envLoader) bool(key string, dst *bool) {
+	if value, ok := e.lookup(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: invalid boolean %q", key, value))
+			return
+		}
+		*dst = b
+	}
+}
+
+func (e *envLoader) duration(key string, dst *time.Duration) {
+	if value, ok := e.lookup(key); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: invalid duration %q", key, value))
+			return
+		}
+		*dst = d
+	}
+}
+
+// list parses a comma-separated list, ignoring empty items
+func (e *envLoader) list(key string, dst *[]string) {
+	if value, ok := e.lookup(key); ok {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*dst = items
+	}
+}
@@ -0,0 +1,177 @@
+// Package cron parses the five-field cron expressions of the scheduled transfers, minute, hour, day of month, month
+// and day of week, and computes their next run.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearch bounds the search of the next run, an expression that does not match within it never does, e.g. 0 0 30 2 *
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type bounds struct {
+	name     string
+	min, max int
+}
+
+var fields = []bounds{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	// 7 is Sunday too
+	{name: "day of week", min: 0, max: 7},
+}
+
+// Schedule is a parsed expression, each field being the set of the values it matches
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// restrictedDays is whether both the day of month and the day of week are restricted, in which case a day matching
+	// either of them runs, as in crontab(5)
+	restrictedDays bool
+}
+
+// Parse parses an expression of five fields, each a *, a value, a range or a list of them, optionally with a /step,
+// or one of the @yearly, @monthly, @weekly, @daily and @hourly macros
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := macros[expr]; ok {
+		expr = macro
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("expected %d fields, got %d", len(fields), len(parts))
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fields[i].name, err)
+		}
+		sets[i] = set
+	}
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] = sets[4]&^(1<<7) | 1
+	}
+
+	return &Schedule{
+		minute:         sets[0],
+		hour:           sets[1],
+		dom:            sets[2],
+		month:          sets[3],
+		dow:            sets[4],
+		restrictedDays: !strings.HasPrefix(parts[2], "*") && !strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		low, high := b.min, b.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			lowPart, highPart, _ := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = parseValue(lowPart, b); err != nil {
+				return 0, err
+			}
+			if high, err = parseValue(highPart, b); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			var err error
+			if low, err = parseValue(rangePart, b); err != nil {
+				return 0, err
+			}
+			// a single value with a step runs from it to the maximum, e.g. 5/15 is 5,20,35,50
+			if !hasStep {
+				high = low
+			}
+		}
+
+		for value := low; value <= high; value += step {
+			set |= 1 << value
+		}
+	}
+	return set, nil
+}
+
+func parseValue(s string, b bounds) (int, error) {
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if value < b.min || value > b.max {
+		return 0, fmt.Errorf("%d is out of range %d-%d", value, b.min, b.max)
+	}
+	return value, nil
+}
+
+// Next returns the first minute after t that the schedule matches, in the location of t, or the zero time when it
+// never matches
+func (s *Schedule) Next(t time.Time) time.Time {
+	limit := t.Add(maxSearch)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.restrictedDays {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func has(set uint64, value int) bool {
+	return set&(1<<value) != 0
+}
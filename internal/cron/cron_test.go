@@ -0,0 +1,138 @@
+package cron
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		err  string
+	}{
+		{
+			name: "too_few_fields",
+			expr: "0 9 * *",
+			err:  "expected 5 fields, got 4",
+		},
+		{
+			name: "unknown_macro",
+			expr: "@fortnightly",
+			err:  "expected 5 fields, got 1",
+		},
+		{
+			name: "out_of_range",
+			expr: "60 * * * *",
+			err:  "minute: 60 is out of range 0-59",
+		},
+		{
+			name: "invalid_value",
+			expr: "0 9 * * MON",
+			err:  `day of week: invalid value "MON"`,
+		},
+		{
+			name: "invalid_range",
+			expr: "0 17-9 * * *",
+			err:  `hour: invalid range "17-9"`,
+		},
+		{
+			name: "invalid_step",
+			expr: "*/0 * * * *",
+			err:  `minute: invalid step "0"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2026, time.January, 14, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{
+			name: "every_minute",
+			expr: "* * * * *",
+			want: time.Date(2026, time.January, 14, 10, 31, 0, 0, time.UTC),
+		},
+		{
+			name: "step",
+			expr: "*/15 * * * *",
+			want: time.Date(2026, time.January, 14, 10, 45, 0, 0, time.UTC),
+		},
+		{
+			name: "value_with_step",
+			expr: "5/20 * * * *",
+			want: time.Date(2026, time.January, 14, 10, 45, 0, 0, time.UTC),
+		},
+		{
+			name: "later_today",
+			expr: "0 17 * * *",
+			want: time.Date(2026, time.January, 14, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "tomorrow",
+			expr: "0 9 * * *",
+			want: time.Date(2026, time.January, 15, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "list_and_range",
+			expr: "0 8,12-14 * * *",
+			want: time.Date(2026, time.January, 14, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "weekly",
+			expr: "@weekly",
+			want: time.Date(2026, time.January, 18, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "sunday_as_seven",
+			expr: "0 0 * * 7",
+			want: time.Date(2026, time.January, 18, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "weekdays",
+			expr: "0 9 * * 1-5",
+			want: time.Date(2026, time.January, 15, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "monthly",
+			expr: "@monthly",
+			want: time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "day_of_month_or_day_of_week",
+			expr: "0 0 20 * 5",
+			want: time.Date(2026, time.January, 16, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "leap_day",
+			expr: "0 0 29 2 *",
+			want: time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "never",
+			expr: "0 0 30 2 *",
+			want: time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.expr)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, schedule.Next(from))
+		})
+	}
+}
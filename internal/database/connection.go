@@ -1,36 +1,142 @@
 package database
 
 import (
-	"firego-wallet-service/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"firego-wallet-service/internal/metrics"
 	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
-	"log"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func Connect(host, port, dbname, user, password, sslmode string) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=%s",
-		host, port, dbname, user, password, sslmode)
+const (
+	initialConnectBackoff = 250 * time.Millisecond
+	maxConnectBackoff     = 5 * time.Second
+)
+
+type Config struct {
+	// URL is a full connection string, either a postgres:// URL or key=value pairs, e.g. with sslrootcert.
+	// When set, the discrete connection settings below are ignored.
+	URL         string
+	Host        string
+	Port        string
+	Name        string
+	User        string
+	Password    string
+	SSLMode     string
+	SSLRootCert string
+
+	// StatementTimeout aborts any statement running longer, 0 disables it
+	StatementTimeout time.Duration
+	// ConnectTimeout bounds the retries while the database is not reachable at startup
+	ConnectTimeout time.Duration
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	LogLevel logger.LogLevel
+}
+
+// Connect opens the connection pool, retrying with backoff until the database answers or ConnectTimeout elapses
+func Connect(ctx context.Context, config Config) (*gorm.DB, error) {
+	connConfig, err := pgx.ParseConfig(config.dsn())
+	if err != nil {
+		return nil, fmt.Errorf("invalid database connection settings: %w", err)
+	}
+	if config.StatementTimeout > 0 {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(config.StatementTimeout.Milliseconds(), 10)
+	}
 
-	log.Printf("Connecting to database with DSN: host=%s port=%s dbname=%s user=%s sslmode=%s",
-		host, port, dbname, user, sslmode)
+	sqlDB := stdlib.OpenDB(*connConfig)
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+	slog.Info("Connecting to database", "host", connConfig.Host, "port", connConfig.Port, "dbname", connConfig.Database, "user", connConfig.User)
+	if err = waitUntilReachable(ctx, sqlDB, config.ConnectTimeout); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:         newSlogLogger(config.LogLevel),
+		TranslateError: true,
 	})
 	if err != nil {
+		sqlDB.Close()
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	log.Println("Successfully connected to database")
+	slog.Info("Successfully connected to database")
 
-	log.Println("Running migrations...")
-	if err = db.AutoMigrate(&model.Wallet{}); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	if err = registerMetricsCallbacks(db); err != nil {
+		return nil, err
+	}
+	if err = metrics.RegisterDBStats(sqlDB); err != nil {
+		return nil, err
 	}
-
-	log.Println("Successfully migrated database")
 
 	return db, nil
 }
+
+// dsn returns the URL if set, or builds key=value pairs from the discrete settings
+func (c Config) dsn() string {
+	if c.URL != "" {
+		return c.URL
+	}
+
+	pairs := []string{
+		"host=" + quote(c.Host),
+		"port=" + quote(c.Port),
+		"dbname=" + quote(c.Name),
+		"user=" + quote(c.User),
+		"password=" + quote(c.Password),
+		"sslmode=" + quote(c.SSLMode),
+	}
+	if c.SSLRootCert != "" {
+		pairs = append(pairs, "sslrootcert="+quote(c.SSLRootCert))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// quote quotes a key=value connection string value, which may then contain spaces and quotes
+func quote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// waitUntilReachable pings the database until it answers, doubling the delay between attempts. The makefile and
+// orchestrators start the database and the service together, so it is commonly not ready yet.
+func waitUntilReachable(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := initialConnectBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("database not reachable after %s: %w", timeout, err)
+		}
+
+		slog.Warn("Database not reachable, retrying", "attempt", attempt, "retry_in", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not reachable after %s: %w", timeout, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxConnectBackoff)
+	}
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConfigDSN(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected string
+	}{
+		{
+			name: "settings",
+			config: Config{
+				Host:        "db",
+				Port:        "5432",
+				Name:        "firego",
+				User:        "wallet",
+				Password:    `it's a p\ss word`,
+				SSLMode:     "verify-full",
+				SSLRootCert: "/etc/ssl/root ca.crt",
+			},
+			expected: `host='db' port='5432' dbname='firego' user='wallet' password='it\'s a p\\ss word' sslmode='verify-full' sslrootcert='/etc/ssl/root ca.crt'`,
+		},
+		{
+			name: "url",
+			config: Config{
+				URL:  "postgres://wallet:secret@db:5432/firego?sslmode=disable",
+				Host: "ignored",
+			},
+			expected: "postgres://wallet:secret@db:5432/firego?sslmode=disable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.config.dsn())
+		})
+	}
+}
+
+func TestConfigDSNParses(t *testing.T) {
+	config := Config{Host: "db", Port: "5433", Name: "firego", User: "wallet", Password: `it's a p\ss word`, SSLMode: "disable"}
+
+	connConfig, err := pgx.ParseConfig(config.dsn())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "db", connConfig.Host)
+	assert.Equal(t, uint16(5433), connConfig.Port)
+	assert.Equal(t, "firego", connConfig.Database)
+	assert.Equal(t, `it's a p\ss word`, connConfig.Password)
+}
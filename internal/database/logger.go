@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/logging"
+	"fmt"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"log/slog"
+	"time"
+)
+
+// slowQueryThreshold is the duration above which queries are logged as warnings
+const slowQueryThreshold = 200 * time.Millisecond
+
+// ParseLogLevel parses one of silent, error, warn or info. At info every statement is logged.
+func ParseLogLevel(level string) (gormlogger.LogLevel, error) {
+	switch level {
+	case "silent":
+		return gormlogger.Silent, nil
+	case "error":
+		return gormlogger.Error, nil
+	case "warn":
+		return gormlogger.Warn, nil
+	case "info":
+		return gormlogger.Info, nil
+	default:
+		return 0, fmt.Errorf("invalid database log level %q", level)
+	}
+}
+
+// slogLogger sends GORM logs to the request logger of the query's context, or the default logger
+type slogLogger struct {
+	level gormlogger.LogLevel
+}
+
+func newSlogLogger(level gormlogger.LogLevel) *slogLogger {
+	return &slogLogger{
+		level: level,
+	}
+}
+
+func (l *slogLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return newSlogLogger(level)
+}
+
+func (l *slogLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Info {
+		logging.FromContext(ctx).InfoContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *slogLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		logging.FromContext(ctx).WarnContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *slogLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Error {
+		logging.FromContext(ctx).ErrorContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *slogLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	logger := logging.FromContext(ctx)
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		logger.ErrorContext(ctx, "Query failed", slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("duration", elapsed), slog.Any("error", err))
+	case elapsed > slowQueryThreshold && l.level >= gormlogger.Warn:
+		sql, rows := fc()
+		logger.WarnContext(ctx, "Slow query", slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("duration", elapsed))
+	case l.level >= gormlogger.Info:
+		sql, rows := fc()
+		logger.InfoContext(ctx, "Query", slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("duration", elapsed))
+	}
+}
+
+// ParamsFilter keeps bind values, such as destination addresses, out of the logged statements
+func (l *slogLogger) ParamsFilter(_ context.Context, sql string, _ ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
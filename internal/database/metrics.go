@@ -0,0 +1,51 @@
+package database
+
+import (
+	"firego-wallet-service/internal/metrics"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+const queryStartKey = "metrics:query_start"
+
+// registerMetricsCallbacks times every query GORM runs, by operation and table
+func registerMetricsCallbacks(db *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(queryStartKey, time.Now())
+	}
+	after := func(operation string) func(tx *gorm.DB) {
+		return func(tx *gorm.DB) {
+			start, ok := tx.InstanceGet(queryStartKey)
+			if !ok {
+				return
+			}
+			table := tx.Statement.Table
+			if table == "" {
+				table = "unknown"
+			}
+			metrics.ObserveDBQuery(operation, table, time.Since(start.(time.Time)))
+		}
+	}
+
+	callback := db.Callback()
+	for _, err := range []error{
+		callback.Create().Before("gorm:create").Register("metrics:before_create", before),
+		callback.Create().After("gorm:create").Register("metrics:after_create", after("create")),
+		callback.Query().Before("gorm:query").Register("metrics:before_query", before),
+		callback.Query().After("gorm:query").Register("metrics:after_query", after("query")),
+		callback.Update().Before("gorm:update").Register("metrics:before_update", before),
+		callback.Update().After("gorm:update").Register("metrics:after_update", after("update")),
+		callback.Delete().Before("gorm:delete").Register("metrics:before_delete", before),
+		callback.Delete().After("gorm:delete").Register("metrics:after_delete", after("delete")),
+		callback.Row().Before("gorm:row").Register("metrics:before_row", before),
+		callback.Row().After("gorm:row").Register("metrics:after_row", after("row")),
+		callback.Raw().Before("gorm:raw").Register("metrics:before_raw", before),
+		callback.Raw().After("gorm:raw").Register("metrics:after_raw", after("raw")),
+	} {
+		if err != nil {
+			return fmt.Errorf("failed to register metrics callbacks: %w", err)
+		}
+	}
+	return nil
+}
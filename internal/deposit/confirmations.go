@@ -0,0 +1,49 @@
+package deposit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ConfirmationThresholds holds the number of confirmations a deposit needs before it is credited
+type ConfirmationThresholds struct {
+	Default  int
+	PerAsset map[string]int
+}
+
+func (c ConfirmationThresholds) For(assetID string) int {
+	if n, ok := c.PerAsset[assetID]; ok {
+		return n
+	}
+	return c.Default
+}
+
+// ParseConfirmationThresholds parses a comma separated list of ASSET=COUNT pairs, e.g. "BTC_TEST=2,ETH_TEST5=12"
+func ParseConfirmationThresholds(spec string, defaultThreshold int) (ConfirmationThresholds, error) {
+	thresholds := ConfirmationThresholds{
+		Default:  defaultThreshold,
+		PerAsset: map[string]int{},
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		assetID, count, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(assetID) == "" {
+			return ConfirmationThresholds{}, fmt.Errorf("invalid confirmation threshold %q, expected ASSET=COUNT", pair)
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil || n < 0 {
+			return ConfirmationThresholds{}, fmt.Errorf("invalid confirmation count for %s: %q", assetID, count)
+		}
+
+		thresholds.PerAsset[strings.TrimSpace(assetID)] = n
+	}
+
+	return thresholds, nil
+}
@@ -0,0 +1,169 @@
+package deposit
+
+import (
+	"context"
+	"firego-wallet-service/internal/fireblocks"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type FireblocksClient interface {
+	GetTransaction(ctx context.Context, txID string) (*fireblocks.TransactionResponse, int, error)
+	ListTransactions(ctx context.Context, req fireblocks.ListTransactionsRequest) ([]fireblocks.TransactionResponse, int, error)
+}
+
+// CursorStore keeps the creation time of the last polled transaction across restarts and leaders
+type CursorStore interface {
+	Get(ctx context.Context, name string) (int64, bool, error)
+	Save(ctx context.Context, name string, value int64) error
+}
+
+type Elector interface {
+	IsLeader(ctx context.Context) bool
+}
+
+const (
+	// pollPageSize is the maximum number of transactions Fireblocks returns per page
+	pollPageSize = 500
+	// cursorName is the name of the poller cursor in the CursorStore
+	cursorName = "deposits"
+)
+
+// Poller is the fallback for missed webhooks. On every tick the elected leader lists transactions into vault
+// accounts created since the last seen one, and refreshes all pending deposits to pick up new confirmations.
+type Poller struct {
+	fireblocksClient FireblocksClient
+	depositRepo      DepositRepository
+	processor        *Processor
+	cursors          CursorStore
+	elector          Elector
+	interval         time.Duration
+	// lookback is how far back the first poll starts, before any cursor is stored
+	lookback time.Duration
+}
+
+func NewPoller(fireblocksClient FireblocksClient, depositRepo DepositRepository, processor *Processor, cursors CursorStore, elector Elector, interval, lookback time.Duration) *Poller {
+	return &Poller{
+		fireblocksClient: fireblocksClient,
+		depositRepo:      depositRepo,
+		processor:        processor,
+		cursors:          cursors,
+		elector:          elector,
+		interval:         interval,
+		lookback:         lookback,
+	}
+}
+
+// Run polls on the elected leader until the context is cancelled
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if p.elector.IsLeader(ctx) {
+			p.Poll(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll runs a single polling cycle
+func (p *Poller) Poll(ctx context.Context) {
+	if err := p.pollNewTransactions(ctx); err != nil {
+		slog.Error("Failed to poll incoming transactions", "error", err)
+	}
+	if err := p.refreshPendingDeposits(ctx); err != nil {
+		slog.Error("Failed to refresh pending deposits", "error", err)
+	}
+}
+
+// pollNewTransactions resumes from the stored cursor, which is read on every poll since another instance may have
+// been the leader in between. The transactions are listed from the cursor included, since more of them than the last
+// page held may share its creation time; the ones already processed by this poll are skipped.
+func (p *Poller) pollNewTransactions(ctx context.Context) error {
+	cursor, ok, err := p.cursors.Get(ctx, cursorName)
+	if err != nil {
+		return fmt.Errorf("failed to get cursor: %w", err)
+	}
+	if !ok {
+		cursor = time.Now().Add(-p.lookback).UnixMilli()
+	}
+
+	seen := map[string]bool{}
+	// after is exclusive
+	after := cursor - 1
+	for {
+		txs, _, err := p.fireblocksClient.ListTransactions(ctx, fireblocks.ListTransactionsRequest{
+			After:    after,
+			DestType: "VAULT_ACCOUNT",
+			OrderBy:  "createdAt",
+			Sort:     "ASC",
+			Limit:    pollPageSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		last := cursor
+		processed := 0
+		for _, tx := range txs {
+			if seen[tx.ID] {
+				continue
+			}
+			if err = p.processor.ProcessTransaction(ctx, tx); err != nil {
+				// stop here so the transaction is picked up again on the next cycle
+				break
+			}
+			seen[tx.ID] = true
+			processed++
+			if tx.CreatedAt > cursor {
+				cursor = tx.CreatedAt
+			}
+		}
+		if cursor > last {
+			if saveErr := p.cursors.Save(ctx, cursorName, cursor); saveErr != nil {
+				// the transactions are processed again from the previous cursor, which is harmless
+				return fmt.Errorf("failed to save cursor: %w", saveErr)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(txs) < pollPageSize {
+			return nil
+		}
+		after = cursor - 1
+		if processed == 0 {
+			// a whole page shares the creation time of the cursor, which listing by creation time cannot page through
+			slog.Warn("More incoming transactions than a page share a creation time, polling past it", "created_at", cursor)
+			after = cursor
+		}
+	}
+}
+
+func (p *Poller) refreshPendingDeposits(ctx context.Context) error {
+	deposits, err := p.depositRepo.ListPending()
+	if err != nil {
+		return err
+	}
+
+	for _, deposit := range deposits {
+		tx, _, err := p.fireblocksClient.GetTransaction(ctx, deposit.FireblocksTxID)
+		if err != nil {
+			slog.Error("Failed to get transaction for deposit", "transaction_id", deposit.FireblocksTxID, "deposit_id", deposit.ID, "error", err)
+			continue
+		}
+		if err = p.processor.ProcessTransaction(ctx, *tx); err != nil {
+			slog.Error("Failed to process transaction", "transaction_id", tx.ID, "error", err)
+		}
+	}
+
+	return nil
+}
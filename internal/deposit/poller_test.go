@@ -0,0 +1,136 @@
+package deposit
+
+import (
+	"context"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// MockFireblocksClient lists a page of the transactions created after the requested time, in creation order
+type MockFireblocksClient struct {
+	Transactions []fireblocks.TransactionResponse
+	ListedAfter  []int64
+}
+
+func (m *MockFireblocksClient) GetTransaction(_ context.Context, txID string) (*fireblocks.TransactionResponse, int, error) {
+	for _, tx := range m.Transactions {
+		if tx.ID == txID {
+			return &tx, 200, nil
+		}
+	}
+	return nil, 404, assert.AnError
+}
+
+func (m *MockFireblocksClient) ListTransactions(_ context.Context, req fireblocks.ListTransactionsRequest) ([]fireblocks.TransactionResponse, int, error) {
+	m.ListedAfter = append(m.ListedAfter, req.After)
+	var txs []fireblocks.TransactionResponse
+	for _, tx := range m.Transactions {
+		if tx.CreatedAt > req.After && len(txs) < req.Limit {
+			txs = append(txs, tx)
+		}
+	}
+	return txs, 200, nil
+}
+
+type MemoryCursorStore struct {
+	Cursors map[string]int64
+}
+
+func (m *MemoryCursorStore) Get(_ context.Context, name string) (int64, bool, error) {
+	value, ok := m.Cursors[name]
+	return value, ok, nil
+}
+
+func (m *MemoryCursorStore) Save(_ context.Context, name string, value int64) error {
+	m.Cursors[name] = value
+	return nil
+}
+
+type StaticElector bool
+
+func (e StaticElector) IsLeader(context.Context) bool {
+	return bool(e)
+}
+
+func TestPollerCursor(t *testing.T) {
+	ctx := context.Background()
+	walletRepo := &MockWalletRepository{Wallets: map[string]*model.Wallet{"86": {ID: "wallet-1", VaultAccountID: "86"}}}
+	depositRepo := &MockDepositRepository{Deposits: map[string]*model.Deposit{}}
+	processor := NewProcessor(walletRepo, depositRepo, &MockLedger{}, ConfirmationThresholds{Default: 1})
+	tx := incomingTx(fireblocks.TransactionStatusCompleted, 1)
+	tx.CreatedAt = time.Now().UnixMilli()
+	client := &MockFireblocksClient{Transactions: []fireblocks.TransactionResponse{tx}}
+	cursors := &MemoryCursorStore{Cursors: map[string]int64{}}
+
+	poller := NewPoller(client, depositRepo, processor, cursors, StaticElector(true), time.Minute, time.Hour)
+	poller.Poll(ctx)
+
+	// the first poll starts from the lookback window and stores the creation time of the last transaction
+	assert.InDelta(t, time.Now().Add(-time.Hour).UnixMilli(), client.ListedAfter[0], float64(time.Minute.Milliseconds()))
+	assert.Equal(t, tx.CreatedAt, cursors.Cursors[cursorName])
+	assert.Contains(t, depositRepo.Deposits, tx.ID)
+
+	// a poller started later, e.g. on the next leader, resumes from the stored cursor
+	NewPoller(client, depositRepo, processor, cursors, StaticElector(true), time.Minute, time.Hour).Poll(ctx)
+	assert.Equal(t, tx.CreatedAt-1, client.ListedAfter[1])
+}
+
+// sharedCreationTimes are pollPageSize+1 incoming transactions into vault account 86, the ones from index shared on
+// created at the same time
+func sharedCreationTimes(shared int) []fireblocks.TransactionResponse {
+	createdAt := time.Now().UnixMilli()
+	txs := make([]fireblocks.TransactionResponse, pollPageSize+1)
+	for i := range txs {
+		txs[i] = incomingTx(fireblocks.TransactionStatusCompleted, 1)
+		txs[i].ID = "tx-" + strconv.Itoa(i)
+		txs[i].CreatedAt = createdAt + int64(min(i, shared))
+	}
+	return txs
+}
+
+func TestPollerPageBoundary(t *testing.T) {
+	tests := []struct {
+		name string
+		// shared is the index of the first transaction sharing the creation time of the last ones
+		shared int
+		// polled is how many transactions get a deposit
+		polled int
+	}{
+		// the last transaction of the first page was created at the same time as the first one of the next page
+		{name: "shared_across_pages", shared: pollPageSize - 1, polled: pollPageSize + 1},
+		// more transactions than a page share a creation time, the poll moves past them instead of listing them forever
+		{name: "shared_by_more_than_a_page", shared: 0, polled: pollPageSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			walletRepo := &MockWalletRepository{Wallets: map[string]*model.Wallet{"86": {ID: "wallet-1", VaultAccountID: "86"}}}
+			depositRepo := &MockDepositRepository{Deposits: map[string]*model.Deposit{}}
+			processor := NewProcessor(walletRepo, depositRepo, &MockLedger{}, ConfirmationThresholds{Default: 1})
+			client := &MockFireblocksClient{Transactions: sharedCreationTimes(tt.shared)}
+			cursors := &MemoryCursorStore{Cursors: map[string]int64{}}
+
+			NewPoller(client, depositRepo, processor, cursors, StaticElector(true), time.Minute, time.Hour).Poll(context.Background())
+
+			assert.Len(t, depositRepo.Deposits, tt.polled)
+			assert.Equal(t, client.Transactions[pollPageSize].CreatedAt, cursors.Cursors[cursorName])
+		})
+	}
+}
+
+func TestPollerNotLeader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &MockFireblocksClient{}
+	depositRepo := &MockDepositRepository{Deposits: map[string]*model.Deposit{}}
+	processor := NewProcessor(&MockWalletRepository{}, depositRepo, &MockLedger{}, ConfirmationThresholds{})
+	poller := NewPoller(client, depositRepo, processor, &MemoryCursorStore{Cursors: map[string]int64{}}, StaticElector(false), time.Minute, time.Hour)
+
+	cancel()
+	poller.Run(ctx)
+
+	assert.Empty(t, client.ListedAfter)
+}
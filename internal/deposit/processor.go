@@ -0,0 +1,131 @@
+package deposit
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/model"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+type WalletRepository interface {
+	GetByVaultAccountID(ctx context.Context, vaultAccountID string) (*model.Wallet, error)
+}
+
+type DepositRepository interface {
+	Save(deposit *model.Deposit) error
+	GetByFireblocksTxID(txID string) (*model.Deposit, error)
+	ListPending() ([]model.Deposit, error)
+}
+
+type Ledger interface {
+	PostDeposit(deposit model.Deposit) error
+}
+
+// Processor turns incoming Fireblocks transactions into deposits against the owning wallet.
+// It is shared by the webhook handler and the poller, so processing the same transaction twice is a no-op.
+type Processor struct {
+	walletRepo    WalletRepository
+	depositRepo   DepositRepository
+	ledger        Ledger
+	confirmations ConfirmationThresholds
+}
+
+func NewProcessor(walletRepo WalletRepository, depositRepo DepositRepository, ledger Ledger, confirmations ConfirmationThresholds) *Processor {
+	return &Processor{
+		walletRepo:    walletRepo,
+		depositRepo:   depositRepo,
+		ledger:        ledger,
+		confirmations: confirmations,
+	}
+}
+
+// ProcessTransaction records or updates the deposit for an incoming transaction.
+// Transactions that do not target one of our vault accounts are ignored, and so are the movements between two of them,
+// such as sweeps, which are booked with the transfer of the source wallet.
+func (p *Processor) ProcessTransaction(ctx context.Context, tx fireblocks.TransactionResponse) error {
+	if tx.Destination.Type != "VAULT_ACCOUNT" || tx.Destination.ID == "" {
+		return nil
+	}
+	if tx.Source.Type == "VAULT_ACCOUNT" && tx.Source.ID != "" {
+		_, err := p.walletRepo.GetByVaultAccountID(ctx, tx.Source.ID)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to get wallet for vault account %s: %w", tx.Source.ID, err)
+		}
+		// a vault account without a wallet is outside the books, its funds come in as a deposit
+	}
+
+	wallet, err := p.walletRepo.GetByVaultAccountID(ctx, tx.Destination.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get wallet for vault account %s: %w", tx.Destination.ID, err)
+	}
+
+	deposit, err := p.depositRepo.GetByFireblocksTxID(tx.ID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to get deposit for transaction %s: %w", tx.ID, err)
+		}
+		deposit = &model.Deposit{
+			WalletID:              wallet.ID,
+			FireblocksTxID:        tx.ID,
+			AssetID:               tx.AssetID,
+			RequiredConfirmations: p.confirmations.For(tx.AssetID),
+			Status:                model.DepositStatusPending,
+		}
+	}
+
+	if deposit.Status != model.DepositStatusPending {
+		// credited and failed deposits are final, late or replayed events must not change them
+		return nil
+	}
+
+	applyTransaction(deposit, tx)
+
+	if deposit.Status == model.DepositStatusCredited {
+		// book before saving: if saving fails the deposit stays pending and the replayed posting is a no-op
+		if err = p.ledger.PostDeposit(*deposit); err != nil {
+			return fmt.Errorf("failed to post deposit for transaction %s to the ledger: %w", tx.ID, err)
+		}
+	}
+
+	if err = p.depositRepo.Save(deposit); err != nil {
+		return fmt.Errorf("failed to save deposit for transaction %s: %w", tx.ID, err)
+	}
+
+	if deposit.Status == model.DepositStatusCredited {
+		logging.FromContext(ctx).Info("Credited deposit", "deposit_id", deposit.ID, "amount", deposit.Amount, "asset_id", deposit.AssetID, "wallet_id", deposit.WalletID)
+	}
+
+	return nil
+}
+
+func applyTransaction(deposit *model.Deposit, tx fireblocks.TransactionResponse) {
+	deposit.Amount = tx.AmountInfo.Amount
+	deposit.SourceAddress = tx.SourceAddress
+	deposit.DestinationAddress = tx.DestinationAddress
+	deposit.TxHash = tx.TxHash
+	deposit.FireblocksStatus = tx.Status
+
+	// events may arrive out of order, never move the confirmation count backwards
+	if tx.NumOfConfirmations > deposit.Confirmations {
+		deposit.Confirmations = tx.NumOfConfirmations
+	}
+
+	switch {
+	case fireblocks.IsFailedTransactionStatus(tx.Status):
+		deposit.Status = model.DepositStatusFailed
+	case tx.Status == fireblocks.TransactionStatusCompleted && deposit.Confirmations >= deposit.RequiredConfirmations:
+		now := time.Now()
+		deposit.Status = model.DepositStatusCredited
+		deposit.CreditedAt = &now
+	}
+}
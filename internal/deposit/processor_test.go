@@ -0,0 +1,280 @@
+package deposit
+
+import (
+	"context"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+)
+
+type MockWalletRepository struct {
+	Wallets map[string]*model.Wallet
+	Error   error
+}
+
+func (m *MockWalletRepository) GetByVaultAccountID(_ context.Context, vaultAccountID string) (*model.Wallet, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	wallet, ok := m.Wallets[vaultAccountID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return wallet, nil
+}
+
+type MockDepositRepository struct {
+	Deposits  map[string]*model.Deposit
+	SaveError error
+	SaveCalls int
+}
+
+func (m *MockDepositRepository) Save(deposit *model.Deposit) error {
+	m.SaveCalls++
+	if m.SaveError != nil {
+		return m.SaveError
+	}
+	if deposit.ID == "" {
+		deposit.ID = "deposit-" + deposit.FireblocksTxID
+	}
+	m.Deposits[deposit.FireblocksTxID] = deposit
+	return nil
+}
+
+func (m *MockDepositRepository) GetByFireblocksTxID(txID string) (*model.Deposit, error) {
+	deposit, ok := m.Deposits[txID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *deposit
+	return &copied, nil
+}
+
+func (m *MockDepositRepository) ListPending() ([]model.Deposit, error) {
+	var deposits []model.Deposit
+	for _, deposit := range m.Deposits {
+		if deposit.Status == model.DepositStatusPending {
+			deposits = append(deposits, *deposit)
+		}
+	}
+	return deposits, nil
+}
+
+type MockLedger struct {
+	Posted []model.Deposit
+	Error  error
+}
+
+func (m *MockLedger) PostDeposit(deposit model.Deposit) error {
+	if m.Error != nil {
+		return m.Error
+	}
+	m.Posted = append(m.Posted, deposit)
+	return nil
+}
+
+func incomingTx(status string, confirmations int) fireblocks.TransactionResponse {
+	return fireblocks.TransactionResponse{
+		ID:                 "tx-1",
+		Status:             status,
+		AssetID:            "BTC_TEST",
+		Source:             fireblocks.TransferPeerPath{Type: "UNKNOWN"},
+		Destination:        fireblocks.TransferPeerPath{Type: "VAULT_ACCOUNT", ID: "86"},
+		SourceAddress:      "tb1qsource",
+		DestinationAddress: "tb1qdestination",
+		TxHash:             "abc123",
+		AmountInfo:         fireblocks.AmountInfo{Amount: "0.0001"},
+		NumOfConfirmations: confirmations,
+	}
+}
+
+func TestProcessTransaction(t *testing.T) {
+	thresholds := ConfirmationThresholds{Default: 1, PerAsset: map[string]int{"BTC_TEST": 3}}
+
+	tests := []struct {
+		name     string
+		existing *model.Deposit
+		txs      []fireblocks.TransactionResponse
+		assert   func(t *testing.T, depositRepo *MockDepositRepository, ledger *MockLedger)
+	}{
+		{
+			name: "new_deposit_is_pending",
+			txs:  []fireblocks.TransactionResponse{incomingTx(fireblocks.TransactionStatusConfirming, 1)},
+			assert: func(t *testing.T, depositRepo *MockDepositRepository, ledger *MockLedger) {
+				deposit := depositRepo.Deposits["tx-1"]
+				assert.NotNil(t, deposit)
+				assert.Equal(t, "wallet-1", deposit.WalletID)
+				assert.Equal(t, "0.0001", deposit.Amount)
+				assert.Equal(t, "tb1qdestination", deposit.DestinationAddress)
+				assert.Equal(t, 1, deposit.Confirmations)
+				assert.Equal(t, 3, deposit.RequiredConfirmations)
+				assert.Equal(t, model.DepositStatusPending, deposit.Status)
+				assert.Nil(t, deposit.CreditedAt)
+				assert.Empty(t, ledger.Posted)
+			},
+		},
+		{
+			name: "completed_below_threshold_stays_pending",
+			txs:  []fireblocks.TransactionResponse{incomingTx(fireblocks.TransactionStatusCompleted, 2)},
+			assert: func(t *testing.T, depositRepo *MockDepositRepository, ledger *MockLedger) {
+				deposit := depositRepo.Deposits["tx-1"]
+				assert.Equal(t, fireblocks.TransactionStatusCompleted, deposit.FireblocksStatus)
+				assert.Equal(t, model.DepositStatusPending, deposit.Status)
+			},
+		},
+		{
+			name: "completed_at_threshold_is_credited",
+			txs: []fireblocks.TransactionResponse{
+				incomingTx(fireblocks.TransactionStatusConfirming, 1),
+				incomingTx(fireblocks.TransactionStatusCompleted, 3),
+			},
+			assert: func(t *testing.T, depositRepo *MockDepositRepository, ledger *MockLedger) {
+				deposit := depositRepo.Deposits["tx-1"]
+				assert.Equal(t, model.DepositStatusCredited, deposit.Status)
+				assert.Equal(t, 3, deposit.Confirmations)
+				assert.NotNil(t, deposit.CreditedAt)
+				assert.Len(t, ledger.Posted, 1)
+				assert.Equal(t, "tx-1", ledger.Posted[0].FireblocksTxID)
+				assert.Equal(t, "wallet-1", ledger.Posted[0].WalletID)
+			},
+		},
+		{
+			name: "confirmations_never_decrease",
+			txs: []fireblocks.TransactionResponse{
+				incomingTx(fireblocks.TransactionStatusConfirming, 2),
+				incomingTx(fireblocks.TransactionStatusConfirming, 1),
+			},
+			assert: func(t *testing.T, depositRepo *MockDepositRepository, ledger *MockLedger) {
+				assert.Equal(t, 2, depositRepo.Deposits["tx-1"].Confirmations)
+			},
+		},
+		{
+			name: "failed_transaction",
+			txs:  []fireblocks.TransactionResponse{incomingTx(fireblocks.TransactionStatusFailed, 0)},
+			assert: func(t *testing.T, depositRepo *MockDepositRepository, ledger *MockLedger) {
+				assert.Equal(t, model.DepositStatusFailed, depositRepo.Deposits["tx-1"].Status)
+			},
+		},
+		{
+			name: "credited_deposit_is_final",
+			existing: &model.Deposit{
+				ID:                    "deposit-tx-1",
+				WalletID:              "wallet-1",
+				FireblocksTxID:        "tx-1",
+				Status:                model.DepositStatusCredited,
+				Confirmations:         3,
+				RequiredConfirmations: 3,
+			},
+			txs: []fireblocks.TransactionResponse{incomingTx(fireblocks.TransactionStatusFailed, 0)},
+			assert: func(t *testing.T, depositRepo *MockDepositRepository, ledger *MockLedger) {
+				assert.Equal(t, model.DepositStatusCredited, depositRepo.Deposits["tx-1"].Status)
+				assert.Equal(t, 0, depositRepo.SaveCalls)
+				assert.Empty(t, ledger.Posted)
+			},
+		},
+		{
+			name: "unknown_vault_is_ignored",
+			txs: []fireblocks.TransactionResponse{func() fireblocks.TransactionResponse {
+				tx := incomingTx(fireblocks.TransactionStatusCompleted, 3)
+				tx.Destination.ID = "999"
+				return tx
+			}()},
+			assert: func(t *testing.T, depositRepo *MockDepositRepository, ledger *MockLedger) {
+				assert.Empty(t, depositRepo.Deposits)
+			},
+		},
+		{
+			name: "transfer_between_wallets_is_ignored",
+			txs: []fireblocks.TransactionResponse{func() fireblocks.TransactionResponse {
+				tx := incomingTx(fireblocks.TransactionStatusCompleted, 3)
+				tx.Source = fireblocks.TransferPeerPath{Type: "VAULT_ACCOUNT", ID: "87"}
+				return tx
+			}()},
+			assert: func(t *testing.T, depositRepo *MockDepositRepository, ledger *MockLedger) {
+				assert.Empty(t, depositRepo.Deposits)
+				assert.Empty(t, ledger.Posted)
+			},
+		},
+		{
+			name: "transfer_from_vault_without_wallet_is_credited",
+			txs: []fireblocks.TransactionResponse{func() fireblocks.TransactionResponse {
+				tx := incomingTx(fireblocks.TransactionStatusCompleted, 3)
+				tx.Source = fireblocks.TransferPeerPath{Type: "VAULT_ACCOUNT", ID: "999"}
+				return tx
+			}()},
+			assert: func(t *testing.T, depositRepo *MockDepositRepository, ledger *MockLedger) {
+				assert.Equal(t, model.DepositStatusCredited, depositRepo.Deposits["tx-1"].Status)
+				assert.Len(t, ledger.Posted, 1)
+			},
+		},
+		{
+			name: "outgoing_transfer_is_ignored",
+			txs: []fireblocks.TransactionResponse{func() fireblocks.TransactionResponse {
+				tx := incomingTx(fireblocks.TransactionStatusCompleted, 3)
+				tx.Destination = fireblocks.TransferPeerPath{Type: "ONE_TIME_ADDRESS"}
+				return tx
+			}()},
+			assert: func(t *testing.T, depositRepo *MockDepositRepository, ledger *MockLedger) {
+				assert.Empty(t, depositRepo.Deposits)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			walletRepo := &MockWalletRepository{Wallets: map[string]*model.Wallet{
+				"86": {ID: "wallet-1", VaultAccountID: "86"},
+				"87": {ID: "wallet-2", VaultAccountID: "87"},
+			}}
+			depositRepo := &MockDepositRepository{Deposits: map[string]*model.Deposit{}}
+			if tt.existing != nil {
+				depositRepo.Deposits[tt.existing.FireblocksTxID] = tt.existing
+			}
+
+			ledger := &MockLedger{}
+			processor := NewProcessor(walletRepo, depositRepo, ledger, thresholds)
+			for _, tx := range tt.txs {
+				assert.NoError(t, processor.ProcessTransaction(context.Background(), tx))
+			}
+
+			tt.assert(t, depositRepo, ledger)
+		})
+	}
+}
+
+func TestProcessTransactionErrors(t *testing.T) {
+	walletRepo := &MockWalletRepository{Error: assert.AnError}
+	processor := NewProcessor(walletRepo, &MockDepositRepository{Deposits: map[string]*model.Deposit{}}, &MockLedger{}, ConfirmationThresholds{})
+	assert.ErrorIs(t, processor.ProcessTransaction(context.Background(), incomingTx(fireblocks.TransactionStatusCompleted, 1)), assert.AnError)
+
+	walletRepo = &MockWalletRepository{Wallets: map[string]*model.Wallet{"86": {ID: "wallet-1"}}}
+	depositRepo := &MockDepositRepository{Deposits: map[string]*model.Deposit{}, SaveError: assert.AnError}
+	processor = NewProcessor(walletRepo, depositRepo, &MockLedger{}, ConfirmationThresholds{})
+	assert.ErrorIs(t, processor.ProcessTransaction(context.Background(), incomingTx(fireblocks.TransactionStatusCompleted, 1)), assert.AnError)
+
+	// a failed posting leaves the deposit pending so the event is retried
+	depositRepo = &MockDepositRepository{Deposits: map[string]*model.Deposit{}}
+	processor = NewProcessor(walletRepo, depositRepo, &MockLedger{Error: assert.AnError}, ConfirmationThresholds{})
+	assert.ErrorIs(t, processor.ProcessTransaction(context.Background(), incomingTx(fireblocks.TransactionStatusCompleted, 1)), assert.AnError)
+	assert.Equal(t, 0, depositRepo.SaveCalls)
+}
+
+func TestParseConfirmationThresholds(t *testing.T) {
+	thresholds, err := ParseConfirmationThresholds("BTC_TEST=2, ETH_TEST5=12,", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, thresholds.For("BTC_TEST"))
+	assert.Equal(t, 12, thresholds.For("ETH_TEST5"))
+	assert.Equal(t, 1, thresholds.For("SOL_TEST"))
+
+	thresholds, err = ParseConfirmationThresholds("", 6)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, thresholds.For("BTC_TEST"))
+
+	_, err = ParseConfirmationThresholds("BTC_TEST", 1)
+	assert.Error(t, err)
+
+	_, err = ParseConfirmationThresholds("BTC_TEST=-1", 1)
+	assert.Error(t, err)
+}
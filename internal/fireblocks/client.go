@@ -2,76 +2,270 @@ package fireblocks
 
 import (
 	"bytes"
+	"context"
 	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/metrics"
+	"firego-wallet-service/internal/tracing"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
+var tracer = otel.Tracer("firego-wallet-service/internal/fireblocks")
+
 type Client struct {
-	baseURL    string
-	apiKey     string
-	privateKey *rsa.PrivateKey
-	httpClient *http.Client
+	baseURL     string
+	apiKey      string
+	privateKey  *rsa.PrivateKey
+	httpClient  *http.Client
+	retryPolicy RetryPolicy
+	limiter     *limiter
+}
+
+// RetryPolicy is how GET requests are retried after a network error, a 429 or a 5xx. Other requests are only
+// retried when sent with an idempotency key, see WithIdempotencyKey, as they may have been applied.
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt, 1 disables retries
+	MaxAttempts int
+	// InitialBackoff is doubled after every attempt, up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+type Option func(*Client)
+
+// WithTimeout sets the timeout of every HTTP request, 30 seconds by default
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithRetryPolicy enables retries, which are disabled by default
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey sets the Idempotency-Key of the POST requests made with the context. Fireblocks answers a request
+// sent again with the same key like it answered the first one instead of applying it twice, so the request is retried
+// like a GET request. The key must be derived from what the caller is doing, so that the caller can be run again too.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// IdempotencyKey is the key set by WithIdempotencyKey, or an empty string
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key
 }
 
-func NewClient(baseURL string, apiKey string, privateKey *rsa.PrivateKey) *Client {
-	return &Client{
-		baseURL:    baseURL,
-		apiKey:     apiKey,
-		privateKey: privateKey,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+func NewClient(baseURL string, apiKey string, privateKey *rsa.PrivateKey, opts ...Option) *Client {
+	c := &Client{
+		baseURL:     baseURL,
+		apiKey:      apiKey,
+		privateKey:  privateKey,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+		retryPolicy: RetryPolicy{MaxAttempts: 1},
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
-func (c *Client) CreateVaultAccount(req CreateVaultAccountRequest) (*CreateVaultAccountResponse, int, error) {
-	respBytes, statusCode, err := c.makeAPIRequest("POST", "/v1/vault/accounts", req)
+func (c *Client) CreateVaultAccount(ctx context.Context, req CreateVaultAccountRequest) (*CreateVaultAccountResponse, int, error) {
+	respBytes, statusCode, err := c.makeAPIRequest(ctx, "POST", "/v1/vault/accounts", req)
 	if err != nil {
-		return nil, 0, err
+		return nil, statusCode, err
 	}
 
 	return handleAPIResponse[CreateVaultAccountResponse](respBytes, statusCode)
 }
 
-func (c *Client) GetVaultAccountAssetBalance(vaultAccountID, assetID string) (*GetVaultAccountAssetBalanceResponse, int, error) {
+// CreateVaultAccountAsset creates the wallet of an asset in a vault account, which balances, addresses and
+// transactions of the asset require
+func (c *Client) CreateVaultAccountAsset(ctx context.Context, vaultAccountID, assetID string) (*CreateVaultAccountAssetResponse, int, error) {
+	path := fmt.Sprintf("/v1/vault/accounts/%s/%s", vaultAccountID, assetID)
+
+	respBytes, statusCode, err := c.makeAPIRequest(ctx, "POST", path, nil)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return handleAPIResponse[CreateVaultAccountAssetResponse](respBytes, statusCode)
+}
+
+func (c *Client) ListVaultAccounts(ctx context.Context, page PageRequest) (*ListVaultAccountsResponse, int, error) {
+	path := "/v1/vault/accounts_paged" + pageQuery(page)
+
+	respBytes, statusCode, err := c.makeAPIRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return handleAPIResponse[ListVaultAccountsResponse](respBytes, statusCode)
+}
+
+func (c *Client) GetVaultAccount(ctx context.Context, vaultAccountID string) (*VaultAccount, int, error) {
+	path := fmt.Sprintf("/v1/vault/accounts/%s", vaultAccountID)
+
+	respBytes, statusCode, err := c.makeAPIRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return handleAPIResponse[VaultAccount](respBytes, statusCode)
+}
+
+func (c *Client) GetVaultAccountAssetBalance(ctx context.Context, vaultAccountID, assetID string) (*GetVaultAccountAssetBalanceResponse, int, error) {
 	path := fmt.Sprintf("/v1/vault/accounts/%s/%s", vaultAccountID, assetID)
 
-	respBytes, statusCode, err := c.makeAPIRequest("GET", path, nil)
+	respBytes, statusCode, err := c.makeAPIRequest(ctx, "GET", path, nil)
 	if err != nil {
-		return nil, 0, err
+		return nil, statusCode, err
 	}
 
 	return handleAPIResponse[GetVaultAccountAssetBalanceResponse](respBytes, statusCode)
 }
 
-func (c *Client) GetVaultAccountAssetAddresses(vaultAccountID, assetID string) (*GetVaultAccountAssetAddressesResponse, int, error) {
-	path := fmt.Sprintf("/v1/vault/accounts/%s/%s/addresses_paginated", vaultAccountID, assetID)
+func (c *Client) GetVaultAccountAssetAddresses(ctx context.Context, vaultAccountID, assetID string) (*GetVaultAccountAssetAddressesResponse, int, error) {
+	return c.GetVaultAccountAssetAddressesPage(ctx, vaultAccountID, assetID, PageRequest{})
+}
+
+func (c *Client) GetVaultAccountAssetAddressesPage(ctx context.Context, vaultAccountID, assetID string, page PageRequest) (*GetVaultAccountAssetAddressesResponse, int, error) {
+	path := fmt.Sprintf("/v1/vault/accounts/%s/%s/addresses_paginated", vaultAccountID, assetID) + pageQuery(page)
 
-	respBytes, statusCode, err := c.makeAPIRequest("GET", path, nil)
+	respBytes, statusCode, err := c.makeAPIRequest(ctx, "GET", path, nil)
 	if err != nil {
-		return nil, 0, err
+		return nil, statusCode, err
 	}
 
 	return handleAPIResponse[GetVaultAccountAssetAddressesResponse](respBytes, statusCode)
 }
 
-func (c *Client) CreateTransaction(req CreateTransactionRequest) (*CreateTransactionResponse, int, error) {
+func (c *Client) CreateVaultAccountAssetAddress(ctx context.Context, vaultAccountID, assetID string, req CreateVaultAccountAssetAddressRequest) (*CreateVaultAccountAssetAddressResponse, int, error) {
+	path := fmt.Sprintf("/v1/vault/accounts/%s/%s/addresses", vaultAccountID, assetID)
+
+	respBytes, statusCode, err := c.makeAPIRequest(ctx, "POST", path, req)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return handleAPIResponse[CreateVaultAccountAssetAddressResponse](respBytes, statusCode)
+}
+
+func (c *Client) CreateTransaction(ctx context.Context, req CreateTransactionRequest) (*CreateTransactionResponse, int, error) {
 	path := "/v1/transactions"
 
-	respBytes, statusCode, err := c.makeAPIRequest("POST", path, req)
+	respBytes, statusCode, err := c.makeAPIRequest(ctx, "POST", path, req)
 	if err != nil {
-		return nil, 0, err
+		return nil, statusCode, err
 	}
 
 	return handleAPIResponse[CreateTransactionResponse](respBytes, statusCode)
 }
 
+func (c *Client) GetTransaction(ctx context.Context, txID string) (*TransactionResponse, int, error) {
+	path := fmt.Sprintf("/v1/transactions/%s", txID)
+
+	respBytes, statusCode, err := c.makeAPIRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return handleAPIResponse[TransactionResponse](respBytes, statusCode)
+}
+
+// GetTransactionByExternalID returns the transaction created with the external ID, 404 when there is none
+func (c *Client) GetTransactionByExternalID(ctx context.Context, externalTxID string) (*TransactionResponse, int, error) {
+	path := fmt.Sprintf("/v1/transactions/external_tx_id/%s", url.PathEscape(externalTxID))
+
+	respBytes, statusCode, err := c.makeAPIRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return handleAPIResponse[TransactionResponse](respBytes, statusCode)
+}
+
+// CancelTransaction asks Fireblocks to cancel a transaction that was not broadcast yet. The cancellation itself is
+// asynchronous, the transaction reaches CANCELLED afterwards.
+func (c *Client) CancelTransaction(ctx context.Context, txID string) (*CancelTransactionResponse, int, error) {
+	path := fmt.Sprintf("/v1/transactions/%s/cancel", txID)
+
+	respBytes, statusCode, err := c.makeAPIRequest(ctx, "POST", path, nil)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return handleAPIResponse[CancelTransactionResponse](respBytes, statusCode)
+}
+
+func (c *Client) ListTransactions(ctx context.Context, req ListTransactionsRequest) ([]TransactionResponse, int, error) {
+	query := url.Values{}
+	if req.After > 0 {
+		query.Set("after", strconv.FormatInt(req.After, 10))
+	}
+	if req.Before > 0 {
+		query.Set("before", strconv.FormatInt(req.Before, 10))
+	}
+	if req.Status != "" {
+		query.Set("status", req.Status)
+	}
+	if req.DestType != "" {
+		query.Set("destType", req.DestType)
+	}
+	if req.DestID != "" {
+		query.Set("destId", req.DestID)
+	}
+	if req.OrderBy != "" {
+		query.Set("orderBy", req.OrderBy)
+	}
+	if req.Sort != "" {
+		query.Set("sort", req.Sort)
+	}
+	if req.Limit > 0 {
+		query.Set("limit", strconv.Itoa(req.Limit))
+	}
+
+	path := "/v1/transactions"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	respBytes, statusCode, err := c.makeAPIRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	resp, statusCode, err := handleAPIResponse[[]TransactionResponse](respBytes, statusCode)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return *resp, statusCode, nil
+}
+
 func NewVaultTransferRequest(assetID, vaultAccountID, destinationAddress, amount, note string) CreateTransactionRequest {
 	return CreateTransactionRequest{
 		Operation: "TRANSFER",
@@ -91,16 +285,92 @@ func NewVaultTransferRequest(assetID, vaultAccountID, destinationAddress, amount
 	}
 }
 
-// GetAccountsPaged is used for testing only
-func (c *Client) GetAccountsPaged() ([]byte, error) {
-	path := "/v1/vault/accounts_paged"
-	resp, _, err := c.makeAPIRequest("GET", path, nil)
-	return resp, err
+// NewVaultToVaultTransferRequest moves funds between two vault accounts of the workspace
+func NewVaultToVaultTransferRequest(assetID, sourceVaultAccountID, destinationVaultAccountID, amount, note string) CreateTransactionRequest {
+	return CreateTransactionRequest{
+		Operation: "TRANSFER",
+		AssetID:   assetID,
+		Source: TransactionSource{
+			Type: "VAULT_ACCOUNT",
+			ID:   sourceVaultAccountID,
+		},
+		Destination: TransactionDestination{
+			Type: "VAULT_ACCOUNT",
+			ID:   destinationVaultAccountID,
+		},
+		Amount: amount,
+		Note:   note,
+	}
 }
 
-func (c *Client) makeAPIRequest(method, path string, body interface{}) ([]byte, int, error) {
-	url := c.baseURL + path
+// pageQuery renders the cursor parameters of a paginated endpoint as a query string
+func pageQuery(page PageRequest) string {
+	query := url.Values{}
+	if page.Before != "" {
+		query.Set("before", page.Before)
+	}
+	if page.After != "" {
+		query.Set("after", page.After)
+	}
+	if page.Limit > 0 {
+		query.Set("limit", strconv.Itoa(page.Limit))
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+func (c *Client) makeAPIRequest(ctx context.Context, method, path string, body interface{}) ([]byte, int, error) {
+	requestURL := c.baseURL + path
+
+	// headers and bodies are left out of the span, they carry the API key, the JWT and destination addresses
+	endpoint, attributes := parseEndpoint(path)
+	ctx, span := tracer.Start(ctx, "fireblocks "+method+" "+endpoint, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		append(attributes, semconv.HTTPRequestMethodKey.String(method), semconv.URLTemplate(endpoint))...,
+	))
+	defer span.End()
+
+	var respBodyBytes []byte
+	var statusCode int
+	var err error
+	backoff := c.retryPolicy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		if err = c.waitForLimiter(ctx, span, method); err != nil {
+			// answered like Fireblocks answers a request over its rate limit, unless the caller gave up waiting
+			statusCode = 0
+			if errors.Is(err, ErrRateLimited) {
+				statusCode = http.StatusTooManyRequests
+			}
+			break
+		}
+		respBodyBytes, statusCode, err = c.doAPIRequest(ctx, method, requestURL, path, endpoint, body)
+		if attempt >= c.retryPolicy.MaxAttempts || !retryable(method, IdempotencyKey(ctx) != "", statusCode, err) {
+			break
+		}
+
+		span.AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt), semconv.HTTPResponseStatusCode(statusCode)))
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, c.retryPolicy.MaxBackoff)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, statusCode, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(statusCode))
+	if statusCode >= 400 {
+		span.SetStatus(codes.Error, http.StatusText(statusCode))
+	}
+	return respBodyBytes, statusCode, nil
+}
 
+func (c *Client) doAPIRequest(ctx context.Context, method, requestURL, path, endpoint string, body interface{}) ([]byte, int, error) {
 	var reqBodyBytes []byte
 	if body != nil {
 		var err error
@@ -115,24 +385,31 @@ func (c *Client) makeAPIRequest(method, path string, body interface{}) ([]byte,
 		return nil, 0, fmt.Errorf("failed to sign JWT: %w", err)
 	}
 
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(reqBodyBytes))
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, bytes.NewBuffer(reqBodyBytes))
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
 	if method == http.MethodPost {
 		req.Header.Set("Content-Type", "application/json")
+		if key := IdempotencyKey(ctx); key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
 	}
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	req.Header.Set("X-API-KEY", c.apiKey)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		metrics.ObserveFireblocksRequest(method, endpoint, 0, time.Since(start))
 		return nil, 0, fmt.Errorf("failed to execute HTTP request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBodyBytes, err := io.ReadAll(resp.Body)
+	metrics.ObserveFireblocksRequest(method, endpoint, resp.StatusCode, time.Since(start))
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to read response body: %w", err)
 	}
@@ -140,6 +417,49 @@ func (c *Client) makeAPIRequest(method, path string, body interface{}) ([]byte,
 	return respBodyBytes, resp.StatusCode, nil
 }
 
+// waitForLimiter waits for the rate limiter, if any, recording the wait on the span
+func (c *Client) waitForLimiter(ctx context.Context, span trace.Span, method string) error {
+	if c.limiter == nil {
+		return nil
+	}
+	waited, err := c.limiter.wait(ctx, method)
+	if waited >= time.Millisecond {
+		span.AddEvent("rate_limited", trace.WithAttributes(attribute.Int64("wait_ms", waited.Milliseconds())))
+	}
+	return err
+}
+
+// retryable tells whether a request may be sent again: it must be idempotent, a GET or sent with an idempotency key,
+// and have failed transiently
+func retryable(method string, hasIdempotencyKey bool, statusCode int, err error) bool {
+	if method != http.MethodGet && !hasIdempotencyKey {
+		return false
+	}
+	return err != nil || statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// parseEndpoint turns a request path into its template, e.g. /v1/vault/accounts/{vaultAccountId}/{assetId}/balance,
+// so that metrics and spans are aggregated per endpoint, and returns the IDs it replaced as span attributes
+func parseEndpoint(path string) (string, []attribute.KeyValue) {
+	path, _, _ = strings.Cut(path, "?")
+	segments := strings.Split(path, "/")
+	var attributes []attribute.KeyValue
+	for i := 1; i < len(segments); i++ {
+		switch segments[i-1] {
+		case "accounts":
+			attributes = append(attributes, tracing.VaultAccountID.String(segments[i]))
+			segments[i] = "{vaultAccountId}"
+		case "{vaultAccountId}":
+			attributes = append(attributes, tracing.AssetID.String(segments[i]))
+			segments[i] = "{assetId}"
+		case "transactions":
+			attributes = append(attributes, tracing.TransactionID.String(segments[i]))
+			segments[i] = "{txId}"
+		}
+	}
+	return strings.Join(segments, "/"), attributes
+}
+
 func (c *Client) signJWT(uri string, bodyBytes []byte) (string, error) {
 	nonce := uuid.New().String()
 	now := time.Now().Unix()
@@ -167,6 +487,13 @@ func (c *Client) signJWT(uri string, bodyBytes []byte) (string, error) {
 	return tokenString, nil
 }
 
+// Rejected reports whether the status code of a failed request means that Fireblocks refused it for good. Requests
+// failing without a response, with a 5xx, a 429 or a 409, the request with the same idempotency key still being
+// handled, may succeed when sent again later.
+func Rejected(statusCode int) bool {
+	return statusCode >= 400 && statusCode < 500 && statusCode != http.StatusTooManyRequests && statusCode != http.StatusConflict
+}
+
 // handleAPIResponse processes the Fireblocks API response, handling both success and error cases
 func handleAPIResponse[T any](respBytes []byte, statusCode int) (*T, int, error) {
 	if statusCode == http.StatusOK {
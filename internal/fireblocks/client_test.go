@@ -1,15 +1,23 @@
 package fireblocks
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/json"
 	"errors"
+	"firego-wallet-service/internal/tracing"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCreateVaultAccount(t *testing.T) {
@@ -95,7 +103,7 @@ func TestCreateVaultAccount(t *testing.T) {
 			assert.NoError(t, err)
 
 			client := NewClient(server.URL, "test-api-key", testPrivateKey)
-			resp, statusCode, err := client.CreateVaultAccount(CreateVaultAccountRequest{Name: "Test"})
+			resp, statusCode, err := client.CreateVaultAccount(context.Background(), CreateVaultAccountRequest{Name: "Test"})
 
 			tt.assert(t, resp, statusCode, err)
 		})
@@ -258,7 +266,7 @@ func TestGetVaultAccountBalanceAsset(t *testing.T) {
 			assert.NoError(t, err)
 
 			client := NewClient(server.URL, "test-api-key", testPrivateKey)
-			resp, statusCode, err := client.GetVaultAccountAssetBalance(tt.vaultAccountID, tt.assetID)
+			resp, statusCode, err := client.GetVaultAccountAssetBalance(context.Background(), tt.vaultAccountID, tt.assetID)
 
 			tt.assert(t, resp, statusCode, err)
 		})
@@ -441,7 +449,7 @@ func TestGetVaultAccountAssetAddresses(t *testing.T) {
 			assert.NoError(t, err)
 
 			client := NewClient(server.URL, "test-api-key", testPrivateKey)
-			resp, statusCode, err := client.GetVaultAccountAssetAddresses(tt.vaultAccountID, tt.assetID)
+			resp, statusCode, err := client.GetVaultAccountAssetAddresses(context.Background(), tt.vaultAccountID, tt.assetID)
 
 			tt.assert(t, resp, statusCode, err)
 		})
@@ -642,9 +650,554 @@ func TestCreateTransaction(t *testing.T) {
 			assert.NoError(t, err)
 
 			client := NewClient(server.URL, "test-api-key", testPrivateKey)
-			resp, statusCode, err := client.CreateTransaction(tt.request)
+			resp, statusCode, err := client.CreateTransaction(context.Background(), tt.request)
 
 			tt.assert(t, resp, statusCode, err)
 		})
 	}
 }
+
+func TestGetTransaction(t *testing.T) {
+	tests := []struct {
+		name      string
+		txID      string
+		mockSetup func(txID string) *httptest.Server
+		assert    func(t *testing.T, resp *TransactionResponse, statusCode int, err error)
+	}{
+		{
+			name: "success",
+			txID: "eff51bfd-8cec-4b77-b01e-b1aff84dcf49",
+			mockSetup: func(txID string) *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, http.MethodGet, r.Method)
+					assert.Equal(t, fmt.Sprintf("/v1/transactions/%s", txID), r.URL.Path)
+					assert.NotEmpty(t, r.Header.Get("X-API-Key"))
+					assert.NotEmpty(t, r.Header.Get("Authorization"))
+
+					w.WriteHeader(http.StatusOK)
+					json.NewEncoder(w).Encode(TransactionResponse{
+						ID:                 txID,
+						Status:             TransactionStatusCompleted,
+						AssetID:            "BTC_TEST",
+						Destination:        TransferPeerPath{Type: "VAULT_ACCOUNT", ID: "86"},
+						AmountInfo:         AmountInfo{Amount: "0.0001"},
+						NumOfConfirmations: 3,
+					})
+				}))
+			},
+			assert: func(t *testing.T, resp *TransactionResponse, statusCode int, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, statusCode)
+				assert.NotNil(t, resp)
+				assert.Equal(t, TransactionStatusCompleted, resp.Status)
+				assert.Equal(t, "86", resp.Destination.ID)
+				assert.Equal(t, "0.0001", resp.AmountInfo.Amount)
+				assert.Equal(t, 3, resp.NumOfConfirmations)
+			},
+		},
+		{
+			name: "not_found",
+			txID: "unknown",
+			mockSetup: func(txID string) *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusNotFound)
+					json.NewEncoder(w).Encode(ErrorResponse{Code: 1006, Message: "Not found"})
+				}))
+			},
+			assert: func(t *testing.T, resp *TransactionResponse, statusCode int, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, http.StatusNotFound, statusCode)
+				assert.Nil(t, resp)
+
+				var fbErr ErrorResponse
+				assert.True(t, errors.As(err, &fbErr))
+				assert.Equal(t, 1006, fbErr.Code)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := tt.mockSetup(tt.txID)
+			defer server.Close()
+
+			testPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			assert.NoError(t, err)
+
+			client := NewClient(server.URL, "test-api-key", testPrivateKey)
+			resp, statusCode, err := client.GetTransaction(context.Background(), tt.txID)
+
+			tt.assert(t, resp, statusCode, err)
+		})
+	}
+}
+
+func TestListTransactions(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   ListTransactionsRequest
+		mockSetup func() *httptest.Server
+		assert    func(t *testing.T, resp []TransactionResponse, statusCode int, err error)
+	}{
+		{
+			name: "success_with_filters",
+			request: ListTransactionsRequest{
+				After:    1700000000000,
+				DestType: "VAULT_ACCOUNT",
+				OrderBy:  "createdAt",
+				Sort:     "ASC",
+				Limit:    500,
+			},
+			mockSetup: func() *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, http.MethodGet, r.Method)
+					assert.Equal(t, "/v1/transactions", r.URL.Path)
+					assert.Equal(t, "1700000000000", r.URL.Query().Get("after"))
+					assert.Equal(t, "VAULT_ACCOUNT", r.URL.Query().Get("destType"))
+					assert.Equal(t, "createdAt", r.URL.Query().Get("orderBy"))
+					assert.Equal(t, "ASC", r.URL.Query().Get("sort"))
+					assert.Equal(t, "500", r.URL.Query().Get("limit"))
+					assert.Empty(t, r.URL.Query().Get("status"))
+
+					w.WriteHeader(http.StatusOK)
+					json.NewEncoder(w).Encode([]TransactionResponse{
+						{ID: "tx-1", Status: TransactionStatusConfirming},
+						{ID: "tx-2", Status: TransactionStatusCompleted},
+					})
+				}))
+			},
+			assert: func(t *testing.T, resp []TransactionResponse, statusCode int, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, statusCode)
+				assert.Len(t, resp, 2)
+				assert.Equal(t, "tx-1", resp[0].ID)
+				assert.Equal(t, TransactionStatusCompleted, resp[1].Status)
+			},
+		},
+		{
+			name: "fireblocks_error_unauthorized",
+			mockSetup: func() *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					assert.Empty(t, r.URL.RawQuery)
+
+					w.WriteHeader(http.StatusUnauthorized)
+					json.NewEncoder(w).Encode(ErrorResponse{Code: -3, Message: "Unauthorized"})
+				}))
+			},
+			assert: func(t *testing.T, resp []TransactionResponse, statusCode int, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, http.StatusUnauthorized, statusCode)
+				assert.Nil(t, resp)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := tt.mockSetup()
+			defer server.Close()
+
+			testPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			assert.NoError(t, err)
+
+			client := NewClient(server.URL, "test-api-key", testPrivateKey)
+			resp, statusCode, err := client.ListTransactions(context.Background(), tt.request)
+
+			tt.assert(t, resp, statusCode, err)
+		})
+	}
+}
+
+func TestGetVaultAccountAssetAddressesPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v1/vault/accounts/123/BTC_TEST/addresses_paginated", r.URL.Path)
+		assert.Equal(t, "cursor", r.URL.Query().Get("after"))
+		assert.Equal(t, "50", r.URL.Query().Get("limit"))
+		assert.Empty(t, r.URL.Query().Get("before"))
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(GetVaultAccountAssetAddressesResponse{
+			Addresses: []VaultAccountAddress{{AssetID: "BTC_TEST", Address: "tb1qaddress", CustomerRefID: "customer-7"}},
+			Paging:    Paging{After: "next-cursor"},
+		})
+	}))
+	defer server.Close()
+
+	testPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	client := NewClient(server.URL, "test-api-key", testPrivateKey)
+	resp, statusCode, err := client.GetVaultAccountAssetAddressesPage(context.Background(), "123", "BTC_TEST", PageRequest{After: "cursor", Limit: 50})
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Len(t, resp.Addresses, 1)
+	assert.Equal(t, "customer-7", resp.Addresses[0].CustomerRefID)
+	assert.Equal(t, "next-cursor", resp.Paging.After)
+}
+
+func TestCreateVaultAccountAssetAddress(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   CreateVaultAccountAssetAddressRequest
+		mockSetup func() *httptest.Server
+		assert    func(t *testing.T, resp *CreateVaultAccountAssetAddressResponse, statusCode int, err error)
+	}{
+		{
+			name:    "success",
+			request: CreateVaultAccountAssetAddressRequest{Description: "Invoice 42", CustomerRefID: "customer-7"},
+			mockSetup: func() *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, http.MethodPost, r.Method)
+					assert.Equal(t, "/v1/vault/accounts/123/BTC_TEST/addresses", r.URL.Path)
+					assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+					var receivedReq CreateVaultAccountAssetAddressRequest
+					err := json.NewDecoder(r.Body).Decode(&receivedReq)
+					assert.NoError(t, err)
+					assert.Equal(t, "Invoice 42", receivedReq.Description)
+					assert.Equal(t, "customer-7", receivedReq.CustomerRefID)
+
+					w.WriteHeader(http.StatusOK)
+					json.NewEncoder(w).Encode(CreateVaultAccountAssetAddressResponse{
+						Address:           "tb1qnewaddress",
+						Bip44AddressIndex: 3,
+					})
+				}))
+			},
+			assert: func(t *testing.T, resp *CreateVaultAccountAssetAddressResponse, statusCode int, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, statusCode)
+				assert.Equal(t, "tb1qnewaddress", resp.Address)
+				assert.Equal(t, 3, resp.Bip44AddressIndex)
+			},
+		},
+		{
+			name: "asset_not_found",
+			mockSetup: func() *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusNotFound)
+					json.NewEncoder(w).Encode(ErrorResponse{Code: 1006, Message: "Not found"})
+				}))
+			},
+			assert: func(t *testing.T, resp *CreateVaultAccountAssetAddressResponse, statusCode int, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, http.StatusNotFound, statusCode)
+				assert.Nil(t, resp)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := tt.mockSetup()
+			defer server.Close()
+
+			testPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			assert.NoError(t, err)
+
+			client := NewClient(server.URL, "test-api-key", testPrivateKey)
+			resp, statusCode, err := client.CreateVaultAccountAssetAddress(context.Background(), "123", "BTC_TEST", tt.request)
+
+			tt.assert(t, resp, statusCode, err)
+		})
+	}
+}
+
+func TestListVaultAccounts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v1/vault/accounts_paged", r.URL.Path)
+		assert.Equal(t, "cursor", r.URL.Query().Get("after"))
+		assert.Equal(t, "500", r.URL.Query().Get("limit"))
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(ListVaultAccountsResponse{
+			Accounts: []VaultAccount{{ID: "86", Name: "Test Wallet"}},
+			Paging:   Paging{After: "next-cursor"},
+		})
+	}))
+	defer server.Close()
+
+	testPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	client := NewClient(server.URL, "test-api-key", testPrivateKey)
+	resp, statusCode, err := client.ListVaultAccounts(context.Background(), PageRequest{After: "cursor", Limit: 500})
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Len(t, resp.Accounts, 1)
+	assert.Equal(t, "86", resp.Accounts[0].ID)
+	assert.Equal(t, "next-cursor", resp.Paging.After)
+}
+
+func TestCreateVaultAccountAsset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/vault/accounts/86/BTC_TEST", r.URL.Path)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(CreateVaultAccountAssetResponse{ID: "BTC_TEST", Address: "tb1qaddress"})
+	}))
+	defer server.Close()
+
+	testPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	client := NewClient(server.URL, "test-api-key", testPrivateKey)
+	resp, statusCode, err := client.CreateVaultAccountAsset(context.Background(), "86", "BTC_TEST")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "BTC_TEST", resp.ID)
+	assert.Equal(t, "tb1qaddress", resp.Address)
+}
+
+func TestGetVaultAccount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v1/vault/accounts/86", r.URL.Path)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(VaultAccount{
+			ID:     "86",
+			Name:   "Test Wallet",
+			Assets: []VaultAccountAsset{{ID: "BTC_TEST", Total: "1.5", Available: "1"}},
+		})
+	}))
+	defer server.Close()
+
+	testPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	client := NewClient(server.URL, "test-api-key", testPrivateKey)
+	resp, statusCode, err := client.GetVaultAccount(context.Background(), "86")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "Test Wallet", resp.Name)
+	assert.Len(t, resp.Assets, 1)
+	assert.Equal(t, "1.5", resp.Assets[0].Total)
+}
+
+func TestCancelTransaction(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		assert     func(t *testing.T, resp *CancelTransactionResponse, statusCode int, err error)
+	}{
+		{
+			name:       "cancelled",
+			statusCode: http.StatusOK,
+			body:       `{"success": true}`,
+			assert: func(t *testing.T, resp *CancelTransactionResponse, statusCode int, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, statusCode)
+				assert.True(t, resp.Success)
+			},
+		},
+		{
+			name:       "already_broadcast",
+			statusCode: http.StatusBadRequest,
+			body:       `{"message": "Transaction cannot be cancelled", "code": 1404}`,
+			assert: func(t *testing.T, resp *CancelTransactionResponse, statusCode int, err error) {
+				assert.Nil(t, resp)
+				assert.Equal(t, http.StatusBadRequest, statusCode)
+				assert.ErrorContains(t, err, "Transaction cannot be cancelled")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "/v1/transactions/tx-1/cancel", r.URL.Path)
+
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			testPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			assert.NoError(t, err)
+
+			client := NewClient(server.URL, "test-api-key", testPrivateKey)
+			resp, statusCode, err := client.CancelTransaction(context.Background(), "tx-1")
+
+			tt.assert(t, resp, statusCode, err)
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		idempotencyKey   string
+		statusCodes      []int
+		expectedStatus   int
+		expectedAttempts int
+	}{
+		{name: "get_retried_until_success", method: http.MethodGet, statusCodes: []int{503, 429, 200}, expectedStatus: 200, expectedAttempts: 3},
+		{name: "get_gives_up_after_max_attempts", method: http.MethodGet, statusCodes: []int{500, 500, 500, 200}, expectedStatus: 500, expectedAttempts: 3},
+		{name: "get_client_error_not_retried", method: http.MethodGet, statusCodes: []int{404, 200}, expectedStatus: 404, expectedAttempts: 1},
+		{name: "post_not_retried", method: http.MethodPost, statusCodes: []int{503, 200}, expectedStatus: 503, expectedAttempts: 1},
+		{name: "post_with_idempotency_key_retried", method: http.MethodPost, idempotencyKey: "payout-1", statusCodes: []int{503, 200}, expectedStatus: 200, expectedAttempts: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tt.idempotencyKey, r.Header.Get("Idempotency-Key"))
+				w.WriteHeader(tt.statusCodes[attempts])
+				attempts++
+				w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+
+			testPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			assert.NoError(t, err)
+
+			client := NewClient(server.URL, "test-api-key", testPrivateKey, WithRetryPolicy(RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     2 * time.Millisecond,
+			}))
+			ctx := context.Background()
+			if tt.idempotencyKey != "" {
+				ctx = WithIdempotencyKey(ctx, tt.idempotencyKey)
+			}
+			_, statusCode, err := client.makeAPIRequest(ctx, tt.method, "/v1/transactions", nil)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, statusCode)
+			assert.Equal(t, tt.expectedAttempts, attempts)
+		})
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	testPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	// a single token, refilled every 50ms for reads and never in practice for writes
+	client := NewClient(server.URL, "test-api-key", testPrivateKey, WithRateLimits(RateLimits{
+		Read:    RateLimit{Rate: 20, Burst: 1},
+		Write:   RateLimit{Rate: 0.001, Burst: 1},
+		MaxWait: time.Second,
+	}))
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 3 {
+		_, statusCode, err := client.makeAPIRequest(ctx, http.MethodGet, "/v1/transactions", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "reads queue for the limiter")
+
+	_, _, err = client.makeAPIRequest(ctx, http.MethodPost, "/v1/transactions", nil)
+	assert.NoError(t, err, "writes have their own bucket")
+
+	start = time.Now()
+	_, statusCode, err := client.makeAPIRequest(ctx, http.MethodPost, "/v1/transactions", nil)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, http.StatusTooManyRequests, statusCode)
+	assert.Less(t, time.Since(start), 100*time.Millisecond, "a request that cannot make it fails right away")
+	assert.Equal(t, 4, requests)
+
+	// a caller giving up while its request waits is not rate limited
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, statusCode, err = client.makeAPIRequest(cancelled, http.MethodGet, "/v1/transactions", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ErrRateLimited)
+	assert.Zero(t, statusCode)
+	assert.Equal(t, 4, requests)
+}
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/v1/vault/accounts", "/v1/vault/accounts"},
+		{"/v1/vault/accounts_paged?limit=500", "/v1/vault/accounts_paged"},
+		{"/v1/vault/accounts/123/BTC_TEST", "/v1/vault/accounts/{vaultAccountId}/{assetId}"},
+		{"/v1/vault/accounts/123/BTC_TEST/addresses_paginated?after=x", "/v1/vault/accounts/{vaultAccountId}/{assetId}/addresses_paginated"},
+		{"/v1/transactions", "/v1/transactions"},
+		{"/v1/transactions/tx-1", "/v1/transactions/{txId}"},
+		{"/v1/transactions/tx-1/cancel", "/v1/transactions/{txId}/cancel"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			endpoint, _ := parseEndpoint(tt.path)
+			assert.Equal(t, tt.want, endpoint)
+		})
+	}
+}
+
+func TestRejected(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		want       bool
+	}{
+		{"no_response", 0, false},
+		{"bad_request", http.StatusBadRequest, true},
+		{"not_found", http.StatusNotFound, true},
+		{"conflict", http.StatusConflict, false},
+		{"rate_limited", http.StatusTooManyRequests, false},
+		{"internal_error", http.StatusInternalServerError, false},
+		{"service_unavailable", http.StatusServiceUnavailable, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Rejected(tt.statusCode))
+		})
+	}
+}
+
+func TestMakeAPIRequestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("traceparent"), "00-"))
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(TransactionResponse{ID: "tx-1", Status: TransactionStatusCompleted})
+	}))
+	defer server.Close()
+
+	testPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	client := NewClient(server.URL, "test-api-key", testPrivateKey)
+	_, _, err = client.GetTransaction(context.Background(), "tx-1")
+	assert.NoError(t, err)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "fireblocks GET /v1/transactions/{txId}", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), tracing.TransactionID.String("tx-1"))
+	for _, attr := range spans[0].Attributes() {
+		assert.NotContains(t, attr.Value.Emit(), "test-api-key")
+	}
+}
@@ -0,0 +1,97 @@
+package fireblockstest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxTokenLifetime is how far apart the iat and exp claims may be, Fireblocks rejects longer-lived tokens
+const maxTokenLifetime = 30 * time.Second
+
+// maxBodySize bounds the request bodies, which are read whole to be hashed
+const maxBodySize = 1 << 20
+
+// authenticate checks the API key and the JWT of a request before passing it to handler. The JWT must be signed with
+// RS256 by the key of the API key, be valid for at most 30 seconds, carry a nonce that was not used before, the URI
+// of the request, path and query, and the hex encoded SHA-256 of its body.
+func (s *Server) authenticate(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if err = s.verifyRequest(r, body); err != nil {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "Unauthorized: "+err.Error())
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *Server) verifyRequest(r *http.Request, body []byte) error {
+	apiKey := r.Header.Get("X-API-KEY")
+	if apiKey == "" || apiKey != s.config.APIKey {
+		return errors.New("unknown API key")
+	}
+
+	tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return errors.New("missing bearer token")
+	}
+
+	var claims struct {
+		jwt.RegisteredClaims
+		URI      string `json:"uri"`
+		Nonce    string `json:"nonce"`
+		BodyHash string `json:"bodyHash"`
+	}
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(*jwt.Token) (any, error) {
+		return s.config.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithExpirationRequired(), jwt.WithIssuedAt())
+	if err != nil {
+		return fmt.Errorf("invalid token: %w", err)
+	}
+
+	if claims.Subject != apiKey {
+		return errors.New("token subject does not match the API key")
+	}
+	if claims.IssuedAt == nil || claims.ExpiresAt.Sub(claims.IssuedAt.Time) > maxTokenLifetime {
+		return fmt.Errorf("token must expire within %s of being issued", maxTokenLifetime)
+	}
+	if claims.URI != r.URL.RequestURI() {
+		return errors.New("token uri does not match the request")
+	}
+	sum := sha256.Sum256(body)
+	if claims.BodyHash != hex.EncodeToString(sum[:]) {
+		return errors.New("token bodyHash does not match the request body")
+	}
+	if claims.Nonce == "" {
+		return errors.New("missing token nonce")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for nonce, expiresAt := range s.nonces {
+		if now.After(expiresAt) {
+			delete(s.nonces, nonce)
+		}
+	}
+	// nonces are remembered until their token expires, after which the token is rejected anyway
+	if _, used := s.nonces[claims.Nonce]; used {
+		return errors.New("token nonce was already used")
+	}
+	s.nonces[claims.Nonce] = claims.ExpiresAt.Time
+	return nil
+}
@@ -0,0 +1,364 @@
+// Package fireblockstest implements an in-memory Fireblocks API, for development and tests without a testnet
+// workspace. It serves the endpoints used by fireblocks.Client, authenticating requests the way Fireblocks does, walks
+// transactions through their statuses and delivers signed webhooks.
+package fireblockstest
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"firego-wallet-service/internal/fireblocks"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Error codes returned by the simulator
+const (
+	codeUnauthorized   = -7
+	codeInvalidRequest = 1000
+	codeNotFound       = 1004
+)
+
+const (
+	defaultPageSize = 200
+	maxPageSize     = 500
+)
+
+type Config struct {
+	// APIKey and PublicKey authenticate the requests, PublicKey being the public part of the key signing the JWTs
+	APIKey    string
+	PublicKey *rsa.PublicKey
+	// WebhookURL receives the transaction events, signed with WebhookKey. Webhooks are not sent when it is empty.
+	WebhookURL string
+	WebhookKey *rsa.PrivateKey
+	// Confirmations is how many steps a transaction spends confirming before it completes, 1 if not set
+	Confirmations int
+}
+
+// Server is a Fireblocks workspace kept in memory. It is safe for concurrent use.
+type Server struct {
+	config  Config
+	mux     *http.ServeMux
+	webhook *http.Client
+
+	mu           sync.Mutex
+	nonces       map[string]time.Time
+	vaults       []*vault
+	transactions []*transaction
+	lastCreated  int64
+	// idempotencyKeys are the Idempotency-Keys of the created transactions, with their IDs
+	idempotencyKeys map[string]string
+}
+
+type vault struct {
+	id     string
+	name   string
+	assets map[string]*asset
+}
+
+type asset struct {
+	total     *big.Rat
+	locked    *big.Rat
+	addresses []fireblocks.VaultAccountAddress
+}
+
+func NewServer(config Config) *Server {
+	if config.Confirmations <= 0 {
+		config.Confirmations = 1
+	}
+	s := &Server{
+		config:  config,
+		mux:     http.NewServeMux(),
+		webhook: &http.Client{Timeout: 10 * time.Second},
+		nonces:  map[string]time.Time{},
+
+		idempotencyKeys: map[string]string{},
+	}
+
+	s.mux.HandleFunc("POST /v1/vault/accounts", s.authenticate(s.createVaultAccount))
+	s.mux.HandleFunc("GET /v1/vault/accounts_paged", s.authenticate(s.listVaultAccounts))
+	s.mux.HandleFunc("GET /v1/vault/accounts/{vaultAccountId}", s.authenticate(s.getVaultAccount))
+	s.mux.HandleFunc("POST /v1/vault/accounts/{vaultAccountId}/{assetId}", s.authenticate(s.createVaultAsset))
+	s.mux.HandleFunc("GET /v1/vault/accounts/{vaultAccountId}/{assetId}", s.authenticate(s.getVaultAssetBalance))
+	s.mux.HandleFunc("GET /v1/vault/accounts/{vaultAccountId}/{assetId}/addresses_paginated", s.authenticate(s.listAddresses))
+	s.mux.HandleFunc("POST /v1/vault/accounts/{vaultAccountId}/{assetId}/addresses", s.authenticate(s.createAddress))
+	s.mux.HandleFunc("POST /v1/transactions", s.authenticate(s.createTransaction))
+	s.mux.HandleFunc("GET /v1/transactions", s.authenticate(s.listTransactions))
+	s.mux.HandleFunc("GET /v1/transactions/{txId}", s.authenticate(s.getTransaction))
+	s.mux.HandleFunc("GET /v1/transactions/external_tx_id/{externalTxId}", s.authenticate(s.getTransactionByExternalID))
+	s.mux.HandleFunc("POST /v1/transactions/{txId}/cancel", s.authenticate(s.cancelTransaction))
+
+	// the simulator controls stand in for faucets, block explorers and the console, they are not authenticated
+	s.mux.HandleFunc("POST /sim/deposits", s.simulateDeposit)
+	s.mux.HandleFunc("POST /sim/advance", s.simulateAdvance)
+	s.mux.HandleFunc("POST /sim/transactions/{txId}/fail", s.simulateFailure)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) createVaultAccount(w http.ResponseWriter, r *http.Request) {
+	var req fireblocks.CreateVaultAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "name is required")
+		return
+	}
+
+	s.mu.Lock()
+	v := &vault{id: strconv.Itoa(len(s.vaults)), name: req.Name, assets: map[string]*asset{}}
+	s.vaults = append(s.vaults, v)
+	s.mu.Unlock()
+
+	writeJSON(w, fireblocks.CreateVaultAccountResponse{ID: v.id, Name: v.name})
+}
+
+func (s *Server) listVaultAccounts(w http.ResponseWriter, r *http.Request) {
+	limit, err := pageLimit(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// vault account IDs are their positions, so the cursors are positions too
+	start, end := pageBounds(len(s.vaults), r.URL.Query().Get("before"), r.URL.Query().Get("after"), limit)
+	resp := fireblocks.ListVaultAccountsResponse{Accounts: []fireblocks.VaultAccount{}}
+	for _, v := range s.vaults[start:end] {
+		resp.Accounts = append(resp.Accounts, v.toResponse())
+	}
+	resp.Paging = paging(start, end, len(s.vaults))
+	writeJSON(w, resp)
+}
+
+func (s *Server) getVaultAccount(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	v := s.vault(r.PathValue("vaultAccountId"))
+	var resp fireblocks.VaultAccount
+	if v != nil {
+		resp = v.toResponse()
+	}
+	s.mu.Unlock()
+
+	if v == nil {
+		writeError(w, http.StatusNotFound, codeNotFound, "vault account not found")
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) createVaultAsset(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	v := s.vault(r.PathValue("vaultAccountId"))
+	if v == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, codeNotFound, "vault account not found")
+		return
+	}
+	a := v.addAsset(r.PathValue("assetId"))
+	address := a.addresses[0]
+	s.mu.Unlock()
+
+	writeJSON(w, fireblocks.CreateVaultAccountAssetResponse{ID: r.PathValue("assetId"), Address: address.Address})
+}
+
+func (s *Server) getVaultAssetBalance(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	a, ok := s.assetOf(w, r)
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	available := new(big.Rat).Sub(a.total, a.locked)
+	resp := fireblocks.GetVaultAccountAssetBalanceResponse{
+		ID:           r.PathValue("assetId"),
+		Total:        formatAmount(a.total),
+		Balance:      formatAmount(a.total),
+		Available:    formatAmount(available),
+		Pending:      "0",
+		Frozen:       "0",
+		LockedAmount: formatAmount(a.locked),
+		Staked:       "0",
+		BlockHeight:  "0",
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, resp)
+}
+
+func (s *Server) listAddresses(w http.ResponseWriter, r *http.Request) {
+	limit, err := pageLimit(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.assetOf(w, r)
+	if !ok {
+		return
+	}
+
+	start, end := pageBounds(len(a.addresses), r.URL.Query().Get("before"), r.URL.Query().Get("after"), limit)
+	writeJSON(w, fireblocks.GetVaultAccountAssetAddressesResponse{
+		Addresses: append([]fireblocks.VaultAccountAddress{}, a.addresses[start:end]...),
+		Paging:    paging(start, end, len(a.addresses)),
+	})
+}
+
+func (s *Server) createAddress(w http.ResponseWriter, r *http.Request) {
+	var req fireblocks.CreateVaultAccountAssetAddressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+
+	s.mu.Lock()
+	a, ok := s.assetOf(w, r)
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	address := a.addAddress(r.PathValue("assetId"), req.Description, req.CustomerRefID)
+	s.mu.Unlock()
+
+	writeJSON(w, fireblocks.CreateVaultAccountAssetAddressResponse{
+		Address:           address.Address,
+		LegacyAddress:     address.LegacyAddress,
+		EnterpriseAddress: address.EnterpriseAddress,
+		Tag:               address.Tag,
+		Bip44AddressIndex: address.Bip44AddressIndex,
+	})
+}
+
+// vault returns the vault account with the ID, nil if there is none. The lock must be held.
+func (s *Server) vault(id string) *vault {
+	index, err := strconv.Atoi(id)
+	if err != nil || index < 0 || index >= len(s.vaults) || strconv.Itoa(index) != id {
+		return nil
+	}
+	return s.vaults[index]
+}
+
+// assetOf returns the asset wallet of the request path, answering 404 if there is none. The lock must be held.
+func (s *Server) assetOf(w http.ResponseWriter, r *http.Request) (*asset, bool) {
+	v := s.vault(r.PathValue("vaultAccountId"))
+	if v == nil {
+		writeError(w, http.StatusNotFound, codeNotFound, "vault account not found")
+		return nil, false
+	}
+	a, ok := v.assets[r.PathValue("assetId")]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeNotFound, "asset wallet not found in the vault account")
+		return nil, false
+	}
+	return a, true
+}
+
+// addAsset creates the asset wallet with its first address, unless it exists
+func (v *vault) addAsset(assetID string) *asset {
+	if a, ok := v.assets[assetID]; ok {
+		return a
+	}
+	a := &asset{total: new(big.Rat), locked: new(big.Rat)}
+	a.addAddress(assetID, "", "")
+	v.assets[assetID] = a
+	return a
+}
+
+func (a *asset) addAddress(assetID, description, customerRefID string) fireblocks.VaultAccountAddress {
+	address := fireblocks.VaultAccountAddress{
+		AssetID:           assetID,
+		Address:           randomAddress(),
+		Description:       description,
+		Type:              "Permanent",
+		Bip44AddressIndex: len(a.addresses),
+		CustomerRefID:     customerRefID,
+	}
+	a.addresses = append(a.addresses, address)
+	return address
+}
+
+func (v *vault) toResponse() fireblocks.VaultAccount {
+	account := fireblocks.VaultAccount{ID: v.id, Name: v.name, Assets: []fireblocks.VaultAccountAsset{}}
+	assetIDs := make([]string, 0, len(v.assets))
+	for assetID := range v.assets {
+		assetIDs = append(assetIDs, assetID)
+	}
+	sort.Strings(assetIDs)
+	for _, assetID := range assetIDs {
+		a := v.assets[assetID]
+		account.Assets = append(account.Assets, fireblocks.VaultAccountAsset{
+			ID:        assetID,
+			Total:     formatAmount(a.total),
+			Balance:   formatAmount(a.total),
+			Available: formatAmount(new(big.Rat).Sub(a.total, a.locked)),
+			Pending:   "0",
+			Frozen:    "0",
+		})
+	}
+	return account
+}
+
+// pageLimit reads the limit query parameter of the paginated endpoints
+func pageLimit(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultPageSize, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+	return limit, nil
+}
+
+// pageBounds returns the positions of the page of a list of size items, the cursors being positions in the list
+func pageBounds(size int, before, after string, limit int) (int, int) {
+	if position, err := strconv.Atoi(before); err == nil {
+		end := min(max(position, 0), size)
+		return max(end-limit, 0), end
+	}
+	start := 0
+	if position, err := strconv.Atoi(after); err == nil {
+		start = min(max(position+1, 0), size)
+	}
+	return start, min(start+limit, size)
+}
+
+func paging(start, end, size int) fireblocks.Paging {
+	var p fireblocks.Paging
+	if start > 0 {
+		p.Before = strconv.Itoa(start)
+	}
+	if end < size {
+		p.After = strconv.Itoa(end - 1)
+	}
+	return p
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(fireblocks.ErrorResponse{Message: message, Code: code}); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,361 @@
+package fireblockstest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"firego-wallet-service/internal/fireblocks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testAPIKey = "sim-api-key"
+
+type testEnv struct {
+	sim    *Server
+	url    string
+	client *fireblocks.Client
+	key    *rsa.PrivateKey
+}
+
+func newTestEnv(t *testing.T, config Config) *testEnv {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	config.APIKey = testAPIKey
+	config.PublicKey = &key.PublicKey
+	sim := NewServer(config)
+	server := httptest.NewServer(sim)
+	t.Cleanup(server.Close)
+
+	return &testEnv{
+		sim:    sim,
+		url:    server.URL,
+		client: fireblocks.NewClient(server.URL, testAPIKey, key),
+		key:    key,
+	}
+}
+
+// do sends a request signed with the claims, defaulting to the ones of a valid token
+func (e *testEnv) do(t *testing.T, method, uri, body string, claims jwt.MapClaims) *http.Response {
+	sum := sha256.Sum256([]byte(body))
+	now := time.Now().Unix()
+	tokenClaims := jwt.MapClaims{
+		"uri":      uri,
+		"nonce":    "nonce-" + t.Name(),
+		"iat":      now,
+		"exp":      now + 30,
+		"sub":      testAPIKey,
+		"bodyHash": hex.EncodeToString(sum[:]),
+	}
+	for name, value := range claims {
+		tokenClaims[name] = value
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, tokenClaims).SignedString(e.key)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(method, e.url+uri, strings.NewReader(body))
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-API-KEY", testAPIKey)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestAuthentication(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name       string
+		uri        string
+		body       string
+		claims     jwt.MapClaims
+		wantStatus int
+	}{
+		{name: "valid", uri: "/v1/vault/accounts_paged?limit=1", wantStatus: http.StatusOK},
+		{name: "uri_without_query", uri: "/v1/vault/accounts_paged?limit=1", claims: jwt.MapClaims{"uri": "/v1/vault/accounts_paged"}, wantStatus: http.StatusUnauthorized},
+		{name: "tampered_body", uri: "/v1/vault/accounts", body: `{"name":"a"}`, claims: jwt.MapClaims{"bodyHash": strings.Repeat("0", 64)}, wantStatus: http.StatusUnauthorized},
+		{name: "expired", uri: "/v1/vault/accounts_paged", claims: jwt.MapClaims{"iat": now - 60, "exp": now - 30}, wantStatus: http.StatusUnauthorized},
+		{name: "long_lived", uri: "/v1/vault/accounts_paged", claims: jwt.MapClaims{"exp": now + 300}, wantStatus: http.StatusUnauthorized},
+		{name: "missing_exp", uri: "/v1/vault/accounts_paged", claims: jwt.MapClaims{"exp": nil}, wantStatus: http.StatusUnauthorized},
+		{name: "missing_nonce", uri: "/v1/vault/accounts_paged", claims: jwt.MapClaims{"nonce": ""}, wantStatus: http.StatusUnauthorized},
+		{name: "other_subject", uri: "/v1/vault/accounts_paged", claims: jwt.MapClaims{"sub": "other-key"}, wantStatus: http.StatusUnauthorized},
+	}
+
+	env := newTestEnv(t, Config{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := http.MethodGet
+			if tt.body != "" {
+				method = http.MethodPost
+			}
+			resp := env.do(t, method, tt.uri, tt.body, tt.claims)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+
+	t.Run("replayed_nonce", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, env.do(t, http.MethodGet, "/v1/vault/accounts_paged", "", nil).StatusCode)
+		assert.Equal(t, http.StatusUnauthorized, env.do(t, http.MethodGet, "/v1/vault/accounts_paged", "", nil).StatusCode)
+	})
+
+	t.Run("other_key", func(t *testing.T) {
+		client := fireblocks.NewClient(env.url, testAPIKey, otherKey)
+		_, statusCode, err := client.ListVaultAccounts(context.Background(), fireblocks.PageRequest{})
+		assert.Equal(t, http.StatusUnauthorized, statusCode)
+		assert.ErrorContains(t, err, "Unauthorized")
+	})
+}
+
+func TestVaultAccounts(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t, Config{})
+
+	for _, name := range []string{"a", "b", "c"} {
+		resp, _, err := env.client.CreateVaultAccount(ctx, fireblocks.CreateVaultAccountRequest{Name: name})
+		assert.NoError(t, err)
+		assert.Equal(t, name, resp.Name)
+	}
+
+	var names []string
+	page := fireblocks.PageRequest{Limit: 2}
+	for {
+		resp, _, err := env.client.ListVaultAccounts(ctx, page)
+		assert.NoError(t, err)
+		for _, account := range resp.Accounts {
+			names = append(names, account.Name)
+		}
+		if resp.Paging.After == "" {
+			break
+		}
+		page.After = resp.Paging.After
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+
+	_, statusCode, err := env.client.GetVaultAccountAssetBalance(ctx, "0", "BTC_TEST")
+	assert.Equal(t, http.StatusNotFound, statusCode)
+	assert.Error(t, err)
+
+	_, err = env.sim.Deposit(ctx, "0", "BTC_TEST", "1")
+	assert.NoError(t, err)
+	created, _, err := env.client.CreateVaultAccountAssetAddress(ctx, "0", "BTC_TEST", fireblocks.CreateVaultAccountAssetAddressRequest{Description: "payer"})
+	assert.NoError(t, err)
+	addresses, _, err := env.client.GetVaultAccountAssetAddresses(ctx, "0", "BTC_TEST")
+	assert.NoError(t, err)
+	assert.Len(t, addresses.Addresses, 2)
+	assert.Equal(t, created.Address, addresses.Addresses[1].Address)
+	assert.Equal(t, "payer", addresses.Addresses[1].Description)
+}
+
+func TestTransactionLifecycle(t *testing.T) {
+	ctx := context.Background()
+	webhookKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	var events []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.NoError(t, fireblocks.VerifyWebhookSignature(&webhookKey.PublicKey, body, r.Header.Get(fireblocks.WebhookSignatureHeader)))
+
+		var event fireblocks.WebhookEvent
+		assert.NoError(t, json.Unmarshal(body, &event))
+		var tx fireblocks.TransactionResponse
+		assert.NoError(t, json.Unmarshal(event.Data, &tx))
+		mu.Lock()
+		events = append(events, event.Type+" "+tx.Destination.Type+" "+tx.Status)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	env := newTestEnv(t, Config{WebhookURL: receiver.URL, WebhookKey: webhookKey, Confirmations: 2})
+	vault, _, err := env.client.CreateVaultAccount(ctx, fireblocks.CreateVaultAccountRequest{Name: "wallet"})
+	assert.NoError(t, err)
+
+	depositID, err := env.sim.Deposit(ctx, vault.ID, "BTC_TEST", "1.5")
+	assert.NoError(t, err)
+	env.sim.Advance(ctx)
+	env.sim.Advance(ctx)
+
+	deposit, _, err := env.client.GetTransaction(ctx, depositID)
+	assert.NoError(t, err)
+	assert.Equal(t, fireblocks.TransactionStatusCompleted, deposit.Status)
+	assert.Equal(t, 2, deposit.NumOfConfirmations)
+	incoming, _, err := env.client.ListTransactions(ctx, fireblocks.ListTransactionsRequest{DestType: "VAULT_ACCOUNT", DestID: vault.ID})
+	assert.NoError(t, err)
+	assert.Len(t, incoming, 1)
+
+	created, _, err := env.client.CreateTransaction(ctx, fireblocks.NewVaultTransferRequest("BTC_TEST", vault.ID, "tb1qdestination", "1", "rent"))
+	assert.NoError(t, err)
+	assert.Equal(t, fireblocks.TransactionStatusSubmitted, created.Status)
+
+	balance, _, err := env.client.GetVaultAccountAssetBalance(ctx, vault.ID, "BTC_TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5", balance.Total)
+	assert.Equal(t, "0.5", balance.Available)
+
+	wantStatuses := []string{
+		fireblocks.TransactionStatusPendingSignature,
+		fireblocks.TransactionStatusBroadcasting,
+		fireblocks.TransactionStatusConfirming,
+		fireblocks.TransactionStatusConfirming,
+		fireblocks.TransactionStatusCompleted,
+	}
+	for _, want := range wantStatuses {
+		env.sim.Advance(ctx)
+		tx, _, err := env.client.GetTransaction(ctx, created.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, want, tx.Status)
+	}
+
+	balance, _, err = env.client.GetVaultAccountAssetBalance(ctx, vault.ID, "BTC_TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.5", balance.Total)
+	assert.Equal(t, "0.5", balance.Available)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"TRANSACTION_CREATED VAULT_ACCOUNT CONFIRMING",
+		"TRANSACTION_STATUS_UPDATED VAULT_ACCOUNT CONFIRMING",
+		"TRANSACTION_STATUS_UPDATED VAULT_ACCOUNT COMPLETED",
+		"TRANSACTION_CREATED ONE_TIME_ADDRESS SUBMITTED",
+		"TRANSACTION_STATUS_UPDATED ONE_TIME_ADDRESS PENDING_SIGNATURE",
+		"TRANSACTION_STATUS_UPDATED ONE_TIME_ADDRESS BROADCASTING",
+		"TRANSACTION_STATUS_UPDATED ONE_TIME_ADDRESS CONFIRMING",
+		"TRANSACTION_STATUS_UPDATED ONE_TIME_ADDRESS CONFIRMING",
+		"TRANSACTION_STATUS_UPDATED ONE_TIME_ADDRESS COMPLETED",
+	}, events)
+}
+
+func TestVaultToVaultTransfer(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t, Config{})
+	source, _, err := env.client.CreateVaultAccount(ctx, fireblocks.CreateVaultAccountRequest{Name: "deposits"})
+	assert.NoError(t, err)
+	treasury, _, err := env.client.CreateVaultAccount(ctx, fireblocks.CreateVaultAccountRequest{Name: "treasury"})
+	assert.NoError(t, err)
+	_, err = env.sim.Deposit(ctx, source.ID, "ETH_TEST5", "2")
+	assert.NoError(t, err)
+	env.sim.Advance(ctx)
+
+	created, _, err := env.client.CreateTransaction(ctx, fireblocks.NewVaultToVaultTransferRequest("ETH_TEST5", source.ID, treasury.ID, "1.5", "sweep"))
+	assert.NoError(t, err)
+	tx, _, err := env.client.GetTransaction(ctx, created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, fireblocks.TransferPeerPath{Type: "VAULT_ACCOUNT", ID: treasury.ID, Name: "treasury"}, tx.Destination)
+	assert.NotEmpty(t, tx.DestinationAddress)
+	incoming, _, err := env.client.ListTransactions(ctx, fireblocks.ListTransactionsRequest{DestType: "VAULT_ACCOUNT", DestID: treasury.ID})
+	assert.NoError(t, err)
+	assert.Len(t, incoming, 1)
+
+	for range 4 {
+		env.sim.Advance(ctx)
+	}
+	tx, _, err = env.client.GetTransaction(ctx, created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, fireblocks.TransactionStatusCompleted, tx.Status)
+	balance, _, err := env.client.GetVaultAccountAssetBalance(ctx, source.ID, "ETH_TEST5")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.5", balance.Total)
+	balance, _, err = env.client.GetVaultAccountAssetBalance(ctx, treasury.ID, "ETH_TEST5")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5", balance.Total)
+
+	_, statusCode, err := env.client.CreateTransaction(ctx, fireblocks.NewVaultToVaultTransferRequest("ETH_TEST5", source.ID, "99", "0.1", ""))
+	assert.Equal(t, http.StatusNotFound, statusCode)
+	assert.Error(t, err)
+}
+
+func TestTransactionFailures(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t, Config{})
+	vault, _, err := env.client.CreateVaultAccount(ctx, fireblocks.CreateVaultAccountRequest{Name: "wallet"})
+	assert.NoError(t, err)
+	depositID, err := env.sim.Deposit(ctx, vault.ID, "ETH_TEST5", "1")
+	assert.NoError(t, err)
+	env.sim.Advance(ctx)
+
+	t.Run("insufficient_funds", func(t *testing.T) {
+		created, _, err := env.client.CreateTransaction(ctx, fireblocks.NewVaultTransferRequest("ETH_TEST5", vault.ID, "0xdestination", "2", ""))
+		assert.NoError(t, err)
+		tx, _, err := env.client.GetTransaction(ctx, created.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, fireblocks.TransactionStatusFailed, tx.Status)
+		assert.Equal(t, "INSUFFICIENT_FUNDS", tx.SubStatus)
+	})
+
+	t.Run("blocked_by_policy", func(t *testing.T) {
+		created, _, err := env.client.CreateTransaction(ctx, fireblocks.NewVaultTransferRequest("ETH_TEST5", vault.ID, "0xdestination", "0.4", ""))
+		assert.NoError(t, err)
+		assert.NoError(t, env.sim.Fail(ctx, created.ID, fireblocks.TransactionStatusBlocked, "BLOCKED_BY_POLICY"))
+
+		tx, _, err := env.client.GetTransaction(ctx, created.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, fireblocks.TransactionStatusBlocked, tx.Status)
+		balance, _, err := env.client.GetVaultAccountAssetBalance(ctx, vault.ID, "ETH_TEST5")
+		assert.NoError(t, err)
+		assert.Equal(t, "1", balance.Available)
+
+		assert.Error(t, env.sim.Fail(ctx, created.ID, fireblocks.TransactionStatusFailed, ""))
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		created, _, err := env.client.CreateTransaction(ctx, fireblocks.NewVaultTransferRequest("ETH_TEST5", vault.ID, "0xdestination", "0.4", ""))
+		assert.NoError(t, err)
+		_, _, err = env.client.CancelTransaction(ctx, created.ID)
+		assert.NoError(t, err)
+
+		tx, _, err := env.client.GetTransaction(ctx, created.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, fireblocks.TransactionStatusCancelled, tx.Status)
+		account, _, err := env.client.GetVaultAccount(ctx, vault.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", account.Assets[0].Available)
+
+		_, statusCode, err := env.client.CancelTransaction(ctx, created.ID)
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+		assert.Error(t, err)
+		_, statusCode, _ = env.client.CancelTransaction(ctx, depositID)
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+	})
+
+	t.Run("idempotency_key", func(t *testing.T) {
+		keyCtx := fireblocks.WithIdempotencyKey(ctx, "payout-1")
+		created, _, err := env.client.CreateTransaction(keyCtx, fireblocks.NewVaultTransferRequest("ETH_TEST5", vault.ID, "0xdestination", "0.1", ""))
+		assert.NoError(t, err)
+		again, _, err := env.client.CreateTransaction(keyCtx, fireblocks.NewVaultTransferRequest("ETH_TEST5", vault.ID, "0xdestination", "0.1", ""))
+		assert.NoError(t, err)
+		assert.Equal(t, created.ID, again.ID)
+
+		balance, _, err := env.client.GetVaultAccountAssetBalance(ctx, vault.ID, "ETH_TEST5")
+		assert.NoError(t, err)
+		assert.Equal(t, "0.1", balance.LockedAmount)
+		_, _, err = env.client.CancelTransaction(ctx, created.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("not_a_failed_status", func(t *testing.T) {
+		assert.Error(t, env.sim.Fail(ctx, depositID, fireblocks.TransactionStatusCompleted, ""))
+	})
+
+	t.Run("unknown_asset", func(t *testing.T) {
+		_, statusCode, err := env.client.CreateTransaction(ctx, fireblocks.NewVaultTransferRequest("SOL_TEST", vault.ID, "0xdestination", "1", ""))
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+		assert.Error(t, err)
+	})
+}
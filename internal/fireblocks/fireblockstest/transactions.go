@@ -0,0 +1,500 @@
+package fireblockstest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/fireblocks"
+	"fmt"
+	"github.com/google/uuid"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for unknown vault accounts and transactions
+var ErrNotFound = errors.New("not found")
+
+// ErrNotCancellable is returned when cancelling a deposit, or a transaction that was broadcast or ended
+var ErrNotCancellable = errors.New("transaction cannot be cancelled")
+
+// externalAddress is the source address of the simulated deposits
+const externalAddress = "sim-external-address"
+
+type transaction struct {
+	fireblocks.TransactionResponse
+	amount *big.Rat
+	// incoming transactions are deposits from outside the workspace
+	incoming bool
+}
+
+func (s *Server) createTransaction(w http.ResponseWriter, r *http.Request) {
+	var req fireblocks.CreateTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+	amount, ok := parseAmount(req.Amount)
+	switch {
+	case req.Operation != "" && req.Operation != "TRANSFER":
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "only TRANSFER operations are supported")
+		return
+	case req.Source.Type != "VAULT_ACCOUNT":
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "source must be a vault account")
+		return
+	case req.Destination.Type == "VAULT_ACCOUNT" && req.Destination.ID == "":
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "destination vault account id is required")
+		return
+	case req.Destination.Type != "VAULT_ACCOUNT" && (req.Destination.Type != "ONE_TIME_ADDRESS" || req.Destination.OneTimeAddress.Address == ""):
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "destination must be a one time address or a vault account")
+		return
+	case !ok:
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "amount must be a positive number")
+		return
+	}
+
+	s.mu.Lock()
+	// a request sent again with the same Idempotency-Key gets the transaction created the first time
+	key := r.Header.Get("Idempotency-Key")
+	if txID, ok := s.idempotencyKeys[key]; ok && key != "" {
+		tx := s.transaction(txID)
+		s.mu.Unlock()
+		writeJSON(w, fireblocks.CreateTransactionResponse{ID: tx.ID, Status: tx.Status})
+		return
+	}
+	if req.ExternalTxID != "" && s.externalTransaction(req.ExternalTxID) != nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "externalTxId already used")
+		return
+	}
+	v := s.vault(req.Source.ID)
+	if v == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, codeNotFound, "vault account not found")
+		return
+	}
+	a, ok := v.assets[req.AssetID]
+	if !ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "asset wallet not found in the vault account")
+		return
+	}
+
+	tx := s.newTransaction(req.AssetID, amount, req.Note)
+	tx.ExternalTxID = req.ExternalTxID
+	tx.Source = fireblocks.TransferPeerPath{Type: "VAULT_ACCOUNT", ID: v.id, Name: v.name}
+	tx.SourceAddress = a.addresses[0].Address
+	if req.Destination.Type == "VAULT_ACCOUNT" {
+		destination := s.vault(req.Destination.ID)
+		if destination == nil {
+			s.mu.Unlock()
+			writeError(w, http.StatusNotFound, codeNotFound, "destination vault account not found")
+			return
+		}
+		tx.Destination = fireblocks.TransferPeerPath{Type: "VAULT_ACCOUNT", ID: destination.id, Name: destination.name}
+		tx.DestinationAddress = destination.addAsset(req.AssetID).addresses[0].Address
+	} else {
+		tx.Destination = fireblocks.TransferPeerPath{Type: "ONE_TIME_ADDRESS"}
+		tx.DestinationAddress = req.Destination.OneTimeAddress.Address
+	}
+	// Fireblocks accepts the transaction and fails it afterwards when the funds are insufficient
+	if available := new(big.Rat).Sub(a.total, a.locked); available.Cmp(amount) < 0 {
+		tx.Status = fireblocks.TransactionStatusFailed
+		tx.SubStatus = "INSUFFICIENT_FUNDS"
+	} else {
+		a.locked.Add(a.locked, amount)
+	}
+	s.transactions = append(s.transactions, tx)
+	if key != "" {
+		s.idempotencyKeys[key] = tx.ID
+	}
+	events := []fireblocks.WebhookEvent{s.event(fireblocks.WebhookEventTransactionCreated, tx)}
+	s.mu.Unlock()
+
+	s.deliver(r.Context(), events)
+	writeJSON(w, fireblocks.CreateTransactionResponse{ID: tx.ID, Status: fireblocks.TransactionStatusSubmitted})
+}
+
+func (s *Server) getTransaction(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	tx := s.transaction(r.PathValue("txId"))
+	var resp fireblocks.TransactionResponse
+	if tx != nil {
+		resp = tx.TransactionResponse
+	}
+	s.mu.Unlock()
+
+	if tx == nil {
+		writeError(w, http.StatusNotFound, codeNotFound, "transaction not found")
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) getTransactionByExternalID(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	tx := s.externalTransaction(r.PathValue("externalTxId"))
+	var resp fireblocks.TransactionResponse
+	if tx != nil {
+		resp = tx.TransactionResponse
+	}
+	s.mu.Unlock()
+
+	if tx == nil {
+		writeError(w, http.StatusNotFound, codeNotFound, "transaction not found")
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) cancelTransaction(w http.ResponseWriter, r *http.Request) {
+	if err := s.Cancel(r.Context(), r.PathValue("txId")); err != nil {
+		status, code := http.StatusBadRequest, codeInvalidRequest
+		if errors.Is(err, ErrNotFound) {
+			status, code = http.StatusNotFound, codeNotFound
+		}
+		writeError(w, status, code, err.Error())
+		return
+	}
+	writeJSON(w, fireblocks.CancelTransactionResponse{Success: true})
+}
+
+func (s *Server) listTransactions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, err := pageLimit(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	after, _ := strconv.ParseInt(query.Get("after"), 10, 64)
+	before, _ := strconv.ParseInt(query.Get("before"), 10, 64)
+	orderBy := query.Get("orderBy")
+	if orderBy == "" {
+		orderBy = "createdAt"
+	}
+	if orderBy != "createdAt" && orderBy != "lastUpdated" {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "orderBy must be createdAt or lastUpdated")
+		return
+	}
+	statuses := map[string]bool{}
+	for _, status := range strings.Split(query.Get("status"), ",") {
+		if status != "" {
+			statuses[status] = true
+		}
+	}
+
+	s.mu.Lock()
+	txs := []fireblocks.TransactionResponse{}
+	for _, tx := range s.transactions {
+		// the time filters apply to the ordering field
+		at := tx.CreatedAt
+		if orderBy == "lastUpdated" {
+			at = tx.LastUpdated
+		}
+		switch {
+		case after > 0 && at <= after, before > 0 && at >= before:
+		case len(statuses) > 0 && !statuses[tx.Status]:
+		case query.Get("destType") != "" && tx.Destination.Type != query.Get("destType"):
+		case query.Get("destId") != "" && tx.Destination.ID != query.Get("destId"):
+		default:
+			txs = append(txs, tx.TransactionResponse)
+		}
+	}
+	s.mu.Unlock()
+
+	ascending := strings.EqualFold(query.Get("sort"), "ASC")
+	sort.SliceStable(txs, func(i, j int) bool {
+		a, b := txs[i].CreatedAt, txs[j].CreatedAt
+		if orderBy == "lastUpdated" {
+			a, b = txs[i].LastUpdated, txs[j].LastUpdated
+		}
+		if ascending {
+			return a < b
+		}
+		return a > b
+	})
+	writeJSON(w, txs[:min(limit, len(txs))])
+}
+
+// Deposit simulates funds sent to the vault account from outside the workspace, creating the asset wallet if needed.
+// The deposit walks through the confirming statuses as the simulator advances.
+func (s *Server) Deposit(ctx context.Context, vaultAccountID, assetID, amount string) (string, error) {
+	value, ok := parseAmount(amount)
+	if !ok {
+		return "", fmt.Errorf("invalid amount %q", amount)
+	}
+
+	s.mu.Lock()
+	v := s.vault(vaultAccountID)
+	if v == nil {
+		s.mu.Unlock()
+		return "", fmt.Errorf("vault account %s: %w", vaultAccountID, ErrNotFound)
+	}
+	a := v.addAsset(assetID)
+
+	tx := s.newTransaction(assetID, value, "")
+	tx.incoming = true
+	tx.Status = fireblocks.TransactionStatusConfirming
+	tx.TxHash = randomHex(32)
+	tx.Source = fireblocks.TransferPeerPath{Type: "UNKNOWN"}
+	tx.Destination = fireblocks.TransferPeerPath{Type: "VAULT_ACCOUNT", ID: v.id, Name: v.name}
+	tx.SourceAddress = externalAddress
+	tx.DestinationAddress = a.addresses[0].Address
+	s.transactions = append(s.transactions, tx)
+	events := []fireblocks.WebhookEvent{s.event(fireblocks.WebhookEventTransactionCreated, tx)}
+	s.mu.Unlock()
+
+	s.deliver(ctx, events)
+	return tx.ID, nil
+}
+
+// Advance moves every transaction in progress to its next status: outgoing transactions go from SUBMITTED through
+// PENDING_SIGNATURE and BROADCASTING to CONFIRMING, then all of them gain a confirmation per step until they are
+// COMPLETED with Config.Confirmations. Balances are settled on completion, and a webhook is sent for every change.
+func (s *Server) Advance(ctx context.Context) {
+	s.mu.Lock()
+	var events []fireblocks.WebhookEvent
+	for _, tx := range s.transactions {
+		if fireblocks.IsTerminalTransactionStatus(tx.Status) {
+			continue
+		}
+		s.step(tx)
+		events = append(events, s.event(fireblocks.WebhookEventTransactionStatusUpdated, tx))
+	}
+	s.mu.Unlock()
+
+	s.deliver(ctx, events)
+}
+
+// Run advances the transactions every interval until the context is done
+func (s *Server) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Advance(ctx)
+		}
+	}
+}
+
+// Fail ends a transaction in progress with a failed status, e.g. BLOCKED with the BLOCKED_BY_POLICY sub-status,
+// releasing the funds it held
+func (s *Server) Fail(ctx context.Context, txID, status, subStatus string) error {
+	if !fireblocks.IsFailedTransactionStatus(status) {
+		return fmt.Errorf("%s is not a failed status", status)
+	}
+
+	s.mu.Lock()
+	tx := s.transaction(txID)
+	if tx == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("transaction %s: %w", txID, ErrNotFound)
+	}
+	if fireblocks.IsTerminalTransactionStatus(tx.Status) {
+		s.mu.Unlock()
+		return fmt.Errorf("transaction %s is already %s", txID, tx.Status)
+	}
+	if !tx.incoming {
+		s.sourceAsset(tx).locked.Sub(s.sourceAsset(tx).locked, tx.amount)
+	}
+	s.update(tx, status)
+	tx.SubStatus = subStatus
+	events := []fireblocks.WebhookEvent{s.event(fireblocks.WebhookEventTransactionStatusUpdated, tx)}
+	s.mu.Unlock()
+
+	s.deliver(ctx, events)
+	return nil
+}
+
+// Cancel cancels an outgoing transaction that was not broadcast yet, releasing the funds it held
+func (s *Server) Cancel(ctx context.Context, txID string) error {
+	s.mu.Lock()
+	tx := s.transaction(txID)
+	if tx == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("transaction %s: %w", txID, ErrNotFound)
+	}
+	if tx.incoming || (tx.Status != fireblocks.TransactionStatusSubmitted && tx.Status != fireblocks.TransactionStatusPendingSignature) {
+		s.mu.Unlock()
+		return fmt.Errorf("transaction %s is %s: %w", txID, tx.Status, ErrNotCancellable)
+	}
+	s.sourceAsset(tx).locked.Sub(s.sourceAsset(tx).locked, tx.amount)
+	s.update(tx, fireblocks.TransactionStatusCancelled)
+	tx.SubStatus = "CANCELLED_BY_USER"
+	events := []fireblocks.WebhookEvent{s.event(fireblocks.WebhookEventTransactionStatusUpdated, tx)}
+	s.mu.Unlock()
+
+	s.deliver(ctx, events)
+	return nil
+}
+
+// step moves the transaction to its next status. The lock must be held.
+func (s *Server) step(tx *transaction) {
+	switch tx.Status {
+	case fireblocks.TransactionStatusSubmitted:
+		s.update(tx, fireblocks.TransactionStatusPendingSignature)
+	case fireblocks.TransactionStatusPendingSignature:
+		tx.TxHash = randomHex(32)
+		s.update(tx, fireblocks.TransactionStatusBroadcasting)
+	case fireblocks.TransactionStatusBroadcasting:
+		s.update(tx, fireblocks.TransactionStatusConfirming)
+	case fireblocks.TransactionStatusConfirming:
+		tx.NumOfConfirmations++
+		if tx.NumOfConfirmations < s.config.Confirmations {
+			s.update(tx, fireblocks.TransactionStatusConfirming)
+			return
+		}
+		if !tx.incoming {
+			a := s.sourceAsset(tx)
+			a.total.Sub(a.total, tx.amount)
+			a.locked.Sub(a.locked, tx.amount)
+		}
+		// deposits and transfers between vault accounts credit the destination vault
+		if tx.Destination.Type == "VAULT_ACCOUNT" {
+			a := s.vault(tx.Destination.ID).assets[tx.AssetID]
+			a.total.Add(a.total, tx.amount)
+		}
+		s.update(tx, fireblocks.TransactionStatusCompleted)
+	}
+}
+
+func (s *Server) update(tx *transaction, status string) {
+	tx.Status = status
+	tx.SubStatus = ""
+	if status == fireblocks.TransactionStatusConfirming || status == fireblocks.TransactionStatusCompleted {
+		tx.SubStatus = "CONFIRMED"
+	}
+	tx.LastUpdated = time.Now().UnixMilli()
+}
+
+// newTransaction creates a SUBMITTED transaction. The lock must be held.
+func (s *Server) newTransaction(assetID string, amount *big.Rat, note string) *transaction {
+	// creation times are kept unique, so that time cursors never skip or repeat a transaction
+	createdAt := max(time.Now().UnixMilli(), s.lastCreated+1)
+	s.lastCreated = createdAt
+
+	formatted := formatAmount(amount)
+	return &transaction{
+		TransactionResponse: fireblocks.TransactionResponse{
+			ID:          uuid.New().String(),
+			Status:      fireblocks.TransactionStatusSubmitted,
+			Operation:   "TRANSFER",
+			Note:        note,
+			AssetID:     assetID,
+			AmountInfo:  fireblocks.AmountInfo{Amount: formatted, RequestedAmount: formatted, NetAmount: formatted},
+			FeeInfo:     fireblocks.FeeInfo{NetworkFee: "0", ServiceFee: "0"},
+			FeeCurrency: assetID,
+			CreatedAt:   createdAt,
+			LastUpdated: createdAt,
+		},
+		amount: amount,
+	}
+}
+
+// transaction returns the transaction with the ID, nil if there is none. The lock must be held.
+func (s *Server) transaction(id string) *transaction {
+	for _, tx := range s.transactions {
+		if tx.ID == id {
+			return tx
+		}
+	}
+	return nil
+}
+
+// externalTransaction returns the transaction created with the external ID, nil if there is none. The lock must be
+// held.
+func (s *Server) externalTransaction(externalTxID string) *transaction {
+	for _, tx := range s.transactions {
+		if tx.ExternalTxID == externalTxID {
+			return tx
+		}
+	}
+	return nil
+}
+
+func (s *Server) sourceAsset(tx *transaction) *asset {
+	return s.vault(tx.Source.ID).assets[tx.AssetID]
+}
+
+func (s *Server) simulateDeposit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		VaultAccountID string `json:"vaultAccountId"`
+		AssetID        string `json:"assetId"`
+		Amount         string `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AssetID == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "vaultAccountId, assetId and amount are required")
+		return
+	}
+
+	txID, err := s.Deposit(r.Context(), req.VaultAccountID, req.AssetID, req.Amount)
+	if err != nil {
+		status, code := http.StatusBadRequest, codeInvalidRequest
+		if errors.Is(err, ErrNotFound) {
+			status, code = http.StatusNotFound, codeNotFound
+		}
+		writeError(w, status, code, err.Error())
+		return
+	}
+	writeJSON(w, fireblocks.CreateTransactionResponse{ID: txID, Status: fireblocks.TransactionStatusConfirming})
+}
+
+func (s *Server) simulateAdvance(w http.ResponseWriter, r *http.Request) {
+	s.Advance(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) simulateFailure(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status    string `json:"status"`
+		SubStatus string `json:"subStatus"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+
+	if err := s.Fail(r.Context(), r.PathValue("txId"), req.Status, req.SubStatus); err != nil {
+		status, code := http.StatusBadRequest, codeInvalidRequest
+		if errors.Is(err, ErrNotFound) {
+			status, code = http.StatusNotFound, codeNotFound
+		}
+		writeError(w, status, code, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseAmount parses a positive decimal amount
+func parseAmount(amount string) (*big.Rat, bool) {
+	value, ok := new(big.Rat).SetString(amount)
+	if !ok || value.Sign() <= 0 {
+		return nil, false
+	}
+	return value, true
+}
+
+// formatAmount renders an amount as a decimal without trailing zeros
+func formatAmount(amount *big.Rat) string {
+	formatted := amount.FloatString(18)
+	formatted = strings.TrimRight(formatted, "0")
+	return strings.TrimSuffix(formatted, ".")
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func randomAddress() string {
+	return "0x" + randomHex(20)
+}
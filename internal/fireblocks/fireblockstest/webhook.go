@@ -0,0 +1,81 @@
+package fireblockstest
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"firego-wallet-service/internal/fireblocks"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// tenantID is the workspace the webhooks are sent for
+const tenantID = "fireblocks-sim"
+
+// SignWebhook signs a webhook body the way Fireblocks does, returning the value of the Fireblocks-Signature header
+func SignWebhook(key *rsa.PrivateKey, body []byte) (string, error) {
+	digest := sha512.Sum512(body)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA512, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign webhook: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// event captures the transaction as it is now. The lock must be held.
+func (s *Server) event(eventType string, tx *transaction) fireblocks.WebhookEvent {
+	data, _ := json.Marshal(tx.TransactionResponse)
+	return fireblocks.WebhookEvent{
+		Type:      eventType,
+		TenantID:  tenantID,
+		Timestamp: time.Now().UnixMilli(),
+		Data:      data,
+	}
+}
+
+// deliver sends the events in order. Failed deliveries are logged and dropped, the service catches up by polling.
+func (s *Server) deliver(ctx context.Context, events []fireblocks.WebhookEvent) {
+	if s.config.WebhookURL == "" {
+		return
+	}
+	for _, event := range events {
+		if err := s.send(ctx, event); err != nil {
+			slog.Warn("Failed to deliver webhook", "type", event.Type, "error", err)
+		}
+	}
+}
+
+func (s *Server) send(ctx context.Context, event fireblocks.WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	signature, err := SignWebhook(s.config.WebhookKey, body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodPost, s.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(fireblocks.WebhookSignatureHeader, signature)
+
+	resp, err := s.webhook.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package fireblocks
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/metrics"
+	"fmt"
+	"golang.org/x/time/rate"
+	"net/http"
+	"time"
+)
+
+// ErrRateLimited is returned when a request would have waited longer than RateLimits.MaxWait for the client-side
+// rate limiter. The request is not sent, and fails with the status code 429 like a request Fireblocks rate limited.
+var ErrRateLimited = errors.New("fireblocks rate limit exceeded")
+
+// Endpoint classes, each with its own rate limit
+const (
+	endpointClassRead  = "read"
+	endpointClassWrite = "write"
+)
+
+// RateLimit is a token bucket: Rate requests per second on average, with bursts of up to Burst requests
+type RateLimit struct {
+	// Rate of 0 disables the limit
+	Rate  float64
+	Burst int
+}
+
+// RateLimits keeps the requests within the Fireblocks API key quotas. GET requests are limited by Read, the other
+// ones by Write. Retries are limited like any other request.
+type RateLimits struct {
+	Read  RateLimit
+	Write RateLimit
+	// MaxWait is how long a request queues for the limiter before failing with ErrRateLimited, 0 fails it right away
+	MaxWait time.Duration
+}
+
+// WithRateLimits enables the client-side rate limiting, which is disabled by default
+func WithRateLimits(limits RateLimits) Option {
+	return func(c *Client) {
+		c.limiter = newLimiter(limits)
+	}
+}
+
+type limiter struct {
+	classes map[string]*rate.Limiter
+	maxWait time.Duration
+}
+
+func newLimiter(limits RateLimits) *limiter {
+	l := &limiter{
+		classes: map[string]*rate.Limiter{},
+		maxWait: limits.MaxWait,
+	}
+	for class, limit := range map[string]RateLimit{endpointClassRead: limits.Read, endpointClassWrite: limits.Write} {
+		if limit.Rate > 0 {
+			l.classes[class] = rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+		}
+	}
+	return l
+}
+
+// wait blocks until the request may be sent and returns how long it waited. It fails right away when the wait
+// would exceed the max wait or the context deadline.
+func (l *limiter) wait(ctx context.Context, method string) (time.Duration, error) {
+	class := endpointClassWrite
+	if method == http.MethodGet {
+		class = endpointClassRead
+	}
+	classLimiter, ok := l.classes[class]
+	if !ok {
+		return 0, nil
+	}
+
+	start := time.Now()
+	var err error
+	if l.maxWait > 0 {
+		waitCtx, cancel := context.WithTimeout(ctx, l.maxWait)
+		err = classLimiter.Wait(waitCtx)
+		cancel()
+	} else if !classLimiter.Allow() {
+		err = context.DeadlineExceeded
+	}
+	waited := time.Since(start)
+	metrics.ObserveFireblocksRateLimit(class, waited, classLimiter.Tokens(), err == nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return waited, ctx.Err()
+		}
+		return waited, fmt.Errorf("%w: %s requests are limited to %g per second", ErrRateLimited, class, float64(classLimiter.Limit()))
+	}
+	return waited, nil
+}
@@ -1,6 +1,9 @@
 package fireblocks
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 type ErrorResponse struct {
 	Message string `json:"message"`
@@ -20,6 +23,37 @@ type CreateVaultAccountResponse struct {
 	Name string `json:"name"`
 }
 
+type CreateVaultAccountAssetResponse struct {
+	ID                string `json:"id"`
+	Address           string `json:"address"`
+	LegacyAddress     string `json:"legacyAddress"`
+	EnterpriseAddress string `json:"enterpriseAddress"`
+	Tag               string `json:"tag"`
+}
+
+type VaultAccount struct {
+	ID            string              `json:"id"`
+	Name          string              `json:"name"`
+	HiddenOnUI    bool                `json:"hiddenOnUI"`
+	CustomerRefID string              `json:"customerRefId"`
+	AutoFuel      bool                `json:"autoFuel"`
+	Assets        []VaultAccountAsset `json:"assets"`
+}
+
+type VaultAccountAsset struct {
+	ID        string `json:"id"`
+	Total     string `json:"total"`
+	Balance   string `json:"balance"`
+	Available string `json:"available"`
+	Pending   string `json:"pending"`
+	Frozen    string `json:"frozen"`
+}
+
+type ListVaultAccountsResponse struct {
+	Accounts []VaultAccount `json:"accounts"`
+	Paging   Paging         `json:"paging"`
+}
+
 type GetVaultAccountAssetBalanceResponse struct {
 	ID           string `json:"id"`
 	Total        string `json:"total"`
@@ -34,6 +68,19 @@ type GetVaultAccountAssetBalanceResponse struct {
 
 type GetVaultAccountAssetAddressesResponse struct {
 	Addresses []VaultAccountAddress `json:"addresses"`
+	Paging    Paging                `json:"paging"`
+}
+
+type Paging struct {
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// PageRequest holds the cursor parameters of paginated Fireblocks endpoints; zero values are omitted
+type PageRequest struct {
+	Before string
+	After  string
+	Limit  int
 }
 
 type VaultAccountAddress struct {
@@ -47,6 +94,20 @@ type VaultAccountAddress struct {
 	EnterpriseAddress string `json:"enterpriseAddress"`
 	Bip44AddressIndex int    `json:"bip44AddressIndex"`
 	UserDefined       bool   `json:"userDefined"`
+	CustomerRefID     string `json:"customerRefId"`
+}
+
+type CreateVaultAccountAssetAddressRequest struct {
+	Description   string `json:"description,omitempty"`
+	CustomerRefID string `json:"customerRefId,omitempty"`
+}
+
+type CreateVaultAccountAssetAddressResponse struct {
+	Address           string `json:"address"`
+	LegacyAddress     string `json:"legacyAddress"`
+	EnterpriseAddress string `json:"enterpriseAddress"`
+	Tag               string `json:"tag"`
+	Bip44AddressIndex int    `json:"bip44AddressIndex"`
 }
 
 type CreateTransactionRequest struct {
@@ -56,6 +117,9 @@ type CreateTransactionRequest struct {
 	Destination TransactionDestination `json:"destination"`
 	Amount      string                 `json:"amount"`
 	Note        string                 `json:"note,omitempty"`
+	// ExternalTxID is unique per transaction, set by the caller to look the transaction up with, see
+	// Client.GetTransactionByExternalID
+	ExternalTxID string `json:"externalTxId,omitempty"`
 }
 
 type TransactionSource struct {
@@ -63,9 +127,11 @@ type TransactionSource struct {
 	ID   string `json:"id"`
 }
 
+// TransactionDestination is either a ONE_TIME_ADDRESS with its OneTimeAddress, or a VAULT_ACCOUNT with its ID
 type TransactionDestination struct {
 	Type           string         `json:"type"`
-	OneTimeAddress OneTimeAddress `json:"oneTimeAddress"`
+	ID             string         `json:"id,omitempty"`
+	OneTimeAddress OneTimeAddress `json:"oneTimeAddress,omitzero"`
 }
 
 type OneTimeAddress struct {
@@ -76,3 +142,119 @@ type CreateTransactionResponse struct {
 	ID     string `json:"id"`
 	Status string `json:"status"`
 }
+
+type CancelTransactionResponse struct {
+	Success bool `json:"success"`
+}
+
+const (
+	TransactionStatusSubmitted              = "SUBMITTED"
+	TransactionStatusPendingAMLScreening    = "PENDING_AML_SCREENING"
+	TransactionStatusPendingAuthorization   = "PENDING_AUTHORIZATION"
+	TransactionStatusQueued                 = "QUEUED"
+	TransactionStatusPendingSignature       = "PENDING_SIGNATURE"
+	TransactionStatusBroadcasting           = "BROADCASTING"
+	TransactionStatusConfirming             = "CONFIRMING"
+	TransactionStatusCompleted              = "COMPLETED"
+	TransactionStatusCancelled              = "CANCELLED"
+	TransactionStatusRejected               = "REJECTED"
+	TransactionStatusBlocked                = "BLOCKED"
+	TransactionStatusFailed                 = "FAILED"
+	TransactionStatusTimeout                = "TIMEOUT"
+	TransactionStatusPending3rdParty        = "PENDING_3RD_PARTY"
+	TransactionStatusPending3rdPartyManual  = "PENDING_3RD_PARTY_MANUAL_APPROVAL"
+	TransactionStatusCancelling             = "CANCELLING"
+	TransactionStatusPendingEnrichment      = "PENDING_ENRICHMENT"
+	TransactionStatusPartiallyCompleted     = "PARTIALLY_COMPLETED"
+	TransactionStatusPendingConsoleApproval = "PENDING_CONSOLE_APPROVAL"
+)
+
+// TerminalTransactionStatuses lists the statuses after which Fireblocks no longer updates a transaction
+var TerminalTransactionStatuses = []string{
+	TransactionStatusCompleted,
+	TransactionStatusCancelled,
+	TransactionStatusRejected,
+	TransactionStatusBlocked,
+	TransactionStatusFailed,
+	TransactionStatusTimeout,
+}
+
+// IsFailedTransactionStatus reports whether the status is a terminal, unsuccessful one
+func IsFailedTransactionStatus(status string) bool {
+	switch status {
+	case TransactionStatusCancelled, TransactionStatusRejected, TransactionStatusBlocked,
+		TransactionStatusFailed, TransactionStatusTimeout:
+		return true
+	}
+	return false
+}
+
+// IsTerminalTransactionStatus reports whether Fireblocks will no longer update the transaction
+func IsTerminalTransactionStatus(status string) bool {
+	return status == TransactionStatusCompleted || IsFailedTransactionStatus(status)
+}
+
+type TransferPeerPath struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type AmountInfo struct {
+	Amount          string `json:"amount"`
+	RequestedAmount string `json:"requestedAmount"`
+	NetAmount       string `json:"netAmount"`
+	AmountUSD       string `json:"amountUSD"`
+}
+
+type FeeInfo struct {
+	NetworkFee string `json:"networkFee"`
+	ServiceFee string `json:"serviceFee"`
+	GasPrice   string `json:"gasPrice"`
+}
+
+type TransactionResponse struct {
+	ID                 string           `json:"id"`
+	ExternalTxID       string           `json:"externalTxId"`
+	Status             string           `json:"status"`
+	SubStatus          string           `json:"subStatus"`
+	TxHash             string           `json:"txHash"`
+	Operation          string           `json:"operation"`
+	Note               string           `json:"note"`
+	AssetID            string           `json:"assetId"`
+	Source             TransferPeerPath `json:"source"`
+	Destination        TransferPeerPath `json:"destination"`
+	SourceAddress      string           `json:"sourceAddress"`
+	DestinationAddress string           `json:"destinationAddress"`
+	DestinationTag     string           `json:"destinationTag"`
+	AmountInfo         AmountInfo       `json:"amountInfo"`
+	FeeInfo            FeeInfo          `json:"feeInfo"`
+	FeeCurrency        string           `json:"feeCurrency"`
+	NumOfConfirmations int              `json:"numOfConfirmations"`
+	CreatedAt          int64            `json:"createdAt"`
+	LastUpdated        int64            `json:"lastUpdated"`
+}
+
+// ListTransactionsRequest holds the query filters of GET /v1/transactions; zero values are omitted
+type ListTransactionsRequest struct {
+	After    int64
+	Before   int64
+	Status   string
+	DestType string
+	DestID   string
+	OrderBy  string
+	Sort     string
+	Limit    int
+}
+
+const (
+	WebhookEventTransactionCreated       = "TRANSACTION_CREATED"
+	WebhookEventTransactionStatusUpdated = "TRANSACTION_STATUS_UPDATED"
+)
+
+type WebhookEvent struct {
+	Type      string          `json:"type"`
+	TenantID  string          `json:"tenantId"`
+	Timestamp int64           `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
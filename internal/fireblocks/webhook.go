@@ -0,0 +1,35 @@
+package fireblocks
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// WebhookSignatureHeader carries the base64 encoded RSA-SHA512 signature of the raw webhook body
+const WebhookSignatureHeader = "Fireblocks-Signature"
+
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+// VerifyWebhookSignature checks a webhook body against the signature sent by Fireblocks,
+// using the Fireblocks webhook public key of the workspace environment
+func VerifyWebhookSignature(publicKey *rsa.PublicKey, body []byte, signature string) error {
+	if signature == "" {
+		return fmt.Errorf("%w: missing signature", ErrInvalidWebhookSignature)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhookSignature, err)
+	}
+
+	digest := sha512.Sum512(body)
+	if err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA512, digest[:], sig); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhookSignature, err)
+	}
+
+	return nil
+}
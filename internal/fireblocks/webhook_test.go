@@ -0,0 +1,49 @@
+package fireblocks
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	body := []byte(`{"type":"TRANSACTION_CREATED","data":{"id":"tx-1"}}`)
+	digest := sha512.Sum512(body)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, signingKey, crypto.SHA512, digest[:])
+	assert.NoError(t, err)
+	signature := base64.StdEncoding.EncodeToString(sig)
+
+	tests := []struct {
+		name      string
+		publicKey *rsa.PublicKey
+		body      []byte
+		signature string
+		wantErr   bool
+	}{
+		{name: "valid", publicKey: &signingKey.PublicKey, body: body, signature: signature},
+		{name: "missing_signature", publicKey: &signingKey.PublicKey, body: body, signature: "", wantErr: true},
+		{name: "not_base64", publicKey: &signingKey.PublicKey, body: body, signature: "%%%", wantErr: true},
+		{name: "tampered_body", publicKey: &signingKey.PublicKey, body: []byte(`{"type":"TRANSACTION_CREATED"}`), signature: signature, wantErr: true},
+		{name: "wrong_key", publicKey: &otherKey.PublicKey, body: body, signature: signature, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.publicKey, tt.body, tt.signature)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"encoding/json"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/audit"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/model"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAuditEntriesLimit = 50
+	maxAuditEntriesLimit     = 500
+)
+
+type AuditLog interface {
+	List(filter audit.Filter) ([]model.AuditEntry, error)
+	Verify() (*audit.Verification, error)
+}
+
+type AuditHandler struct {
+	auditLog AuditLog
+}
+
+func NewAuditHandler(auditLog AuditLog) *AuditHandler {
+	return &AuditHandler{
+		auditLog: auditLog,
+	}
+}
+
+func (h *AuditHandler) ListAuditEntries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Actor:     query.Get("actor"),
+		Action:    query.Get("action"),
+		Target:    query.Get("target"),
+		Outcome:   model.AuditOutcome(strings.ToUpper(query.Get("outcome"))),
+		RequestID: query.Get("requestId"),
+		Limit:     defaultAuditEntriesLimit,
+	}
+
+	if filter.Outcome != "" && filter.Outcome != model.AuditOutcomeSuccess && filter.Outcome != model.AuditOutcomeFailure {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Outcome must be SUCCESS or FAILURE")
+		return
+	}
+	for _, bound := range []struct {
+		name string
+		dest **time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, fmt.Sprintf("Invalid %s, expected an RFC 3339 timestamp", bound.name))
+			return
+		}
+		*bound.dest = &t
+	}
+	if before := query.Get("before"); before != "" {
+		n, err := strconv.ParseInt(before, 10, 64)
+		if err != nil || n < 1 {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Invalid before")
+			return
+		}
+		filter.BeforeSequence = n
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxAuditEntriesLimit {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, fmt.Sprintf("Limit must be between 1 and %d", maxAuditEntriesLimit))
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := h.auditLog.List(filter)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list audit entries", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	response := ListAuditEntriesResponse{Entries: make([]AuditEntryResponse, 0, len(entries))}
+	for _, entry := range entries {
+		entryResponse := AuditEntryResponse{
+			Sequence:   entry.Sequence,
+			Actor:      entry.Actor,
+			Action:     entry.Action,
+			Target:     entry.Target,
+			RequestID:  entry.RequestID,
+			SourceIP:   entry.SourceIP,
+			Outcome:    string(entry.Outcome),
+			StatusCode: entry.StatusCode,
+			CreatedAt:  entry.CreatedAt,
+			PrevHash:   entry.PrevHash,
+			Hash:       entry.Hash,
+		}
+		if json.Valid([]byte(entry.Payload)) {
+			entryResponse.Payload = json.RawMessage(entry.Payload)
+		}
+		if entry.FireblocksIDs != "" {
+			entryResponse.FireblocksIDs = strings.Split(entry.FireblocksIDs, ",")
+		}
+		response.Entries = append(response.Entries, entryResponse)
+	}
+	if len(entries) == filter.Limit {
+		response.NextBefore = entries[len(entries)-1].Sequence
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "error", err)
+	}
+}
+
+func (h *AuditHandler) VerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	verification, err := h.auditLog.Verify()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to verify audit log", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	response := AuditVerificationResponse{
+		Valid:    verification.Valid,
+		Checked:  verification.Checked,
+		BrokenAt: verification.BrokenAt,
+		Reason:   verification.Reason,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "error", err)
+	}
+}
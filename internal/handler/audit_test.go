@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/audit"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type MockAuditLog struct {
+	Entries      []model.AuditEntry
+	Verification *audit.Verification
+	Error        error
+
+	ReceivedFilter audit.Filter
+}
+
+func (m *MockAuditLog) List(filter audit.Filter) ([]model.AuditEntry, error) {
+	m.ReceivedFilter = filter
+	return m.Entries, m.Error
+}
+
+func (m *MockAuditLog) Verify() (*audit.Verification, error) {
+	return m.Verification, m.Error
+}
+
+func TestListAuditEntries(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		auditLog *MockAuditLog
+		assert   func(t *testing.T, recorder *httptest.ResponseRecorder, auditLog *MockAuditLog)
+	}{
+		{
+			name:  "success_with_filters",
+			query: "?actor=alice&action=transfer.initiate&outcome=failure&since=2024-01-01T00:00:00Z&before=10&limit=1",
+			auditLog: &MockAuditLog{Entries: []model.AuditEntry{{
+				Sequence:      9,
+				Actor:         "alice",
+				Action:        "transfer.initiate",
+				Target:        "wallet-1",
+				Payload:       `{"amount":"0.5","destinationAddress":"[REDACTED]"}`,
+				Outcome:       model.AuditOutcomeFailure,
+				StatusCode:    400,
+				FireblocksIDs: "86",
+			}}},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, auditLog *MockAuditLog) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, "alice", auditLog.ReceivedFilter.Actor)
+				assert.Equal(t, "transfer.initiate", auditLog.ReceivedFilter.Action)
+				assert.Equal(t, model.AuditOutcomeFailure, auditLog.ReceivedFilter.Outcome)
+				assert.Equal(t, "2024-01-01T00:00:00Z", auditLog.ReceivedFilter.Since.Format("2006-01-02T15:04:05Z07:00"))
+				assert.Nil(t, auditLog.ReceivedFilter.Until)
+				assert.Equal(t, int64(10), auditLog.ReceivedFilter.BeforeSequence)
+				assert.Equal(t, 1, auditLog.ReceivedFilter.Limit)
+
+				var response ListAuditEntriesResponse
+				err := json.NewDecoder(recorder.Body).Decode(&response)
+				assert.NoError(t, err)
+				assert.Len(t, response.Entries, 1)
+				assert.JSONEq(t, `{"amount":"0.5","destinationAddress":"[REDACTED]"}`, string(response.Entries[0].Payload))
+				assert.Equal(t, []string{"86"}, response.Entries[0].FireblocksIDs)
+				assert.Equal(t, int64(9), response.NextBefore)
+			},
+		},
+		{
+			name:     "default_limit",
+			auditLog: &MockAuditLog{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, auditLog *MockAuditLog) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, defaultAuditEntriesLimit, auditLog.ReceivedFilter.Limit)
+				assert.JSONEq(t, `{"entries":[]}`, recorder.Body.String())
+			},
+		},
+		{
+			name:     "invalid_outcome",
+			query:    "?outcome=maybe",
+			auditLog: &MockAuditLog{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, auditLog *MockAuditLog) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "invalid_until",
+			query:    "?until=yesterday",
+			auditLog: &MockAuditLog{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, auditLog *MockAuditLog) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "repository_error",
+			auditLog: &MockAuditLog{Error: errors.New("database error")},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, auditLog *MockAuditLog) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAuditHandler(tt.auditLog)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /admin/audit", handler.ListAuditEntries)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/audit"+tt.query, nil)
+			recorder := httptest.NewRecorder()
+
+			mux.ServeHTTP(recorder, req)
+
+			tt.assert(t, recorder, tt.auditLog)
+		})
+	}
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	auditLog := &MockAuditLog{Verification: &audit.Verification{Valid: false, Checked: 4, BrokenAt: 5, Reason: "previous hash does not match"}}
+	handler := NewAuditHandler(auditLog)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/audit/verify", handler.VerifyAuditLog)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/audit/verify", nil)
+	recorder := httptest.NewRecorder()
+
+	mux.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"valid":false,"checked":4,"brokenAt":5,"reason":"previous hash does not match"}`, recorder.Body.String())
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/model"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type DepositRepository interface {
+	ListByWalletID(walletID string) ([]model.Deposit, error)
+}
+
+type DepositHandler struct {
+	walletRepo  WalletRepository
+	depositRepo DepositRepository
+}
+
+func NewDepositHandler(walletRepo WalletRepository, depositRepo DepositRepository) *DepositHandler {
+	return &DepositHandler{
+		walletRepo:  walletRepo,
+		depositRepo: depositRepo,
+	}
+}
+
+func (h *DepositHandler) ListDeposits(w http.ResponseWriter, r *http.Request) {
+	walletID := r.PathValue("walletId")
+
+	if walletID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID is required")
+		return
+	}
+
+	if _, err := h.walletRepo.GetByID(r.Context(), walletID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, http.StatusNotFound, apierror.WalletNotFound, "Wallet not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to get wallet", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	deposits, err := h.depositRepo.ListByWalletID(walletID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list deposits", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	response := ListDepositsResponse{Deposits: make([]DepositResponse, 0, len(deposits))}
+	for _, deposit := range deposits {
+		response.Deposits = append(response.Deposits, DepositResponse{
+			ID:                    deposit.ID,
+			TransactionID:         deposit.FireblocksTxID,
+			AssetID:               deposit.AssetID,
+			Amount:                deposit.Amount,
+			SourceAddress:         deposit.SourceAddress,
+			DestinationAddress:    deposit.DestinationAddress,
+			TxHash:                deposit.TxHash,
+			Status:                string(deposit.Status),
+			FireblocksStatus:      deposit.FireblocksStatus,
+			Confirmations:         deposit.Confirmations,
+			RequiredConfirmations: deposit.RequiredConfirmations,
+			CreditedAt:            deposit.CreditedAt,
+			CreatedAt:             deposit.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/logging"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+
+	// dependencyCheckTimeout bounds each dependency check, so that probes answer before their own timeout
+	dependencyCheckTimeout = 3 * time.Second
+	// fireblocksCheckTTL is how long the result of the Fireblocks check is reused, so that frequent probes do not
+	// spend the Fireblocks API quota
+	fireblocksCheckTTL = 5 * time.Second
+)
+
+type DatabasePinger interface {
+	PingContext(ctx context.Context) error
+}
+
+type HealthFireblocksClient interface {
+	ListVaultAccounts(ctx context.Context, page fireblocks.PageRequest) (*fireblocks.ListVaultAccountsResponse, int, error)
+}
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version   string
+	Commit    string
+	BuildTime string
+	GoVersion string
+}
+
+type dependencyCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+type HealthHandler struct {
+	checks    []dependencyCheck
+	buildInfo BuildInfo
+	startedAt time.Time
+}
+
+func NewHealthHandler(db DatabasePinger, fireblocksClient HealthFireblocksClient, buildInfo BuildInfo, startedAt time.Time) *HealthHandler {
+	return &HealthHandler{
+		checks: []dependencyCheck{
+			{name: "database", check: db.PingContext},
+			{name: "fireblocks", check: cachedCheck(fireblocksCheckTTL, func(ctx context.Context) error {
+				// the cheapest authenticated call, it also verifies the API key and the signing key
+				_, statusCode, err := fireblocksClient.ListVaultAccounts(ctx, fireblocks.PageRequest{Limit: 1})
+				if err != nil {
+					return err
+				}
+				if statusCode != http.StatusOK {
+					return fmt.Errorf("unexpected status code %d", statusCode)
+				}
+				return nil
+			})},
+		},
+		buildInfo: buildInfo,
+		startedAt: startedAt,
+	}
+}
+
+// cachedCheck reuses the result of check, passed or failed, for ttl. Concurrent probes wait for the check running
+// rather than running it again.
+func cachedCheck(ttl time.Duration, check func(ctx context.Context) error) func(ctx context.Context) error {
+	var (
+		mu        sync.Mutex
+		checkedAt time.Time
+		result    error
+	)
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if checkedAt.IsZero() || time.Since(checkedAt) >= ttl {
+			result = check(ctx)
+			checkedAt = time.Now()
+		}
+		return result
+	}
+}
+
+// Liveness only tells the process is serving requests, it must not depend on the database or Fireblocks
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	h.writeResponse(w, r, http.StatusOK, HealthResponse{Status: healthStatusOK})
+}
+
+// Readiness reports whether the database and Fireblocks are reachable, with a 503 if any of them is not
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	dependencies, ok := h.checkDependencies(r.Context())
+
+	response := HealthResponse{Status: healthStatusOK, Dependencies: dependencies}
+	statusCode := http.StatusOK
+	if !ok {
+		response.Status = healthStatusUnavailable
+		statusCode = http.StatusServiceUnavailable
+	}
+	h.writeResponse(w, r, statusCode, response)
+}
+
+// Status reports the build, uptime and dependency latencies. It always answers 200, the dependency
+// statuses tell whether the service is degraded.
+func (h *HealthHandler) Status(w http.ResponseWriter, r *http.Request) {
+	dependencies, ok := h.checkDependencies(r.Context())
+
+	response := StatusResponse{
+		Status:        healthStatusOK,
+		Version:       h.buildInfo.Version,
+		Commit:        h.buildInfo.Commit,
+		BuildTime:     h.buildInfo.BuildTime,
+		GoVersion:     h.buildInfo.GoVersion,
+		StartedAt:     h.startedAt,
+		UptimeSeconds: int64(time.Since(h.startedAt).Seconds()),
+		Dependencies:  dependencies,
+	}
+	if !ok {
+		response.Status = healthStatusUnavailable
+	}
+	h.writeResponse(w, r, http.StatusOK, response)
+}
+
+// checkDependencies runs the checks concurrently and returns their results, and whether all of them passed
+func (h *HealthHandler) checkDependencies(ctx context.Context) (map[string]DependencyStatusResponse, bool) {
+	results := make([]DependencyStatusResponse, len(h.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, dependencyCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.check(checkCtx)
+			results[i] = DependencyStatusResponse{
+				Status:    healthStatusOK,
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				// the details are only logged, the endpoints are not authenticated
+				logging.FromContext(ctx).Warn("Dependency check failed", "dependency", check.name, "error", err)
+				results[i].Status = healthStatusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+
+	dependencies := make(map[string]DependencyStatusResponse, len(h.checks))
+	ok := true
+	for i, check := range h.checks {
+		dependencies[check.name] = results[i]
+		ok = ok && results[i].Status == healthStatusOK
+	}
+	return dependencies, ok
+}
+
+func (h *HealthHandler) writeResponse(w http.ResponseWriter, r *http.Request, statusCode int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "error", err)
+	}
+}
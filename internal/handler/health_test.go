@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/fireblocks"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type MockDatabasePinger struct {
+	Error error
+}
+
+func (m *MockDatabasePinger) PingContext(_ context.Context) error {
+	return m.Error
+}
+
+type MockHealthFireblocksClient struct {
+	StatusCode   int
+	Error        error
+	ReceivedPage fireblocks.PageRequest
+	Calls        int
+}
+
+func (m *MockHealthFireblocksClient) ListVaultAccounts(_ context.Context, page fireblocks.PageRequest) (*fireblocks.ListVaultAccountsResponse, int, error) {
+	m.ReceivedPage = page
+	m.Calls++
+	return &fireblocks.ListVaultAccountsResponse{}, m.StatusCode, m.Error
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	buildInfo := BuildInfo{Version: "1.2.0", Commit: "abc123", GoVersion: "go1.24.4"}
+
+	tests := []struct {
+		name     string
+		path     string
+		db       *MockDatabasePinger
+		client   *MockHealthFireblocksClient
+		expected int
+		assert   func(t *testing.T, body []byte)
+	}{
+		{
+			name:     "liveness",
+			path:     "/healthz",
+			db:       &MockDatabasePinger{Error: errors.New("connection refused")},
+			client:   &MockHealthFireblocksClient{Error: errors.New("timeout")},
+			expected: http.StatusOK,
+			assert: func(t *testing.T, body []byte) {
+				assert.JSONEq(t, `{"status":"ok"}`, string(body))
+			},
+		},
+		{
+			name:     "ready",
+			path:     "/readyz",
+			db:       &MockDatabasePinger{},
+			client:   &MockHealthFireblocksClient{StatusCode: http.StatusOK},
+			expected: http.StatusOK,
+			assert: func(t *testing.T, body []byte) {
+				var response HealthResponse
+				assert.NoError(t, json.Unmarshal(body, &response))
+				assert.Equal(t, "ok", response.Status)
+				assert.Equal(t, "ok", response.Dependencies["database"].Status)
+				assert.Equal(t, "ok", response.Dependencies["fireblocks"].Status)
+			},
+		},
+		{
+			name:     "database_down",
+			path:     "/readyz",
+			db:       &MockDatabasePinger{Error: errors.New("connection refused")},
+			client:   &MockHealthFireblocksClient{StatusCode: http.StatusOK},
+			expected: http.StatusServiceUnavailable,
+			assert: func(t *testing.T, body []byte) {
+				var response HealthResponse
+				assert.NoError(t, json.Unmarshal(body, &response))
+				assert.Equal(t, "unavailable", response.Status)
+				assert.Equal(t, "unavailable", response.Dependencies["database"].Status)
+				assert.Equal(t, "ok", response.Dependencies["fireblocks"].Status)
+				assert.NotContains(t, string(body), "connection refused")
+			},
+		},
+		{
+			name:     "fireblocks_unauthorized",
+			path:     "/readyz",
+			db:       &MockDatabasePinger{},
+			client:   &MockHealthFireblocksClient{StatusCode: http.StatusUnauthorized},
+			expected: http.StatusServiceUnavailable,
+			assert: func(t *testing.T, body []byte) {
+				var response HealthResponse
+				assert.NoError(t, json.Unmarshal(body, &response))
+				assert.Equal(t, "unavailable", response.Dependencies["fireblocks"].Status)
+			},
+		},
+		{
+			name:     "status_degraded",
+			path:     "/status",
+			db:       &MockDatabasePinger{},
+			client:   &MockHealthFireblocksClient{Error: errors.New("timeout")},
+			expected: http.StatusOK,
+			assert: func(t *testing.T, body []byte) {
+				var response StatusResponse
+				assert.NoError(t, json.Unmarshal(body, &response))
+				assert.Equal(t, "unavailable", response.Status)
+				assert.Equal(t, "1.2.0", response.Version)
+				assert.Equal(t, "abc123", response.Commit)
+				assert.Equal(t, "go1.24.4", response.GoVersion)
+				assert.GreaterOrEqual(t, response.UptimeSeconds, int64(3600))
+				assert.Equal(t, "ok", response.Dependencies["database"].Status)
+				assert.Equal(t, "unavailable", response.Dependencies["fireblocks"].Status)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthHandler := NewHealthHandler(tt.db, tt.client, buildInfo, time.Now().Add(-time.Hour))
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /healthz", healthHandler.Liveness)
+			mux.HandleFunc("GET /readyz", healthHandler.Readiness)
+			mux.HandleFunc("GET /status", healthHandler.Status)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expected, recorder.Code)
+			assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+			tt.assert(t, recorder.Body.Bytes())
+		})
+	}
+}
+
+func TestReadinessChecksFireblocksCheaply(t *testing.T) {
+	client := &MockHealthFireblocksClient{StatusCode: http.StatusOK}
+	healthHandler := NewHealthHandler(&MockDatabasePinger{}, client, BuildInfo{}, time.Now())
+
+	healthHandler.Readiness(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	assert.Equal(t, fireblocks.PageRequest{Limit: 1}, client.ReceivedPage)
+}
+
+func TestReadinessReusesFireblocksCheck(t *testing.T) {
+	client := &MockHealthFireblocksClient{StatusCode: http.StatusServiceUnavailable}
+	healthHandler := NewHealthHandler(&MockDatabasePinger{}, client, BuildInfo{}, time.Now())
+
+	for range 3 {
+		recorder := httptest.NewRecorder()
+		healthHandler.Readiness(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	}
+
+	assert.Equal(t, 1, client.Calls)
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/ledger"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/model"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultJournalEntriesLimit = 100
+	maxJournalEntriesLimit     = 1000
+)
+
+type Ledger interface {
+	WalletBalance(walletID, assetID string) (*ledger.WalletBalance, error)
+	Allocate(walletID, assetID, from, to, amount, description string) (*model.JournalEntry, error)
+	ListEntries(walletID string, limit int) ([]model.JournalEntry, error)
+}
+
+type LedgerHandler struct {
+	walletRepo WalletRepository
+	ledger     Ledger
+}
+
+func NewLedgerHandler(walletRepo WalletRepository, ledger Ledger) *LedgerHandler {
+	return &LedgerHandler{
+		walletRepo: walletRepo,
+		ledger:     ledger,
+	}
+}
+
+func (h *LedgerHandler) GetLedgerBalance(w http.ResponseWriter, r *http.Request) {
+	walletID := r.PathValue("walletId")
+	assetID := r.PathValue("assetId")
+
+	if walletID == "" || assetID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID and Asset ID are required")
+		return
+	}
+
+	if !h.walletExists(w, r, walletID) {
+		return
+	}
+
+	balance, err := h.ledger.WalletBalance(walletID, assetID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to get ledger balance", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	response := LedgerBalanceResponse{
+		AssetID:     balance.AssetID,
+		Total:       balance.Total,
+		SubAccounts: make([]SubAccountBalanceResponse, 0, len(balance.SubAccounts)),
+	}
+	for _, subAccount := range balance.SubAccounts {
+		response.SubAccounts = append(response.SubAccounts, SubAccountBalanceResponse{
+			Name:    subAccount.Name,
+			Balance: subAccount.Balance,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "error", err)
+	}
+}
+
+func (h *LedgerHandler) CreateAllocation(w http.ResponseWriter, r *http.Request) {
+	walletID := r.PathValue("walletId")
+	assetID := r.PathValue("assetId")
+
+	if walletID == "" || assetID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID and Asset ID are required")
+		return
+	}
+
+	var req CreateAllocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
+		return
+	}
+
+	if req.FromSubAccount == "" {
+		req.FromSubAccount = ledger.MainSubAccount
+	}
+	if req.ToSubAccount == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Destination sub-account is required")
+		return
+	}
+	if req.Amount == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Amount is required")
+		return
+	}
+
+	if !h.walletExists(w, r, walletID) {
+		return
+	}
+
+	entry, err := h.ledger.Allocate(walletID, assetID, req.FromSubAccount, req.ToSubAccount, req.Amount, req.Description)
+	if err != nil {
+		switch {
+		case errors.Is(err, ledger.ErrInvalidAmount):
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidAmount, "Invalid amount format")
+		case errors.Is(err, ledger.ErrInvalidSubAccount):
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Invalid sub-account")
+		case errors.Is(err, ledger.ErrInsufficientFunds):
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InsufficientBalance, "Insufficient balance")
+		default:
+			logging.FromContext(r.Context()).Error("Failed to allocate funds", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(newJournalEntryResponse(*entry)); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "error", err)
+	}
+}
+
+func (h *LedgerHandler) ListLedgerEntries(w http.ResponseWriter, r *http.Request) {
+	walletID := r.PathValue("walletId")
+
+	if walletID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID is required")
+		return
+	}
+
+	limit := defaultJournalEntriesLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxJournalEntriesLimit {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	if !h.walletExists(w, r, walletID) {
+		return
+	}
+
+	entries, err := h.ledger.ListEntries(walletID, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list journal entries", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	response := ListJournalEntriesResponse{Entries: make([]JournalEntryResponse, 0, len(entries))}
+	for _, entry := range entries {
+		response.Entries = append(response.Entries, newJournalEntryResponse(entry))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "error", err)
+	}
+}
+
+// walletExists writes the error response and returns false when the wallet cannot be loaded
+func (h *LedgerHandler) walletExists(w http.ResponseWriter, r *http.Request, walletID string) bool {
+	ctx := r.Context()
+	if _, err := h.walletRepo.GetByID(ctx, walletID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, http.StatusNotFound, apierror.WalletNotFound, "Wallet not found")
+			return false
+		}
+		logging.FromContext(ctx).Error("Failed to get wallet", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return false
+	}
+	return true
+}
+
+func newJournalEntryResponse(entry model.JournalEntry) JournalEntryResponse {
+	response := JournalEntryResponse{
+		ID:          entry.ID,
+		Kind:        string(entry.Kind),
+		Reference:   entry.Reference,
+		Description: entry.Description,
+		CreatedAt:   entry.CreatedAt,
+		Postings:    make([]PostingResponse, 0, len(entry.Postings)),
+	}
+	for _, posting := range entry.Postings {
+		postingResponse := PostingResponse{
+			AccountID: posting.AccountID,
+			AssetID:   posting.AssetID,
+			Amount:    ledger.NormalizeAmount(posting.Amount),
+		}
+		if posting.Account != nil {
+			postingResponse.Account = posting.Account.Code
+		}
+		response.Postings = append(response.Postings, postingResponse)
+	}
+	return response
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"firego-wallet-service/internal/ledger"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type MockLedger struct {
+	WalletBalanceResponse *ledger.WalletBalance
+	AllocateEntry         *model.JournalEntry
+	Entries               []model.JournalEntry
+	Error                 error
+
+	ReceivedFrom  string
+	ReceivedTo    string
+	ReceivedLimit int
+}
+
+func (m *MockLedger) WalletBalance(_, _ string) (*ledger.WalletBalance, error) {
+	return m.WalletBalanceResponse, m.Error
+}
+
+func (m *MockLedger) Allocate(_, _, from, to, _, _ string) (*model.JournalEntry, error) {
+	m.ReceivedFrom = from
+	m.ReceivedTo = to
+	return m.AllocateEntry, m.Error
+}
+
+func (m *MockLedger) ListEntries(_ string, limit int) ([]model.JournalEntry, error) {
+	m.ReceivedLimit = limit
+	return m.Entries, m.Error
+}
+
+func TestGetLedgerBalance(t *testing.T) {
+	tests := []struct {
+		name       string
+		walletRepo *MockWalletRepository
+		ledger     *MockLedger
+		assert     func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "success",
+			walletRepo: &MockWalletRepository{GetByIDWallet: &model.Wallet{ID: "123"}},
+			ledger: &MockLedger{WalletBalanceResponse: &ledger.WalletBalance{
+				AssetID:     "BTC_TEST",
+				Total:       "1",
+				SubAccounts: []ledger.SubAccountBalance{{Name: "main", Balance: "0.6"}, {Name: "payroll", Balance: "0.4"}},
+			}},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.JSONEq(t, `{"assetId":"BTC_TEST","total":"1","subAccounts":[{"name":"main","balance":"0.6"},{"name":"payroll","balance":"0.4"}]}`, recorder.Body.String())
+			},
+		},
+		{
+			name:       "wallet_not_found",
+			walletRepo: &MockWalletRepository{GetByIDError: gorm.ErrRecordNotFound},
+			ledger:     &MockLedger{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:       "ledger_error",
+			walletRepo: &MockWalletRepository{GetByIDWallet: &model.Wallet{ID: "123"}},
+			ledger:     &MockLedger{Error: assert.AnError},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewLedgerHandler(tt.walletRepo, tt.ledger)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/ledger/balance", handler.GetLedgerBalance)
+
+			req := httptest.NewRequest(http.MethodGet, "/wallets/123/assets/BTC_TEST/ledger/balance", nil)
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, req)
+
+			tt.assert(t, recorder)
+		})
+	}
+}
+
+func TestCreateAllocation(t *testing.T) {
+	entry := &model.JournalEntry{
+		ID:        "entry-1",
+		Kind:      model.JournalEntryKindAllocation,
+		Reference: "ref-1",
+		CreatedAt: time.Now(),
+		Postings: []model.Posting{
+			{AccountID: "a-2", AssetID: "BTC_TEST", Amount: "0.4", Account: &model.LedgerAccount{Code: "wallet:123:BTC_TEST:payroll"}},
+			{AccountID: "a-1", AssetID: "BTC_TEST", Amount: "-0.4", Account: &model.LedgerAccount{Code: "wallet:123:BTC_TEST:main"}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		ledger *MockLedger
+		assert func(t *testing.T, recorder *httptest.ResponseRecorder, ledger *MockLedger)
+	}{
+		{
+			name:   "success_defaults_to_main",
+			body:   `{"toSubAccount":"payroll","amount":"0.4"}`,
+			ledger: &MockLedger{AllocateEntry: entry},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, ledger *MockLedger) {
+				assert.Equal(t, http.StatusCreated, recorder.Code)
+				assert.Equal(t, "main", ledger.ReceivedFrom)
+				assert.Equal(t, "payroll", ledger.ReceivedTo)
+
+				var response JournalEntryResponse
+				err := json.NewDecoder(recorder.Body).Decode(&response)
+				assert.NoError(t, err)
+				assert.Equal(t, "ALLOCATION", response.Kind)
+				assert.Len(t, response.Postings, 2)
+				assert.Equal(t, "wallet:123:BTC_TEST:payroll", response.Postings[0].Account)
+				assert.Equal(t, "-0.4", response.Postings[1].Amount)
+			},
+		},
+		{
+			name:   "missing_destination",
+			body:   `{"amount":"0.4"}`,
+			ledger: &MockLedger{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, ledger *MockLedger) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), "Destination sub-account is required")
+			},
+		},
+		{
+			name:   "insufficient_funds",
+			body:   `{"toSubAccount":"payroll","amount":"5"}`,
+			ledger: &MockLedger{Error: ledger.ErrInsufficientFunds},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, ledger *MockLedger) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), "Insufficient balance")
+			},
+		},
+		{
+			name:   "invalid_amount",
+			body:   `{"toSubAccount":"payroll","amount":"abc"}`,
+			ledger: &MockLedger{Error: ledger.ErrInvalidAmount},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, ledger *MockLedger) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), "Invalid amount format")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			walletRepo := &MockWalletRepository{GetByIDWallet: &model.Wallet{ID: "123"}}
+			handler := NewLedgerHandler(walletRepo, tt.ledger)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /wallets/{walletId}/assets/{assetId}/ledger/allocations", handler.CreateAllocation)
+
+			req := httptest.NewRequest(http.MethodPost, "/wallets/123/assets/BTC_TEST/ledger/allocations", bytes.NewReader([]byte(tt.body)))
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, req)
+
+			tt.assert(t, recorder, tt.ledger)
+		})
+	}
+}
+
+func TestListLedgerEntries(t *testing.T) {
+	mockLedger := &MockLedger{Entries: []model.JournalEntry{{ID: "entry-1", Kind: model.JournalEntryKindDeposit, Reference: "tx-1"}}}
+	handler := NewLedgerHandler(&MockWalletRepository{GetByIDWallet: &model.Wallet{ID: "123"}}, mockLedger)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /wallets/{walletId}/ledger/entries", handler.ListLedgerEntries)
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/wallets/123/ledger/entries?limit=10", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 10, mockLedger.ReceivedLimit)
+
+	var response ListJournalEntriesResponse
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	assert.Len(t, response.Entries, 1)
+	assert.Equal(t, "DEPOSIT", response.Entries[0].Kind)
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/wallets/123/ledger/entries?limit=5000", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/audit"
+	"firego-wallet-service/internal/cache"
+	"firego-wallet-service/internal/ledger"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/model"
+	"firego-wallet-service/internal/tracing"
+	"firego-wallet-service/internal/transfer"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"math/big"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// PayoutService stores the payouts and submits them in the background, see payout.Service
+type PayoutService interface {
+	Create(ctx context.Context, payout *model.Payout) error
+	Get(ctx context.Context, id string) (*model.Payout, error)
+	// Reserve runs fn with the amount reserved by the pending payouts of the wallet asset, see payout.Service.Reserve
+	Reserve(ctx context.Context, walletID, assetID string, fn func(ctx context.Context, reserved *big.Rat) error) error
+}
+
+type PayoutHandler struct {
+	walletRepo WalletRepository
+	payouts    PayoutService
+	balances   BalanceCache
+	// maxLines is the most lines a payout may have
+	maxLines int
+}
+
+func NewPayoutHandler(walletRepo WalletRepository, payouts PayoutService, balances BalanceCache, maxLines int) *PayoutHandler {
+	return &PayoutHandler{
+		walletRepo: walletRepo,
+		payouts:    payouts,
+		balances:   balances,
+		maxLines:   maxLines,
+	}
+}
+
+// CreatePayout accepts a payout of an asset out of the wallet, given as JSON or as CSV with the asset in the query.
+// Its total is checked once against the available balance, then the lines are submitted in the background and the
+// payout is answered right away.
+func (h *PayoutHandler) CreatePayout(w http.ResponseWriter, r *http.Request) {
+	walletID := r.PathValue("walletId")
+
+	ctx, span := tracer.Start(r.Context(), "PayoutHandler.CreatePayout")
+	defer span.End()
+	span.SetAttributes(tracing.WalletID.String(walletID))
+
+	if walletID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID is required")
+		return
+	}
+
+	var req CreatePayoutRequest
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		lines, err := parsePayoutCSV(r.Body)
+		if err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid CSV body: "+err.Error())
+			return
+		}
+		req = CreatePayoutRequest{AssetID: r.URL.Query().Get("assetId"), Lines: lines}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
+		return
+	}
+
+	total, apiErr := h.validate(req)
+	if apiErr != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apiErr.Code, apiErr.Message)
+		return
+	}
+	span.SetAttributes(tracing.AssetID.String(req.AssetID))
+
+	wallet, err := h.walletRepo.GetByID(ctx, walletID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, http.StatusNotFound, apierror.WalletNotFound, "Wallet not found")
+			return
+		}
+		logging.FromContext(ctx).Error("Failed to get wallet", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	span.SetAttributes(tracing.VaultAccountID.String(wallet.VaultAccountID))
+
+	payout := model.Payout{
+		WalletID:       wallet.ID,
+		VaultAccountID: wallet.VaultAccountID,
+		AssetID:        req.AssetID,
+		Total:          ledger.FormatAmount(total),
+		Lines:          make([]model.PayoutLine, 0, len(req.Lines)),
+	}
+	for _, line := range req.Lines {
+		payout.Lines = append(payout.Lines, model.PayoutLine{
+			DestinationAddress: line.DestinationAddress,
+			Amount:             line.Amount,
+			Note:               line.Note,
+		})
+	}
+
+	// the whole payout is checked once, against a fresh balance less the payouts not submitted yet, and created before
+	// the next payout or transfer of the wallet asset is checked
+	err = h.payouts.Reserve(ctx, wallet.ID, req.AssetID, func(ctx context.Context, reserved *big.Rat) error {
+		balanceResp, statusCode, err := h.balances.GetVaultAccountAssetBalance(cache.Bypass(ctx), wallet.VaultAccountID, req.AssetID)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to get balance for validation", "error", err)
+			return transfer.FireblocksError(statusCode, "Invalid asset or wallet", "Unable to validate balance")
+		}
+		available, err := ledger.ParseAmount(balanceResp.Available)
+		if err != nil {
+			logging.FromContext(ctx).Error("Invalid balance format from Fireblocks", "available", balanceResp.Available)
+			return &transfer.Error{StatusCode: http.StatusInternalServerError, Code: apierror.ServiceUnavailable, Message: "Unable to validate balance"}
+		}
+		if total.Cmp(available.Sub(available, reserved)) > 0 {
+			logging.FromContext(ctx).Info("Insufficient balance", "requested", ledger.FormatAmount(total), "available", ledger.FormatAmount(available))
+			return &transfer.Error{StatusCode: http.StatusBadRequest, Code: apierror.InsufficientBalance, Message: "Insufficient balance"}
+		}
+
+		if err = h.payouts.Create(ctx, &payout); err != nil {
+			logging.FromContext(ctx).Error("Failed to create payout", "error", err)
+			return &transfer.Error{StatusCode: http.StatusInternalServerError, Code: apierror.Internal, Message: "Failed to create payout"}
+		}
+		return nil
+	})
+	if err != nil {
+		var transferErr *transfer.Error
+		if !errors.As(err, &transferErr) {
+			// the reservation itself failed, or the payout was not committed
+			logging.FromContext(ctx).Error("Failed to reserve payout", "error", err)
+			err = &transfer.Error{StatusCode: http.StatusInternalServerError, Code: apierror.Internal, Message: "Failed to create payout"}
+		}
+		writeError(w, r, err)
+		return
+	}
+	audit.SetTarget(ctx, payout.ID)
+	audit.AddFireblocksID(ctx, wallet.VaultAccountID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err = json.NewEncoder(w).Encode(newPayoutResponse(&payout)); err != nil {
+		logging.FromContext(ctx).Error("Failed to encode response", "error", err)
+	}
+}
+
+// validate checks the payout and returns its total, or why it is rejected
+func (h *PayoutHandler) validate(req CreatePayoutRequest) (*big.Rat, *apierror.Error) {
+	if req.AssetID == "" {
+		return nil, &apierror.Error{Code: apierror.InvalidRequest, Message: "Asset ID is required"}
+	}
+	if len(req.Lines) == 0 {
+		return nil, &apierror.Error{Code: apierror.InvalidRequest, Message: "At least one line is required"}
+	}
+	if len(req.Lines) > h.maxLines {
+		return nil, &apierror.Error{Code: apierror.InvalidRequest, Message: fmt.Sprintf("At most %d lines can be paid out at once", h.maxLines)}
+	}
+
+	total := new(big.Rat)
+	for i, line := range req.Lines {
+		// lines are numbered from 1, like the payout lines they become
+		if line.DestinationAddress == "" {
+			return nil, &apierror.Error{Code: apierror.InvalidRequest, Message: fmt.Sprintf("Line %d: destination address is required", i+1)}
+		}
+		amount, err := ledger.ParseAmount(line.Amount)
+		if err != nil {
+			return nil, &apierror.Error{Code: apierror.InvalidAmount, Message: fmt.Sprintf("Line %d: invalid amount format", i+1)}
+		}
+		if amount.Sign() == 0 {
+			return nil, &apierror.Error{Code: apierror.InvalidAmount, Message: fmt.Sprintf("Line %d: amount must be greater than zero", i+1)}
+		}
+		total.Add(total, amount)
+	}
+	return total, nil
+}
+
+// parsePayoutCSV reads the lines of a payout from CSV with a header row naming the destinationAddress, amount and
+// optional note columns, in any order
+func parsePayoutCSV(body io.Reader) ([]CreatePayoutLine, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("missing header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// spreadsheets may start the file with a byte order mark
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	destination, ok := columns["destinationAddress"]
+	if !ok {
+		return nil, errors.New("missing destinationAddress column")
+	}
+	amount, ok := columns["amount"]
+	if !ok {
+		return nil, errors.New("missing amount column")
+	}
+	note, hasNote := columns["note"]
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]CreatePayoutLine, 0, len(records))
+	for _, record := range records {
+		line := CreatePayoutLine{
+			DestinationAddress: strings.TrimSpace(record[destination]),
+			Amount:             strings.TrimSpace(record[amount]),
+		}
+		if hasNote {
+			line.Note = record[note]
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+func (h *PayoutHandler) GetPayout(w http.ResponseWriter, r *http.Request) {
+	walletID := r.PathValue("walletId")
+	payoutID := r.PathValue("payoutId")
+
+	ctx, span := tracer.Start(r.Context(), "PayoutHandler.GetPayout")
+	defer span.End()
+	span.SetAttributes(tracing.WalletID.String(walletID))
+
+	if walletID == "" || payoutID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID and payout ID are required")
+		return
+	}
+
+	payout, err := h.payouts.Get(ctx, payoutID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logging.FromContext(ctx).Error("Failed to get payout", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	// the payouts of other wallets are not found either
+	if err != nil || payout.WalletID != walletID {
+		apierror.Write(w, r, http.StatusNotFound, apierror.PayoutNotFound, "Payout not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(newPayoutResponse(payout)); err != nil {
+		logging.FromContext(ctx).Error("Failed to encode response", "error", err)
+	}
+}
+
+func newPayoutResponse(payout *model.Payout) PayoutResponse {
+	response := PayoutResponse{
+		ID:          payout.ID,
+		WalletID:    payout.WalletID,
+		AssetID:     payout.AssetID,
+		Total:       payout.Total,
+		Status:      string(payout.Status),
+		CreatedAt:   payout.CreatedAt,
+		CompletedAt: payout.CompletedAt,
+		Lines:       make([]PayoutLineResponse, 0, len(payout.Lines)),
+	}
+	for _, line := range payout.Lines {
+		response.Lines = append(response.Lines, PayoutLineResponse{
+			Line:               line.Line,
+			DestinationAddress: line.DestinationAddress,
+			Amount:             line.Amount,
+			Note:               line.Note,
+			Status:             string(line.Status),
+			TransactionID:      line.FireblocksTxID,
+			Error:              line.Error,
+		})
+	}
+	return response
+}
@@ -0,0 +1,308 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockPayoutService struct {
+	Payouts  map[string]*model.Payout
+	Reserved string
+	Created  *model.Payout
+	GetError error
+}
+
+func (m *MockPayoutService) Create(_ context.Context, payout *model.Payout) error {
+	payout.ID = "payout-1"
+	payout.Status = model.PayoutStatusPending
+	payout.CreatedAt = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := range payout.Lines {
+		payout.Lines[i].Line = i + 1
+		payout.Lines[i].Status = model.PayoutLineStatusPending
+	}
+	m.Created = payout
+	return nil
+}
+
+func (m *MockPayoutService) Get(_ context.Context, id string) (*model.Payout, error) {
+	if m.GetError != nil {
+		return nil, m.GetError
+	}
+	payout, ok := m.Payouts[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return payout, nil
+}
+
+func (m *MockPayoutService) Reserve(ctx context.Context, _, _ string, fn func(ctx context.Context, reserved *big.Rat) error) error {
+	reserved, _ := new(big.Rat).SetString(m.Reserved)
+	return fn(ctx, reserved)
+}
+
+func newTestPayoutHandler(payouts *MockPayoutService, available string) *PayoutHandler {
+	walletRepo := &MockWalletRepository{GetByIDWallet: &model.Wallet{ID: "wallet-1", VaultAccountID: "7"}}
+	client := &MockFireblocksClient{
+		GetVaultAccountAssetBalanceResponse: &fireblocks.GetVaultAccountAssetBalanceResponse{ID: "ETH_TEST5", Available: available},
+		StatusCode:                          http.StatusOK,
+	}
+	return NewPayoutHandler(walletRepo, payouts, uncachedBalances(client), 3)
+}
+
+func createPayout(h *PayoutHandler, target, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.SetPathValue("walletId", "wallet-1")
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	recorder := httptest.NewRecorder()
+	h.CreatePayout(recorder, req)
+	return recorder
+}
+
+func TestCreatePayout(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "json",
+			target:      "/wallets/wallet-1/payouts",
+			contentType: "application/json",
+			body: `{"assetId": "ETH_TEST5", "lines": [
+				{"destinationAddress": "0xalice", "amount": "3", "note": "June salary"},
+				{"destinationAddress": "0xbob", "amount": "4.5"}
+			]}`,
+		},
+		{
+			name:        "csv",
+			target:      "/wallets/wallet-1/payouts?assetId=ETH_TEST5",
+			contentType: "text/csv; charset=utf-8",
+			body:        "\ufeffamount, destinationAddress, note\n3, 0xalice,June salary\n4.5,0xbob,\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payouts := &MockPayoutService{Reserved: "2.5"}
+			h := newTestPayoutHandler(payouts, "10")
+
+			recorder := createPayout(h, tt.target, tt.contentType, tt.body)
+
+			assert.Equal(t, http.StatusAccepted, recorder.Code)
+			var response PayoutResponse
+			assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+			assert.Equal(t, PayoutResponse{
+				ID:        "payout-1",
+				WalletID:  "wallet-1",
+				AssetID:   "ETH_TEST5",
+				Total:     "7.5",
+				Status:    "PENDING",
+				CreatedAt: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+				Lines: []PayoutLineResponse{
+					{Line: 1, DestinationAddress: "0xalice", Amount: "3", Note: "June salary", Status: "PENDING"},
+					{Line: 2, DestinationAddress: "0xbob", Amount: "4.5", Status: "PENDING"},
+				},
+			}, response)
+			assert.Equal(t, "7", payouts.Created.VaultAccountID)
+		})
+	}
+}
+
+func TestCreatePayoutInsufficientBalance(t *testing.T) {
+	// the 10 available are reserved for 4 by the payouts not submitted yet
+	payouts := &MockPayoutService{Reserved: "4"}
+	h := newTestPayoutHandler(payouts, "10")
+
+	recorder := createPayout(h, "/wallets/wallet-1/payouts", "application/json",
+		`{"assetId": "ETH_TEST5", "lines": [{"destinationAddress": "0xalice", "amount": "6.000000000000000001"}]}`)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	var response apierror.Error
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	assert.Equal(t, apierror.InsufficientBalance, response.Code)
+	assert.Nil(t, payouts.Created)
+}
+
+func TestCreatePayoutValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		wantCode    string
+		wantMessage string
+	}{
+		{
+			name:        "missing_asset",
+			body:        `{"lines": [{"destinationAddress": "0xalice", "amount": "1"}]}`,
+			wantCode:    apierror.InvalidRequest,
+			wantMessage: "Asset ID is required",
+		},
+		{
+			name:        "no_lines",
+			body:        `{"assetId": "ETH_TEST5", "lines": []}`,
+			wantCode:    apierror.InvalidRequest,
+			wantMessage: "At least one line is required",
+		},
+		{
+			name:        "too_many_lines",
+			body:        `{"assetId": "ETH_TEST5", "lines": [{"destinationAddress": "a", "amount": "1"}, {"destinationAddress": "b", "amount": "1"}, {"destinationAddress": "c", "amount": "1"}, {"destinationAddress": "d", "amount": "1"}]}`,
+			wantCode:    apierror.InvalidRequest,
+			wantMessage: "At most 3 lines can be paid out at once",
+		},
+		{
+			name:        "missing_destination",
+			body:        `{"assetId": "ETH_TEST5", "lines": [{"destinationAddress": "a", "amount": "1"}, {"amount": "1"}]}`,
+			wantCode:    apierror.InvalidRequest,
+			wantMessage: "Line 2: destination address is required",
+		},
+		{
+			name:        "invalid_amount",
+			body:        `{"assetId": "ETH_TEST5", "lines": [{"destinationAddress": "a", "amount": "1e3"}]}`,
+			wantCode:    apierror.InvalidAmount,
+			wantMessage: "Line 1: invalid amount format",
+		},
+		{
+			name:        "zero_amount",
+			body:        `{"assetId": "ETH_TEST5", "lines": [{"destinationAddress": "a", "amount": "0.0"}]}`,
+			wantCode:    apierror.InvalidAmount,
+			wantMessage: "Line 1: amount must be greater than zero",
+		},
+		{
+			name:        "csv_missing_asset",
+			contentType: "text/csv",
+			body:        "destinationAddress,amount\na,1\n",
+			wantCode:    apierror.InvalidRequest,
+			wantMessage: "Asset ID is required",
+		},
+		{
+			name:        "csv_missing_column",
+			target:      "/wallets/wallet-1/payouts?assetId=ETH_TEST5",
+			contentType: "text/csv",
+			body:        "address,amount\na,1\n",
+			wantCode:    apierror.InvalidBody,
+			wantMessage: "Invalid CSV body: missing destinationAddress column",
+		},
+		{
+			name:        "csv_empty",
+			target:      "/wallets/wallet-1/payouts?assetId=ETH_TEST5",
+			contentType: "text/csv",
+			wantCode:    apierror.InvalidBody,
+			wantMessage: "Invalid CSV body: missing header row",
+		},
+		{
+			name:        "csv_wrong_number_of_fields",
+			target:      "/wallets/wallet-1/payouts?assetId=ETH_TEST5",
+			contentType: "text/csv",
+			body:        "destinationAddress,amount\na,1,extra\n",
+			wantCode:    apierror.InvalidBody,
+			wantMessage: "Invalid CSV body: record on line 2: wrong number of fields",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.target == "" {
+				tt.target = "/wallets/wallet-1/payouts"
+			}
+			if tt.contentType == "" {
+				tt.contentType = "application/json"
+			}
+			payouts := &MockPayoutService{Reserved: "0"}
+			h := newTestPayoutHandler(payouts, "10")
+
+			recorder := createPayout(h, tt.target, tt.contentType, tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			var response apierror.Error
+			assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+			assert.Equal(t, apierror.Error{Code: tt.wantCode, Message: tt.wantMessage}, response)
+			assert.Nil(t, payouts.Created)
+		})
+	}
+}
+
+func TestCreatePayoutWalletNotFound(t *testing.T) {
+	payouts := &MockPayoutService{Reserved: "0"}
+	h := NewPayoutHandler(&MockWalletRepository{GetByIDError: gorm.ErrRecordNotFound}, payouts, uncachedBalances(&MockFireblocksClient{}), 3)
+
+	recorder := createPayout(h, "/wallets/wallet-1/payouts", "application/json",
+		`{"assetId": "ETH_TEST5", "lines": [{"destinationAddress": "0xalice", "amount": "1"}]}`)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestGetPayout(t *testing.T) {
+	completedAt := time.Date(2024, 6, 1, 12, 5, 0, 0, time.UTC)
+	payouts := &MockPayoutService{Payouts: map[string]*model.Payout{
+		"payout-1": {
+			ID:          "payout-1",
+			WalletID:    "wallet-1",
+			AssetID:     "ETH_TEST5",
+			Total:       "3",
+			Status:      model.PayoutStatusPartial,
+			CompletedAt: &completedAt,
+			Lines: []model.PayoutLine{
+				{Line: 1, DestinationAddress: "0xalice", Amount: "1", Status: model.PayoutLineStatusSubmitted, FireblocksTxID: "tx-1"},
+				{Line: 2, DestinationAddress: "0xbob", Amount: "2", Status: model.PayoutLineStatusFailed, Error: "Rejected by Fireblocks"},
+			},
+		},
+		"payout-2": {ID: "payout-2", WalletID: "wallet-2"},
+	}}
+	h := NewPayoutHandler(&MockWalletRepository{}, payouts, nil, 3)
+
+	tests := []struct {
+		name       string
+		payoutID   string
+		getError   error
+		statusCode int
+		wantCode   string
+	}{
+		{name: "found", payoutID: "payout-1", statusCode: http.StatusOK},
+		{name: "unknown", payoutID: "payout-3", statusCode: http.StatusNotFound, wantCode: apierror.PayoutNotFound},
+		{name: "other_wallet", payoutID: "payout-2", statusCode: http.StatusNotFound, wantCode: apierror.PayoutNotFound},
+		{name: "error", payoutID: "payout-1", getError: assert.AnError, statusCode: http.StatusInternalServerError, wantCode: apierror.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payouts.GetError = tt.getError
+			req := httptest.NewRequest(http.MethodGet, "/wallets/wallet-1/payouts/"+tt.payoutID, nil)
+			req.SetPathValue("walletId", "wallet-1")
+			req.SetPathValue("payoutId", tt.payoutID)
+			req.Header.Set("Accept", "application/json")
+			recorder := httptest.NewRecorder()
+
+			h.GetPayout(recorder, req)
+
+			assert.Equal(t, tt.statusCode, recorder.Code)
+			if tt.statusCode != http.StatusOK {
+				var response apierror.Error
+				assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+				assert.Equal(t, tt.wantCode, response.Code)
+				return
+			}
+			var response PayoutResponse
+			assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+			assert.Equal(t, "PARTIAL", response.Status)
+			assert.Equal(t, &completedAt, response.CompletedAt)
+			assert.Equal(t, []PayoutLineResponse{
+				{Line: 1, DestinationAddress: "0xalice", Amount: "1", Status: "SUBMITTED", TransactionID: "tx-1"},
+				{Line: 2, DestinationAddress: "0xbob", Amount: "2", Status: "FAILED", Error: "Rejected by Fireblocks"},
+			}, response.Lines)
+		})
+	}
+}
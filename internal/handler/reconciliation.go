@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/json"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/model"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultReconciliationReportsLimit = 10
+	maxReconciliationReportsLimit     = 100
+)
+
+type ReconciliationReportRepository interface {
+	ListLatest(limit int) ([]model.ReconciliationReport, error)
+}
+
+type ReconciliationHandler struct {
+	reportRepo ReconciliationReportRepository
+}
+
+func NewReconciliationHandler(reportRepo ReconciliationReportRepository) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		reportRepo: reportRepo,
+	}
+}
+
+func (h *ReconciliationHandler) ListReports(w http.ResponseWriter, r *http.Request) {
+	limit := defaultReconciliationReportsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxReconciliationReportsLimit {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	reports, err := h.reportRepo.ListLatest(limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list reconciliation reports", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	response := ListReconciliationReportsResponse{Reports: make([]ReconciliationReportResponse, 0, len(reports))}
+	for _, report := range reports {
+		reportResponse := ReconciliationReportResponse{
+			ID:         report.ID,
+			Status:     string(report.Status),
+			Error:      report.Error,
+			IssueCount: report.IssueCount,
+			StartedAt:  report.StartedAt,
+			FinishedAt: report.FinishedAt,
+			Issues:     make([]ReconciliationIssueResponse, 0, len(report.Issues)),
+		}
+		for _, issue := range report.Issues {
+			reportResponse.Issues = append(reportResponse.Issues, ReconciliationIssueResponse{
+				Kind:           string(issue.Kind),
+				WalletID:       issue.WalletID,
+				VaultAccountID: issue.VaultAccountID,
+				AssetID:        issue.AssetID,
+				Reference:      issue.Reference,
+				Expected:       issue.Expected,
+				Actual:         issue.Actual,
+				Details:        issue.Details,
+			})
+		}
+		response.Reports = append(response.Reports, reportResponse)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type MockReconciliationReportRepository struct {
+	Reports       []model.ReconciliationReport
+	Error         error
+	ReceivedLimit int
+}
+
+func (m *MockReconciliationReportRepository) ListLatest(limit int) ([]model.ReconciliationReport, error) {
+	m.ReceivedLimit = limit
+	return m.Reports, m.Error
+}
+
+func TestListReconciliationReports(t *testing.T) {
+	startedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		query  string
+		repo   *MockReconciliationReportRepository
+		assert func(t *testing.T, recorder *httptest.ResponseRecorder, repo *MockReconciliationReportRepository)
+	}{
+		{
+			name: "success",
+			repo: &MockReconciliationReportRepository{Reports: []model.ReconciliationReport{{
+				ID:         "report-1",
+				Status:     model.ReconciliationStatusCompleted,
+				IssueCount: 1,
+				StartedAt:  startedAt,
+				FinishedAt: startedAt.Add(time.Minute),
+				Issues: []model.ReconciliationIssue{{
+					Kind:           model.ReconciliationIssueOrphanedVault,
+					VaultAccountID: "3",
+					Details:        "vault account \"Leftover\" has no wallet",
+				}},
+			}}},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, repo *MockReconciliationReportRepository) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, defaultReconciliationReportsLimit, repo.ReceivedLimit)
+
+				var response ListReconciliationReportsResponse
+				err := json.NewDecoder(recorder.Body).Decode(&response)
+				assert.NoError(t, err)
+				assert.Len(t, response.Reports, 1)
+				assert.Equal(t, "COMPLETED", response.Reports[0].Status)
+				assert.Equal(t, "ORPHANED_VAULT", response.Reports[0].Issues[0].Kind)
+				assert.Equal(t, "3", response.Reports[0].Issues[0].VaultAccountID)
+			},
+		},
+		{
+			name:  "custom_limit",
+			query: "?limit=5",
+			repo:  &MockReconciliationReportRepository{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, repo *MockReconciliationReportRepository) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, 5, repo.ReceivedLimit)
+				assert.JSONEq(t, `{"reports":[]}`, recorder.Body.String())
+			},
+		},
+		{
+			name:  "invalid_limit",
+			query: "?limit=0",
+			repo:  &MockReconciliationReportRepository{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, repo *MockReconciliationReportRepository) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assert.Equal(t, 0, repo.ReceivedLimit)
+			},
+		},
+		{
+			name: "repository_error",
+			repo: &MockReconciliationReportRepository{Error: errors.New("database error")},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, repo *MockReconciliationReportRepository) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewReconciliationHandler(tt.repo)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /admin/reconciliation", handler.ListReports)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/reconciliation"+tt.query, nil)
+			recorder := httptest.NewRecorder()
+
+			mux.ServeHTTP(recorder, req)
+
+			tt.assert(t, recorder, tt.repo)
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/audit"
+	"firego-wallet-service/internal/cron"
+	"firego-wallet-service/internal/ledger"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/model"
+	"firego-wallet-service/internal/tracing"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultScheduleRunsLimit = 50
+	maxScheduleRunsLimit     = 500
+)
+
+type ScheduleRepository interface {
+	Create(ctx context.Context, schedule *model.TransferSchedule) error
+	GetByID(ctx context.Context, id string) (*model.TransferSchedule, error)
+	ListByWalletID(ctx context.Context, walletID string) ([]model.TransferSchedule, error)
+	Pause(ctx context.Context, id string) error
+	Resume(ctx context.Context, id string, nextRunAt time.Time) error
+	Delete(ctx context.Context, id string) error
+	ListRuns(ctx context.Context, scheduleID string, limit int) ([]model.TransferScheduleRun, error)
+}
+
+type ScheduleHandler struct {
+	walletRepo   WalletRepository
+	scheduleRepo ScheduleRepository
+}
+
+func NewScheduleHandler(walletRepo WalletRepository, scheduleRepo ScheduleRepository) *ScheduleHandler {
+	return &ScheduleHandler{
+		walletRepo:   walletRepo,
+		scheduleRepo: scheduleRepo,
+	}
+}
+
+// CreateSchedule schedules a transfer out of the wallet at a future time, or recurring on a cron expression. The
+// balance is only checked when the transfer runs, see schedule.Scheduler.
+func (h *ScheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	walletID := r.PathValue("walletId")
+
+	ctx, span := tracer.Start(r.Context(), "ScheduleHandler.CreateSchedule")
+	defer span.End()
+	span.SetAttributes(tracing.WalletID.String(walletID))
+
+	if walletID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID is required")
+		return
+	}
+
+	var req CreateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
+		return
+	}
+	nextRunAt, apiErr := validateSchedule(req, time.Now())
+	if apiErr != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apiErr.Code, apiErr.Message)
+		return
+	}
+	span.SetAttributes(tracing.AssetID.String(req.AssetID))
+
+	if _, err := h.walletRepo.GetByID(ctx, walletID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, http.StatusNotFound, apierror.WalletNotFound, "Wallet not found")
+			return
+		}
+		logging.FromContext(ctx).Error("Failed to get wallet", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	schedule := model.TransferSchedule{
+		WalletID:           walletID,
+		AssetID:            req.AssetID,
+		Amount:             req.Amount,
+		DestinationAddress: req.DestinationAddress,
+		Note:               req.Note,
+		Cron:               req.Cron,
+		Status:             model.TransferScheduleStatusActive,
+		NextRunAt:          &nextRunAt,
+	}
+	if err := h.scheduleRepo.Create(ctx, &schedule); err != nil {
+		logging.FromContext(ctx).Error("Failed to create schedule", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to create schedule")
+		return
+	}
+	audit.SetTarget(ctx, schedule.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(newScheduleResponse(&schedule)); err != nil {
+		logging.FromContext(ctx).Error("Failed to encode response", "error", err)
+	}
+}
+
+// validateSchedule checks the schedule and returns its first run, or why it is rejected. The transfer itself is
+// validated again by each run.
+func validateSchedule(req CreateScheduleRequest, now time.Time) (time.Time, *apierror.Error) {
+	if req.AssetID == "" {
+		return time.Time{}, &apierror.Error{Code: apierror.InvalidRequest, Message: "Asset ID is required"}
+	}
+	if req.Amount == "" {
+		return time.Time{}, &apierror.Error{Code: apierror.InvalidRequest, Message: "Amount is required"}
+	}
+	if req.DestinationAddress == "" {
+		return time.Time{}, &apierror.Error{Code: apierror.InvalidRequest, Message: "Destination address is required"}
+	}
+	amount, err := ledger.ParseAmount(req.Amount)
+	if err != nil {
+		return time.Time{}, &apierror.Error{Code: apierror.InvalidAmount, Message: "Invalid amount format"}
+	}
+	if amount.Sign() == 0 {
+		return time.Time{}, &apierror.Error{Code: apierror.InvalidAmount, Message: "Amount must be greater than zero"}
+	}
+
+	switch {
+	case req.Cron == "" && req.RunAt == nil:
+		return time.Time{}, &apierror.Error{Code: apierror.InvalidRequest, Message: "Either cron or runAt is required"}
+	case req.Cron != "" && req.RunAt != nil:
+		return time.Time{}, &apierror.Error{Code: apierror.InvalidRequest, Message: "Only one of cron and runAt can be given"}
+	case req.RunAt != nil:
+		if !req.RunAt.After(now) {
+			return time.Time{}, &apierror.Error{Code: apierror.InvalidRequest, Message: "Run time must be in the future"}
+		}
+		return req.RunAt.UTC(), nil
+	}
+
+	expr, err := cron.Parse(req.Cron)
+	if err != nil {
+		return time.Time{}, &apierror.Error{Code: apierror.InvalidRequest, Message: "Invalid cron expression: " + err.Error()}
+	}
+	next := expr.Next(now.UTC())
+	if next.IsZero() {
+		return time.Time{}, &apierror.Error{Code: apierror.InvalidRequest, Message: "Cron expression never matches"}
+	}
+	return next, nil
+}
+
+func (h *ScheduleHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	walletID := r.PathValue("walletId")
+
+	if walletID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID is required")
+		return
+	}
+
+	if _, err := h.walletRepo.GetByID(r.Context(), walletID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, http.StatusNotFound, apierror.WalletNotFound, "Wallet not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to get wallet", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	schedules, err := h.scheduleRepo.ListByWalletID(r.Context(), walletID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list schedules", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	response := ListSchedulesResponse{Schedules: make([]ScheduleResponse, 0, len(schedules))}
+	for _, schedule := range schedules {
+		response.Schedules = append(response.Schedules, newScheduleResponse(&schedule))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "error", err)
+	}
+}
+
+// PauseSchedule stops the schedule from running until it is resumed
+func (h *ScheduleHandler) PauseSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := h.schedule(w, r)
+	if !ok {
+		return
+	}
+	if schedule.Status == model.TransferScheduleStatusCompleted {
+		apierror.Write(w, r, http.StatusConflict, apierror.ScheduleCompleted, "Schedule has already run")
+		return
+	}
+
+	if schedule.Status == model.TransferScheduleStatusActive {
+		if err := h.scheduleRepo.Pause(r.Context(), schedule.ID); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to pause schedule", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+			return
+		}
+		schedule.Status = model.TransferScheduleStatusPaused
+	}
+	writeSchedule(w, r, schedule)
+}
+
+// ResumeSchedule activates a paused schedule again. A recurring schedule resumes from its next run after now, the runs
+// missed while it was paused are not made up; a one-off schedule whose time has passed runs right away.
+func (h *ScheduleHandler) ResumeSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := h.schedule(w, r)
+	if !ok {
+		return
+	}
+	if schedule.Status == model.TransferScheduleStatusCompleted {
+		apierror.Write(w, r, http.StatusConflict, apierror.ScheduleCompleted, "Schedule has already run")
+		return
+	}
+
+	if schedule.Status == model.TransferScheduleStatusPaused {
+		nextRunAt := *schedule.NextRunAt
+		if schedule.Cron != "" {
+			expr, err := cron.Parse(schedule.Cron)
+			if err != nil {
+				logging.FromContext(r.Context()).Error("Invalid stored cron expression", "cron", schedule.Cron, "error", err)
+				apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+				return
+			}
+			nextRunAt = expr.Next(time.Now().UTC())
+		}
+
+		if err := h.scheduleRepo.Resume(r.Context(), schedule.ID, nextRunAt); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to resume schedule", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+			return
+		}
+		schedule.Status = model.TransferScheduleStatusActive
+		schedule.NextRunAt = &nextRunAt
+	}
+	writeSchedule(w, r, schedule)
+}
+
+// DeleteSchedule stops the schedule for good, its runs are kept
+func (h *ScheduleHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := h.schedule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.scheduleRepo.Delete(r.Context(), schedule.ID); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to delete schedule", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListScheduleRuns returns the latest runs of the schedule, newest first
+func (h *ScheduleHandler) ListScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	limit := defaultScheduleRunsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxScheduleRunsLimit {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	schedule, ok := h.schedule(w, r)
+	if !ok {
+		return
+	}
+
+	runs, err := h.scheduleRepo.ListRuns(r.Context(), schedule.ID, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list schedule runs", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	response := ListScheduleRunsResponse{Runs: make([]ScheduleRunResponse, 0, len(runs))}
+	for _, run := range runs {
+		response.Runs = append(response.Runs, ScheduleRunResponse{
+			ScheduledAt:   run.ScheduledAt,
+			Status:        string(run.Status),
+			TransactionID: run.FireblocksTxID,
+			Error:         run.Error,
+			CreatedAt:     run.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "error", err)
+	}
+}
+
+// schedule loads the schedule of the path, writing the error response and returning false when it cannot be loaded.
+// The schedules of other wallets are not found either.
+func (h *ScheduleHandler) schedule(w http.ResponseWriter, r *http.Request) (*model.TransferSchedule, bool) {
+	walletID := r.PathValue("walletId")
+	scheduleID := r.PathValue("scheduleId")
+
+	if walletID == "" || scheduleID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID and schedule ID are required")
+		return nil, false
+	}
+	audit.SetTarget(r.Context(), scheduleID)
+
+	schedule, err := h.scheduleRepo.GetByID(r.Context(), scheduleID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logging.FromContext(r.Context()).Error("Failed to get schedule", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return nil, false
+	}
+	if err != nil || schedule.WalletID != walletID {
+		apierror.Write(w, r, http.StatusNotFound, apierror.ScheduleNotFound, "Schedule not found")
+		return nil, false
+	}
+	return schedule, true
+}
+
+func writeSchedule(w http.ResponseWriter, r *http.Request, schedule *model.TransferSchedule) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(newScheduleResponse(schedule)); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "error", err)
+	}
+}
+
+func newScheduleResponse(schedule *model.TransferSchedule) ScheduleResponse {
+	return ScheduleResponse{
+		ID:                 schedule.ID,
+		WalletID:           schedule.WalletID,
+		AssetID:            schedule.AssetID,
+		Amount:             schedule.Amount,
+		DestinationAddress: schedule.DestinationAddress,
+		Note:               schedule.Note,
+		Cron:               schedule.Cron,
+		Status:             string(schedule.Status),
+		NextRunAt:          schedule.NextRunAt,
+		CreatedAt:          schedule.CreatedAt,
+	}
+}
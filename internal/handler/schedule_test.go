@@ -0,0 +1,360 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MemoryScheduleRepository keeps the schedules like the database does, deleted ones included
+type MemoryScheduleRepository struct {
+	Schedules map[string]*model.TransferSchedule
+	Runs      []model.TransferScheduleRun
+	Deleted   map[string]bool
+}
+
+func (m *MemoryScheduleRepository) Create(_ context.Context, schedule *model.TransferSchedule) error {
+	schedule.ID = "schedule-" + strconv.Itoa(len(m.Schedules)+1)
+	schedule.CreatedAt = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	stored := *schedule
+	m.Schedules[schedule.ID] = &stored
+	return nil
+}
+
+func (m *MemoryScheduleRepository) GetByID(_ context.Context, id string) (*model.TransferSchedule, error) {
+	schedule, ok := m.Schedules[id]
+	if !ok || m.Deleted[id] {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *schedule
+	return &found, nil
+}
+
+func (m *MemoryScheduleRepository) ListByWalletID(_ context.Context, walletID string) ([]model.TransferSchedule, error) {
+	var schedules []model.TransferSchedule
+	for i := 1; i <= len(m.Schedules); i++ {
+		id := "schedule-" + strconv.Itoa(i)
+		if schedule := m.Schedules[id]; schedule.WalletID == walletID && !m.Deleted[id] {
+			schedules = append(schedules, *schedule)
+		}
+	}
+	return schedules, nil
+}
+
+func (m *MemoryScheduleRepository) Pause(_ context.Context, id string) error {
+	m.Schedules[id].Status = model.TransferScheduleStatusPaused
+	return nil
+}
+
+func (m *MemoryScheduleRepository) Resume(_ context.Context, id string, nextRunAt time.Time) error {
+	m.Schedules[id].Status = model.TransferScheduleStatusActive
+	m.Schedules[id].NextRunAt = &nextRunAt
+	return nil
+}
+
+func (m *MemoryScheduleRepository) Delete(_ context.Context, id string) error {
+	m.Deleted[id] = true
+	return nil
+}
+
+func (m *MemoryScheduleRepository) ListRuns(_ context.Context, scheduleID string, limit int) ([]model.TransferScheduleRun, error) {
+	var runs []model.TransferScheduleRun
+	for i := len(m.Runs) - 1; i >= 0 && len(runs) < limit; i-- {
+		if m.Runs[i].ScheduleID == scheduleID {
+			runs = append(runs, m.Runs[i])
+		}
+	}
+	return runs, nil
+}
+
+func newTestScheduleHandler() (*ScheduleHandler, *MemoryScheduleRepository) {
+	repo := &MemoryScheduleRepository{Schedules: map[string]*model.TransferSchedule{}, Deleted: map[string]bool{}}
+	walletRepo := &MockWalletRepository{GetByIDWallet: &model.Wallet{ID: "wallet-1", VaultAccountID: "7"}}
+	return NewScheduleHandler(walletRepo, repo), repo
+}
+
+// serveSchedules routes the request to the schedule endpoints
+func serveSchedules(h *ScheduleHandler, method, target, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /wallets/{walletId}/schedules", h.CreateSchedule)
+	mux.HandleFunc("GET /wallets/{walletId}/schedules", h.ListSchedules)
+	mux.HandleFunc("POST /wallets/{walletId}/schedules/{scheduleId}/pause", h.PauseSchedule)
+	mux.HandleFunc("POST /wallets/{walletId}/schedules/{scheduleId}/resume", h.ResumeSchedule)
+	mux.HandleFunc("DELETE /wallets/{walletId}/schedules/{scheduleId}", h.DeleteSchedule)
+	mux.HandleFunc("GET /wallets/{walletId}/schedules/{scheduleId}/runs", h.ListScheduleRuns)
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Accept", "application/json")
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestCreateSchedule(t *testing.T) {
+	runAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		body      string
+		cron      string
+		nextRunAt func(t *testing.T, nextRunAt time.Time)
+	}{
+		{
+			name: "recurring",
+			body: `{"assetId": "ETH_TEST5", "amount": "1.5", "destinationAddress": "0xcold", "note": "treasury sweep", "cron": "0 9 * * 1"}`,
+			cron: "0 9 * * 1",
+			nextRunAt: func(t *testing.T, nextRunAt time.Time) {
+				assert.Equal(t, time.Monday, nextRunAt.Weekday())
+				assert.Equal(t, 9, nextRunAt.Hour())
+				assert.True(t, nextRunAt.After(time.Now()))
+			},
+		},
+		{
+			name: "one_off",
+			body: `{"assetId": "ETH_TEST5", "amount": "1.5", "destinationAddress": "0xcold", "note": "treasury sweep", "runAt": "` + runAt.Format(time.RFC3339) + `"}`,
+			nextRunAt: func(t *testing.T, nextRunAt time.Time) {
+				assert.Equal(t, runAt, nextRunAt)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestScheduleHandler()
+
+			recorder := serveSchedules(h, http.MethodPost, "/wallets/wallet-1/schedules", tt.body)
+
+			assert.Equal(t, http.StatusCreated, recorder.Code)
+			var response ScheduleResponse
+			assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+			assert.Equal(t, "schedule-1", response.ID)
+			assert.Equal(t, "wallet-1", response.WalletID)
+			assert.Equal(t, "1.5", response.Amount)
+			assert.Equal(t, "treasury sweep", response.Note)
+			assert.Equal(t, tt.cron, response.Cron)
+			assert.Equal(t, string(model.TransferScheduleStatusActive), response.Status)
+			tt.nextRunAt(t, *response.NextRunAt)
+
+			stored := repo.Schedules["schedule-1"]
+			assert.Equal(t, "0xcold", stored.DestinationAddress)
+			assert.Equal(t, response.NextRunAt.UTC(), *stored.NextRunAt)
+		})
+	}
+}
+
+func TestCreateScheduleValidation(t *testing.T) {
+	past := time.Now().Add(-time.Minute).Format(time.RFC3339)
+
+	tests := []struct {
+		name    string
+		body    string
+		code    string
+		message string
+	}{
+		{
+			name:    "invalid_body",
+			body:    `{"assetId": `,
+			code:    apierror.InvalidBody,
+			message: "Invalid request body",
+		},
+		{
+			name:    "missing_asset",
+			body:    `{"amount": "1", "destinationAddress": "0xcold", "cron": "@daily"}`,
+			code:    apierror.InvalidRequest,
+			message: "Asset ID is required",
+		},
+		{
+			name:    "invalid_amount",
+			body:    `{"assetId": "ETH_TEST5", "amount": "1e3", "destinationAddress": "0xcold", "cron": "@daily"}`,
+			code:    apierror.InvalidAmount,
+			message: "Invalid amount format",
+		},
+		{
+			name:    "zero_amount",
+			body:    `{"assetId": "ETH_TEST5", "amount": "0", "destinationAddress": "0xcold", "cron": "@daily"}`,
+			code:    apierror.InvalidAmount,
+			message: "Amount must be greater than zero",
+		},
+		{
+			name:    "missing_destination",
+			body:    `{"assetId": "ETH_TEST5", "amount": "1", "cron": "@daily"}`,
+			code:    apierror.InvalidRequest,
+			message: "Destination address is required",
+		},
+		{
+			name:    "no_schedule",
+			body:    `{"assetId": "ETH_TEST5", "amount": "1", "destinationAddress": "0xcold"}`,
+			code:    apierror.InvalidRequest,
+			message: "Either cron or runAt is required",
+		},
+		{
+			name:    "both_schedules",
+			body:    `{"assetId": "ETH_TEST5", "amount": "1", "destinationAddress": "0xcold", "cron": "@daily", "runAt": "2099-01-01T00:00:00Z"}`,
+			code:    apierror.InvalidRequest,
+			message: "Only one of cron and runAt can be given",
+		},
+		{
+			name:    "past_run",
+			body:    `{"assetId": "ETH_TEST5", "amount": "1", "destinationAddress": "0xcold", "runAt": "` + past + `"}`,
+			code:    apierror.InvalidRequest,
+			message: "Run time must be in the future",
+		},
+		{
+			name:    "invalid_cron",
+			body:    `{"assetId": "ETH_TEST5", "amount": "1", "destinationAddress": "0xcold", "cron": "0 25 * * *"}`,
+			code:    apierror.InvalidRequest,
+			message: "Invalid cron expression: hour: 25 is out of range 0-23",
+		},
+		{
+			name:    "cron_never_matches",
+			body:    `{"assetId": "ETH_TEST5", "amount": "1", "destinationAddress": "0xcold", "cron": "0 0 31 4 *"}`,
+			code:    apierror.InvalidRequest,
+			message: "Cron expression never matches",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestScheduleHandler()
+
+			recorder := serveSchedules(h, http.MethodPost, "/wallets/wallet-1/schedules", tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			var response apierror.Error
+			assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+			assert.Equal(t, apierror.Error{Code: tt.code, Message: tt.message}, response)
+			assert.Empty(t, repo.Schedules)
+		})
+	}
+}
+
+func TestCreateScheduleWalletNotFound(t *testing.T) {
+	h := NewScheduleHandler(&MockWalletRepository{GetByIDError: gorm.ErrRecordNotFound}, &MemoryScheduleRepository{})
+
+	recorder := serveSchedules(h, http.MethodPost, "/wallets/wallet-2/schedules", `{"assetId": "ETH_TEST5", "amount": "1", "destinationAddress": "0xcold", "cron": "@daily"}`)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), apierror.WalletNotFound)
+}
+
+func TestScheduleLifecycle(t *testing.T) {
+	h, repo := newTestScheduleHandler()
+	body := `{"assetId": "ETH_TEST5", "amount": "1", "destinationAddress": "0xcold", "cron": "*/5 * * * *"}`
+	assert.Equal(t, http.StatusCreated, serveSchedules(h, http.MethodPost, "/wallets/wallet-1/schedules", body).Code)
+	assert.Equal(t, http.StatusCreated, serveSchedules(h, http.MethodPost, "/wallets/wallet-1/schedules", body).Code)
+	stale := time.Now().Add(-time.Hour)
+	repo.Schedules["schedule-1"].NextRunAt = &stale
+
+	recorder := serveSchedules(h, http.MethodPost, "/wallets/wallet-1/schedules/schedule-1/pause", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"status":"PAUSED"`)
+	assert.Equal(t, model.TransferScheduleStatusPaused, repo.Schedules["schedule-1"].Status)
+
+	// a recurring schedule resumes from its next run after now
+	recorder = serveSchedules(h, http.MethodPost, "/wallets/wallet-1/schedules/schedule-1/resume", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"status":"ACTIVE"`)
+	assert.Equal(t, model.TransferScheduleStatusActive, repo.Schedules["schedule-1"].Status)
+	assert.True(t, repo.Schedules["schedule-1"].NextRunAt.After(time.Now()))
+
+	recorder = serveSchedules(h, http.MethodDelete, "/wallets/wallet-1/schedules/schedule-1", "")
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Empty(t, recorder.Body.String())
+
+	recorder = serveSchedules(h, http.MethodGet, "/wallets/wallet-1/schedules", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var response ListSchedulesResponse
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	assert.Len(t, response.Schedules, 1)
+	assert.Equal(t, "schedule-2", response.Schedules[0].ID)
+
+	recorder = serveSchedules(h, http.MethodPost, "/wallets/wallet-1/schedules/schedule-1/pause", "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), apierror.ScheduleNotFound)
+}
+
+func TestScheduleNotChangeable(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+		code   string
+	}{
+		{
+			name:   "pause_completed",
+			method: http.MethodPost,
+			target: "/wallets/wallet-1/schedules/schedule-1/pause",
+			status: http.StatusConflict,
+			code:   apierror.ScheduleCompleted,
+		},
+		{
+			name:   "resume_completed",
+			method: http.MethodPost,
+			target: "/wallets/wallet-1/schedules/schedule-1/resume",
+			status: http.StatusConflict,
+			code:   apierror.ScheduleCompleted,
+		},
+		{
+			name:   "other_wallet",
+			method: http.MethodDelete,
+			target: "/wallets/wallet-2/schedules/schedule-1",
+			status: http.StatusNotFound,
+			code:   apierror.ScheduleNotFound,
+		},
+		{
+			name:   "unknown_schedule",
+			method: http.MethodGet,
+			target: "/wallets/wallet-1/schedules/schedule-9/runs",
+			status: http.StatusNotFound,
+			code:   apierror.ScheduleNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestScheduleHandler()
+			repo.Schedules["schedule-1"] = &model.TransferSchedule{ID: "schedule-1", WalletID: "wallet-1", Status: model.TransferScheduleStatusCompleted}
+
+			recorder := serveSchedules(h, tt.method, tt.target, "")
+
+			assert.Equal(t, tt.status, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.code)
+		})
+	}
+}
+
+func TestListScheduleRuns(t *testing.T) {
+	h, repo := newTestScheduleHandler()
+	repo.Schedules["schedule-1"] = &model.TransferSchedule{ID: "schedule-1", WalletID: "wallet-1", Status: model.TransferScheduleStatusActive}
+	monday := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	repo.Runs = []model.TransferScheduleRun{
+		{ScheduleID: "schedule-1", ScheduledAt: monday, Status: model.TransferScheduleRunStatusSubmitted, FireblocksTxID: "tx-1"},
+		{ScheduleID: "schedule-1", ScheduledAt: monday.AddDate(0, 0, 7), Status: model.TransferScheduleRunStatusSkipped, Error: "Insufficient balance"},
+		{ScheduleID: "schedule-1", ScheduledAt: monday.AddDate(0, 0, 14), Status: model.TransferScheduleRunStatusSubmitted, FireblocksTxID: "tx-3"},
+	}
+
+	recorder := serveSchedules(h, http.MethodGet, "/wallets/wallet-1/schedules/schedule-1/runs?limit=2", "")
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var response ListScheduleRunsResponse
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	assert.Len(t, response.Runs, 2)
+	assert.Equal(t, "tx-3", response.Runs[0].TransactionID)
+	assert.Equal(t, string(model.TransferScheduleRunStatusSkipped), response.Runs[1].Status)
+	assert.Equal(t, "Insufficient balance", response.Runs[1].Error)
+	assert.Equal(t, monday.AddDate(0, 0, 7), response.Runs[1].ScheduledAt)
+
+	recorder = serveSchedules(h, http.MethodGet, "/wallets/wallet-1/schedules/schedule-1/runs?limit=501", "")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Invalid limit")
+}
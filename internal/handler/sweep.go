@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/model"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultSweepsLimit = 50
+	maxSweepsLimit     = 500
+)
+
+type SweepRepository interface {
+	ListLatest(ctx context.Context, walletID string, limit int) ([]model.Sweep, error)
+}
+
+type SweepHandler struct {
+	sweepRepo SweepRepository
+}
+
+func NewSweepHandler(sweepRepo SweepRepository) *SweepHandler {
+	return &SweepHandler{
+		sweepRepo: sweepRepo,
+	}
+}
+
+// ListSweeps returns the latest sweeps with their outcome, of a single wallet when walletId is given
+func (h *SweepHandler) ListSweeps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := defaultSweepsLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxSweepsLimit {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	sweeps, err := h.sweepRepo.ListLatest(r.Context(), query.Get("walletId"), limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list sweeps", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	response := ListSweepsResponse{Sweeps: make([]SweepResponse, 0, len(sweeps))}
+	for _, sweep := range sweeps {
+		response.Sweeps = append(response.Sweeps, SweepResponse{
+			ID:               sweep.ID,
+			WalletID:         sweep.WalletID,
+			AssetID:          sweep.AssetID,
+			TreasuryWalletID: sweep.TreasuryWalletID,
+			Available:        sweep.Available,
+			Amount:           sweep.Amount,
+			Status:           string(sweep.Status),
+			TransactionID:    sweep.FireblocksTxID,
+			Error:            sweep.Error,
+			Attempts:         sweep.Attempts,
+			CreatedAt:        sweep.CreatedAt,
+			UpdatedAt:        sweep.UpdatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type MockSweepRepository struct {
+	Sweeps           []model.Sweep
+	Error            error
+	ReceivedWalletID string
+	ReceivedLimit    int
+}
+
+func (m *MockSweepRepository) ListLatest(_ context.Context, walletID string, limit int) ([]model.Sweep, error) {
+	m.ReceivedWalletID = walletID
+	m.ReceivedLimit = limit
+	return m.Sweeps, m.Error
+}
+
+func TestListSweeps(t *testing.T) {
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		query  string
+		repo   *MockSweepRepository
+		assert func(t *testing.T, recorder *httptest.ResponseRecorder, repo *MockSweepRepository)
+	}{
+		{
+			name: "success",
+			repo: &MockSweepRepository{Sweeps: []model.Sweep{{
+				ID:               "sweep-1",
+				WalletID:         "wallet-1",
+				AssetID:          "ETH_TEST5",
+				TreasuryWalletID: "treasury",
+				Available:        "2",
+				Amount:           "1.9",
+				Status:           model.SweepStatusSubmitted,
+				FireblocksTxID:   "tx-1",
+				Attempts:         1,
+				CreatedAt:        createdAt,
+				UpdatedAt:        createdAt,
+			}}},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, repo *MockSweepRepository) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, defaultSweepsLimit, repo.ReceivedLimit)
+				assert.Empty(t, repo.ReceivedWalletID)
+
+				var response ListSweepsResponse
+				err := json.NewDecoder(recorder.Body).Decode(&response)
+				assert.NoError(t, err)
+				assert.Len(t, response.Sweeps, 1)
+				assert.Equal(t, "SUBMITTED", response.Sweeps[0].Status)
+				assert.Equal(t, "1.9", response.Sweeps[0].Amount)
+				assert.Equal(t, "tx-1", response.Sweeps[0].TransactionID)
+			},
+		},
+		{
+			name:  "wallet_and_limit",
+			query: "?walletId=wallet-1&limit=5",
+			repo:  &MockSweepRepository{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, repo *MockSweepRepository) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, "wallet-1", repo.ReceivedWalletID)
+				assert.Equal(t, 5, repo.ReceivedLimit)
+				assert.JSONEq(t, `{"sweeps":[]}`, recorder.Body.String())
+			},
+		},
+		{
+			name:  "invalid_limit",
+			query: "?limit=501",
+			repo:  &MockSweepRepository{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, repo *MockSweepRepository) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assert.Equal(t, 0, repo.ReceivedLimit)
+			},
+		},
+		{
+			name: "repository_error",
+			repo: &MockSweepRepository{Error: errors.New("database error")},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, repo *MockSweepRepository) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewSweepHandler(tt.repo)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /admin/sweeps", handler.ListSweeps)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/sweeps"+tt.query, nil)
+			recorder := httptest.NewRecorder()
+
+			mux.ServeHTTP(recorder, req)
+
+			tt.assert(t, recorder, tt.repo)
+		})
+	}
+}
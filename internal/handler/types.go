@@ -1,5 +1,11 @@
 package handler
 
+import (
+	"encoding/json"
+	"firego-wallet-service/internal/apierror"
+	"time"
+)
+
 type CreateWalletRequest struct {
 	Name string `json:"name"`
 }
@@ -10,6 +16,41 @@ type CreateWalletResponse struct {
 	VaultAccountID string `json:"vaultAccountID"`
 }
 
+type CreateWalletsRequest struct {
+	Wallets []CreateWalletsItem `json:"wallets"`
+}
+
+type CreateWalletsItem struct {
+	Name string `json:"name"`
+	// Assets are activated in the vault account once the wallet is stored
+	Assets []string `json:"assets,omitempty"`
+}
+
+type CreateWalletsResponse struct {
+	Created int                   `json:"created"`
+	Partial int                   `json:"partial"`
+	Failed  int                   `json:"failed"`
+	Results []CreateWalletsResult `json:"results"`
+}
+
+// CreateWalletsResult is the outcome of a wallet of the batch, at the index of the request
+type CreateWalletsResult struct {
+	Index          int                        `json:"index"`
+	Name           string                     `json:"name"`
+	Status         string                     `json:"status"`
+	ID             string                     `json:"id,omitempty"`
+	VaultAccountID string                     `json:"vaultAccountID,omitempty"`
+	Assets         []CreateWalletsAssetResult `json:"assets,omitempty"`
+	Error          *apierror.Error            `json:"error,omitempty"`
+}
+
+type CreateWalletsAssetResult struct {
+	AssetID string          `json:"assetId"`
+	Address string          `json:"address,omitempty"`
+	Tag     string          `json:"tag,omitempty"`
+	Error   *apierror.Error `json:"error,omitempty"`
+}
+
 type GetWalletBalanceResponse struct {
 	ID           string `json:"id"`
 	Total        string `json:"total"`
@@ -28,6 +69,33 @@ type GetDepositAddressResponse struct {
 	Type          string `json:"type"`
 }
 
+type CreateDepositAddressRequest struct {
+	Description   string `json:"description,omitempty"`
+	CustomerRefID string `json:"customerRefId,omitempty"`
+}
+
+type DepositAddressResponse struct {
+	AssetID           string `json:"assetId"`
+	Address           string `json:"address"`
+	LegacyAddress     string `json:"legacyAddress,omitempty"`
+	Tag               string `json:"tag,omitempty"`
+	Description       string `json:"description,omitempty"`
+	CustomerRefID     string `json:"customerRefId,omitempty"`
+	AddressFormat     string `json:"addressFormat,omitempty"`
+	Type              string `json:"type,omitempty"`
+	Bip44AddressIndex int    `json:"bip44AddressIndex"`
+}
+
+type ListDepositAddressesResponse struct {
+	Addresses []DepositAddressResponse `json:"addresses"`
+	Paging    PagingResponse           `json:"paging"`
+}
+
+type PagingResponse struct {
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
 type InitiateTransferRequest struct {
 	AssetID            string `json:"assetId"`
 	Amount             string `json:"amount"`
@@ -43,3 +111,226 @@ type InitiateTransferResponse struct {
 	DestinationAddress string `json:"destinationAddress"`
 	Note               string `json:"note,omitempty"`
 }
+
+type CreatePayoutRequest struct {
+	AssetID string             `json:"assetId"`
+	Lines   []CreatePayoutLine `json:"lines"`
+}
+
+type CreatePayoutLine struct {
+	DestinationAddress string `json:"destinationAddress"`
+	Amount             string `json:"amount"`
+	Note               string `json:"note,omitempty"`
+}
+
+type PayoutResponse struct {
+	ID          string               `json:"id"`
+	WalletID    string               `json:"walletId"`
+	AssetID     string               `json:"assetId"`
+	Total       string               `json:"total"`
+	Status      string               `json:"status"`
+	CreatedAt   time.Time            `json:"createdAt"`
+	CompletedAt *time.Time           `json:"completedAt,omitempty"`
+	Lines       []PayoutLineResponse `json:"lines"`
+}
+
+type PayoutLineResponse struct {
+	Line               int    `json:"line"`
+	DestinationAddress string `json:"destinationAddress"`
+	Amount             string `json:"amount"`
+	Note               string `json:"note,omitempty"`
+	Status             string `json:"status"`
+	TransactionID      string `json:"transactionId,omitempty"`
+	Error              string `json:"error,omitempty"`
+}
+
+// CreateScheduleRequest is a transfer run once at RunAt, or on each match of the Cron expression, in UTC
+type CreateScheduleRequest struct {
+	AssetID            string     `json:"assetId"`
+	Amount             string     `json:"amount"`
+	DestinationAddress string     `json:"destinationAddress"`
+	Note               string     `json:"note,omitempty"`
+	Cron               string     `json:"cron,omitempty"`
+	RunAt              *time.Time `json:"runAt,omitempty"`
+}
+
+type ScheduleResponse struct {
+	ID                 string     `json:"id"`
+	WalletID           string     `json:"walletId"`
+	AssetID            string     `json:"assetId"`
+	Amount             string     `json:"amount"`
+	DestinationAddress string     `json:"destinationAddress"`
+	Note               string     `json:"note,omitempty"`
+	Cron               string     `json:"cron,omitempty"`
+	Status             string     `json:"status"`
+	NextRunAt          *time.Time `json:"nextRunAt,omitempty"`
+	CreatedAt          time.Time  `json:"createdAt"`
+}
+
+type ListSchedulesResponse struct {
+	Schedules []ScheduleResponse `json:"schedules"`
+}
+
+type ScheduleRunResponse struct {
+	ScheduledAt   time.Time `json:"scheduledAt"`
+	Status        string    `json:"status"`
+	TransactionID string    `json:"transactionId,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type ListScheduleRunsResponse struct {
+	Runs []ScheduleRunResponse `json:"runs"`
+}
+
+type DepositResponse struct {
+	ID                    string     `json:"id"`
+	TransactionID         string     `json:"transactionId"`
+	AssetID               string     `json:"assetId"`
+	Amount                string     `json:"amount"`
+	SourceAddress         string     `json:"sourceAddress,omitempty"`
+	DestinationAddress    string     `json:"destinationAddress,omitempty"`
+	TxHash                string     `json:"txHash,omitempty"`
+	Status                string     `json:"status"`
+	FireblocksStatus      string     `json:"fireblocksStatus"`
+	Confirmations         int        `json:"confirmations"`
+	RequiredConfirmations int        `json:"requiredConfirmations"`
+	CreditedAt            *time.Time `json:"creditedAt,omitempty"`
+	CreatedAt             time.Time  `json:"createdAt"`
+}
+
+type ListDepositsResponse struct {
+	Deposits []DepositResponse `json:"deposits"`
+}
+
+type LedgerBalanceResponse struct {
+	AssetID     string                      `json:"assetId"`
+	Total       string                      `json:"total"`
+	SubAccounts []SubAccountBalanceResponse `json:"subAccounts"`
+}
+
+type SubAccountBalanceResponse struct {
+	Name    string `json:"name"`
+	Balance string `json:"balance"`
+}
+
+type CreateAllocationRequest struct {
+	FromSubAccount string `json:"fromSubAccount,omitempty"`
+	ToSubAccount   string `json:"toSubAccount"`
+	Amount         string `json:"amount"`
+	Description    string `json:"description,omitempty"`
+}
+
+type JournalEntryResponse struct {
+	ID          string            `json:"id"`
+	Kind        string            `json:"kind"`
+	Reference   string            `json:"reference"`
+	Description string            `json:"description"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	Postings    []PostingResponse `json:"postings"`
+}
+
+type PostingResponse struct {
+	AccountID string `json:"accountId"`
+	Account   string `json:"account,omitempty"`
+	AssetID   string `json:"assetId"`
+	Amount    string `json:"amount"`
+}
+
+type ListJournalEntriesResponse struct {
+	Entries []JournalEntryResponse `json:"entries"`
+}
+
+type ReconciliationIssueResponse struct {
+	Kind           string `json:"kind"`
+	WalletID       string `json:"walletId,omitempty"`
+	VaultAccountID string `json:"vaultAccountId,omitempty"`
+	AssetID        string `json:"assetId,omitempty"`
+	Reference      string `json:"reference,omitempty"`
+	Expected       string `json:"expected,omitempty"`
+	Actual         string `json:"actual,omitempty"`
+	Details        string `json:"details,omitempty"`
+}
+
+type ReconciliationReportResponse struct {
+	ID         string                        `json:"id"`
+	Status     string                        `json:"status"`
+	Error      string                        `json:"error,omitempty"`
+	IssueCount int                           `json:"issueCount"`
+	StartedAt  time.Time                     `json:"startedAt"`
+	FinishedAt time.Time                     `json:"finishedAt"`
+	Issues     []ReconciliationIssueResponse `json:"issues"`
+}
+
+type ListReconciliationReportsResponse struct {
+	Reports []ReconciliationReportResponse `json:"reports"`
+}
+
+type SweepResponse struct {
+	ID               string    `json:"id"`
+	WalletID         string    `json:"walletId"`
+	AssetID          string    `json:"assetId"`
+	TreasuryWalletID string    `json:"treasuryWalletId"`
+	Available        string    `json:"available"`
+	Amount           string    `json:"amount"`
+	Status           string    `json:"status"`
+	TransactionID    string    `json:"transactionId,omitempty"`
+	Error            string    `json:"error,omitempty"`
+	Attempts         int       `json:"attempts"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+type ListSweepsResponse struct {
+	Sweeps []SweepResponse `json:"sweeps"`
+}
+
+type AuditEntryResponse struct {
+	Sequence      int64           `json:"sequence"`
+	Actor         string          `json:"actor"`
+	Action        string          `json:"action"`
+	Target        string          `json:"target,omitempty"`
+	RequestID     string          `json:"requestId"`
+	SourceIP      string          `json:"sourceIp,omitempty"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	Outcome       string          `json:"outcome"`
+	StatusCode    int             `json:"statusCode"`
+	FireblocksIDs []string        `json:"fireblocksIds,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	PrevHash      string          `json:"prevHash"`
+	Hash          string          `json:"hash"`
+}
+
+type ListAuditEntriesResponse struct {
+	Entries []AuditEntryResponse `json:"entries"`
+	// NextBefore is the before cursor of the next page, zero on the last page
+	NextBefore int64 `json:"nextBefore,omitempty"`
+}
+
+type AuditVerificationResponse struct {
+	Valid    bool   `json:"valid"`
+	Checked  int    `json:"checked"`
+	BrokenAt int64  `json:"brokenAt,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type DependencyStatusResponse struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latencyMs"`
+}
+
+type HealthResponse struct {
+	Status       string                              `json:"status"`
+	Dependencies map[string]DependencyStatusResponse `json:"dependencies,omitempty"`
+}
+
+type StatusResponse struct {
+	Status        string                              `json:"status"`
+	Version       string                              `json:"version"`
+	Commit        string                              `json:"commit,omitempty"`
+	BuildTime     string                              `json:"buildTime,omitempty"`
+	GoVersion     string                              `json:"goVersion"`
+	StartedAt     time.Time                           `json:"startedAt"`
+	UptimeSeconds int64                               `json:"uptimeSeconds"`
+	Dependencies  map[string]DependencyStatusResponse `json:"dependencies"`
+}
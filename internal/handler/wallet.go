@@ -1,49 +1,83 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/audit"
+	"firego-wallet-service/internal/cache"
 	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/idempotency"
+	"firego-wallet-service/internal/logging"
 	"firego-wallet-service/internal/model"
+	"firego-wallet-service/internal/tracing"
+	"firego-wallet-service/internal/transfer"
+	"fmt"
+	"go.opentelemetry.io/otel"
 	"gorm.io/gorm"
-	"log"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
+var tracer = otel.Tracer("firego-wallet-service/internal/handler")
+
 type FireblocksClient interface {
-	CreateVaultAccount(req fireblocks.CreateVaultAccountRequest) (*fireblocks.CreateVaultAccountResponse, int, error)
-	GetVaultAccountAssetBalance(vaultAccountID, assetID string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error)
-	GetVaultAccountAssetAddresses(vaultAccountID, assetID string) (*fireblocks.GetVaultAccountAssetAddressesResponse, int, error)
-	CreateTransaction(req fireblocks.CreateTransactionRequest) (*fireblocks.CreateTransactionResponse, int, error)
+	CreateVaultAccount(ctx context.Context, req fireblocks.CreateVaultAccountRequest) (*fireblocks.CreateVaultAccountResponse, int, error)
+	GetVaultAccountAssetBalance(ctx context.Context, vaultAccountID, assetID string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error)
+	GetVaultAccountAssetAddresses(ctx context.Context, vaultAccountID, assetID string) (*fireblocks.GetVaultAccountAssetAddressesResponse, int, error)
+	GetVaultAccountAssetAddressesPage(ctx context.Context, vaultAccountID, assetID string, page fireblocks.PageRequest) (*fireblocks.GetVaultAccountAssetAddressesResponse, int, error)
+	CreateVaultAccountAssetAddress(ctx context.Context, vaultAccountID, assetID string, req fireblocks.CreateVaultAccountAssetAddressRequest) (*fireblocks.CreateVaultAccountAssetAddressResponse, int, error)
+}
+
+// BalanceCache reads the vault asset balances through a cache, see cache.Balances
+type BalanceCache interface {
+	GetVaultAccountAssetBalance(ctx context.Context, vaultAccountID, assetID string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error)
+	Invalidate(ctx context.Context, vaultAccountID, assetID string)
 }
 
 type WalletRepository interface {
-	Create(wallet *model.Wallet) error
-	GetByID(id string) (*model.Wallet, error)
+	Create(ctx context.Context, wallet *model.Wallet) error
+	GetByID(ctx context.Context, id string) (*model.Wallet, error)
+}
+
+// maxAddressPageSize is the largest page size accepted by the Fireblocks addresses_paginated endpoint
+const maxAddressPageSize = 1000
+
+// Transferer initiates the transfers out of the wallets, see transfer.Service
+type Transferer interface {
+	Transfer(ctx context.Context, walletID string, req transfer.Request) (*model.Transfer, error)
 }
 
 type WalletHandler struct {
 	walletRepo       WalletRepository
 	fireblocksClient FireblocksClient
+	balances         BalanceCache
+	transfers        Transferer
 }
 
-func NewWalletHandler(walletRepo WalletRepository, fireblocksClient FireblocksClient) *WalletHandler {
+func NewWalletHandler(walletRepo WalletRepository, fireblocksClient FireblocksClient, balances BalanceCache, transfers Transferer) *WalletHandler {
 	return &WalletHandler{
 		walletRepo:       walletRepo,
 		fireblocksClient: fireblocksClient,
+		balances:         balances,
+		transfers:        transfers,
 	}
 }
 
 func (h *WalletHandler) CreateWallet(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "WalletHandler.CreateWallet")
+	defer span.End()
 	var req CreateWalletRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
 		return
 	}
 
 	if req.Name == "" {
-		http.Error(w, "Wallet name is required", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet name is required")
 		return
 	}
 
@@ -51,28 +85,33 @@ func (h *WalletHandler) CreateWallet(w http.ResponseWriter, r *http.Request) {
 		Name: req.Name,
 	}
 
-	fbResp, statusCode, err := h.fireblocksClient.CreateVaultAccount(fbReq)
+	// a request run again with the same Idempotency-Key gets the vault account created the first time
+	if key := idempotency.Key(ctx); key != "" {
+		ctx = fireblocks.WithIdempotencyKey(ctx, key)
+	}
+	idempotency.Attempted(ctx)
+	fbResp, statusCode, err := h.fireblocksClient.CreateVaultAccount(ctx, fbReq)
 	if err != nil {
-		log.Printf("Failed to create Fireblocks vault account: %v", err)
+		logging.FromContext(ctx).Error("Failed to create Fireblocks vault account", "error", err)
 
-		if statusCode >= 400 && statusCode < 500 {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
-		} else {
-			http.Error(w, "Service unavailable", http.StatusInternalServerError)
-		}
+		writeError(w, r, transfer.FireblocksError(statusCode, "Invalid request", "Service unavailable"))
 		return
 	}
+	audit.AddFireblocksID(ctx, fbResp.ID)
+	span.SetAttributes(tracing.VaultAccountID.String(fbResp.ID))
 
 	wallet := model.Wallet{
 		Name:           req.Name,
 		VaultAccountID: fbResp.ID,
 	}
-	err = h.walletRepo.Create(&wallet)
+	err = h.walletRepo.Create(ctx, &wallet)
 	if err != nil {
-		log.Printf("Failed to create wallet: %v", err)
-		http.Error(w, "Failed to create wallet", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("Failed to create wallet", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to create wallet")
 		return
 	}
+	audit.SetTarget(ctx, wallet.ID)
+	span.SetAttributes(tracing.WalletID.String(wallet.ID))
 
 	response := CreateWalletResponse{
 		ID:             wallet.ID,
@@ -83,7 +122,7 @@ func (h *WalletHandler) CreateWallet(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err = json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Failed to encode response: %v", err)
+		logging.FromContext(ctx).Error("Failed to encode response", "error", err)
 	}
 }
 
@@ -91,31 +130,35 @@ func (h *WalletHandler) GetWalletBalance(w http.ResponseWriter, r *http.Request)
 	walletID := r.PathValue("walletId")
 	assetID := r.PathValue("assetId")
 
+	ctx, span := tracer.Start(r.Context(), "WalletHandler.GetWalletBalance")
+	defer span.End()
+	span.SetAttributes(tracing.WalletID.String(walletID), tracing.AssetID.String(assetID))
+
 	if walletID == "" || assetID == "" {
-		http.Error(w, "Wallet ID and Asset ID are required", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID and Asset ID are required")
 		return
 	}
 
-	wallet, err := h.walletRepo.GetByID(walletID)
+	wallet, err := h.walletRepo.GetByID(ctx, walletID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			http.Error(w, "Wallet not found", http.StatusNotFound)
+			apierror.Write(w, r, http.StatusNotFound, apierror.WalletNotFound, "Wallet not found")
 			return
 		}
-		log.Printf("Failed to get wallet: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("Failed to get wallet", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
+	span.SetAttributes(tracing.VaultAccountID.String(wallet.VaultAccountID))
 
-	fbResp, statusCode, err := h.fireblocksClient.GetVaultAccountAssetBalance(wallet.VaultAccountID, assetID)
+	if noCache(r) {
+		ctx = cache.Bypass(ctx)
+	}
+	fbResp, statusCode, err := h.balances.GetVaultAccountAssetBalance(ctx, wallet.VaultAccountID, assetID)
 	if err != nil {
-		log.Printf("Failed to get balance from Fireblocks: %v", err)
+		logging.FromContext(ctx).Error("Failed to get balance from Fireblocks", "error", err)
 
-		if statusCode >= 400 && statusCode < 500 {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
-		} else {
-			http.Error(w, "Service unavailable", http.StatusInternalServerError)
-		}
+		writeError(w, r, transfer.FireblocksError(statusCode, "Invalid request", "Service unavailable"))
 		return
 	}
 
@@ -133,7 +176,7 @@ func (h *WalletHandler) GetWalletBalance(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err = json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Failed to encode response: %v", err)
+		logging.FromContext(ctx).Error("Failed to encode response", "error", err)
 	}
 }
 
@@ -141,37 +184,38 @@ func (h *WalletHandler) GetDepositAddress(w http.ResponseWriter, r *http.Request
 	walletID := r.PathValue("walletId")
 	assetID := r.PathValue("assetId")
 
+	ctx, span := tracer.Start(r.Context(), "WalletHandler.GetDepositAddress")
+	defer span.End()
+	span.SetAttributes(tracing.WalletID.String(walletID), tracing.AssetID.String(assetID))
+
 	if walletID == "" || assetID == "" {
-		http.Error(w, "Wallet ID and Asset ID are required", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID and Asset ID are required")
 		return
 	}
 
-	wallet, err := h.walletRepo.GetByID(walletID)
+	wallet, err := h.walletRepo.GetByID(ctx, walletID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			http.Error(w, "Wallet not found", http.StatusNotFound)
+			apierror.Write(w, r, http.StatusNotFound, apierror.WalletNotFound, "Wallet not found")
 			return
 		}
-		log.Printf("Failed to get wallet: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("Failed to get wallet", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
+	span.SetAttributes(tracing.VaultAccountID.String(wallet.VaultAccountID))
 
-	fbResp, statusCode, err := h.fireblocksClient.GetVaultAccountAssetAddresses(wallet.VaultAccountID, assetID)
+	fbResp, statusCode, err := h.fireblocksClient.GetVaultAccountAssetAddresses(ctx, wallet.VaultAccountID, assetID)
 	if err != nil {
-		log.Printf("Failed to get addresses from Fireblocks: %v", err)
+		logging.FromContext(ctx).Error("Failed to get addresses from Fireblocks", "error", err)
 
-		if statusCode >= 400 && statusCode < 500 {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
-		} else {
-			http.Error(w, "Service unavailable", http.StatusInternalServerError)
-		}
+		writeError(w, r, transfer.FireblocksError(statusCode, "Invalid request", "Service unavailable"))
 		return
 	}
 
 	if len(fbResp.Addresses) == 0 {
-		log.Printf("No addresses found for vault %s, asset %s", wallet.VaultAccountID, assetID)
-		http.Error(w, "No deposit address available", http.StatusNotFound)
+		logging.FromContext(ctx).Warn("No addresses found", "vault_account_id", wallet.VaultAccountID, "asset_id", assetID)
+		apierror.Write(w, r, http.StatusNotFound, apierror.NoDepositAddress, "No deposit address available")
 		return
 	}
 
@@ -187,112 +231,223 @@ func (h *WalletHandler) GetDepositAddress(w http.ResponseWriter, r *http.Request
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err = json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Failed to encode response: %v", err)
+		logging.FromContext(ctx).Error("Failed to encode response", "error", err)
 	}
 }
 
-func (h *WalletHandler) InitiateTransfer(w http.ResponseWriter, r *http.Request) {
+func (h *WalletHandler) CreateDepositAddress(w http.ResponseWriter, r *http.Request) {
 	walletID := r.PathValue("walletId")
+	assetID := r.PathValue("assetId")
 
-	if walletID == "" {
-		http.Error(w, "Wallet ID is required", http.StatusBadRequest)
+	ctx, span := tracer.Start(r.Context(), "WalletHandler.CreateDepositAddress")
+	defer span.End()
+	span.SetAttributes(tracing.WalletID.String(walletID), tracing.AssetID.String(assetID))
+
+	if walletID == "" || assetID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID and Asset ID are required")
 		return
 	}
 
-	var req InitiateTransferRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	// the body is optional, an empty one creates an address without description or customer reference
+	var req CreateDepositAddressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
 		return
 	}
 
-	if req.AssetID == "" {
-		http.Error(w, "Asset ID is required", http.StatusBadRequest)
+	wallet, err := h.walletRepo.GetByID(ctx, walletID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, http.StatusNotFound, apierror.WalletNotFound, "Wallet not found")
+			return
+		}
+		logging.FromContext(ctx).Error("Failed to get wallet", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
-	if req.Amount == "" {
-		http.Error(w, "Amount is required", http.StatusBadRequest)
+	span.SetAttributes(tracing.VaultAccountID.String(wallet.VaultAccountID))
+
+	fbReq := fireblocks.CreateVaultAccountAssetAddressRequest{
+		Description:   req.Description,
+		CustomerRefID: req.CustomerRefID,
+	}
+
+	audit.AddFireblocksID(ctx, wallet.VaultAccountID)
+	fbResp, statusCode, err := h.fireblocksClient.CreateVaultAccountAssetAddress(ctx, wallet.VaultAccountID, assetID, fbReq)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to create deposit address in Fireblocks", "error", err)
+
+		writeError(w, r, transfer.FireblocksError(statusCode, "Invalid request", "Service unavailable"))
+		return
+	}
+
+	response := DepositAddressResponse{
+		AssetID:           assetID,
+		Address:           fbResp.Address,
+		LegacyAddress:     fbResp.LegacyAddress,
+		Tag:               fbResp.Tag,
+		Description:       req.Description,
+		CustomerRefID:     req.CustomerRefID,
+		Bip44AddressIndex: fbResp.Bip44AddressIndex,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(ctx).Error("Failed to encode response", "error", err)
+	}
+}
+
+func (h *WalletHandler) ListDepositAddresses(w http.ResponseWriter, r *http.Request) {
+	walletID := r.PathValue("walletId")
+	assetID := r.PathValue("assetId")
+
+	ctx, span := tracer.Start(r.Context(), "WalletHandler.ListDepositAddresses")
+	defer span.End()
+	span.SetAttributes(tracing.WalletID.String(walletID), tracing.AssetID.String(assetID))
+
+	if walletID == "" || assetID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID and Asset ID are required")
 		return
 	}
-	if req.DestinationAddress == "" {
-		http.Error(w, "Destination address is required", http.StatusBadRequest)
+
+	query := r.URL.Query()
+	page := fireblocks.PageRequest{
+		Before: query.Get("before"),
+		After:  query.Get("after"),
+	}
+	if page.Before != "" && page.After != "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Only one of before and after can be set")
 		return
 	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxAddressPageSize {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, fmt.Sprintf("Limit must be between 1 and %d", maxAddressPageSize))
+			return
+		}
+		page.Limit = n
+	}
 
-	wallet, err := h.walletRepo.GetByID(walletID)
+	wallet, err := h.walletRepo.GetByID(ctx, walletID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			http.Error(w, "Wallet not found", http.StatusNotFound)
+			apierror.Write(w, r, http.StatusNotFound, apierror.WalletNotFound, "Wallet not found")
 			return
 		}
-		log.Printf("Failed to get wallet: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("Failed to get wallet", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
+	span.SetAttributes(tracing.VaultAccountID.String(wallet.VaultAccountID))
 
-	log.Printf("Validating balance for wallet %s, asset %s", walletID, req.AssetID)
-	balanceResp, statusCode, err := h.fireblocksClient.GetVaultAccountAssetBalance(wallet.VaultAccountID, req.AssetID)
+	fbResp, statusCode, err := h.fireblocksClient.GetVaultAccountAssetAddressesPage(ctx, wallet.VaultAccountID, assetID, page)
 	if err != nil {
-		log.Printf("Failed to get balance for validation: %v", err)
+		logging.FromContext(ctx).Error("Failed to get addresses from Fireblocks", "error", err)
 
-		if statusCode >= 400 && statusCode < 500 {
-			http.Error(w, "Invalid asset or wallet", http.StatusBadRequest)
-		} else {
-			http.Error(w, "Unable to validate balance", http.StatusInternalServerError)
-		}
+		writeError(w, r, transfer.FireblocksError(statusCode, "Invalid request", "Service unavailable"))
 		return
 	}
 
-	availableBalance, err := strconv.ParseFloat(balanceResp.Available, 64)
-	if err != nil {
-		log.Printf("Invalid balance format from Fireblocks: %s", balanceResp.Available)
-		http.Error(w, "Unable to validate balance", http.StatusInternalServerError)
-		return
+	response := ListDepositAddressesResponse{
+		Addresses: make([]DepositAddressResponse, 0, len(fbResp.Addresses)),
+		Paging: PagingResponse{
+			Before: fbResp.Paging.Before,
+			After:  fbResp.Paging.After,
+		},
 	}
-
-	transferAmount, err := strconv.ParseFloat(req.Amount, 64)
-	if err != nil {
-		http.Error(w, "Invalid amount format", http.StatusBadRequest)
-		return
+	for _, address := range fbResp.Addresses {
+		response.Addresses = append(response.Addresses, DepositAddressResponse{
+			AssetID:           address.AssetID,
+			Address:           address.Address,
+			LegacyAddress:     address.LegacyAddress,
+			Tag:               address.Tag,
+			Description:       address.Description,
+			CustomerRefID:     address.CustomerRefID,
+			AddressFormat:     address.AddressFormat,
+			Type:              address.Type,
+			Bip44AddressIndex: address.Bip44AddressIndex,
+		})
 	}
 
-	if transferAmount > availableBalance {
-		log.Printf("Insufficient balance: requested %f, available %f", transferAmount, availableBalance)
-		http.Error(w, "Insufficient balance", http.StatusBadRequest)
-		return
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(ctx).Error("Failed to encode response", "error", err)
 	}
+}
 
-	fbReq := fireblocks.NewVaultTransferRequest(
-		req.AssetID,
-		wallet.VaultAccountID,
-		req.DestinationAddress,
-		req.Amount,
-		req.Note,
-	)
+func (h *WalletHandler) InitiateTransfer(w http.ResponseWriter, r *http.Request) {
+	walletID := r.PathValue("walletId")
 
-	fbResp, statusCode, err := h.fireblocksClient.CreateTransaction(fbReq)
-	if err != nil {
-		log.Printf("Failed to create transaction in Fireblocks: %v", err)
+	ctx, span := tracer.Start(r.Context(), "WalletHandler.InitiateTransfer")
+	defer span.End()
+	span.SetAttributes(tracing.WalletID.String(walletID))
 
-		if statusCode >= 400 && statusCode < 500 {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
-		} else {
-			http.Error(w, "Service unavailable", http.StatusInternalServerError)
-		}
+	if walletID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Wallet ID is required")
 		return
 	}
 
-	response := InitiateTransferResponse{
-		TransactionID:      fbResp.ID,
-		Status:             fbResp.Status,
+	var req InitiateTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
+		return
+	}
+
+	// a request run again with the same Idempotency-Key gets the transaction created the first time
+	if key := idempotency.Key(ctx); key != "" {
+		ctx = fireblocks.WithIdempotencyKey(ctx, key)
+	}
+	created, err := h.transfers.Transfer(ctx, walletID, transfer.Request{
 		AssetID:            req.AssetID,
 		Amount:             req.Amount,
 		DestinationAddress: req.DestinationAddress,
 		Note:               req.Note,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response := InitiateTransferResponse{
+		TransactionID:      created.FireblocksTxID,
+		Status:             created.Status,
+		AssetID:            created.AssetID,
+		Amount:             created.Amount,
+		DestinationAddress: created.DestinationAddress,
+		Note:               created.Note,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err = json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Failed to encode response: %v", err)
+		logging.FromContext(ctx).Error("Failed to encode response", "error", err)
+	}
+}
+
+// writeError answers the request with the error of a transfer, a payout or a Fireblocks call, see transfer.Error,
+// telling the client when to retry the errors that are not its fault
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var transferErr *transfer.Error
+	if !errors.As(err, &transferErr) {
+		transferErr = &transfer.Error{StatusCode: http.StatusInternalServerError, Code: apierror.Internal, Message: "Internal server error"}
+	}
+	if transferErr.StatusCode == http.StatusTooManyRequests || transferErr.StatusCode == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", fireblocksRetryAfter)
+	}
+	apierror.Write(w, r, transferErr.StatusCode, transferErr.Code, transferErr.Message)
+}
+
+// fireblocksRetryAfter is the Retry-After, in seconds, of the requests failed by Fireblocks being rate limited or
+// unavailable
+const fireblocksRetryAfter = "1"
+
+// noCache reports whether the request asks for the balance to be read from Fireblocks, with Cache-Control: no-cache
+func noCache(r *http.Request) bool {
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return true
+		}
 	}
+	return false
 }
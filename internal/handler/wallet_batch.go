@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/audit"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/idempotency"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/model"
+	"firego-wallet-service/internal/tracing"
+	"firego-wallet-service/internal/transfer"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+	"net/http"
+	"strconv"
+)
+
+// Statuses of the wallets of a batch
+const (
+	// WalletBatchCreated is a wallet created with all its assets
+	WalletBatchCreated = "created"
+	// WalletBatchPartial is a wallet created and stored, some of whose assets failed to be activated
+	WalletBatchPartial = "partial"
+	// WalletBatchFailed is a wallet not created, or whose vault account could not be stored
+	WalletBatchFailed = "failed"
+)
+
+type BatchFireblocksClient interface {
+	CreateVaultAccount(ctx context.Context, req fireblocks.CreateVaultAccountRequest) (*fireblocks.CreateVaultAccountResponse, int, error)
+	CreateVaultAccountAsset(ctx context.Context, vaultAccountID, assetID string) (*fireblocks.CreateVaultAccountAssetResponse, int, error)
+}
+
+// BatchLimits bound the batches of wallets
+type BatchLimits struct {
+	// MaxSize is the most wallets a batch may create
+	MaxSize int
+	// Concurrency is how many wallets of a batch are created at the same time. Their Fireblocks calls still go through
+	// the client rate limiter, the workers only keep them from queuing for longer than it allows.
+	Concurrency int
+}
+
+type WalletBatchHandler struct {
+	walletRepo       WalletRepository
+	fireblocksClient BatchFireblocksClient
+	limits           BatchLimits
+}
+
+func NewWalletBatchHandler(walletRepo WalletRepository, fireblocksClient BatchFireblocksClient, limits BatchLimits) *WalletBatchHandler {
+	return &WalletBatchHandler{
+		walletRepo:       walletRepo,
+		fireblocksClient: fireblocksClient,
+		limits:           limits,
+	}
+}
+
+// CreateWallets creates a batch of wallets, each with its vault account and assets. The whole batch is validated up
+// front, then the wallets are created independently and the response reports the outcome of each of them.
+func (h *WalletBatchHandler) CreateWallets(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "WalletBatchHandler.CreateWallets")
+	defer span.End()
+
+	var req CreateWalletsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
+		return
+	}
+	if message := h.validate(req); message != "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, message)
+		return
+	}
+
+	results := make([]CreateWalletsResult, len(req.Wallets))
+	var workers errgroup.Group
+	workers.SetLimit(h.limits.Concurrency)
+	for i, item := range req.Wallets {
+		workers.Go(func() error {
+			results[i] = h.createWallet(ctx, i, item)
+			return nil
+		})
+	}
+	workers.Wait()
+
+	response := CreateWalletsResponse{Results: results}
+	for _, result := range results {
+		// the audit record is not safe for concurrent use, so the vault accounts are added once the workers are done
+		audit.AddFireblocksID(ctx, result.VaultAccountID)
+		switch result.Status {
+		case WalletBatchCreated:
+			response.Created++
+		case WalletBatchPartial:
+			response.Partial++
+		default:
+			response.Failed++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(ctx).Error("Failed to encode response", "error", err)
+	}
+}
+
+// validate returns why the batch is rejected, or an empty string
+func (h *WalletBatchHandler) validate(req CreateWalletsRequest) string {
+	if len(req.Wallets) == 0 {
+		return "At least one wallet is required"
+	}
+	if len(req.Wallets) > h.limits.MaxSize {
+		return fmt.Sprintf("At most %d wallets can be created at once", h.limits.MaxSize)
+	}
+	for i, item := range req.Wallets {
+		if item.Name == "" {
+			return fmt.Sprintf("Wallet %d: name is required", i)
+		}
+		seen := make(map[string]bool, len(item.Assets))
+		for _, assetID := range item.Assets {
+			if assetID == "" {
+				return fmt.Sprintf("Wallet %d: asset ID must not be empty", i)
+			}
+			if seen[assetID] {
+				return fmt.Sprintf("Wallet %d: asset %s is listed twice", i, assetID)
+			}
+			seen[assetID] = true
+		}
+	}
+	return ""
+}
+
+// createWallet creates the vault account, stores the wallet as soon as the vault account exists, so that it is never
+// left orphaned, and then activates the assets
+func (h *WalletBatchHandler) createWallet(ctx context.Context, index int, item CreateWalletsItem) CreateWalletsResult {
+	ctx, span := tracer.Start(ctx, "WalletBatchHandler.createWallet")
+	defer span.End()
+	result := CreateWalletsResult{
+		Index:  index,
+		Name:   item.Name,
+		Status: WalletBatchFailed,
+	}
+
+	// the wallets not started yet when the client goes away are not created
+	if err := ctx.Err(); err != nil {
+		result.Error = &apierror.Error{Code: apierror.ServiceUnavailable, Message: "Request cancelled"}
+		return result
+	}
+
+	// once requested, the vault account may exist in Fireblocks whatever happens to the request, so neither the client
+	// disconnecting nor the server shutting down may stop it from being stored. The idempotency key lets the Fireblocks
+	// client retry the request without creating a second vault account, and a batch run again with the same
+	// Idempotency-Key gets the vault accounts created the first time.
+	ctx = context.WithoutCancel(ctx)
+	key := uuid.NewString()
+	if requestKey := idempotency.Key(ctx); requestKey != "" {
+		key = requestKey + "-" + strconv.Itoa(index)
+	}
+	idempotency.Attempted(ctx)
+	fbResp, statusCode, err := h.fireblocksClient.CreateVaultAccount(fireblocks.WithIdempotencyKey(ctx, key), fireblocks.CreateVaultAccountRequest{Name: item.Name})
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to create Fireblocks vault account", "index", index, "error", err)
+		result.Error = batchError(statusCode)
+		return result
+	}
+	result.VaultAccountID = fbResp.ID
+	span.SetAttributes(tracing.VaultAccountID.String(fbResp.ID))
+
+	wallet := model.Wallet{
+		Name:           item.Name,
+		VaultAccountID: fbResp.ID,
+	}
+	// retried, since the vault account is orphaned otherwise
+	err = transfer.StoreCreated(ctx, "wallet", func() error { return h.walletRepo.Create(ctx, &wallet) }, "vault_account_id", fbResp.ID)
+	if err != nil {
+		// reconciliation reports the vault account as orphaned
+		logging.FromContext(ctx).Error("Failed to store wallet", "vault_account_id", fbResp.ID, "error", err)
+		result.Error = &apierror.Error{Code: apierror.Internal, Message: "Failed to create wallet"}
+		return result
+	}
+	result.ID = wallet.ID
+	result.Status = WalletBatchCreated
+	span.SetAttributes(tracing.WalletID.String(wallet.ID))
+
+	for _, assetID := range item.Assets {
+		asset := CreateWalletsAssetResult{AssetID: assetID}
+		assetCtx := fireblocks.WithIdempotencyKey(ctx, key+"-"+assetID)
+		assetResp, statusCode, err := h.fireblocksClient.CreateVaultAccountAsset(assetCtx, fbResp.ID, assetID)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to activate asset", "vault_account_id", fbResp.ID, "asset_id", assetID, "error", err)
+			asset.Error = batchError(statusCode)
+			result.Status = WalletBatchPartial
+		} else {
+			asset.Address = assetResp.Address
+			asset.Tag = assetResp.Tag
+		}
+		result.Assets = append(result.Assets, asset)
+	}
+	return result
+}
+
+// batchError is the error reported for a failed Fireblocks call, like the single wallet endpoints answer it
+func batchError(statusCode int) *apierror.Error {
+	err := transfer.FireblocksError(statusCode, "Invalid request", "Service unavailable")
+	return &apierror.Error{Code: err.Code, Message: err.Message}
+}
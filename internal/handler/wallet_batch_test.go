@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/idempotency"
+	"firego-wallet-service/internal/model"
+	"firego-wallet-service/internal/transfer"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// MemoryBatchWalletRepository is safe for the concurrent workers of a batch
+type MemoryBatchWalletRepository struct {
+	mu      sync.Mutex
+	wallets []model.Wallet
+	// FailVaults is how many times the wallet of each vault account fails to be stored
+	FailVaults map[string]int
+}
+
+func (m *MemoryBatchWalletRepository) Create(_ context.Context, wallet *model.Wallet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.FailVaults[wallet.VaultAccountID] > 0 {
+		m.FailVaults[wallet.VaultAccountID]--
+		return errors.New("connection reset")
+	}
+	wallet.ID = "wallet-" + wallet.VaultAccountID
+	m.wallets = append(m.wallets, *wallet)
+	return nil
+}
+
+func (m *MemoryBatchWalletRepository) GetByID(_ context.Context, _ string) (*model.Wallet, error) {
+	return nil, errors.New("not implemented")
+}
+
+// MockBatchFireblocksClient creates vault accounts named after their ID, and records how many calls ran at once
+type MockBatchFireblocksClient struct {
+	mu       sync.Mutex
+	nextID   int
+	inFlight int
+	// MaxInFlight is the most calls that ran at the same time
+	MaxInFlight int
+	// RejectNames are the names of the vault accounts Fireblocks rejects, FailAssets the assets it fails to activate
+	RejectNames map[string]bool
+	FailAssets  map[string]bool
+	// Cancel is called while a vault account is being created, e.g. as the client disconnecting
+	Cancel context.CancelFunc
+	// Keys are the idempotency keys of the vault accounts by name
+	Keys map[string]string
+}
+
+func (m *MockBatchFireblocksClient) enter() {
+	m.mu.Lock()
+	m.inFlight++
+	m.MaxInFlight = max(m.MaxInFlight, m.inFlight)
+	m.mu.Unlock()
+	time.Sleep(time.Millisecond)
+}
+
+func (m *MockBatchFireblocksClient) leave() {
+	m.mu.Lock()
+	m.inFlight--
+	m.mu.Unlock()
+}
+
+func (m *MockBatchFireblocksClient) CreateVaultAccount(ctx context.Context, req fireblocks.CreateVaultAccountRequest) (*fireblocks.CreateVaultAccountResponse, int, error) {
+	m.enter()
+	defer m.leave()
+	if m.Cancel != nil {
+		m.Cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	if m.RejectNames[req.Name] {
+		return nil, http.StatusBadRequest, errors.New("invalid name")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Keys == nil {
+		m.Keys = map[string]string{}
+	}
+	m.Keys[req.Name] = fireblocks.IdempotencyKey(ctx)
+	m.nextID++
+	return &fireblocks.CreateVaultAccountResponse{ID: strconv.Itoa(m.nextID), Name: req.Name}, http.StatusOK, nil
+}
+
+func (m *MockBatchFireblocksClient) CreateVaultAccountAsset(_ context.Context, _, assetID string) (*fireblocks.CreateVaultAccountAssetResponse, int, error) {
+	m.enter()
+	defer m.leave()
+	if m.FailAssets[assetID] {
+		return nil, http.StatusServiceUnavailable, errors.New("unavailable")
+	}
+	return &fireblocks.CreateVaultAccountAssetResponse{ID: assetID, Address: "address-" + assetID}, http.StatusOK, nil
+}
+
+func createWallets(h *WalletBatchHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/wallets:batch", bytes.NewBufferString(body))
+	req.Header.Set("Accept", "application/json")
+	recorder := httptest.NewRecorder()
+	h.CreateWallets(recorder, req)
+	return recorder
+}
+
+func TestCreateWallets(t *testing.T) {
+	repo := &MemoryBatchWalletRepository{}
+	client := &MockBatchFireblocksClient{}
+	h := NewWalletBatchHandler(repo, client, BatchLimits{MaxSize: 20, Concurrency: 3})
+
+	var req CreateWalletsRequest
+	for i := range 20 {
+		req.Wallets = append(req.Wallets, CreateWalletsItem{Name: "customer-" + strconv.Itoa(i), Assets: []string{"BTC_TEST"}})
+	}
+	body, err := json.Marshal(req)
+	assert.NoError(t, err)
+
+	recorder := createWallets(h, string(body))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var response CreateWalletsResponse
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	assert.Equal(t, 20, response.Created)
+	assert.Len(t, response.Results, 20)
+	for i, result := range response.Results {
+		assert.Equal(t, i, result.Index)
+		assert.Equal(t, "customer-"+strconv.Itoa(i), result.Name)
+		assert.Equal(t, WalletBatchCreated, result.Status)
+		assert.Equal(t, "wallet-"+result.VaultAccountID, result.ID)
+		assert.Equal(t, []CreateWalletsAssetResult{{AssetID: "BTC_TEST", Address: "address-BTC_TEST"}}, result.Assets)
+	}
+	assert.Len(t, repo.wallets, 20)
+	assert.LessOrEqual(t, client.MaxInFlight, 3)
+}
+
+func TestCreateWalletsPartialFailure(t *testing.T) {
+	// the wallet of vault account 1 is stored on the second attempt, the one of vault account 2 never
+	repo := &MemoryBatchWalletRepository{FailVaults: map[string]int{"1": 1, "2": transfer.StoreAttempts}}
+	client := &MockBatchFireblocksClient{
+		RejectNames: map[string]bool{"rejected": true},
+		FailAssets:  map[string]bool{"ETH_TEST5": true},
+	}
+	// a single worker creates the vault accounts in the request order, so that "unstored" gets vault account 2
+	h := NewWalletBatchHandler(repo, client, BatchLimits{MaxSize: 10, Concurrency: 1})
+
+	recorder := createWallets(h, `{"wallets": [
+		{"name": "created"},
+		{"name": "unstored", "assets": ["BTC_TEST"]},
+		{"name": "rejected"},
+		{"name": "partial", "assets": ["BTC_TEST", "ETH_TEST5"]}
+	]}`)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var response CreateWalletsResponse
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, 1, response.Partial)
+	assert.Equal(t, 2, response.Failed)
+	assert.Equal(t, []CreateWalletsResult{
+		{Index: 0, Name: "created", Status: WalletBatchCreated, ID: "wallet-1", VaultAccountID: "1"},
+		{Index: 1, Name: "unstored", Status: WalletBatchFailed, VaultAccountID: "2", Error: &apierror.Error{Code: apierror.Internal, Message: "Failed to create wallet"}},
+		{Index: 2, Name: "rejected", Status: WalletBatchFailed, Error: &apierror.Error{Code: apierror.FireblocksRejected, Message: "Invalid request"}},
+		{Index: 3, Name: "partial", Status: WalletBatchPartial, ID: "wallet-3", VaultAccountID: "3", Assets: []CreateWalletsAssetResult{
+			{AssetID: "BTC_TEST", Address: "address-BTC_TEST"},
+			{AssetID: "ETH_TEST5", Error: &apierror.Error{Code: apierror.ServiceUnavailable, Message: "Service unavailable"}},
+		}},
+	}, response.Results)
+	// the assets of a wallet that failed to be stored are not activated
+	assert.Len(t, repo.wallets, 2)
+}
+
+func TestCreateWalletsIdempotencyKey(t *testing.T) {
+	createKeys := func(idempotencyKey string) map[string]string {
+		client := &MockBatchFireblocksClient{}
+		h := NewWalletBatchHandler(&MemoryBatchWalletRepository{}, client, BatchLimits{MaxSize: 10, Concurrency: 2})
+		// a fresh store, as once the key of the first batch expired
+		keys := idempotency.New(idempotency.NewMemoryStore(), time.Hour)
+		req := httptest.NewRequest(http.MethodPost, "/wallets:batch", bytes.NewBufferString(`{"wallets": [{"name": "first"}, {"name": "second"}]}`))
+		if idempotencyKey != "" {
+			req.Header.Set(idempotency.Header, idempotencyKey)
+		}
+		recorder := httptest.NewRecorder()
+		keys.Wrap(h.CreateWallets)(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		return client.Keys
+	}
+
+	first := createKeys("batch-1")
+	assert.Len(t, first, 2)
+	assert.NotEqual(t, first["first"], first["second"])
+	// the batch run again gets the vault accounts created the first time
+	assert.Equal(t, first, createKeys("batch-1"))
+	assert.NotEqual(t, first, createKeys("batch-2"))
+
+	// without an Idempotency-Key, the keys only let the client retry the calls
+	unkeyed := createKeys("")
+	assert.NotEmpty(t, unkeyed["first"])
+	assert.NotEqual(t, unkeyed, createKeys(""))
+}
+
+func TestCreateWalletsClientDisconnects(t *testing.T) {
+	repo := &MemoryBatchWalletRepository{}
+	client := &MockBatchFireblocksClient{}
+	h := NewWalletBatchHandler(repo, client, BatchLimits{MaxSize: 10, Concurrency: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client.Cancel = cancel
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/wallets:batch", bytes.NewBufferString(`{"wallets": [{"name": "a"}]}`))
+	recorder := httptest.NewRecorder()
+	h.CreateWallets(recorder, req)
+
+	// the vault account requested before the client went away is created and stored
+	var response CreateWalletsResponse
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	assert.Equal(t, 1, response.Created)
+	assert.Len(t, repo.wallets, 1)
+}
+
+func TestCreateWalletsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantCode    string
+		wantMessage string
+	}{
+		{
+			name:        "invalid_body",
+			body:        `{"wallets": "all"}`,
+			wantCode:    apierror.InvalidBody,
+			wantMessage: "Invalid request body",
+		},
+		{
+			name:        "empty",
+			body:        `{"wallets": []}`,
+			wantCode:    apierror.InvalidRequest,
+			wantMessage: "At least one wallet is required",
+		},
+		{
+			name:        "too_many",
+			body:        `{"wallets": [{"name": "a"}, {"name": "b"}, {"name": "c"}]}`,
+			wantCode:    apierror.InvalidRequest,
+			wantMessage: "At most 2 wallets can be created at once",
+		},
+		{
+			name:        "missing_name",
+			body:        `{"wallets": [{"name": "a"}, {"assets": ["BTC_TEST"]}]}`,
+			wantCode:    apierror.InvalidRequest,
+			wantMessage: "Wallet 1: name is required",
+		},
+		{
+			name:        "duplicate_asset",
+			body:        `{"wallets": [{"name": "a", "assets": ["BTC_TEST", "BTC_TEST"]}]}`,
+			wantCode:    apierror.InvalidRequest,
+			wantMessage: "Wallet 0: asset BTC_TEST is listed twice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &MemoryBatchWalletRepository{}
+			client := &MockBatchFireblocksClient{}
+			h := NewWalletBatchHandler(repo, client, BatchLimits{MaxSize: 2, Concurrency: 2})
+
+			recorder := createWallets(h, tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			var response apierror.Error
+			assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+			assert.Equal(t, apierror.Error{Code: tt.wantCode, Message: tt.wantMessage}, response)
+			// nothing is created when any wallet of the batch is invalid
+			assert.Zero(t, client.nextID)
+		})
+	}
+}
+
+func TestCreateWalletsCancelled(t *testing.T) {
+	repo := &MemoryBatchWalletRepository{}
+	client := &MockBatchFireblocksClient{}
+	h := NewWalletBatchHandler(repo, client, BatchLimits{MaxSize: 10, Concurrency: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/wallets:batch", bytes.NewBufferString(`{"wallets": [{"name": "a"}, {"name": "b"}]}`))
+	recorder := httptest.NewRecorder()
+	h.CreateWallets(recorder, req)
+
+	var response CreateWalletsResponse
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	assert.Equal(t, 2, response.Failed)
+	assert.Equal(t, apierror.ServiceUnavailable, response.Results[1].Error.Code)
+	assert.Zero(t, client.nextID)
+}
@@ -2,11 +2,20 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/cache"
 	"firego-wallet-service/internal/fireblocks"
 	"firego-wallet-service/internal/model"
+	"firego-wallet-service/internal/tracing"
+	"firego-wallet-service/internal/transfer"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"gorm.io/gorm"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -21,7 +30,7 @@ type MockWalletRepository struct {
 	GetByIDError  error
 }
 
-func (m *MockWalletRepository) Create(wallet *model.Wallet) error {
+func (m *MockWalletRepository) Create(_ context.Context, wallet *model.Wallet) error {
 	if m.CreateError != nil {
 		return m.CreateError
 	}
@@ -36,7 +45,7 @@ func (m *MockWalletRepository) Create(wallet *model.Wallet) error {
 	return nil
 }
 
-func (m *MockWalletRepository) GetByID(_ string) (*model.Wallet, error) {
+func (m *MockWalletRepository) GetByID(_ context.Context, _ string) (*model.Wallet, error) {
 	if m.GetByIDError != nil {
 		return nil, m.GetByIDError
 	}
@@ -48,28 +57,78 @@ type MockFireblocksClient struct {
 	CreateVaultAccountResponse            *fireblocks.CreateVaultAccountResponse
 	GetVaultAccountAssetBalanceResponse   *fireblocks.GetVaultAccountAssetBalanceResponse
 	GetVaultAccountAssetAddressesResponse *fireblocks.GetVaultAccountAssetAddressesResponse
+	CreateAddressResponse                 *fireblocks.CreateVaultAccountAssetAddressResponse
 	CreateTransactionResponse             *fireblocks.CreateTransactionResponse
 
+	GetBalanceCalls int
+
+	ReceivedPageRequest    fireblocks.PageRequest
+	ReceivedAddressRequest fireblocks.CreateVaultAccountAssetAddressRequest
+
 	StatusCode int
 	Error      error
 }
 
-func (m *MockFireblocksClient) CreateVaultAccount(_ fireblocks.CreateVaultAccountRequest) (*fireblocks.CreateVaultAccountResponse, int, error) {
+func (m *MockFireblocksClient) CreateVaultAccount(_ context.Context, _ fireblocks.CreateVaultAccountRequest) (*fireblocks.CreateVaultAccountResponse, int, error) {
 	return m.CreateVaultAccountResponse, m.StatusCode, m.Error
 }
 
-func (m *MockFireblocksClient) GetVaultAccountAssetBalance(_, _ string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error) {
+func (m *MockFireblocksClient) GetVaultAccountAssetBalance(_ context.Context, _, _ string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error) {
+	m.GetBalanceCalls++
 	return m.GetVaultAccountAssetBalanceResponse, m.StatusCode, m.Error
 }
 
-func (m *MockFireblocksClient) GetVaultAccountAssetAddresses(_, _ string) (*fireblocks.GetVaultAccountAssetAddressesResponse, int, error) {
+func (m *MockFireblocksClient) GetVaultAccountAssetAddresses(_ context.Context, _, _ string) (*fireblocks.GetVaultAccountAssetAddressesResponse, int, error) {
+	return m.GetVaultAccountAssetAddressesResponse, m.StatusCode, m.Error
+}
+
+func (m *MockFireblocksClient) GetVaultAccountAssetAddressesPage(_ context.Context, _, _ string, page fireblocks.PageRequest) (*fireblocks.GetVaultAccountAssetAddressesResponse, int, error) {
+	m.ReceivedPageRequest = page
 	return m.GetVaultAccountAssetAddressesResponse, m.StatusCode, m.Error
 }
 
-func (m *MockFireblocksClient) CreateTransaction(_ fireblocks.CreateTransactionRequest) (*fireblocks.CreateTransactionResponse, int, error) {
+func (m *MockFireblocksClient) CreateVaultAccountAssetAddress(_ context.Context, _, _ string, req fireblocks.CreateVaultAccountAssetAddressRequest) (*fireblocks.CreateVaultAccountAssetAddressResponse, int, error) {
+	m.ReceivedAddressRequest = req
+	return m.CreateAddressResponse, m.StatusCode, m.Error
+}
+
+func (m *MockFireblocksClient) CreateTransaction(_ context.Context, _ fireblocks.CreateTransactionRequest) (*fireblocks.CreateTransactionResponse, int, error) {
 	return m.CreateTransactionResponse, m.StatusCode, m.Error
 }
 
+// DiscardTransfers drops the transfers it is given to store
+type DiscardTransfers struct{}
+
+func (DiscardTransfers) Create(*model.Transfer) error {
+	return nil
+}
+
+// NoReservations checks the transfers against the whole balance, no payout reserving any of it
+type NoReservations struct{}
+
+func (NoReservations) Reserve(ctx context.Context, _, _ string, fn func(ctx context.Context, reserved *big.Rat) error) error {
+	return fn(ctx, new(big.Rat))
+}
+
+func (NoReservations) Hold(context.Context, string, string, *big.Rat) (string, error) {
+	return "", nil
+}
+
+func (NoReservations) Release(context.Context, string) error {
+	return nil
+}
+
+// newTransferHandler initiates the transfers through a transfer service with the same mocks, no payout reserving any
+// of the balance
+func newTransferHandler(walletRepo WalletRepository, client *MockFireblocksClient, balances BalanceCache) *WalletHandler {
+	return NewWalletHandler(walletRepo, client, balances, transfer.NewService(walletRepo, DiscardTransfers{}, client, balances, NoReservations{}))
+}
+
+// uncachedBalances reads every balance from the client
+func uncachedBalances(client FireblocksClient) BalanceCache {
+	return cache.NewBalances(client, nil, 0)
+}
+
 func TestCreateWallet(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -133,7 +192,41 @@ func TestCreateWallet(t *testing.T) {
 			},
 			request: CreateWalletRequest{Name: "Test"},
 			assert: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+				assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+				assert.Contains(t, recorder.Body.String(), "Service unavailable")
+			},
+		},
+		{
+			name: "fireblocks_rate_limited",
+			mockSetup: func() (WalletRepository, FireblocksClient) {
+				mockFireblocksClient := &MockFireblocksClient{
+					CreateVaultAccountResponse: nil,
+					StatusCode:                 http.StatusTooManyRequests,
+					Error:                      fireblocks.ErrRateLimited,
+				}
+				return nil, mockFireblocksClient
+			},
+			request: CreateWalletRequest{Name: "Test"},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+				assert.Contains(t, recorder.Body.String(), "Fireblocks rate limit exceeded")
+			},
+		},
+		{
+			name: "fireblocks_unreachable",
+			mockSetup: func() (WalletRepository, FireblocksClient) {
+				mockFireblocksClient := &MockFireblocksClient{
+					CreateVaultAccountResponse: nil,
+					StatusCode:                 0,
+					Error:                      errors.New("failed to execute HTTP request: connection refused"),
+				}
+				return nil, mockFireblocksClient
+			},
+			request: CreateWalletRequest{Name: "Test"},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
 				assert.Contains(t, recorder.Body.String(), "Service unavailable")
 			},
 		},
@@ -173,7 +266,7 @@ func TestCreateWallet(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo, mockClient := tt.mockSetup()
-			handler := NewWalletHandler(mockRepo, mockClient)
+			handler := NewWalletHandler(mockRepo, mockClient, uncachedBalances(mockClient), nil)
 
 			reqBody, err := json.Marshal(tt.request)
 			assert.NoError(t, err)
@@ -335,7 +428,8 @@ func TestGetWalletBalance(t *testing.T) {
 			},
 			url: "/wallets/123/assets/BTC_TEST/balance",
 			assert: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+				assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
 				assert.Contains(t, recorder.Body.String(), "Service unavailable")
 			},
 		},
@@ -344,7 +438,7 @@ func TestGetWalletBalance(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo, mockClient := tt.mockSetup()
-			handler := NewWalletHandler(mockRepo, mockClient)
+			handler := NewWalletHandler(mockRepo, mockClient, uncachedBalances(mockClient), nil)
 
 			mux := http.NewServeMux()
 			mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/balance", handler.GetWalletBalance)
@@ -540,7 +634,8 @@ func TestGetDepositAddress(t *testing.T) {
 			},
 			url: "/wallets/123/assets/BTC_TEST/address",
 			assert: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+				assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
 				assert.Contains(t, recorder.Body.String(), "Service unavailable")
 			},
 		},
@@ -549,7 +644,7 @@ func TestGetDepositAddress(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo, mockClient := tt.mockSetup()
-			handler := NewWalletHandler(mockRepo, mockClient)
+			handler := NewWalletHandler(mockRepo, mockClient, uncachedBalances(mockClient), nil)
 
 			mux := http.NewServeMux()
 			mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/address", handler.GetDepositAddress)
@@ -564,17 +659,287 @@ func TestGetDepositAddress(t *testing.T) {
 	}
 }
 
+func TestCreateDepositAddress(t *testing.T) {
+	tests := []struct {
+		name      string
+		mockSetup func() (WalletRepository, *MockFireblocksClient)
+		url       string
+		body      string
+		assert    func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient)
+	}{
+		{
+			name: "success",
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
+				mockRepo := &MockWalletRepository{
+					GetByIDWallet: &model.Wallet{ID: "123", Name: "Test", VaultAccountID: "vault-account-id"},
+				}
+				mockFireblocksClient := &MockFireblocksClient{
+					CreateAddressResponse: &fireblocks.CreateVaultAccountAssetAddressResponse{
+						Address:           "tb1qnewaddress",
+						LegacyAddress:     "mlegacyaddress",
+						Bip44AddressIndex: 4,
+					},
+					StatusCode: http.StatusOK,
+				}
+				return mockRepo, mockFireblocksClient
+			},
+			url:  "/wallets/123/assets/BTC_TEST/addresses",
+			body: `{"description":"Invoice 42","customerRefId":"customer-7"}`,
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient) {
+				assert.Equal(t, http.StatusCreated, recorder.Code)
+				assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+				assert.Equal(t, "Invoice 42", mockClient.ReceivedAddressRequest.Description)
+				assert.Equal(t, "customer-7", mockClient.ReceivedAddressRequest.CustomerRefID)
+
+				var response DepositAddressResponse
+				err := json.NewDecoder(recorder.Body).Decode(&response)
+				assert.NoError(t, err)
+
+				assert.Equal(t, "BTC_TEST", response.AssetID)
+				assert.Equal(t, "tb1qnewaddress", response.Address)
+				assert.Equal(t, "mlegacyaddress", response.LegacyAddress)
+				assert.Equal(t, "Invoice 42", response.Description)
+				assert.Equal(t, "customer-7", response.CustomerRefID)
+				assert.Equal(t, 4, response.Bip44AddressIndex)
+			},
+		},
+		{
+			name: "success_empty_body",
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
+				mockRepo := &MockWalletRepository{
+					GetByIDWallet: &model.Wallet{ID: "123", Name: "Test", VaultAccountID: "vault-account-id"},
+				}
+				mockFireblocksClient := &MockFireblocksClient{
+					CreateAddressResponse: &fireblocks.CreateVaultAccountAssetAddressResponse{Address: "tb1qnewaddress"},
+					StatusCode:            http.StatusOK,
+				}
+				return mockRepo, mockFireblocksClient
+			},
+			url: "/wallets/123/assets/BTC_TEST/addresses",
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient) {
+				assert.Equal(t, http.StatusCreated, recorder.Code)
+				assert.Empty(t, mockClient.ReceivedAddressRequest.CustomerRefID)
+			},
+		},
+		{
+			name: "invalid_body",
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
+				return nil, nil
+			},
+			url:  "/wallets/123/assets/BTC_TEST/addresses",
+			body: `{"description":`,
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), "Invalid request body")
+			},
+		},
+		{
+			name: "wallet_not_found",
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
+				return &MockWalletRepository{GetByIDError: gorm.ErrRecordNotFound}, nil
+			},
+			url: "/wallets/nonexistent-wallet/assets/BTC_TEST/addresses",
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient) {
+				assert.Equal(t, http.StatusNotFound, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), "Wallet not found")
+			},
+		},
+		{
+			name: "fireblocks_asset_not_found",
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
+				mockRepo := &MockWalletRepository{
+					GetByIDWallet: &model.Wallet{ID: "123", Name: "Test", VaultAccountID: "vault-account-id"},
+				}
+				mockFireblocksClient := &MockFireblocksClient{
+					StatusCode: http.StatusNotFound,
+					Error:      fireblocks.ErrorResponse{Code: 1006, Message: "Not found"},
+				}
+				return mockRepo, mockFireblocksClient
+			},
+			url: "/wallets/123/assets/INVALID/addresses",
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), "Invalid request")
+			},
+		},
+		{
+			name: "fireblocks_server_error",
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
+				mockRepo := &MockWalletRepository{
+					GetByIDWallet: &model.Wallet{ID: "123", Name: "Test", VaultAccountID: "vault-account-id"},
+				}
+				mockFireblocksClient := &MockFireblocksClient{
+					StatusCode: http.StatusInternalServerError,
+					Error:      fireblocks.ErrorResponse{Code: 1000, Message: "Internal server error"},
+				}
+				return mockRepo, mockFireblocksClient
+			},
+			url: "/wallets/123/assets/BTC_TEST/addresses",
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient) {
+				assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+				assert.Contains(t, recorder.Body.String(), "Service unavailable")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo, mockClient := tt.mockSetup()
+			handler := NewWalletHandler(mockRepo, mockClient, uncachedBalances(mockClient), nil)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /wallets/{walletId}/assets/{assetId}/addresses", handler.CreateDepositAddress)
+
+			req := httptest.NewRequest(http.MethodPost, tt.url, bytes.NewReader([]byte(tt.body)))
+			recorder := httptest.NewRecorder()
+
+			mux.ServeHTTP(recorder, req)
+
+			tt.assert(t, recorder, mockClient)
+		})
+	}
+}
+
+func TestListDepositAddresses(t *testing.T) {
+	tests := []struct {
+		name      string
+		mockSetup func() (WalletRepository, *MockFireblocksClient)
+		url       string
+		assert    func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient)
+	}{
+		{
+			name: "success_with_cursor",
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
+				mockRepo := &MockWalletRepository{
+					GetByIDWallet: &model.Wallet{ID: "123", Name: "Test", VaultAccountID: "vault-account-id"},
+				}
+				mockFireblocksClient := &MockFireblocksClient{
+					GetVaultAccountAssetAddressesResponse: &fireblocks.GetVaultAccountAssetAddressesResponse{
+						Addresses: []fireblocks.VaultAccountAddress{
+							{AssetID: "BTC_TEST", Address: "tb1qfirst", AddressFormat: "SEGWIT", Type: "Permanent"},
+							{AssetID: "BTC_TEST", Address: "tb1qsecond", AddressFormat: "SEGWIT", Type: "Permanent", CustomerRefID: "customer-7", Bip44AddressIndex: 1},
+						},
+						Paging: fireblocks.Paging{After: "next-cursor"},
+					},
+					StatusCode: http.StatusOK,
+				}
+				return mockRepo, mockFireblocksClient
+			},
+			url: "/wallets/123/assets/BTC_TEST/addresses?limit=2&after=cursor",
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, fireblocks.PageRequest{After: "cursor", Limit: 2}, mockClient.ReceivedPageRequest)
+
+				var response ListDepositAddressesResponse
+				err := json.NewDecoder(recorder.Body).Decode(&response)
+				assert.NoError(t, err)
+
+				assert.Len(t, response.Addresses, 2)
+				assert.Equal(t, "tb1qsecond", response.Addresses[1].Address)
+				assert.Equal(t, "customer-7", response.Addresses[1].CustomerRefID)
+				assert.Equal(t, "next-cursor", response.Paging.After)
+			},
+		},
+		{
+			name: "success_empty",
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
+				mockRepo := &MockWalletRepository{
+					GetByIDWallet: &model.Wallet{ID: "123", Name: "Test", VaultAccountID: "vault-account-id"},
+				}
+				mockFireblocksClient := &MockFireblocksClient{
+					GetVaultAccountAssetAddressesResponse: &fireblocks.GetVaultAccountAssetAddressesResponse{},
+					StatusCode:                            http.StatusOK,
+				}
+				return mockRepo, mockFireblocksClient
+			},
+			url: "/wallets/123/assets/BTC_TEST/addresses",
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.JSONEq(t, `{"addresses":[],"paging":{}}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "invalid_limit",
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
+				return nil, nil
+			},
+			url: "/wallets/123/assets/BTC_TEST/addresses?limit=0",
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), "Limit must be between 1 and 1000")
+			},
+		},
+		{
+			name: "both_cursors",
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
+				return nil, nil
+			},
+			url: "/wallets/123/assets/BTC_TEST/addresses?before=a&after=b",
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "wallet_not_found",
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
+				return &MockWalletRepository{GetByIDError: gorm.ErrRecordNotFound}, nil
+			},
+			url: "/wallets/nonexistent-wallet/assets/BTC_TEST/addresses",
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient) {
+				assert.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "fireblocks_server_error",
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
+				mockRepo := &MockWalletRepository{
+					GetByIDWallet: &model.Wallet{ID: "123", Name: "Test", VaultAccountID: "vault-account-id"},
+				}
+				mockFireblocksClient := &MockFireblocksClient{
+					StatusCode: http.StatusInternalServerError,
+					Error:      fireblocks.ErrorResponse{Code: 1000, Message: "Internal server error"},
+				}
+				return mockRepo, mockFireblocksClient
+			},
+			url: "/wallets/123/assets/BTC_TEST/addresses",
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, mockClient *MockFireblocksClient) {
+				assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+				assert.Contains(t, recorder.Body.String(), "Service unavailable")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo, mockClient := tt.mockSetup()
+			handler := NewWalletHandler(mockRepo, mockClient, uncachedBalances(mockClient), nil)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/addresses", handler.ListDepositAddresses)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			recorder := httptest.NewRecorder()
+
+			mux.ServeHTTP(recorder, req)
+
+			tt.assert(t, recorder, mockClient)
+		})
+	}
+}
+
 func TestInitiateTransfer(t *testing.T) {
 	tests := []struct {
 		name      string
-		mockSetup func() (WalletRepository, FireblocksClient)
+		mockSetup func() (WalletRepository, *MockFireblocksClient)
 		url       string
 		request   InitiateTransferRequest
 		assert    func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
 			name: "success",
-			mockSetup: func() (WalletRepository, FireblocksClient) {
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
 				mockRepo := &MockWalletRepository{
 					GetByIDWallet: &model.Wallet{
 						ID:             "123",
@@ -622,7 +987,7 @@ func TestInitiateTransfer(t *testing.T) {
 		},
 		{
 			name: "invalid_request_missing_asset_id",
-			mockSetup: func() (WalletRepository, FireblocksClient) {
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
 				return nil, nil
 			},
 			url: "/wallets/123/transactions",
@@ -637,7 +1002,7 @@ func TestInitiateTransfer(t *testing.T) {
 		},
 		{
 			name: "invalid_request_missing_amount",
-			mockSetup: func() (WalletRepository, FireblocksClient) {
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
 				return nil, nil
 			},
 			url: "/wallets/123/transactions",
@@ -652,7 +1017,7 @@ func TestInitiateTransfer(t *testing.T) {
 		},
 		{
 			name: "invalid_request_missing_destination_address",
-			mockSetup: func() (WalletRepository, FireblocksClient) {
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
 				return nil, nil
 			},
 			url: "/wallets/123/transactions",
@@ -667,7 +1032,7 @@ func TestInitiateTransfer(t *testing.T) {
 		},
 		{
 			name: "wallet_not_found",
-			mockSetup: func() (WalletRepository, FireblocksClient) {
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
 				mockRepo := &MockWalletRepository{
 					GetByIDWallet: nil,
 					GetByIDError:  gorm.ErrRecordNotFound,
@@ -687,7 +1052,7 @@ func TestInitiateTransfer(t *testing.T) {
 		},
 		{
 			name: "database_error",
-			mockSetup: func() (WalletRepository, FireblocksClient) {
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
 				return &MockWalletRepository{GetByIDError: assert.AnError}, nil
 			},
 			url: "/wallets/123/transactions",
@@ -703,7 +1068,7 @@ func TestInitiateTransfer(t *testing.T) {
 		},
 		{
 			name: "fireblocks_asset_not_found",
-			mockSetup: func() (WalletRepository, FireblocksClient) {
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
 				mockRepo := &MockWalletRepository{
 					GetByIDWallet: &model.Wallet{
 						ID:             "123",
@@ -732,7 +1097,7 @@ func TestInitiateTransfer(t *testing.T) {
 		},
 		{
 			name: "invalid_amount_format",
-			mockSetup: func() (WalletRepository, FireblocksClient) {
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
 				mockRepo := &MockWalletRepository{
 					GetByIDWallet: &model.Wallet{
 						ID:             "123",
@@ -764,7 +1129,7 @@ func TestInitiateTransfer(t *testing.T) {
 		},
 		{
 			name: "insufficient_balance",
-			mockSetup: func() (WalletRepository, FireblocksClient) {
+			mockSetup: func() (WalletRepository, *MockFireblocksClient) {
 				mockRepo := &MockWalletRepository{
 					GetByIDWallet: &model.Wallet{
 						ID:             "123",
@@ -799,7 +1164,7 @@ func TestInitiateTransfer(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo, mockClient := tt.mockSetup()
-			handler := NewWalletHandler(mockRepo, mockClient)
+			handler := newTransferHandler(mockRepo, mockClient, uncachedBalances(mockClient))
 
 			reqBody, err := json.Marshal(tt.request)
 			assert.NoError(t, err)
@@ -817,3 +1182,65 @@ func TestInitiateTransfer(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateWalletSpan(t *testing.T) {
+	spanRecorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+
+	mockClient := &MockFireblocksClient{
+		CreateVaultAccountResponse: &fireblocks.CreateVaultAccountResponse{ID: "123", Name: "Test"},
+		StatusCode:                 http.StatusCreated,
+	}
+	handler := NewWalletHandler(&MockWalletRepository{}, mockClient, uncachedBalances(mockClient), nil)
+
+	body, _ := json.Marshal(CreateWalletRequest{Name: "Test"})
+	req := httptest.NewRequest(http.MethodPost, "/wallets", bytes.NewBuffer(body))
+	handler.CreateWallet(httptest.NewRecorder(), req)
+
+	spans := spanRecorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "WalletHandler.CreateWallet", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), tracing.VaultAccountID.String("123"))
+	assert.Contains(t, spans[0].Attributes(), tracing.WalletID.String("test-wallet-id-123"))
+}
+
+func TestBalanceCache(t *testing.T) {
+	mockRepo := &MockWalletRepository{
+		GetByIDWallet: &model.Wallet{ID: "123", Name: "Test", VaultAccountID: "vault-account-id"},
+	}
+	mockClient := &MockFireblocksClient{
+		GetVaultAccountAssetBalanceResponse: &fireblocks.GetVaultAccountAssetBalanceResponse{ID: "BTC_TEST", Available: "0.001"},
+		CreateTransactionResponse:           &fireblocks.CreateTransactionResponse{ID: "tx-id", Status: "SUBMITTED"},
+		StatusCode:                          http.StatusOK,
+	}
+	handler := newTransferHandler(mockRepo, mockClient, cache.NewBalances(mockClient, cache.NewMemoryStore(), time.Minute))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/balance", handler.GetWalletBalance)
+	mux.HandleFunc("POST /wallets/{walletId}/transactions", handler.InitiateTransfer)
+
+	getBalance := func(cacheControl string) {
+		req := httptest.NewRequest(http.MethodGet, "/wallets/123/assets/BTC_TEST/balance", nil)
+		req.Header.Set("Cache-Control", cacheControl)
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	}
+
+	getBalance("")
+	getBalance("")
+	assert.Equal(t, 1, mockClient.GetBalanceCalls)
+
+	getBalance("max-age=0, No-Cache")
+	assert.Equal(t, 2, mockClient.GetBalanceCalls)
+
+	// the transfer validates against a fresh balance, then invalidates it
+	reqBody, _ := json.Marshal(InitiateTransferRequest{AssetID: "BTC_TEST", Amount: "0.0005", DestinationAddress: "tb1q24jg2svw7430u3slcp0rlml7u2tse3h53q0jwe"})
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/wallets/123/transactions", bytes.NewReader(reqBody)))
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Equal(t, 3, mockClient.GetBalanceCalls)
+
+	getBalance("")
+	assert.Equal(t, 4, mockClient.GetBalanceCalls)
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/logging"
+	"io"
+	"net/http"
+)
+
+// maxWebhookBodySize bounds the webhook payload we are willing to read before verifying its signature
+const maxWebhookBodySize = 1 << 20
+
+type TransactionProcessor interface {
+	ProcessTransaction(ctx context.Context, tx fireblocks.TransactionResponse) error
+}
+
+type WebhookHandler struct {
+	publicKey  *rsa.PublicKey
+	processors []TransactionProcessor
+}
+
+// NewWebhookHandler creates a handler passing every transaction event to all processors, each one picks the transactions it tracks
+func NewWebhookHandler(publicKey *rsa.PublicKey, processors ...TransactionProcessor) *WebhookHandler {
+	return &WebhookHandler{
+		publicKey:  publicKey,
+		processors: processors,
+	}
+}
+
+func (h *WebhookHandler) HandleFireblocksWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize))
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
+		return
+	}
+
+	if err = fireblocks.VerifyWebhookSignature(h.publicKey, body, r.Header.Get(fireblocks.WebhookSignatureHeader)); err != nil {
+		logging.FromContext(r.Context()).Warn("Rejected Fireblocks webhook", "error", err)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidSignature, "Invalid signature")
+		return
+	}
+
+	var event fireblocks.WebhookEvent
+	if err = json.Unmarshal(body, &event); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
+		return
+	}
+
+	switch event.Type {
+	case fireblocks.WebhookEventTransactionCreated, fireblocks.WebhookEventTransactionStatusUpdated:
+		var tx fireblocks.TransactionResponse
+		if err = json.Unmarshal(event.Data, &tx); err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Invalid transaction data")
+			return
+		}
+
+		for _, processor := range h.processors {
+			if err = processor.ProcessTransaction(r.Context(), tx); err != nil {
+				// a non-2xx response makes Fireblocks retry the delivery, processors are idempotent
+				logging.FromContext(r.Context()).Error("Failed to process webhook", "transaction_id", tx.ID, "error", err)
+				apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+				return
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/base64"
+	"firego-wallet-service/internal/fireblocks"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type MockTransactionProcessor struct {
+	Processed []fireblocks.TransactionResponse
+	Error     error
+}
+
+func (m *MockTransactionProcessor) ProcessTransaction(_ context.Context, tx fireblocks.TransactionResponse) error {
+	m.Processed = append(m.Processed, tx)
+	return m.Error
+}
+
+func signWebhookBody(t *testing.T, key *rsa.PrivateKey, body []byte) string {
+	digest := sha512.Sum512(body)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA512, digest[:])
+	assert.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestHandleFireblocksWebhook(t *testing.T) {
+	webhookKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	txEvent := []byte(`{"type":"TRANSACTION_STATUS_UPDATED","tenantId":"tenant","timestamp":1700000000000,"data":{"id":"tx-1","status":"COMPLETED","assetId":"BTC_TEST","destination":{"type":"VAULT_ACCOUNT","id":"86"},"numOfConfirmations":3}}`)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature func(body []byte) string
+		processor *MockTransactionProcessor
+		assert    func(t *testing.T, recorder *httptest.ResponseRecorder, processor *MockTransactionProcessor)
+	}{
+		{
+			name:      "transaction_event",
+			body:      txEvent,
+			signature: func(body []byte) string { return signWebhookBody(t, webhookKey, body) },
+			processor: &MockTransactionProcessor{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, processor *MockTransactionProcessor) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Len(t, processor.Processed, 1)
+				assert.Equal(t, "tx-1", processor.Processed[0].ID)
+				assert.Equal(t, "86", processor.Processed[0].Destination.ID)
+				assert.Equal(t, 3, processor.Processed[0].NumOfConfirmations)
+			},
+		},
+		{
+			name:      "other_event_is_acknowledged",
+			body:      []byte(`{"type":"VAULT_ACCOUNT_ADDED","data":{"id":"87"}}`),
+			signature: func(body []byte) string { return signWebhookBody(t, webhookKey, body) },
+			processor: &MockTransactionProcessor{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, processor *MockTransactionProcessor) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Empty(t, processor.Processed)
+			},
+		},
+		{
+			name:      "invalid_signature",
+			body:      txEvent,
+			signature: func(body []byte) string { return signWebhookBody(t, webhookKey, []byte("other body")) },
+			processor: &MockTransactionProcessor{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, processor *MockTransactionProcessor) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Empty(t, processor.Processed)
+			},
+		},
+		{
+			name:      "missing_signature",
+			body:      txEvent,
+			signature: func(body []byte) string { return "" },
+			processor: &MockTransactionProcessor{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, processor *MockTransactionProcessor) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:      "invalid_json",
+			body:      []byte(`not json`),
+			signature: func(body []byte) string { return signWebhookBody(t, webhookKey, body) },
+			processor: &MockTransactionProcessor{},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, processor *MockTransactionProcessor) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "processing_error_triggers_retry",
+			body:      txEvent,
+			signature: func(body []byte) string { return signWebhookBody(t, webhookKey, body) },
+			processor: &MockTransactionProcessor{Error: assert.AnError},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder, processor *MockTransactionProcessor) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewWebhookHandler(&webhookKey.PublicKey, tt.processor)
+
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/fireblocks", bytes.NewReader(tt.body))
+			req.Header.Set(fireblocks.WebhookSignatureHeader, tt.signature(tt.body))
+			recorder := httptest.NewRecorder()
+
+			handler.HandleFireblocksWebhook(recorder, req)
+
+			tt.assert(t, recorder, tt.processor)
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Package idempotency makes POST requests safe to retry: the response to a request carrying an Idempotency-Key is
+// kept, and replayed to the requests sent again with the same key instead of running them again.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/apikey"
+	"firego-wallet-service/internal/logging"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Header carries the key chosen by the client, unique per request it wants run once
+const Header = "Idempotency-Key"
+
+// ReplayedHeader is set to true on the responses replayed from the first request with the key
+const ReplayedHeader = "Idempotent-Replayed"
+
+const maxKeyLength = 255
+
+// pendingTTL is how long a key stays reserved by a request that did not complete, after which it can be used again.
+// It is well over the server write timeout, so that only requests of a stopped instance are left behind.
+const pendingTTL = 5 * time.Minute
+
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+)
+
+// Record is what is kept for a key
+type Record struct {
+	// Fingerprint identifies the request the key was first used with
+	Fingerprint string
+	// Completed is false while the first request is being handled
+	Completed   bool
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// Store keeps the records by key. Implementations are safe for concurrent use and may be shared by the instances of
+// the service.
+type Store interface {
+	// Reserve records that the request with fingerprint is being handled for key, until expiresAt, and returns nil.
+	// When key is already in use, nothing is changed and its record is returned.
+	Reserve(ctx context.Context, key, fingerprint string, expiresAt time.Time) (*Record, error)
+	// Complete stores the response of the request that reserved key, kept until expiresAt
+	Complete(ctx context.Context, key string, record Record, expiresAt time.Time) error
+	// Release frees key, so that the request can be run again
+	Release(ctx context.Context, key string) error
+}
+
+// request is the state of a request run by Wrap, shared with the handler through its context
+type request struct {
+	key string
+	// attempted is set once the handler made a call it cannot take back, see Attempted
+	attempted atomic.Bool
+}
+
+type requestContextKey struct{}
+
+// Key returns the Idempotency-Key of the request run by Wrap, scoped to its API key, or an empty string without one.
+// Handlers send it as the idempotency key of the calls they make, e.g. to Fireblocks, so that a request run again
+// after a failure whose outcome is unknown gets the result of the first call instead of making it twice.
+func Key(ctx context.Context) string {
+	if req, ok := ctx.Value(requestContextKey{}).(*request); ok {
+		return req.key
+	}
+	return ""
+}
+
+// Attempted tells Wrap that the request is about to make a call that may be applied whatever it is answered with, e.g.
+// create a Fireblocks transaction. A server error answered afterwards leaves the outcome unknown, so the key is not
+// released and the retries are not run again until it expires.
+func Attempted(ctx context.Context) {
+	if req, ok := ctx.Value(requestContextKey{}).(*request); ok {
+		req.attempted.Store(true)
+	}
+}
+
+type Keys struct {
+	store Store
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+// New keeps the responses in store for ttl
+func New(store Store, ttl time.Duration) *Keys {
+	return &Keys{
+		store: store,
+		ttl:   ttl,
+		now:   time.Now,
+	}
+}
+
+// Wrap runs the requests to handler with an Idempotency-Key once per key. Requests sent again with the key get the
+// first response, 409 while it is not complete, or 422 when they differ from the first request. Server errors and 429s
+// are not kept: the request can be retried with the same key, and runs again. Server errors answered once the handler
+// called Attempted are the exception, the key stays reserved until it expires and the retries get 409 until then.
+func (k *Keys) Wrap(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" {
+			handler(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Idempotency-Key must be at most "+strconv.Itoa(maxKeyLength)+" characters long")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				apierror.Write(w, r, http.StatusRequestEntityTooLarge, apierror.BodyTooLarge, "Request body too large")
+			} else {
+				apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
+			}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		storeKey := scopedKey(r, key)
+		requestFingerprint := fingerprint(r, body)
+		record, err := k.store.Reserve(ctx, storeKey, requestFingerprint, k.now().Add(pendingTTL))
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to reserve idempotency key", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+			return
+		}
+		if record != nil {
+			replay(w, r, record, requestFingerprint)
+			return
+		}
+
+		state := &request{key: storeKey}
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(recorder, r.WithContext(context.WithValue(ctx, requestContextKey{}, state)))
+
+		// like the handlers submitting transfers, the outcome is stored even if the client went away
+		ctx = context.WithoutCancel(ctx)
+		switch {
+		case recorder.status >= http.StatusInternalServerError && state.attempted.Load():
+			// what the handler called may have been applied, running the request again must wait for the key to expire
+			logging.FromContext(ctx).Warn("Outcome of idempotent request unknown, keeping its key reserved", "status", recorder.status)
+			return
+		case recorder.status >= http.StatusInternalServerError || recorder.status == http.StatusTooManyRequests:
+			err = k.store.Release(ctx, storeKey)
+		default:
+			err = k.store.Complete(ctx, storeKey, Record{
+				Fingerprint: requestFingerprint,
+				Completed:   true,
+				StatusCode:  recorder.status,
+				ContentType: recorder.Header().Get("Content-Type"),
+				Body:        recorder.body.Bytes(),
+			}, k.now().Add(k.ttl))
+		}
+		if err != nil {
+			// the key stays reserved until pendingTTL, retries get 409 until then rather than running twice
+			logging.FromContext(ctx).Error("Failed to store idempotent response", "status", recorder.status, "error", err)
+		}
+	}
+}
+
+func replay(w http.ResponseWriter, r *http.Request, record *Record, requestFingerprint string) {
+	switch {
+	case record.Fingerprint != requestFingerprint:
+		apierror.Write(w, r, http.StatusUnprocessableEntity, apierror.IdempotencyKeyReused, "Idempotency-Key was used for a different request")
+	case !record.Completed:
+		w.Header().Set("Retry-After", "1")
+		apierror.Write(w, r, http.StatusConflict, apierror.RequestInProgress, "A request with this Idempotency-Key is in progress")
+	default:
+		if record.ContentType != "" {
+			w.Header().Set("Content-Type", record.ContentType)
+		}
+		w.Header().Set(ReplayedHeader, "true")
+		w.WriteHeader(record.StatusCode)
+		w.Write(record.Body)
+	}
+}
+
+// scopedKey keeps the keys of the API keys apart, so that clients cannot read each other's responses
+func scopedKey(r *http.Request, key string) string {
+	sum := sha256.Sum256([]byte(r.Header.Get(apikey.Header) + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// fingerprint identifies a request by its method, path and body
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\x00"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder passes the response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,191 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/apikey"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type FailingStore struct {
+	MemoryStore
+}
+
+func (*FailingStore) Reserve(_ context.Context, _, _ string, _ time.Time) (*Record, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func newTestKeys(store *MemoryStore, now *time.Time) *Keys {
+	store.now = func() time.Time { return *now }
+	keys := New(store, time.Hour)
+	keys.now = func() time.Time { return *now }
+	return keys
+}
+
+func serve(handler http.HandlerFunc, key, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/wallets/1/transactions", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+	return recorder
+}
+
+func TestWrap(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	keys := newTestKeys(NewMemoryStore(), &now)
+	calls := 0
+	handler := keys.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"call": ` + strconv.Itoa(calls) + `, "body": ` + string(body) + `}`))
+	})
+
+	first := serve(handler, "key-1", `{"amount": "1"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(ReplayedHeader))
+
+	t.Run("replayed", func(t *testing.T) {
+		recorder := serve(handler, "key-1", `{"amount": "1"}`)
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		assert.Equal(t, "true", recorder.Header().Get(ReplayedHeader))
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+		assert.Equal(t, first.Body.String(), recorder.Body.String())
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("different_request", func(t *testing.T) {
+		recorder := serve(handler, "key-1", `{"amount": "2"}`, "Accept", "application/json")
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		assert.JSONEq(t, `{"code": "idempotency_key_reused", "message": "Idempotency-Key was used for a different request"}`, recorder.Body.String())
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("keys_scoped_by_api_key", func(t *testing.T) {
+		recorder := serve(handler, "key-1", `{"amount": "1"}`, apikey.Header, "other-client")
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		assert.Empty(t, recorder.Header().Get(ReplayedHeader))
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("without_key", func(t *testing.T) {
+		serve(handler, "", `{"amount": "1"}`)
+		serve(handler, "", `{"amount": "1"}`)
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		recorder := serve(handler, "key-1", `{"amount": "1"}`)
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		assert.Empty(t, recorder.Header().Get(ReplayedHeader))
+		assert.Equal(t, 5, calls)
+	})
+
+	t.Run("key_too_long", func(t *testing.T) {
+		recorder := serve(handler, strings.Repeat("k", 256), `{}`)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Equal(t, 5, calls)
+	})
+}
+
+func TestWrapInProgress(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	keys := newTestKeys(NewMemoryStore(), &now)
+	var retry *httptest.ResponseRecorder
+	var handler http.HandlerFunc
+	handler = keys.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		// the retry arrives while the first request is being handled
+		retry = serve(handler, "key-1", `{}`)
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	assert.Equal(t, http.StatusCreated, serve(handler, "key-1", `{}`).Code)
+	assert.Equal(t, http.StatusConflict, retry.Code)
+	assert.Equal(t, "1", retry.Header().Get("Retry-After"))
+}
+
+func TestWrapServerError(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	keys := newTestKeys(NewMemoryStore(), &now)
+	statuses := []int{http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusBadRequest, http.StatusCreated}
+	calls := 0
+	handler := keys.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[calls])
+		calls++
+	})
+
+	// server errors and rate limited requests run again, client errors are kept like successes
+	assert.Equal(t, http.StatusInternalServerError, serve(handler, "key-1", `{}`).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(handler, "key-1", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(handler, "key-1", `{}`).Code)
+	recorder := serve(handler, "key-1", `{}`)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "true", recorder.Header().Get(ReplayedHeader))
+	assert.Equal(t, 3, calls)
+}
+
+func TestWrapOutcomeUnknown(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	keys := newTestKeys(NewMemoryStore(), &now)
+	var received []string
+	handler := keys.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, Key(r.Context()))
+		if len(received) == 1 {
+			// the call times out after it was sent
+			Attempted(r.Context())
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve(handler, "key-1", `{}`).Code)
+
+	// the retries wait for the key to expire rather than make the call again
+	recorder := serve(handler, "key-1", `{}`)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	assert.Len(t, received, 1)
+
+	now = now.Add(pendingTTL)
+	assert.Equal(t, http.StatusCreated, serve(handler, "key-1", `{}`).Code)
+
+	// the key the handler sends along is the same every time, and not the one chosen by the client
+	assert.Len(t, received, 2)
+	assert.NotEmpty(t, received[0])
+	assert.NotEqual(t, "key-1", received[0])
+	assert.Equal(t, received[0], received[1])
+
+	// requests without an Idempotency-Key have none
+	var key string
+	keys.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		key = Key(r.Context())
+		Attempted(r.Context())
+	})(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/wallets", nil))
+	assert.Empty(t, key)
+}
+
+func TestWrapStoreFailure(t *testing.T) {
+	keys := New(&FailingStore{}, time.Hour)
+	calls := 0
+	handler := keys.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+
+	// the request is not run when it cannot be made idempotent, the client retries it
+	assert.Equal(t, http.StatusInternalServerError, serve(handler, "key-1", `{}`).Code)
+	assert.Equal(t, 0, calls)
+}
@@ -0,0 +1,151 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// cleanupInterval is how often the expired keys are dropped
+const cleanupInterval = time.Minute
+
+type memoryRecord struct {
+	Record
+	expiresAt time.Time
+}
+
+// MemoryStore keeps the keys in the process, retries must reach the same instance of the service
+type MemoryStore struct {
+	mu          sync.Mutex
+	records     map[string]memoryRecord
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: map[string]memoryRecord{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Reserve(_ context.Context, key, fingerprint string, expiresAt time.Time) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastCleanup) >= cleanupInterval {
+		for k, record := range s.records {
+			if !now.Before(record.expiresAt) {
+				delete(s.records, k)
+			}
+		}
+		s.lastCleanup = now
+	}
+
+	if existing, ok := s.records[key]; ok && now.Before(existing.expiresAt) {
+		record := existing.Record
+		return &record, nil
+	}
+	s.records[key] = memoryRecord{Record: Record{Fingerprint: fingerprint}, expiresAt: expiresAt}
+	return nil, nil
+}
+
+func (s *MemoryStore) Complete(_ context.Context, key string, record Record, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = memoryRecord{Record: record, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// PostgresStore keeps the keys in the idempotency_keys table, shared by the instances of the service
+type PostgresStore struct {
+	db *sql.DB
+
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Reserve(ctx context.Context, key, fingerprint string, expiresAt time.Time) (*Record, error) {
+	s.cleanup(ctx)
+
+	// an expired key is taken over as if it did not exist. The key may expire between the insert and the select,
+	// in which case the insert is tried again.
+	for range 2 {
+		result, err := s.db.ExecContext(ctx, `
+			INSERT INTO idempotency_keys (key, fingerprint, expires_at) VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO UPDATE
+			SET fingerprint = EXCLUDED.fingerprint, status_code = NULL, content_type = '', body = NULL,
+				expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.expires_at < now()`, key, fingerprint, expiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+		if inserted, err := result.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+		} else if inserted == 1 {
+			return nil, nil
+		}
+
+		var record Record
+		var statusCode sql.NullInt64
+		err = s.db.QueryRowContext(ctx, `
+			SELECT fingerprint, status_code, content_type, body FROM idempotency_keys
+			WHERE key = $1 AND expires_at >= now()`, key).Scan(&record.Fingerprint, &statusCode, &record.ContentType, &record.Body)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read idempotency key: %w", err)
+		}
+		record.Completed = statusCode.Valid
+		record.StatusCode = int(statusCode.Int64)
+		return &record, nil
+	}
+	return nil, errors.New("failed to reserve idempotency key: key kept changing")
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, key string, record Record, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE idempotency_keys SET status_code = $2, content_type = $3, body = $4, expires_at = $5
+		WHERE key = $1 AND fingerprint = $6`, key, record.StatusCode, record.ContentType, record.Body, expiresAt, record.Fingerprint)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Release(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND status_code IS NULL", key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// cleanup drops the expired keys, at most once per cleanupInterval per instance
+func (s *PostgresStore) cleanup(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.lastCleanup) < cleanupInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastCleanup = time.Now()
+	s.mu.Unlock()
+
+	// a failed cleanup is retried on the next interval, expired keys are taken over by Reserve anyway
+	s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at < now()")
+}
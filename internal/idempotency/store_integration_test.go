@@ -0,0 +1,153 @@
+//go:build integration
+
+package idempotency
+
+import (
+	"context"
+	"firego-wallet-service/internal/testdb"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+var postgres *testdb.Server
+
+func TestMain(m *testing.M) {
+	var err error
+	postgres, err = testdb.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start Postgres: %v\n", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	postgres.Stop()
+	os.Exit(code)
+}
+
+func newPostgresStore(t *testing.T) *PostgresStore {
+	sqlDB, err := postgres.NewMigratedDatabase(t).DB()
+	if err != nil {
+		t.Fatalf("failed to get database handle: %v", err)
+	}
+	return NewPostgresStore(sqlDB)
+}
+
+func TestPostgresStoreReserve(t *testing.T) {
+	store := newPostgresStore(t)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Minute)
+
+	record, err := store.Reserve(ctx, "key", "fingerprint", expiresAt)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	// in use by the first request, whatever the fingerprint of the next one
+	for _, fingerprint := range []string{"fingerprint", "other"} {
+		record, err = store.Reserve(ctx, "key", fingerprint, expiresAt)
+		assert.NoError(t, err)
+		assert.Equal(t, &Record{Fingerprint: "fingerprint"}, record)
+	}
+
+	record, err = store.Reserve(ctx, "other-key", "fingerprint", expiresAt)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+}
+
+func TestPostgresStoreComplete(t *testing.T) {
+	store := newPostgresStore(t)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Minute)
+
+	_, err := store.Reserve(ctx, "key", "fingerprint", expiresAt)
+	assert.NoError(t, err)
+	completed := Record{Fingerprint: "fingerprint", Completed: true, StatusCode: http.StatusCreated, ContentType: "application/json", Body: []byte(`{"id":"1"}`)}
+	assert.NoError(t, store.Complete(ctx, "key", completed, time.Now().Add(time.Hour)))
+
+	record, err := store.Reserve(ctx, "key", "fingerprint", expiresAt)
+	assert.NoError(t, err)
+	assert.Equal(t, &completed, record)
+
+	// a request that lost its key does not overwrite the response of the one that took it over
+	assert.NoError(t, store.Complete(ctx, "key", Record{Fingerprint: "other", Completed: true, StatusCode: http.StatusOK}, expiresAt))
+	record, err = store.Reserve(ctx, "key", "fingerprint", expiresAt)
+	assert.NoError(t, err)
+	assert.Equal(t, &completed, record)
+}
+
+func TestPostgresStoreRelease(t *testing.T) {
+	store := newPostgresStore(t)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Minute)
+
+	_, err := store.Reserve(ctx, "pending", "fingerprint", expiresAt)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Release(ctx, "pending"))
+	record, err := store.Reserve(ctx, "pending", "other", expiresAt)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	// a completed key is kept, its response replayed
+	_, err = store.Reserve(ctx, "completed", "fingerprint", expiresAt)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Complete(ctx, "completed", Record{Fingerprint: "fingerprint", Completed: true, StatusCode: http.StatusOK}, expiresAt))
+	assert.NoError(t, store.Release(ctx, "completed"))
+	record, err = store.Reserve(ctx, "completed", "fingerprint", expiresAt)
+	assert.NoError(t, err)
+	assert.NotNil(t, record)
+	assert.True(t, record.Completed)
+
+	assert.NoError(t, store.Release(ctx, "unknown"))
+}
+
+func TestPostgresStoreExpiry(t *testing.T) {
+	store := newPostgresStore(t)
+	ctx := context.Background()
+
+	_, err := store.Reserve(ctx, "pending", "fingerprint", time.Now().Add(-time.Second))
+	assert.NoError(t, err)
+	_, err = store.Reserve(ctx, "completed", "fingerprint", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.NoError(t, store.Complete(ctx, "completed", Record{Fingerprint: "fingerprint", Completed: true, StatusCode: http.StatusOK, Body: []byte("{}")}, time.Now().Add(-time.Second)))
+
+	// an expired key is taken over, without the response of the request it was used with
+	for _, key := range []string{"pending", "completed"} {
+		record, err := store.Reserve(ctx, key, "other", time.Now().Add(time.Minute))
+		assert.NoError(t, err, key)
+		assert.Nil(t, record, key)
+
+		record, err = store.Reserve(ctx, key, "fingerprint", time.Now().Add(time.Minute))
+		assert.NoError(t, err, key)
+		assert.Equal(t, &Record{Fingerprint: "other"}, record, key)
+	}
+}
+
+func TestPostgresStoreConcurrentReserve(t *testing.T) {
+	store := newPostgresStore(t)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Minute)
+
+	const requests = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			record, err := store.Reserve(ctx, "key", fmt.Sprintf("fingerprint-%d", i), expiresAt)
+			assert.NoError(t, err)
+			if err == nil && record == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, reserved)
+}
@@ -0,0 +1,82 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+)
+
+// Elector elects a single leader among all instances sharing the database, using a Postgres session advisory lock.
+// The instance holding the lock stays leader until its dedicated connection is closed or lost,
+// at which point Postgres releases the lock and another instance takes over on its next attempt.
+type Elector struct {
+	db   *sql.DB
+	name string
+	key  int64
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+func NewElector(db *sql.DB, name string) *Elector {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+
+	return &Elector{
+		db:   db,
+		name: name,
+		key:  int64(h.Sum64()),
+	}
+}
+
+// IsLeader reports whether this instance holds the lock, trying to acquire it when it does not
+func (e *Elector) IsLeader(ctx context.Context) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn != nil {
+		if err := e.conn.PingContext(ctx); err == nil {
+			return true
+		}
+		slog.Warn("Lost leadership, the lock connection is gone", "election", e.name)
+		e.conn.Close()
+		e.conn = nil
+	}
+
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		slog.Error("Failed to get a connection for the leader election", "election", e.name, "error", err)
+		return false
+	}
+
+	var acquired bool
+	if err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", e.key).Scan(&acquired); err != nil || !acquired {
+		if err != nil {
+			slog.Error("Failed to try the leader lock", "election", e.name, "error", err)
+		}
+		conn.Close()
+		return false
+	}
+
+	slog.Info("Acquired leadership", "election", e.name)
+	e.conn = conn
+	return true
+}
+
+// Release gives up leadership, if held
+func (e *Elector) Release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn == nil {
+		return
+	}
+
+	if _, err := e.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", e.key); err != nil {
+		slog.Error("Failed to release the leader lock", "election", e.name, "error", err)
+	}
+	e.conn.Close()
+	e.conn = nil
+}
@@ -0,0 +1,50 @@
+package ledger
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// maxScale matches the scale of the numeric(38,18) amount column
+const maxScale = 18
+
+var amountPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// ParseAmount parses a non-negative decimal amount as sent by Fireblocks, e.g. "0.00012"
+func ParseAmount(s string) (*big.Rat, error) {
+	if !amountPattern.MatchString(s) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if _, fraction, ok := strings.Cut(s, "."); ok && len(strings.TrimRight(fraction, "0")) > maxScale {
+		return nil, fmt.Errorf("%w: %q has more than %d decimals", ErrInvalidAmount, s, maxScale)
+	}
+
+	amount, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	return amount, nil
+}
+
+// FormatAmount renders an amount as a plain decimal without trailing zeros
+func FormatAmount(amount *big.Rat) string {
+	s := amount.FloatString(maxScale)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// NormalizeAmount reformats a signed decimal read back from Postgres
+func NormalizeAmount(s string) string {
+	amount, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return s
+	}
+	return FormatAmount(amount)
+}
@@ -0,0 +1,314 @@
+package ledger
+
+import (
+	"errors"
+	"firego-wallet-service/internal/model"
+	"fmt"
+	"github.com/google/uuid"
+	"math/big"
+	"regexp"
+	"sort"
+)
+
+// MainSubAccount is the sub-account every wallet balance starts in; transfers, fees and deposits are booked against it
+const MainSubAccount = "main"
+
+var (
+	ErrInvalidAmount     = errors.New("invalid amount")
+	ErrUnbalancedEntry   = errors.New("journal entry is not balanced")
+	ErrDuplicateEntry    = errors.New("journal entry already exists")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInvalidSubAccount = errors.New("invalid sub-account")
+)
+
+var subAccountPattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+type Repository interface {
+	GetOrCreateAccount(account *model.LedgerAccount) (*model.LedgerAccount, error)
+	CreateEntry(entry *model.JournalEntry, nonNegativeAccountIDs []string) error
+	ListAccountBalances(walletID, assetID string) ([]model.LedgerAccountBalance, error)
+	ListEntries(walletID string, limit int) ([]model.JournalEntry, error)
+}
+
+// Ledger keeps double-entry books mirroring the balances held in Fireblocks.
+// Every wallet asset has a main sub-account plus any number of named sub-accounts sharing the same vault,
+// the outside world is represented by one external account per asset and network fees by one fee account per asset.
+type Ledger struct {
+	repo Repository
+}
+
+func New(repo Repository) *Ledger {
+	return &Ledger{
+		repo: repo,
+	}
+}
+
+type WalletBalance struct {
+	AssetID     string
+	Total       string
+	SubAccounts []SubAccountBalance
+}
+
+type SubAccountBalance struct {
+	Name    string
+	Balance string
+}
+
+type posting struct {
+	account *model.LedgerAccount
+	amount  *big.Rat
+}
+
+// PostDeposit books a credited deposit into the wallet's main sub-account
+func (l *Ledger) PostDeposit(deposit model.Deposit) error {
+	amount, err := ParseAmount(deposit.Amount)
+	if err != nil {
+		return err
+	}
+
+	walletAccount, err := l.walletAccount(deposit.WalletID, deposit.AssetID, MainSubAccount)
+	if err != nil {
+		return err
+	}
+	externalAccount, err := l.systemAccount("external", model.LedgerAccountTypeEquity, deposit.AssetID)
+	if err != nil {
+		return err
+	}
+
+	entry := &model.JournalEntry{
+		Kind:        model.JournalEntryKindDeposit,
+		Reference:   deposit.FireblocksTxID,
+		Description: fmt.Sprintf("Deposit of %s %s", deposit.Amount, deposit.AssetID),
+	}
+
+	return l.postEvent(entry, []posting{
+		{account: walletAccount, amount: amount},
+		{account: externalAccount, amount: new(big.Rat).Neg(amount)},
+	})
+}
+
+// PostTransfer books a completed outgoing transfer and, separately, the network fee it paid
+func (l *Ledger) PostTransfer(transfer model.Transfer) error {
+	externalAccount, err := l.systemAccount("external", model.LedgerAccountTypeEquity, transfer.AssetID)
+	if err != nil {
+		return err
+	}
+	return l.postTransfer(transfer, externalAccount)
+}
+
+// PostInternalTransfer books a completed transfer into another wallet, such as a sweep into a treasury wallet, like
+// PostTransfer but into the main sub-account of the destination wallet. The destination does not book it as a deposit.
+func (l *Ledger) PostInternalTransfer(transfer model.Transfer, destinationWalletID string) error {
+	destinationAccount, err := l.walletAccount(destinationWalletID, transfer.AssetID, MainSubAccount)
+	if err != nil {
+		return err
+	}
+	return l.postTransfer(transfer, destinationAccount)
+}
+
+func (l *Ledger) postTransfer(transfer model.Transfer, destinationAccount *model.LedgerAccount) error {
+	amount, err := ParseAmount(transfer.Amount)
+	if err != nil {
+		return err
+	}
+
+	walletAccount, err := l.walletAccount(transfer.WalletID, transfer.AssetID, MainSubAccount)
+	if err != nil {
+		return err
+	}
+
+	entry := &model.JournalEntry{
+		Kind:        model.JournalEntryKindTransfer,
+		Reference:   transfer.FireblocksTxID,
+		Description: fmt.Sprintf("Transfer of %s %s to %s", transfer.Amount, transfer.AssetID, transfer.DestinationAddress),
+	}
+	err = l.postEvent(entry, []posting{
+		{account: destinationAccount, amount: amount},
+		{account: walletAccount, amount: new(big.Rat).Neg(amount)},
+	})
+	if err != nil {
+		return err
+	}
+
+	if transfer.NetworkFee == "" {
+		return nil
+	}
+
+	fee, err := ParseAmount(transfer.NetworkFee)
+	if err != nil {
+		return err
+	}
+	if fee.Sign() == 0 {
+		return nil
+	}
+
+	// fees are paid in the chain's base asset, e.g. ETH for ERC-20 transfers
+	feeAssetID := transfer.FeeCurrency
+	if feeAssetID == "" {
+		feeAssetID = transfer.AssetID
+	}
+
+	feeWalletAccount, err := l.walletAccount(transfer.WalletID, feeAssetID, MainSubAccount)
+	if err != nil {
+		return err
+	}
+	feeAccount, err := l.systemAccount("fees", model.LedgerAccountTypeExpense, feeAssetID)
+	if err != nil {
+		return err
+	}
+
+	feeEntry := &model.JournalEntry{
+		Kind:        model.JournalEntryKindFee,
+		Reference:   transfer.FireblocksTxID,
+		Description: fmt.Sprintf("Network fee of %s %s", transfer.NetworkFee, feeAssetID),
+	}
+	return l.postEvent(feeEntry, []posting{
+		{account: feeAccount, amount: fee},
+		{account: feeWalletAccount, amount: new(big.Rat).Neg(fee)},
+	})
+}
+
+// Allocate moves funds between two sub-accounts of the same wallet asset, the source may not go negative
+func (l *Ledger) Allocate(walletID, assetID, from, to, amount, description string) (*model.JournalEntry, error) {
+	if !subAccountPattern.MatchString(from) || !subAccountPattern.MatchString(to) {
+		return nil, fmt.Errorf("%w: names must match %s", ErrInvalidSubAccount, subAccountPattern)
+	}
+	if from == to {
+		return nil, fmt.Errorf("%w: source and destination are the same", ErrInvalidSubAccount)
+	}
+
+	value, err := ParseAmount(amount)
+	if err != nil {
+		return nil, err
+	}
+	if value.Sign() == 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidAmount)
+	}
+
+	fromAccount, err := l.walletAccount(walletID, assetID, from)
+	if err != nil {
+		return nil, err
+	}
+	toAccount, err := l.walletAccount(walletID, assetID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if description == "" {
+		description = fmt.Sprintf("Allocation of %s %s from %s to %s", amount, assetID, from, to)
+	}
+	entry := &model.JournalEntry{
+		Kind:        model.JournalEntryKindAllocation,
+		Reference:   uuid.New().String(),
+		Description: description,
+	}
+
+	err = l.post(entry, []posting{
+		{account: toAccount, amount: value},
+		{account: fromAccount, amount: new(big.Rat).Neg(value)},
+	}, []string{fromAccount.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// WalletBalance returns the ledger balance of a wallet asset, in total and per sub-account
+func (l *Ledger) WalletBalance(walletID, assetID string) (*WalletBalance, error) {
+	balances, err := l.repo.ListAccountBalances(walletID, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	total := new(big.Rat)
+	result := &WalletBalance{
+		AssetID:     assetID,
+		SubAccounts: make([]SubAccountBalance, 0, len(balances)),
+	}
+	for _, balance := range balances {
+		value, ok := new(big.Rat).SetString(balance.Balance)
+		if !ok {
+			return nil, fmt.Errorf("invalid balance %q for account %s", balance.Balance, balance.Code)
+		}
+		total.Add(total, value)
+		result.SubAccounts = append(result.SubAccounts, SubAccountBalance{
+			Name:    balance.SubAccount,
+			Balance: FormatAmount(value),
+		})
+	}
+	result.Total = FormatAmount(total)
+
+	sort.Slice(result.SubAccounts, func(i, j int) bool {
+		// keep the main sub-account first, the others alphabetically
+		if result.SubAccounts[i].Name == MainSubAccount || result.SubAccounts[j].Name == MainSubAccount {
+			return result.SubAccounts[i].Name == MainSubAccount
+		}
+		return result.SubAccounts[i].Name < result.SubAccounts[j].Name
+	})
+
+	return result, nil
+}
+
+func (l *Ledger) ListEntries(walletID string, limit int) ([]model.JournalEntry, error) {
+	return l.repo.ListEntries(walletID, limit)
+}
+
+// postEvent posts an entry derived from an external event, replays of the same event are ignored
+func (l *Ledger) postEvent(entry *model.JournalEntry, postings []posting) error {
+	err := l.post(entry, postings, nil)
+	if errors.Is(err, ErrDuplicateEntry) {
+		return nil
+	}
+	return err
+}
+
+func (l *Ledger) post(entry *model.JournalEntry, postings []posting, nonNegativeAccountIDs []string) error {
+	sums := map[string]*big.Rat{}
+	for _, p := range postings {
+		sum, ok := sums[p.account.AssetID]
+		if !ok {
+			sum = new(big.Rat)
+			sums[p.account.AssetID] = sum
+		}
+		sum.Add(sum, p.amount)
+
+		entry.Postings = append(entry.Postings, model.Posting{
+			AccountID: p.account.ID,
+			AssetID:   p.account.AssetID,
+			Amount:    FormatAmount(p.amount),
+		})
+	}
+	for assetID, sum := range sums {
+		if sum.Sign() != 0 {
+			return fmt.Errorf("%w: %s postings sum to %s", ErrUnbalancedEntry, assetID, FormatAmount(sum))
+		}
+	}
+
+	if err := l.repo.CreateEntry(entry, nonNegativeAccountIDs); err != nil {
+		return fmt.Errorf("failed to create journal entry: %w", err)
+	}
+
+	for i := range entry.Postings {
+		entry.Postings[i].Account = postings[i].account
+	}
+	return nil
+}
+
+func (l *Ledger) walletAccount(walletID, assetID, subAccount string) (*model.LedgerAccount, error) {
+	return l.repo.GetOrCreateAccount(&model.LedgerAccount{
+		Code:       fmt.Sprintf("wallet:%s:%s:%s", walletID, assetID, subAccount),
+		Type:       model.LedgerAccountTypeAsset,
+		AssetID:    assetID,
+		WalletID:   &walletID,
+		SubAccount: subAccount,
+	})
+}
+
+func (l *Ledger) systemAccount(name string, accountType model.LedgerAccountType, assetID string) (*model.LedgerAccount, error) {
+	return l.repo.GetOrCreateAccount(&model.LedgerAccount{
+		Code:    fmt.Sprintf("%s:%s", name, assetID),
+		Type:    accountType,
+		AssetID: assetID,
+	})
+}
@@ -0,0 +1,238 @@
+package ledger
+
+import (
+	"firego-wallet-service/internal/model"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+// MockRepository keeps the books in memory and mirrors the constraints enforced by Postgres
+type MockRepository struct {
+	Accounts map[string]*model.LedgerAccount
+	Entries  []model.JournalEntry
+}
+
+func NewMockRepository() *MockRepository {
+	return &MockRepository{Accounts: map[string]*model.LedgerAccount{}}
+}
+
+func (m *MockRepository) GetOrCreateAccount(account *model.LedgerAccount) (*model.LedgerAccount, error) {
+	if existing, ok := m.Accounts[account.Code]; ok {
+		return existing, nil
+	}
+	account.ID = fmt.Sprintf("account-%d", len(m.Accounts)+1)
+	m.Accounts[account.Code] = account
+	return account, nil
+}
+
+func (m *MockRepository) CreateEntry(entry *model.JournalEntry, nonNegativeAccountIDs []string) error {
+	for _, existing := range m.Entries {
+		if existing.Kind == entry.Kind && existing.Reference == entry.Reference {
+			return ErrDuplicateEntry
+		}
+	}
+	m.Entries = append(m.Entries, *entry)
+	for _, accountID := range nonNegativeAccountIDs {
+		if m.balance(accountID).Sign() < 0 {
+			m.Entries = m.Entries[:len(m.Entries)-1]
+			return ErrInsufficientFunds
+		}
+	}
+	return nil
+}
+
+func (m *MockRepository) ListAccountBalances(walletID, assetID string) ([]model.LedgerAccountBalance, error) {
+	var balances []model.LedgerAccountBalance
+	for _, account := range m.Accounts {
+		if account.WalletID != nil && *account.WalletID == walletID && account.AssetID == assetID {
+			balances = append(balances, model.LedgerAccountBalance{
+				LedgerAccount: *account,
+				Balance:       m.balance(account.ID).FloatString(18),
+			})
+		}
+	}
+	return balances, nil
+}
+
+func (m *MockRepository) ListEntries(_ string, _ int) ([]model.JournalEntry, error) {
+	return m.Entries, nil
+}
+
+func (m *MockRepository) balance(accountID string) *big.Rat {
+	sum := new(big.Rat)
+	for _, entry := range m.Entries {
+		for _, posting := range entry.Postings {
+			if posting.AccountID == accountID {
+				amount, _ := new(big.Rat).SetString(posting.Amount)
+				sum.Add(sum, amount)
+			}
+		}
+	}
+	return sum
+}
+
+func (m *MockRepository) balanceOf(code string) string {
+	return FormatAmount(m.balance(m.Accounts[code].ID))
+}
+
+func TestPostDeposit(t *testing.T) {
+	repo := NewMockRepository()
+	ledger := New(repo)
+
+	deposit := model.Deposit{WalletID: "wallet-1", FireblocksTxID: "tx-1", AssetID: "BTC_TEST", Amount: "0.0001"}
+	assert.NoError(t, ledger.PostDeposit(deposit))
+
+	assert.Len(t, repo.Entries, 1)
+	assert.Equal(t, model.JournalEntryKindDeposit, repo.Entries[0].Kind)
+	assert.Equal(t, "tx-1", repo.Entries[0].Reference)
+	assert.Equal(t, "0.0001", repo.balanceOf("wallet:wallet-1:BTC_TEST:main"))
+	assert.Equal(t, "-0.0001", repo.balanceOf("external:BTC_TEST"))
+
+	// a replayed event is not booked twice
+	assert.NoError(t, ledger.PostDeposit(deposit))
+	assert.Len(t, repo.Entries, 1)
+
+	assert.ErrorIs(t, ledger.PostDeposit(model.Deposit{WalletID: "wallet-1", FireblocksTxID: "tx-2", AssetID: "BTC_TEST", Amount: "1e5"}), ErrInvalidAmount)
+}
+
+func TestPostTransfer(t *testing.T) {
+	tests := []struct {
+		name     string
+		deposit  *model.Deposit
+		transfer model.Transfer
+		assert   func(t *testing.T, repo *MockRepository)
+	}{
+		{
+			name:    "with_fee_in_same_asset",
+			deposit: &model.Deposit{WalletID: "wallet-1", FireblocksTxID: "deposit-tx", AssetID: "BTC_TEST", Amount: "0.001"},
+			transfer: model.Transfer{
+				WalletID: "wallet-1", FireblocksTxID: "tx-1", AssetID: "BTC_TEST", Amount: "0.0004", NetworkFee: "0.00001",
+			},
+			assert: func(t *testing.T, repo *MockRepository) {
+				assert.Len(t, repo.Entries, 3)
+				assert.Equal(t, model.JournalEntryKindTransfer, repo.Entries[1].Kind)
+				assert.Equal(t, model.JournalEntryKindFee, repo.Entries[2].Kind)
+				assert.Equal(t, "tx-1", repo.Entries[2].Reference)
+				assert.Equal(t, "0.00059", repo.balanceOf("wallet:wallet-1:BTC_TEST:main"))
+				assert.Equal(t, "-0.0006", repo.balanceOf("external:BTC_TEST"))
+				assert.Equal(t, "0.00001", repo.balanceOf("fees:BTC_TEST"))
+			},
+		},
+		{
+			name: "with_fee_in_base_asset",
+			transfer: model.Transfer{
+				WalletID: "wallet-1", FireblocksTxID: "tx-1", AssetID: "USDC_TEST", Amount: "10", NetworkFee: "0.002", FeeCurrency: "ETH_TEST5",
+			},
+			assert: func(t *testing.T, repo *MockRepository) {
+				assert.Equal(t, "-10", repo.balanceOf("wallet:wallet-1:USDC_TEST:main"))
+				assert.Equal(t, "-0.002", repo.balanceOf("wallet:wallet-1:ETH_TEST5:main"))
+				assert.Equal(t, "0.002", repo.balanceOf("fees:ETH_TEST5"))
+			},
+		},
+		{
+			name: "without_fee",
+			transfer: model.Transfer{
+				WalletID: "wallet-1", FireblocksTxID: "tx-1", AssetID: "BTC_TEST", Amount: "0.0004", NetworkFee: "0",
+			},
+			assert: func(t *testing.T, repo *MockRepository) {
+				assert.Len(t, repo.Entries, 1)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMockRepository()
+			ledger := New(repo)
+
+			if tt.deposit != nil {
+				assert.NoError(t, ledger.PostDeposit(*tt.deposit))
+			}
+
+			assert.NoError(t, ledger.PostTransfer(tt.transfer))
+			// replays of a completed transfer are ignored
+			assert.NoError(t, ledger.PostTransfer(tt.transfer))
+
+			tt.assert(t, repo)
+		})
+	}
+}
+
+func TestPostInternalTransfer(t *testing.T) {
+	repo := NewMockRepository()
+	ledger := New(repo)
+	assert.NoError(t, ledger.PostDeposit(model.Deposit{WalletID: "wallet-1", FireblocksTxID: "deposit-tx", AssetID: "ETH_TEST5", Amount: "1"}))
+	transfer := model.Transfer{
+		WalletID: "wallet-1", FireblocksTxID: "tx-1", AssetID: "ETH_TEST5", Amount: "0.9", NetworkFee: "0.01", DestinationAddress: "vault:2",
+	}
+
+	assert.NoError(t, ledger.PostInternalTransfer(transfer, "treasury"))
+	assert.NoError(t, ledger.PostInternalTransfer(transfer, "treasury"))
+
+	// the funds stay in the books, only the fee leaves them
+	assert.Len(t, repo.Entries, 3)
+	assert.Equal(t, "0.09", repo.balanceOf("wallet:wallet-1:ETH_TEST5:main"))
+	assert.Equal(t, "0.9", repo.balanceOf("wallet:treasury:ETH_TEST5:main"))
+	assert.Equal(t, "-1", repo.balanceOf("external:ETH_TEST5"))
+	assert.Equal(t, "0.01", repo.balanceOf("fees:ETH_TEST5"))
+}
+
+func TestAllocateAndWalletBalance(t *testing.T) {
+	repo := NewMockRepository()
+	ledger := New(repo)
+
+	assert.NoError(t, ledger.PostDeposit(model.Deposit{WalletID: "wallet-1", FireblocksTxID: "tx-1", AssetID: "BTC_TEST", Amount: "1"}))
+
+	entry, err := ledger.Allocate("wallet-1", "BTC_TEST", MainSubAccount, "payroll", "0.4", "")
+	assert.NoError(t, err)
+	assert.Equal(t, model.JournalEntryKindAllocation, entry.Kind)
+	assert.Len(t, entry.Postings, 2)
+	assert.Equal(t, "wallet:wallet-1:BTC_TEST:payroll", entry.Postings[0].Account.Code)
+
+	_, err = ledger.Allocate("wallet-1", "BTC_TEST", "payroll", "treasury", "0.1", "Quarterly reserve")
+	assert.NoError(t, err)
+
+	balance, err := ledger.WalletBalance("wallet-1", "BTC_TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", balance.Total)
+	assert.Equal(t, []SubAccountBalance{
+		{Name: MainSubAccount, Balance: "0.6"},
+		{Name: "payroll", Balance: "0.3"},
+		{Name: "treasury", Balance: "0.1"},
+	}, balance.SubAccounts)
+
+	_, err = ledger.Allocate("wallet-1", "BTC_TEST", "treasury", MainSubAccount, "0.2", "")
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	_, err = ledger.Allocate("wallet-1", "BTC_TEST", "payroll", "payroll", "0.1", "")
+	assert.ErrorIs(t, err, ErrInvalidSubAccount)
+
+	_, err = ledger.Allocate("wallet-1", "BTC_TEST", MainSubAccount, "Not Valid", "0.1", "")
+	assert.ErrorIs(t, err, ErrInvalidSubAccount)
+
+	_, err = ledger.Allocate("wallet-1", "BTC_TEST", MainSubAccount, "payroll", "0", "")
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+	balance, err = ledger.WalletBalance("wallet-1", "ETH_TEST5")
+	assert.NoError(t, err)
+	assert.Equal(t, "0", balance.Total)
+	assert.Empty(t, balance.SubAccounts)
+}
+
+func TestParseAndFormatAmount(t *testing.T) {
+	for _, valid := range []string{"0", "1", "0.00012", "12.500000000000000000"} {
+		_, err := ParseAmount(valid)
+		assert.NoError(t, err, valid)
+	}
+	for _, invalid := range []string{"", "-1", "1e5", "1/3", ".5", "0.0000000000000000001", "abc"} {
+		_, err := ParseAmount(invalid)
+		assert.ErrorIs(t, err, ErrInvalidAmount, invalid)
+	}
+
+	assert.Equal(t, "0.0001", NormalizeAmount("0.000100000000000000"))
+	assert.Equal(t, "-12.5", NormalizeAmount("-12.500000000000000000"))
+	assert.Equal(t, "0", NormalizeAmount("0.000000000000000000"))
+	assert.Equal(t, "100", NormalizeAmount("100"))
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+// redactedKeys are attribute keys whose values are never logged, compared case-insensitively
+// with underscores and dashes removed
+var redactedKeys = map[string]bool{
+	"address":            true,
+	"destinationaddress": true,
+	"sourceaddress":      true,
+	"apikey":             true,
+	"xapikey":            true,
+	"authorization":      true,
+	"token":              true,
+	"jwt":                true,
+	"secret":             true,
+	"password":           true,
+	"privatekey":         true,
+}
+
+// jwtPattern matches JWTs embedded in any logged string, e.g. in an error message echoing a request
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+type loggerKey struct{}
+
+// ParseLevel parses one of debug, info, warn or error
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", level)
+	}
+	return l, nil
+}
+
+// New returns a JSON logger that redacts secrets and destination addresses
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}))
+}
+
+// WithLogger returns a context carrying the logger, see FromContext
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the request logger stored in the context, or the default logger outside a request
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+func redact(_ []string, attr slog.Attr) slog.Attr {
+	key := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(attr.Key))
+	if redactedKeys[key] {
+		return slog.String(attr.Key, redactedValue)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, scrub(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, scrub(err.Error()))
+		}
+	}
+	return attr
+}
+
+func scrub(value string) string {
+	return jwtPattern.ReplaceAllString(value, redactedValue)
+}
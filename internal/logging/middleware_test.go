@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		status    int
+		level     string
+	}{
+		{name: "client request ID", requestID: "req-123", status: http.StatusOK, level: "INFO"},
+		{name: "generated request ID", status: http.StatusOK, level: "INFO"},
+		{name: "server error", requestID: "req-500", status: http.StatusInternalServerError, level: "ERROR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			defaultLogger := slog.Default()
+			slog.SetDefault(New(&buf, slog.LevelInfo))
+			defer slog.SetDefault(defaultLogger)
+
+			var handlerRequestID string
+			handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerRequestID = RequestID(r.Context())
+				FromContext(r.Context()).Info("Handling")
+				w.WriteHeader(tt.status)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/wallets/123/deposits", nil)
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			requestID := rr.Header().Get(RequestIDHeader)
+			assert.NotEmpty(t, requestID)
+			if tt.requestID != "" {
+				assert.Equal(t, tt.requestID, requestID)
+			}
+			assert.Equal(t, requestID, handlerRequestID)
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			assert.Len(t, lines, 2)
+
+			var handled, completed map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(lines[0]), &handled))
+			assert.NoError(t, json.Unmarshal([]byte(lines[1]), &completed))
+			assert.Equal(t, requestID, handled["request_id"])
+			assert.Equal(t, requestID, completed["request_id"])
+			assert.Equal(t, "/wallets/123/deposits", completed["path"])
+			assert.Equal(t, float64(tt.status), completed["status"])
+			assert.Equal(t, tt.level, completed["level"])
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "firego"
+
+// unmatchedRoute labels requests no route pattern matched, keeping arbitrary paths out of the label values
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route pattern and status code.",
+	}, []string{"route", "code"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+
+	fireblocksRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fireblocks_requests_total",
+		Help:      "Fireblocks API calls, by endpoint and status code. The code is 0 when no response was received.",
+	}, []string{"method", "endpoint", "code"})
+
+	fireblocksRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "fireblocks_request_duration_seconds",
+		Help:      "Fireblocks API call latency, by endpoint.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "endpoint"})
+
+	fireblocksRateLimitWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "fireblocks_rate_limit_wait_seconds",
+		Help:      "Time Fireblocks API calls queued for the client-side rate limiter, by endpoint class.",
+		Buckets:   []float64{.001, .01, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"class"})
+
+	fireblocksRateLimitRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fireblocks_rate_limit_rejected_total",
+		Help:      "Fireblocks API calls not sent as they would have queued longer than the max wait, by endpoint class.",
+	}, []string{"class"})
+
+	fireblocksRateLimitTokens = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "fireblocks_rate_limit_tokens",
+		Help:      "Tokens left in the client-side rate limiter bucket after the last call, by endpoint class. Zero or less means calls queue.",
+	}, []string{"class"})
+
+	transfersInitiated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_initiated_total",
+		Help:      "Transfers accepted by Fireblocks, by asset.",
+	}, []string{"asset"})
+
+	transferredAmount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_initiated_amount_total",
+		Help:      "Sum of the amounts of transfers accepted by Fireblocks, by asset, in asset units.",
+	}, []string{"asset"})
+
+	transfersFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_finished_total",
+		Help:      "Transfers that reached a final Fireblocks status, by asset and status.",
+	}, []string{"asset", "status"})
+
+	balanceCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "balance_cache_requests_total",
+		Help:      "Balance reads, by result: hit, miss or bypass.",
+	}, []string{"result"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database query latency, by operation and table.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+)
+
+// RegisterDBStats exposes the connection pool statistics (open, in use and idle connections, waits and closes)
+// as the go_sql_* metrics labelled with db_name="firego". Only the first pool registered is exposed.
+func RegisterDBStats(db *sql.DB) error {
+	err := prometheus.Register(collectors.NewDBStatsCollector(db, namespace))
+	if errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		return nil
+	}
+	return err
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Instrument records the count and latency of the requests served by mux, labelled with the pattern of the
+// route that matched them. The mux sets the pattern on the request while routing it.
+func Instrument(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		mux.ServeHTTP(recorder, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = unmatchedRoute
+		}
+		httpRequests.WithLabelValues(route, strconv.Itoa(recorder.status)).Inc()
+		httpRequestDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveFireblocksRequest records a Fireblocks API call. The endpoint must be a path template, not the
+// requested path, to keep IDs out of the label values.
+func ObserveFireblocksRequest(method, endpoint string, statusCode int, duration time.Duration) {
+	fireblocksRequests.WithLabelValues(method, endpoint, strconv.Itoa(statusCode)).Inc()
+	fireblocksRequestDuration.WithLabelValues(method, endpoint).Observe(duration.Seconds())
+}
+
+// ObserveFireblocksRateLimit records a Fireblocks API call going through the rate limiter of its endpoint class,
+// admitted or not
+func ObserveFireblocksRateLimit(class string, waited time.Duration, tokens float64, admitted bool) {
+	fireblocksRateLimitTokens.WithLabelValues(class).Set(tokens)
+	if !admitted {
+		fireblocksRateLimitRejected.WithLabelValues(class).Inc()
+		return
+	}
+	fireblocksRateLimitWait.WithLabelValues(class).Observe(waited.Seconds())
+}
+
+// ObserveTransferInitiated records a transfer accepted by Fireblocks. Amounts that are not valid numbers
+// are only counted.
+func ObserveTransferInitiated(assetID, amount string) {
+	transfersInitiated.WithLabelValues(assetID).Inc()
+	if value, err := strconv.ParseFloat(amount, 64); err == nil {
+		transferredAmount.WithLabelValues(assetID).Add(value)
+	}
+}
+
+func ObserveTransferFinished(assetID, status string) {
+	transfersFinished.WithLabelValues(assetID, status).Inc()
+}
+
+func ObserveBalanceCache(result string) {
+	balanceCacheRequests.WithLabelValues(result).Inc()
+}
+
+func ObserveDBQuery(operation, table string, duration time.Duration) {
+	dbQueryDuration.WithLabelValues(operation, table).Observe(duration.Seconds())
+}
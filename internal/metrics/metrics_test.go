@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInstrument(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /wallets/{walletId}/deposits", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.Handle("GET /metrics", Handler())
+	handler := Instrument(mux)
+
+	for _, path := range []string{"/wallets/1/deposits", "/wallets/2/deposits", "/unknown"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(httpRequests.WithLabelValues("GET /wallets/{walletId}/deposits", "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequests.WithLabelValues(unmatchedRoute, "404")))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.True(t, strings.Contains(recorder.Body.String(), `firego_http_requests_total{code="404",route="GET /wallets/{walletId}/deposits"} 2`))
+}
+
+func TestObserveFireblocksRequest(t *testing.T) {
+	ObserveFireblocksRequest(http.MethodGet, "/v1/transactions/{txId}", 503, 20*time.Millisecond)
+	ObserveFireblocksRequest(http.MethodGet, "/v1/transactions/{txId}", 0, time.Second)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(fireblocksRequests.WithLabelValues(http.MethodGet, "/v1/transactions/{txId}", "503")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(fireblocksRequests.WithLabelValues(http.MethodGet, "/v1/transactions/{txId}", "0")))
+}
+
+func TestObserveTransferInitiated(t *testing.T) {
+	ObserveTransferInitiated("ETH_TEST", "0.25")
+	ObserveTransferInitiated("ETH_TEST", "0.5")
+	ObserveTransferInitiated("ETH_TEST", "not-a-number")
+
+	assert.Equal(t, 3.0, testutil.ToFloat64(transfersInitiated.WithLabelValues("ETH_TEST")))
+	assert.Equal(t, 0.75, testutil.ToFloat64(transferredAmount.WithLabelValues("ETH_TEST")))
+}
@@ -0,0 +1,88 @@
+package migrate
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := load(files)
+
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, len(migrations), 3)
+	assert.Equal(t, "initial_schema", migrations[0].Name)
+	assert.Equal(t, "ledger_guards", migrations[1].Name)
+	assert.Equal(t, "audit_guards", migrations[2].Name)
+	for _, migration := range migrations {
+		assert.NotEmpty(t, strings.TrimSpace(migration.Up))
+		assert.NotEmpty(t, strings.TrimSpace(migration.Down))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       fstest.MapFS
+		expectedErr string
+	}{
+		{
+			name: "sorted_by_version",
+			files: fstest.MapFS{
+				"sql/0002_second.up.sql":   {Data: []byte("SELECT 2")},
+				"sql/0002_second.down.sql": {Data: []byte("SELECT -2")},
+				"sql/0001_first.up.sql":    {Data: []byte("SELECT 1")},
+				"sql/0001_first.down.sql":  {Data: []byte("SELECT -1")},
+			},
+		},
+		{
+			name: "missing_down",
+			files: fstest.MapFS{
+				"sql/0001_first.up.sql": {Data: []byte("SELECT 1")},
+			},
+			expectedErr: "migration 1 first needs both an up and a down file",
+		},
+		{
+			name: "gap",
+			files: fstest.MapFS{
+				"sql/0001_first.up.sql":   {Data: []byte("SELECT 1")},
+				"sql/0001_first.down.sql": {Data: []byte("SELECT -1")},
+				"sql/0003_third.up.sql":   {Data: []byte("SELECT 3")},
+				"sql/0003_third.down.sql": {Data: []byte("SELECT -3")},
+			},
+			expectedErr: "migration 2 is missing",
+		},
+		{
+			name: "conflicting_names",
+			files: fstest.MapFS{
+				"sql/0001_first.up.sql":   {Data: []byte("SELECT 1")},
+				"sql/0001_other.down.sql": {Data: []byte("SELECT -1")},
+			},
+			expectedErr: "migration 1 has two names",
+		},
+		{
+			name: "invalid_name",
+			files: fstest.MapFS{
+				"sql/first.sql": {Data: []byte("SELECT 1")},
+			},
+			expectedErr: "invalid migration file name first.sql",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := load(tt.files)
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []Migration{
+				{Version: 1, Name: "first", Up: "SELECT 1", Down: "SELECT -1"},
+				{Version: 2, Name: "second", Up: "SELECT 2", Down: "SELECT -2"},
+			}, migrations)
+		})
+	}
+}
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS "audit_entries";
+DROP TABLE IF EXISTS "reconciliation_issues";
+DROP TABLE IF EXISTS "reconciliation_reports";
+DROP TABLE IF EXISTS "postings";
+DROP TABLE IF EXISTS "journal_entries";
+DROP TABLE IF EXISTS "ledger_accounts";
+DROP TABLE IF EXISTS "transfers";
+DROP TABLE IF EXISTS "deposits";
+DROP TABLE IF EXISTS "wallets";
@@ -0,0 +1,146 @@
+-- The schema previously created by GORM AutoMigrate, with the same table, index and constraint names,
+-- so that databases created before migrations existed are adopted as they are.
+
+CREATE TABLE IF NOT EXISTS "wallets" (
+	"id" uuid DEFAULT gen_random_uuid(),
+	"name" text NOT NULL,
+	"vault_account_id" text NOT NULL,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_wallets_vault_account_id" ON "wallets" ("vault_account_id");
+
+CREATE TABLE IF NOT EXISTS "deposits" (
+	"id" uuid DEFAULT gen_random_uuid(),
+	"wallet_id" uuid NOT NULL,
+	"fireblocks_tx_id" text NOT NULL,
+	"asset_id" text NOT NULL,
+	"amount" text NOT NULL,
+	"source_address" text NOT NULL DEFAULT '',
+	"destination_address" text NOT NULL DEFAULT '',
+	"tx_hash" text NOT NULL DEFAULT '',
+	"fireblocks_status" text NOT NULL,
+	"confirmations" bigint NOT NULL DEFAULT 0,
+	"required_confirmations" bigint NOT NULL,
+	"status" varchar(16) NOT NULL,
+	"credited_at" timestamptz,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_deposits_wallet_id" ON "deposits" ("wallet_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_deposits_fireblocks_tx_id" ON "deposits" ("fireblocks_tx_id");
+CREATE INDEX IF NOT EXISTS "idx_deposits_status" ON "deposits" ("status");
+
+CREATE TABLE IF NOT EXISTS "transfers" (
+	"id" uuid DEFAULT gen_random_uuid(),
+	"wallet_id" uuid NOT NULL,
+	"fireblocks_tx_id" text NOT NULL,
+	"asset_id" text NOT NULL,
+	"amount" text NOT NULL,
+	"destination_address" text NOT NULL,
+	"note" text NOT NULL DEFAULT '',
+	"status" text NOT NULL,
+	"sub_status" text NOT NULL DEFAULT '',
+	"tx_hash" text NOT NULL DEFAULT '',
+	"network_fee" text NOT NULL DEFAULT '',
+	"fee_currency" text NOT NULL DEFAULT '',
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_transfers_wallet_id" ON "transfers" ("wallet_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_transfers_fireblocks_tx_id" ON "transfers" ("fireblocks_tx_id");
+CREATE INDEX IF NOT EXISTS "idx_transfers_status" ON "transfers" ("status");
+
+CREATE TABLE IF NOT EXISTS "ledger_accounts" (
+	"id" uuid DEFAULT gen_random_uuid(),
+	"code" text NOT NULL,
+	"type" varchar(16) NOT NULL,
+	"asset_id" text NOT NULL,
+	"wallet_id" uuid,
+	"sub_account" text NOT NULL DEFAULT '',
+	"created_at" timestamptz,
+	PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_ledger_accounts_code" ON "ledger_accounts" ("code");
+CREATE INDEX IF NOT EXISTS "idx_ledger_accounts_wallet_id" ON "ledger_accounts" ("wallet_id");
+
+CREATE TABLE IF NOT EXISTS "journal_entries" (
+	"id" uuid DEFAULT gen_random_uuid(),
+	"kind" varchar(16) NOT NULL,
+	"reference" text NOT NULL,
+	"description" text NOT NULL DEFAULT '',
+	"created_at" timestamptz,
+	PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_journal_entries_kind_reference" ON "journal_entries" ("kind", "reference");
+
+CREATE TABLE IF NOT EXISTS "postings" (
+	"id" uuid DEFAULT gen_random_uuid(),
+	"journal_entry_id" uuid NOT NULL,
+	"account_id" uuid NOT NULL,
+	"asset_id" text NOT NULL,
+	"amount" numeric(38,18) NOT NULL,
+	"created_at" timestamptz,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_journal_entries_postings" FOREIGN KEY ("journal_entry_id") REFERENCES "journal_entries"("id"),
+	CONSTRAINT "fk_postings_account" FOREIGN KEY ("account_id") REFERENCES "ledger_accounts"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_postings_journal_entry_id" ON "postings" ("journal_entry_id");
+CREATE INDEX IF NOT EXISTS "idx_postings_account_id" ON "postings" ("account_id");
+
+CREATE TABLE IF NOT EXISTS "reconciliation_reports" (
+	"id" uuid DEFAULT gen_random_uuid(),
+	"status" varchar(16) NOT NULL,
+	"error" text NOT NULL DEFAULT '',
+	"issue_count" bigint NOT NULL DEFAULT 0,
+	"started_at" timestamptz NOT NULL,
+	"finished_at" timestamptz NOT NULL,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_reconciliation_reports_started_at" ON "reconciliation_reports" ("started_at");
+
+CREATE TABLE IF NOT EXISTS "reconciliation_issues" (
+	"id" uuid DEFAULT gen_random_uuid(),
+	"report_id" uuid NOT NULL,
+	"kind" varchar(32) NOT NULL,
+	"wallet_id" text NOT NULL DEFAULT '',
+	"vault_account_id" text NOT NULL DEFAULT '',
+	"asset_id" text NOT NULL DEFAULT '',
+	"reference" text NOT NULL DEFAULT '',
+	"expected" text NOT NULL DEFAULT '',
+	"actual" text NOT NULL DEFAULT '',
+	"details" text NOT NULL DEFAULT '',
+	"created_at" timestamptz,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_reconciliation_reports_issues" FOREIGN KEY ("report_id") REFERENCES "reconciliation_reports"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_reconciliation_issues_report_id" ON "reconciliation_issues" ("report_id");
+
+CREATE TABLE IF NOT EXISTS "audit_entries" (
+	"id" uuid DEFAULT gen_random_uuid(),
+	"sequence" bigint NOT NULL,
+	"actor" text NOT NULL,
+	"action" text NOT NULL,
+	"target" text NOT NULL DEFAULT '',
+	"request_id" text NOT NULL,
+	"source_ip" text NOT NULL DEFAULT '',
+	"payload" text NOT NULL DEFAULT '',
+	"outcome" varchar(16) NOT NULL,
+	"status_code" bigint NOT NULL,
+	"fireblocks_ids" text NOT NULL DEFAULT '',
+	"created_at" timestamptz NOT NULL,
+	"prev_hash" char(64) NOT NULL,
+	"hash" char(64) NOT NULL,
+	PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_audit_entries_sequence" ON "audit_entries" ("sequence");
+CREATE INDEX IF NOT EXISTS "idx_audit_entries_actor" ON "audit_entries" ("actor");
+CREATE INDEX IF NOT EXISTS "idx_audit_entries_action" ON "audit_entries" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_entries_target" ON "audit_entries" ("target");
+CREATE INDEX IF NOT EXISTS "idx_audit_entries_request_id" ON "audit_entries" ("request_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_entries_outcome" ON "audit_entries" ("outcome");
+CREATE INDEX IF NOT EXISTS "idx_audit_entries_created_at" ON "audit_entries" ("created_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_audit_entries_hash" ON "audit_entries" ("hash");
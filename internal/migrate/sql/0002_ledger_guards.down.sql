@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS postings_balanced ON postings;
+DROP TRIGGER IF EXISTS postings_append_only ON postings;
+DROP TRIGGER IF EXISTS journal_entries_append_only ON journal_entries;
+DROP FUNCTION IF EXISTS ledger_check_balanced();
+DROP FUNCTION IF EXISTS ledger_reject_mutation();
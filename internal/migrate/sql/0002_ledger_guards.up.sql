@@ -0,0 +1,35 @@
+-- Makes the books immutable and balanced at the database level, independently of the application code:
+-- journal entries and postings reject updates and deletes, and every entry's postings must sum to zero per asset at commit
+
+CREATE OR REPLACE FUNCTION ledger_reject_mutation() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'ledger table % is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION ledger_check_balanced() RETURNS trigger AS $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM postings
+		WHERE journal_entry_id = NEW.journal_entry_id
+		GROUP BY asset_id
+		HAVING SUM(amount) <> 0
+	) THEN
+		RAISE EXCEPTION 'journal entry % is not balanced', NEW.journal_entry_id;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS journal_entries_append_only ON journal_entries;
+CREATE TRIGGER journal_entries_append_only BEFORE UPDATE OR DELETE ON journal_entries
+	FOR EACH ROW EXECUTE FUNCTION ledger_reject_mutation();
+
+DROP TRIGGER IF EXISTS postings_append_only ON postings;
+CREATE TRIGGER postings_append_only BEFORE UPDATE OR DELETE ON postings
+	FOR EACH ROW EXECUTE FUNCTION ledger_reject_mutation();
+
+DROP TRIGGER IF EXISTS postings_balanced ON postings;
+CREATE CONSTRAINT TRIGGER postings_balanced AFTER INSERT ON postings
+	DEFERRABLE INITIALLY DEFERRED
+	FOR EACH ROW EXECUTE FUNCTION ledger_check_balanced();
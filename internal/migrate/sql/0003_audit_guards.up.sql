@@ -0,0 +1,16 @@
+-- Makes the audit log append-only at the database level. It does not stop someone with
+-- superuser access from disabling the trigger, which is what the hash chain is there to detect.
+
+CREATE OR REPLACE FUNCTION audit_reject_mutation() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_entries_append_only ON audit_entries;
+CREATE TRIGGER audit_entries_append_only BEFORE UPDATE OR DELETE ON audit_entries
+	FOR EACH ROW EXECUTE FUNCTION audit_reject_mutation();
+
+DROP TRIGGER IF EXISTS audit_entries_no_truncate ON audit_entries;
+CREATE TRIGGER audit_entries_no_truncate BEFORE TRUNCATE ON audit_entries
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_reject_mutation();
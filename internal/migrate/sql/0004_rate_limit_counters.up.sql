@@ -0,0 +1,10 @@
+-- Request counters of the inbound rate limiter when it is shared through Postgres, one row per client, request
+-- class and window. Rows are only useful until their window ends, they are not logged.
+
+CREATE UNLOGGED TABLE rate_limit_counters (
+	key text PRIMARY KEY,
+	count bigint NOT NULL,
+	expires_at timestamptz NOT NULL
+);
+
+CREATE INDEX idx_rate_limit_counters_expires_at ON rate_limit_counters (expires_at);
@@ -0,0 +1,13 @@
+-- Responses kept for the requests sent with an Idempotency-Key, replayed when the request is retried. The status
+-- code is NULL while the first request is being handled.
+
+CREATE TABLE idempotency_keys (
+	key text PRIMARY KEY,
+	fingerprint text NOT NULL,
+	status_code integer,
+	content_type text NOT NULL DEFAULT '',
+	body bytea,
+	expires_at timestamptz NOT NULL
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,12 @@
+-- Keys the clients authenticate with when features.apiKeys is enabled. Only the SHA-256 of a key is stored.
+
+CREATE TABLE api_keys (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	name text NOT NULL,
+	prefix text NOT NULL,
+	hash text NOT NULL,
+	created_at timestamptz,
+	revoked_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_api_keys_hash ON api_keys (hash);
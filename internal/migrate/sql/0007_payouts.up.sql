@@ -0,0 +1,33 @@
+-- Batches of transfers out of a wallet, submitted line by line in the background
+
+CREATE TABLE payouts (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	wallet_id uuid NOT NULL,
+	vault_account_id text NOT NULL,
+	asset_id text NOT NULL,
+	total text NOT NULL,
+	status varchar(16) NOT NULL,
+	created_at timestamptz,
+	updated_at timestamptz,
+	completed_at timestamptz
+);
+
+CREATE INDEX idx_payouts_wallet_id ON payouts (wallet_id);
+CREATE INDEX idx_payouts_status ON payouts (status);
+
+CREATE TABLE payout_lines (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	payout_id uuid NOT NULL REFERENCES payouts (id),
+	line bigint NOT NULL,
+	destination_address text NOT NULL,
+	amount text NOT NULL,
+	note text NOT NULL DEFAULT '',
+	status varchar(16) NOT NULL,
+	fireblocks_tx_id text NOT NULL DEFAULT '',
+	error text NOT NULL DEFAULT '',
+	attempts bigint NOT NULL DEFAULT 0,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_payout_lines_payout_id_line ON payout_lines (payout_id, line);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS transfer_schedule_runs;
+DROP TABLE IF EXISTS transfer_schedules;
@@ -0,0 +1,31 @@
+-- Transfers initiated at a future time or on a cron expression, and the outcome of each of their runs
+
+CREATE TABLE transfer_schedules (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	wallet_id uuid NOT NULL,
+	asset_id text NOT NULL,
+	amount text NOT NULL,
+	destination_address text NOT NULL,
+	note text NOT NULL DEFAULT '',
+	cron text NOT NULL DEFAULT '',
+	status varchar(16) NOT NULL,
+	next_run_at timestamptz,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz
+);
+
+CREATE INDEX idx_transfer_schedules_wallet_id ON transfer_schedules (wallet_id);
+CREATE INDEX idx_transfer_schedules_next_run_at ON transfer_schedules (next_run_at);
+
+CREATE TABLE transfer_schedule_runs (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	schedule_id uuid NOT NULL REFERENCES transfer_schedules (id),
+	scheduled_at timestamptz NOT NULL,
+	status varchar(16) NOT NULL,
+	fireblocks_tx_id text NOT NULL DEFAULT '',
+	error text NOT NULL DEFAULT '',
+	created_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_transfer_schedule_runs_schedule_id_scheduled_at ON transfer_schedule_runs (schedule_id, scheduled_at);
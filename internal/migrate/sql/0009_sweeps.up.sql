@@ -0,0 +1,19 @@
+-- Transfers of the deposit wallet balances into the treasury wallets, and their outcome
+
+CREATE TABLE sweeps (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	wallet_id uuid NOT NULL,
+	asset_id text NOT NULL,
+	treasury_wallet_id uuid NOT NULL,
+	available text NOT NULL,
+	amount text NOT NULL,
+	status varchar(16) NOT NULL,
+	fireblocks_tx_id text NOT NULL DEFAULT '',
+	error text NOT NULL DEFAULT '',
+	attempts bigint NOT NULL DEFAULT 0,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+CREATE INDEX idx_sweeps_wallet_id ON sweeps (wallet_id);
+CREATE INDEX idx_sweeps_status ON sweeps (status);
@@ -0,0 +1,8 @@
+-- Positions of the Fireblocks pollers, so that the leader taking over, or restarting, resumes where the last one
+-- stopped instead of scanning the lookback window again
+
+CREATE TABLE poller_cursors (
+	name text PRIMARY KEY,
+	value bigint NOT NULL,
+	updated_at timestamptz
+);
@@ -0,0 +1 @@
+ALTER TABLE transfer_schedules DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,4 @@
+-- Runs failing transiently are retried with the same idempotency key a bounded number of times before being recorded
+-- as failed
+
+ALTER TABLE transfer_schedules ADD COLUMN attempts integer NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Only the keys issued for them are allowed on the admin endpoints
+
+ALTER TABLE api_keys ADD COLUMN admin boolean NOT NULL DEFAULT false;
@@ -0,0 +1,14 @@
+-- Amounts of the wallet assets set aside for the transactions being submitted to Fireblocks, which the available
+-- balance does not account for until they are created. A hold that is not released expires.
+
+CREATE TABLE balance_holds (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	wallet_id uuid NOT NULL,
+	asset_id text NOT NULL,
+	amount text NOT NULL,
+	expires_at timestamptz NOT NULL,
+	created_at timestamptz
+);
+
+CREATE INDEX idx_balance_holds_wallet_id_asset_id ON balance_holds (wallet_id, asset_id);
+CREATE INDEX idx_balance_holds_expires_at ON balance_holds (expires_at);
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// APIKey is a key clients authenticate with. Only the hash of the key is stored, the prefix identifies it to
+// operators. Only Admin keys are allowed on the admin endpoints.
+type APIKey struct {
+	ID        string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name      string `gorm:"not null"`
+	Prefix    string `gorm:"not null"`
+	Hash      string `gorm:"uniqueIndex;not null"`
+	Admin     bool   `gorm:"not null;default:false"`
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+type AuditOutcome string
+
+const (
+	AuditOutcomeSuccess AuditOutcome = "SUCCESS"
+	AuditOutcomeFailure AuditOutcome = "FAILURE"
+)
+
+// AuditEntry records one mutating API action. Entries form a hash chain: Hash covers the entry's fields and
+// the Hash of the entry with the previous Sequence, so altering or removing an entry breaks every later link.
+type AuditEntry struct {
+	ID            string       `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Sequence      int64        `gorm:"uniqueIndex;not null"`
+	Actor         string       `gorm:"index;not null"`
+	Action        string       `gorm:"index;not null"`
+	Target        string       `gorm:"index;not null;default:''"`
+	RequestID     string       `gorm:"index;not null"`
+	SourceIP      string       `gorm:"not null;default:''"`
+	Payload       string       `gorm:"type:text;not null;default:''"`
+	Outcome       AuditOutcome `gorm:"type:varchar(16);index;not null"`
+	StatusCode    int          `gorm:"not null"`
+	FireblocksIDs string       `gorm:"not null;default:''"`
+	CreatedAt     time.Time    `gorm:"index;not null"`
+	PrevHash      string       `gorm:"type:char(64);not null"`
+	Hash          string       `gorm:"type:char(64);uniqueIndex;not null"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// BalanceHold is an amount of a wallet asset set aside for a transaction being submitted to Fireblocks, counted as
+// reserved until it is released or expires
+type BalanceHold struct {
+	ID        string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	WalletID  string    `gorm:"type:uuid;not null"`
+	AssetID   string    `gorm:"not null"`
+	Amount    string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+}
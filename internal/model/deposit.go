@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+type DepositStatus string
+
+const (
+	DepositStatusPending  DepositStatus = "PENDING"
+	DepositStatusCredited DepositStatus = "CREDITED"
+	DepositStatusFailed   DepositStatus = "FAILED"
+)
+
+type Deposit struct {
+	ID                    string        `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	WalletID              string        `gorm:"type:uuid;index;not null"`
+	FireblocksTxID        string        `gorm:"uniqueIndex;not null"`
+	AssetID               string        `gorm:"not null"`
+	Amount                string        `gorm:"not null"`
+	SourceAddress         string        `gorm:"not null;default:''"`
+	DestinationAddress    string        `gorm:"not null;default:''"`
+	TxHash                string        `gorm:"not null;default:''"`
+	FireblocksStatus      string        `gorm:"not null"`
+	Confirmations         int           `gorm:"not null;default:0"`
+	RequiredConfirmations int           `gorm:"not null"`
+	Status                DepositStatus `gorm:"type:varchar(16);index;not null"`
+	CreditedAt            *time.Time
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+}
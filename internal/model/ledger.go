@@ -0,0 +1,65 @@
+package model
+
+import "time"
+
+type LedgerAccountType string
+
+const (
+	LedgerAccountTypeAsset   LedgerAccountType = "ASSET"
+	LedgerAccountTypeExpense LedgerAccountType = "EXPENSE"
+	LedgerAccountTypeEquity  LedgerAccountType = "EQUITY"
+)
+
+type LedgerAccount struct {
+	ID         string            `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Code       string            `gorm:"uniqueIndex;not null"`
+	Type       LedgerAccountType `gorm:"type:varchar(16);not null"`
+	AssetID    string            `gorm:"not null"`
+	WalletID   *string           `gorm:"type:uuid;index"`
+	SubAccount string            `gorm:"not null;default:''"`
+	CreatedAt  time.Time
+}
+
+type JournalEntryKind string
+
+const (
+	JournalEntryKindDeposit    JournalEntryKind = "DEPOSIT"
+	JournalEntryKindTransfer   JournalEntryKind = "TRANSFER"
+	JournalEntryKindFee        JournalEntryKind = "FEE"
+	JournalEntryKindAllocation JournalEntryKind = "ALLOCATION"
+)
+
+// JournalEntry is immutable once written, its postings must sum to zero per asset.
+// Reference identifies the source event (e.g. the Fireblocks transaction ID) so an event is only booked once per kind.
+type JournalEntry struct {
+	ID          string           `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Kind        JournalEntryKind `gorm:"type:varchar(16);not null;uniqueIndex:idx_journal_entries_kind_reference"`
+	Reference   string           `gorm:"not null;uniqueIndex:idx_journal_entries_kind_reference"`
+	Description string           `gorm:"not null;default:''"`
+	CreatedAt   time.Time
+	Postings    []Posting `gorm:"foreignKey:JournalEntryID"`
+}
+
+// Posting amounts are signed: debits are positive and credits negative
+type Posting struct {
+	ID             string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	JournalEntryID string `gorm:"type:uuid;index;not null"`
+	AccountID      string `gorm:"type:uuid;index;not null"`
+	AssetID        string `gorm:"not null"`
+	Amount         string `gorm:"type:numeric(38,18);not null"`
+	CreatedAt      time.Time
+	Account        *LedgerAccount `gorm:"foreignKey:AccountID"`
+}
+
+// LedgerAccountBalance is a read model of an account with the sum of its postings
+type LedgerAccountBalance struct {
+	LedgerAccount `gorm:"embedded"`
+	Balance       string
+}
+
+// WalletAssetBalance is a read model of a wallet asset's ledger balance summed over its sub-accounts
+type WalletAssetBalance struct {
+	WalletID string
+	AssetID  string
+	Balance  string
+}
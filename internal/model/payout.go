@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+type PayoutStatus string
+
+const (
+	// PayoutStatusPending is a payout some lines of which have not been submitted yet
+	PayoutStatusPending   PayoutStatus = "PENDING"
+	PayoutStatusCompleted PayoutStatus = "COMPLETED"
+	// PayoutStatusPartial is a payout some lines of which were submitted and others failed
+	PayoutStatusPartial PayoutStatus = "PARTIAL"
+	PayoutStatusFailed  PayoutStatus = "FAILED"
+)
+
+type PayoutLineStatus string
+
+const (
+	PayoutLineStatusPending   PayoutLineStatus = "PENDING"
+	PayoutLineStatusSubmitted PayoutLineStatus = "SUBMITTED"
+	PayoutLineStatusFailed    PayoutLineStatus = "FAILED"
+)
+
+// Payout is a batch of transfers of an asset out of a wallet, one per line. Its total was checked against the
+// available balance when it was created.
+type Payout struct {
+	ID             string       `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	WalletID       string       `gorm:"type:uuid;index;not null"`
+	VaultAccountID string       `gorm:"not null"`
+	AssetID        string       `gorm:"not null"`
+	Total          string       `gorm:"not null"`
+	Status         PayoutStatus `gorm:"type:varchar(16);index;not null"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	CompletedAt    *time.Time
+	Lines          []PayoutLine `gorm:"foreignKey:PayoutID"`
+}
+
+// PayoutLine is a transfer of a payout. Line is its 1-based position in the payout, which its Fireblocks idempotency
+// key is derived from.
+type PayoutLine struct {
+	ID                 string           `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PayoutID           string           `gorm:"type:uuid;not null;uniqueIndex:idx_payout_lines_payout_id_line"`
+	Line               int              `gorm:"not null;uniqueIndex:idx_payout_lines_payout_id_line"`
+	DestinationAddress string           `gorm:"not null"`
+	Amount             string           `gorm:"not null"`
+	Note               string           `gorm:"not null;default:''"`
+	Status             PayoutLineStatus `gorm:"type:varchar(16);not null"`
+	FireblocksTxID     string           `gorm:"not null;default:''"`
+	Error              string           `gorm:"not null;default:''"`
+	// Attempts is how many times the line was sent to Fireblocks
+	Attempts  int `gorm:"not null;default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// PollerCursor is the position a poller resumes from, e.g. the creation time in milliseconds of the last transaction
+// it processed
+type PollerCursor struct {
+	Name      string `gorm:"primary_key"`
+	Value     int64  `gorm:"not null"`
+	UpdatedAt time.Time
+}
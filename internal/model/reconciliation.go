@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+type ReconciliationStatus string
+
+const (
+	ReconciliationStatusCompleted ReconciliationStatus = "COMPLETED"
+	ReconciliationStatusFailed    ReconciliationStatus = "FAILED"
+)
+
+type ReconciliationIssueKind string
+
+const (
+	ReconciliationIssueMissingVault    ReconciliationIssueKind = "MISSING_VAULT"
+	ReconciliationIssueOrphanedVault   ReconciliationIssueKind = "ORPHANED_VAULT"
+	ReconciliationIssueStatusDrift     ReconciliationIssueKind = "STATUS_DRIFT"
+	ReconciliationIssueBalanceMismatch ReconciliationIssueKind = "BALANCE_MISMATCH"
+	ReconciliationIssueCheckFailed     ReconciliationIssueKind = "CHECK_FAILED"
+)
+
+type ReconciliationReport struct {
+	ID         string                `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Status     ReconciliationStatus  `gorm:"type:varchar(16);not null"`
+	Error      string                `gorm:"not null;default:''"`
+	IssueCount int                   `gorm:"not null;default:0"`
+	StartedAt  time.Time             `gorm:"index;not null"`
+	FinishedAt time.Time             `gorm:"not null"`
+	Issues     []ReconciliationIssue `gorm:"foreignKey:ReportID"`
+}
+
+type ReconciliationIssue struct {
+	ID             string                  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ReportID       string                  `gorm:"type:uuid;index;not null"`
+	Kind           ReconciliationIssueKind `gorm:"type:varchar(32);not null"`
+	WalletID       string                  `gorm:"not null;default:''"`
+	VaultAccountID string                  `gorm:"not null;default:''"`
+	AssetID        string                  `gorm:"not null;default:''"`
+	Reference      string                  `gorm:"not null;default:''"`
+	Expected       string                  `gorm:"not null;default:''"`
+	Actual         string                  `gorm:"not null;default:''"`
+	Details        string                  `gorm:"not null;default:''"`
+	CreatedAt      time.Time
+}
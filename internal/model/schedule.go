@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+type TransferScheduleStatus string
+
+const (
+	TransferScheduleStatusActive TransferScheduleStatus = "ACTIVE"
+	TransferScheduleStatusPaused TransferScheduleStatus = "PAUSED"
+	// TransferScheduleStatusCompleted is a one-off schedule that has run
+	TransferScheduleStatusCompleted TransferScheduleStatus = "COMPLETED"
+)
+
+type TransferScheduleRunStatus string
+
+const (
+	TransferScheduleRunStatusSubmitted TransferScheduleRunStatus = "SUBMITTED"
+	// TransferScheduleRunStatusSkipped is a run whose wallet did not have the balance for the transfer
+	TransferScheduleRunStatusSkipped TransferScheduleRunStatus = "SKIPPED"
+	TransferScheduleRunStatusFailed  TransferScheduleRunStatus = "FAILED"
+)
+
+// TransferSchedule is a transfer out of a wallet initiated at a future time, once, or recurring on a cron expression.
+// Deleted schedules are kept with their runs.
+type TransferSchedule struct {
+	ID                 string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	WalletID           string `gorm:"type:uuid;index;not null"`
+	AssetID            string `gorm:"not null"`
+	Amount             string `gorm:"not null"`
+	DestinationAddress string `gorm:"not null"`
+	Note               string `gorm:"not null;default:''"`
+	// Cron is the expression of a recurring schedule, evaluated in UTC, empty for a one-off
+	Cron   string                 `gorm:"not null;default:''"`
+	Status TransferScheduleStatus `gorm:"type:varchar(16);not null"`
+	// NextRunAt is when the transfer is due next, nil once a one-off schedule has run
+	NextRunAt *time.Time `gorm:"index"`
+	// Attempts counts the runs due at NextRunAt that failed transiently, reset once a run is recorded
+	Attempts  int `gorm:"not null;default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+}
+
+// TransferScheduleRun is the outcome of a run of a schedule. ScheduledAt is the time the run was due, which its
+// Fireblocks idempotency key is derived from.
+type TransferScheduleRun struct {
+	ID             string                    `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ScheduleID     string                    `gorm:"type:uuid;not null;uniqueIndex:idx_transfer_schedule_runs_schedule_id_scheduled_at"`
+	ScheduledAt    time.Time                 `gorm:"not null;uniqueIndex:idx_transfer_schedule_runs_schedule_id_scheduled_at"`
+	Status         TransferScheduleRunStatus `gorm:"type:varchar(16);not null"`
+	FireblocksTxID string                    `gorm:"not null;default:''"`
+	Error          string                    `gorm:"not null;default:''"`
+	CreatedAt      time.Time
+}
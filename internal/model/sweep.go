@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+type SweepStatus string
+
+const (
+	// SweepStatusPending is a sweep whose outcome is not known yet, it is sent to Fireblocks again
+	SweepStatusPending   SweepStatus = "PENDING"
+	SweepStatusSubmitted SweepStatus = "SUBMITTED"
+	SweepStatusFailed    SweepStatus = "FAILED"
+)
+
+// Sweep is a transfer of the balance of a wallet asset above the minimum leftover into the treasury wallet, made
+// once the balance reached the threshold of the sweep rule of the asset
+type Sweep struct {
+	ID               string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	WalletID         string `gorm:"type:uuid;index;not null"`
+	AssetID          string `gorm:"not null"`
+	TreasuryWalletID string `gorm:"type:uuid;not null"`
+	// Available is the available balance the sweep was decided on, less the payouts not submitted yet
+	Available      string      `gorm:"not null"`
+	Amount         string      `gorm:"not null"`
+	Status         SweepStatus `gorm:"type:varchar(16);index;not null"`
+	FireblocksTxID string      `gorm:"not null;default:''"`
+	Error          string      `gorm:"not null;default:''"`
+	// Attempts is how many times the sweep was sent to Fireblocks
+	Attempts  int `gorm:"not null;default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+type Transfer struct {
+	ID                 string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	WalletID           string `gorm:"type:uuid;index;not null"`
+	FireblocksTxID     string `gorm:"uniqueIndex;not null"`
+	AssetID            string `gorm:"not null"`
+	Amount             string `gorm:"not null"`
+	DestinationAddress string `gorm:"not null"`
+	Note               string `gorm:"not null;default:''"`
+	Status             string `gorm:"index;not null"`
+	SubStatus          string `gorm:"not null;default:''"`
+	TxHash             string `gorm:"not null;default:''"`
+	NetworkFee         string `gorm:"not null;default:''"`
+	FeeCurrency        string `gorm:"not null;default:''"`
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
@@ -0,0 +1,341 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// initialisms are the words written in capitals in Go names, e.g. walletId becomes WalletID
+var initialisms = map[string]string{
+	"api":  "API",
+	"id":   "ID",
+	"ids":  "IDs",
+	"ip":   "IP",
+	"json": "JSON",
+	"url":  "URL",
+}
+
+// GenerateClient writes the types of the component schemas and a method of the package's Client for every operation
+// answering JSON, leaving out the ones marked with x-client: false. The package provides Client, with its
+// do(ctx, method, path, query, body, wantStatus, out) method, for the generated code to build on.
+func GenerateClient(doc *Document, packageName string) ([]byte, error) {
+	g := &generator{doc: doc, imports: map[string]bool{}}
+	for _, name := range sortedSchemaNames(doc) {
+		if err := g.writeType(name, doc.Components.Schemas[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	for _, path := range doc.PathList() {
+		methods := make([]string, 0, len(doc.Paths[path]))
+		for method := range doc.Paths[path] {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			if err := g.writeOperation(strings.ToUpper(method), path, doc.Paths[path][method]); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by openapi-client-gen from internal/openapi/openapi.json. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", packageName)
+	imports := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	out.WriteString("import (\n")
+	for _, path := range imports {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	out.WriteString(")\n")
+	out.Write(g.body.Bytes())
+	return format.Source(out.Bytes())
+}
+
+type generator struct {
+	doc     *Document
+	body    bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+func sortedSchemaNames(doc *Document) []string {
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeType writes the struct of an object schema, the other schemas are used through their Go type
+func (g *generator) writeType(name string, schema *Schema) error {
+	if schema.Type != "object" || len(schema.Properties) == 0 {
+		return nil
+	}
+	g.printf("\n")
+	writeComment(&g.body, "", schema.Description)
+	g.printf("type %s struct {\n", name)
+	for _, property := range schema.PropertyNames() {
+		required := schema.IsRequired(property)
+		goType, err := g.goType(schema.Properties[property], required)
+		if err != nil {
+			return fmt.Errorf("property %s: %w", property, err)
+		}
+		tag := property
+		if !required {
+			tag += ",omitempty"
+		}
+		writeComment(&g.body, "\t", schema.Properties[property].Description)
+		g.printf("\t%s %s `json:%q`\n", exportedName(property), goType, tag)
+	}
+	g.printf("}\n")
+	return nil
+}
+
+// goType returns the Go type of the values of a schema, optional timestamps and objects being pointers
+func (g *generator) goType(schema *Schema, required bool) (string, error) {
+	if schema.Ref != "" {
+		if schema.Resolved().Type == "object" && len(schema.Resolved().Properties) > 0 {
+			if !required {
+				return "*" + schema.RefName(), nil
+			}
+			return schema.RefName(), nil
+		}
+		return g.goType(schema.Resolved(), required)
+	}
+
+	switch schema.Type {
+	case "string":
+		if schema.Format == "date-time" {
+			g.imports["time"] = true
+			if !required {
+				return "*time.Time", nil
+			}
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "integer":
+		if schema.Format == "int64" {
+			return "int64", nil
+		}
+		return "int", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if schema.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := g.goType(schema.Items, true)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object":
+		if schema.AdditionalProperties != nil {
+			value, err := g.goType(schema.AdditionalProperties, true)
+			if err != nil {
+				return "", err
+			}
+			return "map[string]" + value, nil
+		}
+		if len(schema.Properties) > 0 {
+			return "", fmt.Errorf("inline object schemas are not supported, use a component schema")
+		}
+		g.imports["encoding/json"] = true
+		return "json.RawMessage", nil
+	}
+	return "", fmt.Errorf("unsupported type %q", schema.Type)
+}
+
+func (g *generator) writeOperation(method, path string, op *Operation) error {
+	if op.Client != nil && !*op.Client {
+		return nil
+	}
+	statusCode, responseSchema := op.JSONResponse()
+	if responseSchema == nil {
+		return nil
+	}
+	g.imports["context"] = true
+	g.imports["net/http"] = true
+	name := exportedName(op.OperationID)
+
+	args := []string{"ctx context.Context"}
+	pathExpr := path
+	var queryParams []*Parameter
+	for _, param := range op.Parameters {
+		switch param.In {
+		case "path":
+			g.imports["net/url"] = true
+			arg := unexportedName(param.Name)
+			args = append(args, arg+" string")
+			pathExpr = strings.Replace(pathExpr, "{"+param.Name+"}", `" + url.PathEscape(`+arg+`) + "`, 1)
+		case "query":
+			queryParams = append(queryParams, param)
+		}
+	}
+	pathExpr = strings.TrimSuffix(`"`+pathExpr+`"`, ` + ""`)
+
+	queryExpr := "nil"
+	if len(queryParams) > 0 {
+		if err := g.writeParams(name+"Params", queryParams); err != nil {
+			return err
+		}
+		args = append(args, "params *"+name+"Params")
+		queryExpr = "params.query()"
+	}
+	bodyExpr := "nil"
+	if schema := op.JSONRequestSchema(); schema != nil {
+		bodyType, err := g.goType(schema, true)
+		if err != nil {
+			return fmt.Errorf("request body: %w", err)
+		}
+		args = append(args, "body "+bodyType)
+		bodyExpr = "body"
+	}
+
+	responseType, err := g.goType(responseSchema, true)
+	if err != nil {
+		return fmt.Errorf("response: %w", err)
+	}
+	result := responseType
+	if responseSchema.Ref != "" {
+		result = "*" + responseType
+	}
+
+	g.printf("\n// %s calls %s %s: %s\n", name, method, path, lowerFirst(op.Summary))
+	g.printf("func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), result)
+	g.printf("\tvar out %s\n", responseType)
+	g.printf("\tif err := c.do(ctx, %s, %s, %s, %s, %s, &out); err != nil {\n", httpMethodConstant(method), pathExpr, queryExpr, bodyExpr, httpStatusConstant(statusCode))
+	g.printf("\t\treturn nil, err\n\t}\n")
+	if responseSchema.Ref != "" {
+		g.printf("\treturn &out, nil\n}\n")
+	} else {
+		g.printf("\treturn out, nil\n}\n")
+	}
+	return nil
+}
+
+// writeParams writes the struct of the query parameters of an operation, whose zero values are left out
+func (g *generator) writeParams(name string, params []*Parameter) error {
+	g.imports["net/url"] = true
+	g.printf("\n// %s are the query parameters of %s, zero values are left out\n", name, strings.TrimSuffix(name, "Params"))
+	g.printf("type %s struct {\n", name)
+	for _, param := range params {
+		goType, err := g.goType(param.Schema, true)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %w", param.Name, err)
+		}
+		writeComment(&g.body, "\t", param.Description)
+		g.printf("\t%s %s\n", exportedName(param.Name), goType)
+	}
+	g.printf("}\n\n")
+
+	g.printf("func (p *%s) query() url.Values {\n", name)
+	g.printf("\tquery := url.Values{}\n\tif p == nil {\n\t\treturn query\n\t}\n")
+	for _, param := range params {
+		field := "p." + exportedName(param.Name)
+		goType, _ := g.goType(param.Schema, true)
+		switch goType {
+		case "string":
+			g.printf("\tif %s != \"\" {\n\t\tquery.Set(%q, %s)\n\t}\n", field, param.Name, field)
+		case "int":
+			g.imports["strconv"] = true
+			g.printf("\tif %s != 0 {\n\t\tquery.Set(%q, strconv.Itoa(%s))\n\t}\n", field, param.Name, field)
+		case "int64":
+			g.imports["strconv"] = true
+			g.printf("\tif %s != 0 {\n\t\tquery.Set(%q, strconv.FormatInt(%s, 10))\n\t}\n", field, param.Name, field)
+		case "time.Time":
+			g.printf("\tif !%s.IsZero() {\n\t\tquery.Set(%q, %s.Format(time.RFC3339))\n\t}\n", field, param.Name, field)
+		default:
+			return fmt.Errorf("parameter %s: unsupported query parameter type %s", param.Name, goType)
+		}
+	}
+	g.printf("\treturn query\n}\n")
+	return nil
+}
+
+func writeComment(out *bytes.Buffer, indent, text string) {
+	if text == "" {
+		return
+	}
+	fmt.Fprintf(out, "%s// %s\n", indent, text)
+}
+
+// exportedName converts a camel case name, e.g. vaultAccountId, to an exported Go name, e.g. VaultAccountID
+func exportedName(name string) string {
+	var b strings.Builder
+	for _, word := range splitWords(name) {
+		b.WriteString(exportedWord(word))
+	}
+	return b.String()
+}
+
+// unexportedName converts a camel case name, e.g. walletId, to an unexported Go name, e.g. walletID
+func unexportedName(name string) string {
+	words := splitWords(name)
+	var b strings.Builder
+	b.WriteString(strings.ToLower(words[0]))
+	for _, word := range words[1:] {
+		b.WriteString(exportedWord(word))
+	}
+	return b.String()
+}
+
+func exportedWord(word string) string {
+	if initialism, ok := initialisms[strings.ToLower(word)]; ok {
+		return initialism
+	}
+	return strings.ToUpper(word[:1]) + word[1:]
+}
+
+// splitWords splits a camel case name at its capitals, keeping runs of capitals together, e.g. vaultAccountID
+// becomes vault, Account and ID
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && (!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && runes[i+1] != 's')) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(runes[start:]))
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func httpMethodConstant(method string) string {
+	return "http.Method" + strings.ToUpper(method[:1]) + strings.ToLower(method[1:])
+}
+
+func httpStatusConstant(code int) string {
+	switch code {
+	case http.StatusOK:
+		return "http.StatusOK"
+	case http.StatusCreated:
+		return "http.StatusCreated"
+	case http.StatusAccepted:
+		return "http.StatusAccepted"
+	}
+	return fmt.Sprint(code)
+}
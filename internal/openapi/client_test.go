@@ -0,0 +1,80 @@
+package openapi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name       string
+		exported   string
+		unexported string
+	}{
+		{name: "id", exported: "ID", unexported: "id"},
+		{name: "walletId", exported: "WalletID", unexported: "walletID"},
+		{name: "vaultAccountID", exported: "VaultAccountID", unexported: "vaultAccountID"},
+		{name: "fireblocksIds", exported: "FireblocksIDs", unexported: "fireblocksIDs"},
+		{name: "sourceIp", exported: "SourceIP", unexported: "sourceIP"},
+		{name: "getOpenAPISpec", exported: "GetOpenAPISpec", unexported: "getOpenAPISpec"},
+		{name: "bip44AddressIndex", exported: "Bip44AddressIndex", unexported: "bip44AddressIndex"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.exported, exportedName(tt.name))
+			assert.Equal(t, tt.unexported, unexportedName(tt.name))
+		})
+	}
+}
+
+func TestGenerateClient(t *testing.T) {
+	doc, err := Parse([]byte(`{
+  "paths": {
+    "/items/{itemId}": {
+      "get": {
+        "operationId": "getItem",
+        "summary": "Get an item",
+        "parameters": [
+          {"name": "itemId", "in": "path", "required": true, "schema": {"type": "string"}},
+          {"name": "since", "in": "query", "schema": {"type": "string", "format": "date-time"}}
+        ],
+        "responses": {"200": {"description": "The item", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Item"}}}}}
+      }
+    },
+    "/hooks": {
+      "post": {
+        "operationId": "receiveHook",
+        "x-client": false,
+        "responses": {"200": {"description": "Received", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Item"}}}}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Item": {
+        "type": "object",
+        "required": ["id"],
+        "properties": {
+          "id": {"type": "string", "description": "The item ID"},
+          "updatedAt": {"type": "string", "format": "date-time"}
+        }
+      }
+    }
+  }
+}`))
+	assert.NoError(t, err)
+
+	source, err := GenerateClient(doc, "items")
+	assert.NoError(t, err)
+	assert.Contains(t, string(source), "package items")
+	assert.Contains(t, string(source), "\t// The item ID\n\tID        string     `json:\"id\"`\n\tUpdatedAt *time.Time `json:\"updatedAt,omitempty\"`\n")
+	assert.Contains(t, string(source), "func (c *Client) GetItem(ctx context.Context, itemID string, params *GetItemParams) (*Item, error) {")
+	assert.Contains(t, string(source), `c.do(ctx, http.MethodGet, "/items/"+url.PathEscape(itemID), params.query(), nil, http.StatusOK, &out)`)
+	assert.NotContains(t, string(source), "ReceiveHook")
+
+	doc.Components.Schemas["Item"].Properties["nested"] = &Schema{Type: "object", Properties: map[string]*Schema{"a": {Type: "string"}}}
+	doc.Components.Schemas["Item"].propertyOrder = append(doc.Components.Schemas["Item"].propertyOrder, "nested")
+	_, err = GenerateClient(doc, "items")
+	assert.ErrorContains(t, err, "schema Item: property nested: inline object schemas are not supported")
+}
@@ -0,0 +1,241 @@
+// Package openapi serves the OpenAPI specification of the service, validates requests against it and generates the
+// typed client of pkg/walletapi from it. The specification is maintained by hand in openapi.json.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// Spec returns the specification as served at /openapi.json
+func Spec() []byte {
+	return spec
+}
+
+// Document is the part of an OpenAPI 3.0 document the validator and the generator use
+type Document struct {
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Components struct {
+	Schemas    map[string]*Schema    `json:"schemas"`
+	Parameters map[string]*Parameter `json:"parameters"`
+}
+
+// PathItem holds the operations of a path by lower-case method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+	// Client is false for the operations the generated client leaves out, e.g. the webhooks
+	Client *bool `json:"x-client"`
+}
+
+type Parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref         string               `json:"$ref"`
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+const jsonContentType = "application/json"
+
+// Load parses the embedded specification and resolves its parameter and schema references
+func Load() (*Document, error) {
+	return Parse(spec)
+}
+
+// MustLoad is Load for callers that cannot do without the specification, it panics if it does not parse
+func MustLoad() *Document {
+	doc, err := Load()
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+// Parse parses a specification and resolves its parameter and schema references
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse the OpenAPI document: %w", err)
+	}
+
+	for name, schema := range doc.Components.Schemas {
+		if err := doc.resolve(schema); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	for _, path := range doc.PathList() {
+		for method, op := range doc.Paths[path] {
+			if err := doc.resolveOperation(op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+		}
+	}
+	return &doc, nil
+}
+
+// PathList returns the paths sorted, for a stable iteration order
+func (d *Document) PathList() []string {
+	paths := make([]string, 0, len(d.Paths))
+	for path := range d.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// JSONRequestSchema returns the schema of the JSON request body, nil if the operation has none
+func (o *Operation) JSONRequestSchema() *Schema {
+	if o.RequestBody == nil {
+		return nil
+	}
+	return o.RequestBody.Content[jsonContentType].Schema
+}
+
+// JSONResponse returns the first successful status code answered with JSON and its schema, 0 if there is none
+func (o *Operation) JSONResponse() (int, *Schema) {
+	for code := 200; code < 300; code++ {
+		response, ok := o.Responses[fmt.Sprint(code)]
+		if !ok {
+			continue
+		}
+		if media, ok := response.Content[jsonContentType]; ok {
+			return code, media.Schema
+		}
+	}
+	return 0, nil
+}
+
+func (d *Document) resolveOperation(op *Operation) error {
+	if op.OperationID == "" {
+		return fmt.Errorf("missing operationId")
+	}
+	for i, param := range op.Parameters {
+		if param.Ref != "" {
+			name := strings.TrimPrefix(param.Ref, "#/components/parameters/")
+			resolved, ok := d.Components.Parameters[name]
+			if !ok {
+				return fmt.Errorf("unknown parameter %s", param.Ref)
+			}
+			op.Parameters[i] = resolved
+			param = resolved
+		}
+		if param.Schema == nil {
+			return fmt.Errorf("parameter %s has no schema", param.Name)
+		}
+		if err := d.resolve(param.Schema); err != nil {
+			return fmt.Errorf("parameter %s: %w", param.Name, err)
+		}
+	}
+	if schema := op.JSONRequestSchema(); schema != nil {
+		if err := d.resolve(schema); err != nil {
+			return fmt.Errorf("request body: %w", err)
+		}
+	}
+	for code, response := range op.Responses {
+		for _, media := range response.Content {
+			if media.Schema == nil {
+				continue
+			}
+			if err := d.resolve(media.Schema); err != nil {
+				return fmt.Errorf("response %s: %w", code, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolve links the references of the schema and the schemas it contains to the component schemas
+func (d *Document) resolve(schema *Schema) error {
+	if schema.Ref != "" {
+		if schema.resolved != nil {
+			return nil
+		}
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		resolved, ok := d.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("unknown schema %s", schema.Ref)
+		}
+		schema.resolved = resolved
+		return nil
+	}
+	for _, property := range schema.Properties {
+		if err := d.resolve(property); err != nil {
+			return err
+		}
+	}
+	if schema.Items != nil {
+		if err := d.resolve(schema.Items); err != nil {
+			return err
+		}
+	}
+	if schema.AdditionalProperties != nil {
+		if err := d.resolve(schema.AdditionalProperties); err != nil {
+			return err
+		}
+	}
+	return schema.compile()
+}
+
+// Handler serves the specification
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", jsonContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(spec)
+}
+
+// docsPage loads Swagger UI from its CDN, so that the binary does not have to embed it
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>FireGo Wallet Service API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => { window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" }); };
+  </script>
+</body>
+</html>
+`
+
+// DocsHandler serves Swagger UI for the specification
+func DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(docsPage))
+}
@@ -0,0 +1,96 @@
+package openapi
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	doc, err := Load()
+	assert.NoError(t, err)
+
+	operationIDs := make(map[string]string)
+	for _, path := range doc.PathList() {
+		for method, op := range doc.Paths[path] {
+			route := strings.ToUpper(method) + " " + path
+			if other, ok := operationIDs[op.OperationID]; ok {
+				t.Errorf("%s and %s share the operationId %s", other, route, op.OperationID)
+			}
+			operationIDs[op.OperationID] = route
+			assert.NotEmpty(t, op.Summary, route)
+		}
+	}
+
+	op := doc.Paths["/wallets/{walletId}/transactions"]["post"]
+	assert.Equal(t, "initiateTransfer", op.OperationID)
+	assert.Equal(t, "walletId", op.Parameters[0].Name)
+	assert.Equal(t, "uuid", op.Parameters[0].Schema.Format)
+	schema := op.JSONRequestSchema().Resolved()
+	assert.Equal(t, []string{"assetId", "amount", "destinationAddress", "note"}, schema.PropertyNames())
+	assert.Equal(t, "string", schema.Properties["amount"].Resolved().Type)
+
+	code, responseSchema := op.JSONResponse()
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, "InitiateTransferResponse", responseSchema.RefName())
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{
+			name: "invalid_json",
+			doc:  `{"paths": [}`,
+			err:  "failed to parse the OpenAPI document",
+		},
+		{
+			name: "unknown_schema",
+			doc:  `{"components": {"schemas": {"A": {"type": "object", "properties": {"b": {"$ref": "#/components/schemas/B"}}}}}}`,
+			err:  "schema A: unknown schema #/components/schemas/B",
+		},
+		{
+			name: "unknown_parameter",
+			doc:  `{"paths": {"/a": {"get": {"operationId": "getA", "parameters": [{"$ref": "#/components/parameters/b"}]}}}}`,
+			err:  "GET /a: unknown parameter #/components/parameters/b",
+		},
+		{
+			name: "missing_operation_id",
+			doc:  `{"paths": {"/a": {"get": {}}}}`,
+			err:  "GET /a: missing operationId",
+		},
+		{
+			name: "invalid_pattern",
+			doc:  `{"components": {"schemas": {"A": {"type": "string", "pattern": "("}}}}`,
+			err:  "schema A: invalid pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.doc))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Handler(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	var doc map[string]any
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+
+	recorder = httptest.NewRecorder()
+	DocsHandler(recorder, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, recorder.Body.String(), `url: "/openapi.json"`)
+}
@@ -0,0 +1,270 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Schema is the subset of the OpenAPI schema object the specification uses: types, required and nested properties,
+// items, additional properties, enums, patterns, lengths, bounds, and the date-time and uuid formats
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Description          string             `json:"description"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Enum                 []string           `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+
+	resolved *Schema
+	pattern  *regexp.Regexp
+	// propertyOrder lists the properties in the order of the document
+	propertyOrder []string
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type schema Schema
+	if err := json.Unmarshal(data, (*schema)(s)); err != nil {
+		return err
+	}
+	var raw struct {
+		Properties json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil || raw.Properties == nil {
+		return err
+	}
+	order, err := objectKeys(raw.Properties)
+	if err != nil {
+		return err
+	}
+	s.propertyOrder = order
+	return nil
+}
+
+// objectKeys returns the keys of a JSON object in their order
+func objectKeys(data []byte) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key.(string))
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// Resolved returns the schema a reference points to, or the schema itself
+func (s *Schema) Resolved() *Schema {
+	if s.resolved != nil {
+		return s.resolved
+	}
+	return s
+}
+
+// RefName returns the name of the component schema a reference points to, empty if the schema is not a reference
+func (s *Schema) RefName() string {
+	return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+}
+
+// IsRequired tells whether the object schema requires the property
+func (s *Schema) IsRequired(property string) bool {
+	for _, name := range s.Required {
+		if name == property {
+			return true
+		}
+	}
+	return false
+}
+
+// PropertyNames returns the property names in the order of the document
+func (s *Schema) PropertyNames() []string {
+	return s.propertyOrder
+}
+
+func (s *Schema) compile() error {
+	if s.Pattern == "" || s.pattern != nil {
+		return nil
+	}
+	pattern, err := regexp.Compile(s.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+	}
+	s.pattern = pattern
+	return nil
+}
+
+// ValidationError tells which value does not match the schema and why
+type ValidationError struct {
+	// Path locates the value, e.g. body.amount or query parameter limit
+	Path   string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Reason
+}
+
+// Validate checks a value decoded by encoding/json with UseNumber against the schema
+func (s *Schema) Validate(path string, value any) error {
+	s = s.Resolved()
+	if value == nil {
+		return &ValidationError{Path: path, Reason: "must not be null"}
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return &ValidationError{Path: path, Reason: "must be an object"}
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return &ValidationError{Path: join(path, name), Reason: "is required"}
+			}
+		}
+		for _, name := range sortedKeys(object) {
+			if property, ok := s.Properties[name]; ok {
+				if err := property.Validate(join(path, name), object[name]); err != nil {
+					return err
+				}
+			} else if s.AdditionalProperties != nil {
+				if err := s.AdditionalProperties.Validate(join(path, name), object[name]); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return &ValidationError{Path: path, Reason: "must be an array"}
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				if err := s.Items.Validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return &ValidationError{Path: path, Reason: "must be a string"}
+		}
+		return s.validateString(path, str)
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return s.numberTypeError(path)
+		}
+		return s.validateNumber(path, number)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return &ValidationError{Path: path, Reason: "must be a boolean"}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateString(path, value string) error {
+	length := utf8.RuneCountInString(value)
+	if s.MinLength != nil && length < *s.MinLength {
+		if *s.MinLength == 1 {
+			return &ValidationError{Path: path, Reason: "must not be empty"}
+		}
+		return &ValidationError{Path: path, Reason: fmt.Sprintf("must be at least %d characters long", *s.MinLength)}
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		return &ValidationError{Path: path, Reason: fmt.Sprintf("must be at most %d characters long", *s.MaxLength)}
+	}
+	if len(s.Enum) > 0 && !contains(s.Enum, value) {
+		return &ValidationError{Path: path, Reason: "must be one of " + strings.Join(s.Enum, ", ")}
+	}
+	if s.pattern != nil && !s.pattern.MatchString(value) {
+		return &ValidationError{Path: path, Reason: "must match " + s.Pattern}
+	}
+	switch s.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return &ValidationError{Path: path, Reason: "must be an RFC 3339 timestamp"}
+		}
+	case "uuid":
+		if _, err := uuid.Parse(value); err != nil {
+			return &ValidationError{Path: path, Reason: "must be a UUID"}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateNumber(path string, value json.Number) error {
+	number, err := value.Float64()
+	if err != nil {
+		return s.numberTypeError(path)
+	}
+	if s.Type == "integer" {
+		if _, err = value.Int64(); err != nil {
+			return s.numberTypeError(path)
+		}
+	}
+	if s.Minimum != nil && number < *s.Minimum {
+		return &ValidationError{Path: path, Reason: fmt.Sprintf("must be at least %v", *s.Minimum)}
+	}
+	if s.Maximum != nil && number > *s.Maximum {
+		return &ValidationError{Path: path, Reason: fmt.Sprintf("must be at most %v", *s.Maximum)}
+	}
+	return nil
+}
+
+func (s *Schema) numberTypeError(path string) error {
+	if s.Type == "integer" {
+		return &ValidationError{Path: path, Reason: "must be an integer"}
+	}
+	return &ValidationError{Path: path, Reason: "must be a number"}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package openapi
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const testSchemas = `{
+  "components": {
+    "schemas": {
+      "Amount": {"type": "string", "pattern": "^[0-9]+(\\.[0-9]+)?$"},
+      "Item": {
+        "type": "object",
+        "required": ["id", "amount"],
+        "properties": {
+          "id": {"type": "string", "format": "uuid"},
+          "amount": {"$ref": "#/components/schemas/Amount"},
+          "count": {"type": "integer", "minimum": 1, "maximum": 10},
+          "ratio": {"type": "number"},
+          "kind": {"type": "string", "enum": ["A", "B"]},
+          "name": {"type": "string", "minLength": 1, "maxLength": 3},
+          "at": {"type": "string", "format": "date-time"},
+          "enabled": {"type": "boolean"},
+          "tags": {"type": "array", "items": {"type": "string", "minLength": 1}},
+          "labels": {"type": "object", "additionalProperties": {"type": "integer"}}
+        }
+      }
+    }
+  }
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	doc, err := Parse([]byte(testSchemas))
+	assert.NoError(t, err)
+	schema := doc.Components.Schemas["Item"]
+
+	validID := `"id": "0b6f1a57-3d43-4a8e-8d0e-5d1bb1b9f6a2", "amount": "1.5"`
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{name: "valid", body: `{` + validID + `}`},
+		{name: "all_properties", body: `{` + validID + `, "count": 10, "ratio": 0.5, "kind": "B", "name": "abc", "at": "2024-01-01T12:00:00Z", "enabled": true, "tags": ["a"], "labels": {"a": 1}, "unknown": null}`},
+		{name: "not_an_object", body: `[]`, err: "body: must be an object"},
+		{name: "missing_required", body: `{"id": "0b6f1a57-3d43-4a8e-8d0e-5d1bb1b9f6a2"}`, err: "body.amount: is required"},
+		{name: "null", body: `{` + validID + `, "name": null}`, err: "body.name: must not be null"},
+		{name: "invalid_uuid", body: `{"id": "1", "amount": "1"}`, err: "body.id: must be a UUID"},
+		{name: "pattern_through_reference", body: `{"id": "0b6f1a57-3d43-4a8e-8d0e-5d1bb1b9f6a2", "amount": "1e5"}`, err: "body.amount: must match"},
+		{name: "wrong_type", body: `{` + validID + `, "count": "1"}`, err: "body.count: must be an integer"},
+		{name: "fraction_for_integer", body: `{` + validID + `, "count": 1.5}`, err: "body.count: must be an integer"},
+		{name: "below_minimum", body: `{` + validID + `, "count": 0}`, err: "body.count: must be at least 1"},
+		{name: "above_maximum", body: `{` + validID + `, "count": 11}`, err: "body.count: must be at most 10"},
+		{name: "number", body: `{` + validID + `, "ratio": "0.5"}`, err: "body.ratio: must be a number"},
+		{name: "enum", body: `{` + validID + `, "kind": "C"}`, err: "body.kind: must be one of A, B"},
+		{name: "empty", body: `{` + validID + `, "name": ""}`, err: "body.name: must not be empty"},
+		{name: "too_long", body: `{` + validID + `, "name": "abcd"}`, err: "body.name: must be at most 3 characters long"},
+		{name: "date_time", body: `{` + validID + `, "at": "2024-01-01"}`, err: "body.at: must be an RFC 3339 timestamp"},
+		{name: "boolean", body: `{` + validID + `, "enabled": "true"}`, err: "body.enabled: must be a boolean"},
+		{name: "array", body: `{` + validID + `, "tags": "a"}`, err: "body.tags: must be an array"},
+		{name: "array_item", body: `{` + validID + `, "tags": ["a", ""]}`, err: "body.tags[1]: must not be empty"},
+		{name: "additional_property", body: `{` + validID + `, "labels": {"a": "1"}}`, err: "body.labels.a: must be an integer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := json.NewDecoder(strings.NewReader(tt.body))
+			decoder.UseNumber()
+			var value any
+			assert.NoError(t, decoder.Decode(&value))
+
+			err := schema.Validate("body", value)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/apierror"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Validator checks the path and query parameters and the JSON body of the requests against the specification
+type Validator struct {
+	// operations are keyed by route pattern, e.g. POST /wallets/{walletId}/transactions
+	operations map[string]*Operation
+}
+
+func NewValidator(doc *Document) *Validator {
+	operations := make(map[string]*Operation)
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			operations[strings.ToUpper(method)+" "+path] = op
+		}
+	}
+	return &Validator{operations: operations}
+}
+
+// Wrap validates the requests before passing them to next, which has to be registered on a mux with the pattern of
+// its operation: the mux sets the pattern and the path values the operation is looked up and validated with.
+// Requests to routes the specification does not describe are passed as they are.
+func (v *Validator) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		op, ok := v.operations[r.Pattern]
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		if err := validateRequest(op, r); err != nil {
+			var validationErr *ValidationError
+			var maxBytesErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &validationErr):
+				apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request: "+validationErr.Error())
+			case errors.As(err, &maxBytesErr):
+				apierror.Write(w, r, http.StatusRequestEntityTooLarge, apierror.BodyTooLarge, "Request body too large")
+			default:
+				apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
+			}
+			return
+		}
+		next(w, r)
+	}
+}
+
+func validateRequest(op *Operation, r *http.Request) error {
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		var value string
+		switch param.In {
+		case "path":
+			value = r.PathValue(param.Name)
+		case "query":
+			values, ok := query[param.Name]
+			if !ok {
+				if param.Required {
+					return &ValidationError{Path: "query parameter " + param.Name, Reason: "is required"}
+				}
+				continue
+			}
+			value = values[0]
+		default:
+			continue
+		}
+		if err := param.Schema.Validate(param.In+" parameter "+param.Name, parameterValue(param.Schema, value)); err != nil {
+			return err
+		}
+	}
+
+	schema := op.JSONRequestSchema()
+	if schema == nil || !jsonBody(op, r) {
+		return nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	// the handler reads the body again, e.g. to verify a signature over its exact bytes
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			return &ValidationError{Path: "body", Reason: "is required"}
+		}
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err = decoder.Decode(&value); err != nil {
+		return err
+	}
+	return schema.Validate("body", value)
+}
+
+// jsonBody tells whether the body is validated as JSON. The bodies sent as another media type the operation accepts,
+// e.g. text/csv, are left to the handler.
+func jsonBody(op *Operation, r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType == jsonContentType {
+		return true
+	}
+	_, ok := op.RequestBody.Content[mediaType]
+	return !ok
+}
+
+// parameterValue converts a parameter to the JSON value its schema describes, leaving it as a string when it does
+// not parse so that the validation reports it
+func parameterValue(schema *Schema, value string) any {
+	switch schema.Resolved().Type {
+	case "integer", "number":
+		return json.Number(value)
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
@@ -0,0 +1,171 @@
+package openapi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidatorWrap(t *testing.T) {
+	validator := NewValidator(MustLoad())
+	var received string
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /wallets", validator.Wrap(echo))
+	mux.HandleFunc("POST /wallets/{walletId}/transactions", validator.Wrap(echo))
+	mux.HandleFunc("POST /wallets/{walletId}/payouts", validator.Wrap(echo))
+	mux.HandleFunc("POST /wallets/{walletId}/assets/{assetId}/addresses", validator.Wrap(echo))
+	mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/addresses", validator.Wrap(echo))
+	mux.HandleFunc("GET /admin/audit", validator.Wrap(echo))
+	mux.HandleFunc("GET /undocumented/{id}", validator.Wrap(echo))
+	handler := http.MaxBytesHandler(mux, 1024)
+
+	walletID := "0b6f1a57-3d43-4a8e-8d0e-5d1bb1b9f6a2"
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		statusCode  int
+		response    string
+	}{
+		{
+			name:       "valid_body",
+			method:     http.MethodPost,
+			target:     "/wallets/" + walletID + "/transactions",
+			body:       `{"assetId": "BTC_TEST", "amount": "0.5", "destinationAddress": "tb1q"}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "missing_field",
+			method:     http.MethodPost,
+			target:     "/wallets/" + walletID + "/transactions",
+			body:       `{"assetId": "BTC_TEST", "destinationAddress": "tb1q"}`,
+			statusCode: http.StatusBadRequest,
+			response:   "Invalid request: body.amount: is required",
+		},
+		{
+			name:       "invalid_amount",
+			method:     http.MethodPost,
+			target:     "/wallets/" + walletID + "/transactions",
+			body:       `{"assetId": "BTC_TEST", "amount": 0.5, "destinationAddress": "tb1q"}`,
+			statusCode: http.StatusBadRequest,
+			response:   "Invalid request: body.amount: must be a string",
+		},
+		{
+			name:        "other_media_type",
+			method:      http.MethodPost,
+			target:      "/wallets/" + walletID + "/payouts?assetId=BTC_TEST",
+			contentType: "text/csv; charset=utf-8",
+			body:        "destinationAddress,amount\ntb1q,0.5\n",
+			statusCode:  http.StatusOK,
+		},
+		{
+			name:        "unsupported_media_type_validated_as_json",
+			method:      http.MethodPost,
+			target:      "/wallets/" + walletID + "/transactions",
+			contentType: "text/csv",
+			body:        "destinationAddress,amount\ntb1q,0.5\n",
+			statusCode:  http.StatusBadRequest,
+			response:    "Invalid request body",
+		},
+		{
+			name:       "invalid_path_parameter",
+			method:     http.MethodPost,
+			target:     "/wallets/42/transactions",
+			body:       `{"assetId": "BTC_TEST", "amount": "0.5", "destinationAddress": "tb1q"}`,
+			statusCode: http.StatusBadRequest,
+			response:   "Invalid request: path parameter walletId: must be a UUID",
+		},
+		{
+			name:       "malformed_body",
+			method:     http.MethodPost,
+			target:     "/wallets",
+			body:       `{"name": `,
+			statusCode: http.StatusBadRequest,
+			response:   "Invalid request body",
+		},
+		{
+			name:       "missing_required_body",
+			method:     http.MethodPost,
+			target:     "/wallets",
+			statusCode: http.StatusBadRequest,
+			response:   "Invalid request: body: is required",
+		},
+		{
+			name:       "missing_optional_body",
+			method:     http.MethodPost,
+			target:     "/wallets/" + walletID + "/assets/BTC_TEST/addresses",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "body_too_large",
+			method:     http.MethodPost,
+			target:     "/wallets",
+			body:       `{"name": "` + strings.Repeat("a", 2048) + `"}`,
+			statusCode: http.StatusRequestEntityTooLarge,
+			response:   "Request body too large",
+		},
+		{
+			name:       "valid_query",
+			method:     http.MethodGet,
+			target:     "/wallets/" + walletID + "/assets/BTC_TEST/addresses?limit=1000&after=abc",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "query_out_of_range",
+			method:     http.MethodGet,
+			target:     "/wallets/" + walletID + "/assets/BTC_TEST/addresses?limit=1001",
+			statusCode: http.StatusBadRequest,
+			response:   "Invalid request: query parameter limit: must be at most 1000",
+		},
+		{
+			name:       "query_not_a_number",
+			method:     http.MethodGet,
+			target:     "/admin/audit?before=abc",
+			statusCode: http.StatusBadRequest,
+			response:   "Invalid request: query parameter before: must be an integer",
+		},
+		{
+			name:       "query_pattern",
+			method:     http.MethodGet,
+			target:     "/admin/audit?outcome=failure&since=2024-01-01T00:00:00Z",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "undocumented_route",
+			method:     http.MethodGet,
+			target:     "/undocumented/1",
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = ""
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.statusCode, recorder.Code)
+			if tt.statusCode == http.StatusOK {
+				// the handler reads the body the validator read
+				assert.Equal(t, tt.body, received)
+			} else {
+				assert.Equal(t, tt.response, strings.TrimSpace(recorder.Body.String()))
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+// Package payout submits the batches of transfers of a wallet, its payouts, to Fireblocks line by line.
+//
+// Each line is sent with an idempotency key derived from the payout and the line number, so a line whose outcome is
+// unknown, because Fireblocks did not answer or the service stopped before storing it, is simply submitted again:
+// Fireblocks answers with the transaction created the first time instead of creating another one.
+package payout
+
+import (
+	"context"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/ledger"
+	"firego-wallet-service/internal/metrics"
+	"firego-wallet-service/internal/model"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// maxLineAttempts is how many times a line is sent to Fireblocks before it is given up on
+const maxLineAttempts = 5
+
+// holdTTL is how long a hold that is not released keeps its amount reserved, well over the time Fireblocks takes to
+// answer a transaction, with the retries of the client
+const holdTTL = 5 * time.Minute
+
+// queueSize is how many new payouts wait for the worker, the payouts that do not fit are picked up by the retry pass
+const queueSize = 100
+
+type Repository interface {
+	Create(ctx context.Context, payout *model.Payout) error
+	GetByID(ctx context.Context, id string) (*model.Payout, error)
+	ListPending(ctx context.Context) ([]model.Payout, error)
+	PendingAmount(ctx context.Context, walletID, assetID string) (string, error)
+	SaveLine(ctx context.Context, line *model.PayoutLine) error
+	Finish(ctx context.Context, id string, status model.PayoutStatus, completedAt time.Time) error
+	// LockWalletAsset runs fn while no other call for the wallet asset runs, the payouts created and the amounts read
+	// with its context included
+	LockWalletAsset(ctx context.Context, walletID, assetID string, fn func(ctx context.Context) error) error
+	// CreateHold stores a hold, counted by PendingAmount until it is deleted or expires
+	CreateHold(ctx context.Context, hold *model.BalanceHold) error
+	DeleteHold(ctx context.Context, id string) error
+}
+
+type TransferRepository interface {
+	Create(transfer *model.Transfer) error
+}
+
+type FireblocksClient interface {
+	CreateTransaction(ctx context.Context, req fireblocks.CreateTransactionRequest) (*fireblocks.CreateTransactionResponse, int, error)
+	GetTransactionByExternalID(ctx context.Context, externalTxID string) (*fireblocks.TransactionResponse, int, error)
+}
+
+// BalanceCache is invalidated once a line moves funds out of the vault account, see cache.Balances
+type BalanceCache interface {
+	Invalidate(ctx context.Context, vaultAccountID, assetID string)
+}
+
+type Elector interface {
+	IsLeader(ctx context.Context) bool
+}
+
+// Service stores the payouts and submits their lines in the background, on the elected leader only, so that the
+// instances do not submit the same line concurrently
+type Service struct {
+	repo             Repository
+	transferRepo     TransferRepository
+	fireblocksClient FireblocksClient
+	balances         BalanceCache
+	elector          Elector
+	retryInterval    time.Duration
+	queue            chan string
+}
+
+func New(repo Repository, transferRepo TransferRepository, fireblocksClient FireblocksClient, balances BalanceCache, elector Elector, retryInterval time.Duration) *Service {
+	return &Service{
+		repo:             repo,
+		transferRepo:     transferRepo,
+		fireblocksClient: fireblocksClient,
+		balances:         balances,
+		elector:          elector,
+		retryInterval:    retryInterval,
+		queue:            make(chan string, queueSize),
+	}
+}
+
+// IdempotencyKey is the Fireblocks idempotency key of a line of a payout
+func IdempotencyKey(payoutID string, line int) string {
+	return fmt.Sprintf("payout-%s-%d", payoutID, line)
+}
+
+// reservationContextKey carries the IDs of the payouts created during Reserve, queued once they are committed
+type reservationContextKey struct{}
+
+// Create stores a pending payout with its pending lines and queues it for the worker. Within Reserve, the payout is
+// queued once Reserve returns.
+func (s *Service) Create(ctx context.Context, payout *model.Payout) error {
+	payout.Status = model.PayoutStatusPending
+	for i := range payout.Lines {
+		payout.Lines[i].Line = i + 1
+		payout.Lines[i].Status = model.PayoutLineStatusPending
+	}
+	if err := s.repo.Create(ctx, payout); err != nil {
+		return err
+	}
+
+	if created, ok := ctx.Value(reservationContextKey{}).(*[]string); ok {
+		*created = append(*created, payout.ID)
+		return nil
+	}
+	s.enqueue(payout.ID)
+	return nil
+}
+
+func (s *Service) enqueue(id string) {
+	select {
+	case s.queue <- id:
+	default:
+		slog.Warn("Payout queue full, the payout is submitted on the next retry", "payout_id", id)
+	}
+}
+
+func (s *Service) Get(ctx context.Context, id string) (*model.Payout, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// Reserved is the amount of the lines of the wallet asset payouts not submitted yet and of its holds. It is not part of the Fireblocks
+// available balance, so it must be subtracted from it before accepting a new payout.
+func (s *Service) Reserved(ctx context.Context, walletID, assetID string) (*big.Rat, error) {
+	amount, err := s.repo.PendingAmount(ctx, walletID, assetID)
+	if err != nil {
+		return nil, err
+	}
+	return ledger.ParseAmount(amount)
+}
+
+// Reserve runs fn with the Reserved amount of the wallet asset while no other reservation of the wallet asset runs, on
+// any instance. fn checks the available balance less the reserved amount, then creates its payout with the context it
+// is given or submits its transaction, so that concurrent requests cannot spend the same funds twice.
+func (s *Service) Reserve(ctx context.Context, walletID, assetID string, fn func(ctx context.Context, reserved *big.Rat) error) error {
+	var created []string
+	err := s.repo.LockWalletAsset(ctx, walletID, assetID, func(ctx context.Context) error {
+		reserved, err := s.Reserved(ctx, walletID, assetID)
+		if err != nil {
+			return err
+		}
+		return fn(context.WithValue(ctx, reservationContextKey{}, &created), reserved)
+	})
+	if err != nil {
+		return err
+	}
+
+	// the worker would not find the payouts before they are committed
+	for _, id := range created {
+		s.enqueue(id)
+	}
+	return nil
+}
+
+// Hold sets amount of the wallet asset aside for a transaction submitted once Reserve returns, so that the lock is not
+// held while Fireblocks answers, and returns the ID of the hold. It is called with the context of the Reserve function.
+// The hold is released by Release once the transaction was created or rejected, and expires otherwise.
+func (s *Service) Hold(ctx context.Context, walletID, assetID string, amount *big.Rat) (string, error) {
+	hold := model.BalanceHold{
+		WalletID:  walletID,
+		AssetID:   assetID,
+		Amount:    ledger.FormatAmount(amount),
+		ExpiresAt: time.Now().Add(holdTTL),
+	}
+	if err := s.repo.CreateHold(ctx, &hold); err != nil {
+		return "", err
+	}
+	return hold.ID, nil
+}
+
+// Release drops a hold, once its amount is out of the available balance or was not spent
+func (s *Service) Release(ctx context.Context, holdID string) error {
+	return s.repo.DeleteHold(ctx, holdID)
+}
+
+// Run submits the queued payouts until the context is cancelled, and every retry interval the pending payouts, which
+// includes the ones interrupted by a restart and the ones created on the other instances. Only the leader submits
+// payouts, the payouts queued on the other instances wait for the next retry of the leader.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.retryInterval)
+	defer ticker.Stop()
+
+	if s.elector.IsLeader(ctx) {
+		s.ProcessPending(ctx)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-s.queue:
+			if !s.elector.IsLeader(ctx) {
+				continue
+			}
+			if err := s.Process(ctx, id); err != nil {
+				slog.Error("Failed to process payout", "payout_id", id, "error", err)
+			}
+		case <-ticker.C:
+			if s.elector.IsLeader(ctx) {
+				s.ProcessPending(ctx)
+			}
+		}
+	}
+}
+
+// ProcessPending processes every pending payout, oldest first
+func (s *Service) ProcessPending(ctx context.Context) {
+	payouts, err := s.repo.ListPending(ctx)
+	if err != nil {
+		slog.Error("Failed to list pending payouts", "error", err)
+		return
+	}
+
+	for _, payout := range payouts {
+		if ctx.Err() != nil {
+			return
+		}
+		if err = s.process(ctx, &payout); err != nil {
+			slog.Error("Failed to process payout", "payout_id", payout.ID, "error", err)
+		}
+	}
+}
+
+// Process submits the pending lines of a payout in order, then sets its final status once no line is pending
+func (s *Service) Process(ctx context.Context, id string) error {
+	payout, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get payout %s: %w", id, err)
+	}
+	return s.process(ctx, payout)
+}
+
+func (s *Service) process(ctx context.Context, payout *model.Payout) error {
+	if payout.Status != model.PayoutStatusPending {
+		return nil
+	}
+
+	for i := range payout.Lines {
+		line := &payout.Lines[i]
+		if line.Status != model.PayoutLineStatusPending {
+			continue
+		}
+		// the service shutting down stops the payout between lines, the rest is submitted after the restart
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.submit(ctx, payout, line); err != nil {
+			return err
+		}
+		if line.Status == model.PayoutLineStatusPending {
+			// Fireblocks is unavailable, so are the next lines likely: the payout is retried later
+			return nil
+		}
+	}
+
+	status := finalStatus(payout.Lines)
+	if status == model.PayoutStatusPending {
+		return nil
+	}
+	if err := s.repo.Finish(context.WithoutCancel(ctx), payout.ID, status, time.Now()); err != nil {
+		return fmt.Errorf("failed to finish payout %s: %w", payout.ID, err)
+	}
+	slog.Info("Payout finished", "payout_id", payout.ID, "status", status)
+	return nil
+}
+
+// submit sends a line to Fireblocks and stores its outcome. The line is still pending when Fireblocks failed
+// transiently, including when it rate limited the line or was still handling the line sent with the same idempotency
+// key.
+func (s *Service) submit(ctx context.Context, payout *model.Payout, line *model.PayoutLine) error {
+	key := IdempotencyKey(payout.ID, line.Line)
+	// once sent, the transaction may exist in Fireblocks, so the outcome is stored whatever happens to the context
+	ctx = fireblocks.WithIdempotencyKey(context.WithoutCancel(ctx), key)
+	line.Attempts++
+
+	fbReq := fireblocks.NewVaultTransferRequest(payout.AssetID, payout.VaultAccountID, line.DestinationAddress, line.Amount, line.Note)
+	// the key is also the external ID of the transaction, which it can be looked up by
+	fbReq.ExternalTxID = key
+	fbResp, statusCode, err := s.fireblocksClient.CreateTransaction(ctx, fbReq)
+	if err != nil && (fireblocks.Rejected(statusCode) || line.Attempts >= maxLineAttempts) {
+		// an earlier attempt whose outcome was lost may have created the transaction, the line is not failed if it did
+		fbResp = s.lookup(ctx, payout, line, key, statusCode, err)
+	} else if err != nil {
+		slog.Error("Failed to submit payout line", "payout_id", payout.ID, "line", line.Line, "attempts", line.Attempts, "error", err)
+		line.Error = "Fireblocks unavailable"
+	}
+
+	if fbResp != nil {
+		line.Status = model.PayoutLineStatusSubmitted
+		line.FireblocksTxID = fbResp.ID
+		line.Error = ""
+
+		s.balances.Invalidate(ctx, payout.VaultAccountID, payout.AssetID)
+		metrics.ObserveTransferInitiated(payout.AssetID, line.Amount)
+		transfer := model.Transfer{
+			WalletID:           payout.WalletID,
+			FireblocksTxID:     fbResp.ID,
+			AssetID:            payout.AssetID,
+			Amount:             line.Amount,
+			DestinationAddress: line.DestinationAddress,
+			Note:               line.Note,
+			Status:             fbResp.Status,
+		}
+		// a line submitted again after its outcome was lost gets the same transaction, which is already stored
+		if err := s.transferRepo.Create(&transfer); err != nil {
+			slog.Error("Failed to store payout transfer", "payout_id", payout.ID, "transaction_id", fbResp.ID, "error", err)
+		}
+	}
+
+	if err := s.repo.SaveLine(ctx, line); err != nil {
+		// the line stays pending in the database and is submitted again with the same idempotency key
+		return fmt.Errorf("failed to store line %d of payout %s: %w", line.Line, payout.ID, err)
+	}
+	return nil
+}
+
+// lookup is called before failing a line, rejected by Fireblocks or out of attempts, and returns its transaction when
+// Fireblocks has one with the line external ID. The line is failed only once Fireblocks tells it has none, it stays
+// pending when the lookup fails too.
+func (s *Service) lookup(ctx context.Context, payout *model.Payout, line *model.PayoutLine, key string, statusCode int, submitErr error) *fireblocks.CreateTransactionResponse {
+	tx, lookupStatusCode, err := s.fireblocksClient.GetTransactionByExternalID(ctx, key)
+	switch {
+	case err == nil:
+		slog.Info("Payout line transaction found", "payout_id", payout.ID, "line", line.Line, "transaction_id", tx.ID)
+		return &fireblocks.CreateTransactionResponse{ID: tx.ID, Status: tx.Status}
+	case lookupStatusCode != http.StatusNotFound:
+		slog.Error("Payout line outcome unknown, keeping it pending", "payout_id", payout.ID, "line", line.Line, "attempts", line.Attempts, "error", submitErr, "lookup_error", err)
+		line.Error = "Fireblocks unavailable"
+	case fireblocks.Rejected(statusCode):
+		slog.Error("Payout line rejected by Fireblocks", "payout_id", payout.ID, "line", line.Line, "error", submitErr)
+		line.Status = model.PayoutLineStatusFailed
+		line.Error = "Rejected by Fireblocks"
+	default:
+		slog.Error("Failed to submit payout line, giving up", "payout_id", payout.ID, "line", line.Line, "attempts", line.Attempts, "error", submitErr)
+		line.Status = model.PayoutLineStatusFailed
+		line.Error = "Fireblocks unavailable"
+	}
+	return nil
+}
+
+// finalStatus is the status of a payout given its lines: pending while any line is, then completed, partial or failed
+func finalStatus(lines []model.PayoutLine) model.PayoutStatus {
+	var submitted, failed int
+	for _, line := range lines {
+		switch line.Status {
+		case model.PayoutLineStatusSubmitted:
+			submitted++
+		case model.PayoutLineStatusFailed:
+			failed++
+		default:
+			return model.PayoutStatusPending
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return model.PayoutStatusCompleted
+	case submitted == 0:
+		return model.PayoutStatusFailed
+	default:
+		return model.PayoutStatusPartial
+	}
+}
@@ -0,0 +1,561 @@
+package payout
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/fireblocks/fireblockstest"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testAPIKey = "sim-api-key"
+
+// MemoryRepository keeps the payouts like the database does, handing out copies
+type MemoryRepository struct {
+	mu      sync.Mutex
+	payouts map[string]model.Payout
+	// lock stands in for the advisory lock of the wallet assets, one for all of them
+	lock sync.Mutex
+	// FailSaves makes the line updates fail, as if the service stopped before storing them
+	FailSaves bool
+	holds     map[string]model.BalanceHold
+}
+
+func (m *MemoryRepository) Create(_ context.Context, payout *model.Payout) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.payouts == nil {
+		m.payouts = map[string]model.Payout{}
+	}
+	payout.ID = "payout-" + strconv.Itoa(len(m.payouts)+1)
+	for i := range payout.Lines {
+		payout.Lines[i].PayoutID = payout.ID
+	}
+	m.payouts[payout.ID] = copyPayout(*payout)
+	return nil
+}
+
+func (m *MemoryRepository) GetByID(_ context.Context, id string) (*model.Payout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	payout, ok := m.payouts[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	payout = copyPayout(payout)
+	return &payout, nil
+}
+
+func (m *MemoryRepository) ListPending(_ context.Context) ([]model.Payout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var payouts []model.Payout
+	for _, payout := range m.payouts {
+		if payout.Status == model.PayoutStatusPending {
+			payouts = append(payouts, copyPayout(payout))
+		}
+	}
+	return payouts, nil
+}
+
+func (m *MemoryRepository) PendingAmount(_ context.Context, walletID, assetID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	total := new(big.Rat)
+	for _, payout := range m.payouts {
+		if payout.WalletID != walletID || payout.AssetID != assetID {
+			continue
+		}
+		for _, line := range payout.Lines {
+			if line.Status == model.PayoutLineStatusPending {
+				amount, _ := new(big.Rat).SetString(line.Amount)
+				total.Add(total, amount)
+			}
+		}
+	}
+	for _, hold := range m.holds {
+		if hold.WalletID == walletID && hold.AssetID == assetID && time.Now().Before(hold.ExpiresAt) {
+			amount, _ := new(big.Rat).SetString(hold.Amount)
+			total.Add(total, amount)
+		}
+	}
+	return total.FloatString(18), nil
+}
+
+func (m *MemoryRepository) CreateHold(_ context.Context, hold *model.BalanceHold) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holds == nil {
+		m.holds = map[string]model.BalanceHold{}
+	}
+	hold.ID = "hold-" + strconv.Itoa(len(m.holds)+1)
+	m.holds[hold.ID] = *hold
+	return nil
+}
+
+func (m *MemoryRepository) DeleteHold(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.holds, id)
+	return nil
+}
+
+func (m *MemoryRepository) SaveLine(_ context.Context, line *model.PayoutLine) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.FailSaves {
+		return errors.New("connection reset")
+	}
+	payout := m.payouts[line.PayoutID]
+	payout.Lines[line.Line-1] = *line
+	return nil
+}
+
+func (m *MemoryRepository) Finish(_ context.Context, id string, status model.PayoutStatus, completedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	payout := m.payouts[id]
+	payout.Status = status
+	payout.CompletedAt = &completedAt
+	m.payouts[id] = payout
+	return nil
+}
+
+func (m *MemoryRepository) LockWalletAsset(ctx context.Context, _, _ string, fn func(ctx context.Context) error) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return fn(ctx)
+}
+
+func copyPayout(payout model.Payout) model.Payout {
+	payout.Lines = append([]model.PayoutLine(nil), payout.Lines...)
+	return payout
+}
+
+type MemoryTransferRepository struct {
+	Transfers map[string]model.Transfer
+}
+
+func (m *MemoryTransferRepository) Create(transfer *model.Transfer) error {
+	// like the database, the transaction stored first is kept
+	if _, ok := m.Transfers[transfer.FireblocksTxID]; ok {
+		return nil
+	}
+	m.Transfers[transfer.FireblocksTxID] = *transfer
+	return nil
+}
+
+type MockBalanceCache struct {
+	Invalidated int
+}
+
+func (m *MockBalanceCache) Invalidate(_ context.Context, _, _ string) {
+	m.Invalidated++
+}
+
+type StaticElector bool
+
+func (e StaticElector) IsLeader(context.Context) bool {
+	return bool(e)
+}
+
+type testEnv struct {
+	service   *Service
+	repo      *MemoryRepository
+	transfers *MemoryTransferRepository
+	balances  *MockBalanceCache
+	vault     string
+	// unavailable is how many transactions the simulator fails to create with unavailableStatus, a 503 by default,
+	// before it recovers
+	unavailable       atomic.Int32
+	unavailableStatus int
+	// lost is how many transactions the simulator creates before answering with a 503, as if the answer was lost
+	lost atomic.Int32
+	// lookupUnavailable makes the lookups of transactions by external ID fail
+	lookupUnavailable atomic.Bool
+	// created counts the transaction requests that reached the simulator
+	created atomic.Int32
+}
+
+// newTestEnv runs the service against the Fireblocks simulator, with a vault account funded with 10 ETH_TEST5
+func newTestEnv(t *testing.T) *testEnv {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	env := &testEnv{
+		repo:      &MemoryRepository{},
+		transfers: &MemoryTransferRepository{Transfers: map[string]model.Transfer{}},
+		balances:  &MockBalanceCache{},
+
+		unavailableStatus: http.StatusServiceUnavailable,
+	}
+	sim := fireblockstest.NewServer(fireblockstest.Config{APIKey: testAPIKey, PublicKey: &key.PublicKey})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/transactions/external_tx_id/") && env.lookupUnavailable.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method == http.MethodPost && r.URL.Path == "/v1/transactions" {
+			if env.lost.Add(-1) >= 0 {
+				env.created.Add(1)
+				sim.ServeHTTP(httptest.NewRecorder(), r)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if env.unavailable.Add(-1) >= 0 {
+				w.WriteHeader(env.unavailableStatus)
+				return
+			}
+			env.created.Add(1)
+		}
+		sim.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client := fireblocks.NewClient(server.URL, testAPIKey, key)
+	vault, _, err := client.CreateVaultAccount(ctx, fireblocks.CreateVaultAccountRequest{Name: "payouts"})
+	assert.NoError(t, err)
+	_, err = sim.Deposit(ctx, vault.ID, "ETH_TEST5", "10")
+	assert.NoError(t, err)
+	sim.Advance(ctx)
+
+	env.vault = vault.ID
+	env.service = New(env.repo, env.transfers, client, env.balances, StaticElector(true), time.Minute)
+	return env
+}
+
+// create stores a payout of the amounts to distinct addresses
+func (e *testEnv) create(t *testing.T, amounts ...string) *model.Payout {
+	payout := &model.Payout{
+		WalletID:       "wallet-1",
+		VaultAccountID: e.vault,
+		AssetID:        "ETH_TEST5",
+		Total:          "0",
+	}
+	for i, amount := range amounts {
+		payout.Lines = append(payout.Lines, model.PayoutLine{
+			DestinationAddress: "0xemployee" + strconv.Itoa(i+1),
+			Amount:             amount,
+			Note:               "salary",
+		})
+	}
+	assert.NoError(t, e.service.Create(context.Background(), payout))
+	return payout
+}
+
+func (e *testEnv) get(t *testing.T, id string) *model.Payout {
+	payout, err := e.service.Get(context.Background(), id)
+	assert.NoError(t, err)
+	return payout
+}
+
+func TestProcess(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, "1", "0", "2.5")
+
+	assert.NoError(t, env.service.Process(context.Background(), created.ID))
+
+	payout := env.get(t, created.ID)
+	assert.Equal(t, model.PayoutStatusPartial, payout.Status)
+	assert.NotNil(t, payout.CompletedAt)
+	assert.Equal(t, []int{1, 2, 3}, []int{payout.Lines[0].Line, payout.Lines[1].Line, payout.Lines[2].Line})
+	assert.Equal(t, model.PayoutLineStatusSubmitted, payout.Lines[0].Status)
+	assert.NotEmpty(t, payout.Lines[0].FireblocksTxID)
+	assert.Equal(t, model.PayoutLineStatusFailed, payout.Lines[1].Status)
+	assert.Equal(t, "Rejected by Fireblocks", payout.Lines[1].Error)
+	assert.Equal(t, model.PayoutLineStatusSubmitted, payout.Lines[2].Status)
+
+	assert.Len(t, env.transfers.Transfers, 2)
+	transfer := env.transfers.Transfers[payout.Lines[2].FireblocksTxID]
+	assert.Equal(t, "wallet-1", transfer.WalletID)
+	assert.Equal(t, "2.5", transfer.Amount)
+	assert.Equal(t, "0xemployee3", transfer.DestinationAddress)
+	assert.Equal(t, 2, env.balances.Invalidated)
+}
+
+func TestProcessFinalStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		amounts []string
+		want    model.PayoutStatus
+	}{
+		{name: "completed", amounts: []string{"1", "2"}, want: model.PayoutStatusCompleted},
+		{name: "failed", amounts: []string{"0", "0"}, want: model.PayoutStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			created := env.create(t, tt.amounts...)
+
+			assert.NoError(t, env.service.Process(context.Background(), created.ID))
+
+			assert.Equal(t, tt.want, env.get(t, created.ID).Status)
+		})
+	}
+}
+
+func TestProcessUnavailable(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, "1", "2")
+	env.unavailable.Store(1)
+
+	assert.NoError(t, env.service.Process(context.Background(), created.ID))
+
+	// the payout stops at the first line, which stays pending until the retry
+	payout := env.get(t, created.ID)
+	assert.Equal(t, model.PayoutStatusPending, payout.Status)
+	assert.Equal(t, model.PayoutLineStatusPending, payout.Lines[0].Status)
+	assert.Equal(t, "Fireblocks unavailable", payout.Lines[0].Error)
+	assert.Equal(t, 1, payout.Lines[0].Attempts)
+	assert.Zero(t, payout.Lines[1].Attempts)
+
+	env.service.ProcessPending(context.Background())
+
+	payout = env.get(t, created.ID)
+	assert.Equal(t, model.PayoutStatusCompleted, payout.Status)
+	assert.Equal(t, "", payout.Lines[0].Error)
+	assert.Equal(t, 2, payout.Lines[0].Attempts)
+}
+
+func TestProcessRetriesTransientRejections(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+	}{
+		{name: "rate_limited", statusCode: http.StatusTooManyRequests},
+		{name: "in_progress", statusCode: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			created := env.create(t, "1")
+			env.unavailableStatus = tt.statusCode
+			env.unavailable.Store(1)
+
+			assert.NoError(t, env.service.Process(context.Background(), created.ID))
+			assert.Equal(t, model.PayoutLineStatusPending, env.get(t, created.ID).Lines[0].Status)
+
+			env.service.ProcessPending(context.Background())
+			assert.Equal(t, model.PayoutStatusCompleted, env.get(t, created.ID).Status)
+		})
+	}
+}
+
+func TestProcessGivesUp(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, "1")
+	env.unavailable.Store(maxLineAttempts)
+
+	for range maxLineAttempts {
+		env.service.ProcessPending(context.Background())
+	}
+
+	payout := env.get(t, created.ID)
+	assert.Equal(t, model.PayoutStatusFailed, payout.Status)
+	assert.Equal(t, model.PayoutLineStatusFailed, payout.Lines[0].Status)
+	assert.Equal(t, maxLineAttempts, payout.Lines[0].Attempts)
+}
+
+func TestProcessGivesUpFindsTransaction(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, "1")
+	// the first attempt creates the transaction, the next ones do not reach Fireblocks
+	env.lost.Store(1)
+	env.unavailable.Store(maxLineAttempts - 1)
+
+	for range maxLineAttempts {
+		env.service.ProcessPending(context.Background())
+	}
+
+	// the transaction is found by the external ID of the line instead of failing it
+	payout := env.get(t, created.ID)
+	assert.Equal(t, model.PayoutStatusCompleted, payout.Status)
+	assert.Equal(t, model.PayoutLineStatusSubmitted, payout.Lines[0].Status)
+	assert.Empty(t, payout.Lines[0].Error)
+	assert.Equal(t, int32(1), env.created.Load())
+	assert.Contains(t, env.transfers.Transfers, payout.Lines[0].FireblocksTxID)
+}
+
+func TestProcessGivesUpLookupFails(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, "1")
+	env.unavailable.Store(maxLineAttempts)
+	env.lookupUnavailable.Store(true)
+
+	for range maxLineAttempts {
+		env.service.ProcessPending(context.Background())
+	}
+
+	// whether the transaction exists is unknown, the line stays pending
+	payout := env.get(t, created.ID)
+	assert.Equal(t, model.PayoutStatusPending, payout.Status)
+	assert.Equal(t, model.PayoutLineStatusPending, payout.Lines[0].Status)
+	assert.Equal(t, maxLineAttempts, payout.Lines[0].Attempts)
+
+	env.lookupUnavailable.Store(false)
+	env.service.ProcessPending(context.Background())
+	assert.Equal(t, model.PayoutStatusCompleted, env.get(t, created.ID).Status)
+}
+
+func TestProcessResubmitsWithSameKey(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, "1", "2")
+	env.repo.FailSaves = true
+
+	// the payout stops at the first line, whose outcome was lost
+	assert.Error(t, env.service.Process(context.Background(), created.ID))
+	assert.Equal(t, model.PayoutLineStatusPending, env.get(t, created.ID).Lines[0].Status)
+	env.repo.FailSaves = false
+
+	assert.NoError(t, env.service.Process(context.Background(), created.ID))
+
+	// Fireblocks answered the second submission of the first line with the transaction created by the first one
+	payout := env.get(t, created.ID)
+	assert.Equal(t, model.PayoutStatusCompleted, payout.Status)
+	assert.Equal(t, int32(3), env.created.Load())
+	assert.Len(t, env.transfers.Transfers, 2)
+	assert.Contains(t, env.transfers.Transfers, payout.Lines[0].FireblocksTxID)
+	assert.Contains(t, env.transfers.Transfers, payout.Lines[1].FireblocksTxID)
+}
+
+func TestProcessStopsBetweenLines(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, "1", "2")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, env.service.Process(ctx, created.ID), context.Canceled)
+
+	assert.Zero(t, env.created.Load())
+	assert.Equal(t, model.PayoutStatusPending, env.get(t, created.ID).Status)
+}
+
+func TestReserved(t *testing.T) {
+	env := newTestEnv(t)
+	env.create(t, "1", "2.25")
+	submitted := env.create(t, "4")
+	assert.NoError(t, env.service.Process(context.Background(), submitted.ID))
+
+	reserved, err := env.service.Reserved(context.Background(), "wallet-1", "ETH_TEST5")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "13/4", reserved.String())
+}
+
+func TestReserve(t *testing.T) {
+	env := newTestEnv(t)
+	env.create(t, "1.5")
+	<-env.service.queue
+
+	var reserved *big.Rat
+	var created *model.Payout
+	err := env.service.Reserve(context.Background(), "wallet-1", "ETH_TEST5", func(ctx context.Context, amount *big.Rat) error {
+		reserved = amount
+		created = &model.Payout{WalletID: "wallet-1", VaultAccountID: env.vault, AssetID: "ETH_TEST5", Total: "2",
+			Lines: []model.PayoutLine{{DestinationAddress: "0xemployee1", Amount: "2"}}}
+		if err := env.service.Create(ctx, created); err != nil {
+			return err
+		}
+		// the payout is queued once it is committed
+		assert.Empty(t, env.service.queue)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "3/2", reserved.String())
+	assert.Equal(t, created.ID, <-env.service.queue)
+
+	t.Run("failed", func(t *testing.T) {
+		err := env.service.Reserve(context.Background(), "wallet-1", "ETH_TEST5", func(ctx context.Context, amount *big.Rat) error {
+			assert.Equal(t, "7/2", amount.String())
+			return assert.AnError
+		})
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Empty(t, env.service.queue)
+	})
+}
+
+func TestHold(t *testing.T) {
+	env := newTestEnv(t)
+	env.create(t, "1")
+	ctx := context.Background()
+
+	var holdID string
+	err := env.service.Reserve(ctx, "wallet-1", "ETH_TEST5", func(ctx context.Context, reserved *big.Rat) error {
+		var err error
+		holdID, err = env.service.Hold(ctx, "wallet-1", "ETH_TEST5", big.NewRat(1, 2))
+		return err
+	})
+	assert.NoError(t, err)
+
+	// held once the lock is released, until the hold is
+	reserved, err := env.service.Reserved(ctx, "wallet-1", "ETH_TEST5")
+	assert.NoError(t, err)
+	assert.Equal(t, "3/2", reserved.String())
+	reserved, err = env.service.Reserved(ctx, "wallet-1", "BTC_TEST")
+	assert.NoError(t, err)
+	assert.Zero(t, reserved.Sign())
+
+	assert.NoError(t, env.service.Release(ctx, holdID))
+	reserved, err = env.service.Reserved(ctx, "wallet-1", "ETH_TEST5")
+	assert.NoError(t, err)
+	assert.Equal(t, "1/1", reserved.String())
+}
+
+func TestRun(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		env.service.Run(ctx)
+		close(done)
+	}()
+
+	created := env.create(t, "1")
+
+	assert.Eventually(t, func() bool {
+		return env.created.Load() == 1
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+	assert.Equal(t, model.PayoutStatusCompleted, env.get(t, created.ID).Status)
+}
+
+func TestRunNotLeader(t *testing.T) {
+	env := newTestEnv(t)
+	env.service.elector = StaticElector(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		env.service.Run(ctx)
+		close(done)
+	}()
+
+	created := env.create(t, "1")
+
+	// the payout is left to the leader
+	assert.Eventually(t, func() bool {
+		return len(env.service.queue) == 0
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+	assert.Zero(t, env.created.Load())
+	assert.Equal(t, model.PayoutStatusPending, env.get(t, created.ID).Status)
+}
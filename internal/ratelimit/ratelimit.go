@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/apikey"
+	"firego-wallet-service/internal/logging"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request classes, each with its own limit
+const (
+	Read     = "read"
+	Write    = "write"
+	Transfer = "transfer"
+)
+
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+	StoreRedis    = "redis"
+)
+
+const keyPrefix = "firego:ratelimit:"
+
+// Store counts the requests of a window. Implementations are safe for concurrent use and may be shared by the
+// instances of the service.
+type Store interface {
+	// Increment adds one to the counter of key and returns its new value. The counter may be dropped once expired.
+	Increment(ctx context.Context, key string, expiresAt time.Time) (int64, error)
+}
+
+// Limit allows Requests per Window, counted in fixed windows. Zero requests disables it.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+type Config struct {
+	// IPLimits limit the requests of each client IP, by request class
+	IPLimits map[string]Limit
+	// KeyLimits limit the requests of each verified API key across the IPs it is used from, by request class. They are
+	// separate from IPLimits, which the keys of the clients behind the same NAT or proxy share.
+	KeyLimits map[string]Limit
+	// TrustForwardedFor takes the client IP from the last X-Forwarded-For entry, as set by a reverse proxy.
+	// It must only be enabled behind one, as clients can set the header.
+	TrustForwardedFor bool
+}
+
+type Limiter struct {
+	store  Store
+	config Config
+	now    func() time.Time
+}
+
+func NewLimiter(store Store, config Config) *Limiter {
+	return &Limiter{
+		store:  store,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// Wrap limits the requests to handler with the IP limit of class, counted per client IP. The RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers are set on every response, and requests over the limit are
+// answered 429 with a Retry-After header. Requests are let through if the store fails.
+func (l *Limiter) Wrap(class string, handler http.HandlerFunc) http.HandlerFunc {
+	limit := l.config.IPLimits[class]
+	if limit.Requests <= 0 {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if l.allow(w, r, class, limit, "ip:"+l.clientIP(r)) {
+			handler(w, r)
+		}
+	}
+}
+
+// WrapKey is Wrap with the key limit of class counted per API key, for the handlers behind apikey.Wrap: only keys it verified are counted, so
+// that made-up keys cannot get limits of their own. Requests without one are let through, as counted by Wrap. The
+// headers are left to Wrap unless fewer requests remain for the key.
+func (l *Limiter) WrapKey(class string, handler http.HandlerFunc) http.HandlerFunc {
+	limit := l.config.KeyLimits[class]
+	if limit.Requests <= 0 {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey, ok := apikey.FromContext(r.Context())
+		if !ok || l.allow(w, r, class, limit, "key:"+apiKey.ID) {
+			handler(w, r)
+		}
+	}
+}
+
+// allow counts the request of class for client and tells whether it is within limit, answering it 429 otherwise
+func (l *Limiter) allow(w http.ResponseWriter, r *http.Request, class string, limit Limit, client string) bool {
+	now := l.now()
+	windowStart := now.Truncate(limit.Window)
+	windowEnd := windowStart.Add(limit.Window)
+	key := fmt.Sprintf("%s%s:%s:%d", keyPrefix, class, client, windowStart.Unix())
+
+	count, err := l.store.Increment(r.Context(), key, windowEnd)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Failed to count request, letting it through", "class", class, "error", err)
+		return true
+	}
+
+	// the reset is rounded up, so that clients waiting for it land in the next window
+	reset := strconv.Itoa(int((windowEnd.Sub(now) + time.Second - 1) / time.Second))
+	remaining := max(int64(limit.Requests)-count, 0)
+	if previous, err := strconv.ParseInt(w.Header().Get("RateLimit-Remaining"), 10, 64); err != nil || remaining <= previous {
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		w.Header().Set("RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		w.Header().Set("RateLimit-Reset", reset)
+	}
+
+	if count > int64(limit.Requests) {
+		w.Header().Set("Retry-After", reset)
+		apierror.Write(w, r, http.StatusTooManyRequests, apierror.RateLimited, "Too many requests")
+		return false
+	}
+	return true
+}
+
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.config.TrustForwardedFor {
+		if forwardedFor := r.Header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+			entries := strings.Split(forwardedFor[len(forwardedFor)-1], ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,270 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/apikey"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type FailingStore struct{}
+
+func (FailingStore) Increment(_ context.Context, _ string, _ time.Time) (int64, error) {
+	return 0, errors.New("store unavailable")
+}
+
+func okHandler(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func newTestLimiter(store Store, config Config, now *time.Time) *Limiter {
+	limiter := NewLimiter(store, config)
+	limiter.now = func() time.Time { return *now }
+	return limiter
+}
+
+func TestWrap(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 15, 0, time.UTC)
+	limiter := newTestLimiter(NewMemoryStore(), Config{IPLimits: map[string]Limit{
+		Read:     {Requests: 2, Window: time.Minute},
+		Transfer: {Requests: 1, Window: time.Minute},
+	}}, &now)
+	read := limiter.Wrap(Read, okHandler)
+	transfer := limiter.Wrap(Transfer, okHandler)
+
+	serve := func(handler http.HandlerFunc, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/wallets/1/assets/BTC_TEST/balance", nil)
+		req.RemoteAddr = remoteAddr
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+		return recorder
+	}
+
+	recorder := serve(read, "192.0.2.1:1234")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "2", recorder.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", recorder.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "45", recorder.Header().Get("RateLimit-Reset"))
+
+	assert.Equal(t, http.StatusOK, serve(read, "192.0.2.1:1235").Code)
+
+	recorder = serve(read, "192.0.2.1:1236")
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "0", recorder.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "45", recorder.Header().Get("Retry-After"))
+
+	// classes and clients are limited separately
+	assert.Equal(t, http.StatusOK, serve(transfer, "192.0.2.1:1237").Code)
+	assert.Equal(t, http.StatusOK, serve(read, "192.0.2.2:1234").Code)
+
+	// the next window starts over
+	now = now.Add(45 * time.Second)
+	assert.Equal(t, http.StatusOK, serve(read, "192.0.2.1:1238").Code)
+}
+
+func TestWrapClient(t *testing.T) {
+	tests := []struct {
+		name              string
+		trustForwardedFor bool
+		first             map[string]string
+		second            map[string]string
+		sameClient        bool
+	}{
+		{
+			name:       "api_keys_from_the_same_ip",
+			first:      map[string]string{apikey.Header: "fgw_made-up-1"},
+			second:     map[string]string{apikey.Header: "fgw_made-up-2"},
+			sameClient: true,
+		},
+		{
+			name:       "forwarded_for_ignored_by_default",
+			first:      map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			second:     map[string]string{"X-Forwarded-For": "198.51.100.2"},
+			sameClient: true,
+		},
+		{
+			name:              "forwarded_for_trusted",
+			trustForwardedFor: true,
+			first:             map[string]string{"X-Forwarded-For": "203.0.113.9, 198.51.100.1"},
+			second:            map[string]string{"X-Forwarded-For": "198.51.100.2"},
+			sameClient:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			limiter := newTestLimiter(NewMemoryStore(), Config{
+				IPLimits:          map[string]Limit{Read: {Requests: 1, Window: time.Minute}},
+				TrustForwardedFor: tt.trustForwardedFor,
+			}, &now)
+			handler := limiter.Wrap(Read, okHandler)
+
+			codes := make([]int, 0, 2)
+			for _, headers := range []map[string]string{tt.first, tt.second} {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				for key, value := range headers {
+					req.Header.Set(key, value)
+				}
+				recorder := httptest.NewRecorder()
+				handler(recorder, req)
+				codes = append(codes, recorder.Code)
+			}
+
+			if tt.sameClient {
+				assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests}, codes)
+			} else {
+				assert.Equal(t, []int{http.StatusOK, http.StatusOK}, codes)
+			}
+		})
+	}
+}
+
+func TestWrapKey(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(NewMemoryStore(), Config{
+		IPLimits:  map[string]Limit{Read: {Requests: 2, Window: time.Minute}},
+		KeyLimits: map[string]Limit{Read: {Requests: 2, Window: time.Minute}},
+	}, &now)
+	handler := limiter.Wrap(Read, limiter.WrapKey(Read, okHandler))
+
+	serve := func(key *model.APIKey, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		if key != nil {
+			req = req.WithContext(apikey.NewContext(req.Context(), key))
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+		return recorder
+	}
+	key := &model.APIKey{ID: "key-1"}
+
+	// a key is limited across the IPs it is used from, the remaining requests being the lowest of the two counts
+	recorder := serve(key, "192.0.2.1:1234")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "1", recorder.Header().Get("RateLimit-Remaining"))
+	recorder = serve(key, "192.0.2.2:1234")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "0", recorder.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, http.StatusTooManyRequests, serve(key, "192.0.2.3:1234").Code)
+
+	// and an IP across the keys used from it
+	assert.Equal(t, http.StatusOK, serve(&model.APIKey{ID: "key-2"}, "192.0.2.1:1234").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(&model.APIKey{ID: "key-3"}, "192.0.2.1:1234").Code)
+
+	// requests without a verified key are only limited per IP
+	assert.Equal(t, http.StatusOK, serve(nil, "192.0.2.4:1234").Code)
+	assert.Equal(t, http.StatusOK, serve(nil, "192.0.2.4:1234").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(nil, "192.0.2.4:1234").Code)
+}
+
+func TestWrapKeySeparateLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		ipLimit  int
+		keyLimit int
+		// keys are the keys of the requests, all sent from the same IP
+		keys     []string
+		expected []int
+	}{
+		{
+			name:     "key_limit_binds_first",
+			ipLimit:  10,
+			keyLimit: 2,
+			keys:     []string{"key-1", "key-1", "key-1", "key-2"},
+			expected: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		{
+			name:     "ip_limit_binds_first",
+			ipLimit:  2,
+			keyLimit: 10,
+			keys:     []string{"key-1", "key-2", "key-3"},
+			expected: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:     "ip_limit_only",
+			ipLimit:  3,
+			keys:     []string{"key-1", "key-1", "key-1", "key-1"},
+			expected: []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:     "key_limit_only",
+			keyLimit: 1,
+			keys:     []string{"key-1", "key-2", "key-1"},
+			expected: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			limiter := newTestLimiter(NewMemoryStore(), Config{
+				IPLimits:  map[string]Limit{Read: {Requests: tt.ipLimit, Window: time.Minute}},
+				KeyLimits: map[string]Limit{Read: {Requests: tt.keyLimit, Window: time.Minute}},
+			}, &now)
+			handler := limiter.Wrap(Read, limiter.WrapKey(Read, okHandler))
+
+			codes := make([]int, 0, len(tt.keys))
+			for _, key := range tt.keys {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				req = req.WithContext(apikey.NewContext(req.Context(), &model.APIKey{ID: key}))
+				recorder := httptest.NewRecorder()
+				handler(recorder, req)
+				codes = append(codes, recorder.Code)
+				if recorder.Code == http.StatusTooManyRequests {
+					// the headers are those of the limit reached
+					assert.Equal(t, "0", recorder.Header().Get("RateLimit-Remaining"))
+				}
+			}
+
+			assert.Equal(t, tt.expected, codes)
+		})
+	}
+}
+
+func TestWrapStoreFailure(t *testing.T) {
+	now := time.Now()
+	limiter := newTestLimiter(FailingStore{}, Config{IPLimits: map[string]Limit{Read: {Requests: 1, Window: time.Minute}}}, &now)
+	handler := limiter.Wrap(Read, okHandler)
+
+	for range 2 {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestWrapDisabled(t *testing.T) {
+	limiter := NewLimiter(FailingStore{}, Config{IPLimits: map[string]Limit{Read: {Requests: 0, Window: time.Minute}}})
+	handler := limiter.Wrap(Read, okHandler)
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Empty(t, recorder.Header().Get("RateLimit-Limit"))
+}
+
+func TestMemoryStoreCleanup(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	count, err := store.Increment(ctx, "a", now.Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	now = now.Add(cleanupInterval)
+	count, err = store.Increment(ctx, "b", now.Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.Len(t, store.counters, 1)
+}
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"firego-wallet-service/internal/cache"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// cleanupInterval is how often the expired counters are dropped
+const cleanupInterval = time.Minute
+
+type memoryCounter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// MemoryStore counts the requests in the process, each instance of the service has its own limits
+type MemoryStore struct {
+	mu          sync.Mutex
+	counters    map[string]memoryCounter
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters: map[string]memoryCounter{},
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryStore) Increment(_ context.Context, key string, expiresAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastCleanup) >= cleanupInterval {
+		for k, counter := range s.counters {
+			if !now.Before(counter.expiresAt) {
+				delete(s.counters, k)
+			}
+		}
+		s.lastCleanup = now
+	}
+
+	counter := s.counters[key]
+	counter.count++
+	counter.expiresAt = expiresAt
+	s.counters[key] = counter
+	return counter.count, nil
+}
+
+// RedisStore counts the requests in Redis, shared by the instances of the service
+type RedisStore struct {
+	redis *cache.RedisStore
+}
+
+func NewRedisStore(redis *cache.RedisStore) *RedisStore {
+	return &RedisStore{redis: redis}
+}
+
+func (s *RedisStore) Increment(ctx context.Context, key string, expiresAt time.Time) (int64, error) {
+	return s.redis.Increment(ctx, key, time.Until(expiresAt))
+}
+
+// PostgresStore counts the requests in the rate_limit_counters table, shared by the instances of the service
+type PostgresStore struct {
+	db *sql.DB
+
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Increment(ctx context.Context, key string, expiresAt time.Time) (int64, error) {
+	s.cleanup(ctx)
+
+	var count int64
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO rate_limit_counters (key, count, expires_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET count = rate_limit_counters.count + 1
+		RETURNING count`, key, expiresAt).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count request: %w", err)
+	}
+	return count, nil
+}
+
+// cleanup drops the expired counters, at most once per cleanupInterval per instance
+func (s *PostgresStore) cleanup(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.lastCleanup) < cleanupInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastCleanup = time.Now()
+	s.mu.Unlock()
+
+	// a failed cleanup is retried on the next interval, the counters are keyed by window anyway
+	s.db.ExecContext(ctx, "DELETE FROM rate_limit_counters WHERE expires_at < now()")
+}
@@ -0,0 +1,114 @@
+//go:build integration
+
+package ratelimit
+
+import (
+	"context"
+	"firego-wallet-service/internal/testdb"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+var postgres *testdb.Server
+
+func TestMain(m *testing.M) {
+	var err error
+	postgres, err = testdb.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start Postgres: %v\n", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	postgres.Stop()
+	os.Exit(code)
+}
+
+func TestPostgresStoreIncrement(t *testing.T) {
+	sqlDB, err := postgres.NewMigratedDatabase(t).DB()
+	if err != nil {
+		t.Fatalf("failed to get database handle: %v", err)
+	}
+	store := NewPostgresStore(sqlDB)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Minute)
+
+	for want := int64(1); want <= 3; want++ {
+		count, err := store.Increment(ctx, "client:1", expiresAt)
+		assert.NoError(t, err)
+		assert.Equal(t, want, count)
+	}
+
+	count, err := store.Increment(ctx, "client:2", expiresAt)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestPostgresStoreConcurrentIncrement(t *testing.T) {
+	sqlDB, err := postgres.NewMigratedDatabase(t).DB()
+	if err != nil {
+		t.Fatalf("failed to get database handle: %v", err)
+	}
+	// instances of the service share the counters
+	stores := []*PostgresStore{NewPostgresStore(sqlDB), NewPostgresStore(sqlDB)}
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Minute)
+
+	const requests = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var counts []int
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			count, err := stores[i%len(stores)].Increment(ctx, "client", expiresAt)
+			assert.NoError(t, err)
+			mu.Lock()
+			counts = append(counts, int(count))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// each request got its own count
+	sort.Ints(counts)
+	want := make([]int, requests)
+	for i := range want {
+		want[i] = i + 1
+	}
+	assert.Equal(t, want, counts)
+}
+
+func TestPostgresStoreCleanup(t *testing.T) {
+	sqlDB, err := postgres.NewMigratedDatabase(t).DB()
+	if err != nil {
+		t.Fatalf("failed to get database handle: %v", err)
+	}
+	ctx := context.Background()
+
+	store := NewPostgresStore(sqlDB)
+	_, err = store.Increment(ctx, "expired", time.Now().Add(-time.Second))
+	assert.NoError(t, err)
+	_, err = store.Increment(ctx, "current", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+
+	// a new instance drops the expired counters on its first increment
+	_, err = NewPostgresStore(sqlDB).Increment(ctx, "other", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+
+	var keys []string
+	rows, err := sqlDB.QueryContext(ctx, "SELECT key FROM rate_limit_counters ORDER BY key")
+	assert.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		assert.NoError(t, rows.Scan(&key))
+		keys = append(keys, key)
+	}
+	assert.Equal(t, []string{"current", "other"}, keys)
+}
@@ -0,0 +1,245 @@
+package reconcile
+
+import (
+	"context"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/model"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+)
+
+// vaultAccountsPageSize is the largest page size accepted by the Fireblocks accounts_paged endpoint
+const vaultAccountsPageSize = 500
+
+type WalletRepository interface {
+	List(ctx context.Context) ([]model.Wallet, error)
+}
+
+type TransferRepository interface {
+	ListCreatedSince(since time.Time) ([]model.Transfer, error)
+}
+
+type LedgerRepository interface {
+	ListWalletAssetBalances() ([]model.WalletAssetBalance, error)
+}
+
+type ReportRepository interface {
+	Create(report *model.ReconciliationReport) error
+}
+
+type FireblocksClient interface {
+	ListVaultAccounts(ctx context.Context, page fireblocks.PageRequest) (*fireblocks.ListVaultAccountsResponse, int, error)
+	GetVaultAccountAssetBalance(ctx context.Context, vaultAccountID, assetID string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error)
+	GetTransaction(ctx context.Context, txID string) (*fireblocks.TransactionResponse, int, error)
+}
+
+type Config struct {
+	// TransferWindow bounds how far back local transfers are compared against Fireblocks
+	TransferWindow time.Duration
+	// IgnoredVaults are vault accounts not managed by this service, e.g. treasury vaults, which are never reported as orphaned
+	IgnoredVaults []string
+}
+
+// Reconciler compares local records with Fireblocks and stores a report of every discrepancy found
+type Reconciler struct {
+	walletRepo       WalletRepository
+	transferRepo     TransferRepository
+	ledgerRepo       LedgerRepository
+	reportRepo       ReportRepository
+	fireblocksClient FireblocksClient
+	config           Config
+}
+
+func NewReconciler(walletRepo WalletRepository, transferRepo TransferRepository, ledgerRepo LedgerRepository, reportRepo ReportRepository, fireblocksClient FireblocksClient, config Config) *Reconciler {
+	return &Reconciler{
+		walletRepo:       walletRepo,
+		transferRepo:     transferRepo,
+		ledgerRepo:       ledgerRepo,
+		reportRepo:       reportRepo,
+		fireblocksClient: fireblocksClient,
+		config:           config,
+	}
+}
+
+// Run performs a full reconciliation and stores its report. A report is stored even if the run fails part way,
+// with the issues found until then.
+func (r *Reconciler) Run(ctx context.Context) (*model.ReconciliationReport, error) {
+	report := &model.ReconciliationReport{
+		Status:    model.ReconciliationStatusCompleted,
+		StartedAt: time.Now(),
+	}
+
+	runErr := r.reconcile(ctx, report)
+	if runErr != nil {
+		report.Status = model.ReconciliationStatusFailed
+		report.Error = runErr.Error()
+	}
+	report.IssueCount = len(report.Issues)
+	report.FinishedAt = time.Now()
+
+	if err := r.reportRepo.Create(report); err != nil {
+		return nil, fmt.Errorf("failed to store reconciliation report: %w", err)
+	}
+
+	slog.Info("Reconciliation finished", "report_id", report.ID, "status", report.Status, "issues", report.IssueCount)
+	return report, runErr
+}
+
+func (r *Reconciler) reconcile(ctx context.Context, report *model.ReconciliationReport) error {
+	wallets, err := r.walletRepo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list wallets: %w", err)
+	}
+
+	if err = r.reconcileVaults(ctx, report, wallets); err != nil {
+		return err
+	}
+	if err = r.reconcileTransfers(ctx, report); err != nil {
+		return err
+	}
+	return r.reconcileBalances(ctx, report, wallets)
+}
+
+// reconcileVaults flags wallets whose vault account is gone and vault accounts that no wallet points to,
+// the latter typically left behind when storing a wallet failed after its vault was created
+func (r *Reconciler) reconcileVaults(ctx context.Context, report *model.ReconciliationReport, wallets []model.Wallet) error {
+	vaults := map[string]fireblocks.VaultAccount{}
+	page := fireblocks.PageRequest{Limit: vaultAccountsPageSize}
+	for {
+		resp, _, err := r.fireblocksClient.ListVaultAccounts(ctx, page)
+		if err != nil {
+			return fmt.Errorf("failed to list vault accounts: %w", err)
+		}
+		for _, vault := range resp.Accounts {
+			vaults[vault.ID] = vault
+		}
+		if resp.Paging.After == "" {
+			break
+		}
+		page.After = resp.Paging.After
+	}
+
+	walletsByVault := map[string]bool{}
+	for _, wallet := range wallets {
+		walletsByVault[wallet.VaultAccountID] = true
+		if _, ok := vaults[wallet.VaultAccountID]; !ok {
+			addIssue(report, model.ReconciliationIssue{
+				Kind:           model.ReconciliationIssueMissingVault,
+				WalletID:       wallet.ID,
+				VaultAccountID: wallet.VaultAccountID,
+				Details:        fmt.Sprintf("vault account of wallet %q does not exist in Fireblocks", wallet.Name),
+			})
+		}
+	}
+
+	ignored := map[string]bool{}
+	for _, id := range r.config.IgnoredVaults {
+		ignored[id] = true
+	}
+	for id, vault := range vaults {
+		if walletsByVault[id] || ignored[id] {
+			continue
+		}
+		addIssue(report, model.ReconciliationIssue{
+			Kind:           model.ReconciliationIssueOrphanedVault,
+			VaultAccountID: id,
+			Details:        fmt.Sprintf("vault account %q has no wallet", vault.Name),
+		})
+	}
+
+	return nil
+}
+
+func (r *Reconciler) reconcileTransfers(ctx context.Context, report *model.ReconciliationReport) error {
+	transfers, err := r.transferRepo.ListCreatedSince(time.Now().Add(-r.config.TransferWindow))
+	if err != nil {
+		return fmt.Errorf("failed to list transfers: %w", err)
+	}
+
+	for _, transfer := range transfers {
+		tx, _, err := r.fireblocksClient.GetTransaction(ctx, transfer.FireblocksTxID)
+		if err != nil {
+			addIssue(report, model.ReconciliationIssue{
+				Kind:      model.ReconciliationIssueCheckFailed,
+				WalletID:  transfer.WalletID,
+				AssetID:   transfer.AssetID,
+				Reference: transfer.FireblocksTxID,
+				Details:   fmt.Sprintf("failed to get transaction: %v", err),
+			})
+			continue
+		}
+
+		if tx.Status != transfer.Status {
+			addIssue(report, model.ReconciliationIssue{
+				Kind:      model.ReconciliationIssueStatusDrift,
+				WalletID:  transfer.WalletID,
+				AssetID:   transfer.AssetID,
+				Reference: transfer.FireblocksTxID,
+				Expected:  tx.Status,
+				Actual:    transfer.Status,
+				Details:   fmt.Sprintf("transfer %s is %s locally but %s in Fireblocks", transfer.ID, transfer.Status, tx.Status),
+			})
+		}
+	}
+
+	return nil
+}
+
+// reconcileBalances compares the ledger balance of every booked wallet asset with the Fireblocks total.
+// Transfers are booked on completion, so transfers still in flight show up as transient mismatches.
+func (r *Reconciler) reconcileBalances(ctx context.Context, report *model.ReconciliationReport, wallets []model.Wallet) error {
+	balances, err := r.ledgerRepo.ListWalletAssetBalances()
+	if err != nil {
+		return fmt.Errorf("failed to list ledger balances: %w", err)
+	}
+
+	vaultsByWallet := map[string]string{}
+	for _, wallet := range wallets {
+		vaultsByWallet[wallet.ID] = wallet.VaultAccountID
+	}
+
+	for _, balance := range balances {
+		vaultAccountID, ok := vaultsByWallet[balance.WalletID]
+		if !ok {
+			continue
+		}
+
+		fbResp, _, err := r.fireblocksClient.GetVaultAccountAssetBalance(ctx, vaultAccountID, balance.AssetID)
+		if err != nil {
+			addIssue(report, model.ReconciliationIssue{
+				Kind:           model.ReconciliationIssueCheckFailed,
+				WalletID:       balance.WalletID,
+				VaultAccountID: vaultAccountID,
+				AssetID:        balance.AssetID,
+				Details:        fmt.Sprintf("failed to get balance: %v", err),
+			})
+			continue
+		}
+
+		if !sameAmount(balance.Balance, fbResp.Total) {
+			addIssue(report, model.ReconciliationIssue{
+				Kind:           model.ReconciliationIssueBalanceMismatch,
+				WalletID:       balance.WalletID,
+				VaultAccountID: vaultAccountID,
+				AssetID:        balance.AssetID,
+				Expected:       fbResp.Total,
+				Actual:         balance.Balance,
+				Details:        "ledger balance differs from the Fireblocks total",
+			})
+		}
+	}
+
+	return nil
+}
+
+func sameAmount(a, b string) bool {
+	x, okX := new(big.Rat).SetString(a)
+	y, okY := new(big.Rat).SetString(b)
+	return okX && okY && x.Cmp(y) == 0
+}
+
+func addIssue(report *model.ReconciliationReport, issue model.ReconciliationIssue) {
+	report.Issues = append(report.Issues, issue)
+}
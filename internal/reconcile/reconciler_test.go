@@ -0,0 +1,194 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type MockWalletRepository struct {
+	Wallets []model.Wallet
+	Error   error
+}
+
+func (m *MockWalletRepository) List(_ context.Context) ([]model.Wallet, error) {
+	return m.Wallets, m.Error
+}
+
+type MockTransferRepository struct {
+	Transfers []model.Transfer
+}
+
+func (m *MockTransferRepository) ListCreatedSince(since time.Time) ([]model.Transfer, error) {
+	return m.Transfers, nil
+}
+
+type MockLedgerRepository struct {
+	Balances []model.WalletAssetBalance
+}
+
+func (m *MockLedgerRepository) ListWalletAssetBalances() ([]model.WalletAssetBalance, error) {
+	return m.Balances, nil
+}
+
+type MockReportRepository struct {
+	Reports []*model.ReconciliationReport
+}
+
+func (m *MockReportRepository) Create(report *model.ReconciliationReport) error {
+	report.ID = "report-1"
+	m.Reports = append(m.Reports, report)
+	return nil
+}
+
+type MockFireblocksClient struct {
+	Pages        []fireblocks.ListVaultAccountsResponse
+	ListError    error
+	Balances     map[string]string
+	Transactions map[string]fireblocks.TransactionResponse
+	ReceivedPage []fireblocks.PageRequest
+}
+
+func (m *MockFireblocksClient) ListVaultAccounts(_ context.Context, page fireblocks.PageRequest) (*fireblocks.ListVaultAccountsResponse, int, error) {
+	if m.ListError != nil {
+		return nil, 500, m.ListError
+	}
+	m.ReceivedPage = append(m.ReceivedPage, page)
+	resp := m.Pages[len(m.ReceivedPage)-1]
+	return &resp, 200, nil
+}
+
+func (m *MockFireblocksClient) GetVaultAccountAssetBalance(_ context.Context, vaultAccountID, assetID string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error) {
+	total, ok := m.Balances[vaultAccountID+"/"+assetID]
+	if !ok {
+		return nil, 404, errors.New("not found")
+	}
+	return &fireblocks.GetVaultAccountAssetBalanceResponse{ID: assetID, Total: total}, 200, nil
+}
+
+func (m *MockFireblocksClient) GetTransaction(_ context.Context, txID string) (*fireblocks.TransactionResponse, int, error) {
+	tx, ok := m.Transactions[txID]
+	if !ok {
+		return nil, 404, errors.New("not found")
+	}
+	return &tx, 200, nil
+}
+
+func TestRun(t *testing.T) {
+	wallets := []model.Wallet{
+		{ID: "wallet-1", Name: "Alice", VaultAccountID: "1"},
+		{ID: "wallet-2", Name: "Bob", VaultAccountID: "2"},
+	}
+
+	tests := []struct {
+		name         string
+		walletRepo   *MockWalletRepository
+		transferRepo *MockTransferRepository
+		ledgerRepo   *MockLedgerRepository
+		client       *MockFireblocksClient
+		config       Config
+		wantErr      bool
+		assert       func(t *testing.T, report *model.ReconciliationReport, client *MockFireblocksClient)
+	}{
+		{
+			name:         "no_discrepancies",
+			walletRepo:   &MockWalletRepository{Wallets: wallets},
+			transferRepo: &MockTransferRepository{Transfers: []model.Transfer{{ID: "t-1", WalletID: "wallet-1", FireblocksTxID: "tx-1", Status: fireblocks.TransactionStatusCompleted}}},
+			ledgerRepo:   &MockLedgerRepository{Balances: []model.WalletAssetBalance{{WalletID: "wallet-1", AssetID: "BTC_TEST", Balance: "0.500000000000000000"}}},
+			client: &MockFireblocksClient{
+				Pages: []fireblocks.ListVaultAccountsResponse{
+					{Accounts: []fireblocks.VaultAccount{{ID: "1"}}, Paging: fireblocks.Paging{After: "next"}},
+					{Accounts: []fireblocks.VaultAccount{{ID: "2"}}},
+				},
+				Balances:     map[string]string{"1/BTC_TEST": "0.5"},
+				Transactions: map[string]fireblocks.TransactionResponse{"tx-1": {ID: "tx-1", Status: fireblocks.TransactionStatusCompleted}},
+			},
+			assert: func(t *testing.T, report *model.ReconciliationReport, client *MockFireblocksClient) {
+				assert.Equal(t, model.ReconciliationStatusCompleted, report.Status)
+				assert.Equal(t, 0, report.IssueCount)
+				assert.Len(t, client.ReceivedPage, 2)
+				assert.Equal(t, "next", client.ReceivedPage[1].After)
+				assert.Equal(t, vaultAccountsPageSize, client.ReceivedPage[1].Limit)
+			},
+		},
+		{
+			name:         "missing_and_orphaned_vaults",
+			walletRepo:   &MockWalletRepository{Wallets: wallets},
+			transferRepo: &MockTransferRepository{},
+			ledgerRepo:   &MockLedgerRepository{},
+			client: &MockFireblocksClient{
+				Pages: []fireblocks.ListVaultAccountsResponse{
+					{Accounts: []fireblocks.VaultAccount{{ID: "1"}, {ID: "3", Name: "Leftover"}, {ID: "0", Name: "Treasury"}}},
+				},
+			},
+			config: Config{IgnoredVaults: []string{"0"}},
+			assert: func(t *testing.T, report *model.ReconciliationReport, client *MockFireblocksClient) {
+				assert.Equal(t, model.ReconciliationStatusCompleted, report.Status)
+				assert.Equal(t, 2, report.IssueCount)
+				assert.Equal(t, model.ReconciliationIssueMissingVault, report.Issues[0].Kind)
+				assert.Equal(t, "wallet-2", report.Issues[0].WalletID)
+				assert.Equal(t, model.ReconciliationIssueOrphanedVault, report.Issues[1].Kind)
+				assert.Equal(t, "3", report.Issues[1].VaultAccountID)
+			},
+		},
+		{
+			name:       "status_drift_and_balance_mismatch",
+			walletRepo: &MockWalletRepository{Wallets: wallets[:1]},
+			transferRepo: &MockTransferRepository{Transfers: []model.Transfer{
+				{ID: "t-1", WalletID: "wallet-1", FireblocksTxID: "tx-1", Status: fireblocks.TransactionStatusBroadcasting},
+				{ID: "t-2", WalletID: "wallet-1", FireblocksTxID: "tx-unknown", Status: fireblocks.TransactionStatusSubmitted},
+			}},
+			ledgerRepo: &MockLedgerRepository{Balances: []model.WalletAssetBalance{{WalletID: "wallet-1", AssetID: "BTC_TEST", Balance: "0.4"}}},
+			client: &MockFireblocksClient{
+				Pages:        []fireblocks.ListVaultAccountsResponse{{Accounts: []fireblocks.VaultAccount{{ID: "1"}}}},
+				Balances:     map[string]string{"1/BTC_TEST": "0.5"},
+				Transactions: map[string]fireblocks.TransactionResponse{"tx-1": {ID: "tx-1", Status: fireblocks.TransactionStatusCompleted}},
+			},
+			assert: func(t *testing.T, report *model.ReconciliationReport, client *MockFireblocksClient) {
+				assert.Equal(t, 3, report.IssueCount)
+				assert.Equal(t, model.ReconciliationIssueStatusDrift, report.Issues[0].Kind)
+				assert.Equal(t, fireblocks.TransactionStatusCompleted, report.Issues[0].Expected)
+				assert.Equal(t, fireblocks.TransactionStatusBroadcasting, report.Issues[0].Actual)
+				assert.Equal(t, model.ReconciliationIssueCheckFailed, report.Issues[1].Kind)
+				assert.Equal(t, "tx-unknown", report.Issues[1].Reference)
+				assert.Equal(t, model.ReconciliationIssueBalanceMismatch, report.Issues[2].Kind)
+				assert.Equal(t, "0.5", report.Issues[2].Expected)
+				assert.Equal(t, "0.4", report.Issues[2].Actual)
+			},
+		},
+		{
+			name:         "fireblocks_unavailable",
+			walletRepo:   &MockWalletRepository{Wallets: wallets},
+			transferRepo: &MockTransferRepository{},
+			ledgerRepo:   &MockLedgerRepository{},
+			client:       &MockFireblocksClient{ListError: errors.New("connection refused")},
+			wantErr:      true,
+			assert: func(t *testing.T, report *model.ReconciliationReport, client *MockFireblocksClient) {
+				assert.Equal(t, model.ReconciliationStatusFailed, report.Status)
+				assert.Contains(t, report.Error, "connection refused")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reportRepo := &MockReportRepository{}
+			reconciler := NewReconciler(tt.walletRepo, tt.transferRepo, tt.ledgerRepo, reportRepo, tt.client, tt.config)
+
+			report, err := reconciler.Run(context.Background())
+
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Len(t, reportRepo.Reports, 1)
+			assert.Equal(t, "report-1", report.ID)
+			tt.assert(t, report, tt.client)
+		})
+	}
+}
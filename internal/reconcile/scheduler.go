@@ -0,0 +1,47 @@
+package reconcile
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Elector interface {
+	IsLeader(ctx context.Context) bool
+}
+
+// Scheduler runs the reconciler periodically on the elected leader only
+type Scheduler struct {
+	reconciler *Reconciler
+	elector    Elector
+	interval   time.Duration
+}
+
+func NewScheduler(reconciler *Reconciler, elector Elector, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		reconciler: reconciler,
+		elector:    elector,
+		interval:   interval,
+	}
+}
+
+// Run schedules reconciliations until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !s.elector.IsLeader(ctx) {
+			continue
+		}
+		if _, err := s.reconciler.Run(ctx); err != nil {
+			slog.Error("Reconciliation failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"firego-wallet-service/internal/model"
+	"gorm.io/gorm"
+	"time"
+)
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *apiKeyRepository {
+	return &apiKeyRepository{
+		db: db,
+	}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *model.APIKey) (err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.Create")
+	defer func() { endSpan(span, err) }()
+
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, hash string) (_ *model.APIKey, err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.GetByHash")
+	defer func() { endSpan(span, err) }()
+
+	var key model.APIKey
+	err = r.db.WithContext(ctx).Where("hash = ?", hash).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) List(ctx context.Context) (_ []model.APIKey, err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.List")
+	defer func() { endSpan(span, err) }()
+
+	var keys []model.APIKey
+	err = r.db.WithContext(ctx).Order("created_at").Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Revoke marks the key revoked at the given time, gorm.ErrRecordNotFound if it does not exist or was already revoked
+func (r *apiKeyRepository) Revoke(ctx context.Context, id string, revokedAt time.Time) (err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.Revoke")
+	defer func() { endSpan(span, err) }()
+
+	result := r.db.WithContext(ctx).Model(&model.APIKey{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
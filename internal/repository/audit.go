@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"firego-wallet-service/internal/audit"
+	"firego-wallet-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *auditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+// Append locks the table against concurrent appends, which would otherwise fork the chain,
+// while still letting the log be read
+func (r *auditRepository) Append(entry *model.AuditEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("LOCK TABLE audit_entries IN EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		var latest []model.AuditEntry
+		if err := tx.Order("sequence DESC").Limit(1).Find(&latest).Error; err != nil {
+			return err
+		}
+
+		var prev *model.AuditEntry
+		if len(latest) > 0 {
+			prev = &latest[0]
+		}
+		audit.Seal(entry, prev)
+
+		return tx.Create(entry).Error
+	})
+}
+
+func (r *auditRepository) List(filter audit.Filter) ([]model.AuditEntry, error) {
+	query := r.db.Model(&model.AuditEntry{})
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Target != "" {
+		query = query.Where("target = ?", filter.Target)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+	if filter.BeforeSequence > 0 {
+		query = query.Where("sequence < ?", filter.BeforeSequence)
+	}
+
+	var entries []model.AuditEntry
+	if err := query.Order("sequence DESC").Limit(filter.Limit).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *auditRepository) ListFromSequence(from int64, limit int) ([]model.AuditEntry, error) {
+	var entries []model.AuditEntry
+	err := r.db.Where("sequence >= ?", from).Order("sequence").Limit(limit).Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"firego-wallet-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type depositRepository struct {
+	db *gorm.DB
+}
+
+func NewDepositRepository(db *gorm.DB) *depositRepository {
+	return &depositRepository{
+		db: db,
+	}
+}
+
+// Save inserts the deposit, or updates it when it already has an ID
+func (r *depositRepository) Save(deposit *model.Deposit) error {
+	if deposit.ID == "" {
+		return r.db.Create(deposit).Error
+	}
+	return r.db.Save(deposit).Error
+}
+
+func (r *depositRepository) GetByFireblocksTxID(txID string) (*model.Deposit, error) {
+	var deposit model.Deposit
+	err := r.db.Where("fireblocks_tx_id = ?", txID).First(&deposit).Error
+	if err != nil {
+		return nil, err
+	}
+	return &deposit, nil
+}
+
+func (r *depositRepository) ListByWalletID(walletID string) ([]model.Deposit, error) {
+	var deposits []model.Deposit
+	err := r.db.Where("wallet_id = ?", walletID).Order("created_at DESC").Find(&deposits).Error
+	if err != nil {
+		return nil, err
+	}
+	return deposits, nil
+}
+
+func (r *depositRepository) ListPending() ([]model.Deposit, error) {
+	var deposits []model.Deposit
+	err := r.db.Where("status = ?", model.DepositStatusPending).Order("created_at").Find(&deposits).Error
+	if err != nil {
+		return nil, err
+	}
+	return deposits, nil
+}
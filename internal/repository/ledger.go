@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"errors"
+	"firego-wallet-service/internal/ledger"
+	"firego-wallet-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ledgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) *ledgerRepository {
+	return &ledgerRepository{
+		db: db,
+	}
+}
+
+func (r *ledgerRepository) GetOrCreateAccount(account *model.LedgerAccount) (*model.LedgerAccount, error) {
+	err := r.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "code"}}, DoNothing: true}).Create(account).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var existing model.LedgerAccount
+	if err = r.db.Where("code = ?", account.Code).First(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// CreateEntry writes the entry and its postings atomically. The listed accounts are locked for the duration
+// of the transaction and the entry is rolled back with ledger.ErrInsufficientFunds if any of them ends up negative.
+func (r *ledgerRepository) CreateEntry(entry *model.JournalEntry, nonNegativeAccountIDs []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(nonNegativeAccountIDs) > 0 {
+			var locked []model.LedgerAccount
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id IN ?", nonNegativeAccountIDs).
+				Order("id").
+				Find(&locked).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(entry).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ledger.ErrDuplicateEntry
+			}
+			return err
+		}
+
+		for _, accountID := range nonNegativeAccountIDs {
+			var negative bool
+			err := tx.Raw("SELECT COALESCE(SUM(amount), 0) < 0 FROM postings WHERE account_id = ?", accountID).
+				Scan(&negative).Error
+			if err != nil {
+				return err
+			}
+			if negative {
+				return ledger.ErrInsufficientFunds
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *ledgerRepository) ListAccountBalances(walletID, assetID string) ([]model.LedgerAccountBalance, error) {
+	var balances []model.LedgerAccountBalance
+	err := r.db.Raw(`SELECT a.*, COALESCE(SUM(p.amount), 0)::text AS balance
+		FROM ledger_accounts a
+		LEFT JOIN postings p ON p.account_id = a.id
+		WHERE a.wallet_id = ? AND a.asset_id = ?
+		GROUP BY a.id
+		ORDER BY a.sub_account`, walletID, assetID).
+		Scan(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+func (r *ledgerRepository) ListWalletAssetBalances() ([]model.WalletAssetBalance, error) {
+	var balances []model.WalletAssetBalance
+	err := r.db.Raw(`SELECT a.wallet_id, a.asset_id, COALESCE(SUM(p.amount), 0)::text AS balance
+		FROM ledger_accounts a
+		LEFT JOIN postings p ON p.account_id = a.id
+		WHERE a.wallet_id IS NOT NULL
+		GROUP BY a.wallet_id, a.asset_id
+		ORDER BY a.wallet_id, a.asset_id`).
+		Scan(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+func (r *ledgerRepository) ListEntries(walletID string, limit int) ([]model.JournalEntry, error) {
+	walletEntryIDs := r.db.Table("postings").
+		Select("postings.journal_entry_id").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = postings.account_id").
+		Where("ledger_accounts.wallet_id = ?", walletID)
+
+	var entries []model.JournalEntry
+	err := r.db.Preload("Postings.Account").
+		Where("id IN (?)", walletEntryIDs).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,24 @@
+//go:build integration
+
+package repository
+
+import (
+	"firego-wallet-service/internal/testdb"
+	"fmt"
+	"os"
+	"testing"
+)
+
+var postgres *testdb.Server
+
+func TestMain(m *testing.M) {
+	var err error
+	postgres, err = testdb.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start Postgres: %v\n", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	postgres.Stop()
+	os.Exit(code)
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"firego-wallet-service/internal/model"
+	"gorm.io/gorm"
+	"time"
+)
+
+type payoutRepository struct {
+	db *gorm.DB
+}
+
+func NewPayoutRepository(db *gorm.DB) *payoutRepository {
+	return &payoutRepository{
+		db: db,
+	}
+}
+
+// LockWalletAsset runs fn in a transaction holding a lock on the wallet asset, so that the balance checks of its
+// payouts and transfers run one at a time, across the instances. The payouts created and the amounts read with the
+// context passed to fn are part of the transaction, and are visible to the next holder of the lock. Within the
+// transaction of ctx, e.g. a schedule being run, the lock is held until that transaction ends.
+func (r *payoutRepository) LockWalletAsset(ctx context.Context, walletID, assetID string, fn func(ctx context.Context) error) error {
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		// released with the transaction, whatever fn does
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "payout:"+walletID+":"+assetID).Error; err != nil {
+			return err
+		}
+		return fn(context.WithValue(ctx, txContextKey{}, tx))
+	})
+}
+
+// conn is the transaction of LockWalletAsset or LockDue when ctx comes from it, the pool otherwise
+func (r *payoutRepository) conn(ctx context.Context) *gorm.DB {
+	return conn(ctx, r.db)
+}
+
+// Create stores the payout together with its lines
+func (r *payoutRepository) Create(ctx context.Context, payout *model.Payout) (err error) {
+	ctx, span := startSpan(ctx, "PayoutRepository.Create")
+	defer func() { endSpan(span, err) }()
+
+	return r.conn(ctx).Create(payout).Error
+}
+
+func (r *payoutRepository) GetByID(ctx context.Context, id string) (_ *model.Payout, err error) {
+	ctx, span := startSpan(ctx, "PayoutRepository.GetByID")
+	defer func() { endSpan(span, err) }()
+
+	var payout model.Payout
+	err = r.db.WithContext(ctx).Preload("Lines", orderByLine).Where("id = ?", id).First(&payout).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payout, nil
+}
+
+// ListPending returns the payouts with lines left to submit, oldest first
+func (r *payoutRepository) ListPending(ctx context.Context) (_ []model.Payout, err error) {
+	ctx, span := startSpan(ctx, "PayoutRepository.ListPending")
+	defer func() { endSpan(span, err) }()
+
+	var payouts []model.Payout
+	err = r.db.WithContext(ctx).Preload("Lines", orderByLine).Where("status = ?", model.PayoutStatusPending).Order("created_at").Find(&payouts).Error
+	if err != nil {
+		return nil, err
+	}
+	return payouts, nil
+}
+
+// PendingAmount is the sum of the lines not submitted yet of the wallet asset payouts and of its holds that did not
+// expire, which the Fireblocks available balance does not account for
+func (r *payoutRepository) PendingAmount(ctx context.Context, walletID, assetID string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "PayoutRepository.PendingAmount")
+	defer func() { endSpan(span, err) }()
+
+	var amount string
+	err = r.conn(ctx).Raw(`
+		SELECT ((
+			SELECT COALESCE(SUM(payout_lines.amount::numeric), 0) FROM payout_lines
+			JOIN payouts ON payouts.id = payout_lines.payout_id
+			WHERE payouts.wallet_id = ? AND payouts.asset_id = ? AND payout_lines.status = ?
+		) + (
+			SELECT COALESCE(SUM(amount::numeric), 0) FROM balance_holds
+			WHERE wallet_id = ? AND asset_id = ? AND expires_at > now()
+		))::text`, walletID, assetID, model.PayoutLineStatusPending, walletID, assetID).
+		Scan(&amount).Error
+	if err != nil {
+		return "", err
+	}
+	return amount, nil
+}
+
+// CreateHold stores the hold, dropping the expired ones
+func (r *payoutRepository) CreateHold(ctx context.Context, hold *model.BalanceHold) (err error) {
+	ctx, span := startSpan(ctx, "PayoutRepository.CreateHold")
+	defer func() { endSpan(span, err) }()
+
+	if err = r.conn(ctx).Where("expires_at < now()").Delete(&model.BalanceHold{}).Error; err != nil {
+		return err
+	}
+	return r.conn(ctx).Create(hold).Error
+}
+
+func (r *payoutRepository) DeleteHold(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "PayoutRepository.DeleteHold")
+	defer func() { endSpan(span, err) }()
+
+	return r.conn(ctx).Where("id = ?", id).Delete(&model.BalanceHold{}).Error
+}
+
+func (r *payoutRepository) SaveLine(ctx context.Context, line *model.PayoutLine) (err error) {
+	ctx, span := startSpan(ctx, "PayoutRepository.SaveLine")
+	defer func() { endSpan(span, err) }()
+
+	return r.db.WithContext(ctx).Save(line).Error
+}
+
+// Finish sets the final status of the payout
+func (r *payoutRepository) Finish(ctx context.Context, id string, status model.PayoutStatus, completedAt time.Time) (err error) {
+	ctx, span := startSpan(ctx, "PayoutRepository.Finish")
+	defer func() { endSpan(span, err) }()
+
+	return r.db.WithContext(ctx).Model(&model.Payout{}).Where("id = ?", id).Updates(map[string]any{
+		"status":       status,
+		"completed_at": completedAt,
+	}).Error
+}
+
+func orderByLine(db *gorm.DB) *gorm.DB {
+	return db.Order("line")
+}
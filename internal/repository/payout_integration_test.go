@@ -0,0 +1,144 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"firego-wallet-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func testPayout(walletID, assetID string, lines ...model.PayoutLine) *model.Payout {
+	for i := range lines {
+		lines[i].Line = i + 1
+		lines[i].DestinationAddress = "tb1q24jg2svw7430u3slcp0rlml7u2tse3h53q0jwe"
+	}
+	return &model.Payout{
+		WalletID:       walletID,
+		VaultAccountID: "1",
+		AssetID:        assetID,
+		Total:          "1",
+		Status:         model.PayoutStatusPending,
+		Lines:          lines,
+	}
+}
+
+func TestPayoutPendingAmount(t *testing.T) {
+	repo := NewPayoutRepository(postgres.NewMigratedDatabase(t))
+	ctx := context.Background()
+	walletID := uuid.New().String()
+
+	amount, err := repo.PendingAmount(ctx, walletID, "BTC_TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, "0", amount)
+
+	payouts := []*model.Payout{
+		testPayout(walletID, "BTC_TEST",
+			model.PayoutLine{Amount: "0.1", Status: model.PayoutLineStatusPending},
+			model.PayoutLine{Amount: "0.2", Status: model.PayoutLineStatusSubmitted},
+			model.PayoutLine{Amount: "0.3", Status: model.PayoutLineStatusFailed},
+		),
+		testPayout(walletID, "BTC_TEST", model.PayoutLine{Amount: "0.00000001", Status: model.PayoutLineStatusPending}),
+		testPayout(walletID, "ETH_TEST5", model.PayoutLine{Amount: "5", Status: model.PayoutLineStatusPending}),
+		testPayout(uuid.New().String(), "BTC_TEST", model.PayoutLine{Amount: "7", Status: model.PayoutLineStatusPending}),
+	}
+	for _, payout := range payouts {
+		assert.NoError(t, repo.Create(ctx, payout))
+	}
+
+	amount, err = repo.PendingAmount(ctx, walletID, "BTC_TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.10000001", amount)
+}
+
+func TestPayoutLockWalletAsset(t *testing.T) {
+	repo := NewPayoutRepository(postgres.NewMigratedDatabase(t))
+	ctx := context.Background()
+	walletID := uuid.New().String()
+
+	held := make(chan struct{})
+	release := make(chan struct{})
+	first := make(chan error)
+	go func() {
+		first <- repo.LockWalletAsset(ctx, walletID, "BTC_TEST", func(ctx context.Context) error {
+			close(held)
+			<-release
+			return repo.Create(ctx, testPayout(walletID, "BTC_TEST", model.PayoutLine{Amount: "0.1", Status: model.PayoutLineStatusPending}))
+		})
+	}()
+	<-held
+
+	// another asset of the wallet is not held up
+	err := repo.LockWalletAsset(ctx, walletID, "ETH_TEST5", func(ctx context.Context) error { return nil })
+	assert.NoError(t, err)
+
+	entered := make(chan string)
+	second := make(chan error)
+	go func() {
+		second <- repo.LockWalletAsset(ctx, walletID, "BTC_TEST", func(ctx context.Context) error {
+			amount, err := repo.PendingAmount(ctx, walletID, "BTC_TEST")
+			entered <- amount
+			return err
+		})
+	}()
+
+	select {
+	case <-entered:
+		t.Fatal("the wallet asset was locked twice")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-first)
+	// the payout created by the previous holder is counted
+	assert.Equal(t, "0.1", <-entered)
+	assert.NoError(t, <-second)
+}
+
+func TestPayoutLockWalletAssetRollsBack(t *testing.T) {
+	repo := NewPayoutRepository(postgres.NewMigratedDatabase(t))
+	ctx := context.Background()
+	walletID := uuid.New().String()
+
+	err := repo.LockWalletAsset(ctx, walletID, "BTC_TEST", func(ctx context.Context) error {
+		if err := repo.Create(ctx, testPayout(walletID, "BTC_TEST", model.PayoutLine{Amount: "0.1", Status: model.PayoutLineStatusPending})); err != nil {
+			return err
+		}
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+
+	amount, err := repo.PendingAmount(ctx, walletID, "BTC_TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, "0", amount)
+}
+
+func TestPayoutHolds(t *testing.T) {
+	repo := NewPayoutRepository(postgres.NewMigratedDatabase(t))
+	ctx := context.Background()
+	walletID := uuid.New().String()
+
+	assert.NoError(t, repo.Create(ctx, testPayout(walletID, "BTC_TEST", model.PayoutLine{Amount: "0.1", Status: model.PayoutLineStatusPending})))
+	hold := &model.BalanceHold{WalletID: walletID, AssetID: "BTC_TEST", Amount: "0.02", ExpiresAt: time.Now().Add(time.Minute)}
+	assert.NoError(t, repo.CreateHold(ctx, hold))
+	holds := []*model.BalanceHold{
+		{WalletID: walletID, AssetID: "BTC_TEST", Amount: "5", ExpiresAt: time.Now().Add(-time.Second)},
+		{WalletID: walletID, AssetID: "ETH_TEST5", Amount: "5", ExpiresAt: time.Now().Add(time.Minute)},
+	}
+	for _, hold := range holds {
+		assert.NoError(t, repo.CreateHold(ctx, hold))
+	}
+
+	// the expired hold is not counted
+	amount, err := repo.PendingAmount(ctx, walletID, "BTC_TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.12", amount)
+
+	assert.NoError(t, repo.DeleteHold(ctx, hold.ID))
+	amount, err = repo.PendingAmount(ctx, walletID, "BTC_TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.1", amount)
+}
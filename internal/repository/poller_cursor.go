@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type pollerCursorRepository struct {
+	db *gorm.DB
+}
+
+func NewPollerCursorRepository(db *gorm.DB) *pollerCursorRepository {
+	return &pollerCursorRepository{
+		db: db,
+	}
+}
+
+// Get returns the cursor of the poller, false when it has not stored one yet
+func (r *pollerCursorRepository) Get(ctx context.Context, name string) (_ int64, _ bool, err error) {
+	ctx, span := startSpan(ctx, "PollerCursorRepository.Get")
+	defer func() { endSpan(span, err) }()
+
+	var cursor model.PollerCursor
+	err = r.db.WithContext(ctx).Where("name = ?", name).First(&cursor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return cursor.Value, true, nil
+}
+
+func (r *pollerCursorRepository) Save(ctx context.Context, name string, value int64) (err error) {
+	ctx, span := startSpan(ctx, "PollerCursorRepository.Save")
+	defer func() { endSpan(span, err) }()
+
+	cursor := model.PollerCursor{Name: name, Value: value}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&cursor).Error
+}
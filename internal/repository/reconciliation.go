@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"firego-wallet-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type reconciliationRepository struct {
+	db *gorm.DB
+}
+
+func NewReconciliationRepository(db *gorm.DB) *reconciliationRepository {
+	return &reconciliationRepository{
+		db: db,
+	}
+}
+
+// Create stores the report together with its issues
+func (r *reconciliationRepository) Create(report *model.ReconciliationReport) error {
+	return r.db.Create(report).Error
+}
+
+func (r *reconciliationRepository) ListLatest(limit int) ([]model.ReconciliationReport, error) {
+	var reports []model.ReconciliationReport
+	err := r.db.Preload("Issues").Order("started_at DESC").Limit(limit).Find(&reports).Error
+	if err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type transferScheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewTransferScheduleRepository(db *gorm.DB) *transferScheduleRepository {
+	return &transferScheduleRepository{
+		db: db,
+	}
+}
+
+func (r *transferScheduleRepository) Create(ctx context.Context, schedule *model.TransferSchedule) (err error) {
+	ctx, span := startSpan(ctx, "TransferScheduleRepository.Create")
+	defer func() { endSpan(span, err) }()
+
+	return r.db.WithContext(ctx).Create(schedule).Error
+}
+
+// GetByID returns a schedule that is not deleted
+func (r *transferScheduleRepository) GetByID(ctx context.Context, id string) (_ *model.TransferSchedule, err error) {
+	ctx, span := startSpan(ctx, "TransferScheduleRepository.GetByID")
+	defer func() { endSpan(span, err) }()
+
+	var schedule model.TransferSchedule
+	err = r.notDeleted(ctx).Where("id = ?", id).First(&schedule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ListByWalletID returns the schedules of the wallet that are not deleted, oldest first
+func (r *transferScheduleRepository) ListByWalletID(ctx context.Context, walletID string) (_ []model.TransferSchedule, err error) {
+	ctx, span := startSpan(ctx, "TransferScheduleRepository.ListByWalletID")
+	defer func() { endSpan(span, err) }()
+
+	var schedules []model.TransferSchedule
+	err = r.notDeleted(ctx).Where("wallet_id = ?", walletID).Order("created_at").Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// ListDue returns the active schedules due at the given time, the most overdue first
+func (r *transferScheduleRepository) ListDue(ctx context.Context, now time.Time) (_ []model.TransferSchedule, err error) {
+	ctx, span := startSpan(ctx, "TransferScheduleRepository.ListDue")
+	defer func() { endSpan(span, err) }()
+
+	var schedules []model.TransferSchedule
+	err = r.notDeleted(ctx).
+		Where("status = ? AND next_run_at <= ?", model.TransferScheduleStatusActive, now).
+		Order("next_run_at").
+		Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// Pause pauses the schedule if it is active
+func (r *transferScheduleRepository) Pause(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "TransferScheduleRepository.Pause")
+	defer func() { endSpan(span, err) }()
+
+	return r.notDeleted(ctx).Model(&model.TransferSchedule{}).
+		Where("id = ? AND status = ?", id, model.TransferScheduleStatusActive).
+		Update("status", model.TransferScheduleStatusPaused).Error
+}
+
+// Resume activates the schedule again if it is paused, due next at the given time
+func (r *transferScheduleRepository) Resume(ctx context.Context, id string, nextRunAt time.Time) (err error) {
+	ctx, span := startSpan(ctx, "TransferScheduleRepository.Resume")
+	defer func() { endSpan(span, err) }()
+
+	return r.notDeleted(ctx).Model(&model.TransferSchedule{}).
+		Where("id = ? AND status = ?", id, model.TransferScheduleStatusPaused).
+		Updates(map[string]any{
+			"status":      model.TransferScheduleStatusActive,
+			"next_run_at": nextRunAt,
+		}).Error
+}
+
+// Delete marks the schedule deleted, its runs are kept
+func (r *transferScheduleRepository) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "TransferScheduleRepository.Delete")
+	defer func() { endSpan(span, err) }()
+
+	return r.notDeleted(ctx).Model(&model.TransferSchedule{}).Where("id = ?", id).Update("deleted_at", time.Now()).Error
+}
+
+// LockDue runs fn in a transaction holding the row of the schedule, locked for update, if it is still active, not
+// deleted and due at scheduledAt, and returns whether fn ran. Pausing or deleting the schedule waits for the
+// transaction; the runs and attempts recorded and the wallet assets locked with the context passed to fn are part of
+// it.
+func (r *transferScheduleRepository) LockDue(ctx context.Context, id string, scheduledAt time.Time, fn func(ctx context.Context) error) (locked bool, err error) {
+	ctx, span := startSpan(ctx, "TransferScheduleRepository.LockDue")
+	defer func() { endSpan(span, err) }()
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var schedule model.TransferSchedule
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND deleted_at IS NULL AND status = ? AND next_run_at = ?", id, model.TransferScheduleStatusActive, scheduledAt).
+			Take(&schedule).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		locked = true
+		return fn(context.WithValue(ctx, txContextKey{}, tx))
+	})
+	return locked, err
+}
+
+// RecordRun stores the run and moves the schedule to its next run, or completes it when there is none, in a single
+// transaction. The status of a schedule paused meanwhile is left as is.
+func (r *transferScheduleRepository) RecordRun(ctx context.Context, run *model.TransferScheduleRun, nextRunAt *time.Time) (err error) {
+	ctx, span := startSpan(ctx, "TransferScheduleRepository.RecordRun")
+	defer func() { endSpan(span, err) }()
+
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(run).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]any{"next_run_at": nextRunAt, "attempts": 0}
+		if nextRunAt == nil {
+			updates["status"] = model.TransferScheduleStatusCompleted
+		}
+		return tx.Model(&model.TransferSchedule{}).Where("id = ?", run.ScheduleID).Updates(updates).Error
+	})
+}
+
+// RecordAttempt counts a transient failure of the run the schedule is due for, leaving it due
+func (r *transferScheduleRepository) RecordAttempt(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "TransferScheduleRepository.RecordAttempt")
+	defer func() { endSpan(span, err) }()
+
+	return r.conn(ctx).Model(&model.TransferSchedule{}).Where("id = ?", id).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+// ListRuns returns the latest runs of the schedule, newest first
+func (r *transferScheduleRepository) ListRuns(ctx context.Context, scheduleID string, limit int) (_ []model.TransferScheduleRun, err error) {
+	ctx, span := startSpan(ctx, "TransferScheduleRepository.ListRuns")
+	defer func() { endSpan(span, err) }()
+
+	var runs []model.TransferScheduleRun
+	err = r.db.WithContext(ctx).Where("schedule_id = ?", scheduleID).Order("scheduled_at DESC").Limit(limit).Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// conn is the transaction of LockDue when ctx comes from it, the pool otherwise
+func (r *transferScheduleRepository) conn(ctx context.Context) *gorm.DB {
+	return conn(ctx, r.db)
+}
+
+func (r *transferScheduleRepository) notDeleted(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Where("deleted_at IS NULL")
+}
@@ -0,0 +1,250 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"firego-wallet-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// createSchedule stores an active schedule due now, as read back from the database
+func createSchedule(t *testing.T, repo *transferScheduleRepository, cron string) *model.TransferSchedule {
+	t.Helper()
+	ctx := context.Background()
+	nextRunAt := time.Now().UTC().Truncate(time.Second)
+	schedule := &model.TransferSchedule{
+		WalletID:           uuid.New().String(),
+		AssetID:            "BTC_TEST",
+		Amount:             "0.0001",
+		DestinationAddress: "tb1q24jg2svw7430u3slcp0rlml7u2tse3h53q0jwe",
+		Cron:               cron,
+		Status:             model.TransferScheduleStatusActive,
+		NextRunAt:          &nextRunAt,
+	}
+	if err := repo.Create(ctx, schedule); err != nil {
+		t.Fatalf("failed to create schedule: %v", err)
+	}
+	stored, err := repo.GetByID(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("failed to get schedule: %v", err)
+	}
+	return stored
+}
+
+func testRun(schedule *model.TransferSchedule) *model.TransferScheduleRun {
+	return &model.TransferScheduleRun{
+		ScheduleID:     schedule.ID,
+		ScheduledAt:    *schedule.NextRunAt,
+		Status:         model.TransferScheduleRunStatusSubmitted,
+		FireblocksTxID: uuid.New().String(),
+	}
+}
+
+func TestScheduleRecordRun(t *testing.T) {
+	repo := NewTransferScheduleRepository(postgres.NewMigratedDatabase(t))
+	ctx := context.Background()
+
+	t.Run("recurring", func(t *testing.T) {
+		schedule := createSchedule(t, repo, "0 * * * *")
+		assert.NoError(t, repo.RecordAttempt(ctx, schedule.ID))
+		nextRunAt := schedule.NextRunAt.Add(time.Hour)
+
+		assert.NoError(t, repo.RecordRun(ctx, testRun(schedule), &nextRunAt))
+
+		stored, err := repo.GetByID(ctx, schedule.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, model.TransferScheduleStatusActive, stored.Status)
+		assert.True(t, nextRunAt.Equal(*stored.NextRunAt))
+		assert.Zero(t, stored.Attempts)
+		runs, err := repo.ListRuns(ctx, schedule.ID, 10)
+		assert.NoError(t, err)
+		assert.Len(t, runs, 1)
+	})
+
+	t.Run("one_off", func(t *testing.T) {
+		schedule := createSchedule(t, repo, "")
+
+		assert.NoError(t, repo.RecordRun(ctx, testRun(schedule), nil))
+
+		stored, err := repo.GetByID(ctx, schedule.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, model.TransferScheduleStatusCompleted, stored.Status)
+		assert.Nil(t, stored.NextRunAt)
+	})
+
+	t.Run("paused_meanwhile", func(t *testing.T) {
+		schedule := createSchedule(t, repo, "0 * * * *")
+		assert.NoError(t, repo.Pause(ctx, schedule.ID))
+		nextRunAt := schedule.NextRunAt.Add(time.Hour)
+
+		assert.NoError(t, repo.RecordRun(ctx, testRun(schedule), &nextRunAt))
+
+		stored, err := repo.GetByID(ctx, schedule.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, model.TransferScheduleStatusPaused, stored.Status)
+	})
+
+	t.Run("run_recorded_twice", func(t *testing.T) {
+		schedule := createSchedule(t, repo, "0 * * * *")
+		nextRunAt := schedule.NextRunAt.Add(time.Hour)
+		assert.NoError(t, repo.RecordRun(ctx, testRun(schedule), &nextRunAt))
+		assert.NoError(t, repo.RecordAttempt(ctx, schedule.ID))
+
+		later := nextRunAt.Add(time.Hour)
+		assert.Error(t, repo.RecordRun(ctx, testRun(schedule), &later))
+
+		// the schedule is not moved without its run
+		stored, err := repo.GetByID(ctx, schedule.ID)
+		assert.NoError(t, err)
+		assert.True(t, nextRunAt.Equal(*stored.NextRunAt))
+		assert.Equal(t, 1, stored.Attempts)
+	})
+}
+
+func TestScheduleLockDue(t *testing.T) {
+	repo := NewTransferScheduleRepository(postgres.NewMigratedDatabase(t))
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		change      func(schedule *model.TransferSchedule) error
+		scheduledAt func(schedule *model.TransferSchedule) time.Time
+		locked      bool
+	}{
+		{name: "due", locked: true},
+		{name: "paused", change: func(schedule *model.TransferSchedule) error { return repo.Pause(ctx, schedule.ID) }},
+		{name: "deleted", change: func(schedule *model.TransferSchedule) error { return repo.Delete(ctx, schedule.ID) }},
+		{
+			name: "run_elsewhere",
+			change: func(schedule *model.TransferSchedule) error {
+				nextRunAt := schedule.NextRunAt.Add(time.Hour)
+				return repo.RecordRun(ctx, testRun(schedule), &nextRunAt)
+			},
+		},
+		{
+			name:        "due_at_another_time",
+			scheduledAt: func(schedule *model.TransferSchedule) time.Time { return schedule.NextRunAt.Add(-time.Hour) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := createSchedule(t, repo, "0 * * * *")
+			if tt.change != nil {
+				assert.NoError(t, tt.change(schedule))
+			}
+			scheduledAt := *schedule.NextRunAt
+			if tt.scheduledAt != nil {
+				scheduledAt = tt.scheduledAt(schedule)
+			}
+
+			ran := false
+			locked, err := repo.LockDue(ctx, schedule.ID, scheduledAt, func(ctx context.Context) error {
+				ran = true
+				return nil
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.locked, locked)
+			assert.Equal(t, tt.locked, ran)
+		})
+	}
+}
+
+func TestScheduleLockDueHoldsSchedule(t *testing.T) {
+	repo := NewTransferScheduleRepository(postgres.NewMigratedDatabase(t))
+	ctx := context.Background()
+	schedule := createSchedule(t, repo, "0 * * * *")
+	nextRunAt := schedule.NextRunAt.Add(time.Hour)
+
+	held := make(chan struct{})
+	release := make(chan struct{})
+	run := make(chan error)
+	go func() {
+		_, err := repo.LockDue(ctx, schedule.ID, *schedule.NextRunAt, func(ctx context.Context) error {
+			close(held)
+			<-release
+			return repo.RecordRun(ctx, testRun(schedule), &nextRunAt)
+		})
+		run <- err
+	}()
+	<-held
+
+	paused := make(chan error)
+	go func() { paused <- repo.Pause(ctx, schedule.ID) }()
+	select {
+	case <-paused:
+		t.Fatal("the schedule was paused while it ran")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// another instance finds the schedule held, then no longer due once the run is recorded
+	locked := make(chan bool)
+	go func() {
+		ok, err := repo.LockDue(ctx, schedule.ID, *schedule.NextRunAt, func(ctx context.Context) error { return nil })
+		assert.NoError(t, err)
+		locked <- ok
+	}()
+
+	close(release)
+	assert.NoError(t, <-run)
+	assert.NoError(t, <-paused)
+	assert.False(t, <-locked)
+
+	stored, err := repo.GetByID(ctx, schedule.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.TransferScheduleStatusPaused, stored.Status)
+	assert.True(t, nextRunAt.Equal(*stored.NextRunAt))
+	runs, err := repo.ListRuns(ctx, schedule.ID, 10)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+}
+
+func TestScheduleLockDueRollsBack(t *testing.T) {
+	repo := NewTransferScheduleRepository(postgres.NewMigratedDatabase(t))
+	ctx := context.Background()
+	schedule := createSchedule(t, repo, "0 * * * *")
+
+	locked, err := repo.LockDue(ctx, schedule.ID, *schedule.NextRunAt, func(ctx context.Context) error {
+		if err := repo.RecordAttempt(ctx, schedule.ID); err != nil {
+			return err
+		}
+		return assert.AnError
+	})
+	assert.True(t, locked)
+	assert.ErrorIs(t, err, assert.AnError)
+
+	stored, err := repo.GetByID(ctx, schedule.ID)
+	assert.NoError(t, err)
+	assert.Zero(t, stored.Attempts)
+}
+
+func TestScheduleLockDueLocksWalletAssetInTransaction(t *testing.T) {
+	db := postgres.NewMigratedDatabase(t)
+	repo := NewTransferScheduleRepository(db)
+	payouts := NewPayoutRepository(db)
+	schedule := createSchedule(t, repo, "0 * * * *")
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	// a run taking a second connection would wait for it until the deadline
+	sqlDB.SetMaxOpenConns(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	locked, err := repo.LockDue(ctx, schedule.ID, *schedule.NextRunAt, func(ctx context.Context) error {
+		return payouts.LockWalletAsset(ctx, schedule.WalletID, schedule.AssetID, func(ctx context.Context) error {
+			hold := &model.BalanceHold{WalletID: schedule.WalletID, AssetID: schedule.AssetID, Amount: "0.0001", ExpiresAt: time.Now().Add(time.Minute)}
+			return payouts.CreateHold(ctx, hold)
+		})
+	})
+	assert.True(t, locked)
+	assert.NoError(t, err)
+
+	amount, err := payouts.PendingAmount(ctx, schedule.WalletID, schedule.AssetID)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.0001", amount)
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"firego-wallet-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type sweepRepository struct {
+	db *gorm.DB
+}
+
+func NewSweepRepository(db *gorm.DB) *sweepRepository {
+	return &sweepRepository{
+		db: db,
+	}
+}
+
+func (r *sweepRepository) Create(ctx context.Context, sweep *model.Sweep) (err error) {
+	ctx, span := startSpan(ctx, "SweepRepository.Create")
+	defer func() { endSpan(span, err) }()
+
+	return r.db.WithContext(ctx).Create(sweep).Error
+}
+
+func (r *sweepRepository) Save(ctx context.Context, sweep *model.Sweep) (err error) {
+	ctx, span := startSpan(ctx, "SweepRepository.Save")
+	defer func() { endSpan(span, err) }()
+
+	return r.db.WithContext(ctx).Save(sweep).Error
+}
+
+// ListPending returns the sweeps whose outcome is not known yet, oldest first
+func (r *sweepRepository) ListPending(ctx context.Context) (_ []model.Sweep, err error) {
+	ctx, span := startSpan(ctx, "SweepRepository.ListPending")
+	defer func() { endSpan(span, err) }()
+
+	var sweeps []model.Sweep
+	err = r.db.WithContext(ctx).Where("status = ?", model.SweepStatusPending).Order("created_at").Find(&sweeps).Error
+	if err != nil {
+		return nil, err
+	}
+	return sweeps, nil
+}
+
+// ListLatest returns the latest sweeps, of the wallet if not empty, newest first
+func (r *sweepRepository) ListLatest(ctx context.Context, walletID string, limit int) (_ []model.Sweep, err error) {
+	ctx, span := startSpan(ctx, "SweepRepository.ListLatest")
+	defer func() { endSpan(span, err) }()
+
+	query := r.db.WithContext(ctx)
+	if walletID != "" {
+		query = query.Where("wallet_id = ?", walletID)
+	}
+	var sweeps []model.Sweep
+	err = query.Order("created_at DESC").Limit(limit).Find(&sweeps).Error
+	if err != nil {
+		return nil, err
+	}
+	return sweeps, nil
+}
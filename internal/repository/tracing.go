@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+var tracer = otel.Tracer("firego-wallet-service/internal/repository")
+
+// startSpan starts the span of a repository query. Only identifiers are recorded, never the query arguments.
+func startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		append(attributes, semconv.DBSystemPostgreSQL)...,
+	))
+}
+
+// endSpan ends the span, marking it failed unless the query succeeded or found no record
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"firego-wallet-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type transferRepository struct {
+	db *gorm.DB
+}
+
+func NewTransferRepository(db *gorm.DB) *transferRepository {
+	return &transferRepository{
+		db: db,
+	}
+}
+
+// Create stores the transfer, unless its transaction is already stored, as when a submission sent again with the same
+// idempotency key gets the transaction created the first time
+func (r *transferRepository) Create(transfer *model.Transfer) error {
+	return r.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "fireblocks_tx_id"}}, DoNothing: true}).Create(transfer).Error
+}
+
+func (r *transferRepository) Save(transfer *model.Transfer) error {
+	return r.db.Save(transfer).Error
+}
+
+func (r *transferRepository) GetByFireblocksTxID(txID string) (*model.Transfer, error) {
+	var transfer model.Transfer
+	err := r.db.Where("fireblocks_tx_id = ?", txID).First(&transfer).Error
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+func (r *transferRepository) ListExcludingStatuses(statuses []string) ([]model.Transfer, error) {
+	var transfers []model.Transfer
+	err := r.db.Where("status NOT IN ?", statuses).Order("created_at").Find(&transfers).Error
+	if err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+func (r *transferRepository) ListCreatedSince(since time.Time) ([]model.Transfer, error) {
+	var transfers []model.Transfer
+	err := r.db.Where("created_at >= ?", since).Order("created_at").Find(&transfers).Error
+	if err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+// txContextKey carries the transaction of LockDue or LockWalletAsset to the calls made by their functions. A wallet
+// asset locked while a schedule runs is locked in the transaction of the schedule, so that a run holds one connection.
+type txContextKey struct{}
+
+// conn is the transaction carried by ctx, db otherwise
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txContextKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"firego-wallet-service/internal/model"
+	"firego-wallet-service/internal/tracing"
 	"gorm.io/gorm"
 )
 
@@ -15,15 +17,45 @@ func NewWalletRepository(db *gorm.DB) *walletRepository {
 	}
 }
 
-func (r *walletRepository) Create(wallet *model.Wallet) error {
-	return r.db.Create(wallet).Error
+func (r *walletRepository) Create(ctx context.Context, wallet *model.Wallet) (err error) {
+	ctx, span := startSpan(ctx, "WalletRepository.Create", tracing.VaultAccountID.String(wallet.VaultAccountID))
+	defer func() { endSpan(span, err) }()
+
+	return r.db.WithContext(ctx).Create(wallet).Error
+}
+
+func (r *walletRepository) GetByID(ctx context.Context, id string) (_ *model.Wallet, err error) {
+	ctx, span := startSpan(ctx, "WalletRepository.GetByID", tracing.WalletID.String(id))
+	defer func() { endSpan(span, err) }()
+
+	var wallet model.Wallet
+	err = r.db.WithContext(ctx).Where("id = ?", id).First(&wallet).Error
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
 }
 
-func (r *walletRepository) GetByID(id string) (*model.Wallet, error) {
+func (r *walletRepository) GetByVaultAccountID(ctx context.Context, vaultAccountID string) (_ *model.Wallet, err error) {
+	ctx, span := startSpan(ctx, "WalletRepository.GetByVaultAccountID", tracing.VaultAccountID.String(vaultAccountID))
+	defer func() { endSpan(span, err) }()
+
 	var wallet model.Wallet
-	err := r.db.Where("id = ?", id).First(&wallet).Error
+	err = r.db.WithContext(ctx).Where("vault_account_id = ?", vaultAccountID).First(&wallet).Error
 	if err != nil {
 		return nil, err
 	}
 	return &wallet, nil
 }
+
+func (r *walletRepository) List(ctx context.Context) (_ []model.Wallet, err error) {
+	ctx, span := startSpan(ctx, "WalletRepository.List")
+	defer func() { endSpan(span, err) }()
+
+	var wallets []model.Wallet
+	err = r.db.WithContext(ctx).Order("created_at").Find(&wallets).Error
+	if err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
@@ -0,0 +1,243 @@
+// Package schedule runs the scheduled transfers once they are due, through the transfer service the API initiates
+// transfers with.
+//
+// A run is sent with an idempotency key derived from the schedule and the time it was due, so a run whose outcome was
+// not recorded, because Fireblocks did not answer or the service stopped, gets the transaction created the first time
+// when it runs again. The key is also the external ID of the transaction, looked up before a run is skipped or failed. Runs failing transiently, Fireblocks being unavailable or rate limiting them, are left due and
+// run again up to maxAttempts times; only the runs rejected for good, or given up on, are recorded as failed.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/cache"
+	"firego-wallet-service/internal/cron"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/model"
+	"firego-wallet-service/internal/transfer"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// maxAttempts is how many times a run failing transiently is tried before it is recorded as failed
+const maxAttempts = 5
+
+type Repository interface {
+	ListDue(ctx context.Context, now time.Time) ([]model.TransferSchedule, error)
+	RecordRun(ctx context.Context, run *model.TransferScheduleRun, nextRunAt *time.Time) error
+	RecordAttempt(ctx context.Context, id string) error
+	// LockDue runs fn, and returns true, only if the schedule is still active and due at scheduledAt, while it cannot
+	// be paused or deleted. The run is recorded with the context passed to fn.
+	LockDue(ctx context.Context, id string, scheduledAt time.Time, fn func(ctx context.Context) error) (bool, error)
+}
+
+// Transferer initiates transfers, see transfer.Service
+type Transferer interface {
+	Transfer(ctx context.Context, walletID string, req transfer.Request) (*model.Transfer, error)
+}
+
+type TransferRepository interface {
+	Create(transfer *model.Transfer) error
+}
+
+type FireblocksClient interface {
+	GetTransactionByExternalID(ctx context.Context, externalTxID string) (*fireblocks.TransactionResponse, int, error)
+}
+
+type Elector interface {
+	IsLeader(ctx context.Context) bool
+}
+
+// Scheduler runs the due schedules periodically on the elected leader only
+type Scheduler struct {
+	repo             Repository
+	transferer       Transferer
+	transferRepo     TransferRepository
+	fireblocksClient FireblocksClient
+	elector          Elector
+	interval         time.Duration
+}
+
+func NewScheduler(repo Repository, transferer Transferer, transferRepo TransferRepository, fireblocksClient FireblocksClient, elector Elector, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		repo:             repo,
+		transferer:       transferer,
+		transferRepo:     transferRepo,
+		fireblocksClient: fireblocksClient,
+		elector:          elector,
+		interval:         interval,
+	}
+}
+
+// IdempotencyKey is the Fireblocks idempotency key of the run of a schedule due at the given time, and the external ID
+// of its transaction
+func IdempotencyKey(scheduleID string, scheduledAt time.Time) string {
+	return fmt.Sprintf("schedule-%s-%d", scheduleID, scheduledAt.Unix())
+}
+
+// Run runs the due schedules every interval until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !s.elector.IsLeader(ctx) {
+			continue
+		}
+		s.RunDue(ctx, time.Now())
+	}
+}
+
+// RunDue runs once each schedule due at the given time, the runs missed while the service was down included
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) {
+	schedules, err := s.repo.ListDue(ctx, now)
+	if err != nil {
+		slog.Error("Failed to list due schedules", "error", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		if ctx.Err() != nil {
+			return
+		}
+		if err = s.run(ctx, &schedule, now); err != nil {
+			slog.Error("Failed to run schedule", "schedule_id", schedule.ID, "error", err)
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, schedule *model.TransferSchedule, now time.Time) error {
+	// the next run is computed before the transfer, an expression that no longer parses must not move funds
+	var nextRunAt *time.Time
+	if schedule.Cron != "" {
+		expr, err := cron.Parse(schedule.Cron)
+		if err != nil {
+			return fmt.Errorf("invalid cron expression %q: %w", schedule.Cron, err)
+		}
+		// runs missed while the service was down are not caught up, the schedule resumes from now
+		if next := expr.Next(now.UTC()); !next.IsZero() {
+			nextRunAt = &next
+		}
+	}
+
+	run := model.TransferScheduleRun{
+		ScheduleID:  schedule.ID,
+		ScheduledAt: *schedule.NextRunAt,
+	}
+	// the schedule may have been paused or deleted since it was listed, it must not move funds then; once the transfer
+	// is submitted, its outcome is recorded whatever happens to the context. The wallet asset of the transfer is locked
+	// in the same transaction, so that a run holds a single connection.
+	locked, err := s.repo.LockDue(context.WithoutCancel(ctx), schedule.ID, run.ScheduledAt, func(ctx context.Context) error {
+		return s.transfer(ctx, schedule, &run, nextRunAt)
+	})
+	if err != nil {
+		return err
+	}
+	if !locked {
+		slog.Info("Schedule no longer due, not running it", "schedule_id", schedule.ID)
+	}
+	return nil
+}
+
+// transfer runs the transfer of the schedule and records its outcome
+func (s *Scheduler) transfer(ctx context.Context, schedule *model.TransferSchedule, run *model.TransferScheduleRun, nextRunAt *time.Time) error {
+	key := IdempotencyKey(schedule.ID, run.ScheduledAt)
+	// the balance is read fresh, a cached one may no longer cover a transfer made unattended
+	transferCtx := fireblocks.WithIdempotencyKey(cache.Bypass(ctx), key)
+	created, err := s.transferer.Transfer(transferCtx, schedule.WalletID, transfer.Request{
+		AssetID:            schedule.AssetID,
+		Amount:             schedule.Amount,
+		DestinationAddress: schedule.DestinationAddress,
+		Note:               schedule.Note,
+		ExternalTxID:       key,
+	})
+	retry := err != nil && transient(err) && schedule.Attempts+1 < maxAttempts
+	if err != nil && !retry {
+		// an earlier attempt whose outcome was lost may have created the transaction, which the balance no longer
+		// covers then: the run is not skipped or failed if it did, and is left due while Fireblocks cannot tell
+		found, lookupErr := s.lookup(ctx, schedule, key)
+		switch {
+		case lookupErr != nil:
+			slog.Error("Scheduled transfer outcome unknown", "schedule_id", schedule.ID, "error", err, "lookup_error", lookupErr)
+			retry = true
+		case found != nil:
+			created, err = found, nil
+		}
+	}
+
+	var transferErr *transfer.Error
+	switch {
+	case err == nil:
+		run.Status = model.TransferScheduleRunStatusSubmitted
+		run.FireblocksTxID = created.FireblocksTxID
+	case retry:
+		// the transaction may exist in Fireblocks, the run is sent again with the same idempotency key
+		slog.Warn("Scheduled transfer failed, retrying", "schedule_id", schedule.ID, "wallet_id", schedule.WalletID, "attempts", schedule.Attempts+1, "error", err)
+		if err = s.repo.RecordAttempt(ctx, schedule.ID); err != nil {
+			return fmt.Errorf("failed to record attempt: %w", err)
+		}
+		return nil
+	case errors.As(err, &transferErr) && transferErr.Code == apierror.InsufficientBalance:
+		slog.Warn("Scheduled transfer skipped, insufficient balance", "schedule_id", schedule.ID, "wallet_id", schedule.WalletID)
+		run.Status = model.TransferScheduleRunStatusSkipped
+		run.Error = err.Error()
+	default:
+		slog.Error("Scheduled transfer failed", "schedule_id", schedule.ID, "wallet_id", schedule.WalletID, "error", err)
+		run.Status = model.TransferScheduleRunStatusFailed
+		run.Error = err.Error()
+	}
+
+	if err = s.repo.RecordRun(ctx, run, nextRunAt); err != nil {
+		// the schedule is still due and runs again with the same idempotency key
+		return fmt.Errorf("failed to record run: %w", err)
+	}
+	slog.Info("Scheduled transfer run", "schedule_id", schedule.ID, "status", run.Status, "transaction_id", run.FireblocksTxID)
+	return nil
+}
+
+// lookup is called before skipping or failing a run and returns the transfer of the transaction Fireblocks has with
+// the run external ID, stored unless it already is, or nil when Fireblocks has none
+func (s *Scheduler) lookup(ctx context.Context, schedule *model.TransferSchedule, key string) (*model.Transfer, error) {
+	tx, statusCode, err := s.fireblocksClient.GetTransactionByExternalID(ctx, key)
+	if statusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Scheduled transfer transaction found", "schedule_id", schedule.ID, "transaction_id", tx.ID)
+	found := model.Transfer{
+		WalletID:           schedule.WalletID,
+		FireblocksTxID:     tx.ID,
+		AssetID:            schedule.AssetID,
+		Amount:             schedule.Amount,
+		DestinationAddress: schedule.DestinationAddress,
+		Note:               schedule.Note,
+		Status:             tx.Status,
+	}
+	// the transfer of a run whose outcome was lost after it was stored is already stored
+	if err = s.transferRepo.Create(&found); err != nil {
+		slog.Error("Failed to store scheduled transfer", "schedule_id", schedule.ID, "transaction_id", tx.ID, "error", err)
+	}
+	return &found, nil
+}
+
+// transient reports whether a transfer failed for a reason that may be gone when it is tried again: Fireblocks being
+// unavailable or rate limiting it, the service shutting down or failing to store it
+func transient(err error) bool {
+	var transferErr *transfer.Error
+	if !errors.As(err, &transferErr) {
+		return true
+	}
+	return transferErr.StatusCode == http.StatusTooManyRequests || transferErr.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,406 @@
+package schedule
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"firego-wallet-service/internal/cache"
+	"firego-wallet-service/internal/cron"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/fireblocks/fireblockstest"
+	"firego-wallet-service/internal/model"
+	"firego-wallet-service/internal/transfer"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testAPIKey = "sim-api-key"
+
+// MemoryRepository keeps the schedules and their runs like the database does
+type MemoryRepository struct {
+	schedules map[string]model.TransferSchedule
+	runs      []model.TransferScheduleRun
+	// FailRecords makes the runs fail to be recorded, as if the service stopped before storing them
+	FailRecords bool
+}
+
+func (m *MemoryRepository) add(schedule model.TransferSchedule) string {
+	if m.schedules == nil {
+		m.schedules = map[string]model.TransferSchedule{}
+	}
+	schedule.ID = "schedule-" + strconv.Itoa(len(m.schedules)+1)
+	m.schedules[schedule.ID] = schedule
+	return schedule.ID
+}
+
+func (m *MemoryRepository) ListDue(_ context.Context, now time.Time) ([]model.TransferSchedule, error) {
+	var schedules []model.TransferSchedule
+	for _, schedule := range m.schedules {
+		if schedule.Status == model.TransferScheduleStatusActive && !schedule.NextRunAt.After(now) {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].NextRunAt.Before(*schedules[j].NextRunAt) })
+	return schedules, nil
+}
+
+func (m *MemoryRepository) RecordRun(_ context.Context, run *model.TransferScheduleRun, nextRunAt *time.Time) error {
+	if m.FailRecords {
+		return errors.New("connection reset")
+	}
+	m.runs = append(m.runs, *run)
+	schedule := m.schedules[run.ScheduleID]
+	schedule.NextRunAt = nextRunAt
+	schedule.Attempts = 0
+	if nextRunAt == nil {
+		schedule.Status = model.TransferScheduleStatusCompleted
+	}
+	m.schedules[run.ScheduleID] = schedule
+	return nil
+}
+
+func (m *MemoryRepository) RecordAttempt(_ context.Context, id string) error {
+	schedule := m.schedules[id]
+	schedule.Attempts++
+	m.schedules[id] = schedule
+	return nil
+}
+
+func (m *MemoryRepository) LockDue(ctx context.Context, id string, scheduledAt time.Time, fn func(ctx context.Context) error) (bool, error) {
+	schedule, ok := m.schedules[id]
+	if !ok || schedule.DeletedAt != nil || schedule.Status != model.TransferScheduleStatusActive || !schedule.NextRunAt.Equal(scheduledAt) {
+		return false, nil
+	}
+	return true, fn(ctx)
+}
+
+type MemoryWalletRepository struct {
+	wallets map[string]model.Wallet
+}
+
+func (m *MemoryWalletRepository) Create(_ context.Context, wallet *model.Wallet) error {
+	m.wallets[wallet.ID] = *wallet
+	return nil
+}
+
+func (m *MemoryWalletRepository) GetByID(_ context.Context, id string) (*model.Wallet, error) {
+	wallet, ok := m.wallets[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &wallet, nil
+}
+
+type MemoryTransferRepository struct {
+	Transfers map[string]model.Transfer
+}
+
+func (m *MemoryTransferRepository) Create(transfer *model.Transfer) error {
+	// like the database, the transaction stored first is kept
+	if _, ok := m.Transfers[transfer.FireblocksTxID]; ok {
+		return nil
+	}
+	m.Transfers[transfer.FireblocksTxID] = *transfer
+	return nil
+}
+
+// NoReservations checks the transfers against the whole balance, no payout reserving any of it
+type NoReservations struct{}
+
+func (NoReservations) Reserve(ctx context.Context, _, _ string, fn func(ctx context.Context, reserved *big.Rat) error) error {
+	return fn(ctx, new(big.Rat))
+}
+
+func (NoReservations) Hold(context.Context, string, string, *big.Rat) (string, error) {
+	return "", nil
+}
+
+func (NoReservations) Release(context.Context, string) error {
+	return nil
+}
+
+type AlwaysLeader struct{}
+
+func (AlwaysLeader) IsLeader(context.Context) bool {
+	return true
+}
+
+type testEnv struct {
+	scheduler *Scheduler
+	repo      *MemoryRepository
+	transfers *MemoryTransferRepository
+	// unavailable is how many transactions the simulator fails to create with a 503 before it recovers
+	unavailable atomic.Int32
+}
+
+// newTestEnv runs the scheduler through the transfer path of the wallet handler against the Fireblocks simulator,
+// with the wallet-1 vault account funded with 10 ETH_TEST5
+func newTestEnv(t *testing.T) *testEnv {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	env := &testEnv{
+		repo:      &MemoryRepository{},
+		transfers: &MemoryTransferRepository{Transfers: map[string]model.Transfer{}},
+	}
+	sim := fireblockstest.NewServer(fireblockstest.Config{APIKey: testAPIKey, PublicKey: &key.PublicKey})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/v1/transactions" && env.unavailable.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		sim.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client := fireblocks.NewClient(server.URL, testAPIKey, key)
+	vault, _, err := client.CreateVaultAccount(ctx, fireblocks.CreateVaultAccountRequest{Name: "treasury"})
+	assert.NoError(t, err)
+	_, err = sim.Deposit(ctx, vault.ID, "ETH_TEST5", "10")
+	assert.NoError(t, err)
+	sim.Advance(ctx)
+
+	walletRepo := &MemoryWalletRepository{wallets: map[string]model.Wallet{"wallet-1": {ID: "wallet-1", VaultAccountID: vault.ID}}}
+	// the runs read the balances around the cache
+	transfers := transfer.NewService(walletRepo, env.transfers, client, cache.NewBalances(client, cache.NewMemoryStore(), time.Hour), NoReservations{})
+	env.scheduler = NewScheduler(env.repo, transfers, env.transfers, client, AlwaysLeader{}, time.Minute)
+	return env
+}
+
+func newSchedule(amount, expr string, nextRunAt time.Time) model.TransferSchedule {
+	return model.TransferSchedule{
+		WalletID:           "wallet-1",
+		AssetID:            "ETH_TEST5",
+		Amount:             amount,
+		DestinationAddress: "0xcold",
+		Note:               "weekly sweep",
+		Cron:               expr,
+		Status:             model.TransferScheduleStatusActive,
+		NextRunAt:          &nextRunAt,
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Date(2026, time.March, 4, 9, 0, 30, 0, time.UTC)
+	recurring := env.repo.add(newSchedule("1", "0 9 * * 3", now.Add(-30*time.Second)))
+	oneOff := env.repo.add(newSchedule("2", "", now.Add(-time.Hour)))
+	later := env.repo.add(newSchedule("3", "", now.Add(time.Hour)))
+	paused := newSchedule("4", "", now.Add(-time.Hour))
+	paused.Status = model.TransferScheduleStatusPaused
+	env.repo.add(paused)
+
+	env.scheduler.RunDue(context.Background(), now)
+
+	assert.Len(t, env.repo.runs, 2)
+	// the most overdue first
+	assert.Equal(t, oneOff, env.repo.runs[0].ScheduleID)
+	assert.Equal(t, now.Add(-time.Hour), env.repo.runs[0].ScheduledAt)
+	assert.Equal(t, recurring, env.repo.runs[1].ScheduleID)
+	for _, run := range env.repo.runs {
+		assert.Equal(t, model.TransferScheduleRunStatusSubmitted, run.Status)
+		assert.NotEmpty(t, run.FireblocksTxID)
+		assert.Empty(t, run.Error)
+		assert.Equal(t, "0xcold", env.transfers.Transfers[run.FireblocksTxID].DestinationAddress)
+	}
+	assert.Len(t, env.transfers.Transfers, 2)
+
+	assert.Equal(t, model.TransferScheduleStatusActive, env.repo.schedules[recurring].Status)
+	assert.Equal(t, time.Date(2026, time.March, 11, 9, 0, 0, 0, time.UTC), *env.repo.schedules[recurring].NextRunAt)
+	assert.Equal(t, model.TransferScheduleStatusCompleted, env.repo.schedules[oneOff].Status)
+	assert.Nil(t, env.repo.schedules[oneOff].NextRunAt)
+	assert.Equal(t, model.TransferScheduleStatusActive, env.repo.schedules[later].Status)
+}
+
+func TestRunChangedSinceListed(t *testing.T) {
+	now := time.Date(2026, time.March, 4, 9, 0, 30, 0, time.UTC)
+	tests := []struct {
+		name   string
+		change func(schedule *model.TransferSchedule)
+	}{
+		{name: "paused", change: func(schedule *model.TransferSchedule) { schedule.Status = model.TransferScheduleStatusPaused }},
+		{name: "deleted", change: func(schedule *model.TransferSchedule) { schedule.DeletedAt = &now }},
+		{name: "run_elsewhere", change: func(schedule *model.TransferSchedule) {
+			next := now.Add(24 * time.Hour)
+			schedule.NextRunAt = &next
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			id := env.repo.add(newSchedule("1", "@daily", now.Add(-time.Minute)))
+			due, err := env.repo.ListDue(context.Background(), now)
+			assert.NoError(t, err)
+
+			// the schedule changes between the listing and its run
+			schedule := env.repo.schedules[id]
+			tt.change(&schedule)
+			env.repo.schedules[id] = schedule
+
+			assert.NoError(t, env.scheduler.run(context.Background(), &due[0], now))
+
+			assert.Empty(t, env.repo.runs)
+			assert.Empty(t, env.transfers.Transfers)
+		})
+	}
+}
+
+func TestRunDueOutcomes(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule func() model.TransferSchedule
+		status   model.TransferScheduleRunStatus
+		error    string
+	}{
+		{
+			name: "insufficient_balance",
+			schedule: func() model.TransferSchedule {
+				return newSchedule("25", "@daily", time.Now().Add(-time.Minute))
+			},
+			status: model.TransferScheduleRunStatusSkipped,
+			error:  "Insufficient balance",
+		},
+		{
+			name: "wallet_not_found",
+			schedule: func() model.TransferSchedule {
+				schedule := newSchedule("1", "@daily", time.Now().Add(-time.Minute))
+				schedule.WalletID = "wallet-2"
+				return schedule
+			},
+			status: model.TransferScheduleRunStatusFailed,
+			error:  "Wallet not found",
+		},
+		{
+			name: "rejected_by_fireblocks",
+			schedule: func() model.TransferSchedule {
+				return newSchedule("0", "@daily", time.Now().Add(-time.Minute))
+			},
+			status: model.TransferScheduleRunStatusFailed,
+			error:  "Invalid request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			id := env.repo.add(tt.schedule())
+
+			env.scheduler.RunDue(context.Background(), time.Now())
+
+			assert.Len(t, env.repo.runs, 1)
+			assert.Equal(t, tt.status, env.repo.runs[0].Status)
+			assert.Equal(t, tt.error, env.repo.runs[0].Error)
+			assert.Empty(t, env.transfers.Transfers)
+			// recurring schedules go on after a skipped or failed run
+			schedule := env.repo.schedules[id]
+			assert.Equal(t, model.TransferScheduleStatusActive, schedule.Status)
+			assert.True(t, schedule.NextRunAt.After(time.Now()))
+		})
+	}
+}
+
+func TestRunDueResubmitsWithSameKey(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Now()
+	id := env.repo.add(newSchedule("1", "", now.Add(-time.Minute)))
+
+	env.repo.FailRecords = true
+	env.scheduler.RunDue(context.Background(), now)
+	assert.Empty(t, env.repo.runs)
+	assert.Len(t, env.transfers.Transfers, 1)
+
+	// the run is still due, and Fireblocks answers with the transaction created the first time
+	env.repo.FailRecords = false
+	env.scheduler.RunDue(context.Background(), now)
+	assert.Len(t, env.repo.runs, 1)
+	assert.Equal(t, model.TransferScheduleRunStatusSubmitted, env.repo.runs[0].Status)
+	assert.Contains(t, env.transfers.Transfers, env.repo.runs[0].FireblocksTxID)
+	assert.Len(t, env.transfers.Transfers, 1)
+	assert.Equal(t, model.TransferScheduleStatusCompleted, env.repo.schedules[id].Status)
+}
+
+func TestRunDueFindsLostTransaction(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Now()
+	id := env.repo.add(newSchedule("6", "", now.Add(-time.Minute)))
+
+	env.repo.FailRecords = true
+	env.scheduler.RunDue(context.Background(), now)
+	assert.Empty(t, env.repo.runs)
+	env.transfers.Transfers = map[string]model.Transfer{}
+
+	// the balance left no longer covers the run, whose transaction is found by its external ID
+	env.repo.FailRecords = false
+	env.scheduler.RunDue(context.Background(), now)
+	assert.Len(t, env.repo.runs, 1)
+	assert.Equal(t, model.TransferScheduleRunStatusSubmitted, env.repo.runs[0].Status)
+	assert.Empty(t, env.repo.runs[0].Error)
+	assert.Equal(t, "6", env.transfers.Transfers[env.repo.runs[0].FireblocksTxID].Amount)
+	assert.Equal(t, model.TransferScheduleStatusCompleted, env.repo.schedules[id].Status)
+}
+
+func TestRunDueRetriesUnavailable(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Now()
+	id := env.repo.add(newSchedule("1", "@daily", now.Add(-time.Minute)))
+	env.unavailable.Store(1)
+
+	// the run is left due instead of being recorded as failed
+	env.scheduler.RunDue(context.Background(), now)
+	assert.Empty(t, env.repo.runs)
+	assert.Equal(t, 1, env.repo.schedules[id].Attempts)
+	assert.Equal(t, now.Add(-time.Minute), *env.repo.schedules[id].NextRunAt)
+
+	env.scheduler.RunDue(context.Background(), now)
+	assert.Len(t, env.repo.runs, 1)
+	assert.Equal(t, model.TransferScheduleRunStatusSubmitted, env.repo.runs[0].Status)
+	assert.Equal(t, now.Add(-time.Minute), env.repo.runs[0].ScheduledAt)
+	assert.Zero(t, env.repo.schedules[id].Attempts)
+	assert.Len(t, env.transfers.Transfers, 1)
+}
+
+func TestRunDueGivesUp(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Now()
+	id := env.repo.add(newSchedule("1", "@daily", now.Add(-time.Minute)))
+	env.unavailable.Store(maxAttempts)
+
+	for range maxAttempts {
+		env.scheduler.RunDue(context.Background(), now)
+	}
+
+	assert.Len(t, env.repo.runs, 1)
+	assert.Equal(t, model.TransferScheduleRunStatusFailed, env.repo.runs[0].Status)
+	assert.Equal(t, "Service unavailable", env.repo.runs[0].Error)
+	assert.True(t, env.repo.schedules[id].NextRunAt.After(now))
+	assert.Zero(t, env.repo.schedules[id].Attempts)
+}
+
+func TestRunDueMissedRuns(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Date(2026, time.March, 4, 12, 0, 30, 0, time.UTC)
+	id := env.repo.add(newSchedule("1", "@hourly", now.Add(-72*time.Hour)))
+
+	env.scheduler.RunDue(context.Background(), now)
+
+	// the runs missed while the service was down are run once, then the schedule resumes from now
+	assert.Len(t, env.repo.runs, 1)
+	expr, err := cron.Parse("@hourly")
+	assert.NoError(t, err)
+	assert.Equal(t, expr.Next(now), *env.repo.schedules[id].NextRunAt)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	scheduledAt := time.Date(2026, time.March, 4, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, "schedule-7f0c-1772614800", IdempotencyKey("7f0c", scheduledAt))
+}
@@ -0,0 +1,81 @@
+package sweep
+
+import (
+	"errors"
+	"firego-wallet-service/internal/ledger"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Rule sweeps an asset out of every wallet into the treasury wallet once its available balance reaches Threshold
+type Rule struct {
+	Threshold        string `yaml:"threshold"`
+	TreasuryWalletID string `yaml:"treasuryWalletId"`
+	// MinLeftover is what the wallet keeps to pay the network fees of its next transactions, "0" when empty. It is left
+	// in the asset itself, or, for a token, must be available in FeeAsset for the token to be swept.
+	MinLeftover string `yaml:"minLeftover"`
+	// FeeAsset is the asset the network fees of a token are paid in, e.g. ETH_TEST5 for a token on its chain, empty
+	// for an asset paying its own fees. A token is swept whole.
+	FeeAsset string `yaml:"feeAsset"`
+}
+
+// Validate reports the first invalid setting of the rule
+func (r Rule) Validate() error {
+	if r.TreasuryWalletID == "" {
+		return errors.New("treasuryWalletId is required")
+	}
+	_, _, err := r.amounts()
+	return err
+}
+
+// amounts parses the threshold and the minimum leftover. The threshold must be above the leftover when they are both
+// in the asset.
+func (r Rule) amounts() (threshold, minLeftover *big.Rat, err error) {
+	threshold, err = ledger.ParseAmount(r.Threshold)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid threshold: %w", err)
+	}
+	minLeftover = new(big.Rat)
+	if r.MinLeftover != "" {
+		if minLeftover, err = ledger.ParseAmount(r.MinLeftover); err != nil {
+			return nil, nil, fmt.Errorf("invalid minLeftover: %w", err)
+		}
+	}
+	if r.FeeAsset == "" && threshold.Cmp(minLeftover) <= 0 {
+		return nil, nil, errors.New("threshold must be greater than minLeftover")
+	}
+	if r.FeeAsset != "" && threshold.Sign() == 0 {
+		return nil, nil, errors.New("threshold must be greater than 0")
+	}
+	return threshold, minLeftover, nil
+}
+
+// ParseRules parses a comma separated list of ASSET=THRESHOLD:MIN_LEFTOVER:TREASURY_WALLET_ID[:FEE_ASSET] rules, e.g.
+// "ETH_TEST5=0.5:0.01:3f2b8c1e-...,USDC_TEST=100:0.01:3f2b8c1e-...:ETH_TEST5". The rules are validated separately.
+func ParseRules(spec string) (map[string]Rule, error) {
+	rules := map[string]Rule{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		assetID, value, ok := strings.Cut(item, "=")
+		parts := strings.Split(value, ":")
+		if !ok || strings.TrimSpace(assetID) == "" || len(parts) < 3 || len(parts) > 4 {
+			return nil, fmt.Errorf("invalid sweep rule %q, expected ASSET=THRESHOLD:MIN_LEFTOVER:TREASURY_WALLET_ID[:FEE_ASSET]", item)
+		}
+
+		rule := Rule{
+			Threshold:        strings.TrimSpace(parts[0]),
+			MinLeftover:      strings.TrimSpace(parts[1]),
+			TreasuryWalletID: strings.TrimSpace(parts[2]),
+		}
+		if len(parts) == 4 {
+			rule.FeeAsset = strings.TrimSpace(parts[3])
+		}
+		rules[strings.TrimSpace(assetID)] = rule
+	}
+	return rules, nil
+}
@@ -0,0 +1,52 @@
+package sweep
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("ETH_TEST5=0.5:0.01:treasury, BTC_TEST=0.1::treasury,USDC_TEST=100:0.01:treasury:ETH_TEST5")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Rule{
+		"ETH_TEST5": {Threshold: "0.5", MinLeftover: "0.01", TreasuryWalletID: "treasury"},
+		"BTC_TEST":  {Threshold: "0.1", TreasuryWalletID: "treasury"},
+		"USDC_TEST": {Threshold: "100", MinLeftover: "0.01", TreasuryWalletID: "treasury", FeeAsset: "ETH_TEST5"},
+	}, rules)
+
+	rules, err = ParseRules("")
+	assert.NoError(t, err)
+	assert.Empty(t, rules)
+
+	for _, spec := range []string{"ETH_TEST5", "ETH_TEST5=0.5:treasury", "=0.5:0.01:treasury", "USDC_TEST=100:0.01:treasury:ETH_TEST5:x"} {
+		_, err = ParseRules(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		err  string
+	}{
+		{name: "valid", rule: Rule{Threshold: "0.5", MinLeftover: "0.01", TreasuryWalletID: "treasury"}},
+		{name: "no_leftover", rule: Rule{Threshold: "0.5", TreasuryWalletID: "treasury"}},
+		{name: "no_treasury", rule: Rule{Threshold: "0.5"}, err: "treasuryWalletId is required"},
+		{name: "invalid_threshold", rule: Rule{Threshold: "abc", TreasuryWalletID: "treasury"}, err: "invalid threshold"},
+		{name: "invalid_leftover", rule: Rule{Threshold: "0.5", MinLeftover: "-1", TreasuryWalletID: "treasury"}, err: "invalid minLeftover"},
+		{name: "leftover_above_threshold", rule: Rule{Threshold: "0.5", MinLeftover: "0.5", TreasuryWalletID: "treasury"}, err: "threshold must be greater than minLeftover"},
+		{name: "token_leftover_above_threshold", rule: Rule{Threshold: "100", MinLeftover: "200", TreasuryWalletID: "treasury", FeeAsset: "ETH_TEST5"}},
+		{name: "token_zero_threshold", rule: Rule{Threshold: "0", MinLeftover: "0.01", TreasuryWalletID: "treasury", FeeAsset: "ETH_TEST5"}, err: "threshold must be greater than 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
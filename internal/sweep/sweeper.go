@@ -0,0 +1,324 @@
+// Package sweep moves the balances accumulating in the wallets into the treasury wallets, following a rule per asset.
+//
+// A sweep is stored before it is sent to Fireblocks, with an idempotency key derived from its ID, so a sweep whose
+// outcome is unknown, because Fireblocks did not answer or the service stopped before storing it, is simply sent
+// again: Fireblocks answers with the transaction created the first time instead of creating another one.
+package sweep
+
+import (
+	"context"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/ledger"
+	"firego-wallet-service/internal/metrics"
+	"firego-wallet-service/internal/model"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// maxAttempts is how many times a sweep is sent to Fireblocks before it is given up on
+const maxAttempts = 5
+
+type Repository interface {
+	Create(ctx context.Context, sweep *model.Sweep) error
+	Save(ctx context.Context, sweep *model.Sweep) error
+	ListPending(ctx context.Context) ([]model.Sweep, error)
+}
+
+type WalletRepository interface {
+	List(ctx context.Context) ([]model.Wallet, error)
+}
+
+type TransferRepository interface {
+	Create(transfer *model.Transfer) error
+}
+
+type FireblocksClient interface {
+	GetVaultAccountAssetBalance(ctx context.Context, vaultAccountID, assetID string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error)
+	CreateTransaction(ctx context.Context, req fireblocks.CreateTransactionRequest) (*fireblocks.CreateTransactionResponse, int, error)
+}
+
+// Reservations is the amount of the payout lines not submitted yet and of the holds of the transfers being submitted,
+// which the Fireblocks available balance does not account for, see payout.Service.Reserve
+type Reservations interface {
+	Reserve(ctx context.Context, walletID, assetID string, fn func(ctx context.Context, reserved *big.Rat) error) error
+	Reserved(ctx context.Context, walletID, assetID string) (*big.Rat, error)
+	Hold(ctx context.Context, walletID, assetID string, amount *big.Rat) (string, error)
+	Release(ctx context.Context, holdID string) error
+}
+
+// BalanceCache is invalidated once a sweep moves funds out of the vault account, see cache.Balances
+type BalanceCache interface {
+	Invalidate(ctx context.Context, vaultAccountID, assetID string)
+}
+
+type Elector interface {
+	IsLeader(ctx context.Context) bool
+}
+
+type Config struct {
+	// Rules are the sweep rules by asset ID
+	Rules    map[string]Rule
+	Interval time.Duration
+}
+
+// Sweeper sweeps the wallets periodically on the elected leader only
+type Sweeper struct {
+	repo             Repository
+	walletRepo       WalletRepository
+	transferRepo     TransferRepository
+	fireblocksClient FireblocksClient
+	reservations     Reservations
+	balances         BalanceCache
+	elector          Elector
+	config           Config
+}
+
+func NewSweeper(repo Repository, walletRepo WalletRepository, transferRepo TransferRepository, fireblocksClient FireblocksClient, reservations Reservations, balances BalanceCache, elector Elector, config Config) *Sweeper {
+	return &Sweeper{
+		repo:             repo,
+		walletRepo:       walletRepo,
+		transferRepo:     transferRepo,
+		fireblocksClient: fireblocksClient,
+		reservations:     reservations,
+		balances:         balances,
+		elector:          elector,
+		config:           config,
+	}
+}
+
+// IdempotencyKey is the Fireblocks idempotency key of a sweep
+func IdempotencyKey(sweepID string) string {
+	return "sweep-" + sweepID
+}
+
+// TreasuryAddress is the destination address of the transfers made by the sweeps, which go to a vault account rather
+// than to an address
+func TreasuryAddress(vaultAccountID string) string {
+	return "vault:" + vaultAccountID
+}
+
+// Run sweeps the wallets every interval until the context is cancelled
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !s.elector.IsLeader(ctx) {
+			continue
+		}
+		s.Sweep(ctx)
+	}
+}
+
+// Sweep sends again the sweeps whose outcome is unknown, then sweeps every other wallet asset whose available balance
+// reached the threshold of its rule
+func (s *Sweeper) Sweep(ctx context.Context) {
+	wallets, err := s.walletRepo.List(ctx)
+	if err != nil {
+		slog.Error("Failed to list wallets to sweep", "error", err)
+		return
+	}
+	walletsByID := make(map[string]model.Wallet, len(wallets))
+	for _, wallet := range wallets {
+		walletsByID[wallet.ID] = wallet
+	}
+
+	pending, err := s.repo.ListPending(ctx)
+	if err != nil {
+		slog.Error("Failed to list pending sweeps", "error", err)
+		return
+	}
+	// a wallet asset is not swept again until the outcome of its last sweep is known
+	unsettled := map[string]bool{}
+	for i := range pending {
+		if ctx.Err() != nil {
+			return
+		}
+		sweep := &pending[i]
+		unsettled[sweep.WalletID+"/"+sweep.AssetID] = true
+		wallet, treasury := walletsByID[sweep.WalletID], walletsByID[sweep.TreasuryWalletID]
+		if err = s.submit(ctx, wallet, treasury, sweep); err != nil {
+			slog.Error("Failed to submit sweep", "sweep_id", sweep.ID, "error", err)
+		}
+	}
+
+	assetIDs := make([]string, 0, len(s.config.Rules))
+	for assetID := range s.config.Rules {
+		assetIDs = append(assetIDs, assetID)
+	}
+	sort.Strings(assetIDs)
+	for _, assetID := range assetIDs {
+		rule := s.config.Rules[assetID]
+		treasury, ok := walletsByID[rule.TreasuryWalletID]
+		if !ok {
+			slog.Error("Treasury wallet of sweep rule not found", "asset_id", assetID, "treasury_wallet_id", rule.TreasuryWalletID)
+			continue
+		}
+		for _, wallet := range wallets {
+			if ctx.Err() != nil {
+				return
+			}
+			if wallet.ID == treasury.ID || unsettled[wallet.ID+"/"+assetID] {
+				continue
+			}
+			if err = s.sweep(ctx, wallet, treasury, assetID, rule); err != nil {
+				slog.Error("Failed to sweep wallet", "wallet_id", wallet.ID, "asset_id", assetID, "error", err)
+			}
+		}
+	}
+}
+
+// sweep moves the available balance of the wallet asset above the minimum leftover to the treasury, once it reached
+// the threshold, or the whole balance of a token once the wallet has the minimum leftover in its fee asset. The payouts
+// of the wallet not submitted yet and the transfers being submitted keep their funds.
+func (s *Sweeper) sweep(ctx context.Context, wallet, treasury model.Wallet, assetID string, rule Rule) error {
+	threshold, minLeftover, err := rule.amounts()
+	if err != nil {
+		return fmt.Errorf("invalid sweep rule: %w", err)
+	}
+
+	// the balance is checked while no transfer or payout of the wallet asset is, then the amount is held until the
+	// sweep is submitted, like a transfer
+	var sweep *model.Sweep
+	var holdID string
+	err = s.reservations.Reserve(ctx, wallet.ID, assetID, func(ctx context.Context, reserved *big.Rat) error {
+		available, err := s.available(ctx, wallet, assetID, reserved)
+		if err != nil || available.Cmp(threshold) < 0 {
+			return err
+		}
+
+		amount := new(big.Rat).Sub(available, minLeftover)
+		if rule.FeeAsset != "" {
+			amount.Set(available)
+			feeReserved, err := s.reservations.Reserved(ctx, wallet.ID, rule.FeeAsset)
+			if err != nil {
+				return fmt.Errorf("failed to get reserved amount of %s: %w", rule.FeeAsset, err)
+			}
+			fee, err := s.available(ctx, wallet, rule.FeeAsset, feeReserved)
+			if err != nil {
+				return err
+			}
+			if fee.Cmp(minLeftover) < 0 {
+				slog.Info("Not enough fee asset to sweep wallet", "wallet_id", wallet.ID, "asset_id", assetID, "fee_asset_id", rule.FeeAsset, "available", ledger.FormatAmount(fee))
+				return nil
+			}
+		}
+
+		if holdID, err = s.reservations.Hold(ctx, wallet.ID, assetID, amount); err != nil {
+			return fmt.Errorf("failed to hold sweep amount: %w", err)
+		}
+		sweep = &model.Sweep{
+			WalletID:         wallet.ID,
+			AssetID:          assetID,
+			TreasuryWalletID: treasury.ID,
+			Available:        ledger.FormatAmount(available),
+			Amount:           ledger.FormatAmount(amount),
+			Status:           model.SweepStatusPending,
+		}
+		return nil
+	})
+	if err != nil || sweep == nil {
+		return err
+	}
+
+	if err = s.repo.Create(ctx, sweep); err != nil {
+		s.release(ctx, holdID)
+		return fmt.Errorf("failed to store sweep: %w", err)
+	}
+	err = s.submit(ctx, wallet, treasury, sweep)
+	// a sweep still pending may have been created, its amount stays held until the hold expires
+	if sweep.Status != model.SweepStatusPending {
+		s.release(ctx, holdID)
+	}
+	return err
+}
+
+// available is the Fireblocks available balance of the wallet asset less its reserved amount, zero when the wallet
+// does not hold the asset
+func (s *Sweeper) available(ctx context.Context, wallet model.Wallet, assetID string, reserved *big.Rat) (*big.Rat, error) {
+	balance, statusCode, err := s.fireblocksClient.GetVaultAccountAssetBalance(ctx, wallet.VaultAccountID, assetID)
+	if err != nil {
+		if statusCode == http.StatusNotFound || statusCode == http.StatusBadRequest {
+			return new(big.Rat), nil
+		}
+		return nil, fmt.Errorf("failed to get %s balance: %w", assetID, err)
+	}
+	available, err := ledger.ParseAmount(balance.Available)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s available balance: %w", assetID, err)
+	}
+	return available.Sub(available, reserved), nil
+}
+
+// release drops the hold of a sweep, which expires anyway when it cannot be dropped
+func (s *Sweeper) release(ctx context.Context, holdID string) {
+	if err := s.reservations.Release(context.WithoutCancel(ctx), holdID); err != nil {
+		slog.Warn("Failed to release balance hold", "hold_id", holdID, "error", err)
+	}
+}
+
+// submit sends a sweep to Fireblocks and stores its outcome. The sweep is still pending when Fireblocks failed
+// transiently, including when it rate limited the sweep or was still handling the sweep sent with the same
+// idempotency key.
+func (s *Sweeper) submit(ctx context.Context, wallet, treasury model.Wallet, sweep *model.Sweep) error {
+	if wallet.ID == "" || treasury.ID == "" {
+		return fmt.Errorf("wallet %s or treasury wallet %s not found", sweep.WalletID, sweep.TreasuryWalletID)
+	}
+
+	// once sent, the transaction may exist in Fireblocks, so the outcome is stored whatever happens to the context
+	ctx = fireblocks.WithIdempotencyKey(context.WithoutCancel(ctx), IdempotencyKey(sweep.ID))
+	sweep.Attempts++
+	note := fmt.Sprintf("Sweep %s", sweep.ID)
+	fbReq := fireblocks.NewVaultToVaultTransferRequest(sweep.AssetID, wallet.VaultAccountID, treasury.VaultAccountID, sweep.Amount, note)
+	fbResp, statusCode, err := s.fireblocksClient.CreateTransaction(ctx, fbReq)
+	switch {
+	case err == nil:
+		sweep.Status = model.SweepStatusSubmitted
+		sweep.FireblocksTxID = fbResp.ID
+		sweep.Error = ""
+	case fireblocks.Rejected(statusCode):
+		slog.Error("Sweep rejected by Fireblocks", "sweep_id", sweep.ID, "wallet_id", sweep.WalletID, "error", err)
+		sweep.Status = model.SweepStatusFailed
+		sweep.Error = "Rejected by Fireblocks"
+	default:
+		slog.Error("Failed to submit sweep", "sweep_id", sweep.ID, "wallet_id", sweep.WalletID, "attempts", sweep.Attempts, "error", err)
+		sweep.Error = "Fireblocks unavailable"
+		if sweep.Attempts >= maxAttempts {
+			sweep.Status = model.SweepStatusFailed
+		}
+	}
+	if err == nil {
+		s.balances.Invalidate(ctx, wallet.VaultAccountID, sweep.AssetID)
+		metrics.ObserveTransferInitiated(sweep.AssetID, sweep.Amount)
+		// the sweep is tracked and booked in the ledger like any transfer out of the wallet
+		transfer := model.Transfer{
+			WalletID:           sweep.WalletID,
+			FireblocksTxID:     fbResp.ID,
+			AssetID:            sweep.AssetID,
+			Amount:             sweep.Amount,
+			DestinationAddress: TreasuryAddress(treasury.VaultAccountID),
+			Note:               note,
+			Status:             fbResp.Status,
+		}
+		// a sweep sent again after its outcome was lost gets the same transaction, which is already stored
+		if err := s.transferRepo.Create(&transfer); err != nil {
+			slog.Error("Failed to store sweep transfer", "sweep_id", sweep.ID, "transaction_id", fbResp.ID, "error", err)
+		}
+	}
+	if err := s.repo.Save(ctx, sweep); err != nil {
+		// the sweep stays pending in the database and is sent again with the same idempotency key
+		return fmt.Errorf("failed to store sweep %s: %w", sweep.ID, err)
+	}
+	slog.Info("Sweep sent", "sweep_id", sweep.ID, "wallet_id", sweep.WalletID, "asset_id", sweep.AssetID, "amount", sweep.Amount, "status", sweep.Status)
+	return nil
+}
@@ -0,0 +1,391 @@
+package sweep
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/fireblocks/fireblockstest"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testAPIKey = "sim-api-key"
+
+// MemoryRepository keeps the sweeps like the database does, in creation order
+type MemoryRepository struct {
+	sweeps []model.Sweep
+	// FailSaves makes the outcome updates fail, as if the service stopped before storing them
+	FailSaves bool
+}
+
+func (m *MemoryRepository) Create(_ context.Context, sweep *model.Sweep) error {
+	sweep.ID = "sweep-" + strconv.Itoa(len(m.sweeps)+1)
+	m.sweeps = append(m.sweeps, *sweep)
+	return nil
+}
+
+func (m *MemoryRepository) Save(_ context.Context, sweep *model.Sweep) error {
+	if m.FailSaves {
+		return errors.New("connection reset")
+	}
+	for i := range m.sweeps {
+		if m.sweeps[i].ID == sweep.ID {
+			m.sweeps[i] = *sweep
+		}
+	}
+	return nil
+}
+
+func (m *MemoryRepository) ListPending(_ context.Context) ([]model.Sweep, error) {
+	var sweeps []model.Sweep
+	for _, sweep := range m.sweeps {
+		if sweep.Status == model.SweepStatusPending {
+			sweeps = append(sweeps, sweep)
+		}
+	}
+	return sweeps, nil
+}
+
+type MemoryWalletRepository struct {
+	wallets []model.Wallet
+}
+
+func (m *MemoryWalletRepository) List(_ context.Context) ([]model.Wallet, error) {
+	return m.wallets, nil
+}
+
+type MemoryTransferRepository struct {
+	Transfers map[string]model.Transfer
+}
+
+func (m *MemoryTransferRepository) Create(transfer *model.Transfer) error {
+	// like the database, the transaction stored first is kept
+	if _, ok := m.Transfers[transfer.FireblocksTxID]; ok {
+		return nil
+	}
+	m.Transfers[transfer.FireblocksTxID] = *transfer
+	return nil
+}
+
+// MockReservations reserves the same amount in every wallet and records the holds
+type MockReservations struct {
+	Amount string
+	// Holds are the amounts held by hold ID, Released the holds released
+	Holds    map[string]string
+	Released []string
+}
+
+func (m *MockReservations) Reserve(ctx context.Context, walletID, assetID string, fn func(ctx context.Context, reserved *big.Rat) error) error {
+	reserved, _ := m.Reserved(ctx, walletID, assetID)
+	return fn(ctx, reserved)
+}
+
+func (m *MockReservations) Reserved(_ context.Context, _, _ string) (*big.Rat, error) {
+	amount, _ := new(big.Rat).SetString(m.Amount)
+	return amount, nil
+}
+
+func (m *MockReservations) Hold(_ context.Context, _, _ string, amount *big.Rat) (string, error) {
+	holdID := "hold-" + strconv.Itoa(len(m.Holds)+1)
+	m.Holds[holdID] = amount.FloatString(2)
+	return holdID, nil
+}
+
+func (m *MockReservations) Release(_ context.Context, holdID string) error {
+	m.Released = append(m.Released, holdID)
+	return nil
+}
+
+type MockBalanceCache struct {
+	Invalidated int
+}
+
+func (m *MockBalanceCache) Invalidate(_ context.Context, _, _ string) {
+	m.Invalidated++
+}
+
+type AlwaysLeader struct{}
+
+func (AlwaysLeader) IsLeader(context.Context) bool {
+	return true
+}
+
+type testEnv struct {
+	sweeper      *Sweeper
+	sim          *fireblockstest.Server
+	client       *fireblocks.Client
+	repo         *MemoryRepository
+	transfers    *MemoryTransferRepository
+	reservations *MockReservations
+	balances     *MockBalanceCache
+	rule         Rule
+	// treasury, rich, poor and bitcoin are the vault accounts of the wallets of the same names
+	treasury, rich, poor, bitcoin string
+	// unavailable is how many transactions the simulator fails to create with unavailableStatus, a 503 by default,
+	// before it recovers
+	unavailable       atomic.Int32
+	unavailableStatus int
+	// created counts the transaction requests that reached the simulator
+	created atomic.Int32
+}
+
+// newTestEnv sweeps ETH_TEST5 over 1 into the treasury wallet, leaving 0.1, against the Fireblocks simulator. The rich
+// wallet holds 2 ETH_TEST5, the poor one 0.5 and the bitcoin one only BTC_TEST. The rich and bitcoin wallets also hold
+// 200 USDC_TEST, a token paying its fees in ETH_TEST5, which is not swept unless a rule is added for it.
+func newTestEnv(t *testing.T) *testEnv {
+	ctx := context.Background()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	env := &testEnv{
+		repo:         &MemoryRepository{},
+		transfers:    &MemoryTransferRepository{Transfers: map[string]model.Transfer{}},
+		reservations: &MockReservations{Amount: "0", Holds: map[string]string{}},
+		balances:     &MockBalanceCache{},
+		rule:         Rule{Threshold: "1", MinLeftover: "0.1", TreasuryWalletID: "treasury"},
+
+		unavailableStatus: http.StatusServiceUnavailable,
+	}
+	env.sim = fireblockstest.NewServer(fireblockstest.Config{APIKey: testAPIKey, PublicKey: &key.PublicKey})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/v1/transactions" {
+			if env.unavailable.Add(-1) >= 0 {
+				w.WriteHeader(env.unavailableStatus)
+				return
+			}
+			env.created.Add(1)
+		}
+		env.sim.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	env.client = fireblocks.NewClient(server.URL, testAPIKey, key)
+
+	walletRepo := &MemoryWalletRepository{}
+	vaults := map[string]*string{"treasury": &env.treasury, "rich": &env.rich, "poor": &env.poor, "bitcoin": &env.bitcoin}
+	deposits := map[string][2]string{"rich": {"ETH_TEST5", "2"}, "poor": {"ETH_TEST5", "0.5"}, "bitcoin": {"BTC_TEST", "3"}}
+	for _, name := range []string{"treasury", "rich", "poor", "bitcoin"} {
+		vault, _, err := env.client.CreateVaultAccount(ctx, fireblocks.CreateVaultAccountRequest{Name: name})
+		assert.NoError(t, err)
+		*vaults[name] = vault.ID
+		walletRepo.wallets = append(walletRepo.wallets, model.Wallet{ID: name, VaultAccountID: vault.ID})
+		if deposit, ok := deposits[name]; ok {
+			_, err = env.sim.Deposit(ctx, vault.ID, deposit[0], deposit[1])
+			assert.NoError(t, err)
+		}
+		if name == "rich" || name == "bitcoin" {
+			_, err = env.sim.Deposit(ctx, vault.ID, "USDC_TEST", "200")
+			assert.NoError(t, err)
+		}
+	}
+	env.sim.Advance(ctx)
+
+	env.sweeper = NewSweeper(env.repo, walletRepo, env.transfers, env.client, env.reservations, env.balances, AlwaysLeader{}, Config{
+		Rules:    map[string]Rule{"ETH_TEST5": env.rule},
+		Interval: time.Minute,
+	})
+	return env
+}
+
+func (e *testEnv) balance(t *testing.T, vaultAccountID string) *fireblocks.GetVaultAccountAssetBalanceResponse {
+	balance, _, err := e.client.GetVaultAccountAssetBalance(context.Background(), vaultAccountID, "ETH_TEST5")
+	assert.NoError(t, err)
+	return balance
+}
+
+func TestSweep(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	env.sweeper.Sweep(ctx)
+
+	assert.Len(t, env.repo.sweeps, 1)
+	sweep := env.repo.sweeps[0]
+	assert.Equal(t, "rich", sweep.WalletID)
+	assert.Equal(t, "ETH_TEST5", sweep.AssetID)
+	assert.Equal(t, "treasury", sweep.TreasuryWalletID)
+	assert.Equal(t, "2", sweep.Available)
+	assert.Equal(t, "1.9", sweep.Amount)
+	assert.Equal(t, model.SweepStatusSubmitted, sweep.Status)
+	assert.Equal(t, 1, sweep.Attempts)
+	assert.Empty(t, sweep.Error)
+
+	transfer := env.transfers.Transfers[sweep.FireblocksTxID]
+	assert.Equal(t, "rich", transfer.WalletID)
+	assert.Equal(t, "1.9", transfer.Amount)
+	assert.Equal(t, TreasuryAddress(env.treasury), transfer.DestinationAddress)
+	assert.Equal(t, 1, env.balances.Invalidated)
+	assert.Equal(t, "0.1", env.balance(t, env.rich).Available)
+	// the amount is held until the sweep is submitted
+	assert.Equal(t, map[string]string{"hold-1": "1.90"}, env.reservations.Holds)
+	assert.Equal(t, []string{"hold-1"}, env.reservations.Released)
+
+	// what the sweep left is under the threshold
+	env.sweeper.Sweep(ctx)
+	assert.Len(t, env.repo.sweeps, 1)
+
+	for range 4 {
+		env.sim.Advance(ctx)
+	}
+	assert.Equal(t, "1.9", env.balance(t, env.treasury).Total)
+	assert.Equal(t, "0.1", env.balance(t, env.rich).Total)
+}
+
+func TestSweepReserved(t *testing.T) {
+	env := newTestEnv(t)
+	env.reservations.Amount = "0.5"
+
+	env.sweeper.Sweep(context.Background())
+
+	assert.Len(t, env.repo.sweeps, 1)
+	assert.Equal(t, "1.5", env.repo.sweeps[0].Available)
+	assert.Equal(t, "1.4", env.repo.sweeps[0].Amount)
+	// the funds of the payouts not submitted yet stay in the wallet
+	assert.Equal(t, "0.6", env.balance(t, env.rich).Available)
+}
+
+func TestSweepToken(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	env.sweeper.config.Rules = map[string]Rule{
+		"USDC_TEST": {Threshold: "100", MinLeftover: "0.1", TreasuryWalletID: "treasury", FeeAsset: "ETH_TEST5"},
+	}
+
+	env.sweeper.Sweep(ctx)
+
+	// the bitcoin wallet holds no ETH_TEST5 to pay the fees of the token, the rich one sweeps it whole
+	assert.Len(t, env.repo.sweeps, 1)
+	sweep := env.repo.sweeps[0]
+	assert.Equal(t, "rich", sweep.WalletID)
+	assert.Equal(t, "USDC_TEST", sweep.AssetID)
+	assert.Equal(t, "200", sweep.Amount)
+	assert.Equal(t, model.SweepStatusSubmitted, sweep.Status)
+
+	balance, _, err := env.client.GetVaultAccountAssetBalance(ctx, env.rich, "USDC_TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, "0", balance.Available)
+	balance, _, err = env.client.GetVaultAccountAssetBalance(ctx, env.bitcoin, "USDC_TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, "200", balance.Available)
+}
+
+func TestSweepTokenReservedFee(t *testing.T) {
+	env := newTestEnv(t)
+	env.sweeper.config.Rules = map[string]Rule{
+		"USDC_TEST": {Threshold: "100", MinLeftover: "0.1", TreasuryWalletID: "treasury", FeeAsset: "ETH_TEST5"},
+	}
+	// the payouts not submitted yet take all the ETH_TEST5 of the rich wallet
+	env.reservations.Amount = "2"
+
+	env.sweeper.Sweep(context.Background())
+
+	assert.Empty(t, env.repo.sweeps)
+	assert.Equal(t, int32(0), env.created.Load())
+}
+
+func TestSweepUnavailable(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	env.unavailable.Store(1)
+
+	env.sweeper.Sweep(ctx)
+
+	assert.Len(t, env.repo.sweeps, 1)
+	assert.Equal(t, model.SweepStatusPending, env.repo.sweeps[0].Status)
+	assert.Equal(t, "Fireblocks unavailable", env.repo.sweeps[0].Error)
+	assert.Empty(t, env.transfers.Transfers)
+	// the sweep may have been created, so its amount stays held
+	assert.Len(t, env.reservations.Holds, 1)
+	assert.Empty(t, env.reservations.Released)
+
+	// the pending sweep is sent again instead of a new one being made
+	env.sweeper.Sweep(ctx)
+
+	assert.Len(t, env.repo.sweeps, 1)
+	assert.Equal(t, model.SweepStatusSubmitted, env.repo.sweeps[0].Status)
+	assert.Equal(t, 2, env.repo.sweeps[0].Attempts)
+	assert.Empty(t, env.repo.sweeps[0].Error)
+	assert.Len(t, env.transfers.Transfers, 1)
+}
+
+func TestSweepRetriesTransientRejections(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+	}{
+		{name: "rate_limited", statusCode: http.StatusTooManyRequests},
+		{name: "in_progress", statusCode: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			ctx := context.Background()
+			env.unavailableStatus = tt.statusCode
+			env.unavailable.Store(1)
+
+			env.sweeper.Sweep(ctx)
+			assert.Equal(t, model.SweepStatusPending, env.repo.sweeps[0].Status)
+
+			env.sweeper.Sweep(ctx)
+			assert.Len(t, env.repo.sweeps, 1)
+			assert.Equal(t, model.SweepStatusSubmitted, env.repo.sweeps[0].Status)
+		})
+	}
+}
+
+func TestSweepLostOutcome(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	env.repo.FailSaves = true
+
+	env.sweeper.Sweep(ctx)
+	assert.Equal(t, model.SweepStatusPending, env.repo.sweeps[0].Status)
+	assert.Len(t, env.transfers.Transfers, 1)
+
+	// Fireblocks answers with the transaction created the first time
+	env.repo.FailSaves = false
+	env.sweeper.Sweep(ctx)
+
+	assert.Len(t, env.repo.sweeps, 1)
+	assert.Equal(t, model.SweepStatusSubmitted, env.repo.sweeps[0].Status)
+	assert.Contains(t, env.transfers.Transfers, env.repo.sweeps[0].FireblocksTxID)
+	assert.Equal(t, int32(2), env.created.Load())
+	assert.Equal(t, "0.1", env.balance(t, env.rich).Available)
+}
+
+func TestSweepRejected(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	env.unavailable.Store(maxAttempts)
+
+	for range maxAttempts {
+		env.sweeper.Sweep(ctx)
+	}
+
+	// a sweep that cannot be sent is given up on, and the balance is swept again on the next run
+	assert.Len(t, env.repo.sweeps, 1)
+	assert.Equal(t, model.SweepStatusFailed, env.repo.sweeps[0].Status)
+	assert.Equal(t, maxAttempts, env.repo.sweeps[0].Attempts)
+	env.sweeper.Sweep(ctx)
+	assert.Len(t, env.repo.sweeps, 2)
+	assert.Equal(t, model.SweepStatusSubmitted, env.repo.sweeps[1].Status)
+}
+
+func TestSweepTreasuryNotFound(t *testing.T) {
+	env := newTestEnv(t)
+	env.sweeper.config.Rules["ETH_TEST5"] = Rule{Threshold: "1", TreasuryWalletID: "unknown"}
+
+	env.sweeper.Sweep(context.Background())
+
+	assert.Empty(t, env.repo.sweeps)
+	assert.Equal(t, int32(0), env.created.Load())
+}
@@ -0,0 +1,174 @@
+// Package testdb provides Postgres databases to the integration tests, which are built with the integration tag:
+//
+//	go test -tags integration ./...
+//
+// The databases are created in the server of INTEGRATION_DATABASE_URL, e.g. the one of make db-up, or in a throwaway
+// server started with the initdb and pg_ctl binaries found in the PATH.
+package testdb
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/database"
+	"firego-wallet-service/internal/migrate"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// URLVariable names the environment variable of the server to create the databases in, as a postgres:// URL
+const URLVariable = "INTEGRATION_DATABASE_URL"
+
+// Server is the Postgres server the test databases are created in
+type Server struct {
+	url  *url.URL
+	stop func()
+}
+
+// Start connects to the server of INTEGRATION_DATABASE_URL, or starts one when it is not set
+func Start() (*Server, error) {
+	if rawURL := os.Getenv(URLVariable); rawURL != "" {
+		serverURL, err := url.Parse(rawURL)
+		if err != nil || serverURL.Scheme == "" {
+			return nil, fmt.Errorf("%s must be a postgres:// URL", URLVariable)
+		}
+		return &Server{url: serverURL, stop: func() {}}, nil
+	}
+	return startLocal()
+}
+
+// Stop stops the server if it was started by Start
+func (s *Server) Stop() {
+	s.stop()
+}
+
+// NewDatabase creates an empty database, dropped when the test ends, and returns its URL
+func (s *Server) NewDatabase(t testing.TB) string {
+	t.Helper()
+	name := "firego_test_" + strings.ReplaceAll(uuid.New().String(), "-", "")[:16]
+	if err := s.exec(fmt.Sprintf("CREATE DATABASE %s", name)); err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := s.exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s WITH (FORCE)", name)); err != nil {
+			t.Logf("failed to drop database %s: %v", name, err)
+		}
+	})
+
+	databaseURL := *s.url
+	databaseURL.Path = "/" + name
+	return databaseURL.String()
+}
+
+// NewMigratedDatabase creates a database with the migrations applied and connects to it
+func (s *Server) NewMigratedDatabase(t testing.TB) *gorm.DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := database.Connect(ctx, database.Config{
+		URL:            s.NewDatabase(t),
+		ConnectTimeout: 10 * time.Second,
+		MaxOpenConns:   10,
+		MaxIdleConns:   2,
+		LogLevel:       logger.Warn,
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get database handle: %v", err)
+	}
+	// registered after the database drop, so that it runs before it
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrator, err := migrate.New(sqlDB)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err = migrator.Up(ctx); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+	return db
+}
+
+// exec runs a statement on the server's default database
+func (s *Server) exec(statement string) error {
+	connConfig, err := pgx.ParseConfig(s.url.String())
+	if err != nil {
+		return err
+	}
+	db := stdlib.OpenDB(*connConfig)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err = db.ExecContext(ctx, statement)
+	return err
+}
+
+// startLocal initializes and starts a server in a temporary directory, listening on a free local port
+func startLocal() (*Server, error) {
+	initdb, err := exec.LookPath("initdb")
+	if err != nil {
+		return nil, fmt.Errorf("set %s or add the Postgres binaries (initdb, pg_ctl) to the PATH: %w", URLVariable, err)
+	}
+	pgCtl, err := exec.LookPath("pg_ctl")
+	if err != nil {
+		return nil, fmt.Errorf("set %s or add the Postgres binaries (initdb, pg_ctl) to the PATH: %w", URLVariable, err)
+	}
+
+	dir, err := os.MkdirTemp("", "firego-postgres-")
+	if err != nil {
+		return nil, err
+	}
+	dataDir := filepath.Join(dir, "data")
+	if out, err := exec.Command(initdb, "-D", dataDir, "-U", "postgres", "-A", "trust", "--no-sync").CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("initdb failed: %w\n%s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1 -c fsync=off", port, dir)
+	if out, err := exec.Command(pgCtl, "-D", dataDir, "-l", filepath.Join(dir, "postgres.log"), "-o", options, "-w", "start").CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("pg_ctl start failed: %w\n%s", err, out)
+	}
+
+	stop := func() {
+		exec.Command(pgCtl, "-D", dataDir, "-m", "immediate", "stop").Run()
+		os.RemoveAll(dir)
+	}
+	return &Server{
+		url:  &url.URL{Scheme: "postgres", User: url.User("postgres"), Host: net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), Path: "/postgres", RawQuery: "sslmode=disable"},
+		stop: stop,
+	}, nil
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	addr, ok := listener.Addr().(*net.TCPAddr)
+	if !ok {
+		return 0, errors.New("failed to find a free port")
+	}
+	return addr.Port, nil
+}
@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"os"
+)
+
+const serviceName = "firego-wallet-service"
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+// Attribute keys shared by the spans of the service. Only identifiers and statuses are recorded, never request
+// bodies, addresses or credentials.
+const (
+	WalletID          = attribute.Key("wallet.id")
+	VaultAccountID    = attribute.Key("fireblocks.vault_account.id")
+	AssetID           = attribute.Key("fireblocks.asset.id")
+	TransactionID     = attribute.Key("fireblocks.transaction.id")
+	TransactionStatus = attribute.Key("fireblocks.transaction.status")
+)
+
+type Config struct {
+	// Exporter is one of none, stdout, file or otlp. The otlp exporter is configured through the standard
+	// OTEL_EXPORTER_OTLP_* environment variables.
+	Exporter string
+	// FilePath is where the file exporter appends spans, one JSON document per span
+	FilePath string
+}
+
+// Setup installs the global tracer provider and the W3C trace context propagator. The returned function flushes
+// pending spans and must be called on shutdown. With the none exporter spans are not recorded, but incoming
+// trace context is still propagated to Fireblocks.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var closeFile func() error
+	var err error
+	switch config.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterFile:
+		if config.FilePath == "" {
+			return nil, fmt.Errorf("a file path is required by the file exporter")
+		}
+		file, openErr := os.OpenFile(config.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if openErr != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", openErr)
+		}
+		closeFile = file.Close
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", config.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", config.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeFile != nil {
+			if closeErr := closeFile(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
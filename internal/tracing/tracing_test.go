@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /wallets/{walletId}/deposits", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/wallets/123/deposits", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	Middleware(mux).ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "GET /wallets/{walletId}/deposits", span.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Equal(t, codes.Error, span.Status().Code)
+}
+
+func TestSetup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterFile, FilePath: path})
+	assert.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "test-span")
+	span.End()
+	assert.NoError(t, shutdown(context.Background()))
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(content), `"Name":"test-span"`))
+	assert.True(t, strings.Contains(string(content), serviceName))
+
+	_, err = Setup(context.Background(), Config{Exporter: ExporterFile})
+	assert.Error(t, err)
+
+	_, err = Setup(context.Background(), Config{Exporter: "jaeger"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package transfer
+
+import (
+	"context"
+	"firego-wallet-service/internal/fireblocks"
+	"log/slog"
+	"time"
+)
+
+type FireblocksClient interface {
+	GetTransaction(ctx context.Context, txID string) (*fireblocks.TransactionResponse, int, error)
+}
+
+type Elector interface {
+	IsLeader(ctx context.Context) bool
+}
+
+// Poller is the fallback for missed webhooks, on the elected leader it refreshes every transfer that has not reached
+// a terminal status
+type Poller struct {
+	fireblocksClient FireblocksClient
+	transferRepo     TransferRepository
+	processor        *Processor
+	elector          Elector
+	interval         time.Duration
+}
+
+func NewPoller(fireblocksClient FireblocksClient, transferRepo TransferRepository, processor *Processor, elector Elector, interval time.Duration) *Poller {
+	return &Poller{
+		fireblocksClient: fireblocksClient,
+		transferRepo:     transferRepo,
+		processor:        processor,
+		elector:          elector,
+		interval:         interval,
+	}
+}
+
+// Run polls on the elected leader until the context is cancelled
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if p.elector.IsLeader(ctx) {
+			p.Poll(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll runs a single polling cycle
+func (p *Poller) Poll(ctx context.Context) {
+	transfers, err := p.transferRepo.ListExcludingStatuses(fireblocks.TerminalTransactionStatuses)
+	if err != nil {
+		slog.Error("Failed to list open transfers", "error", err)
+		return
+	}
+
+	for _, transfer := range transfers {
+		tx, _, err := p.fireblocksClient.GetTransaction(ctx, transfer.FireblocksTxID)
+		if err != nil {
+			slog.Error("Failed to get transaction for transfer", "transaction_id", transfer.FireblocksTxID, "transfer_id", transfer.ID, "error", err)
+			continue
+		}
+		if err = p.processor.ProcessTransaction(ctx, *tx); err != nil {
+			slog.Error("Failed to process transaction", "transaction_id", tx.ID, "error", err)
+		}
+	}
+}
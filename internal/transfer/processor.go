@@ -0,0 +1,104 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/metrics"
+	"firego-wallet-service/internal/model"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+type TransferRepository interface {
+	Save(transfer *model.Transfer) error
+	GetByFireblocksTxID(txID string) (*model.Transfer, error)
+	ListExcludingStatuses(statuses []string) ([]model.Transfer, error)
+}
+
+// VaultWalletRepository finds the wallet of the vault account a transfer goes to
+type VaultWalletRepository interface {
+	GetByVaultAccountID(ctx context.Context, vaultAccountID string) (*model.Wallet, error)
+}
+
+type Ledger interface {
+	PostTransfer(transfer model.Transfer) error
+	PostInternalTransfer(transfer model.Transfer, destinationWalletID string) error
+}
+
+// Processor keeps locally stored transfers in sync with their Fireblocks transaction and books them once completed
+type Processor struct {
+	transferRepo TransferRepository
+	walletRepo   VaultWalletRepository
+	ledger       Ledger
+}
+
+func NewProcessor(transferRepo TransferRepository, walletRepo VaultWalletRepository, ledger Ledger) *Processor {
+	return &Processor{
+		transferRepo: transferRepo,
+		walletRepo:   walletRepo,
+		ledger:       ledger,
+	}
+}
+
+// ProcessTransaction updates the transfer created for the transaction, if any.
+// Transactions that were not initiated through this service are ignored.
+func (p *Processor) ProcessTransaction(ctx context.Context, tx fireblocks.TransactionResponse) error {
+	if tx.Source.Type != "VAULT_ACCOUNT" {
+		return nil
+	}
+
+	transfer, err := p.transferRepo.GetByFireblocksTxID(tx.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get transfer for transaction %s: %w", tx.ID, err)
+	}
+
+	if fireblocks.IsTerminalTransactionStatus(transfer.Status) {
+		return nil
+	}
+
+	transfer.Status = tx.Status
+	transfer.SubStatus = tx.SubStatus
+	transfer.TxHash = tx.TxHash
+	transfer.NetworkFee = tx.FeeInfo.NetworkFee
+	transfer.FeeCurrency = tx.FeeCurrency
+	if tx.AmountInfo.Amount != "" {
+		transfer.Amount = tx.AmountInfo.Amount
+	}
+
+	if transfer.Status == fireblocks.TransactionStatusCompleted {
+		// book before saving: if saving fails the transfer stays open and the replayed posting is a no-op
+		if err = p.post(ctx, tx, *transfer); err != nil {
+			return fmt.Errorf("failed to post transfer for transaction %s to the ledger: %w", tx.ID, err)
+		}
+	}
+
+	if err = p.transferRepo.Save(transfer); err != nil {
+		return fmt.Errorf("failed to save transfer for transaction %s: %w", tx.ID, err)
+	}
+	if fireblocks.IsTerminalTransactionStatus(transfer.Status) {
+		metrics.ObserveTransferFinished(transfer.AssetID, transfer.Status)
+	}
+
+	return nil
+}
+
+// post books a transfer out of the books, or into the wallet of the vault account it went to, such as the treasury
+// wallet of a sweep, which the deposit processor leaves to it
+func (p *Processor) post(ctx context.Context, tx fireblocks.TransactionResponse, transfer model.Transfer) error {
+	if tx.Destination.Type != "VAULT_ACCOUNT" || tx.Destination.ID == "" {
+		return p.ledger.PostTransfer(transfer)
+	}
+
+	wallet, err := p.walletRepo.GetByVaultAccountID(ctx, tx.Destination.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return p.ledger.PostTransfer(transfer)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get wallet for vault account %s: %w", tx.Destination.ID, err)
+	}
+	return p.ledger.PostInternalTransfer(transfer, wallet.ID)
+}
@@ -0,0 +1,204 @@
+package transfer
+
+import (
+	"context"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+)
+
+type MockTransferRepository struct {
+	Transfers map[string]*model.Transfer
+	SaveError error
+	SaveCalls int
+}
+
+func (m *MockTransferRepository) Save(transfer *model.Transfer) error {
+	m.SaveCalls++
+	if m.SaveError != nil {
+		return m.SaveError
+	}
+	m.Transfers[transfer.FireblocksTxID] = transfer
+	return nil
+}
+
+func (m *MockTransferRepository) GetByFireblocksTxID(txID string) (*model.Transfer, error) {
+	transfer, ok := m.Transfers[txID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *transfer
+	return &copied, nil
+}
+
+func (m *MockTransferRepository) ListExcludingStatuses(statuses []string) ([]model.Transfer, error) {
+	var transfers []model.Transfer
+	for _, transfer := range m.Transfers {
+		if !fireblocks.IsTerminalTransactionStatus(transfer.Status) {
+			transfers = append(transfers, *transfer)
+		}
+	}
+	return transfers, nil
+}
+
+// MockVaultWalletRepository knows the wallets of the vault accounts by ID
+type MockVaultWalletRepository map[string]string
+
+func (m MockVaultWalletRepository) GetByVaultAccountID(_ context.Context, vaultAccountID string) (*model.Wallet, error) {
+	walletID, ok := m[vaultAccountID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &model.Wallet{ID: walletID, VaultAccountID: vaultAccountID}, nil
+}
+
+type MockLedger struct {
+	Posted []model.Transfer
+	// Destinations are the destination wallets of the posted transfers, empty for the external ones
+	Destinations []string
+	Error        error
+}
+
+func (m *MockLedger) PostTransfer(transfer model.Transfer) error {
+	return m.PostInternalTransfer(transfer, "")
+}
+
+func (m *MockLedger) PostInternalTransfer(transfer model.Transfer, destinationWalletID string) error {
+	if m.Error != nil {
+		return m.Error
+	}
+	m.Posted = append(m.Posted, transfer)
+	m.Destinations = append(m.Destinations, destinationWalletID)
+	return nil
+}
+
+func outgoingTx(status string) fireblocks.TransactionResponse {
+	return fireblocks.TransactionResponse{
+		ID:          "tx-1",
+		Status:      status,
+		AssetID:     "BTC_TEST",
+		Source:      fireblocks.TransferPeerPath{Type: "VAULT_ACCOUNT", ID: "86"},
+		Destination: fireblocks.TransferPeerPath{Type: "ONE_TIME_ADDRESS"},
+		TxHash:      "abc123",
+		AmountInfo:  fireblocks.AmountInfo{Amount: "0.0005"},
+		FeeInfo:     fireblocks.FeeInfo{NetworkFee: "0.00001"},
+		FeeCurrency: "BTC_TEST",
+	}
+}
+
+func TestProcessTransaction(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		tx      fireblocks.TransactionResponse
+		ledger  *MockLedger
+		wantErr bool
+		assert  func(t *testing.T, repo *MockTransferRepository, ledger *MockLedger)
+	}{
+		{
+			name:   "status_update",
+			status: fireblocks.TransactionStatusSubmitted,
+			tx:     outgoingTx(fireblocks.TransactionStatusBroadcasting),
+			ledger: &MockLedger{},
+			assert: func(t *testing.T, repo *MockTransferRepository, ledger *MockLedger) {
+				assert.Equal(t, fireblocks.TransactionStatusBroadcasting, repo.Transfers["tx-1"].Status)
+				assert.Equal(t, "abc123", repo.Transfers["tx-1"].TxHash)
+				assert.Empty(t, ledger.Posted)
+			},
+		},
+		{
+			name:   "completed_is_booked",
+			status: fireblocks.TransactionStatusConfirming,
+			tx:     outgoingTx(fireblocks.TransactionStatusCompleted),
+			ledger: &MockLedger{},
+			assert: func(t *testing.T, repo *MockTransferRepository, ledger *MockLedger) {
+				assert.Equal(t, fireblocks.TransactionStatusCompleted, repo.Transfers["tx-1"].Status)
+				assert.Len(t, ledger.Posted, 1)
+				assert.Equal(t, "0.00001", ledger.Posted[0].NetworkFee)
+				assert.Equal(t, "BTC_TEST", ledger.Posted[0].FeeCurrency)
+				assert.Equal(t, []string{""}, ledger.Destinations)
+			},
+		},
+		{
+			name:   "completed_into_wallet_is_booked_into_it",
+			status: fireblocks.TransactionStatusConfirming,
+			tx: func() fireblocks.TransactionResponse {
+				tx := outgoingTx(fireblocks.TransactionStatusCompleted)
+				tx.Destination = fireblocks.TransferPeerPath{Type: "VAULT_ACCOUNT", ID: "1"}
+				return tx
+			}(),
+			ledger: &MockLedger{},
+			assert: func(t *testing.T, repo *MockTransferRepository, ledger *MockLedger) {
+				assert.Equal(t, fireblocks.TransactionStatusCompleted, repo.Transfers["tx-1"].Status)
+				assert.Equal(t, []string{"treasury"}, ledger.Destinations)
+			},
+		},
+		{
+			name:   "completed_into_unknown_vault_is_booked_out",
+			status: fireblocks.TransactionStatusConfirming,
+			tx: func() fireblocks.TransactionResponse {
+				tx := outgoingTx(fireblocks.TransactionStatusCompleted)
+				tx.Destination = fireblocks.TransferPeerPath{Type: "VAULT_ACCOUNT", ID: "99"}
+				return tx
+			}(),
+			ledger: &MockLedger{},
+			assert: func(t *testing.T, repo *MockTransferRepository, ledger *MockLedger) {
+				assert.Equal(t, []string{""}, ledger.Destinations)
+			},
+		},
+		{
+			name:   "terminal_transfer_is_final",
+			status: fireblocks.TransactionStatusCompleted,
+			tx:     outgoingTx(fireblocks.TransactionStatusFailed),
+			ledger: &MockLedger{},
+			assert: func(t *testing.T, repo *MockTransferRepository, ledger *MockLedger) {
+				assert.Equal(t, fireblocks.TransactionStatusCompleted, repo.Transfers["tx-1"].Status)
+				assert.Equal(t, 0, repo.SaveCalls)
+				assert.Empty(t, ledger.Posted)
+			},
+		},
+		{
+			name:   "unknown_transaction_is_ignored",
+			status: fireblocks.TransactionStatusSubmitted,
+			tx: func() fireblocks.TransactionResponse {
+				tx := outgoingTx(fireblocks.TransactionStatusCompleted)
+				tx.ID = "tx-other"
+				return tx
+			}(),
+			ledger: &MockLedger{},
+			assert: func(t *testing.T, repo *MockTransferRepository, ledger *MockLedger) {
+				assert.Equal(t, 0, repo.SaveCalls)
+			},
+		},
+		{
+			name:    "ledger_error_keeps_transfer_open",
+			status:  fireblocks.TransactionStatusConfirming,
+			tx:      outgoingTx(fireblocks.TransactionStatusCompleted),
+			ledger:  &MockLedger{Error: assert.AnError},
+			wantErr: true,
+			assert: func(t *testing.T, repo *MockTransferRepository, ledger *MockLedger) {
+				assert.Equal(t, fireblocks.TransactionStatusConfirming, repo.Transfers["tx-1"].Status)
+				assert.Equal(t, 0, repo.SaveCalls)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &MockTransferRepository{Transfers: map[string]*model.Transfer{
+				"tx-1": {ID: "transfer-1", WalletID: "wallet-1", FireblocksTxID: "tx-1", AssetID: "BTC_TEST", Amount: "0.0005", Status: tt.status},
+			}}
+
+			err := NewProcessor(repo, MockVaultWalletRepository{"1": "treasury"}, tt.ledger).ProcessTransaction(context.Background(), tt.tx)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			tt.assert(t, repo, tt.ledger)
+		})
+	}
+}
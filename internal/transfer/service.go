@@ -0,0 +1,304 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/audit"
+	"firego-wallet-service/internal/cache"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/idempotency"
+	"firego-wallet-service/internal/ledger"
+	"firego-wallet-service/internal/logging"
+	"firego-wallet-service/internal/metrics"
+	"firego-wallet-service/internal/model"
+	"firego-wallet-service/internal/tracing"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var tracer = otel.Tracer("firego-wallet-service/internal/transfer")
+
+const (
+	// StoreAttempts is how many times what Fireblocks created is stored before the failure is reported, see StoreCreated
+	StoreAttempts = 3
+	// storeBackoff is the wait before the second attempt to store it, doubled for the next ones
+	storeBackoff = 100 * time.Millisecond
+)
+
+type WalletRepository interface {
+	GetByID(ctx context.Context, id string) (*model.Wallet, error)
+}
+
+// Store keeps the transfers created in Fireblocks
+type Store interface {
+	Create(transfer *model.Transfer) error
+}
+
+// TransactionCreator creates the transactions in Fireblocks
+type TransactionCreator interface {
+	CreateTransaction(ctx context.Context, req fireblocks.CreateTransactionRequest) (*fireblocks.CreateTransactionResponse, int, error)
+}
+
+// BalanceCache reads the vault asset balances through a cache, see cache.Balances
+type BalanceCache interface {
+	GetVaultAccountAssetBalance(ctx context.Context, vaultAccountID, assetID string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error)
+	Invalidate(ctx context.Context, vaultAccountID, assetID string)
+}
+
+// Reservations runs the balance checks of a wallet asset one at a time, with the amount reserved by its payouts not
+// submitted yet and its holds, see payout.Service.Reserve
+type Reservations interface {
+	Reserve(ctx context.Context, walletID, assetID string, fn func(ctx context.Context, reserved *big.Rat) error) error
+	Hold(ctx context.Context, walletID, assetID string, amount *big.Rat) (string, error)
+	Release(ctx context.Context, holdID string) error
+}
+
+// Request is a transfer out of a wallet
+type Request struct {
+	AssetID            string
+	Amount             string
+	DestinationAddress string
+	Note               string
+	// ExternalTxID is the external ID of the transaction, which it can be looked up by in Fireblocks, none when empty
+	ExternalTxID string
+}
+
+// Error is why a transfer or a payout was not initiated, or a Fireblocks call failed, with the status code and error
+// code the API answers it with
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// FireblocksError is the error of a failed Fireblocks call: a 400 with the rejected message when Fireblocks refused
+// the request, a 429 when the calls to Fireblocks are over its rate limit, and a 503 with the unavailable message when
+// it could not be reached or failed
+func FireblocksError(statusCode int, rejected, unavailable string) *Error {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return &Error{StatusCode: http.StatusTooManyRequests, Code: apierror.RateLimited, Message: "Fireblocks rate limit exceeded"}
+	case fireblocks.Rejected(statusCode):
+		return &Error{StatusCode: http.StatusBadRequest, Code: apierror.FireblocksRejected, Message: rejected}
+	default:
+		return &Error{StatusCode: http.StatusServiceUnavailable, Code: apierror.ServiceUnavailable, Message: unavailable}
+	}
+}
+
+// Service initiates the transfers out of the wallets, those of the API and the scheduled ones alike
+type Service struct {
+	walletRepo       WalletRepository
+	transferRepo     Store
+	fireblocksClient TransactionCreator
+	balances         BalanceCache
+	reservations     Reservations
+	// submissions tracks transfers being created in Fireblocks and stored, see WaitForTransfers
+	submissions sync.WaitGroup
+	// submissionsMu guards draining, so that no submission is added once WaitForTransfers waits for them
+	submissionsMu sync.Mutex
+	draining      bool
+}
+
+func NewService(walletRepo WalletRepository, transferRepo Store, fireblocksClient TransactionCreator, balances BalanceCache, reservations Reservations) *Service {
+	return &Service{
+		walletRepo:       walletRepo,
+		transferRepo:     transferRepo,
+		fireblocksClient: fireblocksClient,
+		balances:         balances,
+		reservations:     reservations,
+	}
+}
+
+// Transfer validates a transfer out of the wallet against its available balance, read from Fireblocks, then creates the
+// transaction in Fireblocks and stores it. It fails with an *Error.
+func (s *Service) Transfer(ctx context.Context, walletID string, req Request) (*model.Transfer, error) {
+	ctx, span := tracer.Start(ctx, "TransferService.Transfer")
+	defer span.End()
+
+	if req.AssetID == "" {
+		return nil, &Error{StatusCode: http.StatusBadRequest, Code: apierror.InvalidRequest, Message: "Asset ID is required"}
+	}
+	if req.Amount == "" {
+		return nil, &Error{StatusCode: http.StatusBadRequest, Code: apierror.InvalidRequest, Message: "Amount is required"}
+	}
+	if req.DestinationAddress == "" {
+		return nil, &Error{StatusCode: http.StatusBadRequest, Code: apierror.InvalidRequest, Message: "Destination address is required"}
+	}
+
+	wallet, err := s.walletRepo.GetByID(ctx, walletID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &Error{StatusCode: http.StatusNotFound, Code: apierror.WalletNotFound, Message: "Wallet not found"}
+		}
+		logging.FromContext(ctx).Error("Failed to get wallet", "error", err)
+		return nil, &Error{StatusCode: http.StatusInternalServerError, Code: apierror.Internal, Message: "Internal server error"}
+	}
+	span.SetAttributes(tracing.VaultAccountID.String(wallet.VaultAccountID))
+
+	span.SetAttributes(tracing.AssetID.String(req.AssetID))
+
+	amount, err := ledger.ParseAmount(req.Amount)
+	if err != nil {
+		return nil, &Error{StatusCode: http.StatusBadRequest, Code: apierror.InvalidAmount, Message: "Invalid amount format"}
+	}
+
+	// the balance is checked fresh, less the payouts not submitted yet and the transfers being submitted, while no
+	// other transfer or payout of the wallet asset is checked. The amount is then held until the transaction, which
+	// takes it out of the available balance, is created, so that the lock is not held while Fireblocks answers.
+	var holdID string
+	err = s.reservations.Reserve(ctx, wallet.ID, req.AssetID, func(ctx context.Context, reserved *big.Rat) error {
+		logging.FromContext(ctx).Debug("Validating balance", "wallet_id", walletID, "asset_id", req.AssetID)
+		balanceResp, statusCode, err := s.balances.GetVaultAccountAssetBalance(cache.Bypass(ctx), wallet.VaultAccountID, req.AssetID)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to get balance for validation", "error", err)
+
+			return FireblocksError(statusCode, "Invalid asset or wallet", "Unable to validate balance")
+		}
+
+		available, err := ledger.ParseAmount(balanceResp.Available)
+		if err != nil {
+			logging.FromContext(ctx).Error("Invalid balance format from Fireblocks", "available", balanceResp.Available)
+			return &Error{StatusCode: http.StatusInternalServerError, Code: apierror.ServiceUnavailable, Message: "Unable to validate balance"}
+		}
+
+		if amount.Cmp(available.Sub(available, reserved)) > 0 {
+			logging.FromContext(ctx).Info("Insufficient balance", "requested", req.Amount, "available", ledger.FormatAmount(available), "reserved", ledger.FormatAmount(reserved))
+			return &Error{StatusCode: http.StatusBadRequest, Code: apierror.InsufficientBalance, Message: "Insufficient balance"}
+		}
+
+		holdID, err = s.reservations.Hold(ctx, wallet.ID, req.AssetID, amount)
+		return err
+	})
+	if err != nil {
+		var transferErr *Error
+		if !errors.As(err, &transferErr) {
+			logging.FromContext(ctx).Error("Failed to reserve balance", "error", err)
+			transferErr = &Error{StatusCode: http.StatusInternalServerError, Code: apierror.Internal, Message: "Internal server error"}
+		}
+		return nil, transferErr
+	}
+
+	fbReq := fireblocks.NewVaultTransferRequest(
+		req.AssetID,
+		wallet.VaultAccountID,
+		req.DestinationAddress,
+		req.Amount,
+		req.Note,
+	)
+	fbReq.ExternalTxID = req.ExternalTxID
+
+	audit.AddFireblocksID(ctx, wallet.VaultAccountID)
+	if !s.startSubmission() {
+		s.release(ctx, holdID)
+		return nil, &Error{StatusCode: http.StatusServiceUnavailable, Code: apierror.ServiceUnavailable, Message: "Service is shutting down"}
+	}
+	defer s.submissions.Done()
+	// once submitted, the transaction may exist in Fireblocks whatever happens to the request, so neither a client
+	// disconnecting nor the server shutting down may stop it from being stored
+	ctx = context.WithoutCancel(ctx)
+	idempotency.Attempted(ctx)
+	fbResp, statusCode, err := s.fireblocksClient.CreateTransaction(ctx, fbReq)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to create transaction in Fireblocks", "error", err)
+		if statusCode == http.StatusTooManyRequests || fireblocks.Rejected(statusCode) {
+			s.release(ctx, holdID)
+		}
+		// otherwise the transaction may have been created, the amount stays held until the hold expires
+
+		return nil, FireblocksError(statusCode, "Invalid request", "Service unavailable")
+	}
+	// the next balance read sees the amount out of the available balance before the hold is released
+	s.balances.Invalidate(ctx, wallet.VaultAccountID, req.AssetID)
+	s.release(ctx, holdID)
+
+	audit.AddFireblocksID(ctx, fbResp.ID)
+	span.SetAttributes(tracing.TransactionID.String(fbResp.ID), tracing.TransactionStatus.String(fbResp.Status))
+	metrics.ObserveTransferInitiated(req.AssetID, req.Amount)
+
+	transfer := model.Transfer{
+		WalletID:           wallet.ID,
+		FireblocksTxID:     fbResp.ID,
+		AssetID:            req.AssetID,
+		Amount:             req.Amount,
+		DestinationAddress: req.DestinationAddress,
+		Note:               req.Note,
+		Status:             fbResp.Status,
+	}
+	err = StoreCreated(ctx, "transfer", func() error { return s.transferRepo.Create(&transfer) }, "transaction_id", fbResp.ID)
+	if err != nil {
+		// the transaction exists in Fireblocks regardless, the caller must not send it again
+		logging.FromContext(ctx).Error("Failed to store transfer", "transaction_id", fbResp.ID, "error", err)
+		return nil, &Error{
+			StatusCode: http.StatusInternalServerError,
+			Code:       apierror.Internal,
+			Message:    fmt.Sprintf("Transfer submitted to Fireblocks as %s but not stored", fbResp.ID),
+		}
+	}
+
+	return &transfer, nil
+}
+
+// release drops the hold of a transfer, which expires anyway when it cannot be dropped
+func (s *Service) release(ctx context.Context, holdID string) {
+	if err := s.reservations.Release(ctx, holdID); err != nil {
+		logging.FromContext(ctx).Warn("Failed to release balance hold", "hold_id", holdID, "error", err)
+	}
+}
+
+// StoreCreated runs store, which stores what Fireblocks created, up to StoreAttempts times with a backoff doubling from
+// storeBackoff, since it is not tracked otherwise. what names it in the logs, with logArgs.
+func StoreCreated(ctx context.Context, what string, store func() error, logArgs ...any) error {
+	backoff := storeBackoff
+	for attempt := 1; ; attempt++ {
+		err := store()
+		if err == nil || attempt >= StoreAttempts {
+			return err
+		}
+		args := append(append([]any{}, logArgs...), "attempt", attempt, "error", err)
+		logging.FromContext(ctx).Warn("Failed to store "+what+", retrying", args...)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// startSubmission tracks a transfer about to be submitted, unless WaitForTransfers was called
+func (s *Service) startSubmission() bool {
+	s.submissionsMu.Lock()
+	defer s.submissionsMu.Unlock()
+	if s.draining {
+		return false
+	}
+	s.submissions.Add(1)
+	return true
+}
+
+// WaitForTransfers blocks until the transfers being submitted to Fireblocks are stored, or the context is done.
+// It is called on shutdown, once the server and the workers stopped; transfers started afterwards are refused.
+func (s *Service) WaitForTransfers(ctx context.Context) error {
+	s.submissionsMu.Lock()
+	s.draining = true
+	s.submissionsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.submissions.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,365 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/cache"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"math/big"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type MockWalletRepository struct {
+	Wallet *model.Wallet
+}
+
+func (m *MockWalletRepository) GetByID(_ context.Context, id string) (*model.Wallet, error) {
+	if m.Wallet == nil || m.Wallet.ID != id {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return m.Wallet, nil
+}
+
+type MockStore struct {
+	CreateError     error
+	CreatedTransfer *model.Transfer
+	// FailCreates is how many calls to Create fail before it succeeds
+	FailCreates int
+	CreateCalls int
+}
+
+func (m *MockStore) Create(transfer *model.Transfer) error {
+	m.CreateCalls++
+	if m.CreateError != nil {
+		return m.CreateError
+	}
+	if m.CreateCalls <= m.FailCreates {
+		return errors.New("connection reset")
+	}
+	m.CreatedTransfer = transfer
+	return nil
+}
+
+type MockFireblocksClient struct {
+	Available                 string
+	CreateTransactionResponse *fireblocks.CreateTransactionResponse
+	StatusCode                int
+	Error                     error
+	// Started and Release, when set, hold CreateTransaction until Release is closed
+	Started chan struct{}
+	Release chan struct{}
+
+	CreateCalls        int
+	ReceivedContextErr error
+}
+
+func (m *MockFireblocksClient) GetVaultAccountAssetBalance(_ context.Context, _, assetID string) (*fireblocks.GetVaultAccountAssetBalanceResponse, int, error) {
+	return &fireblocks.GetVaultAccountAssetBalanceResponse{ID: assetID, Available: m.Available}, http.StatusOK, nil
+}
+
+func (m *MockFireblocksClient) CreateTransaction(ctx context.Context, _ fireblocks.CreateTransactionRequest) (*fireblocks.CreateTransactionResponse, int, error) {
+	m.CreateCalls++
+	if m.Started != nil {
+		close(m.Started)
+		<-m.Release
+	}
+	m.ReceivedContextErr = ctx.Err()
+	return m.CreateTransactionResponse, m.StatusCode, m.Error
+}
+
+type MockReservations struct {
+	// Reserved is the amount reserved by the payouts, none when empty
+	Reserved string
+	Error    error
+	Calls    int
+	// Held is the amount of the hold not released, if any
+	Held     string
+	Holds    int
+	Released int
+}
+
+func (m *MockReservations) Reserve(ctx context.Context, _, _ string, fn func(ctx context.Context, reserved *big.Rat) error) error {
+	m.Calls++
+	if m.Error != nil {
+		return m.Error
+	}
+	reserved := new(big.Rat)
+	if m.Reserved != "" {
+		reserved.SetString(m.Reserved)
+	}
+	return fn(ctx, reserved)
+}
+
+func (m *MockReservations) Hold(_ context.Context, _, _ string, amount *big.Rat) (string, error) {
+	m.Holds++
+	m.Held = amount.RatString()
+	return "hold-" + strconv.Itoa(m.Holds), nil
+}
+
+func (m *MockReservations) Release(_ context.Context, _ string) error {
+	m.Released++
+	m.Held = ""
+	return nil
+}
+
+// newTestService transfers out of wallet 123, holding 0.001 BTC_TEST, read without caching
+func newTestService(store *MockStore, client *MockFireblocksClient, reservations *MockReservations) *Service {
+	client.Available = "0.001"
+	if client.CreateTransactionResponse == nil {
+		client.CreateTransactionResponse = &fireblocks.CreateTransactionResponse{ID: "eff51bfd-8cec-4b77-b01e-b1aff84dcf49", Status: "SUBMITTED"}
+		client.StatusCode = http.StatusOK
+	}
+	walletRepo := &MockWalletRepository{Wallet: &model.Wallet{ID: "123", Name: "Test", VaultAccountID: "vault-account-id"}}
+	return NewService(walletRepo, store, client, cache.NewBalances(client, nil, 0), reservations)
+}
+
+func testRequest(amount string) Request {
+	return Request{AssetID: "BTC_TEST", Amount: amount, DestinationAddress: "tb1q24jg2svw7430u3slcp0rlml7u2tse3h53q0jwe"}
+}
+
+func TestTransfer(t *testing.T) {
+	tests := []struct {
+		name       string
+		walletID   string
+		request    Request
+		client     *MockFireblocksClient
+		statusCode int
+		wantCode   string
+	}{
+		{name: "missing_asset_id", walletID: "123", request: Request{Amount: "0.0005", DestinationAddress: "tb1q"}, statusCode: http.StatusBadRequest, wantCode: apierror.InvalidRequest},
+		{name: "missing_amount", walletID: "123", request: Request{AssetID: "BTC_TEST", DestinationAddress: "tb1q"}, statusCode: http.StatusBadRequest, wantCode: apierror.InvalidRequest},
+		{name: "invalid_amount", walletID: "123", request: testRequest("abc"), statusCode: http.StatusBadRequest, wantCode: apierror.InvalidAmount},
+		{name: "wallet_not_found", walletID: "456", request: testRequest("0.0005"), statusCode: http.StatusNotFound, wantCode: apierror.WalletNotFound},
+		{name: "insufficient_balance", walletID: "123", request: testRequest("0.002"), statusCode: http.StatusBadRequest, wantCode: apierror.InsufficientBalance},
+		{
+			name:       "rejected_by_fireblocks",
+			walletID:   "123",
+			request:    testRequest("0.0005"),
+			client:     &MockFireblocksClient{StatusCode: http.StatusBadRequest, Error: errors.New("invalid address"), CreateTransactionResponse: &fireblocks.CreateTransactionResponse{}},
+			statusCode: http.StatusBadRequest,
+			wantCode:   apierror.FireblocksRejected,
+		},
+		{
+			name:       "fireblocks_unavailable",
+			walletID:   "123",
+			request:    testRequest("0.0005"),
+			client:     &MockFireblocksClient{Error: errors.New("connection refused"), CreateTransactionResponse: &fireblocks.CreateTransactionResponse{}},
+			statusCode: http.StatusServiceUnavailable,
+			wantCode:   apierror.ServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := tt.client
+			if client == nil {
+				client = &MockFireblocksClient{}
+			}
+			store := &MockStore{}
+			service := newTestService(store, client, &MockReservations{})
+
+			_, err := service.Transfer(context.Background(), tt.walletID, tt.request)
+
+			var transferErr *Error
+			assert.ErrorAs(t, err, &transferErr)
+			assert.Equal(t, tt.statusCode, transferErr.StatusCode)
+			assert.Equal(t, tt.wantCode, transferErr.Code)
+			assert.Nil(t, store.CreatedTransfer)
+		})
+	}
+}
+
+func TestTransferStoresTransfer(t *testing.T) {
+	tests := []struct {
+		name   string
+		store  *MockStore
+		assert func(t *testing.T, transfer *model.Transfer, err error, store *MockStore)
+	}{
+		{
+			name:  "stored",
+			store: &MockStore{},
+			assert: func(t *testing.T, transfer *model.Transfer, err error, store *MockStore) {
+				assert.NoError(t, err)
+				assert.Equal(t, store.CreatedTransfer, transfer)
+				assert.Equal(t, "123", transfer.WalletID)
+				assert.Equal(t, "eff51bfd-8cec-4b77-b01e-b1aff84dcf49", transfer.FireblocksTxID)
+				assert.Equal(t, "BTC_TEST", transfer.AssetID)
+				assert.Equal(t, "0.0005", transfer.Amount)
+				assert.Equal(t, "SUBMITTED", transfer.Status)
+			},
+		},
+		{
+			name:  "store_retried",
+			store: &MockStore{FailCreates: StoreAttempts - 1},
+			assert: func(t *testing.T, transfer *model.Transfer, err error, store *MockStore) {
+				assert.NoError(t, err)
+				assert.Equal(t, StoreAttempts, store.CreateCalls)
+				assert.NotNil(t, store.CreatedTransfer)
+			},
+		},
+		{
+			name:  "store_error_reports_transaction",
+			store: &MockStore{CreateError: assert.AnError},
+			assert: func(t *testing.T, transfer *model.Transfer, err error, store *MockStore) {
+				var transferErr *Error
+				assert.ErrorAs(t, err, &transferErr)
+				assert.Equal(t, http.StatusInternalServerError, transferErr.StatusCode)
+				assert.Equal(t, StoreAttempts, store.CreateCalls)
+				// the caller learns the transaction exists, so that it does not send it again
+				assert.Contains(t, transferErr.Message, "eff51bfd-8cec-4b77-b01e-b1aff84dcf49")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestService(tt.store, &MockFireblocksClient{}, &MockReservations{})
+
+			transfer, err := service.Transfer(context.Background(), "123", testRequest("0.0005"))
+
+			tt.assert(t, transfer, err, tt.store)
+		})
+	}
+}
+
+func TestTransferReserved(t *testing.T) {
+	tests := []struct {
+		name         string
+		amount       string
+		reservations *MockReservations
+		statusCode   int
+		wantCode     string
+	}{
+		{name: "within_unreserved", amount: "0.0005", reservations: &MockReservations{Reserved: "0.0005"}},
+		// compared exactly, not as floats
+		{name: "exactly_unreserved", amount: "0.000999999999999999", reservations: &MockReservations{Reserved: "0.000000000000000001"}},
+		{name: "above_unreserved", amount: "0.001", reservations: &MockReservations{Reserved: "0.000000000000000001"}, statusCode: http.StatusBadRequest, wantCode: apierror.InsufficientBalance},
+		{name: "reserved_by_payouts", amount: "0.0006", reservations: &MockReservations{Reserved: "0.0005"}, statusCode: http.StatusBadRequest, wantCode: apierror.InsufficientBalance},
+		{name: "reservation_failed", amount: "0.0005", reservations: &MockReservations{Error: assert.AnError}, statusCode: http.StatusInternalServerError, wantCode: apierror.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &MockStore{}
+			service := newTestService(store, &MockFireblocksClient{}, tt.reservations)
+
+			_, err := service.Transfer(context.Background(), "123", testRequest(tt.amount))
+
+			assert.Equal(t, 1, tt.reservations.Calls)
+			if tt.statusCode == 0 {
+				assert.NoError(t, err)
+				assert.NotNil(t, store.CreatedTransfer)
+				return
+			}
+			var transferErr *Error
+			assert.ErrorAs(t, err, &transferErr)
+			assert.Equal(t, tt.statusCode, transferErr.StatusCode)
+			assert.Equal(t, tt.wantCode, transferErr.Code)
+			assert.Nil(t, store.CreatedTransfer)
+		})
+	}
+}
+
+func TestTransferHold(t *testing.T) {
+	tests := []struct {
+		name     string
+		client   *MockFireblocksClient
+		released bool
+	}{
+		{name: "created", client: &MockFireblocksClient{}, released: true},
+		{name: "rejected", client: &MockFireblocksClient{StatusCode: http.StatusBadRequest, Error: errors.New("invalid address")}, released: true},
+		{name: "rate_limited", client: &MockFireblocksClient{StatusCode: http.StatusTooManyRequests, Error: errors.New("rate limited")}, released: true},
+		// the transaction may exist, its amount stays held until the hold expires
+		{name: "outcome_unknown", client: &MockFireblocksClient{Error: errors.New("connection reset")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.client.Error != nil {
+				tt.client.CreateTransactionResponse = &fireblocks.CreateTransactionResponse{}
+			}
+			reservations := &MockReservations{}
+			service := newTestService(&MockStore{}, tt.client, reservations)
+
+			service.Transfer(context.Background(), "123", testRequest("0.0005"))
+
+			assert.Equal(t, 1, reservations.Holds)
+			if tt.released {
+				assert.Equal(t, 1, reservations.Released)
+				assert.Empty(t, reservations.Held)
+			} else {
+				assert.Zero(t, reservations.Released)
+				assert.Equal(t, "1/2000", reservations.Held)
+			}
+		})
+	}
+}
+
+func TestTransferReadsFreshBalance(t *testing.T) {
+	store := &MockStore{}
+	client := &MockFireblocksClient{}
+	service := newTestService(store, client, &MockReservations{})
+	service.balances = cache.NewBalances(client, cache.NewMemoryStore(), time.Minute)
+	_, _, err := service.balances.GetVaultAccountAssetBalance(context.Background(), "vault-account-id", "BTC_TEST")
+	assert.NoError(t, err)
+
+	// spent since it was cached
+	client.Available = "0.0001"
+	_, err = service.Transfer(context.Background(), "123", testRequest("0.0005"))
+
+	var transferErr *Error
+	assert.ErrorAs(t, err, &transferErr)
+	assert.Equal(t, apierror.InsufficientBalance, transferErr.Code)
+	assert.Zero(t, client.CreateCalls)
+}
+
+func TestTransferSurvivesCancellation(t *testing.T) {
+	store := &MockStore{}
+	client := &MockFireblocksClient{Started: make(chan struct{}), Release: make(chan struct{})}
+	service := newTestService(store, client, &MockReservations{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.Transfer(ctx, "123", testRequest("0.0005"))
+	}()
+
+	<-client.Started
+	cancel()
+
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelWait()
+	assert.ErrorIs(t, service.WaitForTransfers(waitCtx), context.DeadlineExceeded)
+
+	close(client.Release)
+	assert.NoError(t, service.WaitForTransfers(context.Background()))
+	<-done
+
+	assert.NoError(t, client.ReceivedContextErr)
+	assert.NotNil(t, store.CreatedTransfer)
+	assert.Equal(t, "eff51bfd-8cec-4b77-b01e-b1aff84dcf49", store.CreatedTransfer.FireblocksTxID)
+}
+
+func TestTransferRefusedOnceDraining(t *testing.T) {
+	store := &MockStore{}
+	client := &MockFireblocksClient{}
+	service := newTestService(store, client, &MockReservations{})
+	assert.NoError(t, service.WaitForTransfers(context.Background()))
+
+	// e.g. a scheduled transfer still running while the service shuts down
+	_, err := service.Transfer(context.Background(), "123", testRequest("0.0005"))
+
+	var transferErr *Error
+	assert.ErrorAs(t, err, &transferErr)
+	assert.Equal(t, http.StatusServiceUnavailable, transferErr.StatusCode)
+	assert.Zero(t, client.CreateCalls)
+	assert.Nil(t, store.CreatedTransfer)
+}
@@ -0,0 +1,217 @@
+// Package client is the SDK of the wallet endpoints of the service. On top of the requests and responses of the
+// handlers, it retries the requests that failed transiently, sending the POST requests with an Idempotency-Key so that
+// retries cannot apply them twice, returns the error responses as typed errors, and iterates over the paginated lists.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/idempotency"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBytes bounds how much of an error response is kept as the message
+const maxErrorBytes = 4 << 10
+
+type Client struct {
+	baseURL     string
+	httpClient  *http.Client
+	header      http.Header
+	retryPolicy RetryPolicy
+}
+
+// RetryPolicy is how requests are retried after a network error, a 429, a 502, 503 or 504, Fireblocks being
+// unavailable, or the first request with the same Idempotency-Key still being handled. A Retry-After longer than
+// MaxBackoff is not waited for, the error is returned.
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt, 1 disables retries
+	MaxAttempts int
+	// InitialBackoff is doubled after every attempt, up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces the default HTTP client, which times out after 30 seconds
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAPIKey sets the X-API-Key the rate limits and the Idempotency-Keys are scoped to
+func WithAPIKey(apiKey string) Option {
+	return func(c *Client) {
+		c.header.Set("X-API-Key", apiKey)
+	}
+}
+
+// WithActor sets the X-Actor the audit log records the requests against
+func WithActor(actor string) Option {
+	return func(c *Client) {
+		c.header.Set("X-Actor", actor)
+	}
+}
+
+// WithRetryPolicy replaces the default policy of 3 attempts, backing off from 200ms up to 5s
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+// New creates a client of the service at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		header:     make(http.Header),
+		retryPolicy: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: 200 * time.Millisecond,
+			MaxBackoff:     5 * time.Second,
+		},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey sets the Idempotency-Key of the POST request made with the context. Without it every call gets a
+// random key, shared by its retries only: a caller that can itself be retried, e.g. a job, should derive the key from
+// what it is doing, so that the request is not run again when the caller is.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// do sends a request with the JSON encoded body, if not nil, and decodes the response into out, if not nil, when the
+// service answers with wantStatus, retrying as set by the RetryPolicy
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any, wantStatus int, out any) error {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		if bodyBytes, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+	idempotencyKey := ""
+	if method == http.MethodPost {
+		idempotencyKey, _ = ctx.Value(idempotencyKeyContextKey{}).(string)
+		if idempotencyKey == "" {
+			idempotencyKey = uuid.NewString()
+		}
+	}
+
+	backoff := c.retryPolicy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := c.send(ctx, method, target, bodyBytes, idempotencyKey, wantStatus, out)
+		if err == nil || attempt >= c.retryPolicy.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		wait := max(backoff, retryAfter)
+		if wait > c.retryPolicy.MaxBackoff {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff = min(2*backoff, c.retryPolicy.MaxBackoff)
+	}
+}
+
+// send makes one attempt of a request, returning the Retry-After of the response along with its error
+func (c *Client) send(ctx context.Context, method, target string, body []byte, idempotencyKey string, wantStatus int, out any) (time.Duration, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reqBody)
+	if err != nil {
+		return 0, err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set(idempotency.Header, idempotencyKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		return retryAfter(resp), responseError(resp)
+	}
+	if out == nil {
+		return 0, nil
+	}
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return 0, nil
+}
+
+func responseError(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBytes))
+	var errResp apierror.Error
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && json.Unmarshal(message, &errResp) == nil && errResp.Code != "" {
+		return &Error{StatusCode: resp.StatusCode, Code: Code(errResp.Code), Message: errResp.Message}
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+}
+
+// retryAfter reads the Retry-After header in seconds, zero when it is missing or a date
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// retryable tells whether a request may be sent again. Every request can, the POST ones carrying their Idempotency-Key,
+// as long as it failed transiently.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		// the service could not be reached, or the connection broke
+		return true
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return apiErr.Code == ErrServiceUnavailable || apiErr.Code == ErrRequestInProgress
+}
@@ -0,0 +1,697 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"firego-wallet-service/internal/cache"
+	"firego-wallet-service/internal/fireblocks"
+	"firego-wallet-service/internal/fireblocks/fireblockstest"
+	"firego-wallet-service/internal/handler"
+	"firego-wallet-service/internal/idempotency"
+	"firego-wallet-service/internal/model"
+	"firego-wallet-service/internal/schedule"
+	"firego-wallet-service/internal/transfer"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testAPIKey = "test-api-key"
+
+type MemoryWalletRepository struct {
+	mu      sync.Mutex
+	wallets map[string]model.Wallet
+}
+
+func (r *MemoryWalletRepository) Create(_ context.Context, wallet *model.Wallet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wallet.ID = uuid.NewString()
+	r.wallets[wallet.ID] = *wallet
+	return nil
+}
+
+func (r *MemoryWalletRepository) GetByID(_ context.Context, id string) (*model.Wallet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wallet, ok := r.wallets[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &wallet, nil
+}
+
+type MemoryTransferRepository struct {
+	mu        sync.Mutex
+	transfers []model.Transfer
+}
+
+func (r *MemoryTransferRepository) Create(transfer *model.Transfer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transfers = append(r.transfers, *transfer)
+	return nil
+}
+
+func (r *MemoryTransferRepository) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.transfers)
+}
+
+// MemoryPayoutService keeps the payouts without submitting them
+type MemoryPayoutService struct {
+	mu      sync.Mutex
+	payouts map[string]model.Payout
+}
+
+func (s *MemoryPayoutService) Create(_ context.Context, payout *model.Payout) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payout.ID = uuid.NewString()
+	payout.Status = model.PayoutStatusPending
+	for i := range payout.Lines {
+		payout.Lines[i].Line = i + 1
+		payout.Lines[i].Status = model.PayoutLineStatusPending
+	}
+	s.payouts[payout.ID] = *payout
+	return nil
+}
+
+func (s *MemoryPayoutService) Get(_ context.Context, id string) (*model.Payout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payout, ok := s.payouts[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &payout, nil
+}
+
+func (s *MemoryPayoutService) Reserve(ctx context.Context, _, _ string, fn func(ctx context.Context, reserved *big.Rat) error) error {
+	return fn(ctx, new(big.Rat))
+}
+
+func (s *MemoryPayoutService) Hold(context.Context, string, string, *big.Rat) (string, error) {
+	return "", nil
+}
+
+func (s *MemoryPayoutService) Release(context.Context, string) error {
+	return nil
+}
+
+// MemoryScheduleRepository keeps the schedules and their runs, for the handler and the scheduler
+type MemoryScheduleRepository struct {
+	mu        sync.Mutex
+	schedules map[string]model.TransferSchedule
+	runs      []model.TransferScheduleRun
+}
+
+func (r *MemoryScheduleRepository) Create(_ context.Context, schedule *model.TransferSchedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	schedule.ID = uuid.NewString()
+	schedule.CreatedAt = time.Now()
+	r.schedules[schedule.ID] = *schedule
+	return nil
+}
+
+func (r *MemoryScheduleRepository) GetByID(_ context.Context, id string) (*model.TransferSchedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	schedule, ok := r.schedules[id]
+	if !ok || schedule.DeletedAt != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &schedule, nil
+}
+
+func (r *MemoryScheduleRepository) ListByWalletID(_ context.Context, walletID string) ([]model.TransferSchedule, error) {
+	return r.list(func(schedule model.TransferSchedule) bool { return schedule.WalletID == walletID }), nil
+}
+
+func (r *MemoryScheduleRepository) ListDue(_ context.Context, now time.Time) ([]model.TransferSchedule, error) {
+	return r.list(func(schedule model.TransferSchedule) bool {
+		return schedule.Status == model.TransferScheduleStatusActive && !schedule.NextRunAt.After(now)
+	}), nil
+}
+
+func (r *MemoryScheduleRepository) list(match func(model.TransferSchedule) bool) []model.TransferSchedule {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var schedules []model.TransferSchedule
+	for _, schedule := range r.schedules {
+		if schedule.DeletedAt == nil && match(schedule) {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].CreatedAt.Before(schedules[j].CreatedAt) })
+	return schedules
+}
+
+func (r *MemoryScheduleRepository) Pause(_ context.Context, id string) error {
+	r.update(id, func(schedule *model.TransferSchedule) {
+		if schedule.Status == model.TransferScheduleStatusActive {
+			schedule.Status = model.TransferScheduleStatusPaused
+		}
+	})
+	return nil
+}
+
+func (r *MemoryScheduleRepository) Resume(_ context.Context, id string, nextRunAt time.Time) error {
+	r.update(id, func(schedule *model.TransferSchedule) {
+		if schedule.Status == model.TransferScheduleStatusPaused {
+			schedule.Status = model.TransferScheduleStatusActive
+			schedule.NextRunAt = &nextRunAt
+		}
+	})
+	return nil
+}
+
+func (r *MemoryScheduleRepository) Delete(_ context.Context, id string) error {
+	r.update(id, func(schedule *model.TransferSchedule) {
+		now := time.Now()
+		schedule.DeletedAt = &now
+	})
+	return nil
+}
+
+func (r *MemoryScheduleRepository) RecordRun(_ context.Context, run *model.TransferScheduleRun, nextRunAt *time.Time) error {
+	r.update(run.ScheduleID, func(schedule *model.TransferSchedule) {
+		schedule.NextRunAt = nextRunAt
+		schedule.Attempts = 0
+		if nextRunAt == nil {
+			schedule.Status = model.TransferScheduleStatusCompleted
+		}
+	})
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run.CreatedAt = time.Now()
+	r.runs = append(r.runs, *run)
+	return nil
+}
+
+func (r *MemoryScheduleRepository) LockDue(ctx context.Context, id string, scheduledAt time.Time, fn func(ctx context.Context) error) (bool, error) {
+	r.mu.Lock()
+	schedule, ok := r.schedules[id]
+	r.mu.Unlock()
+	if !ok || schedule.DeletedAt != nil || schedule.Status != model.TransferScheduleStatusActive || !schedule.NextRunAt.Equal(scheduledAt) {
+		return false, nil
+	}
+	return true, fn(ctx)
+}
+
+func (r *MemoryScheduleRepository) RecordAttempt(_ context.Context, id string) error {
+	r.update(id, func(schedule *model.TransferSchedule) {
+		schedule.Attempts++
+	})
+	return nil
+}
+
+func (r *MemoryScheduleRepository) ListRuns(_ context.Context, scheduleID string, limit int) ([]model.TransferScheduleRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var runs []model.TransferScheduleRun
+	for i := len(r.runs) - 1; i >= 0 && len(runs) < limit; i-- {
+		if r.runs[i].ScheduleID == scheduleID {
+			runs = append(runs, r.runs[i])
+		}
+	}
+	return runs, nil
+}
+
+func (r *MemoryScheduleRepository) update(id string, change func(*model.TransferSchedule)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	schedule := r.schedules[id]
+	change(&schedule)
+	r.schedules[id] = schedule
+}
+
+type AlwaysLeader struct{}
+
+func (AlwaysLeader) IsLeader(context.Context) bool {
+	return true
+}
+
+// testService serves the wallet handlers, wired as in the service, against the Fireblocks simulator
+type testService struct {
+	url        string
+	sim        *fireblockstest.Server
+	fireblocks *fireblocks.Client
+	transfers  *MemoryTransferRepository
+	scheduler  *schedule.Scheduler
+	// lostResponses is how many of the next responses are replaced by a 502 after the request was handled, as if the
+	// connection to a proxy in front of the service had failed
+	lostResponses atomic.Int32
+	requests      atomic.Int32
+	// hungTransactions is how many of the next transactions are created by the simulator without it answering, until
+	// the Fireblocks client times out, and rateLimitedTransactions how many are answered 429 without being created
+	hungTransactions        atomic.Int32
+	rateLimitedTransactions atomic.Int32
+	// transactionRequests counts the Fireblocks transaction requests, the last one carrying transactionKey
+	transactionRequests atomic.Int32
+	transactionKey      atomic.Value
+}
+
+func newTestService(t *testing.T) *testService {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	sim := fireblockstest.NewServer(fireblockstest.Config{APIKey: testAPIKey, PublicKey: &key.PublicKey})
+	s := &testService{
+		sim:       sim,
+		transfers: &MemoryTransferRepository{},
+	}
+	simServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/transactions" {
+			sim.ServeHTTP(w, r)
+			return
+		}
+		s.transactionRequests.Add(1)
+		s.transactionKey.Store(r.Header.Get(idempotency.Header))
+		switch {
+		case s.rateLimitedTransactions.Add(-1) >= 0:
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+		case s.hungTransactions.Add(-1) >= 0:
+			sim.ServeHTTP(httptest.NewRecorder(), r)
+			<-r.Context().Done()
+		default:
+			sim.ServeHTTP(w, r)
+		}
+	}))
+	t.Cleanup(simServer.Close)
+	s.fireblocks = fireblocks.NewClient(simServer.URL, testAPIKey, key, fireblocks.WithTimeout(time.Second))
+	walletRepo := &MemoryWalletRepository{wallets: map[string]model.Wallet{}}
+	payouts := &MemoryPayoutService{payouts: map[string]model.Payout{}}
+	transfers := transfer.NewService(walletRepo, s.transfers, s.fireblocks, cache.NewBalances(s.fireblocks, nil, 0), payouts)
+	walletHandler := handler.NewWalletHandler(walletRepo, s.fireblocks, cache.NewBalances(s.fireblocks, nil, 0), transfers)
+	walletBatchHandler := handler.NewWalletBatchHandler(walletRepo, s.fireblocks, handler.BatchLimits{MaxSize: 10, Concurrency: 3})
+	payoutHandler := handler.NewPayoutHandler(walletRepo, payouts, cache.NewBalances(s.fireblocks, nil, 0), 10)
+	scheduleRepo := &MemoryScheduleRepository{schedules: map[string]model.TransferSchedule{}}
+	scheduleHandler := handler.NewScheduleHandler(walletRepo, scheduleRepo)
+	s.scheduler = schedule.NewScheduler(scheduleRepo, transfers, s.transfers, s.fireblocks, AlwaysLeader{}, time.Minute)
+	keys := idempotency.New(idempotency.NewMemoryStore(), time.Hour)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /wallets", keys.Wrap(walletHandler.CreateWallet))
+	mux.HandleFunc("POST /wallets:batch", keys.Wrap(walletBatchHandler.CreateWallets))
+	mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/balance", walletHandler.GetWalletBalance)
+	mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/address", walletHandler.GetDepositAddress)
+	mux.HandleFunc("POST /wallets/{walletId}/assets/{assetId}/addresses", keys.Wrap(walletHandler.CreateDepositAddress))
+	mux.HandleFunc("GET /wallets/{walletId}/assets/{assetId}/addresses", walletHandler.ListDepositAddresses)
+	mux.HandleFunc("POST /wallets/{walletId}/transactions", keys.Wrap(walletHandler.InitiateTransfer))
+	mux.HandleFunc("POST /wallets/{walletId}/payouts", keys.Wrap(payoutHandler.CreatePayout))
+	mux.HandleFunc("GET /wallets/{walletId}/payouts/{payoutId}", payoutHandler.GetPayout)
+	mux.HandleFunc("POST /wallets/{walletId}/schedules", keys.Wrap(scheduleHandler.CreateSchedule))
+	mux.HandleFunc("GET /wallets/{walletId}/schedules", scheduleHandler.ListSchedules)
+	mux.HandleFunc("POST /wallets/{walletId}/schedules/{scheduleId}/pause", keys.Wrap(scheduleHandler.PauseSchedule))
+	mux.HandleFunc("POST /wallets/{walletId}/schedules/{scheduleId}/resume", keys.Wrap(scheduleHandler.ResumeSchedule))
+	mux.HandleFunc("DELETE /wallets/{walletId}/schedules/{scheduleId}", scheduleHandler.DeleteSchedule)
+	mux.HandleFunc("GET /wallets/{walletId}/schedules/{scheduleId}/runs", scheduleHandler.ListScheduleRuns)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		if s.lostResponses.Add(-1) >= 0 {
+			mux.ServeHTTP(httptest.NewRecorder(), r)
+			http.Error(w, "Bad gateway", http.StatusBadGateway)
+			return
+		}
+		s.lostResponses.Store(0)
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	s.url = server.URL
+	return s
+}
+
+func (s *testService) client(opts ...Option) *Client {
+	return New(s.url, append([]Option{
+		WithActor("sdk-test"),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}),
+	}, opts...)...)
+}
+
+// createFundedWallet creates a wallet holding amount of the asset
+func (s *testService) createFundedWallet(t *testing.T, client *Client, assetID, amount string) *CreateWalletResponse {
+	t.Helper()
+	ctx := context.Background()
+	wallet, err := client.CreateWallet(ctx, CreateWalletRequest{Name: "sdk"})
+	assert.NoError(t, err)
+	_, _, err = s.fireblocks.CreateVaultAccountAsset(ctx, wallet.VaultAccountID, assetID)
+	assert.NoError(t, err)
+	if amount != "0" {
+		_, err = s.sim.Deposit(ctx, wallet.VaultAccountID, assetID, amount)
+		assert.NoError(t, err)
+		s.sim.Advance(ctx)
+	}
+	return wallet
+}
+
+func TestWallets(t *testing.T) {
+	s := newTestService(t)
+	client := s.client()
+	ctx := context.Background()
+	wallet := s.createFundedWallet(t, client, "BTC_TEST", "1.5")
+	assert.Equal(t, "sdk", wallet.Name)
+
+	balance, err := client.GetWalletBalance(ctx, wallet.ID, "BTC_TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5", balance.Available)
+
+	first, err := client.GetDepositAddress(ctx, wallet.ID, "BTC_TEST")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, first.Address)
+
+	created, err := client.CreateDepositAddress(ctx, wallet.ID, "BTC_TEST", CreateDepositAddressRequest{Description: "invoice 1", CustomerRefID: "customer-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "invoice 1", created.Description)
+	assert.Equal(t, 1, created.Bip44AddressIndex)
+	for range 3 {
+		_, err = client.CreateDepositAddress(ctx, wallet.ID, "BTC_TEST", CreateDepositAddressRequest{})
+		assert.NoError(t, err)
+	}
+
+	page, err := client.ListDepositAddresses(ctx, wallet.ID, "BTC_TEST", &ListDepositAddressesParams{Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, page.Addresses, 2)
+	assert.NotEmpty(t, page.Paging.After)
+
+	transfer, err := client.InitiateTransfer(ctx, wallet.ID, InitiateTransferRequest{AssetID: "BTC_TEST", Amount: "0.5", DestinationAddress: "tb1qdestination"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, transfer.TransactionID)
+	assert.Equal(t, fireblocks.TransactionStatusSubmitted, transfer.Status)
+	assert.Equal(t, 1, s.transfers.count())
+}
+
+func TestCreateWallets(t *testing.T) {
+	s := newTestService(t)
+	client := s.client()
+	ctx := context.Background()
+
+	req := CreateWalletsRequest{}
+	for i := range 5 {
+		req.Wallets = append(req.Wallets, CreateWalletsItem{Name: "cohort-" + strconv.Itoa(i), Assets: []string{"BTC_TEST", "ETH_TEST5"}})
+	}
+	resp, err := client.CreateWallets(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, resp.Created)
+	assert.Len(t, resp.Results, 5)
+	for i, result := range resp.Results {
+		assert.Equal(t, i, result.Index)
+		assert.Equal(t, "cohort-"+strconv.Itoa(i), result.Name)
+		assert.Equal(t, handler.WalletBatchCreated, result.Status)
+		assert.Len(t, result.Assets, 2)
+
+		balance, err := client.GetWalletBalance(ctx, result.ID, "ETH_TEST5")
+		assert.NoError(t, err)
+		assert.Equal(t, "0", balance.Available)
+	}
+
+	_, err = client.CreateWallets(ctx, CreateWalletsRequest{Wallets: []CreateWalletsItem{{Name: "valid"}, {}}})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestPayouts(t *testing.T) {
+	s := newTestService(t)
+	client := s.client()
+	ctx := context.Background()
+	wallet := s.createFundedWallet(t, client, "ETH_TEST5", "3")
+
+	created, err := client.CreatePayout(ctx, wallet.ID, CreatePayoutRequest{AssetID: "ETH_TEST5", Lines: []CreatePayoutLine{
+		{DestinationAddress: "0xalice", Amount: "1", Note: "June salary"},
+		{DestinationAddress: "0xbob", Amount: "1.5"},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "2.5", created.Total)
+	assert.Equal(t, string(model.PayoutStatusPending), created.Status)
+
+	payout, err := client.GetPayout(ctx, wallet.ID, created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, payout.ID)
+	assert.Len(t, payout.Lines, 2)
+	assert.Equal(t, "0xbob", payout.Lines[1].DestinationAddress)
+
+	_, err = client.GetPayout(ctx, wallet.ID, uuid.NewString())
+	assert.ErrorIs(t, err, ErrPayoutNotFound)
+	_, err = client.CreatePayout(ctx, wallet.ID, CreatePayoutRequest{AssetID: "ETH_TEST5", Lines: []CreatePayoutLine{{DestinationAddress: "0xalice", Amount: "4"}}})
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+}
+
+func TestSchedules(t *testing.T) {
+	s := newTestService(t)
+	client := s.client()
+	ctx := context.Background()
+	wallet := s.createFundedWallet(t, client, "ETH_TEST5", "3")
+
+	runAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	oneOff, err := client.CreateSchedule(ctx, wallet.ID, CreateScheduleRequest{AssetID: "ETH_TEST5", Amount: "1", DestinationAddress: "0xcold", RunAt: &runAt})
+	assert.NoError(t, err)
+	assert.Equal(t, runAt, oneOff.NextRunAt.UTC())
+	recurring, err := client.CreateSchedule(ctx, wallet.ID, CreateScheduleRequest{AssetID: "ETH_TEST5", Amount: "5", DestinationAddress: "0xcold", Cron: "@hourly"})
+	assert.NoError(t, err)
+	_, err = client.CreateSchedule(ctx, wallet.ID, CreateScheduleRequest{AssetID: "ETH_TEST5", Amount: "1", DestinationAddress: "0xcold", Cron: "@often"})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+
+	paused, err := client.PauseSchedule(ctx, wallet.ID, oneOff.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, string(model.TransferScheduleStatusPaused), paused.Status)
+	resumed, err := client.ResumeSchedule(ctx, wallet.ID, oneOff.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, string(model.TransferScheduleStatusActive), resumed.Status)
+
+	s.scheduler.RunDue(ctx, runAt.Add(time.Hour))
+
+	runs, err := client.ListScheduleRuns(ctx, wallet.ID, oneOff.ID, 0)
+	assert.NoError(t, err)
+	assert.Len(t, runs.Runs, 1)
+	assert.Equal(t, string(model.TransferScheduleRunStatusSubmitted), runs.Runs[0].Status)
+	assert.Equal(t, runAt, runs.Runs[0].ScheduledAt.UTC())
+	assert.Equal(t, 1, s.transfers.count())
+	runs, err = client.ListScheduleRuns(ctx, wallet.ID, recurring.ID, 0)
+	assert.NoError(t, err)
+	assert.Len(t, runs.Runs, 1)
+	assert.Equal(t, string(model.TransferScheduleRunStatusSkipped), runs.Runs[0].Status)
+
+	_, err = client.PauseSchedule(ctx, wallet.ID, oneOff.ID)
+	assert.ErrorIs(t, err, ErrScheduleCompleted)
+	assert.NoError(t, client.DeleteSchedule(ctx, wallet.ID, oneOff.ID))
+	schedules, err := client.ListSchedules(ctx, wallet.ID)
+	assert.NoError(t, err)
+	assert.Len(t, schedules.Schedules, 1)
+	assert.Equal(t, recurring.ID, schedules.Schedules[0].ID)
+	_, err = client.ListScheduleRuns(ctx, wallet.ID, oneOff.ID, 0)
+	assert.ErrorIs(t, err, ErrScheduleNotFound)
+}
+
+func TestDepositAddresses(t *testing.T) {
+	s := newTestService(t)
+	client := s.client()
+	ctx := context.Background()
+	wallet := s.createFundedWallet(t, client, "BTC_TEST", "0")
+	for range 4 {
+		_, err := client.CreateDepositAddress(ctx, wallet.ID, "BTC_TEST", CreateDepositAddressRequest{})
+		assert.NoError(t, err)
+	}
+
+	t.Run("all_pages", func(t *testing.T) {
+		var indexes []int
+		for address, err := range client.DepositAddresses(ctx, wallet.ID, "BTC_TEST", 2) {
+			assert.NoError(t, err)
+			indexes = append(indexes, address.Bip44AddressIndex)
+		}
+		assert.Equal(t, []int{0, 1, 2, 3, 4}, indexes)
+	})
+
+	t.Run("stopped_early", func(t *testing.T) {
+		before := s.requests.Load()
+		count := 0
+		for range client.DepositAddresses(ctx, wallet.ID, "BTC_TEST", 2) {
+			count++
+			if count == 3 {
+				break
+			}
+		}
+		assert.Equal(t, 3, count)
+		assert.Equal(t, int32(2), s.requests.Load()-before)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var errs []error
+		for _, err := range client.DepositAddresses(ctx, uuid.NewString(), "BTC_TEST", 2) {
+			errs = append(errs, err)
+		}
+		assert.Len(t, errs, 1)
+		assert.ErrorIs(t, errs[0], ErrWalletNotFound)
+	})
+}
+
+func TestErrors(t *testing.T) {
+	s := newTestService(t)
+	client := s.client()
+	ctx := context.Background()
+	wallet := s.createFundedWallet(t, client, "BTC_TEST", "1")
+
+	tests := []struct {
+		name       string
+		call       func() error
+		statusCode int
+		code       Code
+	}{
+		{
+			name: "invalid_request",
+			call: func() error {
+				_, err := client.CreateWallet(ctx, CreateWalletRequest{})
+				return err
+			},
+			statusCode: http.StatusBadRequest,
+			code:       ErrInvalidRequest,
+		},
+		{
+			name: "wallet_not_found",
+			call: func() error {
+				_, err := client.GetWalletBalance(ctx, uuid.NewString(), "BTC_TEST")
+				return err
+			},
+			statusCode: http.StatusNotFound,
+			code:       ErrWalletNotFound,
+		},
+		{
+			name: "insufficient_balance",
+			call: func() error {
+				_, err := client.InitiateTransfer(ctx, wallet.ID, InitiateTransferRequest{AssetID: "BTC_TEST", Amount: "2", DestinationAddress: "tb1qdestination"})
+				return err
+			},
+			statusCode: http.StatusBadRequest,
+			code:       ErrInsufficientBalance,
+		},
+		{
+			name: "invalid_amount",
+			call: func() error {
+				_, err := client.InitiateTransfer(ctx, wallet.ID, InitiateTransferRequest{AssetID: "BTC_TEST", Amount: "lots", DestinationAddress: "tb1qdestination"})
+				return err
+			},
+			statusCode: http.StatusBadRequest,
+			code:       ErrInvalidAmount,
+		},
+		{
+			name: "fireblocks_rejected",
+			call: func() error {
+				_, err := client.GetWalletBalance(ctx, wallet.ID, "ETH_TEST")
+				return err
+			},
+			statusCode: http.StatusBadRequest,
+			code:       ErrFireblocksRejected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			var apiErr *Error
+			assert.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, tt.statusCode, apiErr.StatusCode)
+			assert.Equal(t, tt.code, apiErr.Code)
+			assert.NotEmpty(t, apiErr.Message)
+			assert.ErrorIs(t, err, tt.code)
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	wallet := s.createFundedWallet(t, s.client(), "BTC_TEST", "1")
+	transferReq := InitiateTransferRequest{AssetID: "BTC_TEST", Amount: "0.1", DestinationAddress: "tb1qdestination"}
+
+	t.Run("response_lost", func(t *testing.T) {
+		// the transfer is submitted by the first attempt, the retry gets its response instead of submitting another
+		s.lostResponses.Store(2)
+		before := s.transfers.count()
+		transfer, err := s.client().InitiateTransfer(ctx, wallet.ID, transferReq)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, transfer.TransactionID)
+		assert.Equal(t, before+1, s.transfers.count())
+	})
+
+	t.Run("caller_idempotency_key", func(t *testing.T) {
+		keyCtx := WithIdempotencyKey(ctx, "payout-42")
+		first, err := s.client().InitiateTransfer(keyCtx, wallet.ID, transferReq)
+		assert.NoError(t, err)
+		again, err := s.client().InitiateTransfer(keyCtx, wallet.ID, transferReq)
+		assert.NoError(t, err)
+		assert.Equal(t, first.TransactionID, again.TransactionID)
+
+		_, err = s.client().InitiateTransfer(keyCtx, wallet.ID, InitiateTransferRequest{AssetID: "BTC_TEST", Amount: "0.2", DestinationAddress: "tb1qdestination"})
+		assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+		// keys are scoped to the API key
+		other, err := s.client(WithAPIKey("other")).InitiateTransfer(keyCtx, wallet.ID, transferReq)
+		assert.NoError(t, err)
+		assert.NotEqual(t, first.TransactionID, other.TransactionID)
+	})
+
+	// the Retry-After of the service is waited for
+	slowRetries := WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Second})
+
+	t.Run("fireblocks_timeout", func(t *testing.T) {
+		// the transaction is created but the call times out: the retries must not create another one
+		s.hungTransactions.Store(1)
+		s.rateLimitedTransactions.Store(0)
+		before := s.transfers.count()
+		requestsBefore := s.transactionRequests.Load()
+		_, err := s.client(slowRetries).InitiateTransfer(WithIdempotencyKey(ctx, "timeout-1"), wallet.ID, transferReq)
+		assert.ErrorIs(t, err, ErrRequestInProgress)
+		assert.Equal(t, int32(1), s.transactionRequests.Load()-requestsBefore)
+		assert.NotEmpty(t, s.transactionKey.Load())
+		assert.Equal(t, before, s.transfers.count())
+	})
+
+	t.Run("fireblocks_rate_limited", func(t *testing.T) {
+		// a rate limited request is not kept, the retry with the same key creates the transfer
+		s.rateLimitedTransactions.Store(1)
+		s.hungTransactions.Store(0)
+		before := s.transfers.count()
+		requestsBefore := s.transactionRequests.Load()
+		transfer, err := s.client(slowRetries).InitiateTransfer(ctx, wallet.ID, transferReq)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, transfer.TransactionID)
+		assert.Equal(t, int32(2), s.transactionRequests.Load()-requestsBefore)
+		assert.Equal(t, before+1, s.transfers.count())
+	})
+
+	t.Run("attempts_exhausted", func(t *testing.T) {
+		s.lostResponses.Store(3)
+		before := s.requests.Load()
+		_, err := s.client().GetWalletBalance(ctx, wallet.ID, "BTC_TEST")
+		var apiErr *Error
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+		assert.Empty(t, apiErr.Code)
+		assert.Equal(t, int32(3), s.requests.Load()-before)
+	})
+
+	t.Run("client_errors_not_retried", func(t *testing.T) {
+		before := s.requests.Load()
+		_, err := s.client().GetWalletBalance(ctx, uuid.NewString(), "BTC_TEST")
+		assert.ErrorIs(t, err, ErrWalletNotFound)
+		assert.Equal(t, int32(1), s.requests.Load()-before)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := s.client().GetWalletBalance(cancelled, wallet.ID, "BTC_TEST")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
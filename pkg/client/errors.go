@@ -0,0 +1,57 @@
+package client
+
+import (
+	"firego-wallet-service/internal/apierror"
+	"fmt"
+)
+
+// Code is the code of the JSON error responses. The codes are errors themselves, so that errors can be checked with
+// errors.Is, e.g. errors.Is(err, client.ErrInsufficientBalance).
+type Code string
+
+func (c Code) Error() string {
+	return string(c)
+}
+
+const (
+	ErrInvalidRequest       Code = apierror.InvalidRequest
+	ErrInvalidBody          Code = apierror.InvalidBody
+	ErrInvalidAmount        Code = apierror.InvalidAmount
+	ErrBodyTooLarge         Code = apierror.BodyTooLarge
+	ErrWalletNotFound       Code = apierror.WalletNotFound
+	ErrPayoutNotFound       Code = apierror.PayoutNotFound
+	ErrScheduleNotFound     Code = apierror.ScheduleNotFound
+	ErrScheduleCompleted    Code = apierror.ScheduleCompleted
+	ErrNoDepositAddress     Code = apierror.NoDepositAddress
+	ErrInsufficientBalance  Code = apierror.InsufficientBalance
+	ErrFireblocksRejected   Code = apierror.FireblocksRejected
+	ErrUnauthorized         Code = apierror.Unauthorized
+	ErrForbidden            Code = apierror.Forbidden
+	ErrRateLimited          Code = apierror.RateLimited
+	ErrIdempotencyKeyReused Code = apierror.IdempotencyKeyReused
+	ErrRequestInProgress    Code = apierror.RequestInProgress
+	ErrServiceUnavailable   Code = apierror.ServiceUnavailable
+	ErrInternal             Code = apierror.Internal
+)
+
+// Error is returned when the service answers with an error status code
+type Error struct {
+	StatusCode int
+	// Code is empty when the response was not a JSON error, e.g. from a proxy in front of the service
+	Code    Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("wallet service answered %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("wallet service answered %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	if e.Code == "" {
+		return nil
+	}
+	return e.Code
+}
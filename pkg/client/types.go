@@ -0,0 +1,41 @@
+package client
+
+import "firego-wallet-service/internal/handler"
+
+// The requests and responses are the ones of the handlers, so that the client cannot drift from the service
+
+type (
+	CreateWalletRequest          = handler.CreateWalletRequest
+	CreateWalletResponse         = handler.CreateWalletResponse
+	CreateWalletsRequest         = handler.CreateWalletsRequest
+	CreateWalletsItem            = handler.CreateWalletsItem
+	CreateWalletsResponse        = handler.CreateWalletsResponse
+	CreateWalletsResult          = handler.CreateWalletsResult
+	CreateWalletsAssetResult     = handler.CreateWalletsAssetResult
+	GetWalletBalanceResponse     = handler.GetWalletBalanceResponse
+	GetDepositAddressResponse    = handler.GetDepositAddressResponse
+	CreateDepositAddressRequest  = handler.CreateDepositAddressRequest
+	DepositAddressResponse       = handler.DepositAddressResponse
+	ListDepositAddressesResponse = handler.ListDepositAddressesResponse
+	PagingResponse               = handler.PagingResponse
+	InitiateTransferRequest      = handler.InitiateTransferRequest
+	InitiateTransferResponse     = handler.InitiateTransferResponse
+	CreatePayoutRequest          = handler.CreatePayoutRequest
+	CreatePayoutLine             = handler.CreatePayoutLine
+	PayoutResponse               = handler.PayoutResponse
+	PayoutLineResponse           = handler.PayoutLineResponse
+	CreateScheduleRequest        = handler.CreateScheduleRequest
+	ScheduleResponse             = handler.ScheduleResponse
+	ListSchedulesResponse        = handler.ListSchedulesResponse
+	ScheduleRunResponse          = handler.ScheduleRunResponse
+	ListScheduleRunsResponse     = handler.ListScheduleRunsResponse
+)
+
+// ListDepositAddressesParams selects a page of addresses, at most one of Before and After can be set
+type ListDepositAddressesParams struct {
+	// Before and After are the cursors of the Paging of a previous page
+	Before string
+	After  string
+	// Limit is the page size, up to 1000, the Fireblocks default when 0
+	Limit int
+}
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// CreateWallet creates a wallet and its Fireblocks vault account
+func (c *Client) CreateWallet(ctx context.Context, req CreateWalletRequest) (*CreateWalletResponse, error) {
+	var out CreateWalletResponse
+	if err := c.do(ctx, http.MethodPost, "/wallets", nil, req, http.StatusCreated, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateWallets creates a batch of wallets, with their vault accounts and assets. Failing wallets do not fail the
+// call, the response reports the outcome of each wallet.
+func (c *Client) CreateWallets(ctx context.Context, req CreateWalletsRequest) (*CreateWalletsResponse, error) {
+	var out CreateWalletsResponse
+	if err := c.do(ctx, http.MethodPost, "/wallets:batch", nil, req, http.StatusOK, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetWalletBalance returns the Fireblocks balance of an asset of the wallet
+func (c *Client) GetWalletBalance(ctx context.Context, walletID, assetID string) (*GetWalletBalanceResponse, error) {
+	var out GetWalletBalanceResponse
+	if err := c.do(ctx, http.MethodGet, assetPath(walletID, assetID)+"/balance", nil, nil, http.StatusOK, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDepositAddress returns the first deposit address of an asset of the wallet
+func (c *Client) GetDepositAddress(ctx context.Context, walletID, assetID string) (*GetDepositAddressResponse, error) {
+	var out GetDepositAddressResponse
+	if err := c.do(ctx, http.MethodGet, assetPath(walletID, assetID)+"/address", nil, nil, http.StatusOK, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateDepositAddress creates a deposit address for an asset of the wallet
+func (c *Client) CreateDepositAddress(ctx context.Context, walletID, assetID string, req CreateDepositAddressRequest) (*DepositAddressResponse, error) {
+	var out DepositAddressResponse
+	if err := c.do(ctx, http.MethodPost, assetPath(walletID, assetID)+"/addresses", nil, req, http.StatusCreated, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListDepositAddresses returns a page of the deposit addresses of an asset of the wallet, the first one when params
+// is nil. DepositAddresses iterates over all of them.
+func (c *Client) ListDepositAddresses(ctx context.Context, walletID, assetID string, params *ListDepositAddressesParams) (*ListDepositAddressesResponse, error) {
+	query := url.Values{}
+	if params != nil {
+		if params.Before != "" {
+			query.Set("before", params.Before)
+		}
+		if params.After != "" {
+			query.Set("after", params.After)
+		}
+		if params.Limit != 0 {
+			query.Set("limit", strconv.Itoa(params.Limit))
+		}
+	}
+
+	var out ListDepositAddressesResponse
+	if err := c.do(ctx, http.MethodGet, assetPath(walletID, assetID)+"/addresses", query, nil, http.StatusOK, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DepositAddresses iterates over the deposit addresses of an asset of the wallet, fetching pages of pageSize, or the
+// Fireblocks default when 0, as it goes. The iteration stops after yielding an error.
+func (c *Client) DepositAddresses(ctx context.Context, walletID, assetID string, pageSize int) iter.Seq2[DepositAddressResponse, error] {
+	return func(yield func(DepositAddressResponse, error) bool) {
+		params := &ListDepositAddressesParams{Limit: pageSize}
+		for {
+			page, err := c.ListDepositAddresses(ctx, walletID, assetID, params)
+			if err != nil {
+				yield(DepositAddressResponse{}, err)
+				return
+			}
+			for _, address := range page.Addresses {
+				if !yield(address, nil) {
+					return
+				}
+			}
+			if page.Paging.After == "" {
+				return
+			}
+			params.After = page.Paging.After
+		}
+	}
+}
+
+// InitiateTransfer submits a transfer from the wallet to an address. Retries, including those of a caller using
+// WithIdempotencyKey, cannot submit it twice.
+func (c *Client) InitiateTransfer(ctx context.Context, walletID string, req InitiateTransferRequest) (*InitiateTransferResponse, error) {
+	var out InitiateTransferResponse
+	if err := c.do(ctx, http.MethodPost, "/wallets/"+url.PathEscape(walletID)+"/transactions", nil, req, http.StatusCreated, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreatePayout accepts a payout from the wallet, whose lines the service then submits one by one. GetPayout reports
+// how far it went.
+func (c *Client) CreatePayout(ctx context.Context, walletID string, req CreatePayoutRequest) (*PayoutResponse, error) {
+	var out PayoutResponse
+	if err := c.do(ctx, http.MethodPost, "/wallets/"+url.PathEscape(walletID)+"/payouts", nil, req, http.StatusAccepted, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPayout returns a payout of the wallet with the status of each of its lines
+func (c *Client) GetPayout(ctx context.Context, walletID, payoutID string) (*PayoutResponse, error) {
+	var out PayoutResponse
+	if err := c.do(ctx, http.MethodGet, "/wallets/"+url.PathEscape(walletID)+"/payouts/"+url.PathEscape(payoutID), nil, nil, http.StatusOK, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateSchedule schedules a transfer from the wallet, once at RunAt or recurring on the Cron expression. The balance
+// is only checked when the transfer runs, ListScheduleRuns reports the outcome of each run.
+func (c *Client) CreateSchedule(ctx context.Context, walletID string, req CreateScheduleRequest) (*ScheduleResponse, error) {
+	var out ScheduleResponse
+	if err := c.do(ctx, http.MethodPost, schedulesPath(walletID), nil, req, http.StatusCreated, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListSchedules returns the scheduled transfers of the wallet, oldest first
+func (c *Client) ListSchedules(ctx context.Context, walletID string) (*ListSchedulesResponse, error) {
+	var out ListSchedulesResponse
+	if err := c.do(ctx, http.MethodGet, schedulesPath(walletID), nil, nil, http.StatusOK, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PauseSchedule stops a scheduled transfer from running until ResumeSchedule
+func (c *Client) PauseSchedule(ctx context.Context, walletID, scheduleID string) (*ScheduleResponse, error) {
+	var out ScheduleResponse
+	if err := c.do(ctx, http.MethodPost, schedulesPath(walletID)+"/"+url.PathEscape(scheduleID)+"/pause", nil, nil, http.StatusOK, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ResumeSchedule activates a paused scheduled transfer again
+func (c *Client) ResumeSchedule(ctx context.Context, walletID, scheduleID string) (*ScheduleResponse, error) {
+	var out ScheduleResponse
+	if err := c.do(ctx, http.MethodPost, schedulesPath(walletID)+"/"+url.PathEscape(scheduleID)+"/resume", nil, nil, http.StatusOK, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteSchedule deletes a scheduled transfer, its runs are kept
+func (c *Client) DeleteSchedule(ctx context.Context, walletID, scheduleID string) error {
+	return c.do(ctx, http.MethodDelete, schedulesPath(walletID)+"/"+url.PathEscape(scheduleID), nil, nil, http.StatusNoContent, nil)
+}
+
+// ListScheduleRuns returns the latest runs of a scheduled transfer, newest first, the default number of them when
+// limit is 0
+func (c *Client) ListScheduleRuns(ctx context.Context, walletID, scheduleID string, limit int) (*ListScheduleRunsResponse, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var out ListScheduleRunsResponse
+	if err := c.do(ctx, http.MethodGet, schedulesPath(walletID)+"/"+url.PathEscape(scheduleID)+"/runs", query, nil, http.StatusOK, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func schedulesPath(walletID string) string {
+	return "/wallets/" + url.PathEscape(walletID) + "/schedules"
+}
+
+func assetPath(walletID, assetID string) string {
+	return "/wallets/" + url.PathEscape(walletID) + "/assets/" + url.PathEscape(assetID)
+}
@@ -0,0 +1,117 @@
+// Package walletapi is the typed client of the wallet service API. The types and the Client methods in client.gen.go
+// are generated from the OpenAPI specification served at /openapi.json, this file holds what they build on.
+package walletapi
+
+//go:generate go run ../../cmd/openapi-client-gen -package walletapi -o client.gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxErrorBytes bounds how much of an error response is kept as the message
+const maxErrorBytes = 4 << 10
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	header     http.Header
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces the default HTTP client, which times out after 30 seconds
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithHeader sets a header on every request, e.g. the X-Actor the audit log records or the X-API-Key the rate
+// limits are counted for
+func WithHeader(name, value string) Option {
+	return func(c *Client) {
+		c.header.Set(name, value)
+	}
+}
+
+// NewClient creates a client of the service at baseURL, e.g. http://localhost:8080
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		header:     make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is returned when the service answers with another status code than the operation's success one
+type Error struct {
+	StatusCode int
+	// Code is the error code of the JSON error responses, empty for the other ones
+	Code string
+	// Message is the error message the service answered with
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("wallet service answered %d: %s", e.StatusCode, e.Message)
+}
+
+// do sends a request with the JSON encoded body, if not nil, and decodes the response into out when the service
+// answers with wantStatus
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any, wantStatus int, out any) error {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(bodyBytes)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reqBody)
+	if err != nil {
+		return err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBytes))
+		var errResp ErrorResponse
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && json.Unmarshal(message, &errResp) == nil {
+			return &Error{StatusCode: resp.StatusCode, Code: errResp.Code, Message: errResp.Message}
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
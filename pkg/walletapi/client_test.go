@@ -0,0 +1,95 @@
+package walletapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"firego-wallet-service/internal/apierror"
+	"firego-wallet-service/internal/openapi"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGeneratedClientIsUpToDate(t *testing.T) {
+	want, err := openapi.GenerateClient(openapi.MustLoad(), "walletapi")
+	assert.NoError(t, err)
+	got, err := os.ReadFile("client.gen.go")
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), string(got), "client.gen.go is stale, run go generate ./pkg/walletapi")
+}
+
+func TestClient(t *testing.T) {
+	var gotRequest *http.Request
+	var gotBody []byte
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /wallets/{walletId}/transactions", func(w http.ResponseWriter, r *http.Request) {
+		gotRequest = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(InitiateTransferResponse{TransactionID: "tx-1", Status: "SUBMITTED", AssetID: "BTC_TEST", Amount: "0.5"})
+	})
+	mux.HandleFunc("GET /admin/audit", func(w http.ResponseWriter, r *http.Request) {
+		gotRequest = r
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(ListAuditEntriesResponse{Entries: []AuditEntryResponse{{Sequence: 7}}, NextBefore: 7})
+	})
+	mux.HandleFunc("GET /wallets/{walletId}/deposits", func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.WalletNotFound, "Wallet not found")
+	})
+	mux.HandleFunc("GET /wallets/{walletId}/ledger/entries", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Bad gateway", http.StatusBadGateway)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(server.URL+"/", WithHeader("X-Actor", "payouts"))
+
+	t.Run("body_and_path", func(t *testing.T) {
+		transfer, err := client.InitiateTransfer(ctx, "wallet/1", InitiateTransferRequest{AssetID: "BTC_TEST", Amount: "0.5", DestinationAddress: "tb1q"})
+		assert.NoError(t, err)
+		assert.Equal(t, "tx-1", transfer.TransactionID)
+		assert.Equal(t, "/wallets/wallet%2F1/transactions", gotRequest.URL.EscapedPath())
+		assert.Equal(t, "payouts", gotRequest.Header.Get("X-Actor"))
+		assert.Equal(t, "application/json", gotRequest.Header.Get("Content-Type"))
+		assert.JSONEq(t, `{"assetId": "BTC_TEST", "amount": "0.5", "destinationAddress": "tb1q"}`, string(gotBody))
+	})
+
+	t.Run("query", func(t *testing.T) {
+		entries, err := client.ListAuditEntries(ctx, &ListAuditEntriesParams{
+			Outcome: "FAILURE",
+			Since:   time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+			Before:  42,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), entries.NextBefore)
+		assert.Equal(t, "before=42&outcome=FAILURE&since=2024-01-01T12%3A00%3A00Z", gotRequest.URL.RawQuery)
+
+		_, err = client.ListAuditEntries(ctx, nil)
+		assert.NoError(t, err)
+		assert.Empty(t, gotRequest.URL.RawQuery)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := client.ListDeposits(ctx, "wallet-1")
+		var apiErr *Error
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal(t, "wallet_not_found", apiErr.Code)
+		assert.Equal(t, "Wallet not found", apiErr.Message)
+
+		// errors of proxies in front of the service are not JSON
+		_, err = client.ListLedgerEntries(ctx, "wallet-1", nil)
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+		assert.Empty(t, apiErr.Code)
+		assert.Equal(t, "Bad gateway", apiErr.Message)
+	})
+}